@@ -0,0 +1,231 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+/*
+  Archive, as written by noms backup:
+    Magic     // archiveMagic
+    Chunks    // gzipped chunks, as written by chunks.Serialize
+    Manifest  // gzipped manifest
+    Offset    // 8-byte offset of Manifest
+    Magic     // archiveMagic
+
+  Manifest:
+    Len       // 4-byte length of Info
+    Info      // archiveInfo as JSON
+    Hashes    // 20-byte hashes of the chunks in the archive and its bases
+
+  The manifest is at the end, as the chunks aren't known until they've been
+  written, and can be read without reading the chunks, which is all an
+  incremental backup needs of the archive it's based on.
+*/
+
+const archiveMagic = "NOMSAR01"
+
+// archiveInfo describes the database an archive is a backup of. If Base
+// isn't empty, the archive is incremental: it only has the chunks which
+// weren't in the archive of the root Base, and its bases.
+type archiveInfo struct {
+	Root    string    `json:"root"`
+	Base    string    `json:"base,omitempty"`
+	Created time.Time `json:"created"`
+	Chunks  int       `json:"chunks"`
+	Bytes   uint64    `json:"bytes"`
+}
+
+// archiveManifest is the info of an archive and the hashes of the chunks in
+// it and its bases.
+type archiveManifest struct {
+	archiveInfo
+	hashes hash.HashSet
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// writeArchive writes the chunks reachable from root in cs to w, leaving out
+// the chunks in base, if it isn't nil.
+func writeArchive(w io.Writer, cs chunks.ChunkStore, root hash.Hash, base *archiveManifest) (archiveInfo, error) {
+	info := archiveInfo{Root: root.String(), Created: time.Now().UTC()}
+	seen := hash.HashSet{}
+	if base != nil {
+		info.Base = base.Root
+		seen = base.hashes
+	}
+
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, archiveMagic); err != nil {
+		return info, err
+	}
+	gw := gzip.NewWriter(cw)
+	walkChunks(cs, root, seen, func(c chunks.Chunk) {
+		chunks.Serialize(c, gw)
+		info.Chunks++
+		info.Bytes += uint64(len(c.Data()))
+	})
+	if err := gw.Close(); err != nil {
+		return info, err
+	}
+
+	offset := cw.n
+	gw = gzip.NewWriter(cw)
+	data, err := json.Marshal(info)
+	if err != nil {
+		return info, err
+	}
+	if err := binary.Write(gw, binary.BigEndian, uint32(len(data))); err != nil {
+		return info, err
+	}
+	if _, err := gw.Write(data); err != nil {
+		return info, err
+	}
+	for h := range seen {
+		if _, err := gw.Write(h[:]); err != nil {
+			return info, err
+		}
+	}
+	if err := gw.Close(); err != nil {
+		return info, err
+	}
+	if err := binary.Write(cw, binary.BigEndian, uint64(offset)); err != nil {
+		return info, err
+	}
+	_, err = io.WriteString(cw, archiveMagic)
+	return info, err
+}
+
+// archiveFile is an archive open for reading.
+type archiveFile struct {
+	f              *os.File
+	manifestOffset int64
+}
+
+func openArchive(name string) (*archiveFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	a := &archiveFile{f: f}
+	if err := a.readFooter(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a noms archive: %s", name, err)
+	}
+	return a, nil
+}
+
+func (a *archiveFile) readFooter() error {
+	magic := make([]byte, len(archiveMagic))
+	if _, err := io.ReadFull(a.f, magic); err != nil {
+		return err
+	}
+	if string(magic) != archiveMagic {
+		return errors.New("bad magic")
+	}
+	footer := make([]byte, 8+len(archiveMagic))
+	end, err := a.f.Seek(-int64(len(footer)), io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(a.f, footer); err != nil {
+		return err
+	}
+	if string(footer[8:]) != archiveMagic {
+		return errors.New("bad magic")
+	}
+	a.manifestOffset = int64(binary.BigEndian.Uint64(footer))
+	if a.manifestOffset < int64(len(archiveMagic)) || a.manifestOffset > end {
+		return errors.New("bad manifest offset")
+	}
+	return nil
+}
+
+func (a *archiveFile) Close() error {
+	return a.f.Close()
+}
+
+// manifest reads the manifest of the archive. The hashes of its chunks are
+// only read if withHashes is set.
+func (a *archiveFile) manifest(withHashes bool) (archiveManifest, error) {
+	m := archiveManifest{}
+	if _, err := a.f.Seek(a.manifestOffset, io.SeekStart); err != nil {
+		return m, err
+	}
+	gr, err := gzip.NewReader(bufio.NewReader(a.f))
+	if err != nil {
+		return m, err
+	}
+	defer gr.Close()
+	// The footer follows the manifest.
+	gr.Multistream(false)
+
+	var n uint32
+	if err := binary.Read(gr, binary.BigEndian, &n); err != nil {
+		return m, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(gr, data); err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m.archiveInfo); err != nil {
+		return m, err
+	}
+	if !withHashes {
+		return m, nil
+	}
+
+	m.hashes = hash.HashSet{}
+	var h hash.Hash
+	for {
+		if _, err := io.ReadFull(gr, h[:]); err == io.EOF {
+			return m, nil
+		} else if err != nil {
+			return m, err
+		}
+		m.hashes.Insert(h)
+	}
+}
+
+// readChunks sends the chunks in the archive to found, and closes it.
+func (a *archiveFile) readChunks(found chan<- *chunks.Chunk) (err error) {
+	defer close(found)
+	// chunks.Deserialize panics if a chunk doesn't match its hash.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("corrupt chunk: %v", r)
+		}
+	}()
+	start := int64(len(archiveMagic))
+	sr := io.NewSectionReader(a.f, start, a.manifestOffset-start)
+	gr, err := gzip.NewReader(bufio.NewReader(sr))
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	return chunks.Deserialize(gr, found)
+}
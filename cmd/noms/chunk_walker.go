@@ -0,0 +1,42 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// walkChunks calls cb with each chunk reachable from root, including root,
+// which isn't in seen yet, and adds them to seen. Chunks are read a level of
+// the graph at a time.
+func walkChunks(cs chunks.ChunkStore, root hash.Hash, seen hash.HashSet, cb func(c chunks.Chunk)) {
+	if root.IsEmpty() || seen.Has(root) {
+		return
+	}
+
+	next := hash.HashSet{root: struct{}{}}
+	for len(next) > 0 {
+		for h := range next {
+			seen.Insert(h)
+		}
+		found := make(chan *chunks.Chunk, len(next))
+		go func(hashes hash.HashSet) {
+			defer close(found)
+			cs.GetMany(hashes, found)
+		}(next)
+
+		next = hash.HashSet{}
+		for c := range found {
+			cb(*c)
+			types.DecodeValue(*c, nil).WalkRefs(func(r types.Ref) {
+				if h := r.TargetHash(); !seen.Has(h) {
+					next.Insert(h)
+				}
+			})
+		}
+	}
+}
@@ -17,17 +17,47 @@ import (
 )
 
 var commands = []*util.Command{
+	nomsAudit,
+	nomsBackup,
+	nomsBisect,
+	nomsBranch,
+	nomsCheckout,
+	nomsChunk,
+	nomsCodegen,
 	nomsCommit,
+	nomsCompletion,
 	nomsConfig,
 	nomsDiff,
 	nomsDs,
+	nomsDump,
+	nomsEdit,
+	nomsExport,
+	nomsGC,
+	nomsGrep,
+	nomsHotspots,
+	nomsJSON,
+	nomsLoad,
 	nomsLog,
 	nomsMerge,
+	nomsMigrate,
+	nomsNBS,
+	nomsPin,
+	nomsQuery,
+	nomsRedact,
+	nomsReflog,
+	nomsRefs,
+	nomsRestore,
 	nomsRoot,
 	nomsServe,
+	nomsShell,
 	nomsShow,
+	nomsSnapshot,
+	nomsSQLServer,
+	nomsStats,
 	nomsSync,
+	nomsTag,
 	nomsVersion,
+	nomsWatch,
 }
 
 var actions = []string{
@@ -60,6 +90,11 @@ func main() {
 		return
 	}
 
+	if args[0] == completeCommand {
+		runComplete(args[1:])
+		return
+	}
+
 	if args[0] == "help" {
 		util.Help(args[1:])
 		return
@@ -0,0 +1,56 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsAudit = &util.Command{
+	Run:       runAudit,
+	UsageLine: "audit <dataset> [<hash>...]",
+	Short:     "Verifies an audit log written by noms serve --audit",
+	Long: `Checks that the entries of the audit log in <dataset> form an unbroken chain of updates of the root, and prints the number of entries, the head of the log, and the root its last entry updated to. Given the hashes of heads of the log recorded before, it also checks that they're still part of the log, which they wouldn't be if it had been rewritten since.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.`,
+	Flags: setupAuditFlags,
+	Nargs: 1,
+}
+
+func setupAuditFlags() *flag.FlagSet {
+	auditFlagSet := flag.NewFlagSet("audit", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(auditFlagSet)
+	return auditFlagSet
+}
+
+func runAudit(args []string) int {
+	known := []hash.Hash{}
+	for _, str := range args[1:] {
+		h, ok := hash.MaybeParse(strings.TrimPrefix(str, "#"))
+		if !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Invalid hash: %s", str))
+		}
+		known = append(known, h)
+	}
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[0])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	v, err := datas.VerifyAuditLog(ds, known...)
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("Verified %d entries\nhead #%s\nroot #%s\n", v.Entries, v.Head, v.Root)
+	return 0
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsAudit(t *testing.T) {
+	suite.Run(t, &nomsAuditTestSuite{})
+}
+
+type nomsAuditTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsAuditTestSuite) TestAudit() {
+	a, b := hash.Of([]byte("a")), hash.Of([]byte("b"))
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	log := datas.NewAuditLog(db, "audit")
+	s.NoError(log.Append(datas.AuditEntry{Old: a.String(), New: b.String(), Date: "2017-01-01T00:00:00Z"}))
+	head := db.GetDataset("audit").HeadRef().TargetHash().String()
+	db.Close()
+
+	ds := spec.CreateValueSpecString("nbs", s.DBDir, "audit")
+	out, _ := s.MustRun(main, []string{"audit", ds, "#" + head})
+	s.Equal("Verified 1 entries\nhead #"+head+"\nroot #"+b.String()+"\n", out)
+
+	_, stderr, recovered := s.Run(main, []string{"audit", ds, a.String()})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "doesn't contain "+a.String())
+}
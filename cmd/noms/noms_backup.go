@@ -0,0 +1,81 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var backupIncremental string
+
+var nomsBackup = &util.Command{
+	Run:       runBackup,
+	UsageLine: "backup [--incremental <archive>] <database> <file>",
+	Short:     "Writes a database to an archive file",
+	Long: `Writes everything reachable from the root of <database> to <file>, a single compressed archive which "noms restore" reads back, conventionally named *.nomsar.
+
+With --incremental, only the data which isn't in <archive>, or the archives it's based on, is written. Restoring it needs these archives to be restored first. Only the end of <archive>, its manifest, is read.
+
+Only databases with a local chunk store, such as nbs, are supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupBackupFlags,
+	Nargs: 2,
+}
+
+func setupBackupFlags() *flag.FlagSet {
+	backupFlagSet := flag.NewFlagSet("backup", flag.ExitOnError)
+	backupFlagSet.StringVar(&backupIncremental, "incremental", "", "archive to write an incremental backup on")
+	verbose.RegisterVerboseFlags(backupFlagSet)
+	return backupFlagSet
+}
+
+func runBackup(args []string) int {
+	var base *archiveManifest
+	if backupIncremental != "" {
+		a, err := openArchive(backupIncremental)
+		d.CheckErrorNoUsage(err)
+		m, err := a.manifest(true)
+		a.Close()
+		d.CheckErrorNoUsage(err)
+		base = &m
+	}
+
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(args[0])
+	d.CheckErrorNoUsage(err)
+	if cs == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have a local chunk store, use noms sync to copy it to one first", args[0]))
+	}
+	defer cs.Close()
+
+	// The archive is written next to the file, so that an existing file isn't
+	// replaced by a partial archive.
+	tmp := args[1] + ".tmp"
+	f, err := os.Create(tmp)
+	d.CheckErrorNoUsage(err)
+	info, err := writeArchive(f, cs, cs.Root(), base)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		d.CheckErrorNoUsage(err)
+	}
+	d.CheckErrorNoUsage(os.Rename(tmp, args[1]))
+
+	fmt.Printf("Backed up #%s to %s: %d chunks (%s)", info.Root, args[1], info.Chunks, humanize.Bytes(info.Bytes))
+	if info.Base != "" {
+		fmt.Printf(", incremental on #%s", info.Base)
+	}
+	fmt.Println()
+	return 0
+}
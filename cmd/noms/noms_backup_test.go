@@ -0,0 +1,96 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsBackup(t *testing.T) {
+	suite.Run(t, &nomsBackupTestSuite{})
+}
+
+type nomsBackupTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsBackupTestSuite) commit(dir string, v types.Value) string {
+	db := datas.NewDatabase(nbs.NewLocalStore(dir, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("ds"), v)
+	s.NoError(err)
+	return ds.HeadRef().TargetHash().String()
+}
+
+func (s *nomsBackupTestSuite) TestBackupRestore() {
+	numbers := func(from, to int) types.List {
+		l := types.NewList()
+		for i := from; i < to; i++ {
+			l = l.Append(types.Number(i))
+		}
+		return l
+	}
+	s.commit(s.DBDir, numbers(0, 10000))
+	full := filepath.Join(s.TempDir, "full.nomsar")
+	src := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	out, _ := s.MustRun(main, []string{"backup", src, full})
+	s.Contains(out, "to "+full+": ")
+
+	s.commit(s.DBDir, numbers(0, 10001))
+	inc := filepath.Join(s.TempDir, "inc.nomsar")
+	out, _ = s.MustRun(main, []string{"backup", "--incremental", full, src, inc})
+	s.Contains(out, ", incremental on #")
+
+	fullInfo, incInfo := s.archiveInfo(full), s.archiveInfo(inc)
+	s.Equal(fullInfo.Root, incInfo.Base)
+	s.True(incInfo.Chunks < fullInfo.Chunks)
+
+	dst := spec.CreateDatabaseSpecString("nbs", s.DBDir2)
+	_, stderr, recovered := s.Run(main, []string{"restore", inc, dst})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "is based on #"+fullInfo.Root)
+
+	out, _ = s.MustRun(main, []string{"restore", full, dst})
+	s.Equal("Restored #"+fullInfo.Root+" from "+full+"\n", out)
+	out, _ = s.MustRun(main, []string{"show", dst + "::ds.value[9999]"})
+	s.Equal("9999\n", out)
+
+	out, _ = s.MustRun(main, []string{"restore", full, inc, dst})
+	s.Equal("Database is already at #"+fullInfo.Root+", skipped "+full+"\nRestored #"+incInfo.Root+" from "+inc+"\n", out)
+	out, _ = s.MustRun(main, []string{"show", dst + "::ds.value[10000]"})
+	s.Equal("10000\n", out)
+
+	other := filepath.Join(s.TempDir, "other")
+	s.NoError(os.Mkdir(other, 0777))
+	s.commit(other, types.String("other"))
+	_, stderr, recovered = s.Run(main, []string{"restore", full, spec.CreateDatabaseSpecString("nbs", other)})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "the database isn't empty")
+
+	notArchive := filepath.Join(s.TempDir, "not-an-archive")
+	s.NoError(ioutil.WriteFile(notArchive, []byte("not an archive"), 0644))
+	_, stderr, recovered = s.Run(main, []string{"restore", notArchive, dst})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "is not a noms archive")
+}
+
+func (s *nomsBackupTestSuite) archiveInfo(name string) archiveInfo {
+	a, err := openArchive(name)
+	s.NoError(err)
+	defer a.Close()
+	m, err := a.manifest(false)
+	s.NoError(err)
+	return m.archiveInfo
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/query"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	bisectGood  string
+	bisectCmd   string
+	bisectWhere string
+)
+
+var nomsBisect = &util.Command{
+	Run:       runBisect,
+	UsageLine: "bisect [options] <dataset>",
+	Short:     "Finds the commit which broke a dataset",
+	Long: `Searches the history of <dataset>, or of any other path to a commit, for the first commit which is bad, assuming the commits before it are good and the ones after it are bad. The search follows first parents, from the head back to the --good commit, or to the first commit.
+
+Commits are tested with exactly one of:
+
+  --where <condition>  commits whose value matches the condition are good, e.g. --where "count > 0 AND status = 'ok'". Conditions are like WHERE clauses of noms query, and _value is the value itself.
+  --cmd <command>      commits for which the shell command exits with 0 are good, and ones for which it exits with 125 are skipped. The command is given the spec of the commit in $NOMS_BISECT_COMMIT and its hash in $NOMS_BISECT_HASH.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset and commit arguments.`,
+	Flags: setupBisectFlags,
+	Nargs: 1,
+}
+
+func setupBisectFlags() *flag.FlagSet {
+	bisectFlagSet := flag.NewFlagSet("bisect", flag.ExitOnError)
+	bisectFlagSet.StringVar(&bisectGood, "good", "", "a commit known to be good (defaults to testing the first commit)")
+	bisectFlagSet.StringVar(&bisectCmd, "cmd", "", "shell command which tests a commit")
+	bisectFlagSet.StringVar(&bisectWhere, "where", "", "condition which the values of good commits match")
+	verbose.RegisterVerboseFlags(bisectFlagSet)
+	return bisectFlagSet
+}
+
+type bisectResult int
+
+const (
+	bisectIsGood bisectResult = iota
+	bisectIsBad
+	bisectSkipped
+)
+
+func (r bisectResult) String() string {
+	return [...]string{"good", "bad", "skipped"}[r]
+}
+
+func runBisect(args []string) int {
+	cfg := config.NewResolver()
+	resolved := cfg.ResolvePathSpec(args[0])
+	dbSpec := strings.SplitN(resolved, spec.Separator, 2)[0]
+	db, v, err := cfg.GetPath(resolved)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	bad, ok := v.(types.Struct)
+	if !ok || !datas.IsCommitType(types.TypeOf(bad)) {
+		d.CheckErrorNoUsage(fmt.Errorf("%s does not reference a Commit object", args[0]))
+	}
+
+	var test func(commit types.Struct) (bisectResult, error)
+	switch {
+	case (bisectCmd == "") == (bisectWhere == ""):
+		d.CheckErrorNoUsage(errors.New("expected exactly one of --cmd and --where"))
+	case bisectWhere != "":
+		cond, err := query.ParseCondition(bisectWhere)
+		d.CheckErrorNoUsage(err)
+		test = func(commit types.Struct) (bisectResult, error) {
+			if cond.Match(commit.Get(datas.ValueField)) {
+				return bisectIsGood, nil
+			}
+			return bisectIsBad, nil
+		}
+	default:
+		test = func(commit types.Struct) (bisectResult, error) {
+			return runBisectCmd(dbSpec, commit)
+		}
+	}
+	check := func(commit types.Struct) bisectResult {
+		r, err := test(commit)
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("#%s %s\n", commit.Hash().String(), r)
+		return r
+	}
+
+	var good *types.Struct
+	if bisectGood != "" {
+		g, err := resolveCommit(cfg, db, bisectGood)
+		d.CheckErrorNoUsage(err)
+		good = &g
+	}
+	commits, err := firstParentHistory(db, bad, good)
+	d.CheckErrorNoUsage(err)
+
+	if r := check(bad); r != bisectIsBad {
+		d.CheckErrorNoUsage(fmt.Errorf("#%s is %s, so there's nothing to bisect", bad.Hash().String(), r))
+	}
+	if good != nil {
+		if r := check(*good); r != bisectIsGood {
+			d.CheckErrorNoUsage(fmt.Errorf("the good commit #%s is %s", good.Hash().String(), r))
+		}
+	}
+
+	// commits[lo] is good, or lo is -1 if no commit is known to be good, and
+	// commits[hi] is bad.
+	lo, hi := -1, len(commits)-1
+	if good != nil {
+		lo = 0
+	}
+	fmt.Printf("Bisecting %d commits, about %d steps\n", hi-lo-1, int(math.Ceil(math.Log2(float64(hi-lo)))))
+	skipped := map[int]bool{}
+	for hi-lo > 1 {
+		mid := nextBisectCommit(lo, hi, skipped)
+		if mid < 0 {
+			break
+		}
+		switch check(commits[mid]) {
+		case bisectIsGood:
+			lo = mid
+		case bisectIsBad:
+			hi = mid
+		case bisectSkipped:
+			skipped[mid] = true
+		}
+	}
+
+	if hi-lo > 1 {
+		fmt.Println("\nOnly skipped commits are left to test. The first bad commit is one of:")
+		for i := lo + 1; i <= hi; i++ {
+			fmt.Printf("#%s\n", commits[i].Hash().String())
+		}
+		return 1
+	}
+	fmt.Printf("\n#%s is the first bad commit\n", commits[hi].Hash().String())
+	printCommitSummary(os.Stdout, db, commits[hi])
+	return 0
+}
+
+// firstParentHistory returns the commits from good, or the first commit if
+// good is nil, to bad following first parents, oldest first.
+func firstParentHistory(db datas.Database, bad types.Struct, good *types.Struct) ([]types.Struct, error) {
+	commits := []types.Struct{bad}
+	for c := bad; good == nil || !c.Equals(*good); {
+		parents := commitRefsFromSet(c.Get(datas.ParentsField).(types.Set))
+		if len(parents) == 0 {
+			if good != nil {
+				return nil, fmt.Errorf("#%s isn't a first-parent ancestor of #%s", good.Hash().String(), bad.Hash().String())
+			}
+			break
+		}
+		c = parents[0].TargetValue(db).(types.Struct)
+		commits = append(commits, c)
+	}
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+// nextBisectCommit returns the index of the untested commit between lo and
+// hi which is nearest the middle, or -1 if they've all been skipped.
+func nextBisectCommit(lo, hi int, skipped map[int]bool) int {
+	mid := lo + (hi-lo)/2
+	for delta := 0; mid-delta > lo || mid+delta < hi; delta++ {
+		if i := mid - delta; i > lo && !skipped[i] {
+			return i
+		}
+		if i := mid + delta; i < hi && !skipped[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// runBisectCmd tests commit with the --cmd shell command.
+func runBisectCmd(dbSpec string, commit types.Struct) (bisectResult, error) {
+	cmd := exec.Command("sh", "-c", bisectCmd)
+	cmd.Env = append(os.Environ(),
+		"NOMS_BISECT_COMMIT="+dbSpec+spec.Separator+"#"+commit.Hash().String(),
+		"NOMS_BISECT_HASH="+commit.Hash().String(),
+	)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	err := cmd.Run()
+	if err == nil {
+		return bisectIsGood, nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return bisectIsBad, err
+	}
+	if exitErr.Sys().(syscall.WaitStatus).ExitStatus() == 125 {
+		return bisectSkipped, nil
+	}
+	return bisectIsBad, nil
+}
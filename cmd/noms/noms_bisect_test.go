@@ -0,0 +1,96 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsBisect(t *testing.T) {
+	suite.Run(t, &nomsBisectTestSuite{})
+}
+
+type nomsBisectTestSuite struct {
+	clienttest.ClientTestSuite
+	hashes []string
+}
+
+// SetupSuite commits the numbers 0 to 9 as structs, with the total going
+// negative from 6 on.
+func (s *nomsBisectTestSuite) SetupSuite() {
+	s.ClientTestSuite.SetupSuite()
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	ds := db.GetDataset("ds")
+	for i := 0; i < 10; i++ {
+		total := i
+		if i >= 6 {
+			total = -i
+		}
+		var err error
+		ds, err = db.CommitValue(ds, types.NewStruct("", types.StructData{"total": types.Number(total)}))
+		s.NoError(err)
+		s.hashes = append(s.hashes, ds.HeadRef().TargetHash().String())
+	}
+}
+
+func (s *nomsBisectTestSuite) ds() string {
+	return spec.CreateValueSpecString("nbs", s.DBDir, "ds")
+}
+
+func (s *nomsBisectTestSuite) TestWhere() {
+	out, _ := s.MustRun(main, []string{"bisect", "--where", "total >= 0", s.ds()})
+	s.Equal(`#`+s.hashes[9]+` bad
+Bisecting 9 commits, about 4 steps
+#`+s.hashes[4]+` good
+#`+s.hashes[6]+` bad
+#`+s.hashes[5]+` good
+
+#`+s.hashes[6]+` is the first bad commit
+commit `+s.hashes[6]+`
+Parent: `+s.hashes[5]+`
+0 insertions (0.00%), 0 deletions (0.00%), 1 change (100.00%), (1 field vs 1 field)
+
+`, out)
+}
+
+func (s *nomsBisectTestSuite) TestCmd() {
+	good := spec.CreateValueSpecString("nbs", s.DBDir, "#"+s.hashes[2])
+	// Commits 4 and 5 can't be tested.
+	cmd := `case $NOMS_BISECT_HASH in ` + s.hashes[4] + `|` + s.hashes[5] + `) exit 125;; esac
+case $NOMS_BISECT_COMMIT in *::#` + s.hashes[6] + `|*::#` + s.hashes[7] + `|*::#` + s.hashes[8] + `|*::#` + s.hashes[9] + `) exit 1;; esac`
+	out, _, recovered := s.Run(main, []string{"bisect", "--good", good, "--cmd", cmd, s.ds()})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Equal(`#`+s.hashes[9]+` bad
+#`+s.hashes[2]+` good
+Bisecting 6 commits, about 3 steps
+#`+s.hashes[5]+` skipped
+#`+s.hashes[4]+` skipped
+#`+s.hashes[6]+` bad
+#`+s.hashes[3]+` good
+
+Only skipped commits are left to test. The first bad commit is one of:
+#`+s.hashes[4]+`
+#`+s.hashes[5]+`
+#`+s.hashes[6]+`
+`, out)
+}
+
+func (s *nomsBisectTestSuite) TestErrors() {
+	_, stderr, recovered := s.Run(main, []string{"bisect", "--where", "total < 100", s.ds()})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "is good, so there's nothing to bisect")
+
+	_, stderr, recovered = s.Run(main, []string{"bisect", s.ds()})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "expected exactly one of --cmd and --where")
+}
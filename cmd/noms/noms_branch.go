@@ -0,0 +1,116 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var branchDelete string
+
+var nomsBranch = &util.Command{
+	Run:       runBranch,
+	UsageLine: "branch [<database> | <dataset> <commit> | -d <dataset>]",
+	Short:     "Lists, creates or deletes branches",
+	Long: `A branch is a dataset: a name for a line of history.
+
+With no arguments, lists the branches of <database> and the commits they're at, leaving out tags (see "noms tag"). With two, creates <dataset> at <commit>, which can be any path to a commit in the same database, such as another dataset. Use "noms checkout" to move an existing branch.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database, dataset and commit arguments.`,
+	Flags: setupBranchFlags,
+	Nargs: 0,
+}
+
+func setupBranchFlags() *flag.FlagSet {
+	branchFlagSet := flag.NewFlagSet("branch", flag.ExitOnError)
+	branchFlagSet.StringVar(&branchDelete, "d", "", "branch to delete")
+	verbose.RegisterVerboseFlags(branchFlagSet)
+	return branchFlagSet
+}
+
+func runBranch(args []string) int {
+	cfg := config.NewResolver()
+	switch {
+	case branchDelete != "":
+		db, ds, err := cfg.GetDataset(branchDelete)
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+		d.CheckErrorNoUsage(checkNotTag(ds.ID()))
+
+		head, ok := ds.MaybeHeadRef()
+		if !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Branch %s not found", ds.ID()))
+		}
+		_, err = db.Delete(ds)
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Deleted branch %s (was #%s)\n", ds.ID(), head.TargetHash().String())
+
+	case len(args) <= 1:
+		dbSpec := ""
+		if len(args) == 1 {
+			dbSpec = args[0]
+		}
+		db, err := cfg.GetDatabase(dbSpec)
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		db.Datasets().IterAll(func(k, v types.Value) {
+			if id := string(k.(types.String)); !strings.HasPrefix(id, tagPrefix) {
+				fmt.Fprintf(tw, "%s\t#%s\n", id, v.(types.Ref).TargetHash().String())
+			}
+		})
+		tw.Flush()
+
+	case len(args) == 2:
+		db, ds, err := cfg.GetDataset(args[0])
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+		d.CheckErrorNoUsage(checkNotTag(ds.ID()))
+
+		if head, ok := ds.MaybeHeadRef(); ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Branch %s already exists at #%s, use noms checkout to move it", ds.ID(), head.TargetHash().String()))
+		}
+		commit, err := resolveCommit(cfg, db, args[1])
+		d.CheckErrorNoUsage(err)
+		_, err = db.SetHead(ds, types.NewRef(commit))
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Created branch %s at #%s\n", ds.ID(), commit.Hash().String())
+
+	default:
+		d.CheckErrorNoUsage(errors.New("expected a database, or a dataset and a commit"))
+	}
+	return 0
+}
+
+// resolveCommit returns the commit which str spells, which must be in db.
+func resolveCommit(cfg *config.Resolver, db datas.Database, str string) (types.Struct, error) {
+	commitDB, v, err := cfg.GetPath(str)
+	if err != nil {
+		return types.Struct{}, err
+	}
+	defer commitDB.Close()
+
+	commit, ok := v.(types.Struct)
+	if !ok || !datas.IsCommitType(types.TypeOf(commit)) {
+		return types.Struct{}, fmt.Errorf("%s is not a commit", str)
+	}
+	if db.ReadValue(commit.Hash()) == nil {
+		return types.Struct{}, fmt.Errorf("%s is in another database", str)
+	}
+	return commit, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsBranch(t *testing.T) {
+	suite.Run(t, &nomsBranchTestSuite{})
+}
+
+type nomsBranchTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+// setupBranches commits two values to the dataset "master" and tags the
+// first, and returns the hashes of the commits.
+func setupBranches(s *clienttest.ClientTestSuite) (first, second string) {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("master"), types.Number(1))
+	s.NoError(err)
+	first = ds.HeadRef().TargetHash().String()
+	_, err = db.SetHead(db.GetDataset(tagPrefix+"v1"), ds.HeadRef())
+	s.NoError(err)
+	ds, err = db.CommitValue(ds, types.Number(2))
+	s.NoError(err)
+	return first, ds.HeadRef().TargetHash().String()
+}
+
+func (s *nomsBranchTestSuite) TestBranch() {
+	first, second := setupBranches(&s.ClientTestSuite)
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	branch := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+
+	out, _ := s.MustRun(main, []string{"branch", dbSpec})
+	s.Equal("master  #"+second+"\n", out)
+
+	out, _ = s.MustRun(main, []string{"branch", branch("feature"), spec.CreateHashSpecString("nbs", s.DBDir, hash.Parse(first))})
+	s.Equal("Created branch feature at #"+first+"\n", out)
+	out, _ = s.MustRun(main, []string{"branch", dbSpec})
+	s.Equal("feature  #"+first+"\nmaster   #"+second+"\n", out)
+
+	out, _ = s.MustRun(main, []string{"branch", "-d", branch("feature")})
+	s.Equal("Deleted branch feature (was #"+first+")\n", out)
+	out, _ = s.MustRun(main, []string{"branch", dbSpec})
+	s.Equal("master  #"+second+"\n", out)
+}
+
+func (s *nomsBranchTestSuite) TestBranchErrors() {
+	setupBranches(&s.ClientTestSuite)
+	branch := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+
+	_, stderr, recovered := s.Run(main, []string{"branch", branch("master"), branch("tags/v1")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Branch master already exists")
+
+	_, stderr, recovered = s.Run(main, []string{"branch", branch("other"), branch("master.value")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "is not a commit")
+
+	_, stderr, recovered = s.Run(main, []string{"branch", "-d", branch("tags/v1")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "tags/v1 is a tag")
+
+	_, stderr, recovered = s.Run(main, []string{"branch", "-d", branch("nope")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Branch nope not found")
+}
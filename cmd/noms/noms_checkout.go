@@ -0,0 +1,60 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var checkoutCreate bool
+
+var nomsCheckout = &util.Command{
+	Run:       runCheckout,
+	UsageLine: "checkout [-b] <dataset> <commit>",
+	Short:     "Moves a branch to a commit",
+	Long: `Sets the head of the branch <dataset> to <commit>, which can be any path to a commit in the same database, such as a tag or another dataset. Unlike "noms merge", the new head needn't descend from the old one, so commits only reachable from the old head are no longer part of the branch.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset and commit arguments.`,
+	Flags: setupCheckoutFlags,
+	Nargs: 2,
+}
+
+func setupCheckoutFlags() *flag.FlagSet {
+	checkoutFlagSet := flag.NewFlagSet("checkout", flag.ExitOnError)
+	checkoutFlagSet.BoolVar(&checkoutCreate, "b", false, "create the branch if it doesn't exist")
+	verbose.RegisterVerboseFlags(checkoutFlagSet)
+	return checkoutFlagSet
+}
+
+func runCheckout(args []string) int {
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[0])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	d.CheckErrorNoUsage(checkNotTag(ds.ID()))
+
+	head, ok := ds.MaybeHeadRef()
+	if !ok && !checkoutCreate {
+		d.CheckErrorNoUsage(fmt.Errorf("Branch %s not found, use -b to create it", ds.ID()))
+	}
+	commit, err := resolveCommit(cfg, db, args[1])
+	d.CheckErrorNoUsage(err)
+	_, err = db.SetHead(ds, types.NewRef(commit))
+	d.CheckErrorNoUsage(err)
+
+	if ok {
+		fmt.Printf("Moved branch %s from #%s to #%s\n", ds.ID(), head.TargetHash().String(), commit.Hash().String())
+	} else {
+		fmt.Printf("Created branch %s at #%s\n", ds.ID(), commit.Hash().String())
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsCheckout(t *testing.T) {
+	suite.Run(t, &nomsCheckoutTestSuite{})
+}
+
+type nomsCheckoutTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsCheckoutTestSuite) TestCheckout() {
+	first, second := setupBranches(&s.ClientTestSuite)
+	name := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+
+	out, _ := s.MustRun(main, []string{"checkout", name("master"), name("tags/v1")})
+	s.Equal("Moved branch master from #"+second+" to #"+first+"\n", out)
+	out, _ = s.MustRun(main, []string{"show", name("master.value")})
+	s.Equal("1\n", out)
+
+	_, stderr, recovered := s.Run(main, []string{"checkout", name("other"), name("master")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Branch other not found, use -b to create it")
+
+	out, _ = s.MustRun(main, []string{"checkout", "-b", name("other"), spec.CreateHashSpecString("nbs", s.DBDir, hash.Parse(second))})
+	s.Equal("Created branch other at #"+second+"\n", out)
+
+	_, stderr, recovered = s.Run(main, []string{"checkout", name("tags/v1"), name("master")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "tags/v1 is a tag")
+}
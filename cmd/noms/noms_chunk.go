@@ -0,0 +1,133 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var chunkRaw = false
+
+var nomsChunk = &util.Command{
+	Run:       runChunk,
+	UsageLine: "chunk [--raw] <database> <hash>",
+	Short:     "Shows a chunk of a database",
+	Long: `Shows the size, kind and type of the chunk <hash> of <database>, the chunks it refers to, the value it decodes to and a hexdump of its data, for debugging corruption and seeing where values are split into chunks.
+
+The chunk of a collection which is split into several chunks only refers to the chunks of its subtrees, so its elements aren't shown. With --raw, only the data of the chunk is written, as it's stored.
+
+Remote databases are not supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupChunkFlags,
+	Nargs: 2,
+}
+
+func setupChunkFlags() *flag.FlagSet {
+	chunkFlagSet := flag.NewFlagSet("chunk", flag.ExitOnError)
+	chunkFlagSet.BoolVar(&chunkRaw, "raw", false, "write the data of the chunk only")
+	outputpager.RegisterOutputpagerFlags(chunkFlagSet)
+	verbose.RegisterVerboseFlags(chunkFlagSet)
+	return chunkFlagSet
+}
+
+// getChunk returns the database str and its chunk h, which is parsed with or
+// without a leading '#'.
+func getChunk(str, h string) (datas.Database, chunks.Chunk, error) {
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(str)
+	if err != nil {
+		return nil, chunks.EmptyChunk, err
+	}
+	if cs == nil {
+		return nil, chunks.EmptyChunk, fmt.Errorf("%s is a remote database", str)
+	}
+	db := datas.NewDatabase(cs)
+
+	parsed, ok := hash.MaybeParse(strings.TrimPrefix(h, "#"))
+	if !ok {
+		db.Close()
+		return nil, chunks.EmptyChunk, fmt.Errorf("Invalid hash: %s", h)
+	}
+	c := cs.Get(parsed)
+	if c.IsEmpty() {
+		db.Close()
+		return nil, chunks.EmptyChunk, fmt.Errorf("Chunk #%s not found in %s", parsed, str)
+	}
+	return db, c, nil
+}
+
+func runChunk(args []string) int {
+	db, c, err := getChunk(args[0], args[1])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	if chunkRaw {
+		_, err := os.Stdout.Write(c.Data())
+		d.CheckError(err)
+		return 0
+	}
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+	printChunk(pgr.Writer, c, db)
+	return 0
+}
+
+func printChunk(w io.Writer, c chunks.Chunk, vr types.ValueReader) {
+	v := types.DecodeValue(c, vr)
+	refs := []types.Ref{}
+	v.WalkRefs(func(r types.Ref) {
+		refs = append(refs, r)
+	})
+
+	// The type is last, as it can span several lines.
+	fmt.Fprintf(w, "Hash:   #%s\n", c.Hash())
+	fmt.Fprintf(w, "Size:   %s\n", humanize.Bytes(uint64(len(c.Data()))))
+	fmt.Fprintf(w, "Kind:   %s\n", v.Kind())
+	fmt.Fprintf(w, "Height: %d\n", types.NewRef(v).Height())
+	fmt.Fprintf(w, "Refs:   %d\n", len(refs))
+	fmt.Fprintf(w, "Type:   %s\n", types.TypeOf(v).Describe())
+
+	if len(refs) > 0 {
+		fmt.Fprintln(w)
+		printRefs(w, refs)
+	}
+
+	fmt.Fprintln(w)
+	if col, ok := v.(types.Collection); ok && types.IsChunked(col) {
+		fmt.Fprintf(w, "%d elements in %d subtrees\n", col.Len(), len(refs))
+	} else {
+		types.WriteEncodedValue(w, v)
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w)
+	io.WriteString(w, hex.Dump(c.Data()))
+}
+
+// printRefs writes the hash, height and target type of each of refs.
+func printRefs(w io.Writer, refs []types.Ref) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, r := range refs {
+		fmt.Fprintf(tw, "#%s\t%d\t%s\n", r.TargetHash(), r.Height(), r.TargetType().Describe())
+	}
+	d.PanicIfError(tw.Flush())
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsChunk(t *testing.T) {
+	suite.Run(t, &nomsChunkTestSuite{})
+}
+
+type nomsChunkTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsChunkTestSuite) TestChunkAndRefs() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	l := types.NewList()
+	for i := 0; i < 10000; i++ {
+		l = l.Append(types.Number(i))
+	}
+	lRef := db.WriteValue(l)
+	value := types.NewStruct("Value", types.StructData{
+		"list": lRef,
+		"name": types.String("hello"),
+	})
+	_, err := db.CommitValue(db.GetDataset("ds"), db.WriteValue(value))
+	s.NoError(err)
+	s.NoError(db.Close())
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+
+	out, _ := s.MustRun(main, []string{"chunk", dbSpec, "#" + value.Hash().String()})
+	s.Contains(out, "Hash:   #"+value.Hash().String()+"\n")
+	s.Contains(out, "Kind:   Struct\n")
+	s.Contains(out, "Refs:   1\n")
+	s.Contains(out, "#"+lRef.TargetHash().String()+"  ")
+	s.Contains(out, `name: "hello"`)
+	s.Contains(out, "00000000  ")
+
+	out, _ = s.MustRun(main, []string{"refs", dbSpec, value.Hash().String()})
+	s.Equal(fmt.Sprintf("#%s  %d  List<Number>\n", lRef.TargetHash(), lRef.Height()), out)
+
+	// The list is chunked, so its chunk only refers to those of its subtrees.
+	out, _ = s.MustRun(main, []string{"chunk", dbSpec, lRef.TargetHash().String()})
+	s.Contains(out, "Kind:   List\n")
+	s.Contains(out, "10000 elements in ")
+	s.NotContains(out, "9999")
+
+	out, _ = s.MustRun(main, []string{"refs", dbSpec, lRef.TargetHash().String()})
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		s.True(strings.HasSuffix(line, "  1  List<Number>"), line)
+	}
+
+	out, _ = s.MustRun(main, []string{"chunk", "--raw", dbSpec, value.Hash().String()})
+	s.Equal(string(types.EncodeValue(value, nil).Data()), out)
+
+	_, stderr, recovered := s.Run(main, []string{"chunk", dbSpec, "#00000000000000000000000000000000"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "not found in")
+	_, stderr, recovered = s.Run(main, []string{"refs", dbSpec, "nope"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Invalid hash: nope")
+}
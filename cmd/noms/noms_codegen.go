@@ -0,0 +1,469 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nomdl"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	codegenLang    string
+	codegenPackage string
+	codegenSchema  string
+	codegenName    string
+)
+
+var nomsCodegen = &util.Command{
+	Run:       runCodegen,
+	UsageLine: "codegen [flags] [<path> | --schema <file>]",
+	Short:     "Generates typed accessors for the types of a value or a schema file",
+	Long: `Writes Go code with a type for each named struct type of the value at <path>, or of the types declared by a NomsDL schema file, to stdout. If <path> is a commit, such as the head of a dataset, the types are those of its value. Each type wraps a types.Struct, with a method to get and one to set each field, and a New function which builds one from its required fields. Union fields also have a method to get and one to set each member type of the union. Struct types without names get a type only if a schema file declares them.
+
+With --lang ts, writes TypeScript interfaces of the JSON written by noms show --format=json instead, and a type for each type declared by the schema file, or one called --name for the type of the value at <path>.
+
+Struct types with the same name must be the same type; declare them with different names in a schema file otherwise.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupCodegenFlags,
+	Nargs: 0,
+}
+
+func setupCodegenFlags() *flag.FlagSet {
+	codegenFlagSet := flag.NewFlagSet("codegen", flag.ExitOnError)
+	codegenFlagSet.StringVar(&codegenLang, "lang", "go", "language to generate: go or ts")
+	codegenFlagSet.StringVar(&codegenPackage, "package", "schema", "package of the Go code")
+	codegenFlagSet.StringVar(&codegenSchema, "schema", "", "NomsDL schema file to generate code for the types of")
+	codegenFlagSet.StringVar(&codegenName, "name", "Root", "name of the type of the value at <path>")
+	verbose.RegisterVerboseFlags(codegenFlagSet)
+	return codegenFlagSet
+}
+
+func runCodegen(args []string) int {
+	schema := &nomdl.Schema{Types: map[string]*types.Type{}}
+	switch {
+	case codegenSchema != "" && len(args) > 0:
+		d.CheckErrorNoUsage(errors.New("expected only one of <path> and --schema"))
+	case codegenSchema != "":
+		var err error
+		schema, err = nomdl.ParseSchemaFile(codegenSchema)
+		d.CheckErrorNoUsage(err)
+	case len(args) == 1:
+		cfg := config.NewResolver()
+		db, v, err := cfg.GetPath(args[0])
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+		if v == nil {
+			d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[0]))
+		}
+		t := types.TypeOf(v)
+		if datas.IsCommitType(t) {
+			t = types.TypeOf(v.(types.Struct).Get(datas.ValueField))
+		}
+		schema.Names = []string{codegenName}
+		schema.Types[codegenName] = t
+	default:
+		d.CheckError(errors.New("expected a <path> or --schema"))
+	}
+
+	gen, err := newCodegen(schema)
+	d.CheckErrorNoUsage(err)
+	switch codegenLang {
+	case "go":
+		err = gen.writeGo(os.Stdout, codegenPackage)
+	case "ts":
+		gen.writeTS(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown language %s, expected go or ts", codegenLang)
+	}
+	d.CheckErrorNoUsage(err)
+	return 0
+}
+
+// codegen generates code for the named struct types of a schema, and for
+// the struct types without names it declares, by the names it declares them
+// as.
+type codegen struct {
+	schema *nomdl.Schema
+	// structs are the struct types to generate types for, by the name of the
+	// type.
+	structs map[string]*types.Type
+	// declared are the names of the types generated for the struct types
+	// without names declared by the schema.
+	declared map[*types.Type]string
+}
+
+func newCodegen(schema *nomdl.Schema) (*codegen, error) {
+	gen := &codegen{schema, map[string]*types.Type{}, map[*types.Type]string{}}
+	for _, name := range schema.Names {
+		t := schema.Types[name]
+		if t.TargetKind() == types.StructKind && t.Desc.(types.StructDesc).Name == "" {
+			if err := gen.add(name, t); err != nil {
+				return nil, err
+			}
+			gen.declared[t] = name
+		}
+		if err := gen.collect(t); err != nil {
+			return nil, err
+		}
+	}
+	return gen, nil
+}
+
+func (gen *codegen) add(name string, t *types.Type) error {
+	if other, ok := gen.structs[name]; ok && !other.Equals(t) {
+		return fmt.Errorf("There are two different struct types called %s, declare them with different names in a schema file", name)
+	}
+	gen.structs[name] = t
+	return nil
+}
+
+// collect adds the named struct types in t.
+func (gen *codegen) collect(t *types.Type) error {
+	switch desc := t.Desc.(type) {
+	case types.StructDesc:
+		if desc.Name != "" {
+			if other, ok := gen.structs[desc.Name]; ok && other == t {
+				// Already collected, e.g. t is cyclic.
+				return nil
+			}
+			if err := gen.add(desc.Name, t); err != nil {
+				return err
+			}
+		}
+		var err error
+		desc.IterFields(func(name string, t *types.Type, optional bool) {
+			if err == nil {
+				err = gen.collect(t)
+			}
+		})
+		return err
+	case types.CompoundDesc:
+		for _, t := range desc.ElemTypes {
+			if err := gen.collect(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// structName returns the name of the type generated for the struct type t,
+// if there is one.
+func (gen *codegen) structName(t *types.Type) (string, bool) {
+	switch desc := t.Desc.(type) {
+	case types.StructDesc:
+		if desc.Name != "" {
+			return desc.Name, true
+		}
+		for declared, name := range gen.declared {
+			if declared.Equals(t) {
+				return name, true
+			}
+		}
+	case types.CycleDesc:
+		return string(desc), true
+	}
+	return "", false
+}
+
+func (gen *codegen) structNames() []string {
+	names := make([]string, 0, len(gen.structs))
+	for name := range gen.structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// goConv describes how values of a Noms type are represented in Go.
+type goConv struct {
+	// typ is the Go type of the values, and noms the Go type of the Noms
+	// values, e.g. float64 and types.Number.
+	typ, noms string
+	// wrapper is the name of the generated type of a struct type.
+	wrapper string
+}
+
+// from returns the expression which converts x, of type noms, to typ.
+func (c goConv) from(x string) string {
+	switch {
+	case c.wrapper != "":
+		return fmt.Sprintf("%s{%s}", c.typ, x)
+	case c.typ != c.noms:
+		return fmt.Sprintf("%s(%s)", c.typ, x)
+	}
+	return x
+}
+
+// to returns the expression which converts x, of type typ, to noms.
+func (c goConv) to(x string) string {
+	switch {
+	case c.wrapper != "":
+		return x + ".Struct"
+	case c.typ != c.noms:
+		return fmt.Sprintf("%s(%s)", c.noms, x)
+	}
+	return x
+}
+
+// assert returns the statement which asserts that v is of type noms, as f,
+// and the condition which is true if it is.
+func (c goConv) assert(v string) (string, string) {
+	if c.wrapper != "" {
+		return fmt.Sprintf("f, ok := %s.(types.Struct)", v), fmt.Sprintf("ok && f.Name() == %q", c.wrapper)
+	}
+	return fmt.Sprintf("f, ok := %s.(%s)", v, c.noms), "ok"
+}
+
+func (gen *codegen) goConv(t *types.Type) goConv {
+	switch t.TargetKind() {
+	case types.BoolKind:
+		return goConv{typ: "bool", noms: "types.Bool"}
+	case types.NumberKind:
+		return goConv{typ: "float64", noms: "types.Number"}
+	case types.StringKind:
+		return goConv{typ: "string", noms: "types.String"}
+	case types.BlobKind:
+		return goConv{typ: "types.Blob", noms: "types.Blob"}
+	case types.ListKind:
+		return goConv{typ: "types.List", noms: "types.List"}
+	case types.SetKind:
+		return goConv{typ: "types.Set", noms: "types.Set"}
+	case types.MapKind:
+		return goConv{typ: "types.Map", noms: "types.Map"}
+	case types.RefKind:
+		return goConv{typ: "types.Ref", noms: "types.Ref"}
+	case types.TypeKind:
+		return goConv{typ: "*types.Type", noms: "*types.Type"}
+	case types.StructKind, types.CycleKind:
+		if name, ok := gen.structName(t); ok {
+			return goConv{typ: goName(name), noms: "types.Struct", wrapper: name}
+		}
+		return goConv{typ: "types.Struct", noms: "types.Struct"}
+	}
+	return goConv{typ: "types.Value", noms: "types.Value"}
+}
+
+// goCase returns the suffix of the names of the methods of the member t of
+// a union.
+func (gen *codegen) goCase(t *types.Type) string {
+	if c := gen.goConv(t); c.wrapper != "" {
+		return goName(c.wrapper)
+	}
+	return t.TargetKind().String()
+}
+
+func (gen *codegen) writeGo(w io.Writer, pkg string) error {
+	names := gen.structNames()
+	if len(names) == 0 {
+		return errors.New("There are no struct types to generate code for")
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by noms codegen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	fmt.Fprintf(buf, "import \"github.com/attic-labs/noms/go/types\"\n")
+	for _, name := range names {
+		gen.writeGoStruct(buf, name, gen.structs[name])
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func (gen *codegen) writeGoStruct(w io.Writer, name string, t *types.Type) {
+	typ := goName(name)
+	desc := t.Desc.(types.StructDesc)
+	fmt.Fprintf(w, "\n// %s wraps a Struct of the type %s.\ntype %s struct {\n\ttypes.Struct\n}\n", typ, name, typ)
+
+	params, data := []string{}, []string{}
+	desc.IterFields(func(field string, ft *types.Type, optional bool) {
+		if optional {
+			return
+		}
+		param := goParam(field)
+		if ft.TargetKind() == types.UnionKind {
+			params = append(params, param+" types.Value")
+			data = append(data, fmt.Sprintf("%q: %s,", field, param))
+			return
+		}
+		c := gen.goConv(ft)
+		params = append(params, param+" "+c.typ)
+		data = append(data, fmt.Sprintf("%q: %s,", field, c.to(param)))
+	})
+	fmt.Fprintf(w, "\n// New%s returns a %s of its required fields.\n", typ, typ)
+	fmt.Fprintf(w, "func New%s(%s) %s {\n\treturn %s{types.NewStruct(%q, types.StructData{\n%s\n})}\n}\n",
+		typ, strings.Join(params, ", "), typ, typ, desc.Name, strings.Join(data, "\n"))
+
+	desc.IterFields(func(field string, ft *types.Type, optional bool) {
+		method := goName(field)
+		get := fmt.Sprintf("s.Get(%q)", field)
+		if optional {
+			get = fmt.Sprintf("s.MaybeGet(%q)", field)
+		}
+
+		if ft.TargetKind() == types.UnionKind {
+			if optional {
+				fmt.Fprintf(w, "\n// %s returns the %s field, if it's set.\nfunc (s %s) %s() (types.Value, bool) {\n\treturn %s\n}\n", method, field, typ, method, get)
+			} else {
+				fmt.Fprintf(w, "\n// %s returns the %s field.\nfunc (s %s) %s() types.Value {\n\treturn %s\n}\n", method, field, typ, method, get)
+			}
+			fmt.Fprintf(w, "\n// Set%s returns s with the %s field set to v.\nfunc (s %s) Set%s(v types.Value) %s {\n\treturn %s{s.Set(%q, v)}\n}\n", method, field, typ, method, typ, typ, field)
+
+			seen := map[string]bool{}
+			for _, mt := range ft.Desc.(types.CompoundDesc).ElemTypes {
+				suffix := gen.goCase(mt)
+				if seen[suffix] {
+					continue
+				}
+				seen[suffix] = true
+				c := gen.goConv(mt)
+				assert, cond := c.assert("v")
+				fmt.Fprintf(w, "\n// %s%s returns the %s field, if it's a %s.\nfunc (s %s) %s%s() (v %s, ok bool) {\n", method, suffix, field, suffix, typ, method, suffix, c.typ)
+				if optional {
+					fmt.Fprintf(w, "\tf, _ := %s\n\tif %s; %s {\n", get, strings.Replace(assert, "v.", "f.", 1), cond)
+				} else {
+					fmt.Fprintf(w, "\tif %s; %s {\n", strings.Replace(assert, "v.", get+".", 1), cond)
+				}
+				fmt.Fprintf(w, "\t\treturn %s, true\n\t}\n\treturn\n}\n", c.from("f"))
+				fmt.Fprintf(w, "\n// Set%s%s returns s with the %s field set to the %s v.\nfunc (s %s) Set%s%s(v %s) %s {\n\treturn %s{s.Set(%q, %s)}\n}\n",
+					method, suffix, field, suffix, typ, method, suffix, c.typ, typ, typ, field, c.to("v"))
+			}
+		} else {
+			c := gen.goConv(ft)
+			if optional {
+				assert, _ := c.assert("f")
+				fmt.Fprintf(w, "\n// %s returns the %s field, if it's set.\nfunc (s %s) %s() (v %s, ok bool) {\n", method, field, typ, method, c.typ)
+				fmt.Fprintf(w, "\tif f, ok := %s; ok {\n\t\t%s\n\t\treturn %s, ok\n\t}\n\treturn\n}\n", get, assert, c.from("f"))
+			} else {
+				value := get
+				if c.noms != "types.Value" {
+					value = fmt.Sprintf("%s.(%s)", get, c.noms)
+				}
+				fmt.Fprintf(w, "\n// %s returns the %s field.\nfunc (s %s) %s() %s {\n\treturn %s\n}\n", method, field, typ, method, c.typ, c.from(value))
+			}
+			fmt.Fprintf(w, "\n// Set%s returns s with the %s field set to v.\nfunc (s %s) Set%s(v %s) %s {\n\treturn %s{s.Set(%q, %s)}\n}\n", method, field, typ, method, c.typ, typ, typ, field, c.to("v"))
+		}
+
+		if optional {
+			fmt.Fprintf(w, "\n// Remove%s returns s without the %s field.\nfunc (s %s) Remove%s() %s {\n\treturn %s{s.Delete(%q)}\n}\n", method, field, typ, method, typ, typ, field)
+		}
+	})
+}
+
+// goName returns name as an exported Go identifier, e.g. FirstName for
+// first_name.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '_' })
+	for i, part := range parts {
+		parts[i] = string(unicode.ToUpper(rune(part[0]))) + part[1:]
+	}
+	if len(parts) == 0 {
+		return "X"
+	}
+	return strings.Join(parts, "")
+}
+
+// goParam returns the field name as the name of a parameter, e.g. firstName
+// for first_name.
+func goParam(name string) string {
+	n := goName(name)
+	param := string(unicode.ToLower(rune(n[0]))) + n[1:]
+	if token.Lookup(param).IsKeyword() || param == "types" {
+		param += "_"
+	}
+	return param
+}
+
+func (gen *codegen) writeTS(w io.Writer) {
+	fmt.Fprintf(w, "// Code generated by noms codegen. DO NOT EDIT.\n")
+	for _, name := range gen.structNames() {
+		fmt.Fprintf(w, "\nexport interface %s %s\n", name, gen.tsFields(gen.structs[name], ""))
+	}
+	for _, name := range gen.schema.Names {
+		t := gen.schema.Types[name]
+		if n, ok := gen.structName(t); ok && n == name {
+			continue
+		}
+		fmt.Fprintf(w, "\nexport type %s = %s;\n", name, gen.tsType(t, ""))
+	}
+}
+
+// tsType returns the TypeScript type of the JSON of the values of t, which
+// is indented by indent.
+func (gen *codegen) tsType(t *types.Type, indent string) string {
+	switch desc := t.Desc.(type) {
+	case types.StructDesc:
+		if name, ok := gen.structName(t); ok {
+			return name
+		}
+		return gen.tsFields(t, indent)
+	case types.CycleDesc:
+		return string(desc)
+	case types.CompoundDesc:
+		elems := make([]string, len(desc.ElemTypes))
+		for i, et := range desc.ElemTypes {
+			elems[i] = gen.tsType(et, indent)
+		}
+		switch desc.Kind() {
+		case types.ListKind, types.SetKind:
+			return fmt.Sprintf("Array<%s>", elems[0])
+		case types.MapKind:
+			if desc.ElemTypes[0].TargetKind() == types.StringKind {
+				return fmt.Sprintf("{[key: string]: %s}", elems[1])
+			}
+			return fmt.Sprintf("Array<[%s, %s]>", elems[0], elems[1])
+		case types.RefKind:
+			return "string"
+		case types.UnionKind:
+			if len(elems) == 0 {
+				return "never"
+			}
+			return strings.Join(elems, " | ")
+		}
+	}
+	switch t.TargetKind() {
+	case types.BoolKind:
+		return "boolean"
+	case types.NumberKind:
+		return "number"
+	case types.StringKind, types.BlobKind, types.TypeKind:
+		return "string"
+	}
+	return "any"
+}
+
+func (gen *codegen) tsFields(t *types.Type, indent string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{\n")
+	t.Desc.(types.StructDesc).IterFields(func(field string, ft *types.Type, optional bool) {
+		opt := ""
+		if optional {
+			opt = "?"
+		}
+		fmt.Fprintf(buf, "%s  %s%s: %s;\n", indent, field, opt, gen.tsType(ft, indent+"  "))
+	})
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
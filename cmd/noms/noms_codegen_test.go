@@ -0,0 +1,104 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsCodegen(t *testing.T) {
+	suite.Run(t, &nomsCodegenTestSuite{})
+}
+
+type nomsCodegenTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+const codegenSchemaText = `
+type Person = struct Person {
+	name: String,
+	age: Number,
+	nick?: String,
+	id: Number | String,
+	friend?: Cycle<Person>,
+}
+type Point = struct {
+	x: Number,
+	y: Number,
+}
+`
+
+func (s *nomsCodegenTestSuite) writeSchema() string {
+	p := filepath.Join(s.TempDir, "schema.noms")
+	s.NoError(ioutil.WriteFile(p, []byte(codegenSchemaText), 0644))
+	return p
+}
+
+func (s *nomsCodegenTestSuite) TestGoFromSchema() {
+	stdout, _ := s.MustRun(main, []string{"codegen", "--package", "people", "--schema", s.writeSchema()})
+	s.Contains(stdout, "package people\n")
+	s.Contains(stdout, "type Person struct {\n\ttypes.Struct\n}")
+	s.Contains(stdout, "func NewPerson(age float64, id types.Value, name string) Person {")
+	s.Contains(stdout, "func (s Person) Name() string {")
+	s.Contains(stdout, "func (s Person) SetAge(v float64) Person {")
+	s.Contains(stdout, "func (s Person) Nick() (v string, ok bool) {")
+	s.Contains(stdout, "func (s Person) RemoveNick() Person {")
+	s.Contains(stdout, "func (s Person) IdNumber() (v float64, ok bool) {")
+	s.Contains(stdout, "func (s Person) SetIdString(v string) Person {")
+	s.Contains(stdout, "func (s Person) Friend() (v Person, ok bool) {")
+	s.Contains(stdout, "type Point struct {\n\ttypes.Struct\n}")
+	s.Contains(stdout, "func NewPoint(x float64, y float64) Point {")
+}
+
+func (s *nomsCodegenTestSuite) TestTSFromSchema() {
+	stdout, _ := s.MustRun(main, []string{"codegen", "--lang", "ts", "--schema", s.writeSchema()})
+	s.Contains(stdout, "export interface Person {\n  age: number;\n  friend?: Person;\n  id: number | string;\n  name: string;\n  nick?: string;\n}\n")
+	s.Contains(stdout, "export interface Point {\n  x: number;\n  y: number;\n}\n")
+}
+
+func (s *nomsCodegenTestSuite) TestFromPath() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+	db := sp.GetDatabase()
+	v := types.NewStruct("Person", types.StructData{
+		"name": types.String("Alice"),
+		"age":  types.Number(42),
+	})
+	_, err = db.CommitValue(sp.GetDataset(), v)
+	s.NoError(err)
+
+	str := spec.CreateValueSpecString("nbs", s.DBDir, "ds")
+	stdout, _ := s.MustRun(main, []string{"codegen", str})
+	s.Contains(stdout, "package schema\n")
+	s.Contains(stdout, "func NewPerson(age float64, name string) Person {")
+
+	stdout, _ = s.MustRun(main, []string{"codegen", "--lang", "ts", "--name", "Head", str})
+	s.Contains(stdout, "export interface Person {\n  age: number;\n  name: string;\n}\n")
+	s.Contains(stdout, "export type Head = Person;\n")
+}
+
+func (s *nomsCodegenTestSuite) TestNoStructs() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.Number(42))
+	s.NoError(err)
+
+	str := spec.CreateValueSpecString("nbs", s.DBDir, "ds")
+	stdout, stderr, recovered := s.Run(main, []string{"codegen", str})
+	s.Equal("", stdout)
+	s.Equal("error: There are no struct types to generate code for\n", stderr)
+	if mainErr, ok := recovered.(clienttest.ExitError); s.True(ok) {
+		s.Equal(1, mainErr.Code)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/juju/gnuflag"
+)
+
+// completeCommand is the hidden command which the completion scripts run to
+// get the completions of the last of its arguments, which are the words of
+// the command line after "noms".
+const completeCommand = "__complete"
+
+var nomsCompletion = &util.Command{
+	Run:       runCompletion,
+	UsageLine: "completion <shell>",
+	Short:     "Prints a shell completion script",
+	Long: `Prints the script which completes noms commands, flags, database aliases from .nomsconfig, dataset names and struct fields in paths for <shell>, which is bash, zsh or fish. Dataset names and fields are read from the database being completed.
+
+To enable it, add this to ~/.bashrc or ~/.zshrc:
+
+  source <(noms completion bash)   # or zsh
+
+or for fish, run:
+
+  noms completion fish > ~/.config/fish/completions/noms.fish`,
+	Flags: setupCompletionFlags,
+	Nargs: 1,
+}
+
+func setupCompletionFlags() *flag.FlagSet {
+	return flag.NewFlagSet("completion", flag.ExitOnError)
+}
+
+var completionScripts = map[string]string{
+	// Bash splits words at colons, so the words are split again from the
+	// line, and the part of the completions bash sees as a separate word kept.
+	"bash": `_noms() {
+	local line=${COMP_LINE:0:COMP_POINT}
+	local -a words
+	read -ra words <<< "$line"
+	[[ $line == *[[:space:]] ]] && words+=("")
+	local cur=${words[${#words[@]}-1]}
+	local colons=${cur%"${cur##*:}"}
+	local IFS=$'\n'
+	COMPREPLY=($(noms __complete "${words[@]:1}" 2>/dev/null))
+	COMPREPLY=("${COMPREPLY[@]#"$colons"}")
+	if [[ ${#COMPREPLY[@]} == 1 && ${COMPREPLY[0]} == *[:.] ]]; then
+		compopt -o nospace
+	fi
+}
+complete -o default -F _noms noms
+`,
+	"zsh": `#compdef noms
+_noms() {
+	local -a completions nospace space
+	completions=(${(f)"$(noms __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	for c in $completions; do
+		if [[ $c == *[:.] ]]; then nospace+=($c); else space+=($c); fi
+	done
+	compadd -Q -S '' -a nospace
+	compadd -Q -a space
+	(( ${#completions} )) || _files
+}
+compdef _noms noms
+`,
+	"fish": `function __noms_complete
+	set -l words (commandline -opc) (commandline -ct)
+	noms __complete $words[2..-1] 2>/dev/null
+end
+complete -c noms -f -a '(__noms_complete)'
+`,
+}
+
+func runCompletion(args []string) int {
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("unknown shell %s, expected bash, zsh or fish", args[0]))
+	}
+	fmt.Print(script)
+	return 0
+}
+
+// runComplete prints the completions of the last of words, one per line.
+func runComplete(words []string) {
+	for _, c := range complete(words) {
+		fmt.Println(c)
+	}
+}
+
+// complete returns the completions of the last of words, which are the words
+// of a command line after "noms".
+func complete(words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	cur := words[len(words)-1]
+	if len(words) == 1 || (len(words) == 2 && words[0] == "help") {
+		names := []string{}
+		if len(words) == 1 {
+			names = append(names, "help")
+		}
+		for _, cmd := range commands {
+			names = append(names, cmd.Name())
+		}
+		return withPrefix(cur, names)
+	}
+
+	var cmd *util.Command
+	for _, c := range commands {
+		if c.Name() == words[0] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		return nil
+	}
+	flags := cmd.Flags()
+	if strings.HasPrefix(cur, "-") {
+		if strings.Contains(cur, "=") {
+			return nil
+		}
+		names := []string{}
+		flags.VisitAll(func(f *flag.Flag) {
+			if len(f.Name) == 1 {
+				names = append(names, "-"+f.Name)
+			} else {
+				names = append(names, "--"+f.Name)
+			}
+		})
+		return withPrefix(cur, names)
+	}
+	return completeSpec(cur)
+}
+
+// completeSpec returns the completions of cur as a database alias, dataset or
+// path. Errors opening databases and reading values are ignored, as there's
+// nowhere to report them to.
+func completeSpec(cur string) (completions []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			completions = nil
+		}
+	}()
+
+	cfg := config.NewResolver()
+	db, rest := "", cur
+	if i := strings.LastIndex(cur, spec.Separator); i >= 0 {
+		db, rest = cur[:i], cur[i+len(spec.Separator):]
+	}
+
+	// The name of a field of the struct the path before the last dot spells.
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		base := cur[:len(cur)-len(rest)+i]
+		pathDB, v, err := cfg.GetPath(base)
+		if err != nil {
+			return nil
+		}
+		defer pathDB.Close()
+		s, ok := v.(types.Struct)
+		if !ok {
+			return nil
+		}
+		names := []string{}
+		s.IterFields(func(name string, v types.Value) {
+			names = append(names, base+"."+name)
+		})
+		return withPrefix(cur, names)
+	}
+
+	names := []string{}
+	if db == "" {
+		if c, err := config.FindNomsConfig(); err == nil {
+			for alias := range c.Db {
+				names = append(names, alias+spec.Separator)
+			}
+			if _, ok := c.Db[config.DefaultDbAlias]; !ok {
+				return withPrefix(cur, names)
+			}
+		} else {
+			return nil
+		}
+	}
+	store, err := cfg.GetDatabase(db)
+	if err != nil {
+		return withPrefix(cur, names)
+	}
+	defer store.Close()
+	prefix := cur[:len(cur)-len(rest)]
+	store.Datasets().IterAll(func(k, v types.Value) {
+		names = append(names, prefix+string(k.(types.String)))
+	})
+	return withPrefix(cur, names)
+}
+
+// withPrefix returns the sorted names which start with prefix.
+func withPrefix(prefix string, names []string) []string {
+	matches := []string{}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsCompletion(t *testing.T) {
+	suite.Run(t, &nomsCompletionTestSuite{})
+}
+
+type nomsCompletionTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsCompletionTestSuite) TestCompletionScripts() {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		stdout, _ := s.MustRun(main, []string{"completion", shell})
+		s.Contains(stdout, "noms __complete")
+	}
+	_, _, recovered := s.Run(main, []string{"completion", "csh"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
+
+func (s *nomsCompletionTestSuite) TestCompleteCommandsAndFlags() {
+	stdout, _ := s.MustRun(main, []string{completeCommand, "co"})
+	s.Equal("codegen\ncommit\ncompletion\nconfig\n", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "help", "he"})
+	s.Equal("", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "ds", "--ren"})
+	s.Equal("--rename\n", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "ds", "-"})
+	s.Contains(stdout, "-d\n")
+	s.Contains(stdout, "--copy\n")
+}
+
+func (s *nomsCompletionTestSuite) TestCompleteSpecs() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	_, err := db.CommitValue(db.GetDataset("people"), types.NewStruct("Person", types.StructData{
+		"name": types.String("alice"),
+		"nick": types.String("al"),
+		"age":  types.Number(30),
+	}))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("places"), types.String("sf"))
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	stdout, _ := s.MustRun(main, []string{completeCommand, "show", dbSpec + "::p"})
+	s.Equal(dbSpec+"::people\n"+dbSpec+"::places\n", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "show", dbSpec + "::pe"})
+	s.Equal(dbSpec+"::people\n", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "show", dbSpec + "::people.v"})
+	s.Equal(dbSpec+"::people.value\n", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "show", dbSpec + "::people.value.n"})
+	s.Equal(dbSpec+"::people.value.name\n"+dbSpec+"::people.value.nick\n", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "show", dbSpec + "::places.value.x"})
+	s.Equal("", stdout)
+	stdout, _ = s.MustRun(main, []string{completeCommand, "show", dbSpec + "::missing.value."})
+	s.Equal("", stdout)
+}
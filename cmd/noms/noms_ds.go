@@ -5,37 +5,111 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
-var toDelete string
+var (
+	toDelete string
+	dsRename bool
+	dsCopy   bool
+	dsReset  bool
+)
 
 var nomsDs = &util.Command{
 	Run:       runDs,
-	UsageLine: "ds [<database> | -d <dataset>]",
+	UsageLine: "ds [<database> | -d <dataset> | --rename <dataset> <name> | --copy <dataset> <name> | --reset <dataset> <hash>]",
 	Short:     "Noms dataset management",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and dataset arguments.",
-	Flags:     setupDsFlags,
-	Nargs:     0,
+	Long: `See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and dataset arguments.
+
+--rename moves <dataset> to <name> in the same database, and --copy creates <name> at the head of <dataset>, sharing its history. Both fail if <name> already exists, and update the database in one step, so other clients never see the dataset missing or half copied.
+
+--reset moves <dataset> to the commit <hash>, such as a previous head listed by "noms reflog <dataset>".
+
+With --format=json, yaml or template, each dataset is written with its name and the hash of its head, e.g. --template '{{.name}} {{.head}}'.`,
+	Flags: setupDsFlags,
+	Nargs: 0,
 }
 
 func setupDsFlags() *flag.FlagSet {
 	dsFlagSet := flag.NewFlagSet("ds", flag.ExitOnError)
 	dsFlagSet.StringVar(&toDelete, "d", "", "dataset to delete")
+	dsFlagSet.BoolVar(&dsRename, "rename", false, "rename a dataset")
+	dsFlagSet.BoolVar(&dsCopy, "copy", false, "copy a dataset")
+	dsFlagSet.BoolVar(&dsReset, "reset", false, "move a dataset to a commit")
+	registerOutputFormatFlags(dsFlagSet)
 	verbose.RegisterVerboseFlags(dsFlagSet)
 	return dsFlagSet
 }
 
 func runDs(args []string) int {
 	cfg := config.NewResolver()
-	if toDelete != "" {
+	if dsRename && dsCopy || dsReset && (dsRename || dsCopy) {
+		d.CheckErrorNoUsage(errors.New("only one of --rename, --copy and --reset can be used"))
+	}
+	if dsRename || dsCopy {
+		if len(args) != 2 {
+			d.CheckError(errors.New("expected a dataset and a new name"))
+		}
+		db, set, err := cfg.GetDataset(args[0])
+		d.CheckError(err)
+		defer db.Close()
+		if !datas.DatasetFullRe.MatchString(args[1]) {
+			d.CheckErrorNoUsage(fmt.Errorf("Invalid dataset name: %s", args[1]))
+		}
+
+		op, verb := db.Rename, "Renamed"
+		if dsCopy {
+			op, verb = db.Copy, "Copied"
+		}
+		newSet, err := op(set, args[1])
+		switch err {
+		case datas.ErrDatasetNotFound:
+			err = fmt.Errorf("Dataset %s not found", set.ID())
+		case datas.ErrDatasetExists:
+			err = fmt.Errorf("Dataset %s already exists", args[1])
+		case datas.ErrMergeNeeded:
+			err = fmt.Errorf("Dataset %s was changed by someone else, try again", set.ID())
+		}
+		d.CheckErrorNoUsage(err)
+
+		fmt.Printf("%s %s to %s (#%s)\n", verb, set.ID(), newSet.ID(), newSet.HeadRef().TargetHash().String())
+	} else if dsReset {
+		if len(args) != 2 {
+			d.CheckError(errors.New("expected a dataset and a hash"))
+		}
+		db, set, err := cfg.GetDataset(args[0])
+		d.CheckError(err)
+		defer db.Close()
+
+		h, ok := hash.MaybeParse(strings.TrimPrefix(args[1], "#"))
+		if !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Invalid hash: %s", args[1]))
+		}
+		commit := db.ReadValue(h)
+		if commit == nil || !datas.IsCommitType(types.TypeOf(commit)) {
+			d.CheckErrorNoUsage(fmt.Errorf("#%s is not a commit in the database", h.String()))
+		}
+		was := "was new"
+		if head, ok := set.MaybeHeadRef(); ok {
+			was = "was #" + head.TargetHash().String()
+		}
+		_, err = db.SetHead(set, types.NewRef(commit))
+		d.CheckErrorNoUsage(err)
+
+		fmt.Printf("Reset %s to #%s (%s)\n", set.ID(), h.String(), was)
+	} else if toDelete != "" {
 		db, set, err := cfg.GetDataset(toDelete)
 		d.CheckError(err)
 		defer db.Close()
@@ -54,12 +128,19 @@ func runDs(args []string) int {
 		if len(args) >= 1 {
 			dbSpec = args[0]
 		}
+		rw, err := newRecordWriter(true)
+		d.CheckErrorNoUsage(err)
 		store, err := cfg.GetDatabase(dbSpec)
 		d.CheckError(err)
 		defer store.Close()
 
 		store.Datasets().IterAll(func(k, v types.Value) {
-			fmt.Println(k)
+			if rw == nil {
+				fmt.Println(k)
+				return
+			}
+			rec := map[string]interface{}{"name": string(k.(types.String)), "head": v.(types.Ref).TargetHash().String()}
+			d.CheckErrorNoUsage(rw.write(os.Stdout, rec, rec, nil))
 		})
 	}
 	return 0
@@ -36,6 +36,22 @@ func (s *nomsDsTestSuite) TestEmptyNomsDs() {
 	s.Equal("", rtnVal)
 }
 
+func (s *nomsDsTestSuite) TestNomsDsFormat() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	ds, err := db.CommitValue(db.GetDataset("formatTest"), types.String("Commit Value"))
+	s.NoError(err)
+	h := ds.HeadRef().TargetHash().String()
+	s.NoError(db.Close())
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	rtnVal, _ := s.MustRun(main, []string{"ds", "--format=json", dbSpec})
+	s.Equal(`{"head":"`+h+`","name":"formatTest"}`+"\n", rtnVal)
+	rtnVal, _ = s.MustRun(main, []string{"ds", "--format=yaml", dbSpec})
+	s.Equal(`- head: "`+h+`"`+"\n"+`  name: "formatTest"`+"\n", rtnVal)
+	rtnVal, _ = s.MustRun(main, []string{"ds", "--format=template", "--template={{.name}}: {{.head}}", dbSpec})
+	s.Equal("formatTest: "+h+"\n", rtnVal)
+}
+
 func (s *nomsDsTestSuite) TestNomsDs() {
 	dir := s.DBDir
 
@@ -83,3 +99,55 @@ func (s *nomsDsTestSuite) TestNomsDs() {
 	rtnVal, _ = s.MustRun(main, []string{"ds", dbSpec})
 	s.Equal("", rtnVal)
 }
+
+func (s *nomsDsTestSuite) TestNomsDsRenameCopy() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	ds, err := db.CommitValue(db.GetDataset("a"), types.String("a"))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("b"), types.String("b"))
+	s.NoError(err)
+	h := ds.HeadRef().TargetHash().String()
+	s.NoError(db.Close())
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir2)
+	a := spec.CreateValueSpecString("nbs", s.DBDir2, "a")
+	rtnVal, _ := s.MustRun(main, []string{"ds", "--rename", a, "c"})
+	s.Equal("Renamed a to c (#"+h+")\n", rtnVal)
+	rtnVal, _ = s.MustRun(main, []string{"ds", dbSpec})
+	s.Equal("b\nc\n", rtnVal)
+
+	c := spec.CreateValueSpecString("nbs", s.DBDir2, "c")
+	rtnVal, _ = s.MustRun(main, []string{"ds", "--copy", c, "d"})
+	s.Equal("Copied c to d (#"+h+")\n", rtnVal)
+	rtnVal, _ = s.MustRun(main, []string{"ds", "--format=template", "--template={{.name}} {{.head}}", dbSpec})
+	s.Contains(rtnVal, "c "+h+"\n")
+	s.Contains(rtnVal, "d "+h+"\n")
+
+	_, _, recovered := s.Run(main, []string{"ds", "--rename", c, "b"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	_, _, recovered = s.Run(main, []string{"ds", "--copy", a, "e"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
+
+func (s *nomsDsTestSuite) TestNomsDsReset() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	ds, err := db.CommitValue(db.GetDataset("reset"), types.String("a"))
+	s.NoError(err)
+	first := ds.HeadRef().TargetHash().String()
+	ds, err = db.CommitValue(ds, types.String("b"))
+	s.NoError(err)
+	second := ds.HeadRef().TargetHash().String()
+	s.NoError(db.Close())
+
+	name := spec.CreateValueSpecString("nbs", s.DBDir2, "reset")
+	rtnVal, _ := s.MustRun(main, []string{"ds", "--reset", name, "#" + first})
+	s.Equal("Reset reset to #"+first+" (was #"+second+")\n", rtnVal)
+	rtnVal, _ = s.MustRun(main, []string{"show", name + ".value"})
+	s.Equal("\"a\"\n", rtnVal)
+
+	_, stderr, recovered := s.Run(main, []string{"ds", "--reset", name, "#" + first[:31] + "0"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "is not a commit")
+	_, _, recovered = s.Run(main, []string{"ds", "--reset", "--copy", name, "other"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
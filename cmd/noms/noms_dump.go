@@ -0,0 +1,116 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var dumpRoots string
+
+var nomsDump = &util.Command{
+	Run:       runDump,
+	UsageLine: "dump [--roots <hashes>] <database> <file>",
+	Short:     "Writes the chunks of a database to a chunk stream",
+	Long: `Writes the chunks reachable from the root of <database>, or from the chunks given by --roots, to <file>, or to stdout if it's "-", as a chunk stream, which "noms load" reads back. Chunk streams are conventionally named *.nomscs, and their format is documented at https://github.com/attic-labs/noms/blob/master/doc/chunk-stream.md, so that they can be written and read outside of Noms.
+
+Only databases with a local chunk store, such as nbs, are supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupDumpFlags,
+	Nargs: 2,
+}
+
+func setupDumpFlags() *flag.FlagSet {
+	dumpFlagSet := flag.NewFlagSet("dump", flag.ExitOnError)
+	dumpFlagSet.StringVar(&dumpRoots, "roots", "", "comma-separated hashes of the chunks to write the chunks reachable from, instead of the root of the database")
+	verbose.RegisterVerboseFlags(dumpFlagSet)
+	return dumpFlagSet
+}
+
+func runDump(args []string) int {
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(args[0])
+	d.CheckErrorNoUsage(err)
+	if cs == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have a local chunk store, use noms sync to copy it to one first", args[0]))
+	}
+	defer cs.Close()
+
+	roots := []hash.Hash{cs.Root()}
+	if dumpRoots != "" {
+		roots = roots[:0]
+		for _, str := range strings.Split(dumpRoots, ",") {
+			h, ok := hash.MaybeParse(strings.TrimPrefix(str, "#"))
+			if !ok {
+				d.CheckErrorNoUsage(fmt.Errorf("Invalid hash: %s", str))
+			}
+			if !cs.Has(h) {
+				d.CheckErrorNoUsage(fmt.Errorf("Chunk #%s not found in %s", h, args[0]))
+			}
+			roots = append(roots, h)
+		}
+	}
+
+	if args[1] == "-" {
+		_, err := writeDump(os.Stdout, cs, roots)
+		d.CheckErrorNoUsage(err)
+		return 0
+	}
+
+	// The stream is written next to the file, so that an existing file isn't
+	// replaced by a partial stream.
+	tmp := args[1] + ".tmp"
+	f, err := os.Create(tmp)
+	d.CheckErrorNoUsage(err)
+	n, err := writeDump(f, cs, roots)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		d.CheckErrorNoUsage(err)
+	}
+	d.CheckErrorNoUsage(os.Rename(tmp, args[1]))
+	fmt.Printf("Dumped %d chunks to %s\n", n, args[1])
+	return 0
+}
+
+// writeDump writes the chunks of cs reachable from roots to w as a chunk
+// stream, and returns how many it wrote.
+func writeDump(w io.Writer, cs chunks.ChunkStore, roots []hash.Hash) (n int, err error) {
+	sw, err := chunks.NewStreamWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	seen := hash.HashSet{}
+	for _, root := range roots {
+		walkChunks(cs, root, seen, func(c chunks.Chunk) {
+			if err == nil {
+				err = sw.Write(c)
+				n++
+			}
+		})
+	}
+	if err != nil {
+		return n, err
+	}
+	var nonEmpty []hash.Hash
+	for _, root := range roots {
+		if !root.IsEmpty() {
+			nonEmpty = append(nonEmpty, root)
+		}
+	}
+	return n, sw.Close(nonEmpty...)
+}
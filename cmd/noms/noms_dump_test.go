@@ -0,0 +1,77 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsDump(t *testing.T) {
+	suite.Run(t, &nomsDumpTestSuite{})
+}
+
+type nomsDumpTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsDumpTestSuite) TestDumpLoad() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	l := types.NewList()
+	for i := 0; i < 10000; i++ {
+		l = l.Append(types.Number(i))
+	}
+	r := db.WriteValue(l)
+	_, err := db.CommitValue(db.GetDataset("ds"), r)
+	s.NoError(err)
+	listHash := r.TargetHash()
+	s.NoError(db.Close())
+
+	src := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	dst := spec.CreateDatabaseSpecString("nbs", s.DBDir2)
+	full := filepath.Join(s.TempDir, "full.nomscs")
+	out, _ := s.MustRun(main, []string{"dump", src, full})
+	s.Contains(out, " chunks to "+full)
+
+	// The dump of a whole database can be loaded as a copy of it.
+	out, _ = s.MustRun(main, []string{"load", "--set-root", full, dst})
+	s.Contains(out, ", and moved the root to #")
+	out, _ = s.MustRun(main, []string{"show", spec.CreateValueSpecString("nbs", s.DBDir2, "#"+listHash.String()+"[9999]")})
+	s.Equal("9999\n", out)
+
+	// The dump of a value has its hash as the root, which can be committed.
+	value := filepath.Join(s.TempDir, "value.nomscs")
+	s.MustRun(main, []string{"dump", "--roots", "#" + listHash.String(), src, value})
+	f, err := os.Open(value)
+	s.NoError(err)
+	roots, err := chunks.ReadStream(f, func(c chunks.Chunk) error { return nil })
+	f.Close()
+	s.NoError(err)
+	s.Equal(listHash, roots[0])
+
+	s.NoError(os.Mkdir(filepath.Join(s.TempDir, "db3"), 0777))
+	dst3 := spec.CreateDatabaseSpecString("nbs", s.TempDir+"/db3")
+	out, _ = s.MustRun(main, []string{"load", value, dst3})
+	s.Contains(out, " chunks with roots #"+listHash.String()+"\n")
+	s.MustRun(main, []string{"commit", "#" + listHash.String(), spec.CreateValueSpecString("nbs", s.TempDir+"/db3", "copy")})
+	out, _ = s.MustRun(main, []string{"show", spec.CreateValueSpecString("nbs", s.TempDir+"/db3", "copy.value[5000]")})
+	s.Equal("5000\n", out)
+
+	// A value can't be set as the root, and streams are checked.
+	_, _, recovered := s.Run(main, []string{"load", "--set-root", value, dst3})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.NoError(os.Truncate(value, 100))
+	_, _, recovered = s.Run(main, []string{"load", value, dst3})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
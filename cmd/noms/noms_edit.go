@@ -0,0 +1,376 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/diff"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/jsontonoms"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsEdit = &util.Command{
+	Run:       runEdit,
+	UsageLine: "edit [options] <path>",
+	Short:     "Edits the value at a path in the head of a dataset with $EDITOR",
+	Long: `Writes the value at <path>, which must be under the value of the head of a dataset, e.g. ds.value.name, to a temporary file as JSON and opens it with $VISUAL, $EDITOR or vi. Once the editor exits, the JSON is read back and, if it changed, the value is replaced with it in a new head of the dataset, whose meta has a "path" field set to the path which was edited.
+
+The JSON must have the type of the value it replaces: the Structs in it keep their names and fields, and the elements of Lists, Sets and Maps have to be of the types of those already there. Values of empty collections, and those of type Value, can be of any type. Blobs, Refs, Types and Maps with keys which aren't Strings can't be edited as JSON.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupEditFlags,
+	Nargs: 1,
+}
+
+func setupEditFlags() *flag.FlagSet {
+	editFlagSet := flag.NewFlagSet("edit", flag.ExitOnError)
+	spec.RegisterCommitMetaFlags(editFlagSet)
+	verbose.RegisterVerboseFlags(editFlagSet)
+	return editFlagSet
+}
+
+func runEdit(args []string) int {
+	cfg := config.NewResolver()
+	pathSpec := cfg.ResolvePathSpec(args[0])
+	sp, err := spec.ForPath(pathSpec)
+	d.CheckErrorNoUsage(err)
+	absPath := sp.Path
+	if absPath.Dataset == "" {
+		d.CheckErrorNoUsage(fmt.Errorf("%s isn't in a dataset, only the heads of datasets can be edited", args[0]))
+	}
+	if absPath.IsHistorical() {
+		d.CheckErrorNoUsage(fmt.Errorf("%s is in an earlier commit of %s, only the heads of datasets can be edited", args[0], absPath.Dataset))
+	}
+	if fp, ok := firstPathPart(absPath.Path).(types.FieldPath); !ok || fp.Name != datas.ValueField {
+		d.CheckErrorNoUsage(fmt.Errorf("%s isn't under the value of %s, e.g. %s.value", args[0], absPath.Dataset, absPath.Dataset))
+	}
+	for _, pp := range absPath.Path {
+		if isKeyPathPart(pp) {
+			d.CheckErrorNoUsage(fmt.Errorf("%s is in a key of a Map or Set, which can't be edited", args[0]))
+		}
+	}
+
+	dsSpec := pathSpec[:strings.LastIndex(pathSpec, spec.Separator)] + spec.Separator + absPath.Dataset
+	db, ds, err := cfg.GetDataset(dsSpec)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	head, ok := ds.MaybeHead()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("%s has no head", dsSpec))
+	}
+	old := absPath.Path.Resolve(head)
+	if old == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[0]))
+	}
+
+	data, err := editJSON(old)
+	d.CheckErrorNoUsage(err)
+	edited, err := runEditor(data)
+	d.CheckErrorNoUsage(err)
+	if bytes.Equal(data, edited) {
+		fmt.Println("Nothing changed, not committing")
+		return 0
+	}
+
+	var o interface{}
+	err = json.Unmarshal(edited, &o)
+	if err == nil {
+		var v types.Value
+		if v, err = valueFromEditJSON(o, types.TypeOf(old), nil); err == nil {
+			err = commitEdit(db, ds, absPath.Path, old, v)
+		}
+	}
+	if err != nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s, the head of %s is still #%s", err, dsSpec, ds.HeadRef().TargetHash()))
+	}
+	return 0
+}
+
+// commitEdit commits the head of ds with the value at p replaced by v.
+func commitEdit(db datas.Database, ds datas.Dataset, p types.Path, old, v types.Value) error {
+	if v.Equals(old) {
+		fmt.Println("Nothing changed, not committing")
+		return nil
+	}
+	head := ds.Head()
+	patch := diff.Patch{{Path: p, ChangeType: types.DiffChangeModified, OldValue: old, NewValue: v}}
+	value := diff.Apply(head, patch).(types.Struct).Get(datas.ValueField)
+
+	meta, err := spec.CreateCommitMetaStruct(db, "", "", map[string]string{"path": p.String()}, nil)
+	if err != nil {
+		return err
+	}
+	newDs, err := db.Commit(ds, value, datas.CommitOptions{Meta: meta})
+	if err == datas.ErrMergeNeeded {
+		return fmt.Errorf("The head of %s changed while it was being edited", ds.ID())
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("New head #%v (was #%v)\n", newDs.HeadRef().TargetHash(), ds.HeadRef().TargetHash())
+	return nil
+}
+
+// runEditor opens data in the user's editor and returns it once they're done
+// with it.
+func runEditor(data []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "noms-edit")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor is run by the shell, so that it can have arguments.
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "noms-edit", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Editor %s failed: %s", editor, err)
+	}
+	return ioutil.ReadFile(f.Name())
+}
+
+// editJSON renders v as indented JSON.
+func editJSON(v types.Value) ([]byte, error) {
+	o, err := editJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func editJSONValue(v types.Value) (o interface{}, err error) {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v), nil
+	case types.Number:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case types.List:
+		arr := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value, _ uint64) {
+			if err == nil {
+				var e interface{}
+				e, err = editJSONValue(v)
+				arr = append(arr, e)
+			}
+		})
+		return arr, err
+	case types.Set:
+		arr := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value) {
+			if err == nil {
+				var e interface{}
+				e, err = editJSONValue(v)
+				arr = append(arr, e)
+			}
+		})
+		return arr, err
+	case types.Map:
+		obj := make(map[string]interface{}, v.Len())
+		v.IterAll(func(k, v types.Value) {
+			if err != nil {
+				return
+			}
+			s, ok := k.(types.String)
+			if !ok {
+				err = fmt.Errorf("Maps with keys which aren't Strings can't be edited as JSON")
+				return
+			}
+			obj[string(s)], err = editJSONValue(v)
+		})
+		return obj, err
+	case types.Struct:
+		obj := make(map[string]interface{}, v.Len())
+		v.IterFields(func(name string, v types.Value) {
+			if err == nil {
+				obj[name], err = editJSONValue(v)
+			}
+		})
+		return obj, err
+	}
+	return nil, fmt.Errorf("%s can't be edited as JSON", editTypeDescribe(types.TypeOf(v)))
+}
+
+// valueFromEditJSON converts the decoded JSON o to a value of type t. structs
+// holds the Struct types which t is in, by name, for the cycles in t.
+func valueFromEditJSON(o interface{}, t *types.Type, structs map[string]*types.Type) (types.Value, error) {
+	mismatch := func() error {
+		return fmt.Errorf("%s isn't a %s", editJSONDescribe(o), editTypeDescribe(t))
+	}
+	switch t.TargetKind() {
+	case types.BoolKind:
+		if b, ok := o.(bool); ok {
+			return types.Bool(b), nil
+		}
+	case types.NumberKind:
+		if n, ok := o.(float64); ok {
+			return types.Number(n), nil
+		}
+	case types.StringKind:
+		if s, ok := o.(string); ok {
+			return types.String(s), nil
+		}
+	case types.ValueKind:
+		if o != nil {
+			return jsontonoms.NomsValueFromDecodedJSON(o, true), nil
+		}
+	case types.ListKind, types.SetKind:
+		arr, ok := o.([]interface{})
+		if !ok {
+			return nil, mismatch()
+		}
+		elemType := t.Desc.(types.CompoundDesc).ElemTypes[0]
+		values := make(types.ValueSlice, len(arr))
+		for i, e := range arr {
+			v, err := valueFromEditJSON(e, elemType, structs)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		if t.TargetKind() == types.ListKind {
+			return types.NewList(values...), nil
+		}
+		return types.NewSet(values...), nil
+	case types.MapKind:
+		obj, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, mismatch()
+		}
+		elemTypes := t.Desc.(types.CompoundDesc).ElemTypes
+		kvs := make(types.ValueSlice, 0, 2*len(obj))
+		for k, e := range obj {
+			kv, err := valueFromEditJSON(k, elemTypes[0], structs)
+			if err != nil {
+				return nil, err
+			}
+			v, err := valueFromEditJSON(e, elemTypes[1], structs)
+			if err != nil {
+				return nil, err
+			}
+			kvs = append(kvs, kv, v)
+		}
+		return types.NewMap(kvs...), nil
+	case types.StructKind:
+		obj, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, mismatch()
+		}
+		desc := t.Desc.(types.StructDesc)
+		if desc.Name != "" {
+			inner := make(map[string]*types.Type, len(structs)+1)
+			for name, st := range structs {
+				inner[name] = st
+			}
+			inner[desc.Name] = t
+			structs = inner
+		}
+		data := make(types.StructData, len(obj))
+		var err error
+		desc.IterFields(func(name string, ft *types.Type, optional bool) {
+			if err != nil {
+				return
+			}
+			e, ok := obj[name]
+			if !ok {
+				if !optional {
+					err = fmt.Errorf("%s is missing the field %s", editTypeDescribe(t), name)
+				}
+				return
+			}
+			data[name], err = valueFromEditJSON(e, ft, structs)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for name := range obj {
+			if _, ok := data[name]; !ok {
+				return nil, fmt.Errorf("%s has no field %s", editTypeDescribe(t), name)
+			}
+		}
+		return types.NewStruct(desc.Name, data), nil
+	case types.CycleKind:
+		if st, ok := structs[string(t.Desc.(types.CycleDesc))]; ok {
+			return valueFromEditJSON(o, st, structs)
+		}
+	case types.UnionKind:
+		elemTypes := t.Desc.(types.CompoundDesc).ElemTypes
+		if len(elemTypes) == 0 && o != nil {
+			// The element type of an empty collection.
+			return jsontonoms.NomsValueFromDecodedJSON(o, true), nil
+		}
+		for _, et := range elemTypes {
+			if v, err := valueFromEditJSON(o, et, structs); err == nil {
+				return v, nil
+			}
+		}
+	}
+	return nil, mismatch()
+}
+
+// editJSONDescribe describes the decoded JSON o for errors.
+func editJSONDescribe(o interface{}) string {
+	data, err := json.Marshal(o)
+	d.PanicIfError(err)
+	if len(data) > 40 {
+		return string(data[:37]) + "..."
+	}
+	return string(data)
+}
+
+// editTypeDescribe describes t on one line for errors.
+func editTypeDescribe(t *types.Type) string {
+	return strings.Join(strings.Fields(t.Describe()), " ")
+}
+
+func firstPathPart(p types.Path) types.PathPart {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[0]
+}
+
+func isKeyPathPart(pp types.PathPart) bool {
+	switch pp := pp.(type) {
+	case types.IndexPath:
+		return pp.IntoKey
+	case types.HashIndexPath:
+		return pp.IntoKey
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsEdit(t *testing.T) {
+	suite.Run(t, &nomsEditTestSuite{})
+}
+
+type nomsEditTestSuite struct {
+	clienttest.ClientTestSuite
+	visual, editor string
+}
+
+func (s *nomsEditTestSuite) SetupTest() {
+	s.visual, s.editor = os.Getenv("VISUAL"), os.Getenv("EDITOR")
+	os.Setenv("VISUAL", "")
+}
+
+func (s *nomsEditTestSuite) TearDownTest() {
+	os.Setenv("VISUAL", s.visual)
+	os.Setenv("EDITOR", s.editor)
+}
+
+// setEditor makes the editor replace the file it edits with json, after
+// saving the file it was given to seen, if it isn't empty.
+func (s *nomsEditTestSuite) setEditor(json, seen string) {
+	file := filepath.Join(s.TempDir, "edited.json")
+	s.NoError(ioutil.WriteFile(file, []byte(json), 0644))
+	editor := "cp " + file
+	if seen != "" {
+		editor = `cp "$1" ` + seen + "; " + editor
+	}
+	os.Setenv("EDITOR", editor)
+}
+
+func (s *nomsEditTestSuite) commit(value types.Value) {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), value)
+	s.NoError(err)
+}
+
+func (s *nomsEditTestSuite) head() types.Struct {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+	return sp.GetDataset().Head()
+}
+
+func (s *nomsEditTestSuite) TestEdit() {
+	s.commit(types.NewStruct("Doc", types.StructData{
+		"title": types.String("Helo"),
+		"tags":  types.NewSet(types.String("a")),
+		"count": types.Number(1),
+	}))
+
+	seen := filepath.Join(s.TempDir, "seen.json")
+	s.setEditor(`{"count": 2, "tags": ["a", "b"], "title": "Hello"}`, seen)
+	stdout, _ := s.MustRun(main, []string{"edit", "--message", "fix typo", spec.CreateValueSpecString("nbs", s.DBDir, "ds.value")})
+	s.Contains(stdout, "New head #")
+	data, err := ioutil.ReadFile(seen)
+	s.NoError(err)
+	s.Equal("{\n  \"count\": 1,\n  \"tags\": [\n    \"a\"\n  ],\n  \"title\": \"Helo\"\n}\n", string(data))
+
+	head := s.head()
+	s.True(types.NewStruct("Doc", types.StructData{
+		"title": types.String("Hello"),
+		"tags":  types.NewSet(types.String("a"), types.String("b")),
+		"count": types.Number(2),
+	}).Equals(head.Get("value")))
+	meta := head.Get("meta").(types.Struct)
+	s.Equal(types.String(".value"), meta.Get("path"))
+	s.Equal(types.String("fix typo"), meta.Get("message"))
+
+	s.setEditor(`"Hello, world"`, "")
+	s.MustRun(main, []string{"edit", spec.CreateValueSpecString("nbs", s.DBDir, "ds.value.title")})
+	s.Equal(types.String("Hello, world"), s.head().Get("value").(types.Struct).Get("title"))
+	s.Equal(types.String(".value.title"), s.head().Get("meta").(types.Struct).Get("path"))
+}
+
+func (s *nomsEditTestSuite) TestEditUnchanged() {
+	s.commit(types.NewList(types.Number(1)))
+	h := s.head().Hash()
+	os.Setenv("EDITOR", "true")
+	stdout, _ := s.MustRun(main, []string{"edit", spec.CreateValueSpecString("nbs", s.DBDir, "ds.value")})
+	s.Equal("Nothing changed, not committing\n", stdout)
+	s.Equal(h, s.head().Hash())
+}
+
+func (s *nomsEditTestSuite) TestEditEmptyCollection() {
+	s.commit(types.NewList())
+	s.setEditor(`["a", 1]`, "")
+	s.MustRun(main, []string{"edit", spec.CreateValueSpecString("nbs", s.DBDir, "ds.value")})
+	s.True(types.NewList(types.String("a"), types.Number(1)).Equals(s.head().Get("value")))
+}
+
+func (s *nomsEditTestSuite) TestEditWrongType() {
+	s.commit(types.NewStruct("Doc", types.StructData{"title": types.String("Hi")}))
+	h := s.head().Hash()
+	valueSpec := spec.CreateValueSpecString("nbs", s.DBDir, "ds.value")
+	for _, json := range []string{`{"title": 42}`, `{}`, `{"title": "Hi", "extra": true}`, `["Hi"]`, `{`} {
+		s.setEditor(json, "")
+		_, _, err := s.Run(main, []string{"edit", valueSpec})
+		s.Equal(clienttest.ExitError{Code: 1}, err, json)
+	}
+	s.Equal(h, s.head().Hash())
+}
+
+func (s *nomsEditTestSuite) TestEditBadPath() {
+	s.commit(types.NewStruct("Doc", types.StructData{"title": types.String("Hi")}))
+	os.Setenv("EDITOR", "true")
+	for _, p := range []string{"ds", "ds.meta", "ds.value.nope", "nope.value"} {
+		_, _, err := s.Run(main, []string{"edit", spec.CreateValueSpecString("nbs", s.DBDir, p)})
+		s.Equal(clienttest.ExitError{Code: 1}, err, p)
+	}
+}
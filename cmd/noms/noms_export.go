@@ -0,0 +1,462 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsExport = &util.Command{
+	Run:       runExport,
+	UsageLine: "export sql [flags] <path>",
+	Short:     "Exports a collection of structs as SQL",
+	Long: `Exports the List, Set or Map of structs at <path> as a CREATE TABLE statement followed by INSERT statements, with a row per struct. Map values are exported, descending into nested Maps.
+
+Fields of type Bool, Number, String and Blob become columns. How other fields are exported is set with --nested:
+  columns  flatten fields of nested structs into columns named <field>_<subfield>, export other nested values as JSON
+  json     export nested values as JSON
+  skip     leave nested values out
+and can be set for individual fields with --rules, e.g. --rules address=json,address.geo=skip.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupExportFlags,
+	Nargs: 2,
+}
+
+var (
+	exportTable     string
+	exportDialect   string
+	exportNested    string
+	exportRules     string
+	exportSeparator string
+	exportBatchSize int
+	exportNoCreate  bool
+	exportOut       string
+)
+
+func setupExportFlags() *flag.FlagSet {
+	exportFlagSet := flag.NewFlagSet("export", flag.ExitOnError)
+	exportFlagSet.StringVar(&exportTable, "table", "", "name of the table to create, defaults to the name of the dataset")
+	exportFlagSet.StringVar(&exportDialect, "dialect", "postgres", "SQL dialect to write: postgres, mysql or sqlite")
+	exportFlagSet.StringVar(&exportNested, "nested", "columns", "how to export nested values: columns, json or skip")
+	exportFlagSet.StringVar(&exportRules, "rules", "", "comma-separated list of <field>=<columns|json|skip> overriding --nested for fields, where <field> is a dotted path of field names")
+	exportFlagSet.StringVar(&exportSeparator, "separator", "_", "separator between the field names of flattened columns")
+	exportFlagSet.IntVar(&exportBatchSize, "batch-size", 100, "number of rows in each INSERT statement")
+	exportFlagSet.BoolVar(&exportNoCreate, "no-create", false, "don't write a CREATE TABLE statement")
+	exportFlagSet.StringVar(&exportOut, "out", "", "file to write to, defaults to stdout")
+	return exportFlagSet
+}
+
+func runExport(args []string) int {
+	if args[0] != "sql" {
+		d.CheckErrorNoUsage(fmt.Errorf("unsupported export format %s", args[0]))
+	}
+	dialect, ok := sqlDialects[exportDialect]
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("unknown dialect %s, expected postgres, mysql or sqlite", exportDialect))
+	}
+	rules, err := parseNestedRules(exportNested, exportRules)
+	d.CheckErrorNoUsage(err)
+	table := exportTable
+	if table == "" {
+		if table = defaultExportTable(args[1]); table == "" {
+			d.CheckErrorNoUsage(fmt.Errorf("--table is required to export %s", args[1]))
+		}
+	}
+	if exportBatchSize < 1 {
+		exportBatchSize = 1
+	}
+
+	cfg := config.NewResolver()
+	db, value, err := cfg.GetPath(args[1])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[1]))
+	}
+
+	out := os.Stdout
+	if exportOut != "" {
+		out, err = os.Create(exportOut)
+		d.CheckErrorNoUsage(err)
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+	err = exportSQL(w, value, table, dialect, rules)
+	if err == nil {
+		err = w.Flush()
+	}
+	d.CheckErrorNoUsage(err)
+	return 0
+}
+
+var unsafeTableChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// defaultExportTable returns the name of the dataset of a path spec, made
+// safe for use as a table name, or "" if path isn't rooted at a dataset.
+func defaultExportTable(path string) string {
+	if i := strings.LastIndex(path, spec.Separator); i >= 0 {
+		path = path[i+len(spec.Separator):]
+	}
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" || path[0] == '#' {
+		return ""
+	}
+	return unsafeTableChars.ReplaceAllString(path, "_")
+}
+
+const (
+	nestedColumns = "columns"
+	nestedJSON    = "json"
+	nestedSkip    = "skip"
+)
+
+// nestedRules maps dotted field paths to how they're exported. The "" entry
+// is the default.
+type nestedRules map[string]string
+
+func parseNestedRules(def, rules string) (nestedRules, error) {
+	r := nestedRules{"": def}
+	if rules != "" {
+		for _, rule := range strings.Split(rules, ",") {
+			parts := strings.SplitN(rule, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("invalid rule %s, expected <field>=<columns|json|skip>", rule)
+			}
+			r[parts[0]] = parts[1]
+		}
+	}
+	for field, mode := range r {
+		if mode != nestedColumns && mode != nestedJSON && mode != nestedSkip {
+			if field == "" {
+				return nil, fmt.Errorf("invalid nested mode %s, expected columns, json or skip", mode)
+			}
+			return nil, fmt.Errorf("invalid mode %s for field %s, expected columns, json or skip", mode, field)
+		}
+	}
+	return r, nil
+}
+
+func (r nestedRules) mode(path []string) string {
+	if m, ok := r[strings.Join(path, ".")]; ok {
+		return m
+	}
+	return r[""]
+}
+
+type sqlDialect struct {
+	quote           string
+	colTypes        map[types.NomsKind]string // ValueKind is the type of JSON columns
+	boolean         func(b bool) string
+	blob            func(b []byte) string
+	escapeBackslash bool
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"postgres": {
+		quote: `"`,
+		colTypes: map[types.NomsKind]string{
+			types.BoolKind:   "BOOLEAN",
+			types.NumberKind: "DOUBLE PRECISION",
+			types.StringKind: "TEXT",
+			types.BlobKind:   "BYTEA",
+			types.ValueKind:  "JSONB",
+		},
+		boolean: func(b bool) string { return strings.ToUpper(strconv.FormatBool(b)) },
+		blob:    func(b []byte) string { return `'\x` + hex.EncodeToString(b) + `'` },
+	},
+	"mysql": {
+		quote: "`",
+		colTypes: map[types.NomsKind]string{
+			types.BoolKind:   "BOOLEAN",
+			types.NumberKind: "DOUBLE",
+			types.StringKind: "LONGTEXT",
+			types.BlobKind:   "LONGBLOB",
+			types.ValueKind:  "JSON",
+		},
+		boolean:         func(b bool) string { return strings.ToUpper(strconv.FormatBool(b)) },
+		blob:            func(b []byte) string { return "X'" + hex.EncodeToString(b) + "'" },
+		escapeBackslash: true,
+	},
+	"sqlite": {
+		quote: `"`,
+		colTypes: map[types.NomsKind]string{
+			types.BoolKind:   "BOOLEAN",
+			types.NumberKind: "REAL",
+			types.StringKind: "TEXT",
+			types.BlobKind:   "BLOB",
+			types.ValueKind:  "TEXT",
+		},
+		boolean: func(b bool) string {
+			if b {
+				return "1"
+			}
+			return "0"
+		},
+		blob: func(b []byte) string { return "X'" + hex.EncodeToString(b) + "'" },
+	},
+}
+
+func (dl sqlDialect) ident(s string) string {
+	return dl.quote + strings.Replace(s, dl.quote, dl.quote+dl.quote, -1) + dl.quote
+}
+
+func (dl sqlDialect) str(s string) string {
+	if dl.escapeBackslash {
+		s = strings.Replace(s, `\`, `\\`, -1)
+	}
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// sqlColumn is a column of the exported table, holding the value at path in
+// the exported structs.
+type sqlColumn struct {
+	name     string
+	path     []string
+	kind     types.NomsKind // ValueKind for JSON columns
+	nullable bool
+}
+
+// sqlColumns returns the columns structs described by sd are exported as.
+func sqlColumns(sd types.StructDesc, rules nestedRules, sep string) []sqlColumn {
+	var columns []sqlColumn
+	var walk func(sd types.StructDesc, prefix []string, nullable bool)
+	walk = func(sd types.StructDesc, prefix []string, nullable bool) {
+		sd.IterFields(func(name string, t *types.Type, optional bool) {
+			path := append(append([]string{}, prefix...), name)
+			k := t.TargetKind()
+			if types.IsPrimitiveKind(k) && k != types.ValueKind {
+				columns = append(columns, sqlColumn{strings.Join(path, sep), path, k, nullable || optional})
+				return
+			}
+			switch rules.mode(path) {
+			case nestedColumns:
+				if k == types.StructKind {
+					walk(t.Desc.(types.StructDesc), path, nullable || optional)
+					return
+				}
+				fallthrough
+			case nestedJSON:
+				columns = append(columns, sqlColumn{strings.Join(path, sep), path, types.ValueKind, nullable || optional})
+			}
+		})
+	}
+	walk(sd, nil, false)
+	return columns
+}
+
+// exportStructDesc returns the description of the structs in v, which must be
+// a List or Set of structs or a Map, possibly nested, whose values are
+// structs.
+func exportStructDesc(v types.Value) (types.StructDesc, error) {
+	t := types.TypeOf(v)
+	for {
+		switch t.TargetKind() {
+		case types.ListKind, types.SetKind:
+			t = t.Desc.(types.CompoundDesc).ElemTypes[0]
+		case types.MapKind:
+			t = t.Desc.(types.CompoundDesc).ElemTypes[1]
+		case types.StructKind:
+			return t.Desc.(types.StructDesc), nil
+		default:
+			return types.StructDesc{}, fmt.Errorf("expected a List, Set or Map of structs, found %s", types.TypeOf(v).Describe())
+		}
+	}
+}
+
+func iterExportRows(v types.Value, cb func(s types.Struct) error) (err error) {
+	switch v := v.(type) {
+	case types.List:
+		v.Iter(func(v types.Value, _ uint64) bool {
+			err = cb(v.(types.Struct))
+			return err != nil
+		})
+	case types.Set:
+		v.Iter(func(v types.Value) bool {
+			err = cb(v.(types.Struct))
+			return err != nil
+		})
+	case types.Map:
+		v.Iter(func(_, v types.Value) bool {
+			if s, ok := v.(types.Struct); ok {
+				err = cb(s)
+			} else {
+				err = iterExportRows(v, cb)
+			}
+			return err != nil
+		})
+	}
+	return
+}
+
+func exportSQL(w io.Writer, v types.Value, table string, dl sqlDialect, rules nestedRules) error {
+	sd, err := exportStructDesc(v)
+	if err != nil {
+		return err
+	}
+	columns := sqlColumns(sd, rules, exportSeparator)
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns to export")
+	}
+
+	if !exportNoCreate {
+		fmt.Fprintf(w, "CREATE TABLE %s (\n", dl.ident(table))
+		for i, c := range columns {
+			fmt.Fprintf(w, "  %s %s", dl.ident(c.name), dl.colTypes[c.kind])
+			if !c.nullable {
+				fmt.Fprint(w, " NOT NULL")
+			}
+			if i < len(columns)-1 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, ");\n")
+	}
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = dl.ident(c.name)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", dl.ident(table), strings.Join(names, ", "))
+
+	n := 0
+	values := make([]string, len(columns))
+	err = iterExportRows(v, func(s types.Struct) error {
+		for i, c := range columns {
+			lit, err := sqlLiteral(s, c, dl)
+			if err != nil {
+				return err
+			}
+			values[i] = lit
+		}
+		if n%exportBatchSize == 0 {
+			if n > 0 {
+				fmt.Fprint(w, ";\n")
+			}
+			fmt.Fprint(w, insert)
+		} else {
+			fmt.Fprint(w, ",\n")
+		}
+		_, err := fmt.Fprintf(w, "  (%s)", strings.Join(values, ", "))
+		n++
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Fprint(w, ";\n")
+	}
+	return nil
+}
+
+func sqlLiteral(s types.Struct, c sqlColumn, dl sqlDialect) (string, error) {
+	var v types.Value = s
+	for _, f := range c.path {
+		st, ok := v.(types.Struct)
+		if !ok {
+			return "NULL", nil
+		}
+		if v, ok = st.MaybeGet(f); !ok {
+			return "NULL", nil
+		}
+	}
+
+	if c.kind == types.ValueKind {
+		b, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return "", err
+		}
+		return dl.str(string(b)), nil
+	}
+	switch v := v.(type) {
+	case types.Bool:
+		return dl.boolean(bool(v)), nil
+	case types.Number:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64), nil
+	case types.String:
+		return dl.str(string(v)), nil
+	case types.Blob:
+		b, err := ioutil.ReadAll(v.Reader())
+		if err != nil {
+			return "", err
+		}
+		return dl.blob(b), nil
+	}
+	return "", fmt.Errorf("value of column %s has kind %s, expected %s", c.name, v.Kind(), c.kind)
+}
+
+// jsonValue converts v to a value encoding/json encodes as JSON. Blobs are
+// encoded as base64 strings, Maps with String keys as objects and other Maps
+// as arrays of [key, value] pairs, and Refs as their target hashes.
+func jsonValue(v types.Value) interface{} {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	case types.Blob:
+		b, err := ioutil.ReadAll(v.Reader())
+		d.PanicIfError(err)
+		return b
+	case types.List:
+		a := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value, _ uint64) {
+			a = append(a, jsonValue(v))
+		})
+		return a
+	case types.Set:
+		a := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value) {
+			a = append(a, jsonValue(v))
+		})
+		return a
+	case types.Map:
+		if types.TypeOf(v).Desc.(types.CompoundDesc).ElemTypes[0].TargetKind() == types.StringKind {
+			o := make(map[string]interface{}, v.Len())
+			v.IterAll(func(k, v types.Value) {
+				o[string(k.(types.String))] = jsonValue(v)
+			})
+			return o
+		}
+		a := make([]interface{}, 0, v.Len())
+		v.IterAll(func(k, v types.Value) {
+			a = append(a, []interface{}{jsonValue(k), jsonValue(v)})
+		})
+		return a
+	case types.Struct:
+		o := make(map[string]interface{}, v.Len())
+		v.IterFields(func(name string, v types.Value) {
+			o[name] = jsonValue(v)
+		})
+		return o
+	case types.Ref:
+		return v.TargetHash().String()
+	case *types.Type:
+		return v.Describe()
+	}
+	panic("not reached")
+}
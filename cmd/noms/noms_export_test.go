@@ -0,0 +1,130 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsExport(t *testing.T) {
+	suite.Run(t, &nomsExportTestSuite{})
+}
+
+type nomsExportTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsExportTestSuite) setupPeople() {
+	cs := nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize)
+	db := datas.NewDatabase(cs)
+	people := types.NewList(
+		types.NewStruct("Person", types.StructData{
+			"name":   types.String("Ann O'Neil"),
+			"age":    types.Number(42.5),
+			"admin":  types.Bool(true),
+			"avatar": types.NewBlob(bytes.NewReader([]byte{0xca, 0xfe})),
+			"address": types.NewStruct("Address", types.StructData{
+				"city": types.String("Oakland"),
+				"geo":  types.NewStruct("Geo", types.StructData{"lat": types.Number(37.8), "lon": types.Number(-122.27)}),
+			}),
+			"tags": types.NewList(types.String("a"), types.String("b")),
+		}),
+		types.NewStruct("Person", types.StructData{
+			"name":  types.String(`Bob "the \ builder"`),
+			"age":   types.Number(7),
+			"admin": types.Bool(false),
+			"address": types.NewStruct("Address", types.StructData{
+				"city": types.String("Boston"),
+				"geo":  types.NewStruct("Geo", types.StructData{"lat": types.Number(42.36), "lon": types.Number(-71.06)}),
+			}),
+			"tags": types.NewList(),
+		}),
+	)
+	_, err := db.CommitValue(db.GetDataset("people"), people)
+	s.NoError(err)
+	s.NoError(db.Close())
+}
+
+func (s *nomsExportTestSuite) TestPostgres() {
+	s.setupPeople()
+	out, _ := s.MustRun(main, []string{"export", "sql", spec.CreateValueSpecString("nbs", s.DBDir, "people.value")})
+	s.Equal(`CREATE TABLE "people" (
+  "address_city" TEXT NOT NULL,
+  "address_geo_lat" DOUBLE PRECISION NOT NULL,
+  "address_geo_lon" DOUBLE PRECISION NOT NULL,
+  "admin" BOOLEAN NOT NULL,
+  "age" DOUBLE PRECISION NOT NULL,
+  "avatar" BYTEA,
+  "name" TEXT NOT NULL,
+  "tags" JSONB NOT NULL
+);
+INSERT INTO "people" ("address_city", "address_geo_lat", "address_geo_lon", "admin", "age", "avatar", "name", "tags") VALUES
+  ('Oakland', 37.8, -122.27, TRUE, 42.5, '\xcafe', 'Ann O''Neil', '["a","b"]'),
+  ('Boston', 42.36, -71.06, FALSE, 7, NULL, 'Bob "the \ builder"', '[]');
+`, out)
+}
+
+func (s *nomsExportTestSuite) TestRules() {
+	s.setupPeople()
+	path := spec.CreateValueSpecString("nbs", s.DBDir, "people.value")
+	out, _ := s.MustRun(main, []string{"export", "sql", "--dialect", "mysql", "--table", "p", "--batch-size", "1", "--rules", "address=json,tags=skip", "--no-create", path})
+	s.Equal("INSERT INTO `p` (`address`, `admin`, `age`, `avatar`, `name`) VALUES\n"+
+		`  ('{"city":"Oakland","geo":{"lat":37.8,"lon":-122.27}}', TRUE, 42.5, X'cafe', 'Ann O''Neil');`+"\n"+
+		"INSERT INTO `p` (`address`, `admin`, `age`, `avatar`, `name`) VALUES\n"+
+		`  ('{"city":"Boston","geo":{"lat":42.36,"lon":-71.06}}', FALSE, 7, NULL, 'Bob "the \\ builder"');`+"\n", out)
+
+	outFile := filepath.Join(s.TempDir, "people.sql")
+	out, _ = s.MustRun(main, []string{"export", "sql", "--dialect", "sqlite", "--nested", "skip", "--separator", ".", "--rules", "address=columns", "--out", outFile, path})
+	s.Equal("", out)
+	b, err := ioutil.ReadFile(outFile)
+	s.NoError(err)
+	s.Equal(`CREATE TABLE "people" (
+  "address.city" TEXT NOT NULL,
+  "admin" BOOLEAN NOT NULL,
+  "age" REAL NOT NULL,
+  "avatar" BLOB,
+  "name" TEXT NOT NULL
+);
+INSERT INTO "people" ("address.city", "admin", "age", "avatar", "name") VALUES
+  ('Oakland', 1, 42.5, X'cafe', 'Ann O''Neil'),
+  ('Boston', 0, 7, NULL, 'Bob "the \ builder"');
+`, string(b))
+}
+
+func (s *nomsExportTestSuite) TestMap() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	m := types.NewMap(
+		types.String("x"), types.NewMap(types.Number(1), types.NewStruct("", types.StructData{"v": types.Number(1)})),
+		types.String("y"), types.NewMap(types.Number(2), types.NewStruct("", types.StructData{"v": types.Number(2)})),
+	)
+	r := db.WriteValue(m)
+	_, err := db.CommitValue(db.GetDataset("m"), r)
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	hashPath := spec.CreateValueSpecString("nbs", s.DBDir, "#"+r.TargetHash().String())
+	_, stderr, recovered := s.Run(main, []string{"export", "sql", hashPath})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "--table is required")
+
+	out, _ := s.MustRun(main, []string{"export", "sql", "--table", "t", hashPath})
+	s.Equal(`CREATE TABLE "t" (
+  "v" DOUBLE PRECISION NOT NULL
+);
+INSERT INTO "t" ("v") VALUES
+  (1),
+  (2);
+`, out)
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/util/profile"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	gcDryRun      bool
+	gcRetention   time.Duration
+	gcConcurrency int
+)
+
+var nomsGC = &util.Command{
+	Run:       runGC,
+	UsageLine: "gc [options] <database>",
+	Short:     "Removes data which is no longer reachable from the datasets of a database",
+	Long:      "Only local nbs databases are supported. Other processes must not write to the database while it's being collected. Expired pins are removed first, see noms pin.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupGCFlags,
+	Nargs:     1,
+}
+
+func setupGCFlags() *flag.FlagSet {
+	gcFlagSet := flag.NewFlagSet("gc", flag.ExitOnError)
+	gcFlagSet.BoolVar(&gcDryRun, "dry-run", false, "only report how much data would be reclaimed")
+	gcFlagSet.DurationVar(&gcRetention, "retention", 0, "don't collect data written within this duration, e.g. 24h")
+	gcFlagSet.IntVar(&gcConcurrency, "concurrency", 4, "number of tables to read concurrently")
+	verbose.RegisterVerboseFlags(gcFlagSet)
+	profile.RegisterProfileFlags(gcFlagSet)
+	return gcFlagSet
+}
+
+func runGC(args []string) int {
+	cfg := config.NewResolver()
+	if !gcDryRun {
+		db, err := cfg.GetDatabase(args[0])
+		d.CheckErrorNoUsage(err)
+		expired, err := db.ExpirePins()
+		db.Close()
+		d.CheckErrorNoUsage(err)
+		verbose.Log("Removed %d expired pins", len(expired))
+	}
+
+	cs, err := cfg.GetChunkStore(args[0])
+	d.CheckErrorNoUsage(err)
+	defer cs.Close()
+
+	store, ok := cs.(*nbs.NomsBlockStore)
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("%s is not a local nbs database", args[0]))
+	}
+
+	defer profile.MaybeStartProfile().Stop()
+	start := time.Now()
+	reachable := reachableChunks(store, store.Root())
+	verbose.Log("Found %d reachable chunks in %s", len(reachable), time.Since(start))
+
+	stats, err := store.GC(reachable.Has, nbs.GCOptions{
+		Retention:   gcRetention,
+		Concurrency: gcConcurrency,
+		DryRun:      gcDryRun,
+	})
+	d.CheckErrorNoUsage(err)
+
+	verb := "Reclaimed"
+	if gcDryRun {
+		verb = "Would reclaim"
+	}
+	fmt.Printf("%s %d of %d chunks (%s of %s) in %d of %d tables\n", verb,
+		stats.ReclaimedChunks, stats.Chunks,
+		humanize.Bytes(stats.ReclaimedBytes), humanize.Bytes(stats.Bytes),
+		stats.CollectedTables, stats.Tables)
+	return 0
+}
+
+// reachableChunks returns the hashes of all chunks reachable from root,
+// including root itself.
+func reachableChunks(cs chunks.ChunkStore, root hash.Hash) hash.HashSet {
+	reachable := hash.HashSet{}
+	walkChunks(cs, root, reachable, func(chunks.Chunk) {})
+	return reachable
+}
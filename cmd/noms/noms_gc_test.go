@@ -0,0 +1,52 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsGC(t *testing.T) {
+	suite.Run(t, &nomsGCTestSuite{})
+}
+
+type nomsGCTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsGCTestSuite) TestGC() {
+	cs := nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize)
+	db := datas.NewDatabase(cs)
+	_, err := db.CommitValue(db.GetDataset("garbage"), types.String("soon to be deleted"))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("live"), types.String("still here"))
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	s.MustRun(main, []string{"ds", "-d", spec.CreateValueSpecString("nbs", s.DBDir, "garbage")})
+
+	out, _ := s.MustRun(main, []string{"gc", "--dry-run", dbSpec})
+	s.Equal("Would reclaim 3 of 5 chunks (153 B of 231 B) in 2 of 3 tables\n", out)
+
+	out, _ = s.MustRun(main, []string{"gc", "--retention", "1h", dbSpec})
+	s.Equal("Reclaimed 0 of 5 chunks (0 B of 231 B) in 0 of 3 tables\n", out)
+
+	out, _ = s.MustRun(main, []string{"gc", dbSpec})
+	s.Equal("Reclaimed 3 of 5 chunks (153 B of 231 B) in 2 of 3 tables\n", out)
+
+	out, _ = s.MustRun(main, []string{"gc", dbSpec})
+	s.Equal("Reclaimed 0 of 2 chunks (0 B of 78 B) in 0 of 2 tables\n", out)
+
+	out, _ = s.MustRun(main, []string{"show", spec.CreateValueSpecString("nbs", s.DBDir, "live.value")})
+	s.Equal("\"still here\"\n", out)
+}
@@ -0,0 +1,236 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	grepIgnoreCase  bool
+	grepKeys        bool
+	grepContext     int
+	grepParallelism int
+)
+
+var nomsGrep = &util.Command{
+	Run:       runGrep,
+	UsageLine: "grep [flags] <pattern> <path>",
+	Short:     "Searches the strings of a value for a regular expression",
+	Long: `Prints the path of each String under <path> which matches the regular expression <pattern>, as taken by Go's regexp package, with the first match and the text around it. With --keys, the String keys of Maps are searched too, and their paths end in @key.
+
+Refs aren't followed, so only the values which <path> holds directly are searched, including all of the elements of its collections. The elements of a large collection are searched --parallel at a time, in order.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupGrepFlags,
+	Nargs: 2,
+}
+
+func setupGrepFlags() *flag.FlagSet {
+	grepFlagSet := flag.NewFlagSet("grep", flag.ExitOnError)
+	grepFlagSet.BoolVar(&grepIgnoreCase, "i", false, "ignore case")
+	grepFlagSet.BoolVar(&grepKeys, "keys", false, "search the keys of maps too")
+	grepFlagSet.IntVar(&grepContext, "context", 30, "number of characters of context to show on each side of a match")
+	grepFlagSet.IntVar(&grepParallelism, "parallel", 8, "number of parts of a collection to search at a time")
+	outputpager.RegisterOutputpagerFlags(grepFlagSet)
+	verbose.RegisterVerboseFlags(grepFlagSet)
+	return grepFlagSet
+}
+
+func runGrep(args []string) int {
+	pattern := args[0]
+	if grepIgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	d.CheckErrorNoUsage(err)
+	if grepParallelism < 1 {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid --parallel %d", grepParallelism))
+	}
+
+	cfg := config.NewResolver()
+	db, value, err := cfg.GetPath(args[1])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[1]))
+	}
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+	g := &grepper{root: args[1], re: re, keys: grepKeys, context: grepContext, parallelism: grepParallelism}
+	out := make(chan string, 64)
+	go func() {
+		g.grep(types.Path{}, value, out, true)
+		close(out)
+	}()
+	for line := range out {
+		io.WriteString(pgr.Writer, line)
+	}
+	return 0
+}
+
+// grepParallelLen is the length from which a collection is split into parts
+// which are searched in parallel.
+const grepParallelLen = 1 << 12
+
+// grepper searches the Strings of values for re. The paths it prints are
+// relative to root.
+type grepper struct {
+	root        string
+	re          *regexp.Regexp
+	keys        bool
+	context     int
+	parallelism int
+}
+
+// grep sends a line to out for each match in v, which is at path p. If
+// parallel is true, the elements of the collections in v are searched in
+// parallel.
+func (g *grepper) grep(p types.Path, v types.Value, out chan<- string, parallel bool) {
+	switch v := v.(type) {
+	case types.String:
+		if line, ok := g.match(p, string(v)); ok {
+			out <- line
+		}
+	case types.Struct:
+		v.IterFields(func(name string, fv types.Value) {
+			g.grep(p.Append(types.NewFieldPath(name)), fv, out, parallel)
+		})
+	case types.List, types.Map, types.Set:
+		col := v.(types.Collection)
+		if parallel && col.Len() >= grepParallelLen {
+			g.grepParallel(p, col, out)
+		} else {
+			g.grepRange(p, col, 0, col.Len(), out)
+		}
+	}
+}
+
+// grepRange searches the elements of col from start up to end.
+func (g *grepper) grepRange(p types.Path, col types.Collection, start, end uint64, out chan<- string) {
+	switch col := col.(type) {
+	case types.List:
+		it := col.IteratorAt(start)
+		for i := start; i < end; i++ {
+			g.grep(p.Append(types.NewIndexPath(types.Number(i))), it.Next(), out, false)
+		}
+	case types.Map:
+		it := col.IteratorAt(start)
+		for i := start; i < end; i++ {
+			k, v := it.Next()
+			if g.keys {
+				if ks, ok := k.(types.String); ok {
+					if line, ok := g.match(p.Append(types.NewIndexIntoKeyPath(k)), string(ks)); ok {
+						out <- line
+					}
+				}
+			}
+			g.grep(p.Append(grepIndex(k)), v, out, false)
+		}
+	case types.Set:
+		it := col.IteratorAt(start)
+		for i := start; i < end; i++ {
+			v := it.Next()
+			g.grep(p.Append(grepIndex(v)), v, out, false)
+		}
+	}
+}
+
+// grepParallel splits col into parts which are searched g.parallelism at a
+// time, sending the lines of each part to out in order.
+func (g *grepper) grepParallel(p types.Path, col types.Collection, out chan<- string) {
+	parts := uint64(g.parallelism * 4)
+	partLen := (col.Len() + parts - 1) / parts
+	outs := make([]chan string, 0, parts)
+	starts := make(chan int)
+	for start := uint64(0); start < col.Len(); start += partLen {
+		outs = append(outs, make(chan string, 64))
+	}
+
+	// Parts are started in order, so the first unfinished one is always being
+	// searched, and the searches which wait for their lines to be sent can't
+	// hold it up.
+	wg := &sync.WaitGroup{}
+	for i := 0; i < g.parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range starts {
+				start := uint64(i) * partLen
+				end := start + partLen
+				if end > col.Len() {
+					end = col.Len()
+				}
+				g.grepRange(p, col, start, end, outs[i])
+				close(outs[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range outs {
+			starts <- i
+		}
+		close(starts)
+	}()
+
+	for _, o := range outs {
+		for line := range o {
+			out <- line
+		}
+	}
+	wg.Wait()
+}
+
+// match returns the line for s, which is at path p, if it matches g.re.
+func (g *grepper) match(p types.Path, s string) (string, bool) {
+	loc := g.re.FindStringIndex(s)
+	if loc == nil {
+		return "", false
+	}
+	start, end := loc[0]-g.context, loc[1]+g.context
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "..."
+		// Don't split a rune.
+		for start < loc[0] && !isRuneStart(s[start]) {
+			start++
+		}
+	} else {
+		start = 0
+	}
+	if end < len(s) {
+		suffix = "..."
+		for end > loc[1] && !isRuneStart(s[end]) {
+			end--
+		}
+	} else {
+		end = len(s)
+	}
+	return fmt.Sprintf("%s%s: %s%s%s\n", g.root, p, prefix, types.EncodedValue(types.String(s[start:end])), suffix), true
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// grepIndex returns the path index of a Map key or Set element.
+func grepIndex(k types.Value) types.PathPart {
+	if types.ValueCanBePathIndex(k) {
+		return types.NewIndexPath(k)
+	}
+	return types.NewHashIndexPath(k.Hash())
+}
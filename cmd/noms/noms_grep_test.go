@@ -0,0 +1,90 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsGrep(t *testing.T) {
+	suite.Run(t, &nomsGrepTestSuite{})
+}
+
+type nomsGrepTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsGrepTestSuite) commit(value types.Value) string {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), value)
+	s.NoError(err)
+	return spec.CreateValueSpecString("nbs", s.DBDir, "ds.value")
+}
+
+func (s *nomsGrepTestSuite) TestGrep() {
+	root := s.commit(types.NewStruct("Doc", types.StructData{
+		"title": types.String("Hello world"),
+		"tags":  types.NewList(types.String("greeting"), types.String("World tour")),
+		"notes": types.NewMap(types.String("world"), types.String("planet"), types.Number(1), types.String("no match")),
+		"count": types.Number(42),
+	}))
+
+	stdout, _ := s.MustRun(main, []string{"grep", "world", root})
+	s.Equal(root+`.title: "Hello world"`+"\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "-i", "world", root})
+	s.Equal(root+`.tags[1]: "World tour"`+"\n"+root+`.title: "Hello world"`+"\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "--keys", "world", root})
+	s.Equal(root+`.notes["world"]@key: "world"`+"\n"+root+`.title: "Hello world"`+"\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "an[ae]t", root + ".notes"})
+	s.Equal(root+`.notes["world"]: "planet"`+"\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "--context", "2", "o w", root})
+	s.Equal(root+`.title: ..."llo wor"...`+"\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "nothing", root})
+	s.Equal("", stdout)
+}
+
+func (s *nomsGrepTestSuite) TestGrepParallel() {
+	n := grepParallelLen + 10
+	values := make([]types.Value, n)
+	expected := &strings.Builder{}
+	for i := range values {
+		str := fmt.Sprintf("value %d", i)
+		if i%1000 == 7 {
+			str = fmt.Sprintf("match %d", i)
+		}
+		values[i] = types.String(str)
+	}
+	root := s.commit(types.NewList(values...))
+	for i := range values {
+		if i%1000 == 7 {
+			fmt.Fprintf(expected, "%s[%d]: \"match %d\"\n", root, i, i)
+		}
+	}
+
+	stdout, _ := s.MustRun(main, []string{"grep", "--parallel", "3", "^match", root})
+	s.Equal(expected.String(), stdout)
+}
+
+func (s *nomsGrepTestSuite) TestGrepErrors() {
+	root := s.commit(types.String("hi"))
+	_, _, err := s.Run(main, []string{"grep", "(", root})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	_, _, err = s.Run(main, []string{"grep", "hi", spec.CreateValueSpecString("nbs", s.DBDir, "ds.value.nope")})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+}
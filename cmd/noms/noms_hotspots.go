@@ -0,0 +1,294 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var hotspotsTop = 20
+
+var nomsHotspots = &util.Command{
+	Run:       runHotspots,
+	UsageLine: "hotspots [--top <n>] <database> <access-log>",
+	Short:     "Shows the chunks of a database which are read the most",
+	Long: `Reads <access-log>, a record of the chunks read from <database> made with the record option, e.g. /tmp/noms-data?record=/tmp/access.log, and shows the --top chunks which were read the most, with how many times they were read, their size, the path of the value they belong to and the operations which read them, for finding the values whose structure amplifies reads.
+
+The path of each chunk is found by walking the values of the heads of the datasets, and then their histories, until all of the chunks are found. The chunks of a collection which is split into several chunks are at the path of the collection, and the chunk of the value a Ref points to is at the path of the Ref. The values of earlier commits are at paths from the hash of the commit, e.g. #<hash>.value. The chunks of the map of datasets are at "(datasets)", and those which aren't reachable from it at "?".
+
+Remote databases are not supported. Open <database> without the record option, so that the reads of this command aren't recorded. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupHotspotsFlags,
+	Nargs: 2,
+}
+
+func setupHotspotsFlags() *flag.FlagSet {
+	hotspotsFlagSet := flag.NewFlagSet("hotspots", flag.ExitOnError)
+	hotspotsFlagSet.IntVar(&hotspotsTop, "top", 20, "number of chunks to show")
+	outputpager.RegisterOutputpagerFlags(hotspotsFlagSet)
+	verbose.RegisterVerboseFlags(hotspotsFlagSet)
+	return hotspotsFlagSet
+}
+
+func runHotspots(args []string) int {
+	if hotspotsTop < 1 {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid --top %d", hotspotsTop))
+	}
+
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(args[0])
+	d.CheckErrorNoUsage(err)
+	if cs == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s is a remote database", args[0]))
+	}
+	db := datas.NewDatabase(cs)
+	defer db.Close()
+
+	f, err := os.Open(args[1])
+	d.CheckErrorNoUsage(err)
+	defer f.Close()
+	hot, err := readHotChunks(f)
+	d.CheckErrorNoUsage(err)
+	if len(hot) > hotspotsTop {
+		hot = hot[:hotspotsTop]
+	}
+
+	hashes := hash.HashSet{}
+	for _, c := range hot {
+		hashes.Insert(c.hash)
+	}
+	paths := findChunkPaths(db, hashes)
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+	printHotChunks(pgr.Writer, hot, paths, cs)
+	return 0
+}
+
+// hotChunk is how many times a chunk was read, in total and by each
+// operation.
+type hotChunk struct {
+	hash  hash.Hash
+	reads int
+	ops   map[string]int
+}
+
+// readHotChunks returns the chunks of the access log read from r, those read
+// the most first.
+func readHotChunks(r io.Reader) ([]*hotChunk, error) {
+	byHash := map[hash.Hash]*hotChunk{}
+	err := chunks.ReadAccessLog(r, func(rec chunks.AccessRecord) {
+		c, ok := byHash[rec.Hash]
+		if !ok {
+			c = &hotChunk{hash: rec.Hash, ops: map[string]int{}}
+			byHash[rec.Hash] = c
+		}
+		c.reads++
+		c.ops[rec.Op]++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hot := make([]*hotChunk, 0, len(byHash))
+	for _, c := range byHash {
+		hot = append(hot, c)
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].reads != hot[j].reads {
+			return hot[i].reads > hot[j].reads
+		}
+		return hot[i].hash.Less(hot[j].hash)
+	})
+	return hot, nil
+}
+
+func printHotChunks(w io.Writer, hot []*hotChunk, paths map[hash.Hash]string, cs chunks.ChunkStore) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Reads\tChunk\tSize\tPath\tOperations")
+	for _, c := range hot {
+		size := "-"
+		if data := cs.Get(c.hash); !data.IsEmpty() {
+			size = humanize.Bytes(uint64(len(data.Data())))
+		}
+		path, ok := paths[c.hash]
+		if !ok {
+			path = "?"
+		}
+
+		ops := make([]string, 0, len(c.ops))
+		for op := range c.ops {
+			ops = append(ops, op)
+		}
+		sort.Slice(ops, func(i, j int) bool {
+			if c.ops[ops[i]] != c.ops[ops[j]] {
+				return c.ops[ops[i]] > c.ops[ops[j]]
+			}
+			return ops[i] < ops[j]
+		})
+		for i, op := range ops {
+			ops[i] = fmt.Sprintf("%s (%d)", op, c.ops[op])
+		}
+		fmt.Fprintf(tw, "%d\t#%s\t%s\t%s\t%s\n", c.reads, c.hash, size, path, strings.Join(ops, ", "))
+	}
+	d.PanicIfError(tw.Flush())
+}
+
+// findChunkPaths returns the paths of the values which the chunks of hashes
+// belong to, as described by nomsHotspots. Chunks which aren't reachable from
+// the root of db are left out.
+func findChunkPaths(db datas.Database, hashes hash.HashSet) map[hash.Hash]string {
+	f := &chunkPathFinder{db, map[hash.Hash]string{}, hashes.Copy(), hash.HashSet{}, nil}
+	datasets := db.Datasets()
+	if f.visit(datasets.Hash(), "(datasets)") && types.IsChunked(datasets) {
+		f.walkTree("(datasets)", datasets)
+	}
+	datasets.IterAll(func(k, v types.Value) {
+		name, head := string(k.(types.String)), v.(types.Ref)
+		if f.visit(head.TargetHash(), name) {
+			f.walk(name, types.Path{}, head.TargetValue(db))
+		}
+	})
+	for len(f.history) > 0 && !f.done() {
+		commit := f.history[0]
+		f.history = f.history[1:]
+		base := "#" + commit.TargetHash().String()
+		if f.visit(commit.TargetHash(), base) {
+			f.walk(base, types.Path{}, commit.TargetValue(db))
+		}
+	}
+	return f.paths
+}
+
+type chunkPathFinder struct {
+	vr    types.ValueReader
+	paths map[hash.Hash]string
+	// remaining are the chunks whose paths haven't been found yet, seen
+	// those which have been walked.
+	remaining, seen hash.HashSet
+	// history are the commits to walk once the heads of the datasets have
+	// been.
+	history []types.Ref
+}
+
+func (f *chunkPathFinder) done() bool {
+	return len(f.remaining) == 0
+}
+
+// visit records that the chunk h is at path, and returns false if it's
+// already been walked.
+func (f *chunkPathFinder) visit(h hash.Hash, path string) bool {
+	if f.seen.Has(h) {
+		return false
+	}
+	f.seen.Insert(h)
+	if f.remaining.Has(h) {
+		f.paths[h] = path
+		f.remaining.Remove(h)
+	}
+	return true
+}
+
+// walk walks the chunks reachable from v, which is at base followed by p.
+func (f *chunkPathFinder) walk(base string, p types.Path, v types.Value) {
+	if f.done() {
+		return
+	}
+	switch v := v.(type) {
+	case types.Ref:
+		if datas.IsRefOfCommitType(types.TypeOf(v)) {
+			f.history = append(f.history, v)
+		} else if f.visit(v.TargetHash(), base+p.String()) {
+			f.walk(base, p, v.TargetValue(f.vr))
+		}
+	case types.Struct:
+		v.IterFields(func(name string, fv types.Value) {
+			f.walk(base, p.Append(types.NewFieldPath(name)), fv)
+		})
+	case types.Collection:
+		if types.IsChunked(v) {
+			f.walkTree(base+p.String(), v)
+		}
+		if !mayHoldRefs(types.TypeOf(v)) {
+			return
+		}
+		switch v := v.(type) {
+		case types.List:
+			it := v.Iterator()
+			for i, ev := uint64(0), it.Next(); ev != nil && !f.done(); i, ev = i+1, it.Next() {
+				f.walk(base, p.Append(types.NewIndexPath(types.Number(i))), ev)
+			}
+		case types.Map:
+			it := v.Iterator()
+			for k, mv := it.Next(); k != nil && !f.done(); k, mv = it.Next() {
+				f.walk(base, p.Append(keyIndex(k)), k)
+				f.walk(base, p.Append(grepIndex(k)), mv)
+			}
+		case types.Set:
+			it := v.Iterator()
+			for ev := it.Next(); ev != nil && !f.done(); ev = it.Next() {
+				f.walk(base, p.Append(grepIndex(ev)), ev)
+			}
+		}
+	}
+}
+
+// walkTree records that the subtrees of the chunked collection col are at
+// path.
+func (f *chunkPathFinder) walkTree(path string, col types.Collection) {
+	col.WalkRefs(func(r types.Ref) {
+		if f.visit(r.TargetHash(), path) && !f.done() {
+			if sub := r.TargetValue(f.vr).(types.Collection); types.IsChunked(sub) {
+				f.walkTree(path, sub)
+			}
+		}
+	})
+}
+
+// keyIndex returns the path index of a Map key itself, see grepIndex.
+func keyIndex(k types.Value) types.PathPart {
+	if types.ValueCanBePathIndex(k) {
+		return types.NewIndexIntoKeyPath(k)
+	}
+	return types.NewHashIndexIntoKeyPath(k.Hash())
+}
+
+// mayHoldRefs returns false if values of type t can't hold any Refs, so
+// that their elements needn't be walked.
+func mayHoldRefs(t *types.Type) bool {
+	switch t.TargetKind() {
+	case types.RefKind, types.ValueKind, types.CycleKind:
+		return true
+	case types.StructKind:
+		holds := false
+		t.Desc.(types.StructDesc).IterFields(func(name string, ft *types.Type, optional bool) {
+			holds = holds || mayHoldRefs(ft)
+		})
+		return holds
+	case types.ListKind, types.MapKind, types.SetKind, types.UnionKind:
+		for _, et := range t.Desc.(types.CompoundDesc).ElemTypes {
+			if mayHoldRefs(et) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsHotspots(t *testing.T) {
+	suite.Run(t, &nomsHotspotsTestSuite{})
+}
+
+type nomsHotspotsTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsHotspotsTestSuite) TestHotspots() {
+	cs := nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize)
+	db := datas.NewDatabase(cs)
+	nums := make([]types.Value, 5000)
+	for i := range nums {
+		nums[i] = types.Number(i)
+	}
+	l := types.NewList(nums...)
+	s.True(types.IsChunked(l))
+	target := types.String("target")
+	ds, err := db.CommitValue(db.GetDataset("a"), types.NewStruct("", types.StructData{
+		"list": l,
+		"ref":  db.WriteValue(target),
+	}))
+	s.NoError(err)
+	first := ds.HeadRef().TargetHash()
+	ds, err = db.CommitValue(ds, types.String("new"))
+	s.NoError(err)
+	head := ds.HeadRef().TargetHash()
+	s.NoError(db.Close())
+
+	var subtree hash.Hash
+	l.WalkRefs(func(r types.Ref) {
+		if subtree.IsEmpty() {
+			subtree = r.TargetHash()
+		}
+	})
+	missing := hash.Of([]byte("missing"))
+
+	log := filepath.Join(s.TempDir, "access.log")
+	lines := []string{}
+	record := func(h hash.Hash, op string) {
+		lines = append(lines, chunks.AccessRecord{Time: time.Now(), Hash: h, Op: op}.String())
+	}
+	record(head, "noms show")
+	record(subtree, "noms show")
+	record(head, "noms show")
+	record(subtree, "noms log")
+	record(target.Hash(), "noms log")
+	record(head, "noms log")
+	record(missing, "noms log")
+	s.NoError(ioutil.WriteFile(log, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	out, _ := s.MustRun(main, []string{"hotspots", dbSpec, log})
+	rows := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	s.Len(rows, 5)
+	s.Equal([]string{"Reads", "Chunk", "Size", "Path", "Operations"}, strings.Fields(rows[0]))
+	expected := [][]string{
+		{"3", "#" + head.String(), "a", "noms show (2), noms log (1)"},
+		{"2", "#" + subtree.String(), "#" + first.String() + ".value.list", "noms log (1), noms show (1)"},
+	}
+	last := [][]string{
+		{"1", "#" + missing.String(), "?", "noms log (1)"},
+		{"1", "#" + target.Hash().String(), "#" + first.String() + ".value.ref", "noms log (1)"},
+	}
+	if target.Hash().Less(missing) {
+		last[0], last[1] = last[1], last[0]
+	}
+	for i, cols := range append(expected, last...) {
+		fields := strings.Fields(rows[i+1])
+		s.Equal(cols[0], fields[0])
+		s.Equal(cols[1], fields[1])
+		s.Equal(cols[2], fields[len(fields)-len(strings.Fields(cols[3]))-1])
+		s.True(strings.HasSuffix(rows[i+1], cols[3]), rows[i+1])
+	}
+
+	out, _ = s.MustRun(main, []string{"hotspots", "--top", "1", dbSpec, log})
+	s.Len(strings.Split(strings.TrimSuffix(out, "\n"), "\n"), 2)
+
+	_, _, recovered := s.Run(main, []string{"hotspots", "--top", "0", dbSpec, log})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
+
+func (s *nomsHotspotsTestSuite) TestRecord() {
+	cs := nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize)
+	db := datas.NewDatabase(cs)
+	ds, err := db.CommitValue(db.GetDataset("a"), types.String("hello"))
+	s.NoError(err)
+	head := ds.HeadRef().TargetHash()
+	s.NoError(db.Close())
+
+	log := filepath.Join(s.TempDir, "access.log")
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	out, _ := s.MustRun(main, []string{"show", fmt.Sprintf("%s?record=%s::a.value", dbSpec, log)})
+	s.Equal("\"hello\"\n", out)
+
+	out, _ = s.MustRun(main, []string{"hotspots", dbSpec, log})
+	s.Contains(out, fmt.Sprintf("#%s", head))
+}
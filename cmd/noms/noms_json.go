@@ -0,0 +1,250 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/jsontonoms"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	jsonDestType     string
+	jsonBatchSize    int
+	jsonObjects      string
+	jsonNamedStructs bool
+	jsonParseStrings bool
+)
+
+var nomsJSON = &util.Command{
+	Run:       runJSON,
+	UsageLine: "json import [flags] <file> <dataset>",
+	Short:     "Imports newline-delimited JSON into a dataset",
+	Long: `Imports the JSON values in <file>, one per line, into a List, or with --dest-type map:<field>, a Map keyed by the field <field> of each value, which must be an object; later values replace earlier ones with the same key. If <file> is -, the values are read from stdin.
+
+The values are converted as they're read, so the whole of <file> is never held in memory. With --batch, a commit is made every <n> values, each of which adds them to the collection of the one before, so that an interrupted import can be resumed from the last commit.
+
+How values are converted is set with:
+  --objects         convert objects to Structs, or to Maps from String
+  --named-structs   name the Struct of an object by its "_name" field, if it's a string
+  --parse-strings   convert strings which are numbers or booleans to Numbers and Bools
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.`,
+	Flags: setupJSONFlags,
+	Nargs: 3,
+}
+
+func setupJSONFlags() *flag.FlagSet {
+	jsonFlagSet := flag.NewFlagSet("json", flag.ExitOnError)
+	jsonFlagSet.StringVar(&jsonDestType, "dest-type", "list", "collection to import to: list or map:<field>")
+	jsonFlagSet.IntVar(&jsonBatchSize, "batch", 0, "commit every <n> values, 0 commits once all of them are imported")
+	jsonFlagSet.StringVar(&jsonObjects, "objects", "struct", "convert objects to struct or map")
+	jsonFlagSet.BoolVar(&jsonNamedStructs, "named-structs", false, `name structs by the "_name" field of objects`)
+	jsonFlagSet.BoolVar(&jsonParseStrings, "parse-strings", false, "convert strings which are numbers or booleans")
+	spec.RegisterCommitMetaFlags(jsonFlagSet)
+	verbose.RegisterVerboseFlags(jsonFlagSet)
+	return jsonFlagSet
+}
+
+func runJSON(args []string) int {
+	if args[0] != "import" {
+		d.CheckErrorNoUsage(fmt.Errorf("Unknown json command %s, the only one is import", args[0]))
+	}
+	keyField := ""
+	if jsonDestType != "list" {
+		if !strings.HasPrefix(jsonDestType, "map:") || jsonDestType == "map:" {
+			d.CheckErrorNoUsage(fmt.Errorf("Invalid --dest-type %s, expected list or map:<field>", jsonDestType))
+		}
+		keyField = strings.TrimPrefix(jsonDestType, "map:")
+	}
+	if jsonObjects != "struct" && jsonObjects != "map" {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid --objects %s, expected struct or map", jsonObjects))
+	}
+	if jsonBatchSize < 0 {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid --batch %d", jsonBatchSize))
+	}
+
+	var r io.Reader = os.Stdin
+	if args[1] != "-" {
+		f, err := os.Open(args[1])
+		d.CheckErrorNoUsage(err)
+		defer f.Close()
+		r = f
+	}
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[2])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	imp := &jsonImporter{db: db, ds: ds, keyField: keyField, file: args[1]}
+	d.CheckErrorNoUsage(imp.run(bufio.NewReader(r)))
+	fmt.Printf("Imported %d values to %s in %d commits, head #%s\n", imp.count, args[2], imp.commits, imp.ds.HeadRef().TargetHash())
+	return 0
+}
+
+// jsonImporter streams JSON values into the List or Map of a dataset,
+// committing it every jsonBatchSize values.
+type jsonImporter struct {
+	db       datas.Database
+	ds       datas.Dataset
+	keyField string
+	file     string
+
+	value          types.Value // the collection committed last, nil at first
+	count, commits int
+}
+
+func (imp *jsonImporter) run(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		n, err := imp.importBatch(dec)
+		if err != nil {
+			return err
+		}
+		if n == 0 && imp.commits > 0 {
+			return nil
+		}
+		if err := imp.commit(); err != nil {
+			return err
+		}
+		if jsonBatchSize == 0 || n < jsonBatchSize {
+			return nil
+		}
+	}
+}
+
+// importBatch reads the next batch of values and adds them to imp.value,
+// returning how many there were.
+func (imp *jsonImporter) importBatch(dec *json.Decoder) (n int, err error) {
+	values := make(chan types.Value, 128)
+	var lists <-chan types.List
+	var maps <-chan types.Map
+	if imp.keyField == "" {
+		lists = types.NewStreamingList(imp.db, values)
+	} else {
+		maps = types.NewStreamingMap(imp.db, values)
+	}
+
+	for jsonBatchSize == 0 || n < jsonBatchSize {
+		var o interface{}
+		if err = dec.Decode(&o); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = fmt.Errorf("Invalid JSON after %d values: %s", imp.count, err)
+			break
+		}
+		if err = imp.send(values, o); err != nil {
+			break
+		}
+		n++
+		imp.count++
+	}
+	close(values)
+
+	if lists != nil {
+		l := <-lists
+		if imp.value != nil {
+			l = imp.value.(types.List).Concat(l)
+		}
+		imp.value = l
+		return
+	}
+	m := <-maps
+	if imp.value != nil {
+		kvs := make([]types.Value, 0, 2*m.Len())
+		m.IterAll(func(k, v types.Value) {
+			kvs = append(kvs, k, v)
+		})
+		m = imp.value.(types.Map).SetM(kvs...)
+	}
+	imp.value = m
+	return
+}
+
+// send converts o and sends it to values, preceded by its key if importing
+// to a Map.
+func (imp *jsonImporter) send(values chan<- types.Value, o interface{}) error {
+	if jsonParseStrings {
+		o = parseJSONStrings(o)
+	}
+	if imp.keyField != "" {
+		obj, ok := o.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Value %d isn't an object", imp.count+1)
+		}
+		k := jsontonoms.NomsValueFromDecodedJSON(obj[imp.keyField], jsonObjects == "struct")
+		if k == nil {
+			return fmt.Errorf("Value %d has no field %s", imp.count+1, imp.keyField)
+		}
+		values <- k
+	}
+
+	var v types.Value
+	if jsonNamedStructs {
+		v = jsontonoms.NomsValueUsingNamedStructsFromDecodedJSON(o)
+	} else {
+		v = jsontonoms.NomsValueFromDecodedJSON(o, jsonObjects == "struct")
+	}
+	if v == nil {
+		return fmt.Errorf("Value %d is null", imp.count+1)
+	}
+	values <- v
+	return nil
+}
+
+func (imp *jsonImporter) commit() error {
+	meta, err := spec.CreateCommitMetaStruct(imp.db, "", "", map[string]string{"file": imp.file}, nil)
+	if err != nil {
+		return err
+	}
+	imp.ds, err = imp.db.Commit(imp.ds, imp.value, datas.CommitOptions{Meta: meta})
+	if err != nil {
+		return err
+	}
+	imp.commits++
+	verbose.Log("Committed %d values as #%s", imp.count, imp.ds.HeadRef().TargetHash())
+	return nil
+}
+
+// parseJSONStrings replaces the strings in o which are numbers or booleans
+// by them.
+func parseJSONStrings(o interface{}) interface{} {
+	switch o := o.(type) {
+	case string:
+		if o == "true" || o == "false" {
+			return o == "true"
+		}
+		// Numbers can't be NaN or infinite.
+		if f, err := strconv.ParseFloat(o, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case []interface{}:
+		for i, v := range o {
+			o[i] = parseJSONStrings(v)
+		}
+	case map[string]interface{}:
+		for k, v := range o {
+			o[k] = parseJSONStrings(v)
+		}
+	}
+	return o
+}
@@ -0,0 +1,100 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsJSON(t *testing.T) {
+	suite.Run(t, &nomsJSONTestSuite{})
+}
+
+type nomsJSONTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsJSONTestSuite) writeFile(name, data string) string {
+	name = filepath.Join(s.TempDir, name)
+	s.NoError(ioutil.WriteFile(name, []byte(data), 0644))
+	return name
+}
+
+func (s *nomsJSONTestSuite) headValue(id string) types.Value {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	return db.GetDataset(id).HeadValue()
+}
+
+func (s *nomsJSONTestSuite) TestImportList() {
+	file := s.writeFile("people.json", `{"name": "alice", "age": 30}
+{"name": "bob", "tags": ["a", "b"]}
+
+"carol"
+`)
+	dsSpec := spec.CreateValueSpecString("nbs", s.DBDir, "people")
+	out, _ := s.MustRun(main, []string{"json", "import", file, dsSpec})
+	s.Contains(out, "Imported 3 values to "+dsSpec+" in 1 commits, head #")
+
+	s.True(types.NewList(
+		types.NewStruct("", types.StructData{"name": types.String("alice"), "age": types.Number(30)}),
+		types.NewStruct("", types.StructData{"name": types.String("bob"), "tags": types.NewList(types.String("a"), types.String("b"))}),
+		types.String("carol"),
+	).Equals(s.headValue("people")))
+
+	out, _ = s.MustRun(main, []string{"json", "import", "--batch", "2", file, dsSpec})
+	s.Contains(out, "Imported 3 values to "+dsSpec+" in 2 commits, head #")
+	s.Equal(uint64(3), s.headValue("people").(types.List).Len())
+}
+
+func (s *nomsJSONTestSuite) TestImportMap() {
+	file := s.writeFile("people.json", `{"id": "a", "n": "1"}
+{"id": "b", "n": "2"}
+{"id": "c", "n": "x"}
+{"id": "a", "n": "true"}
+`)
+	dsSpec := spec.CreateValueSpecString("nbs", s.DBDir, "people")
+	out, _ := s.MustRun(main, []string{"json", "import", "--dest-type", "map:id", "--batch", "2", "--objects", "map", "--parse-strings", file, dsSpec})
+	s.Contains(out, "Imported 4 values to "+dsSpec+" in 2 commits, head #")
+
+	row := func(id string, n types.Value) types.Map {
+		return types.NewMap(types.String("id"), types.String(id), types.String("n"), n)
+	}
+	s.True(types.NewMap(
+		types.String("a"), row("a", types.Bool(true)),
+		types.String("b"), row("b", types.Number(2)),
+		types.String("c"), row("c", types.String("x")),
+	).Equals(s.headValue("people")))
+}
+
+func (s *nomsJSONTestSuite) TestImportErrors() {
+	dsSpec := spec.CreateValueSpecString("nbs", s.DBDir, "ds")
+	file := s.writeFile("bad.json", "1\n{nope}\n")
+	_, stderr, recovered := s.Run(main, []string{"json", "import", file, dsSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Invalid JSON after 1 values")
+
+	file = s.writeFile("nokey.json", `{"id": 1}`+"\n"+`{"name": "x"}`+"\n")
+	_, stderr, recovered = s.Run(main, []string{"json", "import", "--dest-type", "map:id", file, dsSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Value 2 has no field id")
+
+	_, stderr, recovered = s.Run(main, []string{"json", "import", "--dest-type", "set", file, dsSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Invalid --dest-type set")
+
+	_, stderr, recovered = s.Run(main, []string{"json", "export", file, dsSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Unknown json command export")
+}
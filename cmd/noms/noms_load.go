@@ -0,0 +1,115 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var loadSetRoot bool
+
+var nomsLoad = &util.Command{
+	Run:       runLoad,
+	UsageLine: "load [--set-root] <file> <database>",
+	Short:     "Reads the chunks of a chunk stream into a database",
+	Long: `Puts the chunks of the chunk stream <file>, or of stdin if it's "-", as written by "noms dump", into <database>, and prints the hashes of the roots recorded in the stream, e.g. to commit them with "noms commit". The chunks of the stream are checked against their hashes, and the chunks reachable from the roots must either be in the stream or the database already. See https://github.com/attic-labs/noms/blob/master/doc/chunk-stream.md for the format of chunk streams.
+
+With --set-root, the root of <database> is moved to the root of the stream, which must have exactly one, e.g. to load the dump of a whole database.
+
+Only databases with a local chunk store, such as nbs, are supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupLoadFlags,
+	Nargs: 2,
+}
+
+func setupLoadFlags() *flag.FlagSet {
+	loadFlagSet := flag.NewFlagSet("load", flag.ExitOnError)
+	loadFlagSet.BoolVar(&loadSetRoot, "set-root", false, "move the root of the database to the root of the stream")
+	verbose.RegisterVerboseFlags(loadFlagSet)
+	return loadFlagSet
+}
+
+func runLoad(args []string) int {
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		d.CheckErrorNoUsage(err)
+		defer f.Close()
+		r = f
+	}
+
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(args[1])
+	d.CheckErrorNoUsage(err)
+	if cs == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have a local chunk store", args[1]))
+	}
+	defer cs.Close()
+
+	sr, err := chunks.NewStreamReader(r)
+	d.CheckErrorNoUsage(err)
+	if v := sr.DataVersion(); v != constants.DataVersion() {
+		d.CheckErrorNoUsage(fmt.Errorf("%s has chunks of data version %s, expected %s", args[0], v, constants.DataVersion()))
+	}
+
+	current := cs.Root()
+	n := 0
+	batch := make([]chunks.Chunk, 0, restoreBatchSize)
+	for {
+		c, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		d.CheckErrorNoUsage(err)
+		if batch = append(batch, c); len(batch) == restoreBatchSize {
+			cs.PutMany(batch)
+			batch = batch[:0]
+		}
+		n++
+	}
+	cs.PutMany(batch)
+	cs.Flush()
+
+	roots := sr.Roots()
+	strs := make([]string, len(roots))
+	for i, root := range roots {
+		if !cs.Has(root) {
+			d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have the chunk of its root #%s, and neither does the database", args[0], root))
+		}
+		strs[i] = "#" + root.String()
+	}
+
+	if loadSetRoot {
+		if len(roots) != 1 {
+			d.CheckErrorNoUsage(fmt.Errorf("%s has %d roots, --set-root expects 1", args[0], len(roots)))
+		}
+		if _, ok := types.DecodeValue(cs.Get(roots[0]), nil).(types.Map); !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("The root #%s of %s isn't the root of a database", roots[0], args[0]))
+		}
+		if !cs.UpdateRoot(roots[0], current) {
+			d.CheckErrorNoUsage(errors.New("The database was changed while the stream was loaded"))
+		}
+		fmt.Printf("Loaded %d chunks, and moved the root to #%s\n", n, roots[0])
+		return 0
+	}
+	if len(roots) == 0 {
+		fmt.Printf("Loaded %d chunks\n", n)
+	} else {
+		fmt.Printf("Loaded %d chunks with roots %s\n", n, strings.Join(strs, ", "))
+	}
+	return 0
+}
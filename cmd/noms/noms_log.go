@@ -45,9 +45,11 @@ var nomsLog = &util.Command{
 	Run:       runLog,
 	UsageLine: "log [options] <path-spec>",
 	Short:     "Displays the history of a path",
-	Long:      "Displays the history of a path. See Spelling Values at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the <path-spec> parameter.",
-	Flags:     setupLogFlags,
-	Nargs:     1,
+	Long: `Displays the history of a path. See Spelling Values at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the <path-spec> parameter.
+
+With --format=json or yaml, each commit is written with its hash, parents and meta fields, and with --show-value its value at the path. Templates are executed with the same fields, e.g. --template '{{.hash}} {{.meta.message}}', and {{path ".value.name"}} reads a path in the commit.`,
+	Flags: setupLogFlags,
+	Nargs: 1,
 }
 
 func setupLogFlags() *flag.FlagSet {
@@ -58,6 +60,7 @@ func setupLogFlags() *flag.FlagSet {
 	logFlagSet.BoolVar(&oneline, "oneline", false, "show a summary of each commit on a single line")
 	logFlagSet.BoolVar(&showGraph, "graph", false, "show ascii-based commit hierarchy on left side of output")
 	logFlagSet.BoolVar(&showValue, "show-value", false, "show commit value rather than diff information")
+	registerOutputFormatFlags(logFlagSet)
 	outputpager.RegisterOutputpagerFlags(logFlagSet)
 	verbose.RegisterVerboseFlags(logFlagSet)
 	return logFlagSet
@@ -97,6 +100,25 @@ func runLog(args []string) int {
 		maxCommits = math.MaxInt32
 	}
 
+	rw, err := newRecordWriter(true)
+	d.CheckErrorNoUsage(err)
+	if rw != nil {
+		var valuePath types.Path
+		if showValue {
+			valuePath = path
+		}
+		pgr := outputpager.Start()
+		defer pgr.Stop()
+		for ln, ok := iter.Next(); ok && displayed < maxCommits; ln, ok = iter.Next() {
+			rec := commitRecord(ln.commit, valuePath)
+			if err := rw.write(pgr.Writer, rec, rec, ln.commit); err != nil {
+				break
+			}
+			displayed++
+		}
+		return 0
+	}
+
 	bytesChan := make(chan chan []byte, parallelism)
 
 	var done = false
@@ -259,24 +281,30 @@ func writeMetaLines(node LogNode, maxLines, lineno, maxLabelLen int, w io.Writer
 		mlw := &writers.MaxLineWriter{Dest: w, MaxLines: uint32(maxLines), NumLines: uint32(lineno)}
 		pw := &writers.PrefixWriter{Dest: mlw, PrefixFunc: genPrefix, NeedsPrefix: true, NumLines: uint32(lineno)}
 		err := d.Try(func() {
-			types.TypeOf(meta).Desc.(types.StructDesc).IterFields(func(fieldName string, t *types.Type, optional bool) {
-				v := meta.Get(fieldName)
-				fmt.Fprintf(pw, "%-*s", maxLabelLen+2, strings.Title(fieldName)+":")
-				if types.TypeOf(v).Equals(datetime.DateTimeType) {
-					var dt datetime.DateTime
-					dt.UnmarshalNoms(v)
-					fmt.Fprintf(pw, time.Time(dt).Format(spec.CommitMetaDateFormat))
-				} else {
-					types.WriteEncodedValue(pw, v)
-				}
-				fmt.Fprintf(pw, "\n")
-			})
+			writeMetaFields(pw, meta, maxLabelLen)
 		})
 		return int(pw.NumLines), err
 	}
 	return lineno, nil
 }
 
+// writeMetaFields writes a line per field of meta, with the values aligned
+// after labels maxLabelLen long.
+func writeMetaFields(w io.Writer, meta types.Struct, maxLabelLen int) {
+	types.TypeOf(meta).Desc.(types.StructDesc).IterFields(func(fieldName string, t *types.Type, optional bool) {
+		v := meta.Get(fieldName)
+		fmt.Fprintf(w, "%-*s", maxLabelLen+2, strings.Title(fieldName)+":")
+		if types.TypeOf(v).Equals(datetime.DateTimeType) {
+			var dt datetime.DateTime
+			dt.UnmarshalNoms(v)
+			fmt.Fprintf(w, time.Time(dt).Format(spec.CommitMetaDateFormat))
+		} else {
+			types.WriteEncodedValue(w, v)
+		}
+		fmt.Fprintf(w, "\n")
+	})
+}
+
 func writeCommitLines(node LogNode, path types.Path, maxLines, lineno int, w io.Writer) (lineCnt int, err error) {
 	genPrefix := func(pw *writers.PrefixWriter) []byte {
 		return []byte(genGraph(node, int(pw.NumLines)+1))
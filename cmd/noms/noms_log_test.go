@@ -127,6 +127,43 @@ func (s *nomsLogTestSuite) TestNArg() {
 	s.Contains(res, h1.String())
 }
 
+func (s *nomsLogTestSuite) TestFormat() {
+	sp, err := spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	ds, err := addCommit(db.GetDataset("formatTest"), "1")
+	s.NoError(err)
+	h1 := ds.Head().Hash().String()
+	meta := types.NewStruct("Meta", types.StructData{"message": types.String("second")})
+	ds, err = db.Commit(ds, types.String("2"), datas.CommitOptions{Meta: meta})
+	s.NoError(err)
+	h2 := ds.Head().Hash().String()
+
+	dsSpec := spec.CreateValueSpecString("nbs", s.DBDir, "formatTest")
+	res, _ := s.MustRun(main, []string{"log", "--format=json", dsSpec})
+	s.Equal(`{"hash":"`+h2+`","meta":{"message":"second"},"parents":["`+h1+`"]}
+{"hash":"`+h1+`","meta":{},"parents":[]}
+`, res)
+
+	res, _ = s.MustRun(main, []string{"log", "--format=yaml", "--show-value", "-n1", dsSpec})
+	s.Equal(`- hash: "`+h2+`"
+  meta:
+    message: "second"
+  parents:
+    - "`+h1+`"
+  value: "2"
+`, res)
+
+	res, _ = s.MustRun(main, []string{"log", "--format=template", "--template", `{{.hash}} {{path ".value"}} {{.meta.message}}`, dsSpec})
+	s.Equal(h2+" 2 second\n"+h1+" 1 <no value>\n", res)
+
+	_, stderr, recovered := s.Run(main, []string{"log", "--format=template", dsSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "--format=template requires --template")
+}
+
 func (s *nomsLogTestSuite) TestEmptyCommit() {
 	sp, err := spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
 	s.NoError(err)
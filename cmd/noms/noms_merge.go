@@ -12,6 +12,7 @@ import (
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
+	_ "github.com/attic-labs/noms/go/crdt" // registers the merges of the CRDT structs
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/merge"
@@ -0,0 +1,58 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/migration"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsMigrate = &util.Command{
+	Run:       runMigrate,
+	UsageLine: "migrate <source-database> <dest-database>",
+	Short:     "Rewrites a database of an older noms version in the current format",
+	Long: `Rewrites <source-database>, written by an older version of noms, to <dest-database>, which must be empty, in the format of the current version, ` + constants.NomsVersion + `. <source-database> isn't changed.
+
+The data is migrated a version at a time, and hashes are kept wherever the format didn't change.
+
+Only databases with a local chunk store, such as nbs, are supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database arguments.`,
+	Flags: setupMigrateFlags,
+	Nargs: 2,
+}
+
+func setupMigrateFlags() *flag.FlagSet {
+	migrateFlagSet := flag.NewFlagSet("migrate", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(migrateFlagSet)
+	return migrateFlagSet
+}
+
+func runMigrate(args []string) int {
+	cfg := config.NewResolver()
+	src, err := cfg.GetChunkStore(args[0])
+	d.CheckErrorNoUsage(err)
+	if src == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have a local chunk store", args[0]))
+	}
+	defer src.Close()
+	sink, err := cfg.GetChunkStore(args[1])
+	d.CheckErrorNoUsage(err)
+	if sink == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have a local chunk store", args[1]))
+	}
+	defer sink.Close()
+
+	from, oldRoot := src.Version(), src.Root()
+	root, err := migration.Run(src, sink)
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("Migrated #%s (version %s) to #%s (version %s)\n", oldRoot, from, root, constants.NomsVersion)
+	return 0
+}
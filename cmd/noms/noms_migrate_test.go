@@ -0,0 +1,80 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/migration"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+// testOldVersion is the version of the database migrated by the tests.
+const testOldVersion = "0.0-migrate-test"
+
+func init() {
+	migration.Register(migration.Migration{From: testOldVersion, To: constants.NomsVersion, Migrate: migration.Copy})
+}
+
+func TestNomsMigrate(t *testing.T) {
+	suite.Run(t, &nomsMigrateTestSuite{})
+}
+
+type nomsMigrateTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+// setVersion rewrites the noms version in the manifest of the nbs store in
+// dir.
+func (s *nomsMigrateTestSuite) setVersion(dir, version string) {
+	name := filepath.Join(dir, "manifest")
+	data, err := ioutil.ReadFile(name)
+	s.NoError(err)
+	fields := strings.Split(string(data), ":")
+	fields[1] = version
+	s.NoError(ioutil.WriteFile(name, []byte(strings.Join(fields, ":")), 0644))
+}
+
+func (s *nomsMigrateTestSuite) TestMigrate() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("hello"))
+	s.NoError(err)
+	root := ds.HeadRef().TargetHash()
+	s.NoError(db.Close())
+
+	src := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	dst := spec.CreateDatabaseSpecString("nbs", s.DBDir2)
+	_, stderr, recovered := s.Run(main, []string{"migrate", src, dst})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "already at version "+constants.NomsVersion)
+
+	s.setVersion(s.DBDir, testOldVersion)
+	out, _ := s.MustRun(main, []string{"migrate", src, dst})
+	s.Contains(out, "(version "+testOldVersion+") to #")
+	s.Contains(out, "(version "+constants.NomsVersion+")\n")
+
+	out, _ = s.MustRun(main, []string{"show", dst + "::ds"})
+	s.Contains(out, "value: \"hello\"")
+	out, _ = s.MustRun(main, []string{"show", dst + "::#" + root.String() + ".value"})
+	s.Equal("\"hello\"\n", out)
+
+	_, stderr, recovered = s.Run(main, []string{"migrate", src, dst})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "the destination isn't empty")
+
+	s.setVersion(s.DBDir, "0.0-unknown")
+	_, stderr, recovered = s.Run(main, []string{"migrate", src, spec.CreateDatabaseSpecString("nbs", s.TempDir)})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "no migration from version 0.0-unknown")
+}
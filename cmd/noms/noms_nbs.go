@@ -0,0 +1,124 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var nbsNoGarbage bool
+
+var nomsNBS = &util.Command{
+	Run:       runNBS,
+	UsageLine: "nbs inspect [--no-garbage] <database>",
+	Short:     "Shows the manifest and tables of an nbs database",
+	Long: `Shows the contents of the manifest of <database>, which must be an nbs database, either local or in AWS, the number of chunks in each of its tables and their sizes, how much of the data is garbage, which "noms gc" would reclaim, and what could be done about it.
+
+Finding the garbage reads all of the data reachable from the root of the database, which can take a while; --no-garbage skips it.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupNBSFlags,
+	Nargs: 2,
+}
+
+func setupNBSFlags() *flag.FlagSet {
+	nbsFlagSet := flag.NewFlagSet("nbs", flag.ExitOnError)
+	nbsFlagSet.BoolVar(&nbsNoGarbage, "no-garbage", false, "don't look for garbage")
+	verbose.RegisterVerboseFlags(nbsFlagSet)
+	return nbsFlagSet
+}
+
+func runNBS(args []string) int {
+	if args[0] != "inspect" {
+		d.CheckErrorNoUsage(fmt.Errorf("Unknown nbs command %s, the only one is inspect", args[0]))
+	}
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(args[1])
+	d.CheckErrorNoUsage(err)
+	store, ok := cs.(*nbs.NomsBlockStore)
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("%s is not an nbs database", args[1]))
+	}
+	defer store.Close()
+
+	info := store.Inspect()
+	var garbage *nbs.GCStats
+	if !nbsNoGarbage {
+		reachable := reachableChunks(store, store.Root())
+		stats, err := store.GC(reachable.Has, nbs.GCOptions{DryRun: true})
+		d.CheckErrorNoUsage(err)
+		garbage = &stats
+	}
+	printInspection(os.Stdout, args[1], info, garbage)
+	return 0
+}
+
+// smallTableChunks is the number of chunks below which a table is small.
+// Each table is searched separately, so reads get slower as small tables
+// pile up.
+const smallTableChunks = 1 << 10
+
+func printInspection(w io.Writer, dbSpec string, info nbs.ManifestInfo, garbage *nbs.GCStats) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Storage version:\t%s\n", info.StorageVersion)
+	fmt.Fprintf(tw, "Noms version:\t%s\n", info.NomsVersion)
+	fmt.Fprintf(tw, "Lock:\t%s\n", info.Lock)
+	fmt.Fprintf(tw, "Root:\t#%s\n", info.Root)
+	d.PanicIfError(tw.Flush())
+
+	var chunks, small uint64
+	var raw, compressed uint64
+	for _, t := range info.Tables {
+		chunks += uint64(t.Chunks)
+		raw += t.RawBytes
+		compressed += t.CompressedBytes
+		if t.Chunks < smallTableChunks {
+			small++
+		}
+	}
+	fmt.Fprintf(w, "\n%d of at most %d tables: %d chunks (%s, %s compressed)\n", len(info.Tables), info.MaxTables, chunks, humanize.Bytes(raw), humanize.Bytes(compressed))
+	if len(info.Tables) > 0 {
+		fmt.Fprintln(tw, "Table\tChunks\tSize\tCompressed")
+		for _, t := range info.Tables {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", t.Name, t.Chunks, humanize.Bytes(t.RawBytes), humanize.Bytes(t.CompressedBytes))
+		}
+		d.PanicIfError(tw.Flush())
+	}
+
+	if garbage != nil {
+		fmt.Fprintf(w, "\nGarbage: %d of %d chunks (%s of %s) in %d of %d tables\n",
+			garbage.ReclaimedChunks, garbage.Chunks,
+			humanize.Bytes(garbage.ReclaimedBytes), humanize.Bytes(garbage.Bytes),
+			garbage.CollectedTables, garbage.Tables)
+	}
+
+	recommendations := []string{}
+	if garbage != nil && garbage.ReclaimedChunks > 0 {
+		recommendations = append(recommendations, fmt.Sprintf("Run noms gc %s to reclaim the garbage. The tables holding it, %d of %d, are rewritten into one.", dbSpec, garbage.CollectedTables, garbage.Tables))
+	}
+	if len(info.Tables) > info.MaxTables*3/4 {
+		recommendations = append(recommendations, fmt.Sprintf("All %d tables will be conjoined into one on a write once there are more than %d, which holds all of the data, %s, in memory.", len(info.Tables), info.MaxTables, humanize.Bytes(raw)))
+	} else if small > 1 && small*2 > uint64(len(info.Tables)) {
+		recommendations = append(recommendations, fmt.Sprintf("%d tables have fewer than %d chunks each, which slows reads down. Writing more data at a time makes bigger tables.", small, smallTableChunks))
+	}
+	if len(recommendations) == 0 {
+		recommendations = append(recommendations, "None, the store looks healthy.")
+	}
+	fmt.Fprintln(w, "\nRecommendations:")
+	for _, r := range recommendations {
+		fmt.Fprintf(w, "  %s\n", r)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsNBS(t *testing.T) {
+	suite.Run(t, &nomsNBSTestSuite{})
+}
+
+type nomsNBSTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsNBSTestSuite) TestInspect() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	_, err := db.CommitValue(db.GetDataset("keep"), types.String("keep"))
+	s.NoError(err)
+	ds, err := db.CommitValue(db.GetDataset("drop"), types.String("drop"))
+	s.NoError(err)
+	_, err = db.Delete(ds)
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	out, _ := s.MustRun(main, []string{"nbs", "inspect", dbSpec})
+	s.Contains(out, "Noms version:     "+constants.NomsVersion+"\n")
+	s.Contains(out, "2 of at most 128 tables: 4 chunks")
+	s.Contains(out, "Table  ")
+	s.Contains(out, "Garbage: 2 of 4 chunks")
+	s.Contains(out, "Run noms gc "+dbSpec+" to reclaim the garbage. The tables holding it, 1 of 2, ")
+
+	s.MustRun(main, []string{"gc", dbSpec})
+	out, _ = s.MustRun(main, []string{"nbs", "inspect", dbSpec})
+	s.Contains(out, "Garbage: 0 of ")
+	s.NotContains(out, "Run noms gc")
+
+	out, _ = s.MustRun(main, []string{"nbs", "inspect", "--no-garbage", dbSpec})
+	s.NotContains(out, "Garbage:")
+
+	_, stderr, recovered := s.Run(main, []string{"nbs", "inspect", "mem"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "mem is not an nbs database")
+	_, stderr, recovered = s.Run(main, []string{"nbs", "fix", dbSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Unknown nbs command fix")
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	pinValue  string
+	pinDelete string
+	pinTTL    time.Duration
+	pinExpire bool
+)
+
+var nomsPin = &util.Command{
+	Run:       runPin,
+	UsageLine: "pin [<database> | -p <value> [--ttl <duration>] | -d <value> | --expire <database>]",
+	Short:     "Lists, adds or removes pins, which keep values from being collected",
+	Long: `A pin keeps a value, and the values reachable from it, from being collected by "noms gc", e.g. while a process which wrote it is still working with it, before the value is committed. Pins are counted: a value which is pinned twice stays pinned until it's unpinned twice, or until the pin expires. Pins with a --ttl expire when the last of those counted does, and are removed by "noms pin --expire" and before "noms gc" collects a database. Pins are stored in the datasets "pins/<hash>".
+
+With no flags, lists the pins of <database>, how many times their values are pinned, and when the pins expire.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and value arguments.`,
+	Flags: setupPinFlags,
+	Nargs: 0,
+}
+
+func setupPinFlags() *flag.FlagSet {
+	pinFlagSet := flag.NewFlagSet("pin", flag.ExitOnError)
+	pinFlagSet.StringVar(&pinValue, "p", "", "value to pin")
+	pinFlagSet.StringVar(&pinDelete, "d", "", "value to unpin")
+	pinFlagSet.DurationVar(&pinTTL, "ttl", 0, "duration after which the pin expires, e.g. 1h, never if 0")
+	pinFlagSet.BoolVar(&pinExpire, "expire", false, "remove the expired pins of <database>")
+	verbose.RegisterVerboseFlags(pinFlagSet)
+	return pinFlagSet
+}
+
+func runPin(args []string) int {
+	cfg := config.NewResolver()
+	switch {
+	case pinValue != "" && pinDelete != "":
+		d.CheckErrorNoUsage(errors.New("expected only one of -p and -d"))
+
+	case pinValue != "":
+		db, v := getPinValue(cfg, pinValue)
+		defer db.Close()
+
+		p, err := db.Pin(v.Hash(), pinTTL)
+		if err == datas.ErrValueNotFound {
+			err = fmt.Errorf("%s is not a value of its own in the database, pin a value which contains it", pinValue)
+		}
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Pinned #%s (%s)\n", v.Hash().String(), describePin(p))
+
+	case pinDelete != "":
+		db, v := getPinValue(cfg, pinDelete)
+		defer db.Close()
+
+		p, err := db.Unpin(v.Hash())
+		if err == datas.ErrNotPinned {
+			err = fmt.Errorf("#%s is not pinned", v.Hash().String())
+		}
+		d.CheckErrorNoUsage(err)
+		if p.Count == 0 {
+			fmt.Printf("Unpinned #%s\n", v.Hash().String())
+		} else {
+			fmt.Printf("Unpinned #%s (%s)\n", v.Hash().String(), describePin(p))
+		}
+
+	case len(args) <= 1:
+		dbSpec := ""
+		if len(args) == 1 {
+			dbSpec = args[0]
+		}
+		db, err := cfg.GetDatabase(dbSpec)
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+
+		if pinExpire {
+			expired, err := db.ExpirePins()
+			d.CheckErrorNoUsage(err)
+			fmt.Printf("Removed %d expired pins\n", len(expired))
+			return 0
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, p := range datas.Pins(db) {
+			fmt.Fprintf(tw, "#%s\t%s\n", p.Value.TargetHash().String(), describePin(p))
+		}
+		tw.Flush()
+
+	default:
+		d.CheckErrorNoUsage(errors.New("expected a database, or -p or -d with a value"))
+	}
+	return 0
+}
+
+// getPinValue returns the value spelled by str, and its database.
+func getPinValue(cfg *config.Resolver, str string) (datas.Database, types.Value) {
+	db, v, err := cfg.GetPath(str)
+	d.CheckErrorNoUsage(err)
+	if v == nil {
+		db.Close()
+		d.CheckErrorNoUsage(fmt.Errorf("Error resolving value: %s", str))
+	}
+	return db, v
+}
+
+func describePin(p datas.Pin) string {
+	expires := "never expires"
+	if p.Expired(time.Now()) {
+		expires = "expired " + p.Expires.Local().Format(time.RFC3339)
+	} else if !p.Expires.IsZero() {
+		expires = "expires " + p.Expires.Local().Format(time.RFC3339)
+	}
+	times := "times"
+	if p.Count == 1 {
+		times = "time"
+	}
+	return fmt.Sprintf("pinned %d %s, %s", p.Count, times, expires)
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsPin(t *testing.T) {
+	suite.Run(t, &nomsPinTestSuite{})
+}
+
+type nomsPinTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsPinTestSuite) TestPin() {
+	cs := nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize)
+	db := datas.NewDatabase(cs)
+	_, err := db.CommitValue(db.GetDataset("live"), types.String("still here"))
+	s.NoError(err)
+	pinnedRef := db.WriteValue(types.NewList(types.String("work"), types.String("in progress")))
+	expiringRef := db.WriteValue(types.NewList(types.String("abandoned")))
+	pinned, expiring := pinnedRef.TargetHash(), expiringRef.TargetHash()
+	// Values are only written once they're reachable from a dataset, which
+	// is deleted below.
+	_, err = db.CommitValue(db.GetDataset("tmp"), types.NewList(pinnedRef, expiringRef))
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	value := func(h string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, "#"+h)
+	}
+	s.MustRun(main, []string{"ds", "-d", spec.CreateValueSpecString("nbs", s.DBDir, "tmp")})
+
+	out, _ := s.MustRun(main, []string{"pin", "-p", value(pinned.String())})
+	s.Equal("Pinned #"+pinned.String()+" (pinned 1 time, never expires)\n", out)
+	out, _ = s.MustRun(main, []string{"pin", "-p", value(pinned.String())})
+	s.Equal("Pinned #"+pinned.String()+" (pinned 2 times, never expires)\n", out)
+	out, _ = s.MustRun(main, []string{"pin", "--ttl", "1ns", "-p", value(expiring.String())})
+	s.True(strings.HasPrefix(out, "Pinned #"+expiring.String()+" (pinned 1 time, "))
+	time.Sleep(time.Millisecond)
+
+	out, _ = s.MustRun(main, []string{"pin", dbSpec})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	s.Len(lines, 2)
+	s.Contains(out, "#"+pinned.String()+"  pinned 2 times, never expires\n")
+	s.Contains(out, "#"+expiring.String()+"  pinned 1 time, expired ")
+
+	// GC removes the expired pin, and keeps the pinned value.
+	s.MustRun(main, []string{"gc", dbSpec})
+	out, _ = s.MustRun(main, []string{"show", value(pinned.String())})
+	s.Contains(out, "in progress")
+	out, _ = s.MustRun(main, []string{"pin", dbSpec})
+	s.Equal("#"+pinned.String()+"  pinned 2 times, never expires\n", out)
+	_, errOut, _ := s.Run(main, []string{"show", value(expiring.String())})
+	s.Contains(errOut, "Object not found")
+
+	out, _ = s.MustRun(main, []string{"pin", "-d", value(pinned.String())})
+	s.Equal("Unpinned #"+pinned.String()+" (pinned 1 time, never expires)\n", out)
+	out, _ = s.MustRun(main, []string{"pin", "-d", value(pinned.String())})
+	s.Equal("Unpinned #"+pinned.String()+"\n", out)
+	out, _ = s.MustRun(main, []string{"pin", dbSpec})
+	s.Equal("", out)
+
+	s.MustRun(main, []string{"gc", dbSpec})
+	_, errOut, _ = s.Run(main, []string{"show", value(pinned.String())})
+	s.Contains(errOut, "Object not found")
+	out, _ = s.MustRun(main, []string{"show", spec.CreateValueSpecString("nbs", s.DBDir, "live.value")})
+	s.Equal("\"still here\"\n", out)
+
+	_, _, recovered := s.Run(main, []string{"pin", "-d", value(pinned.String())})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
@@ -0,0 +1,215 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/query"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsQuery = &util.Command{
+	Run:       runQuery,
+	UsageLine: "query [flags] <query>",
+	Short:     "Queries a collection with a subset of SQL",
+	Long: `Runs a query of the form:
+
+  SELECT <fields> FROM <path> [WHERE <expr>] [ORDER BY <field> [ASC|DESC], ...] [LIMIT <n> [OFFSET <m>]]
+
+against the List, Set or Map at <path>, e.g.
+
+  noms query "SELECT name, address.city AS city FROM db::people.value WHERE age >= 18 ORDER BY city"
+
+Rows are the elements of the collection and fields are the fields of the elements if they're structs. The pseudo fields _key, _index and _value are the key of a Map entry or Set element, the index of a List element and the element itself. Conditions on _key or _index limit the part of the collection which is scanned.
+
+WHERE expressions can use =, !=, <>, <, <=, >, >=, LIKE, IS [NOT] NULL, AND, OR and NOT. Missing fields are NULL.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path.`,
+	Flags: setupQueryFlags,
+	Nargs: 1,
+}
+
+var queryFormat string
+
+func setupQueryFlags() *flag.FlagSet {
+	queryFlagSet := flag.NewFlagSet("query", flag.ExitOnError)
+	queryFlagSet.StringVar(&queryFormat, "format", "table", "output format: table, csv or json, which writes an object per line")
+	outputpager.RegisterOutputpagerFlags(queryFlagSet)
+	verbose.RegisterVerboseFlags(queryFlagSet)
+	return queryFlagSet
+}
+
+func runQuery(args []string) int {
+	q, err := query.Parse(strings.Join(args, " "))
+	d.CheckErrorNoUsage(err)
+
+	var w queryWriter
+	switch queryFormat {
+	case "table":
+		w = &tableQueryWriter{}
+	case "csv":
+		w = &csvQueryWriter{}
+	case "json":
+		w = &jsonQueryWriter{}
+	default:
+		d.CheckErrorNoUsage(fmt.Errorf("unknown format %s, expected table, csv or json", queryFormat))
+	}
+
+	cfg := config.NewResolver()
+	db, value, err := cfg.GetPath(q.From)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", q.From))
+	}
+
+	columns, err := q.Columns(value)
+	d.CheckErrorNoUsage(err)
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	err = w.start(pgr.Writer, names)
+	if err == nil {
+		err = q.Run(value, func(values []types.Value) bool {
+			err = w.row(values)
+			return err != nil
+		})
+	}
+	if err == nil {
+		err = w.end()
+	}
+	d.CheckErrorNoUsage(err)
+	return 0
+}
+
+// queryWriter writes the rows of a query in some format.
+type queryWriter interface {
+	start(w io.Writer, columns []string) error
+	row(values []types.Value) error
+	end() error
+}
+
+// formatQueryValue formats a value for table and CSV output. Strings are
+// written as they are and other values as JSON.
+func formatQueryValue(v types.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case types.String:
+		return string(v)
+	}
+	b, err := json.Marshal(jsonValue(v))
+	d.PanicIfError(err)
+	return string(b)
+}
+
+// tableQueryWriter aligns the rows in columns, so it writes them all at the
+// end.
+type tableQueryWriter struct {
+	tw *tabwriter.Writer
+}
+
+// Tabs and newlines in values would break the table.
+var tableCellReplacer = strings.NewReplacer("\t", " ", "\n", " ")
+
+func (w *tableQueryWriter) start(out io.Writer, columns []string) error {
+	w.tw = tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	_, err := fmt.Fprintln(w.tw, strings.Join(columns, "\t"))
+	return err
+}
+
+func (w *tableQueryWriter) row(values []types.Value) error {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = tableCellReplacer.Replace(formatQueryValue(v))
+	}
+	_, err := fmt.Fprintln(w.tw, strings.Join(cells, "\t"))
+	return err
+}
+
+func (w *tableQueryWriter) end() error {
+	return w.tw.Flush()
+}
+
+type csvQueryWriter struct {
+	cw *csv.Writer
+}
+
+func (w *csvQueryWriter) start(out io.Writer, columns []string) error {
+	w.cw = csv.NewWriter(out)
+	return w.cw.Write(columns)
+}
+
+func (w *csvQueryWriter) row(values []types.Value) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatQueryValue(v)
+	}
+	return w.cw.Write(record)
+}
+
+func (w *csvQueryWriter) end() error {
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+// jsonQueryWriter writes a JSON object per row, with the columns in order.
+// NULL values are left out.
+type jsonQueryWriter struct {
+	out     io.Writer
+	columns [][]byte
+}
+
+func (w *jsonQueryWriter) start(out io.Writer, columns []string) error {
+	w.out = out
+	for _, c := range columns {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		w.columns = append(w.columns, b)
+	}
+	return nil
+}
+
+func (w *jsonQueryWriter) row(values []types.Value) error {
+	buf := []byte{'{'}
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		b, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(append(append(buf, w.columns[i]...), ':'), b...)
+	}
+	_, err := w.out.Write(append(buf, '}', '\n'))
+	return err
+}
+
+func (w *jsonQueryWriter) end() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsQuery(t *testing.T) {
+	suite.Run(t, &nomsQueryTestSuite{})
+}
+
+type nomsQueryTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsQueryTestSuite) SetupTest() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	city := func(name, state string, population float64) types.Value {
+		return types.NewStruct("City", types.StructData{
+			"name":       types.String(name),
+			"state":      types.String(state),
+			"population": types.Number(population),
+			"tags":       types.NewSet(types.String(state)),
+		})
+	}
+	cities := types.NewMap(
+		types.String("aus"), city("Austin", "TX", 947890),
+		types.String("bos"), city("Boston", "MA", 673184),
+		types.String("dal"), city("Dallas", "TX", 1317929),
+		types.String("oak"), city("Oakland, \"The Town\"", "CA", 420005),
+	)
+	_, err := db.CommitValue(db.GetDataset("cities"), cities)
+	s.NoError(err)
+	s.NoError(db.Close())
+}
+
+func (s *nomsQueryTestSuite) path() string {
+	return spec.CreateValueSpecString("nbs", s.DBDir, "cities.value")
+}
+
+func (s *nomsQueryTestSuite) TestTable() {
+	out, _ := s.MustRun(main, []string{"query", "SELECT _key AS code, name, population FROM " + s.path() + " WHERE state = 'TX' ORDER BY population DESC"})
+	s.Equal(`code  name    population
+dal   Dallas  1317929
+aus   Austin  947890
+`, out)
+
+	out, _ = s.MustRun(main, []string{"query", "SELECT * FROM " + s.path() + " WHERE _key > 'b' AND _key < 'd'"})
+	s.Equal(`_key  name    population  state  tags
+bos   Boston  673184      MA     ["MA"]
+`, out)
+}
+
+func (s *nomsQueryTestSuite) TestCSV() {
+	out, _ := s.MustRun(main, []string{"query", "--format", "csv", "SELECT name, state FROM " + s.path() + " WHERE name LIKE '%a%'"})
+	s.Equal("name,state\nDallas,TX\n\"Oakland, \"\"The Town\"\"\",CA\n", out)
+}
+
+func (s *nomsQueryTestSuite) TestJSON() {
+	out, _ := s.MustRun(main, []string{"query", "--format=json", "SELECT name, tags, nope FROM " + s.path() + " WHERE population < 500000 OR _key = 'aus'"})
+	s.Equal(`{"name":"Austin","tags":["TX"]}
+{"name":"Oakland, \"The Town\"","tags":["CA"]}
+`, out)
+}
+
+func (s *nomsQueryTestSuite) TestErrors() {
+	_, stderr, recovered := s.Run(main, []string{"query", "SELECT name FROM"})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "expected path after FROM")
+
+	_, stderr, recovered = s.Run(main, []string{"query", "SELECT * FROM " + spec.CreateValueSpecString("nbs", s.DBDir, `cities.value["aus"]`)})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "expected a List, Set or Map")
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/redact"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	redactRules string
+	redactSalt  string
+)
+
+var nomsRedact = &util.Command{
+	Run:       runRedact,
+	UsageLine: "redact --rules <rules> [--salt <salt>] <absolute-path> <dataset>",
+	Short:     "Commits a redacted copy of a value as head of a dataset",
+	Long: `Copies the value at absolute-path, with the values in it which match the rules dropped, masked or hashed, and commits the copy as the head of dataset, without the history of the value, e.g. to make a copy of production data which is safe for developers to use. Values which Refs point to are redacted too, as if they were at the path of the Ref. Use "noms sync" to copy the redacted dataset to another database.
+
+Rules are of the form <action>:<pattern>, where action is drop, mask or hash, and pattern is either the name of a struct field, e.g. "hash:email", or a path in which ".*" matches any field, "[*]" any element of a collection, "[*]@key" any key of a map, and "**" any number of parts, e.g. "drop:.users[*].ssn" or "mask:**.address". The first rule a value matches applies. Mask replaces strings with "***", numbers with 0, bools with false and collections with empty ones. Hash replaces values with a hash of them keyed by --salt, so that equal values stay equal.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the absolute-path and dataset arguments.`,
+	Flags: setupRedactFlags,
+	Nargs: 2,
+}
+
+func setupRedactFlags() *flag.FlagSet {
+	redactFlagSet := flag.NewFlagSet("redact", flag.ExitOnError)
+	redactFlagSet.StringVar(&redactRules, "rules", "", "comma-separated list of <drop|mask|hash>:<pattern> rules")
+	redactFlagSet.StringVar(&redactSalt, "salt", "", "salt of the hashes of the values redacted by hash rules")
+	spec.RegisterCommitMetaFlags(redactFlagSet)
+	verbose.RegisterVerboseFlags(redactFlagSet)
+	return redactFlagSet
+}
+
+func runRedact(args []string) int {
+	if redactRules == "" {
+		d.CheckErrorNoUsage(errors.New("expected --rules"))
+	}
+	rules := []redact.Rule{}
+	for _, str := range strings.Split(redactRules, ",") {
+		rule, err := redact.ParseRule(str)
+		d.CheckErrorNoUsage(err)
+		rules = append(rules, rule)
+	}
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[1])
+	d.CheckError(err)
+	defer db.Close()
+
+	absPath, err := spec.NewAbsolutePath(args[0])
+	d.CheckError(err)
+	value := absPath.Resolve(db)
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Error resolving value: %s", args[0]))
+	}
+
+	redacted := redact.Redactor{Rules: rules, Salt: []byte(redactSalt)}.Value(value, db)
+	if redacted == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s is dropped by the rules", args[0]))
+	}
+
+	meta, err := spec.CreateCommitMetaStruct(db, "", "", nil, nil)
+	d.CheckErrorNoUsage(err)
+	ds, err = db.Commit(ds, redacted, datas.CommitOptions{Meta: meta})
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("Redacted %s to #%s\n", args[0], ds.HeadRef().TargetHash().String())
+	return 0
+}
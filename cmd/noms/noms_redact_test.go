@@ -0,0 +1,63 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/redact"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsRedact(t *testing.T) {
+	suite.Run(t, &nomsRedactTestSuite{})
+}
+
+type nomsRedactTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsRedactTestSuite) TestRedact() {
+	name := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+	sp, err := spec.ForDataset(name("prod"))
+	s.NoError(err)
+	defer sp.Close()
+
+	user := func(name, ssn, email string) types.Struct {
+		return types.NewStruct("User", types.StructData{
+			"name":  types.String(name),
+			"ssn":   types.String(ssn),
+			"email": types.String(email),
+		})
+	}
+	users := types.NewList(user("alice", "123", "a@example.com"), user("bob", "456", "b@example.com"))
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.NewStruct("", types.StructData{"users": users}))
+	s.NoError(err)
+
+	out, _ := s.MustRun(main, []string{"redact", "--rules", "drop:.users[*].ssn,mask:email", "prod.value", name("dev")})
+	s.Contains(out, "Redacted prod.value to #")
+
+	dev, err := spec.ForDataset(name("dev"))
+	s.NoError(err)
+	defer dev.Close()
+	head, ok := dev.GetDataset().MaybeHead()
+	s.True(ok)
+	s.Equal(uint64(0), head.Get("parents").(types.Set).Len())
+	redacted := dev.GetDataset().HeadValue().(types.Struct).Get("users").(types.List)
+	s.Equal(uint64(2), redacted.Len())
+	bob := redacted.Get(1).(types.Struct)
+	s.Equal(types.String("bob"), bob.Get("name"))
+	s.Equal(types.String(redact.MaskString), bob.Get("email"))
+	_, ok = bob.MaybeGet("ssn")
+	s.False(ok)
+
+	_, _, recovered := s.Run(main, []string{"redact", "--rules", "erase:ssn", "prod.value", name("dev")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsReflog = &util.Command{
+	Run:       runReflog,
+	UsageLine: "reflog <dataset>",
+	Short:     "Lists the previous heads of a dataset",
+	Long: `Lists the heads <dataset> had, newest first, with when it moved to them and the command which moved it.
+
+Like git's, the reflog is kept on the machine running noms, in ` + "`~/.noms/reflog`" + `, and records the changes noms commands made from it. Use "noms ds --reset <dataset> <hash>" to move a dataset back to a previous head.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.`,
+	Flags: setupReflogFlags,
+	Nargs: 1,
+}
+
+func setupReflogFlags() *flag.FlagSet {
+	reflogFlagSet := flag.NewFlagSet("reflog", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(reflogFlagSet)
+	return reflogFlagSet
+}
+
+func runReflog(args []string) int {
+	cfg := config.NewResolver()
+	entries, err := cfg.Reflog(args[0])
+	d.CheckErrorNoUsage(err)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, e := range entries {
+		head := "deleted"
+		if !e.New.IsEmpty() {
+			head = "#" + e.New.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", head, e.Time.Local().Format("2006-01-02 15:04:05 -0700"), e.Command)
+	}
+	tw.Flush()
+	return 0
+}
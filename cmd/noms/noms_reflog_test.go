@@ -0,0 +1,51 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsReflog(t *testing.T) {
+	suite.Run(t, &nomsReflogTestSuite{})
+}
+
+type nomsReflogTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsReflogTestSuite) TestReflog() {
+	first, second := setupBranches(&s.ClientTestSuite)
+	name := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+
+	out, _ := s.MustRun(main, []string{"reflog", name("master")})
+	s.Equal("", out)
+
+	s.MustRun(main, []string{"ds", "--reset", name("master"), first})
+	s.MustRun(main, []string{"ds", "--rename", name("master"), "main"})
+
+	out, _ = s.MustRun(main, []string{"reflog", name("master")})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	s.Len(lines, 2)
+	s.True(strings.HasPrefix(lines[0], "deleted  "))
+	s.Contains(lines[0], " ds --rename "+name("master")+" main")
+	s.True(strings.HasPrefix(lines[1], "#"+first+"  "))
+	s.Contains(lines[1], " ds --reset "+name("master")+" "+first)
+
+	out, _ = s.MustRun(main, []string{"reflog", name("main")})
+	s.True(strings.HasPrefix(out, "#"+first+"  "))
+
+	// The previous head is in the reflog, so it can be restored.
+	s.MustRun(main, []string{"ds", "--reset", name("main"), second})
+	out, _ = s.MustRun(main, []string{"reflog", name("main")})
+	s.True(strings.HasPrefix(out, "#"+second+"  "))
+}
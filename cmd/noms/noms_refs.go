@@ -0,0 +1,45 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsRefs = &util.Command{
+	Run:       runRefs,
+	UsageLine: "refs <database> <hash>",
+	Short:     "Lists the chunks a chunk of a database refers to",
+	Long: `Lists the hash, height and target type of each ref in the chunk <hash> of <database>, one per line.
+
+Remote databases are not supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupRefsFlags,
+	Nargs: 2,
+}
+
+func setupRefsFlags() *flag.FlagSet {
+	refsFlagSet := flag.NewFlagSet("refs", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(refsFlagSet)
+	return refsFlagSet
+}
+
+func runRefs(args []string) int {
+	db, c, err := getChunk(args[0], args[1])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	refs := []types.Ref{}
+	types.DecodeValue(c, db).WalkRefs(func(r types.Ref) {
+		refs = append(refs, r)
+	})
+	printRefs(os.Stdout, refs)
+	return 0
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+// restoreBatchSize is the number of chunks put into the chunk store at a
+// time.
+const restoreBatchSize = 1 << 10
+
+var nomsRestore = &util.Command{
+	Run:       runRestore,
+	UsageLine: "restore <archive>... <database>",
+	Short:     "Restores a database from archive files",
+	Long: `Restores the archives written by "noms backup" to <database>, in order. The first archive must be a full backup if <database> is empty, and each incremental archive must be based on the database restored so far, so a database can be brought up to date with the incremental archives written after the one it was restored from.
+
+Only databases with a local chunk store, such as nbs, are supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupRestoreFlags,
+	Nargs: 2,
+}
+
+func setupRestoreFlags() *flag.FlagSet {
+	restoreFlagSet := flag.NewFlagSet("restore", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(restoreFlagSet)
+	return restoreFlagSet
+}
+
+func runRestore(args []string) int {
+	archives, dbSpec := args[:len(args)-1], args[len(args)-1]
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(dbSpec)
+	d.CheckErrorNoUsage(err)
+	if cs == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't have a local chunk store", dbSpec))
+	}
+	defer cs.Close()
+
+	for _, name := range archives {
+		d.CheckErrorNoUsage(restoreArchive(cs, name))
+	}
+	return 0
+}
+
+// restoreArchive puts the chunks in the archive name into cs, and moves its
+// root to the archive's.
+func restoreArchive(cs chunks.ChunkStore, name string) error {
+	a, err := openArchive(name)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	m, err := a.manifest(false)
+	if err != nil {
+		return err
+	}
+	root, ok := hash.MaybeParse(m.Root)
+	if !ok {
+		return fmt.Errorf("%s has an invalid root: %s", name, m.Root)
+	}
+
+	current := cs.Root()
+	switch {
+	case current == root:
+		fmt.Printf("Database is already at #%s, skipped %s\n", root, name)
+		return nil
+	case m.Base == "" && !current.IsEmpty():
+		return fmt.Errorf("%s is a full backup, but the database isn't empty", name)
+	case m.Base != "" && current.String() != m.Base:
+		return fmt.Errorf("%s is based on #%s, but the database is at #%s", name, m.Base, current)
+	}
+
+	found := make(chan *chunks.Chunk, restoreBatchSize)
+	errs := make(chan error, 1)
+	go func() { errs <- a.readChunks(found) }()
+	batch := make([]chunks.Chunk, 0, restoreBatchSize)
+	for c := range found {
+		if batch = append(batch, *c); len(batch) == restoreBatchSize {
+			cs.PutMany(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := <-errs; err != nil {
+		return fmt.Errorf("Couldn't read %s: %s", name, err)
+	}
+	cs.PutMany(batch)
+	cs.Flush()
+
+	if !root.IsEmpty() && !cs.Has(root) {
+		return fmt.Errorf("%s doesn't have the chunk of its root #%s", name, root)
+	}
+	if !cs.UpdateRoot(root, current) {
+		return fmt.Errorf("The database was changed while %s was restored", name)
+	}
+	fmt.Printf("Restored #%s from %s\n", root, name)
+	return nil
+}
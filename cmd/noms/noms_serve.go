@@ -5,41 +5,142 @@
 package main
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/util/profile"
+	"github.com/attic-labs/noms/go/util/sizecache"
 	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
 	flag "github.com/juju/gnuflag"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 var (
-	port int
+	port            int
+	graphqlDatasets string
+	graphqlToken    string
+	serveCert       string
+	serveKey        string
+	acmeDomains     string
+	acmeCache       string
+	acmeEmail       string
+	readOnly        bool
+	maintenance     bool
+	retryAfter      time.Duration
+	authFile        string
+	memoryBudget    string
+	webhooksFile    string
+	replicaOf       string
+	proxyWrites     bool
+	auditLog        string
 )
 
 var nomsServe = &util.Command{
 	Run:       runServe,
 	UsageLine: "serve [options] <database>",
 	Short:     "Serves a Noms database over HTTP",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
-	Flags:     setupServeFlags,
-	Nargs:     0,
+	Long: `See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.
+
+To serve HTTPS, and HTTP/2 to clients which support it, either give a certificate and its key with --cert and --key, or the host names to get certificates for from Let's Encrypt with --acme-domains. Let's Encrypt must be able to reach the server on port 443 to verify the host names, so use --port 443 with --acme-domains.
+
+With --memory-budget, the caches and buffers which otherwise size themselves independently share one limit, so that together they stay within a container's memory. A quarter of it is kept for pending writes, which the caches can't use.
+
+With --read-only, requests to write to the database are rejected. With --maintenance, all requests are rejected with 503 Service Unavailable and a Retry-After header, except health checks to /health/, so that the database can be backed up or migrated safely while the server keeps running.
+
+With --auth, clients must authenticate with a token, a user name and password, or credentials checked by an external URL, and can only read and write the datasets the config file allows them to. As chunks can't be attributed to datasets, clients which can read any dataset can read all chunks by hash, but GraphQL queries and updates of datasets are checked per dataset. The config file is read again on SIGHUP. For example:
+
+  [[token]]
+  name = "ci"
+  token = "s3cret"
+
+  [[user]]
+  name = "alice"
+  password = "$2a$10$..."  # bcrypt hash
+
+  [verify]
+  url = "https://auth.example.com/noms"  # 200 OK with the client's name for valid credentials
+
+  [[allow]]
+  clients = ["alice", "ci"]  # or "*" for any client
+  read = ".*"                # regular expressions matching dataset IDs
+  write = "alice/.*"
+
+  [[allow]]
+  clients = ["ops"]
+  admin = true  # write access to all datasets, and the admin API
+
+The admin API, which requires --auth, has these endpoints:
+
+  GET /admin/datasets/            the datasets, with their heads and sizes
+  GET /admin/stats/               uptime, request and connection counts, and the size of the store
+  GET /admin/connections/         the open connections
+  POST /admin/gc/?retention=1h    removes unreachable data, except that written within the retention; nbs databases only
+  POST /admin/read-only/?enabled=true|false
+  GET /admin/audit/?known=<hash>  verifies the audit log, and that it contains a head of it recorded before
+
+With --replica-of, the server serves a replica of the database served by another noms serve, its primary, which it keeps up to date as the primary's root changes. The replica is first synced with the primary before serving, using the credentials of the primary's profile, if any. Requests to write to the replica are rejected, or, with --proxy-writes, sent to the primary, after which the replica syncs before responding so that clients read their own writes. To fail over when the primary is lost, promote the replica to a primary with the admin API:
+
+  POST /admin/promote/
+
+With --webhooks, whenever an update moves the head of a dataset, the server POSTs a JSON object with the dataset, its old and new heads, the meta fields of the new head and a summary of the changes to each URL in the config file. Requests are retried with backoff if they fail, and signed with the secret, if any, in an X-Noms-Signature header of "sha256=" and the hex HMAC-SHA256 of the body. For example:
+
+  [[webhook]]
+  url = "https://ci.example.com/noms"
+  secret = "s3cret"
+  datasets = "prod/.*"  # regular expression matching dataset IDs; all if unset
+
+With --audit, every update of the root is appended to the audit log dataset, which must be in another database, with the old and new roots, the name of the client, its address and the time. Each entry is a commit whose parent is the entry before, so the hash of the head of the log commits to all of the entries. Record the head, given by noms audit or the admin API, somewhere the server can't change, and verify the log against it later with noms audit to detect changes to the log since.`,
+	Flags: setupServeFlags,
+	Nargs: 0,
 }
 
 func setupServeFlags() *flag.FlagSet {
 	serveFlagSet := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveFlagSet.IntVar(&port, "port", 8000, "port to listen on for HTTP requests")
+	serveFlagSet.StringVar(&graphqlDatasets, "graphql-datasets", "", "regular expression matching the datasets that can be queried with GraphQL; values can't be queried by hash if set")
+	serveFlagSet.StringVar(&graphqlToken, "graphql-token", "", "if set, GraphQL requests must include an 'Authorization: Bearer <token>' header to query any data")
+	serveFlagSet.StringVar(&serveCert, "cert", "", "PEM file of the certificate, and any intermediate certificates, to serve HTTPS with")
+	serveFlagSet.StringVar(&serveKey, "key", "", "PEM file of the private key of the certificate given by --cert")
+	serveFlagSet.StringVar(&acmeDomains, "acme-domains", "", "comma-separated host names to serve HTTPS for with certificates from Let's Encrypt")
+	serveFlagSet.StringVar(&acmeCache, "acme-cache", "", "directory to keep certificates from Let's Encrypt in, so that they're reused after restarts")
+	serveFlagSet.StringVar(&acmeEmail, "acme-email", "", "contact email address for the Let's Encrypt account")
+	serveFlagSet.BoolVar(&readOnly, "read-only", false, "reject requests which write to the database")
+	serveFlagSet.BoolVar(&maintenance, "maintenance", false, "reject all requests other than health checks with 503 Service Unavailable")
+	serveFlagSet.DurationVar(&retryAfter, "retry-after", time.Minute, "how long clients are told to wait before retrying with --maintenance")
+	serveFlagSet.StringVar(&authFile, "auth", "", "TOML file of the tokens, users and permissions of clients")
+	serveFlagSet.StringVar(&replicaOf, "replica-of", "", "HTTP database to serve a replica of")
+	serveFlagSet.BoolVar(&proxyWrites, "proxy-writes", false, "send requests to write to a replica to its primary, rather than rejecting them")
+	serveFlagSet.StringVar(&auditLog, "audit", "", "dataset of another database to append an audit log of updates of the root to")
+	serveFlagSet.StringVar(&webhooksFile, "webhooks", "", "TOML file of the URLs to POST to when dataset heads move")
+	serveFlagSet.StringVar(&memoryBudget, "memory-budget", "", "limit on the total size of the value cache, table index caches and pending writes, e.g. 512MB")
 	verbose.RegisterVerboseFlags(serveFlagSet)
 	profile.RegisterProfileFlags(serveFlagSet)
 	return serveFlagSet
 }
 
 func runServe(args []string) int {
+	if memoryBudget != "" {
+		size, err := humanize.ParseBytes(memoryBudget)
+		d.CheckErrorNoUsage(err)
+		sizecache.SetBudget(sizecache.NewBudget(size))
+	}
 	cfg := config.NewResolver()
 	db := ""
 	if len(args) > 0 {
@@ -48,6 +149,62 @@ func runServe(args []string) int {
 	cs, err := cfg.GetChunkStore(db)
 	d.CheckError(err)
 	server := datas.NewRemoteDatabaseServer(cs, port)
+	server.Authorize = graphqlAuthorizer(graphqlDatasets, graphqlToken)
+	server.TLSConfig, err = serveTLSConfig()
+	d.CheckErrorNoUsage(err)
+	server.ReadOnly = readOnly
+	server.Maintenance = maintenance
+	server.RetryAfter = retryAfter
+	if store, ok := cs.(*nbs.NomsBlockStore); ok {
+		pinsDB := datas.NewDatabase(store)
+		server.GC = func(retention time.Duration) (interface{}, error) {
+			if _, err := pinsDB.ExpirePins(); err != nil {
+				return nil, err
+			}
+			return store.GC(reachableChunks(store, store.Root()).Has, nbs.GCOptions{Retention: retention, Concurrency: 4})
+		}
+		server.StoreStats = func() interface{} {
+			return store.Stats()
+		}
+	}
+	if replicaOf != "" {
+		primaryURL, auth, err := cfg.GetRemote(replicaOf)
+		d.CheckErrorNoUsage(err)
+		server.Replica = datas.NewReplica(cs, primaryURL, auth)
+		server.Replica.ProxyWrites = proxyWrites
+		root, err := server.Replica.Sync()
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Synced replica with %s at %s\n", primaryURL, root)
+	} else if proxyWrites {
+		d.CheckErrorNoUsage(errors.New("--proxy-writes requires --replica-of"))
+	}
+	if webhooksFile != "" {
+		server.Webhooks, err = serveWebhooks(webhooksFile)
+		d.CheckErrorNoUsage(err)
+	}
+	if auditLog != "" {
+		auditDB, auditDS, err := cfg.GetDataset(auditLog)
+		d.CheckErrorNoUsage(err)
+		defer auditDB.Close()
+		server.Audit = datas.NewAuditLog(auditDB, auditDS.ID())
+	}
+	if authFile != "" {
+		auth, err := newServeAuth(authFile)
+		d.CheckErrorNoUsage(err)
+		server.Auth = auth
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := auth.reload(); err != nil {
+					fmt.Fprintf(os.Stderr, "Couldn't reload %s, keeping the previous config: %s\n", authFile, err)
+				} else {
+					fmt.Printf("Reloaded %s\n", authFile)
+				}
+			}
+		}()
+	}
 
 	// Shutdown server gracefully so that profile may be written
 	c := make(chan os.Signal, 1)
@@ -64,3 +221,89 @@ func runServe(args []string) int {
 	})
 	return 0
 }
+
+func graphqlAuthorizer(datasets, token string) datas.DatasetAuthorizer {
+	if datasets == "" && token == "" {
+		return nil
+	}
+	var datasetsRe *regexp.Regexp
+	if datasets != "" {
+		var err error
+		datasetsRe, err = regexp.Compile("^(?:" + datasets + ")$")
+		d.CheckErrorNoUsage(err)
+	}
+	return func(req *http.Request, datasetID string) bool {
+		if token != "" && subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			return false
+		}
+		if datasetsRe != nil {
+			return datasetID != "" && datasetsRe.MatchString(datasetID)
+		}
+		return true
+	}
+}
+
+// serveWebhooks returns the webhooks in the TOML config file, see the help of
+// nomsServe for an example.
+func serveWebhooks(file string) ([]datas.Webhook, error) {
+	var config struct {
+		Webhook []struct {
+			URL      string
+			Secret   string
+			Datasets string
+		}
+	}
+	if _, err := toml.DecodeFile(file, &config); err != nil {
+		return nil, err
+	}
+	hooks := []datas.Webhook{}
+	for _, w := range config.Webhook {
+		u, err := url.Parse(w.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("webhook URL %s must be http or https", w.URL)
+		}
+		hook := datas.Webhook{URL: w.URL, Secret: w.Secret}
+		if w.Datasets != "" {
+			hook.Datasets, err = regexp.Compile("^(?:" + w.Datasets + ")$")
+			if err != nil {
+				return nil, err
+			}
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// serveTLSConfig returns the TLS config given by the --cert, --key and
+// --acme-* flags, or nil if none of them are set.
+func serveTLSConfig() (*tls.Config, error) {
+	switch {
+	case acmeDomains != "":
+		if serveCert != "" || serveKey != "" {
+			return nil, errors.New("--acme-domains can't be used with --cert or --key")
+		}
+		if acmeCache == "" {
+			return nil, errors.New("--acme-domains requires --acme-cache, so as not to exceed Let's Encrypt's rate limits by getting new certificates whenever the server starts")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(acmeDomains, ",")...),
+			Cache:      autocert.DirCache(acmeCache),
+			Email:      acmeEmail,
+		}
+		return &tls.Config{GetCertificate: m.GetCertificate}, nil
+	case serveCert != "" || serveKey != "":
+		if serveCert == "" || serveKey == "" {
+			return nil, errors.New("--cert and --key must be used together")
+		}
+		cert, err := tls.LoadX509KeyPair(serveCert, serveKey)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+	return nil, nil
+}
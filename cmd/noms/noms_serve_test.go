@@ -0,0 +1,43 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestServeWebhooks(t *testing.T) {
+	assert := assert.New(t)
+	file := writeServeAuthConfig(assert, `
+[[webhook]]
+url = "https://ci.example.com/noms"
+secret = "s3cret"
+datasets = "prod/.*"
+
+[[webhook]]
+url = "http://localhost:8080/"
+`)
+	defer os.Remove(file)
+
+	hooks, err := serveWebhooks(file)
+	assert.NoError(err)
+	assert.Len(hooks, 2)
+	assert.Equal("https://ci.example.com/noms", hooks[0].URL)
+	assert.Equal("s3cret", hooks[0].Secret)
+	assert.True(hooks[0].Datasets.MatchString("prod/users"))
+	assert.False(hooks[0].Datasets.MatchString("dev/prod/users"))
+	assert.Nil(hooks[1].Datasets)
+
+	bad := writeServeAuthConfig(assert, `
+[[webhook]]
+url = "ftp://example.com/"
+`)
+	defer os.Remove(bad)
+	_, err = serveWebhooks(bad)
+	assert.Error(err)
+}
@@ -0,0 +1,462 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var nomsShell = &util.Command{
+	Run:       runShell,
+	UsageLine: "shell [<database>]",
+	Short:     "Explores a database interactively",
+	Long: `Starts a shell for navigating the datasets and values of <database>, or the default database in .nomsconfig, as if they were directories. Type "help" in the shell for its commands.
+
+Paths in the shell are dataset IDs followed by Noms paths, e.g. "people.value.address", or are relative to the current value. Values and datasets can be completed with the Tab key, and "pwd" prints the spec of the current value for use with other noms commands.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument and paths.`,
+	Flags: setupShellFlags,
+	Nargs: 0,
+}
+
+func setupShellFlags() *flag.FlagSet {
+	shellFlagSet := flag.NewFlagSet("shell", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(shellFlagSet)
+	return shellFlagSet
+}
+
+func runShell(args []string) int {
+	dbSpec := ""
+	if len(args) > 0 {
+		dbSpec = args[0]
+	}
+	sh := &shell{cfg: config.NewResolver(), out: os.Stdout}
+	d.CheckErrorNoUsage(sh.open(dbSpec))
+	defer sh.db.Close()
+
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		sh.runLines(os.Stdin)
+		return 0
+	}
+
+	state, err := terminal.MakeRaw(fd)
+	d.CheckErrorNoUsage(err)
+	defer terminal.Restore(fd, state)
+	term := terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, candidates := sh.complete(line, pos)
+		if len(candidates) > 1 && newLine == line {
+			fmt.Fprintln(term, strings.Join(candidates, "  "))
+		}
+		return newLine, newPos, true
+	}
+	sh.out = term
+	for {
+		term.SetPrompt(sh.prompt())
+		line, err := term.ReadLine()
+		if err == io.EOF || err == nil && sh.exec(line) {
+			return 0
+		}
+		d.CheckErrorNoUsage(err)
+	}
+}
+
+// shell is the state of a noms shell: the database it's exploring and the
+// value it's at, which is the root of the database if dataset is empty, and
+// otherwise path in the head of dataset.
+type shell struct {
+	cfg     *config.Resolver
+	dbSpec  string
+	db      datas.Database
+	dataset string
+	path    types.Path
+	out     io.Writer
+}
+
+var shellCommands = []struct {
+	name, args, help string
+}{
+	{"cd", "[<path>]", "go to <path>, or to the root of the database"},
+	{"exit", "", "leave the shell"},
+	{"help", "", "show this help"},
+	{"ls", "[<path>]", "list the datasets at the root, or the fields or elements of a value"},
+	{"open", "<database>", "explore another database, or reload this one to see new commits"},
+	{"pwd", "", "print the spec of the current value"},
+	{"show", "[<path>]", "show a value"},
+	{"type", "[<path>]", "show the type of a value"},
+}
+
+// maxShellListing is the most fields or elements ls lists.
+const maxShellListing = 100
+
+// runLines executes the commands read from r, one per line, until one of
+// them is exit.
+func (sh *shell) runLines(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if sh.exec(scanner.Text()) {
+			return
+		}
+	}
+}
+
+func (sh *shell) open(dbSpec string) error {
+	db, err := sh.cfg.GetDatabase(dbSpec)
+	if err != nil {
+		return err
+	}
+	if sh.db != nil {
+		sh.db.Close()
+	}
+	sh.dbSpec, sh.db, sh.dataset, sh.path = sh.cfg.ResolveDbSpec(dbSpec), db, "", nil
+	return nil
+}
+
+func (sh *shell) prompt() string {
+	if sh.dataset == "" {
+		return "/> "
+	}
+	return "/" + sh.dataset + sh.path.String() + "> "
+}
+
+// exec executes the command on line and returns whether it was exit.
+func (sh *shell) exec(line string) (exit bool) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return false
+	}
+	cmd := words[0]
+	arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), cmd))
+
+	var err error
+	switch cmd {
+	case "cd":
+		err = sh.cd(arg)
+	case "exit", "quit":
+		return true
+	case "help":
+		sh.help()
+	case "ls":
+		err = sh.ls(arg)
+	case "open":
+		if arg == "" {
+			arg = sh.dbSpec
+		}
+		err = sh.open(arg)
+	case "pwd":
+		fmt.Fprintln(sh.out, sh.spec(sh.dataset, sh.path))
+	case "show":
+		err = sh.show(arg)
+	case "type":
+		err = sh.showType(arg)
+	default:
+		err = fmt.Errorf("unknown command %s, try help", cmd)
+	}
+	if err != nil {
+		fmt.Fprintf(sh.out, "error: %s\n", err)
+	}
+	return false
+}
+
+func (sh *shell) help() {
+	tw := tabwriter.NewWriter(sh.out, 0, 8, 2, ' ', 0)
+	for _, c := range shellCommands {
+		fmt.Fprintf(tw, "%s %s\t%s\n", c.name, c.args, c.help)
+	}
+	tw.Flush()
+}
+
+// spec returns the spec of the value at path in dataset, or of the database
+// if dataset is empty.
+func (sh *shell) spec(dataset string, path types.Path) string {
+	if dataset == "" {
+		return sh.dbSpec
+	}
+	return sh.dbSpec + spec.Separator + dataset + path.String()
+}
+
+// target returns the dataset and path which arg refers to. Absolute paths
+// start with "/" and ".." goes up a level.
+func (sh *shell) target(arg string) (dataset string, path types.Path, err error) {
+	dataset, path = sh.dataset, sh.path
+	if strings.HasPrefix(arg, "/") {
+		dataset, path, arg = "", nil, arg[1:]
+	}
+	for arg == ".." || strings.HasPrefix(arg, "../") {
+		if len(path) > 0 {
+			path = path[:len(path)-1]
+		} else {
+			dataset = ""
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, ".."), "/")
+	}
+	if arg == "" {
+		return
+	}
+	if dataset == "" {
+		i := strings.IndexAny(arg, ".[@")
+		if i < 0 {
+			i = len(arg)
+		}
+		dataset, arg = arg[:i], arg[i:]
+		if !datas.DatasetFullRe.MatchString(dataset) {
+			return "", nil, fmt.Errorf("invalid dataset ID %q", dataset)
+		}
+		if arg == "" {
+			return
+		}
+	}
+	if !strings.ContainsAny(arg[:1], ".[@") {
+		arg = "." + arg
+	}
+	rel, err := types.ParsePath(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	return dataset, append(append(types.Path{}, path...), rel...), nil
+}
+
+// resolve returns the value at path in the head of dataset.
+func (sh *shell) resolve(dataset string, path types.Path) (types.Value, error) {
+	head, ok := sh.db.GetDataset(dataset).MaybeHead()
+	if !ok {
+		return nil, fmt.Errorf("dataset %s not found", dataset)
+	}
+	v := path.Resolve(head)
+	if v == nil {
+		return nil, fmt.Errorf("%s not found", dataset+path.String())
+	}
+	return v, nil
+}
+
+// value returns the dataset, path and value which arg refers to. The value
+// is nil at the root of the database.
+func (sh *shell) value(arg string) (dataset string, path types.Path, v types.Value, err error) {
+	dataset, path, err = sh.target(arg)
+	if err != nil || dataset == "" {
+		return
+	}
+	v, err = sh.resolve(dataset, path)
+	return
+}
+
+func (sh *shell) cd(arg string) error {
+	if arg == "" {
+		arg = "/"
+	}
+	dataset, path, _, err := sh.value(arg)
+	if err != nil {
+		return err
+	}
+	sh.dataset, sh.path = dataset, path
+	return nil
+}
+
+func (sh *shell) ls(arg string) error {
+	_, _, v, err := sh.value(arg)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		sh.db.Datasets().IterAll(func(k, _ types.Value) {
+			fmt.Fprintln(sh.out, string(k.(types.String)))
+		})
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(sh.out, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+	n := 0
+	more := func() bool {
+		n++
+		return n > maxShellListing
+	}
+	switch v := v.(type) {
+	case types.Struct:
+		v.IterFields(func(name string, fv types.Value) {
+			fmt.Fprintf(tw, "%s\t%s\n", name, shortValue(fv))
+		})
+	case types.List:
+		v.Iter(func(ev types.Value, i uint64) bool {
+			if more() {
+				return true
+			}
+			fmt.Fprintf(tw, "[%d]\t%s\n", i, shortValue(ev))
+			return false
+		})
+	case types.Map:
+		v.Iter(func(k, ev types.Value) bool {
+			if more() {
+				return true
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", shellIndex(k), shortValue(ev))
+			return false
+		})
+	case types.Set:
+		v.Iter(func(ev types.Value) bool {
+			if more() {
+				return true
+			}
+			fmt.Fprintf(tw, "%s\n", shortValue(ev))
+			return false
+		})
+	default:
+		fmt.Fprintln(tw, shortValue(v))
+	}
+	if l, ok := v.(types.Collection); ok && l.Len() > maxShellListing {
+		fmt.Fprintf(tw, "... %d more\n", l.Len()-maxShellListing)
+	}
+	return nil
+}
+
+func (sh *shell) show(arg string) error {
+	_, _, v, err := sh.value(arg)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		v = sh.db.Datasets()
+	}
+	types.WriteEncodedValue(sh.out, v)
+	fmt.Fprintln(sh.out)
+	return nil
+}
+
+func (sh *shell) showType(arg string) error {
+	_, _, v, err := sh.value(arg)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errors.New("the root of the database isn't a value")
+	}
+	fmt.Fprintln(sh.out, types.TypeOf(v).Describe())
+	return nil
+}
+
+// shellIndex returns the path index of a Map key.
+func shellIndex(k types.Value) string {
+	if types.ValueCanBePathIndex(k) {
+		return types.NewIndexPath(k).String()
+	}
+	return types.NewHashIndexPath(k.Hash()).String()
+}
+
+// shortValue describes v in a line: primitives as they are and other values
+// by their kind and size.
+func shortValue(v types.Value) string {
+	const maxLen = 60
+	switch v := v.(type) {
+	case types.Bool, types.Number, types.String:
+		s := types.EncodedValue(v)
+		if len(s) > maxLen {
+			s = s[:maxLen-3] + "..."
+		}
+		return s
+	case types.Struct:
+		return strings.TrimSpace("struct " + v.Name())
+	case types.Blob:
+		return fmt.Sprintf("Blob (%d bytes)", v.Len())
+	case types.Collection:
+		return fmt.Sprintf("%s (%d)", types.KindToString[v.Kind()], v.Len())
+	case types.Ref:
+		return "Ref #" + v.TargetHash().String()
+	}
+	return types.TypeOf(v).Describe()
+}
+
+// complete completes the word before pos in line. If there's one candidate,
+// the word is replaced by it, and if there are more, by their common prefix.
+func (sh *shell) complete(line string, pos int) (newLine string, newPos int, candidates []string) {
+	start := strings.LastIndexAny(line[:pos], " \t") + 1
+	word := line[start:pos]
+	if strings.TrimSpace(line[:start]) == "" {
+		for _, c := range shellCommands {
+			if strings.HasPrefix(c.name, word) {
+				candidates = append(candidates, c.name)
+			}
+		}
+	} else {
+		candidates = sh.completions(word)
+	}
+	if len(candidates) == 0 {
+		return line, pos, nil
+	}
+	completion := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), candidates
+}
+
+// completions returns the dataset IDs and struct fields word could be the
+// start of.
+func (sh *shell) completions(word string) []string {
+	prefix, rest := "", word
+	if strings.HasPrefix(rest, "/") {
+		prefix, rest = "/", rest[1:]
+	}
+	for strings.HasPrefix(rest, "../") {
+		prefix, rest = prefix+"../", rest[3:]
+	}
+
+	candidates := []string{}
+	dataset, _, err := sh.target(prefix)
+	if err != nil {
+		return nil
+	}
+	if dataset == "" && !strings.ContainsAny(rest, ".[@") {
+		sh.db.Datasets().IterAll(func(k, _ types.Value) {
+			if id := string(k.(types.String)); strings.HasPrefix(id, rest) {
+				candidates = append(candidates, prefix+id)
+			}
+		})
+		return candidates
+	}
+
+	dir, partial := "", rest
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		dir, partial = rest[:i], rest[i+1:]
+	}
+	if strings.ContainsAny(partial, "[]@") {
+		return nil
+	}
+	_, _, v, err := sh.value(prefix + dir)
+	s, ok := v.(types.Struct)
+	if err != nil || !ok {
+		return nil
+	}
+	s.IterFields(func(name string, _ types.Value) {
+		if strings.HasPrefix(name, partial) {
+			candidates = append(candidates, word[:len(word)-len(partial)]+name)
+		}
+	})
+	return candidates
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsShell(t *testing.T) {
+	suite.Run(t, &nomsShellTestSuite{})
+}
+
+type nomsShellTestSuite struct {
+	clienttest.ClientTestSuite
+	sh  *shell
+	out *bytes.Buffer
+}
+
+func (s *nomsShellTestSuite) SetupTest() {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	person := func(name string, age float64) types.Value {
+		return types.NewStruct("Person", types.StructData{
+			"name":    types.String(name),
+			"age":     types.Number(age),
+			"address": types.NewStruct("", types.StructData{"city": types.String("Oakland")}),
+		})
+	}
+	_, err := db.CommitValue(db.GetDataset("people"), types.NewList(person("Ann", 42), person("Bob", 7)))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("places"), types.NewMap(types.String("home"), types.Number(1), types.Number(2), types.Bool(true)))
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	s.out = &bytes.Buffer{}
+	s.sh = &shell{cfg: config.NewResolver(), out: s.out}
+	s.NoError(s.sh.open(spec.CreateDatabaseSpecString("nbs", s.DBDir)))
+}
+
+func (s *nomsShellTestSuite) TearDownTest() {
+	s.sh.db.Close()
+}
+
+// run executes lines in the shell and returns what they wrote.
+func (s *nomsShellTestSuite) run(lines ...string) string {
+	s.out.Reset()
+	s.sh.runLines(strings.NewReader(strings.Join(lines, "\n")))
+	return s.out.String()
+}
+
+func (s *nomsShellTestSuite) TestNavigate() {
+	s.Equal("people\nplaces\n", s.run("ls"))
+	s.Equal("/> ", s.sh.prompt())
+
+	s.Equal("", s.run("cd people.value"))
+	s.Equal("/people.value> ", s.sh.prompt())
+	s.Equal("[0]  struct Person\n[1]  struct Person\n", s.run("ls"))
+	s.Equal("address  struct\nage      7\nname     \"Bob\"\n", s.run("cd [1]", "ls"))
+	s.Equal(`"Oakland"`+"\n", s.run("show address.city"))
+	s.Equal(`"Ann"`+"\n", s.run("show ../[0].name"))
+	s.Equal(spec.CreateValueSpecString("nbs", s.DBDir, "people.value[1]")+"\n", s.run("pwd"))
+
+	s.Equal("", s.run("cd .."))
+	s.Equal("/people.value> ", s.sh.prompt())
+	s.Equal("Number\n", s.run("type /places.value[\"home\"]"))
+	s.Equal("[2]       true\n[\"home\"]  1\n", s.run("ls /places.value"))
+
+	s.Equal("", s.run("cd"))
+	s.Equal("/> ", s.sh.prompt())
+	s.Equal("", s.run("cd people", "cd value"))
+	s.Equal("/people.value> ", s.sh.prompt())
+}
+
+func (s *nomsShellTestSuite) TestErrors() {
+	s.Equal("error: unknown command nope, try help\n", s.run("nope"))
+	s.Equal("error: dataset nope not found\n", s.run("cd nope"))
+	s.Equal("error: people.value.nope not found\n", s.run("cd people.value.nope"))
+	s.Equal("error: the root of the database isn't a value\n", s.run("type"))
+	s.Equal("/> ", s.sh.prompt())
+	s.Contains(s.run("help"), "pwd ")
+}
+
+func (s *nomsShellTestSuite) TestComplete() {
+	complete := func(line string) (string, []string) {
+		newLine, newPos, candidates := s.sh.complete(line, len(line))
+		s.Equal(len(newLine), newPos)
+		return newLine, candidates
+	}
+
+	line, candidates := complete("s")
+	s.Equal("show", line)
+	s.Equal([]string{"show"}, candidates)
+
+	line, candidates = complete("cd p")
+	s.Equal("cd p", line)
+	s.Equal([]string{"people", "places"}, candidates)
+
+	line, _ = complete("cd peo")
+	s.Equal("cd people", line)
+	line, _ = complete("cd people.v")
+	s.Equal("cd people.value", line)
+	line, _ = complete("ls /people.value[0].a")
+	s.Equal("ls /people.value[0].a", line)
+	line, candidates = complete("ls people.value[0].a")
+	s.Equal([]string{"people.value[0].address", "people.value[0].age"}, candidates)
+	s.Equal("ls people.value[0].a", line)
+
+	s.run("cd people.value[1]")
+	line, _ = complete("show n")
+	s.Equal("show name", line)
+	line, _ = complete("show address.c")
+	s.Equal("show address.city", line)
+	line, _ = complete("show ../../m")
+	s.Equal("show ../../meta", line)
+	line, _ = complete("show /pl")
+	s.Equal("show /places", line)
+	line, candidates = complete("show zzz")
+	s.Equal("show zzz", line)
+	s.Nil(candidates)
+}
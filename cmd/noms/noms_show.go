@@ -23,9 +23,11 @@ var nomsShow = &util.Command{
 	Run:       runShow,
 	UsageLine: "show [flags] <object>",
 	Short:     "Shows a serialization of a Noms object",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.",
-	Flags:     setupShowFlags,
-	Nargs:     1,
+	Long: `See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.
+
+With --format=json or yaml, the object is written as JSON or YAML. Structs and Maps with String keys become objects, other Maps lists of key-value pairs, Blobs base64 and Refs their hashes. Templates are executed with the fields hash, type and value, e.g. --template '{{.hash}} {{.type}}', and {{path ".name"}} reads a path in the object.`,
+	Flags: setupShowFlags,
+	Nargs: 1,
 }
 
 var showRaw = false
@@ -35,6 +37,7 @@ func setupShowFlags() *flag.FlagSet {
 	outputpager.RegisterOutputpagerFlags(showFlagSet)
 	verbose.RegisterVerboseFlags(showFlagSet)
 	showFlagSet.BoolVar(&showRaw, "raw", false, "If true, dumps the raw binary version of the data")
+	registerOutputFormatFlags(showFlagSet)
 	return showFlagSet
 }
 
@@ -57,9 +60,22 @@ func runShow(args []string) int {
 		return 0
 	}
 
+	rw, err := newRecordWriter(false)
+	d.CheckErrorNoUsage(err)
+
 	pgr := outputpager.Start()
 	defer pgr.Stop()
 
+	if rw != nil {
+		data := map[string]interface{}{
+			"hash":  value.Hash().String(),
+			"type":  types.TypeOf(value).Describe(),
+			"value": jsonValue(value),
+		}
+		d.CheckErrorNoUsage(rw.write(pgr.Writer, data["value"], data, value))
+		return 0
+	}
+
 	types.WriteEncodedValue(pgr.Writer, value)
 	fmt.Fprintln(pgr.Writer)
 	return 0
@@ -120,3 +120,37 @@ func (s *nomsShowTestSuite) TestNomsShowRaw() {
 	s.True(numChildChunks > 0)
 	test(l)
 }
+
+func (s *nomsShowTestSuite) TestNomsShowFormat() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "formatTest"))
+	s.NoError(err)
+	defer sp.Close()
+	v := types.NewStruct("Person", types.StructData{
+		"name": types.String("Ann"),
+		"tags": types.NewList(types.String("a"), types.Number(2)),
+		"ids":  types.NewMap(types.Number(1), types.Bool(true)),
+	})
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), v)
+	s.NoError(err)
+	str := spec.CreateValueSpecString("nbs", s.DBDir, "formatTest.value")
+
+	res, _ := s.MustRun(main, []string{"show", "--format=json", str})
+	s.Equal(`{"ids":[[1,true]],"name":"Ann","tags":["a",2]}`+"\n", res)
+
+	res, _ = s.MustRun(main, []string{"show", "--format=yaml", str})
+	s.Equal(`ids:
+  - - 1
+    - true
+name: "Ann"
+tags:
+  - "a"
+  - 2
+`, res)
+
+	res, _ = s.MustRun(main, []string{"show", "--format=template", "--template", `{{.type}} {{.hash}} {{.value.name}} {{path ".tags[1]"}} {{json .value.tags}}`, str})
+	s.Equal(types.TypeOf(v).Describe()+" "+v.Hash().String()+` Ann 2 ["a",2]`+"\n", res)
+
+	_, stderr, recovered := s.Run(main, []string{"show", "--format=xml", str})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "unknown format xml")
+}
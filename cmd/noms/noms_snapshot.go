@@ -0,0 +1,107 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	snapshotCreate  string
+	snapshotRestore string
+)
+
+var nomsSnapshot = &util.Command{
+	Run:       runSnapshot,
+	UsageLine: "snapshot [<database> | -c <snapshot> | --restore <snapshot>]",
+	Short:     "Lists, creates or restores snapshots of all datasets",
+	Long: `A snapshot records the heads of all datasets of a database at once, so that they can all be moved back to them later, e.g. before a migration which updates many datasets. Snapshots are spelled like datasets, e.g. "db::before-migration", and are stored in the dataset "snapshots/<label>".
+
+With no flags, lists the snapshots of <database>, when they were taken, and how many datasets they have. Restoring a snapshot moves the heads of the datasets back to those of the snapshot, and deletes the datasets created since, other than snapshots.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and snapshot arguments.`,
+	Flags: setupSnapshotFlags,
+	Nargs: 0,
+}
+
+func setupSnapshotFlags() *flag.FlagSet {
+	snapshotFlagSet := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	snapshotFlagSet.StringVar(&snapshotCreate, "c", "", "snapshot to create")
+	snapshotFlagSet.StringVar(&snapshotRestore, "restore", "", "snapshot to restore")
+	verbose.RegisterVerboseFlags(snapshotFlagSet)
+	return snapshotFlagSet
+}
+
+func runSnapshot(args []string) int {
+	cfg := config.NewResolver()
+	switch {
+	case snapshotCreate != "" && snapshotRestore != "":
+		d.CheckErrorNoUsage(errors.New("expected only one of -c and --restore"))
+
+	case snapshotCreate != "":
+		db, label := getSnapshotLabel(cfg, snapshotCreate)
+		defer db.Close()
+
+		s, err := db.Snapshot(label)
+		if err == datas.ErrDatasetExists {
+			err = fmt.Errorf("Snapshot %s already exists", label)
+		}
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Created snapshot %s of %d datasets\n", label, s.Datasets.Len())
+
+	case snapshotRestore != "":
+		db, label := getSnapshotLabel(cfg, snapshotRestore)
+		defer db.Close()
+
+		s, ok := datas.GetSnapshot(db, label)
+		if !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Snapshot %s not found", label))
+		}
+		d.CheckErrorNoUsage(db.Restore(s))
+		fmt.Printf("Restored snapshot %s of %d datasets\n", label, s.Datasets.Len())
+
+	case len(args) <= 1:
+		dbSpec := ""
+		if len(args) == 1 {
+			dbSpec = args[0]
+		}
+		db, err := cfg.GetDatabase(dbSpec)
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, s := range datas.Snapshots(db) {
+			date := ""
+			if !s.Date.IsZero() {
+				date = s.Date.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d datasets\n", s.Label, date, s.Datasets.Len())
+		}
+		tw.Flush()
+
+	default:
+		d.CheckErrorNoUsage(errors.New("expected a database, or -c or --restore with a snapshot"))
+	}
+	return 0
+}
+
+// getSnapshotLabel returns the database of the snapshot spelled by str, and
+// the snapshot's label.
+func getSnapshotLabel(cfg *config.Resolver, str string) (datas.Database, string) {
+	db, ds, err := cfg.GetDataset(str)
+	d.CheckErrorNoUsage(err)
+	return db, ds.ID()
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsSnapshot(t *testing.T) {
+	suite.Run(t, &nomsSnapshotTestSuite{})
+}
+
+type nomsSnapshotTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsSnapshotTestSuite) TestSnapshot() {
+	first, second := setupBranches(&s.ClientTestSuite)
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	name := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+
+	out, _ := s.MustRun(main, []string{"snapshot", dbSpec})
+	s.Equal("", out)
+
+	out, _ = s.MustRun(main, []string{"snapshot", "-c", name("before")})
+	s.Equal("Created snapshot before of 2 datasets\n", out)
+	out, _ = s.MustRun(main, []string{"snapshot", dbSpec})
+	s.Contains(out, "before")
+	s.Contains(out, "2 datasets\n")
+
+	_, stderr, recovered := s.Run(main, []string{"snapshot", "-c", name("before")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Snapshot before already exists")
+
+	_, _ = s.MustRun(main, []string{"branch", "-d", name("master")})
+	_, _ = s.MustRun(main, []string{"branch", name("feature"), name("tags/v1")})
+
+	out, _ = s.MustRun(main, []string{"snapshot", "--restore", name("before")})
+	s.Equal("Restored snapshot before of 2 datasets\n", out)
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	s.Equal(second, db.GetDataset("master").HeadRef().TargetHash().String())
+	s.Equal(first, db.GetDataset(tagPrefix+"v1").HeadRef().TargetHash().String())
+	s.False(db.GetDataset("feature").HasHead())
+	s.True(db.GetDataset(datas.SnapshotPrefix + "before").HeadValue().(types.Map).Has(types.String("master")))
+
+	_, stderr, recovered = s.Run(main, []string{"snapshot", "--restore", name("after")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Snapshot after not found")
+}
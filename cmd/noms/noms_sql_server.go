@@ -0,0 +1,70 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/pgwire"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var sqlServerPort int
+
+var nomsSQLServer = &util.Command{
+	Run:       runSQLServer,
+	UsageLine: "sql-server [options] <database>",
+	Short:     "Serves the datasets of a database to PostgreSQL clients",
+	Long: `Serves the datasets of <database> over the PostgreSQL wire protocol, so that psql, SQL drivers and BI tools can run SELECT queries against them, e.g.
+
+  psql -h localhost -p 5432 -c "SELECT name, age FROM people WHERE age >= 18"
+
+Each dataset whose head is a List, Set or Map is a table, queried as with noms query, and the tables are listed in information_schema.tables and information_schema.columns. Only the simple query protocol is supported, and clients aren't authenticated, so don't expose the server to untrusted networks.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupSQLServerFlags,
+	Nargs: 1,
+}
+
+func setupSQLServerFlags() *flag.FlagSet {
+	sqlServerFlagSet := flag.NewFlagSet("sql-server", flag.ExitOnError)
+	sqlServerFlagSet.IntVar(&sqlServerPort, "port", 5432, "port to listen on for PostgreSQL clients")
+	verbose.RegisterVerboseFlags(sqlServerFlagSet)
+	return sqlServerFlagSet
+}
+
+func runSQLServer(args []string) int {
+	cfg := config.NewResolver()
+	open := func() (datas.Database, error) {
+		return cfg.GetDatabase(args[0])
+	}
+	db, err := open()
+	d.CheckError(err)
+	db.Close()
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", sqlServerPort))
+	d.CheckErrorNoUsage(err)
+	server := pgwire.NewServer(open)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		server.Stop()
+	}()
+
+	fmt.Printf("Listening on port %d...\n", sqlServerPort)
+	d.CheckErrorNoUsage(server.Serve(l))
+	return 0
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/profile"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsStats = &util.Command{
+	Run:       runStats,
+	UsageLine: "stats <database>",
+	Short:     "Shows how much storage a database and each of its datasets use",
+	Long:      "Chunks which are reachable from the heads of several datasets are shared, those reachable from a single dataset are unique to it. The height of a dataset is the height of the graph of chunks reachable from its head commit, the value height that of its head value.\n\nRemote databases are not supported. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupStatsFlags,
+	Nargs:     1,
+}
+
+func setupStatsFlags() *flag.FlagSet {
+	statsFlagSet := flag.NewFlagSet("stats", flag.ExitOnError)
+	verbose.RegisterVerboseFlags(statsFlagSet)
+	profile.RegisterProfileFlags(statsFlagSet)
+	return statsFlagSet
+}
+
+type chunkTally struct {
+	chunks, bytes uint64
+}
+
+func (t *chunkTally) add(size int) {
+	t.chunks++
+	t.bytes += uint64(size)
+}
+
+func (t chunkTally) String() string {
+	return fmt.Sprintf("%d chunks (%s)", t.chunks, humanize.Bytes(t.bytes))
+}
+
+type datasetStats struct {
+	id                  string
+	reachable, unique   chunkTally
+	height, valueHeight uint64
+	seen                hash.HashSet
+}
+
+func runStats(args []string) int {
+	cfg := config.NewResolver()
+	cs, err := cfg.GetChunkStore(args[0])
+	d.CheckErrorNoUsage(err)
+	if cs == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("%s is a remote database", args[0]))
+	}
+	db := datas.NewDatabase(cs)
+	defer db.Close()
+
+	defer profile.MaybeStartProfile().Stop()
+	printStats(os.Stdout, cs, db)
+	return 0
+}
+
+func printStats(w io.Writer, cs chunks.ChunkStore, db datas.Database) {
+	sizes := map[hash.Hash]int{}
+	owners := map[hash.Hash]int{}
+	dss := []*datasetStats{}
+	db.Datasets().IterAll(func(k, v types.Value) {
+		ds := db.GetDataset(string(k.(types.String)))
+		s := &datasetStats{
+			id:          ds.ID(),
+			height:      ds.HeadRef().Height(),
+			valueHeight: types.NewRef(ds.HeadValue()).Height(),
+			seen:        hash.HashSet{},
+		}
+		walkChunks(cs, v.(types.Ref).TargetHash(), s.seen, func(c chunks.Chunk) {
+			sizes[c.Hash()] = len(c.Data())
+			owners[c.Hash()]++
+			s.reachable.add(len(c.Data()))
+		})
+		dss = append(dss, s)
+	})
+
+	shared := chunkTally{}
+	for h, n := range owners {
+		if n > 1 {
+			shared.add(sizes[h])
+		}
+	}
+	for _, s := range dss {
+		for h := range s.seen {
+			if owners[h] == 1 {
+				s.unique.add(sizes[h])
+			}
+		}
+	}
+
+	// The root also references the chunks of the map of datasets.
+	reachable := chunkTally{}
+	walkChunks(cs, cs.Root(), hash.HashSet{}, func(c chunks.Chunk) {
+		reachable.add(len(c.Data()))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if store, ok := cs.(*nbs.NomsBlockStore); ok {
+		st := store.Stats()
+		fmt.Fprintf(tw, "Stored:\t%d chunks (%s, %s compressed) in %d tables\n", st.Chunks, humanize.Bytes(st.RawBytes), humanize.Bytes(st.CompressedBytes), st.Tables)
+	}
+	fmt.Fprintf(tw, "Reachable:\t%s\n", reachable)
+	fmt.Fprintf(tw, "Shared:\t%s\n", shared)
+	d.PanicIfError(tw.Flush())
+
+	if len(dss) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "Dataset\tReachable\tUnique\tHeight\tValue height")
+	for _, s := range dss {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\n", s.id, s.reachable, s.unique, s.height, s.valueHeight)
+	}
+	d.PanicIfError(tw.Flush())
+}
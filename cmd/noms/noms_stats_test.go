@@ -0,0 +1,55 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsStats(t *testing.T) {
+	suite.Run(t, &nomsStatsTestSuite{})
+}
+
+type nomsStatsTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsStatsTestSuite) TestEmpty() {
+	out, _ := s.MustRun(main, []string{"stats", spec.CreateDatabaseSpecString("nbs", s.DBDir)})
+	s.Equal("Stored:     0 chunks (0 B, 0 B compressed) in 0 tables\nReachable:  0 chunks (0 B)\nShared:     0 chunks (0 B)\n", out)
+}
+
+func (s *nomsStatsTestSuite) TestStats() {
+	cs := nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize)
+	db := datas.NewDatabase(cs)
+	l := types.NewList()
+	for i := 0; i < 1000; i++ {
+		l = l.Append(types.String("shared between datasets"), types.Number(i))
+	}
+	_, err := db.CommitValue(db.GetDataset("a"), l)
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("b"), l)
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("b"), types.String("only in b"))
+	s.NoError(err)
+	s.NoError(db.Close())
+
+	out, _ := s.MustRun(main, []string{"stats", spec.CreateDatabaseSpecString("nbs", s.DBDir)})
+	s.Equal(`Stored:     14 chunks (29 kB, 5.9 kB compressed) in 3 tables
+Reachable:  12 chunks (29 kB)
+Shared:     10 chunks (29 kB)
+
+Dataset  Reachable          Unique            Height  Value height
+a        10 chunks (29 kB)  0 chunks (0 B)    2       2
+b        11 chunks (29 kB)  1 chunks (109 B)  3       1
+`, out)
+}
@@ -7,12 +7,15 @@ package main
 import (
 	"fmt"
 	"log"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/profile"
 	"github.com/attic-labs/noms/go/util/status"
@@ -22,21 +25,33 @@ import (
 )
 
 var (
-	p int
+	p             int
+	syncDryRun    bool
+	syncHasFilter bool
 )
 
 var nomsSync = &util.Command{
 	Run:       runSync,
-	UsageLine: "sync [options] <source-object> <dest-dataset>",
+	UsageLine: "sync [options] <source-object>... <dest-dataset-or-database>",
 	Short:     "Moves datasets between or within databases",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
-	Flags:     setupSyncFlags,
-	Nargs:     2,
+	Long: `Makes <source-object>, which must be a commit, the head of the dataset <dest-dataset-or-database>, copying the chunks it reaches which the destination database doesn't have.
+
+With several source datasets, or ones spelled with a pattern like db::prefix/*, which is matched against the names of the datasets of db as by path.Match, each is synced to the dataset of the same name in the database <dest-dataset-or-database>, in a single session, so that chunks shared by several of them are only looked for and copied once.
+
+With --dry-run, nothing is written, and instead the number of chunks which would be copied to the destination database and their size are shown, with what would happen to <dest-dataset>. Each chunk reachable from <source-object> is looked for in the destination, and only the chunks reachable from those it doesn't have are visited.
+
+With --has-filter, a remote destination is asked once for a filter of the chunks reachable from the head of <dest-dataset>, and only asked whether it has the chunks which may be in it, rather than each chunk, which speeds up syncing a lot of new data to a server. Chunks the destination has which only other datasets reach are copied again.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.`,
+	Flags: setupSyncFlags,
+	Nargs: 2,
 }
 
 func setupSyncFlags() *flag.FlagSet {
 	syncFlagSet := flag.NewFlagSet("sync", flag.ExitOnError)
 	syncFlagSet.IntVar(&p, "p", 512, "parallelism")
+	syncFlagSet.BoolVar(&syncDryRun, "dry-run", false, "show how much would be synced without syncing it")
+	syncFlagSet.BoolVar(&syncHasFilter, "has-filter", false, "ask a remote destination for a filter of the chunks it has")
 	verbose.RegisterVerboseFlags(syncFlagSet)
 	profile.RegisterProfileFlags(syncFlagSet)
 	return syncFlagSet
@@ -44,6 +59,10 @@ func setupSyncFlags() *flag.FlagSet {
 
 func runSync(args []string) int {
 	cfg := config.NewResolver()
+	if len(args) > 2 || isDatasetPattern(args[0]) {
+		return runSyncMany(cfg, args[:len(args)-1], args[len(args)-1])
+	}
+
 	sourceStore, sourceObj, err := cfg.GetPath(args[0])
 	d.CheckError(err)
 	defer sourceStore.Close()
@@ -55,7 +74,104 @@ func runSync(args []string) int {
 	sinkDB, sinkDataset, err := cfg.GetDataset(args[1])
 	d.CheckError(err)
 	defer sinkDB.Close()
+	setSyncHasFilter(sinkDB)
+
+	if syncDryRun {
+		estimateSync([]datas.Database{sourceStore}, sinkDB, types.RefSlice{types.NewRef(sourceObj)}, []datas.Dataset{sinkDataset})
+		return 0
+	}
+	syncDataset(sourceStore, sinkDB, types.NewRef(sourceObj), sinkDataset, args[1])
+	return 0
+}
+
+// runSyncMany syncs the datasets of sources, each of which is a dataset or a
+// pattern matching those of a database, to the datasets of the same names in
+// the database dest. All of them are synced to the same Database, which
+// remembers the chunks it has, so chunks shared by several datasets are only
+// probed for and copied once.
+func runSyncMany(cfg *config.Resolver, sources []string, dest string) int {
+	sinkDB, err := cfg.GetDatabase(dest)
+	d.CheckError(err)
+	defer sinkDB.Close()
+	setSyncHasFilter(sinkDB)
+
+	srcDBs := []datas.Database{}
+	sourceRefs := types.RefSlice{}
+	sinkDatasets := []datas.Dataset{}
+	openDBs := map[string]datas.Database{}
+	for _, source := range sources {
+		dbSpec, pattern := source, ""
+		if i := strings.LastIndex(source, spec.Separator); i >= 0 {
+			dbSpec, pattern = source[:i], source[i+len(spec.Separator):]
+		}
+		db, ok := openDBs[dbSpec]
+		if !ok {
+			db, err = cfg.GetDatabase(dbSpec)
+			d.CheckError(err)
+			defer db.Close()
+			openDBs[dbSpec] = db
+		}
+
+		names, err := matchDatasets(db, pattern)
+		d.CheckErrorNoUsage(err)
+		if len(names) == 0 {
+			d.CheckErrorNoUsage(fmt.Errorf("No datasets match %s", source))
+		}
+		for _, name := range names {
+			for _, ds := range sinkDatasets {
+				if ds.ID() == name {
+					d.CheckErrorNoUsage(fmt.Errorf("Dataset %s is given more than once", name))
+				}
+			}
+			srcDBs = append(srcDBs, db)
+			sourceRefs = append(sourceRefs, db.GetDataset(name).HeadRef())
+			sinkDatasets = append(sinkDatasets, sinkDB.GetDataset(name))
+		}
+	}
+
+	if syncDryRun {
+		estimateSync(srcDBs, sinkDB, sourceRefs, sinkDatasets)
+		return 0
+	}
+	for i, ds := range sinkDatasets {
+		syncDataset(srcDBs[i], sinkDB, sourceRefs[i], ds, dest+spec.Separator+ds.ID())
+	}
+	return 0
+}
+
+// setSyncHasFilter makes pulls to sinkDB use a has filter, if --has-filter is
+// set and sinkDB is remote.
+func setSyncHasFilter(sinkDB datas.Database) {
+	if rdb, ok := sinkDB.(*datas.RemoteDatabaseClient); ok && syncHasFilter {
+		rdb.SetHasFilter(true)
+	}
+}
 
+// isDatasetPattern returns whether the dataset of the spec str is a pattern
+// as taken by path.Match.
+func isDatasetPattern(str string) bool {
+	i := strings.LastIndex(str, spec.Separator)
+	return i >= 0 && strings.ContainsAny(str[i+len(spec.Separator):], "*?[")
+}
+
+// matchDatasets returns the names of the datasets of db which match pattern,
+// or pattern itself if it isn't a pattern and db has a dataset of that name.
+func matchDatasets(db datas.Database, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("Invalid dataset pattern %s: %s", pattern, err)
+	}
+	names := []string{}
+	db.Datasets().IterAll(func(k, v types.Value) {
+		if ok, _ := path.Match(pattern, string(k.(types.String))); ok {
+			names = append(names, string(k.(types.String)))
+		}
+	})
+	return names, nil
+}
+
+// syncDataset pulls sourceRef from sourceStore to sinkDB and makes it the head
+// of sinkDataset, which is spelled dsSpec, printing the progress and outcome.
+func syncDataset(sourceStore, sinkDB datas.Database, sourceRef types.Ref, sinkDataset datas.Dataset, dsSpec string) {
 	start := time.Now()
 	progressCh := make(chan datas.PullProgress)
 	lastProgressCh := make(chan datas.PullProgress)
@@ -79,10 +195,9 @@ func runSync(args []string) int {
 		lastProgressCh <- last
 	}()
 
-	sourceRef := types.NewRef(sourceObj)
 	sinkRef, sinkExists := sinkDataset.MaybeHeadRef()
 	nonFF := false
-	err = d.Try(func() {
+	err := d.Try(func() {
 		defer profile.MaybeStartProfile().Stop()
 		datas.PullWithFlush(sourceStore, sinkDB, sourceRef, sinkRef, p, progressCh)
 
@@ -105,14 +220,77 @@ func runSync(args []string) int {
 			humanize.Bytes(last.ApproxWrittenBytes), since(start), bytesPerSec(last.ApproxWrittenBytes, start))
 		status.Done()
 	} else if !sinkExists {
-		fmt.Printf("All chunks already exist at destination! Created new dataset %s.\n", args[1])
+		fmt.Printf("All chunks already exist at destination! Created new dataset %s.\n", dsSpec)
 	} else if nonFF && !sourceRef.Equals(sinkRef) {
 		fmt.Printf("Abandoning %s; new head is %s\n", sinkRef.TargetHash(), sourceRef.TargetHash())
 	} else {
-		fmt.Printf("Dataset %s is already up to date.\n", args[1])
+		fmt.Printf("Dataset %s is already up to date.\n", dsSpec)
 	}
+}
 
-	return 0
+// estimateSync prints how much data syncing each of sourceRefs to the same
+// one of sinkDatasets would copy from the same one of srcDBs to sinkDB, and
+// what would become of their heads.
+func estimateSync(srcDBs []datas.Database, sinkDB datas.Database, sourceRefs types.RefSlice, sinkDatasets []datas.Dataset) {
+	progressCh := make(chan datas.PullProgress)
+	doneCh := make(chan struct{})
+	go func() {
+		for info := range progressCh {
+			if status.WillPrint() {
+				status.Printf("Probing - %d of %d chunks", info.DoneCount, info.KnownCount)
+			}
+		}
+		status.Clear()
+		close(doneCh)
+	}()
+	// The refs are estimated together for each source database, so that chunks
+	// several of them share are only counted once.
+	refsByDB := map[datas.Database]types.RefSlice{}
+	for i, srcDB := range srcDBs {
+		refsByDB[srcDB] = append(refsByDB[srcDB], sourceRefs[i])
+	}
+	est := datas.PullEstimate{}
+	for srcDB, refs := range refsByDB {
+		e := datas.EstimatePull(srcDB, sinkDB, refs, p, progressCh)
+		est.Chunks, est.Bytes, est.CompressedBytes, est.Probed = est.Chunks+e.Chunks, est.Bytes+e.Bytes, est.CompressedBytes+e.CompressedBytes, est.Probed+e.Probed
+	}
+	close(progressCh)
+	<-doneCh
+
+	heads := make([]string, len(sinkDatasets))
+	for i, ds := range sinkDatasets {
+		dsName, sourceRef := ds.ID(), sourceRefs[i]
+		sinkRef, sinkExists := ds.MaybeHeadRef()
+		switch {
+		case !sinkExists:
+			heads[i] = fmt.Sprintf("create dataset %s with head #%s", dsName, sourceRef.TargetHash())
+		case sinkRef.Equals(sourceRef):
+			heads[i] = fmt.Sprintf("leave %s as it is", dsName)
+		case isAncestor(sinkRef, sourceRef, srcDBs[i]):
+			heads[i] = fmt.Sprintf("fast-forward %s to #%s", dsName, sourceRef.TargetHash())
+		default:
+			heads[i] = fmt.Sprintf("abandon #%s, the head of %s, for #%s", sinkRef.TargetHash(), dsName, sourceRef.TargetHash())
+		}
+	}
+	summary := fmt.Sprintf("Would sync %d chunks, %s (about %s written), and", est.Chunks, humanize.Bytes(est.Bytes), humanize.Bytes(est.CompressedBytes))
+	if len(heads) == 1 {
+		fmt.Printf("%s %s.\n", summary, heads[0])
+	} else {
+		fmt.Printf("%s:\n", summary)
+		for _, head := range heads {
+			fmt.Printf("  %s\n", head)
+		}
+	}
+	verbose.Log("Probed for %d chunks", est.Probed)
+}
+
+// isAncestor returns whether the commit ancestor is sourceRef or one of its
+// ancestors in vr.
+func isAncestor(ancestor, sourceRef types.Ref, vr types.ValueReader) bool {
+	if !datas.IsRefOfCommitType(types.TypeOf(sourceRef)) || vr.ReadValue(ancestor.TargetHash()) == nil {
+		return false
+	}
+	return datas.IsAncestor(ancestor, sourceRef, vr)
 }
 
 func bytesPerSec(bytes uint64, start time.Time) string {
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
@@ -140,3 +141,89 @@ func (s *nomsSyncTestSuite) TestRewind() {
 	s.True(types.Number(42).Equals(dest.HeadValue()))
 	db.Close()
 }
+
+func (s *nomsSyncTestSuite) TestSyncDryRun() {
+	defer s.NoError(os.RemoveAll(s.DBDir2))
+
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	source1 := sourceDB.GetDataset("src")
+	source1, err := sourceDB.CommitValue(source1, types.Number(42))
+	s.NoError(err)
+	source1HeadRef := source1.Head().Hash()
+	source1, err = sourceDB.CommitValue(source1, types.Number(43))
+	s.NoError(err)
+	source2HeadRef := source1.Head().Hash()
+	sourceDB.Close()
+
+	sourceSpec := spec.CreateValueSpecString("nbs", s.DBDir, "#"+source1HeadRef.String())
+	sourceDataset := spec.CreateValueSpecString("nbs", s.DBDir, "src")
+	sinkDatasetSpec := spec.CreateValueSpecString("nbs", s.DBDir2, "dest")
+
+	sout, _ := s.MustRun(main, []string{"sync", "--dry-run", sourceSpec, sinkDatasetSpec})
+	s.Regexp(`Would sync \d+ chunks, \d+ B \(about \d+ B written\), and create dataset dest with head #`+source1HeadRef.String()+`\.\n$`, sout)
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	_, ok := db.GetDataset("dest").MaybeHead()
+	s.False(ok)
+	db.Close()
+
+	s.MustRun(main, []string{"sync", sourceSpec, sinkDatasetSpec})
+	sout, _ = s.MustRun(main, []string{"sync", "--dry-run", sourceDataset, sinkDatasetSpec})
+	s.Regexp(`Would sync 1 chunks, .*, and fast-forward dest to #`+source2HeadRef.String()+`\.\n$`, sout)
+
+	s.MustRun(main, []string{"sync", sourceDataset, sinkDatasetSpec})
+	sout, _ = s.MustRun(main, []string{"sync", "--dry-run", sourceDataset, sinkDatasetSpec})
+	s.Contains(sout, "Would sync 0 chunks, 0 B (about 0 B written), and leave dest as it is.\n")
+
+	sout, _ = s.MustRun(main, []string{"sync", "--dry-run", sourceSpec, sinkDatasetSpec})
+	s.Contains(sout, "Would sync 0 chunks, 0 B (about 0 B written), and abandon #"+source2HeadRef.String()+", the head of dest, for #"+source1HeadRef.String()+".\n")
+}
+
+func (s *nomsSyncTestSuite) TestSyncMany() {
+	defer s.NoError(os.RemoveAll(s.DBDir2))
+
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	l := types.NewList()
+	for i := 0; i < 10000; i++ {
+		l = l.Append(types.Number(i))
+	}
+	heads := map[string]hash.Hash{}
+	for _, name := range []string{"logs/a", "logs/b", "other"} {
+		meta := types.NewStruct("Meta", types.StructData{"name": types.String(name)})
+		ds, err := sourceDB.Commit(sourceDB.GetDataset(name), l, datas.CommitOptions{Meta: meta})
+		s.NoError(err)
+		heads[name] = ds.HeadRef().TargetHash()
+	}
+	sourceDB.Close()
+
+	sinkSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir2)
+	sourcePattern := spec.CreateDatabaseSpecString("nbs", s.DBDir) + "::logs/*"
+
+	sout, _ := s.MustRun(main, []string{"sync", "--dry-run", sourcePattern, sinkSpec})
+	s.Regexp(`Would sync \d+ chunks, .*, and:\n  create dataset logs/a with head #`+heads["logs/a"].String()+`\n  create dataset logs/b with head #`+heads["logs/b"].String()+`\n$`, sout)
+
+	s.MustRun(main, []string{"sync", sourcePattern, sinkSpec})
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	s.Equal(uint64(2), db.Datasets().Len())
+	s.Equal(heads["logs/a"], db.GetDataset("logs/a").HeadRef().TargetHash())
+	s.Equal(heads["logs/b"], db.GetDataset("logs/b").HeadRef().TargetHash())
+	db.Close()
+
+	// The list is already there, so only the commit of other is missing.
+	sout, _ = s.MustRun(main, []string{"sync", "--dry-run", spec.CreateValueSpecString("nbs", s.DBDir, "other"), spec.CreateValueSpecString("nbs", s.DBDir, "logs/a"), sinkSpec})
+	s.Contains(sout, "Would sync 1 chunks, ")
+	s.Contains(sout, "\n  create dataset other with head #"+heads["other"].String()+"\n  leave logs/a as it is\n")
+
+	sout, _ = s.MustRun(main, []string{"sync", spec.CreateValueSpecString("nbs", s.DBDir, "other"), sourcePattern, sinkSpec})
+	s.Contains(sout, "Done - Synced ")
+	s.Contains(sout, "Dataset "+sinkSpec+"::logs/a is already up to date.\n")
+	db = datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	s.Equal(heads["other"], db.GetDataset("other").HeadRef().TargetHash())
+	db.Close()
+
+	_, stderr, recovered := s.Run(main, []string{"sync", spec.CreateDatabaseSpecString("nbs", s.DBDir) + "::nope/*", sinkSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "No datasets match ")
+	_, stderr, recovered = s.Run(main, []string{"sync", sourcePattern, spec.CreateValueSpecString("nbs", s.DBDir, "logs/a"), sinkSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Dataset logs/a is given more than once")
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+// tagPrefix starts the IDs of the datasets tags are stored in.
+const tagPrefix = "tags/"
+
+var tagDelete string
+
+var nomsTag = &util.Command{
+	Run:       runTag,
+	UsageLine: "tag [<database> | <tag> <commit> | -d <tag>]",
+	Short:     "Lists, creates or deletes tags",
+	Long: `A tag is a name for a commit which, unlike a branch, doesn't move. Tags are spelled like datasets, e.g. "db::v1", and are stored in the dataset "tags/<name>".
+
+With no arguments, lists the tags of <database> and the commits they're at. With two, tags <commit>, which can be any path to a commit in the same database, such as a dataset.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and commit arguments.`,
+	Flags: setupTagFlags,
+	Nargs: 0,
+}
+
+func setupTagFlags() *flag.FlagSet {
+	tagFlagSet := flag.NewFlagSet("tag", flag.ExitOnError)
+	tagFlagSet.StringVar(&tagDelete, "d", "", "tag to delete")
+	verbose.RegisterVerboseFlags(tagFlagSet)
+	return tagFlagSet
+}
+
+func runTag(args []string) int {
+	cfg := config.NewResolver()
+	switch {
+	case tagDelete != "":
+		db, ds, name := getTag(cfg, tagDelete)
+		defer db.Close()
+
+		head, ok := ds.MaybeHeadRef()
+		if !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Tag %s not found", name))
+		}
+		_, err := db.Delete(ds)
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Deleted tag %s (was #%s)\n", name, head.TargetHash().String())
+
+	case len(args) <= 1:
+		dbSpec := ""
+		if len(args) == 1 {
+			dbSpec = args[0]
+		}
+		db, err := cfg.GetDatabase(dbSpec)
+		d.CheckErrorNoUsage(err)
+		defer db.Close()
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		db.Datasets().IterAll(func(k, v types.Value) {
+			if id := string(k.(types.String)); strings.HasPrefix(id, tagPrefix) {
+				fmt.Fprintf(tw, "%s\t#%s\n", strings.TrimPrefix(id, tagPrefix), v.(types.Ref).TargetHash().String())
+			}
+		})
+		tw.Flush()
+
+	case len(args) == 2:
+		db, ds, name := getTag(cfg, args[0])
+		defer db.Close()
+
+		if head, ok := ds.MaybeHeadRef(); ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Tag %s already exists at #%s", name, head.TargetHash().String()))
+		}
+		commit, err := resolveCommit(cfg, db, args[1])
+		d.CheckErrorNoUsage(err)
+		_, err = db.SetHead(ds, types.NewRef(commit))
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Tagged #%s as %s\n", commit.Hash().String(), name)
+
+	default:
+		d.CheckErrorNoUsage(errors.New("expected a database, or a tag and a commit"))
+	}
+	return 0
+}
+
+// getTag returns the dataset which the tag spelled by str is stored in, and
+// the tag's name.
+func getTag(cfg *config.Resolver, str string) (datas.Database, datas.Dataset, string) {
+	db, ds, err := cfg.GetDataset(str)
+	d.CheckErrorNoUsage(err)
+	return db, db.GetDataset(tagPrefix + ds.ID()), ds.ID()
+}
+
+// checkNotTag returns an error if the dataset with the given ID stores a
+// tag, which mustn't be changed like a branch.
+func checkNotTag(id string) error {
+	if strings.HasPrefix(id, tagPrefix) {
+		return fmt.Errorf("%s is a tag, use noms tag to manage it", id)
+	}
+	return nil
+}
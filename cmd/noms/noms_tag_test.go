@@ -0,0 +1,50 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsTag(t *testing.T) {
+	suite.Run(t, &nomsTagTestSuite{})
+}
+
+type nomsTagTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsTagTestSuite) TestTag() {
+	first, second := setupBranches(&s.ClientTestSuite)
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	name := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+
+	out, _ := s.MustRun(main, []string{"tag", dbSpec})
+	s.Equal("v1  #"+first+"\n", out)
+
+	out, _ = s.MustRun(main, []string{"tag", name("v2"), name("master")})
+	s.Equal("Tagged #"+second+" as v2\n", out)
+	out, _ = s.MustRun(main, []string{"tag", dbSpec})
+	s.Equal("v1  #"+first+"\nv2  #"+second+"\n", out)
+
+	_, stderr, recovered := s.Run(main, []string{"tag", name("v2"), name("tags/v1")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Tag v2 already exists at #"+second)
+
+	out, _ = s.MustRun(main, []string{"tag", "-d", name("v2")})
+	s.Equal("Deleted tag v2 (was #"+second+")\n", out)
+	out, _ = s.MustRun(main, []string{"tag", dbSpec})
+	s.Equal("v1  #"+first+"\n", out)
+
+	_, stderr, recovered = s.Run(main, []string{"tag", "-d", name("v2")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "Tag v2 not found")
+}
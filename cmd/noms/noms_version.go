@@ -27,7 +27,7 @@ func setupVersionFlags() *flag.FlagSet {
 }
 
 func runVersion(args []string) int {
-	fmt.Fprintf(os.Stdout, "format version: %v\n", constants.NomsVersion)
+	fmt.Fprintf(os.Stdout, "format version: %v\n", constants.DataVersion())
 	fmt.Fprintf(os.Stdout, "built from %v\n", constants.NomsGitSHA)
 	return 0
 }
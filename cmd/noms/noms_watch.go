@@ -0,0 +1,152 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/diff"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsWatch = &util.Command{
+	Run:       runWatch,
+	UsageLine: "watch [options] <dataset>",
+	Short:     "Prints commits to a dataset as they land",
+	Long: `Waits for the head of <dataset> to change and prints each new commit, with its meta fields and a summary of how it changed the value, oldest first.
+
+Databases served by "noms serve" are told to notify the watcher when their root changes. Other databases are polled every --interval.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.`,
+	Flags: setupWatchFlags,
+	Nargs: 1,
+}
+
+var (
+	watchInterval time.Duration
+	watchCount    int
+)
+
+const (
+	// watchWait is how long a server is asked to wait for its root to change
+	// before the watcher asks again.
+	watchWait = 30 * time.Second
+	// maxWatchCommits is the most commits printed for one change of head,
+	// newest first, e.g. after a big sync.
+	maxWatchCommits = 100
+)
+
+func setupWatchFlags() *flag.FlagSet {
+	watchFlagSet := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchFlagSet.DurationVar(&watchInterval, "interval", time.Second, "how often to poll databases which can't notify the watcher of changes")
+	watchFlagSet.IntVar(&watchCount, "n", 0, "exit after printing this many commits (0 to watch forever)")
+	verbose.RegisterVerboseFlags(watchFlagSet)
+	return watchFlagSet
+}
+
+func runWatch(args []string) int {
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[0])
+	d.CheckErrorNoUsage(err)
+
+	var last hash.Hash
+	if head, ok := ds.MaybeHeadRef(); ok {
+		last = head.TargetHash()
+	}
+	printed := 0
+	for watchCount <= 0 || printed < watchCount {
+		waitForChange(ds.Database())
+		// A Database is a moment in history, so a new one is needed to see
+		// the new head.
+		db.Close()
+		db, ds, err = cfg.GetDataset(args[0])
+		d.CheckErrorNoUsage(err)
+
+		head, ok := ds.MaybeHeadRef()
+		if !ok || head.TargetHash() == last {
+			continue
+		}
+		commits := newCommits(db, head.TargetValue(db).(types.Struct), last)
+		for i := len(commits) - 1; i >= 0 && (watchCount <= 0 || printed < watchCount); i-- {
+			printCommitSummary(os.Stdout, db, commits[i])
+			printed++
+		}
+		last = head.TargetHash()
+	}
+	db.Close()
+	return 0
+}
+
+// waitForChange returns when the root of db may have changed.
+func waitForChange(db datas.Database) {
+	if rdb, ok := db.(*datas.RemoteDatabaseClient); ok {
+		if _, ok := rdb.WaitForRoot(watchWait); ok {
+			return
+		}
+	}
+	time.Sleep(watchInterval)
+}
+
+// newCommits returns the commits in the history of head down to, but not
+// including, last, newest first and at most maxWatchCommits of them.
+func newCommits(db datas.Database, head types.Struct, last hash.Hash) []types.Struct {
+	commits := []types.Struct{}
+	iter := NewCommitIterator(db, head)
+	for node, ok := iter.Next(); ok && len(commits) < maxWatchCommits; node, ok = iter.Next() {
+		if node.commit.Hash() == last {
+			break
+		}
+		commits = append(commits, node.commit)
+	}
+	return commits
+}
+
+// printCommitSummary prints the hash, parent and meta fields of commit and a
+// summary of how it changed the value of its first parent.
+func printCommitSummary(w io.Writer, db datas.Database, commit types.Struct) {
+	parentValue := "None"
+	var parentCommit types.Struct
+	parents := commitRefsFromSet(commit.Get(datas.ParentsField).(types.Set))
+	if len(parents) > 0 {
+		parentValue = parents[0].TargetHash().String()
+		parentCommit = parents[0].TargetValue(db).(types.Struct)
+	}
+
+	labelLen := len("Parent")
+	meta, hasMeta := commit.MaybeGet(datas.MetaField)
+	if hasMeta {
+		types.TypeOf(meta).Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
+			labelLen = max(labelLen, len(name))
+		})
+	}
+
+	fmt.Fprintf(w, "commit %s\n", commit.Hash().String())
+	fmt.Fprintf(w, "%-*s %s\n", labelLen+1, "Parent:", parentValue)
+	if hasMeta {
+		writeMetaFields(w, meta.(types.Struct), labelLen)
+	}
+
+	value := commit.Get(datas.ValueField)
+	if len(parents) == 0 {
+		fmt.Fprintf(w, "new %s\n\n", types.TypeOf(value).Describe())
+		return
+	}
+	old := parentCommit.Get(datas.ValueField)
+	if old.Kind() != value.Kind() {
+		fmt.Fprintf(w, "changed from %s to %s\n\n", types.TypeOf(old).Describe(), types.TypeOf(value).Describe())
+		return
+	}
+	fmt.Fprintf(w, "%s\n\n", diff.SummaryString(old, value))
+}
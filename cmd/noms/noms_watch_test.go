@@ -0,0 +1,76 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsWatch(t *testing.T) {
+	suite.Run(t, &nomsWatchTestSuite{})
+}
+
+type nomsWatchTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsWatchTestSuite) commit(dsName string, v types.Value, message string) string {
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	meta := types.NewStruct("Meta", types.StructData{"message": types.String(message)})
+	ds, err := db.Commit(db.GetDataset(dsName), v, datas.CommitOptions{Meta: meta})
+	s.NoError(err)
+	return ds.HeadRef().TargetHash().String()
+}
+
+func (s *nomsWatchTestSuite) TestWatch() {
+	first := s.commit("ds", types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2)), "first")
+
+	commits := make(chan []string)
+	go func() {
+		// Give watch time to read the head it starts from.
+		time.Sleep(200 * time.Millisecond)
+		second := s.commit("ds", types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(3), types.String("c"), types.Number(4)), "second")
+		third := s.commit("ds", types.NewList(types.String("x")), "third")
+		commits <- []string{second, third}
+	}()
+
+	out, _ := s.MustRun(main, []string{"watch", "--interval", "10ms", "-n", "2", spec.CreateValueSpecString("nbs", s.DBDir, "ds")})
+	hashes := <-commits
+	s.Equal(`commit `+hashes[0]+`
+Parent:  `+first+`
+Message: "second"
+1 insertion (50.00%), 0 deletions (0.00%), 1 change (50.00%), (2 entries vs 3 entries)
+
+commit `+hashes[1]+`
+Parent:  `+hashes[0]+`
+Message: "third"
+changed from Map<String, Number> to List<String>
+
+`, out)
+}
+
+func (s *nomsWatchTestSuite) TestWatchNewDataset() {
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.commit("new", types.String("hello"), "hi")
+	}()
+	out, _ := s.MustRun(main, []string{"watch", "--interval", "10ms", "-n", "1", spec.CreateValueSpecString("nbs", s.DBDir, "new")})
+	s.Contains(out, "Parent:  None\nMessage: \"hi\"\nnew String\n")
+}
+
+func (s *nomsWatchTestSuite) TestWatchNotADataset() {
+	_, stderr, recovered := s.Run(main, []string{"watch", spec.CreateValueSpecString("nbs", s.DBDir, "ds.value")})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+	s.Contains(stderr, "path is not allowed for dataset spec")
+}
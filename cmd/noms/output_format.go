@@ -0,0 +1,228 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/datetime"
+	flag "github.com/juju/gnuflag"
+)
+
+// The --format and --template flags of commands with structured output.
+var (
+	outputFormat   string
+	outputTemplate string
+)
+
+func registerOutputFormatFlags(flags *flag.FlagSet) {
+	flags.StringVar(&outputFormat, "format", "text", "output format: text, json, yaml or template")
+	flags.StringVar(&outputTemplate, "template", "", "Go text/template to format output with if --format=template, e.g. '{{.hash}}'")
+}
+
+// recordWriter writes the records a command outputs in the format chosen
+// with --format, other than text. A record is a JSON-like value, see
+// jsonValue. If multi is true, there are any number of records, which are
+// written a line each as JSON, as items of a sequence as YAML and a template
+// execution each otherwise.
+type recordWriter struct {
+	multi bool
+	tmpl  *template.Template
+}
+
+// newRecordWriter returns nil if the output format is text.
+func newRecordWriter(multi bool) (*recordWriter, error) {
+	rw := &recordWriter{multi: multi}
+	switch outputFormat {
+	case "text":
+		return nil, nil
+	case "json", "yaml":
+	case "template":
+		if outputTemplate == "" {
+			return nil, fmt.Errorf("--format=template requires --template")
+		}
+		tmpl, err := template.New("output").Funcs(template.FuncMap{
+			"json": templateJSON,
+			"path": func(string) (interface{}, error) { return nil, nil },
+		}).Parse(outputTemplate)
+		if err != nil {
+			return nil, err
+		}
+		rw.tmpl = tmpl
+	default:
+		return nil, fmt.Errorf("unknown format %s, expected text, json, yaml or template", outputFormat)
+	}
+	return rw, nil
+}
+
+// write writes rec to w, or executes the template with data, in which the
+// path function resolves paths relative to root, if it isn't nil.
+func (rw *recordWriter) write(w io.Writer, rec, data interface{}, root types.Value) error {
+	var err error
+	switch {
+	case rw.tmpl != nil:
+		rw.tmpl.Funcs(template.FuncMap{
+			"path": func(str string) (interface{}, error) {
+				p, err := types.ParsePath(str)
+				if err != nil || root == nil {
+					return nil, err
+				}
+				if v := p.Resolve(root); v != nil {
+					return jsonValue(v), nil
+				}
+				return nil, nil
+			},
+		})
+		if err = rw.tmpl.Execute(w, data); err == nil {
+			_, err = io.WriteString(w, "\n")
+		}
+	case outputFormat == "json":
+		var b []byte
+		if b, err = json.Marshal(rec); err == nil {
+			_, err = w.Write(append(b, '\n'))
+		}
+	default:
+		lines := yamlLines(rec)
+		if rw.multi {
+			lines = yamlItem(lines)
+		}
+		_, err = io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	}
+	return err
+}
+
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// commitRecord returns the record for commit: its hash, parents and meta
+// fields, and the value at path if it isn't nil.
+func commitRecord(commit types.Struct, path types.Path) map[string]interface{} {
+	parents := []interface{}{}
+	for _, p := range commitRefsFromSet(commit.Get(datas.ParentsField).(types.Set)) {
+		parents = append(parents, p.TargetHash().String())
+	}
+	meta := map[string]interface{}{}
+	if m, ok := commit.MaybeGet(datas.MetaField); ok {
+		m.(types.Struct).IterFields(func(name string, v types.Value) {
+			if types.TypeOf(v).Equals(datetime.DateTimeType) {
+				var dt datetime.DateTime
+				dt.UnmarshalNoms(v)
+				meta[name] = time.Time(dt).Format(spec.CommitMetaDateFormat)
+			} else {
+				meta[name] = jsonValue(v)
+			}
+		})
+	}
+	rec := map[string]interface{}{
+		"hash":    commit.Hash().String(),
+		"parents": parents,
+		"meta":    meta,
+	}
+	if path != nil {
+		if v := path.Resolve(commit); v != nil {
+			rec["value"] = jsonValue(v)
+		}
+	}
+	return rec
+}
+
+// yamlPlainKey matches keys which needn't be quoted, apart from the ones
+// YAML reads as booleans or null.
+var (
+	yamlPlainKey    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-]*$`)
+	yamlReservedKey = regexp.MustCompile(`^(?i:y|n|yes|no|true|false|on|off|null)$`)
+)
+
+// yamlLines returns the lines of the block-style YAML for a JSON-like value.
+// Strings are always double-quoted, as in JSON.
+func yamlLines(v interface{}) []string {
+	switch v := v.(type) {
+	case nil:
+		return []string{"null"}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'g', -1, 64)}
+	case string:
+		b, _ := json.Marshal(v)
+		return []string{string(b)}
+	case []byte:
+		return []string{"!!binary " + base64.StdEncoding.EncodeToString(v)}
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{"[]"}
+		}
+		lines := []string{}
+		for _, e := range v {
+			lines = append(lines, yamlItem(yamlLines(e))...)
+		}
+		return lines
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return []string{"{}"}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		lines := []string{}
+		for _, k := range keys {
+			key := k
+			if !yamlPlainKey.MatchString(k) || yamlReservedKey.MatchString(k) {
+				key = yamlLines(k)[0]
+			}
+			child := yamlLines(v[k])
+			if isYAMLBlock(v[k]) {
+				lines = append(lines, key+":")
+				for _, l := range child {
+					lines = append(lines, "  "+l)
+				}
+			} else {
+				lines = append(lines, key+": "+child[0])
+			}
+		}
+		return lines
+	}
+	panic("not reached")
+}
+
+// yamlItem returns lines as an item of a sequence.
+func yamlItem(lines []string) []string {
+	item := make([]string, len(lines))
+	for i, l := range lines {
+		if i == 0 {
+			item[i] = "- " + l
+		} else {
+			item[i] = "  " + l
+		}
+	}
+	return item
+}
+
+// isYAMLBlock returns whether v is a non-empty sequence or mapping.
+func isYAMLBlock(v interface{}) bool {
+	switch v := v.(type) {
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return false
+}
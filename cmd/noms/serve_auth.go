@@ -0,0 +1,246 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/attic-labs/noms/go/datas"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// serveAuthConfig is the TOML auth config file of noms serve, see the help
+// of nomsServe for an example. Clients send tokens as bearer tokens, and user
+// names and passwords with basic auth. Other credentials are sent to the
+// verify URL, if any, in the Authorization header of a GET request, which
+// responds with 200 OK and the name of the client if they're valid.
+type serveAuthConfig struct {
+	Token []struct {
+		Name  string
+		Token string
+	}
+	User []struct {
+		Name     string
+		Password string
+	}
+	Verify struct {
+		URL string
+	}
+	Allow []struct {
+		Clients []string
+		Read    string
+		Write   string
+		Admin   bool
+	}
+}
+
+// verifyCacheTime is how long the verify URL's response to credentials is
+// reused for, rather than sending a request for every chunk request.
+const verifyCacheTime = time.Minute
+
+// maxVerifiedClients bounds the number of responses of the verify URL kept.
+const maxVerifiedClients = 10000
+
+type serveAuthRule struct {
+	clients     map[string]bool
+	read, write *regexp.Regexp
+	admin       bool
+}
+
+type verifiedClient struct {
+	name    string
+	expires time.Time
+}
+
+// serveAuth implements datas.ServerAuth with a serveAuthConfig read from a
+// file, which is read again by reload.
+type serveAuth struct {
+	file   string
+	client *http.Client
+
+	mu       sync.RWMutex
+	tokens   map[string]string
+	users    map[string][]byte
+	verify   string
+	rules    []serveAuthRule
+	verified map[string]verifiedClient
+}
+
+func newServeAuth(file string) (*serveAuth, error) {
+	a := &serveAuth{file: file, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload reads the config file again. If it's invalid, the previous config
+// is kept.
+func (a *serveAuth) reload() error {
+	data, err := ioutil.ReadFile(a.file)
+	if err != nil {
+		return err
+	}
+	var c serveAuthConfig
+	if _, err := toml.Decode(string(data), &c); err != nil {
+		return err
+	}
+
+	tokens := map[string]string{}
+	for _, t := range c.Token {
+		if t.Name == "" || t.Token == "" {
+			return errors.New("tokens must have a name and a token")
+		}
+		tokens[t.Token] = t.Name
+	}
+	users := map[string][]byte{}
+	for _, u := range c.User {
+		if u.Name == "" || u.Password == "" {
+			return errors.New("users must have a name and a password")
+		}
+		if _, err := bcrypt.Cost([]byte(u.Password)); err != nil {
+			return fmt.Errorf("the password of %s must be a bcrypt hash: %s", u.Name, err)
+		}
+		users[u.Name] = []byte(u.Password)
+	}
+	rules := make([]serveAuthRule, len(c.Allow))
+	for i, allow := range c.Allow {
+		rules[i].clients = map[string]bool{}
+		for _, name := range allow.Clients {
+			rules[i].clients[name] = true
+		}
+		if rules[i].read, err = compileDatasetsRe(allow.Read); err != nil {
+			return err
+		}
+		if rules[i].write, err = compileDatasetsRe(allow.Write); err != nil {
+			return err
+		}
+		rules[i].admin = allow.Admin
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens, a.users, a.verify, a.rules = tokens, users, c.Verify.URL, rules
+	a.verified = map[string]verifiedClient{}
+	return nil
+}
+
+// compileDatasetsRe returns the regular expression matching the whole of the
+// dataset IDs str matches, or nil if str is empty.
+func compileDatasetsRe(str string) (*regexp.Regexp, error) {
+	if str == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + str + ")$")
+}
+
+func (a *serveAuth) Authenticate(req *http.Request) (string, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return "", errors.New("Missing credentials")
+	}
+
+	a.mu.RLock()
+	verify := a.verify
+	if strings.HasPrefix(header, "Bearer ") {
+		token := []byte(strings.TrimPrefix(header, "Bearer "))
+		for t, name := range a.tokens {
+			if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+				a.mu.RUnlock()
+				return name, nil
+			}
+		}
+	} else if user, password, ok := req.BasicAuth(); ok {
+		if hash, ok := a.users[user]; ok {
+			a.mu.RUnlock()
+			if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+				return "", errors.New("Invalid credentials")
+			}
+			return user, nil
+		}
+	}
+	v, ok := a.verified[header]
+	a.mu.RUnlock()
+
+	switch {
+	case verify == "":
+		return "", errors.New("Invalid credentials")
+	case ok && time.Now().Before(v.expires):
+		if v.name == "" {
+			return "", errors.New("Invalid credentials")
+		}
+		return v.name, nil
+	}
+
+	name, err := a.verifyCredentials(verify, header)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	if len(a.verified) >= maxVerifiedClients {
+		a.verified = map[string]verifiedClient{}
+	}
+	a.verified[header] = verifiedClient{name, time.Now().Add(verifyCacheTime)}
+	a.mu.Unlock()
+	if name == "" {
+		return "", errors.New("Invalid credentials")
+	}
+	return name, nil
+}
+
+// verifyCredentials sends the Authorization header to the verify URL, and
+// returns the name of the client, or "" if the credentials are invalid.
+func (a *serveAuth) verifyCredentials(url, header string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", header)
+	res, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't verify credentials: %s", err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return "", fmt.Errorf("Couldn't verify credentials: %s", err)
+		}
+		return strings.TrimSpace(string(body)), nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", nil
+	}
+	return "", fmt.Errorf("Couldn't verify credentials: %s", res.Status)
+}
+
+func (a *serveAuth) Access(name, datasetID string) datas.Access {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	access := datas.NoAccess
+	for _, r := range a.rules {
+		if !r.clients[name] && !r.clients["*"] {
+			continue
+		}
+		switch {
+		case r.admin:
+			return datas.AdminAccess
+		case r.write != nil && (datasetID == "" || r.write.MatchString(datasetID)):
+			return datas.WriteAccess
+		case r.read != nil && (datasetID == "" || r.read.MatchString(datasetID)):
+			access = datas.ReadAccess
+		}
+	}
+	return access
+}
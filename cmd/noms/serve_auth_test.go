@@ -0,0 +1,132 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeServeAuthConfig(assert *assert.Assertions, config string) string {
+	f, err := ioutil.TempFile("", "noms-serve-auth")
+	assert.NoError(err)
+	defer f.Close()
+	_, err = f.WriteString(config)
+	assert.NoError(err)
+	return f.Name()
+}
+
+func TestServeAuth(t *testing.T) {
+	assert := assert.New(t)
+	verifications := 0
+	verifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		verifications++
+		if req.Header.Get("Authorization") != "Bearer external" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, "bob")
+	}))
+	defer verifier.Close()
+
+	password, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(err)
+	file := writeServeAuthConfig(assert, fmt.Sprintf(`
+[[token]]
+name = "ci"
+token = "t0ken"
+
+[[user]]
+name = "alice"
+password = "%s"
+
+[verify]
+url = "%s"
+
+[[allow]]
+clients = ["alice"]
+write = "alice/.*"
+
+[[allow]]
+clients = ["*"]
+read = "public"
+
+[[allow]]
+clients = ["ops"]
+admin = true
+`, password, verifier.URL))
+	defer os.Remove(file)
+
+	auth, err := newServeAuth(file)
+	assert.NoError(err)
+
+	authenticate := func(header string) (string, error) {
+		req, _ := http.NewRequest("GET", "/root/", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		return auth.Authenticate(req)
+	}
+	name, err := authenticate("Bearer t0ken")
+	assert.NoError(err)
+	assert.Equal("ci", name)
+
+	req, _ := http.NewRequest("GET", "/root/", nil)
+	req.SetBasicAuth("alice", "secret")
+	name, err = authenticate(req.Header.Get("Authorization"))
+	assert.NoError(err)
+	assert.Equal("alice", name)
+	req.SetBasicAuth("alice", "wrong")
+	_, err = authenticate(req.Header.Get("Authorization"))
+	assert.Error(err)
+
+	_, err = authenticate("")
+	assert.Error(err)
+
+	// Credentials checked by the verify URL are cached.
+	for i := 0; i < 2; i++ {
+		name, err = authenticate("Bearer external")
+		assert.NoError(err)
+		assert.Equal("bob", name)
+		_, err = authenticate("Bearer wrong")
+		assert.Error(err)
+	}
+	assert.Equal(2, verifications)
+
+	assert.Equal(datas.WriteAccess, auth.Access("alice", ""))
+	assert.Equal(datas.WriteAccess, auth.Access("alice", "alice/photos"))
+	assert.Equal(datas.ReadAccess, auth.Access("alice", "public"))
+	assert.Equal(datas.NoAccess, auth.Access("alice", "other"))
+	assert.Equal(datas.ReadAccess, auth.Access("ci", ""))
+	assert.Equal(datas.ReadAccess, auth.Access("bob", "public"))
+	assert.Equal(datas.NoAccess, auth.Access("bob", "alice/photos"))
+	assert.Equal(datas.AdminAccess, auth.Access("ops", ""))
+	assert.Equal(datas.AdminAccess, auth.Access("ops", "alice/photos"))
+
+	// An invalid config isn't loaded.
+	assert.NoError(ioutil.WriteFile(file, []byte(`[[allow]]
+clients = ["*"]
+write = "("
+`), 0644))
+	assert.Error(auth.reload())
+	assert.Equal(datas.NoAccess, auth.Access("ci", "other"))
+
+	assert.NoError(ioutil.WriteFile(file, []byte(`[[allow]]
+clients = ["*"]
+write = ".*"
+`), 0644))
+	assert.NoError(auth.reload())
+	assert.Equal(datas.WriteAccess, auth.Access("ci", "other"))
+	_, err = authenticate("Bearer t0ken")
+	assert.Error(err)
+}
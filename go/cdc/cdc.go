@@ -0,0 +1,121 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package cdc publishes the changes to a dataset for change data capture. Each
+// commit is published as the Events of the differences between its value and
+// the value of its parent, oldest commit first, as yielded by a
+// datas.ChangeFeed. The last published commit is
+// kept in a Checkpoint, so that publishing resumes after it, and a commit's
+// Events may be published again if publishing stops before it's saved.
+package cdc
+
+import (
+	"errors"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/diff"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Event is the change of the value at Path in the value of Dataset made by
+// Commit. Old and New are the values before and after the change, in the
+// human readable encoding, and are empty for added and removed values.
+type Event struct {
+	Dataset string `json:"dataset"`
+	Commit  string `json:"commit"`
+	Parent  string `json:"parent,omitempty"`
+	Path    string `json:"path"`
+	Change  string `json:"change"` // "added", "removed" or "modified"
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+}
+
+// Key is the key which orders the Events of the same value, the dataset and
+// path of the value.
+func (e Event) Key() string {
+	return e.Dataset + e.Path
+}
+
+// Publisher publishes Events. Publish must not return nil until the Events
+// won't be lost.
+type Publisher interface {
+	Publish(events []Event) error
+}
+
+// BatchSize is the most Events given to Publisher.Publish at once.
+var BatchSize = 500
+
+// ErrCheckpointNotFound is returned by Publish if the commit in the Checkpoint
+// isn't the head of the dataset or one of its ancestors, e.g. because the
+// dataset was reset to another commit.
+var ErrCheckpointNotFound = errors.New("checkpoint commit isn't an ancestor of the dataset head")
+
+var changeNames = map[types.DiffChangeType]string{
+	types.DiffChangeAdded:    "added",
+	types.DiffChangeRemoved:  "removed",
+	types.DiffChangeModified: "modified",
+}
+
+// Publish publishes the Events of the commits of ds after the one in cp, and
+// saves each commit to cp once its Events are published. It returns the
+// number of commits published. The commits are those of a datas.ChangeFeed.
+func Publish(ds datas.Dataset, pub Publisher, cp Checkpoint) (int, error) {
+	last, err := cp.Load()
+	if err != nil {
+		return 0, err
+	}
+	feed, err := datas.ChangeFeed(ds, last)
+	if err == datas.ErrUnknownResumeToken {
+		return 0, ErrCheckpointNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for c, ok := feed.Next(); ok; c, ok = feed.Next() {
+		events := ChangeEvents(ds.ID(), c)
+		for len(events) > 0 {
+			n := BatchSize
+			if n > len(events) {
+				n = len(events)
+			}
+			if err := pub.Publish(events[:n]); err != nil {
+				return published, err
+			}
+			events = events[n:]
+		}
+		if err := cp.Save(c.Token()); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// ChangeEvents returns the Events of c, a Change of datasetID.
+func ChangeEvents(datasetID string, c datas.Change) []Event {
+	base := Event{Dataset: datasetID, Commit: c.Commit.Hash().String()}
+	if c.HasParent() {
+		base.Parent = c.Parent.TargetHash().String()
+	}
+
+	events := []Event{}
+	dChan, stopChan := make(chan diff.Difference, 16), make(chan struct{})
+	go func() {
+		diff.ChangeDiff(c, dChan, stopChan)
+		close(dChan)
+	}()
+	for dif := range dChan {
+		e := base
+		e.Path, e.Change = dif.Path.String(), changeNames[dif.ChangeType]
+		if dif.OldValue != nil {
+			e.Old = types.EncodedValue(dif.OldValue)
+		}
+		if dif.NewValue != nil {
+			e.New = types.EncodedValue(dif.NewValue)
+		}
+		events = append(events, e)
+	}
+	return events
+}
@@ -0,0 +1,294 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type memoryPublisher struct {
+	events []Event
+	fail   bool
+}
+
+func (mp *memoryPublisher) Publish(events []Event) error {
+	if mp.fail {
+		return errors.New("publish failed")
+	}
+	mp.events = append(mp.events, events...)
+	return nil
+}
+
+type memoryCheckpoint struct {
+	h    hash.Hash
+	fail bool
+}
+
+func (mc *memoryCheckpoint) Load() (hash.Hash, error) {
+	return mc.h, nil
+}
+
+func (mc *memoryCheckpoint) Save(h hash.Hash) error {
+	if mc.fail {
+		return errors.New("save failed")
+	}
+	mc.h = h
+	return nil
+}
+
+func commitMap(db datas.Database, ds datas.Dataset, kv ...types.Value) datas.Dataset {
+	ds, err := db.CommitValue(ds, types.NewMap(kv...))
+	if err != nil {
+		panic(err)
+	}
+	return ds
+}
+
+func TestPublish(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := db.GetDataset("ds")
+
+	ds = commitMap(db, ds, types.String("a"), types.Number(1))
+	ds = commitMap(db, ds, types.String("a"), types.Number(2), types.String("b"), types.Number(3))
+
+	pub, cp := &memoryPublisher{}, &memoryCheckpoint{}
+	n, err := Publish(ds, pub, cp)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	assert.Equal(ds.Head().Hash(), cp.h)
+	assert.Len(pub.events, 3)
+
+	first := pub.events[0]
+	assert.Equal("ds", first.Dataset)
+	assert.Equal(`["a"]`, first.Path)
+	assert.Equal("added", first.Change)
+	assert.Equal("1", first.New)
+	assert.Empty(first.Parent)
+	assert.Equal(`ds["a"]`, first.Key())
+
+	assert.Equal("modified", pub.events[1].Change)
+	assert.Equal("1", pub.events[1].Old)
+	assert.Equal("2", pub.events[1].New)
+	assert.Equal(first.Commit, pub.events[1].Parent)
+	assert.Equal(ds.Head().Hash().String(), pub.events[1].Commit)
+	assert.Equal("added", pub.events[2].Change)
+	assert.Equal(`["b"]`, pub.events[2].Path)
+
+	// Nothing is published again until there are new commits.
+	n, err = Publish(ds, pub, cp)
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	ds = commitMap(db, ds, types.String("b"), types.Number(3))
+	n, err = Publish(ds, pub, cp)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	assert.Len(pub.events, 4)
+	assert.Equal("removed", pub.events[3].Change)
+	assert.Equal("2", pub.events[3].Old)
+}
+
+func TestPublishAfterMerge(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := commitMap(db, db.GetDataset("ds"), types.String("a"), types.Number(1))
+	other, err := db.Commit(db.GetDataset("other"), types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(1)), datas.CommitOptions{Parents: types.NewSet(ds.HeadRef())})
+	assert.NoError(err)
+	other = commitMap(db, other, types.String("a"), types.Number(1), types.String("b"), types.Number(2))
+	ds = commitMap(db, ds, types.String("a"), types.Number(2))
+
+	pub, cp := &memoryPublisher{}, &memoryCheckpoint{}
+	_, err = Publish(ds, pub, cp)
+	assert.NoError(err)
+	pub.events = nil
+
+	// The merge's taller parent is other, but it's published as a change
+	// from the last commit published.
+	merged := types.NewMap(types.String("a"), types.Number(2), types.String("b"), types.Number(2))
+	ds, err = db.Commit(ds, merged, datas.CommitOptions{Parents: types.NewSet(ds.HeadRef(), other.HeadRef())})
+	assert.NoError(err)
+	n, err := Publish(ds, pub, cp)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	assert.Len(pub.events, 1)
+	assert.Equal("added", pub.events[0].Change)
+	assert.Equal(`["b"]`, pub.events[0].Path)
+}
+
+func TestPublishAtLeastOnce(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := db.GetDataset("ds")
+	ds = commitMap(db, ds, types.String("a"), types.Number(1))
+
+	pub, cp := &memoryPublisher{fail: true}, &memoryCheckpoint{}
+	n, err := Publish(ds, pub, cp)
+	assert.Error(err)
+	assert.Equal(0, n)
+	assert.True(cp.h.IsEmpty())
+
+	// The events are published, but the checkpoint isn't saved, so they're
+	// published again.
+	pub.fail, cp.fail = false, true
+	_, err = Publish(ds, pub, cp)
+	assert.Error(err)
+	assert.Len(pub.events, 1)
+
+	cp.fail = false
+	n, err = Publish(ds, pub, cp)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	assert.Len(pub.events, 2)
+	assert.Equal(pub.events[0], pub.events[1])
+}
+
+func TestPublishBatches(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := db.GetDataset("ds")
+	kv := []types.Value{}
+	for i := 0; i < 5; i++ {
+		kv = append(kv, types.Number(i), types.Bool(true))
+	}
+	ds = commitMap(db, ds, kv...)
+
+	defer func(n int) { BatchSize = n }(BatchSize)
+	BatchSize = 2
+	batches := 0
+	pub := publisherFunc(func(events []Event) error {
+		assert.True(len(events) <= 2)
+		batches++
+		return nil
+	})
+	_, err := Publish(ds, pub, &memoryCheckpoint{})
+	assert.NoError(err)
+	assert.Equal(3, batches)
+}
+
+type publisherFunc func([]Event) error
+
+func (f publisherFunc) Publish(events []Event) error {
+	return f(events)
+}
+
+func TestPublishCheckpointNotFound(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := commitMap(db, db.GetDataset("ds"), types.String("a"), types.Number(1))
+
+	_, err := Publish(ds, &memoryPublisher{}, &memoryCheckpoint{h: hash.Of([]byte("other"))})
+	assert.Equal(ErrCheckpointNotFound, err)
+}
+
+// headEvents returns the Events of the head commit of ds.
+func headEvents(ds datas.Dataset) (events []Event) {
+	feed, err := datas.ChangeFeed(ds, hash.Hash{})
+	if err != nil {
+		panic(err)
+	}
+	for c, ok := feed.Next(); ok; c, ok = feed.Next() {
+		events = ChangeEvents(ds.ID(), c)
+	}
+	return
+}
+
+func TestChangeEventsNonCollection(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	events := headEvents(ds)
+	assert.Len(events, 1)
+	assert.Equal("added", events[0].Change)
+	assert.Equal("1", events[0].New)
+
+	ds, err = db.CommitValue(ds, types.String("x"))
+	assert.NoError(err)
+	events = headEvents(ds)
+	assert.Len(events, 1)
+	assert.Equal("modified", events[0].Change)
+	assert.Equal("1", events[0].Old)
+	assert.Equal(`"x"`, events[0].New)
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "cdc")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fc := FileCheckpoint(filepath.Join(dir, "checkpoint"))
+	h, err := fc.Load()
+	assert.NoError(err)
+	assert.True(h.IsEmpty())
+
+	expected := hash.Of([]byte("commit"))
+	assert.NoError(fc.Save(expected))
+	h, err = fc.Load()
+	assert.NoError(err)
+	assert.Equal(expected, h)
+
+	assert.NoError(ioutil.WriteFile(string(fc), []byte("garbage"), 0644))
+	_, err = fc.Load()
+	assert.Error(err)
+}
+
+func TestKafkaRESTPublisher(t *testing.T) {
+	assert := assert.New(t)
+	var body struct {
+		Records []struct {
+			Key   string
+			Value Event
+		}
+	}
+	errorCode := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/topics/changes" {
+			http.Error(w, "topic not found", http.StatusNotFound)
+			return
+		}
+		assert.Equal("application/vnd.kafka.json.v2+json", req.Header.Get("Content-Type"))
+		assert.NoError(json.NewDecoder(req.Body).Decode(&body))
+		if errorCode != 0 {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"broker unavailable"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	kp := KafkaRESTPublisher{URL: server.URL + "/", Topic: "changes"}
+	e := Event{Dataset: "ds", Commit: "c", Path: `["a"]`, Change: "added", New: "1"}
+	assert.NoError(kp.Publish([]Event{e}))
+	assert.Len(body.Records, 1)
+	assert.Equal(e.Key(), body.Records[0].Key)
+	assert.Equal(e, body.Records[0].Value)
+
+	errorCode = 50002
+	assert.Error(kp.Publish([]Event{e}))
+
+	kp.Topic = "missing"
+	assert.Error(kp.Publish([]Event{e}))
+}
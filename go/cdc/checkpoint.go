@@ -0,0 +1,91 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cdc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Checkpoint keeps the hash of the last commit whose Events were published,
+// the high-water mark of Publish.
+type Checkpoint interface {
+	// Load returns the saved hash, or the empty hash if none was saved.
+	Load() (hash.Hash, error)
+	Save(h hash.Hash) error
+}
+
+// FileCheckpoint is a Checkpoint kept in the file at its path.
+type FileCheckpoint string
+
+func (fc FileCheckpoint) Load() (hash.Hash, error) {
+	data, err := ioutil.ReadFile(string(fc))
+	if os.IsNotExist(err) {
+		return hash.Hash{}, nil
+	} else if err != nil {
+		return hash.Hash{}, err
+	}
+	h, ok := hash.MaybeParse(strings.TrimSpace(string(data)))
+	if !ok {
+		return hash.Hash{}, fmt.Errorf("%s doesn't contain a hash", fc)
+	}
+	return h, nil
+}
+
+// Save writes h to a temporary file which replaces the file, so that the file
+// is never left incomplete.
+func (fc FileCheckpoint) Save(h hash.Hash) error {
+	f, err := ioutil.TempFile(filepath.Dir(string(fc)), filepath.Base(string(fc)))
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(h.String() + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), string(fc))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// DatasetCheckpoint is a Checkpoint kept as the value of the dataset ID of DB,
+// a sync watermark which can be kept in the database being published.
+type DatasetCheckpoint struct {
+	DB datas.Database
+	ID string
+}
+
+func (dc DatasetCheckpoint) Load() (hash.Hash, error) {
+	v, ok := dc.DB.GetDataset(dc.ID).MaybeHeadValue()
+	if !ok {
+		return hash.Hash{}, nil
+	}
+	if s, ok := v.(types.String); ok {
+		if h, ok := hash.MaybeParse(string(s)); ok {
+			return h, nil
+		}
+	}
+	return hash.Hash{}, fmt.Errorf("dataset %s doesn't contain a hash", dc.ID)
+}
+
+// Save commits h, as a String, to the dataset.
+func (dc DatasetCheckpoint) Save(h hash.Hash) error {
+	_, err := dc.DB.CommitValue(dc.DB.GetDataset(dc.ID), types.String(h.String()))
+	return err
+}
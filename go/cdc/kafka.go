@@ -0,0 +1,85 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cdc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KafkaRESTPublisher publishes Events to Topic through the Kafka REST Proxy
+// (https://github.com/confluentinc/kafka-rest) at URL. Events are keyed by
+// Event.Key, so that the changes of each value are kept in order in one
+// partition of the topic.
+type KafkaRESTPublisher struct {
+	URL    string
+	Topic  string
+	Client *http.Client // http.DefaultClient if nil
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+type kafkaResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish produces events to the topic, and returns an error if the proxy
+// didn't acknowledge all of them.
+func (kp KafkaRESTPublisher) Publish(events []Event) error {
+	records := make([]kafkaRecord, len(events))
+	for i, e := range events {
+		records[i] = kafkaRecord{e.Key(), e}
+	}
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{records})
+	if err != nil {
+		return err
+	}
+
+	client := kp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := strings.TrimRight(kp.URL, "/") + "/topics/" + url.PathEscape(kp.Topic)
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("producing to %s failed: %s", kp.Topic, res.Status)
+	}
+
+	var kr kafkaResponse
+	if err := json.NewDecoder(res.Body).Decode(&kr); err != nil {
+		return err
+	}
+	if len(kr.Offsets) != len(events) {
+		return fmt.Errorf("producing to %s acknowledged %d of %d events", kp.Topic, len(kr.Offsets), len(events))
+	}
+	for _, o := range kr.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("producing to %s failed: %s (%d)", kp.Topic, o.Error, *o.ErrorCode)
+		}
+	}
+	return nil
+}
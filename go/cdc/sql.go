@@ -0,0 +1,191 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cdc
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// RowChange is the change of the row with Key of a struct collection, a Map
+// of structs. Row is the struct after the change, and is the zero Struct if
+// the row was removed.
+type RowChange struct {
+	Key     types.Value
+	Row     types.Struct
+	Removed bool
+}
+
+// ChangeRows returns the RowChanges of c, a Change of a dataset whose value is
+// a Map of structs. All the rows are added by the first commit of the dataset,
+// or if the value of the parent isn't a Map.
+func ChangeRows(c datas.Change) ([]RowChange, error) {
+	newMap, ok := c.NewValue().(types.Map)
+	if !ok {
+		return nil, fmt.Errorf("commit %s: value is a %s, not a Map of structs", c.Commit.Hash(), types.TypeOf(c.NewValue()).Describe())
+	}
+	oldMap := types.NewMap()
+	if c.HasParent() {
+		if oldMap, ok = c.OldValue().(types.Map); !ok {
+			oldMap = types.NewMap()
+		}
+	}
+
+	rows := []RowChange{}
+	changes, stop := make(chan types.ValueChanged, 16), make(chan struct{})
+	go func() {
+		newMap.Diff(oldMap, changes, stop)
+		close(changes)
+	}()
+	var err error
+	for vc := range changes {
+		if err != nil {
+			continue
+		}
+		if vc.ChangeType == types.DiffChangeRemoved {
+			rows = append(rows, RowChange{Key: vc.V, Removed: true})
+			continue
+		}
+		row, ok := newMap.Get(vc.V).(types.Struct)
+		if !ok {
+			err = fmt.Errorf("commit %s: value of %s isn't a struct", c.Commit.Hash(), types.EncodedValue(vc.V))
+			close(stop)
+			continue
+		}
+		rows = append(rows, RowChange{Key: vc.V, Row: row})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SQLExporter exports the rows of a struct collection to Table of DB, a
+// database/sql target such as a data warehouse. Each row is keyed by
+// KeyColumn, and has a column for each field of its struct. Booleans, numbers
+// and strings are exported as such, and other values in the human readable
+// encoding. Fields missing from a struct are left to the column default.
+type SQLExporter struct {
+	DB        *sql.DB
+	Table     string
+	KeyColumn string // "key" if empty
+	// Placeholder returns the placeholder of the nth (from 1) argument of a
+	// statement, QuestionPlaceholder if nil.
+	Placeholder func(n int) string
+}
+
+// QuestionPlaceholder is the placeholder of MySQL, SQLite and BigQuery.
+func QuestionPlaceholder(n int) string {
+	return "?"
+}
+
+// DollarPlaceholder is the placeholder of PostgreSQL.
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Export exports the RowChanges of the commits of ds after the one in cp, and
+// saves each commit to cp once its changes are committed to the table. It
+// returns the number of commits exported. Each commit's changes are made in
+// one transaction, changed rows are deleted and inserted again, so a commit
+// may be exported again if exporting stops before it's saved.
+func (se SQLExporter) Export(ds datas.Dataset, cp Checkpoint) (int, error) {
+	last, err := cp.Load()
+	if err != nil {
+		return 0, err
+	}
+	feed, err := datas.ChangeFeed(ds, last)
+	if err == datas.ErrUnknownResumeToken {
+		return 0, ErrCheckpointNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	for c, ok := feed.Next(); ok; c, ok = feed.Next() {
+		rows, err := ChangeRows(c)
+		if err != nil {
+			return exported, err
+		}
+		if err := se.apply(rows); err != nil {
+			return exported, err
+		}
+		if err := cp.Save(c.Token()); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+	return exported, nil
+}
+
+func (se SQLExporter) apply(rows []RowChange) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+	tx, err := se.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	del := fmt.Sprintf("DELETE FROM %s WHERE %s = %s", se.Table, se.keyColumn(), se.placeholder(1))
+	for _, rc := range rows {
+		if _, err = tx.Exec(del, sqlValue(rc.Key)); err != nil {
+			return err
+		}
+		if rc.Removed {
+			continue
+		}
+		cols, args := []string{se.keyColumn()}, []interface{}{sqlValue(rc.Key)}
+		rc.Row.IterFields(func(name string, v types.Value) {
+			cols, args = append(cols, name), append(args, sqlValue(v))
+		})
+		params := make([]string, len(args))
+		for i := range params {
+			params[i] = se.placeholder(i + 1)
+		}
+		ins := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", se.Table, strings.Join(cols, ", "), strings.Join(params, ", "))
+		if _, err = tx.Exec(ins, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (se SQLExporter) keyColumn() string {
+	if se.KeyColumn == "" {
+		return "key"
+	}
+	return se.KeyColumn
+}
+
+func (se SQLExporter) placeholder(n int) string {
+	if se.Placeholder == nil {
+		return QuestionPlaceholder(n)
+	}
+	return se.Placeholder(n)
+}
+
+// sqlValue returns v as an argument of a statement.
+func sqlValue(v types.Value) interface{} {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	}
+	return types.EncodedValue(v)
+}
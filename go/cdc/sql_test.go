@@ -0,0 +1,207 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cdc
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// recordingDriver is a database/sql driver which records the statements
+// committed through it, by data source name.
+type recordingDriver struct {
+	mu        sync.Mutex
+	committed map[string][]string
+	fail      bool
+}
+
+var recorder = &recordingDriver{committed: map[string][]string{}}
+
+func init() {
+	sql.Register("cdc-recording", recorder)
+}
+
+func (rd *recordingDriver) Open(name string) (driver.Conn, error) {
+	return &recordingConn{rd, name, nil}, nil
+}
+
+func (rd *recordingDriver) statements(name string) []string {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	return rd.committed[name]
+}
+
+type recordingConn struct {
+	rd      *recordingDriver
+	name    string
+	pending []string
+}
+
+func (rc *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{rc, query}, nil
+}
+
+func (rc *recordingConn) Close() error {
+	return nil
+}
+
+func (rc *recordingConn) Begin() (driver.Tx, error) {
+	rc.pending = []string{}
+	return rc, nil
+}
+
+func (rc *recordingConn) Commit() error {
+	rc.rd.mu.Lock()
+	defer rc.rd.mu.Unlock()
+	if rc.rd.fail {
+		return errors.New("commit failed")
+	}
+	rc.rd.committed[rc.name] = append(rc.rd.committed[rc.name], rc.pending...)
+	return nil
+}
+
+func (rc *recordingConn) Rollback() error {
+	rc.pending = nil
+	return nil
+}
+
+type recordingStmt struct {
+	rc    *recordingConn
+	query string
+}
+
+func (rs recordingStmt) Close() error {
+	return nil
+}
+
+func (rs recordingStmt) NumInput() int {
+	return -1
+}
+
+func (rs recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	strs := make([]string, len(args))
+	for i, a := range args {
+		strs[i] = fmt.Sprintf("%v", a)
+	}
+	rs.rc.pending = append(rs.rc.pending, rs.query+" "+strings.Join(strs, ","))
+	return driver.RowsAffected(1), nil
+}
+
+func (rs recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, io.EOF
+}
+
+func person(name string, age float64) types.Struct {
+	return types.NewStruct("Person", types.StructData{
+		"name": types.String(name),
+		"age":  types.Number(age),
+	})
+}
+
+func TestSQLExport(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	sqlDB, err := sql.Open("cdc-recording", t.Name())
+	assert.NoError(err)
+	defer sqlDB.Close()
+
+	ds := commitMap(db, db.GetDataset("people"), types.String("a"), person("Alice", 40))
+	ds = commitMap(db, ds, types.String("a"), person("Alice", 41), types.String("b"), person("Bob", 30))
+
+	se := SQLExporter{DB: sqlDB, Table: "people", KeyColumn: "id"}
+	cp := DatasetCheckpoint{db, "people-watermark"}
+	n, err := se.Export(ds, cp)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	assert.Equal([]string{
+		"DELETE FROM people WHERE id = ? a",
+		"INSERT INTO people (id, age, name) VALUES (?, ?, ?) a,40,Alice",
+		"DELETE FROM people WHERE id = ? a",
+		"INSERT INTO people (id, age, name) VALUES (?, ?, ?) a,41,Alice",
+		"DELETE FROM people WHERE id = ? b",
+		"INSERT INTO people (id, age, name) VALUES (?, ?, ?) b,30,Bob",
+	}, recorder.statements(t.Name()))
+	h, err := cp.Load()
+	assert.NoError(err)
+	assert.Equal(ds.Head().Hash(), h)
+
+	// Nothing is exported again until there are new commits.
+	n, err = se.Export(ds, cp)
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	ds = commitMap(db, ds, types.String("b"), person("Bob", 30))
+	se.Placeholder = DollarPlaceholder
+	n, err = se.Export(ds, cp)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	statements := recorder.statements(t.Name())
+	assert.Len(statements, 7)
+	assert.Equal("DELETE FROM people WHERE id = $1 a", statements[6])
+}
+
+func TestSQLExportAtLeastOnce(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	sqlDB, err := sql.Open("cdc-recording", t.Name())
+	assert.NoError(err)
+	defer sqlDB.Close()
+	ds := commitMap(db, db.GetDataset("people"), types.Number(1), person("Alice", 40))
+
+	se, cp := SQLExporter{DB: sqlDB, Table: "people"}, &memoryCheckpoint{}
+	recorder.fail = true
+	n, err := se.Export(ds, cp)
+	recorder.fail = false
+	assert.Error(err)
+	assert.Equal(0, n)
+	assert.True(cp.h.IsEmpty())
+	assert.Empty(recorder.statements(t.Name()))
+
+	// The rows are committed, but the checkpoint isn't saved, so they're
+	// exported again, replacing the rows.
+	cp.fail = true
+	_, err = se.Export(ds, cp)
+	assert.Error(err)
+	cp.fail = false
+	n, err = se.Export(ds, cp)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	statements := recorder.statements(t.Name())
+	assert.Len(statements, 4)
+	assert.Equal(statements[:2], statements[2:])
+	assert.Equal("INSERT INTO people (key, age, name) VALUES (?, ?, ?) 1,40,Alice", statements[1])
+}
+
+func TestChangeRowsNotStructs(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	ds := commitMap(db, db.GetDataset("ds"), types.String("a"), types.Number(1))
+	feed, err := datas.ChangeFeed(ds, hash.Hash{})
+	assert.NoError(err)
+	c, _ := feed.Next()
+	_, err = ChangeRows(c)
+	assert.Error(err)
+
+	ds, err = db.CommitValue(ds, types.Number(1))
+	assert.NoError(err)
+	_, err = SQLExporter{}.Export(ds, &memoryCheckpoint{h: c.Token()})
+	assert.Error(err)
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/metrics"
+	"github.com/attic-labs/noms/go/util/sizecache"
+)
+
+// chunkCache is a size-bounded cache of chunks, which expires the least
+// recently used ones first.
+type chunkCache interface {
+	get(h hash.Hash) (Chunk, bool)
+	has(h hash.Hash) bool
+	add(c Chunk)
+}
+
+// cachingStore is a ChunkStore which keeps the chunks read from the
+// ChunkStore it wraps in a cache, so that they're only read from it once.
+// Chunks never change, so only the root has to be read from it every time.
+type cachingStore struct {
+	ChunkStore
+	cache chunkCache
+}
+
+// NewMemoryCachingStore returns a ChunkStore which keeps up to size bytes of
+// the chunks read from cs in memory.
+func NewMemoryCachingStore(cs ChunkStore, size uint64) ChunkStore {
+	return cachingStore{cs, memoryChunkCache{sizecache.New(size)}}
+}
+
+// NewDiskCachingStore returns a ChunkStore which keeps up to size bytes of the
+// chunks read from cs in files in dir. The chunks in dir are kept when the
+// store is closed, to be used by the next store of cs with the same dir.
+func NewDiskCachingStore(cs ChunkStore, dir string, size uint64) ChunkStore {
+	return cachingStore{cs, newDiskChunkCache(dir, size)}
+}
+
+func (s cachingStore) Get(h hash.Hash) Chunk {
+	if c, ok := s.cache.get(h); ok {
+		metrics.Add("chunk_cache_hits", 1)
+		return c
+	}
+	metrics.Add("chunk_cache_misses", 1)
+	c := s.ChunkStore.Get(h)
+	if !c.IsEmpty() {
+		s.cache.add(c)
+	}
+	return c
+}
+
+func (s cachingStore) GetMany(hashes hash.HashSet, foundChunks chan *Chunk) {
+	remaining := hash.HashSet{}
+	for h := range hashes {
+		if c, ok := s.cache.get(h); ok {
+			foundChunks <- &c
+		} else {
+			remaining.Insert(h)
+		}
+	}
+	metrics.Add("chunk_cache_hits", int64(len(hashes)-len(remaining)))
+	metrics.Add("chunk_cache_misses", int64(len(remaining)))
+	if len(remaining) == 0 {
+		return
+	}
+
+	found := make(chan *Chunk, len(remaining))
+	go func() {
+		defer close(found)
+		s.ChunkStore.GetMany(remaining, found)
+	}()
+	for c := range found {
+		s.cache.add(*c)
+		foundChunks <- c
+	}
+}
+
+func (s cachingStore) Has(h hash.Hash) bool {
+	return s.cache.has(h) || s.ChunkStore.Has(h)
+}
+
+func (s cachingStore) HasMany(hashes hash.HashSet) hash.HashSet {
+	present, remaining := hash.HashSet{}, hash.HashSet{}
+	for h := range hashes {
+		if s.cache.has(h) {
+			present.Insert(h)
+		} else {
+			remaining.Insert(h)
+		}
+	}
+	if len(remaining) > 0 {
+		for h := range s.ChunkStore.HasMany(remaining) {
+			present.Insert(h)
+		}
+	}
+	return present
+}
+
+type memoryChunkCache struct {
+	cache *sizecache.SizeCache
+}
+
+func (mc memoryChunkCache) get(h hash.Hash) (Chunk, bool) {
+	if c, ok := mc.cache.Get(h); ok {
+		return c.(Chunk), true
+	}
+	return EmptyChunk, false
+}
+
+func (mc memoryChunkCache) has(h hash.Hash) bool {
+	_, ok := mc.cache.Get(h)
+	return ok
+}
+
+func (mc memoryChunkCache) add(c Chunk) {
+	mc.cache.Add(c.Hash(), uint64(len(c.Data())), c)
+}
+
+// diskChunkCache keeps each chunk in a file in dir named by its hash. Only
+// the hashes and sizes of the chunks are kept in memory.
+type diskChunkCache struct {
+	dir   string
+	size  uint64
+	cache *sizecache.SizeCache
+}
+
+func newDiskChunkCache(dir string, size uint64) *diskChunkCache {
+	d.PanicIfError(os.MkdirAll(dir, 0777))
+	dc := &diskChunkCache{dir: dir, size: size}
+	dc.cache = sizecache.NewWithExpireCallback(size, func(key interface{}) {
+		os.Remove(dc.path(key.(hash.Hash)))
+	})
+
+	// Add the chunks left by earlier stores oldest first, so that they're
+	// expired first.
+	infos, err := ioutil.ReadDir(dir)
+	d.PanicIfError(err)
+	sort.Sort(byModTime(infos))
+	for _, info := range infos {
+		if h, ok := hash.MaybeParse(info.Name()); ok {
+			if uint64(info.Size()) > size {
+				os.Remove(dc.path(h))
+				continue
+			}
+			dc.cache.Add(h, uint64(info.Size()), nil)
+		}
+	}
+	return dc
+}
+
+type byModTime []os.FileInfo
+
+func (s byModTime) Len() int           { return len(s) }
+func (s byModTime) Less(i, j int) bool { return s[i].ModTime().Before(s[j].ModTime()) }
+func (s byModTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (dc *diskChunkCache) path(h hash.Hash) string {
+	return filepath.Join(dc.dir, h.String())
+}
+
+func (dc *diskChunkCache) get(h hash.Hash) (Chunk, bool) {
+	if !dc.has(h) {
+		return EmptyChunk, false
+	}
+	data, err := ioutil.ReadFile(dc.path(h))
+	if err != nil {
+		// Another store with the same dir expired it.
+		dc.cache.Drop(h)
+		return EmptyChunk, false
+	}
+	return NewChunkWithHash(h, data), true
+}
+
+func (dc *diskChunkCache) has(h hash.Hash) bool {
+	_, ok := dc.cache.Get(h)
+	return ok
+}
+
+func (dc *diskChunkCache) add(c Chunk) {
+	size := uint64(len(c.Data()))
+	if size > dc.size || dc.has(c.Hash()) {
+		return
+	}
+
+	// Write the chunk to a temporary file first, so that other stores with the
+	// same dir never read part of it.
+	f, err := ioutil.TempFile(dc.dir, "tmp")
+	if err != nil {
+		return // the cache is only an optimization
+	}
+	_, err = f.Write(c.Data())
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), dc.path(c.Hash()))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	dc.cache.Add(c.Hash(), size, nil)
+}
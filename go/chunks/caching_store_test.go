@@ -0,0 +1,150 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/metrics"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestMemoryCachingStoreTestSuite(t *testing.T) {
+	suite.Run(t, &cachingStoreTestSuite{})
+}
+
+func TestDiskCachingStoreTestSuite(t *testing.T) {
+	suite.Run(t, &cachingStoreTestSuite{onDisk: true})
+}
+
+type cachingStoreTestSuite struct {
+	ChunkStoreTestSuite
+	onDisk bool
+	dir    string
+}
+
+func (suite *cachingStoreTestSuite) SetupTest() {
+	if suite.onDisk {
+		var err error
+		suite.dir, err = ioutil.TempDir("", "caching_store_test")
+		suite.NoError(err)
+		suite.Store = NewDiskCachingStore(NewMemoryStore(), suite.dir, 1<<20)
+	} else {
+		suite.Store = NewMemoryCachingStore(NewMemoryStore(), 1<<20)
+	}
+}
+
+func (suite *cachingStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+	os.RemoveAll(suite.dir)
+}
+
+func getMany(s ChunkStore, hashes ...hash.Hash) map[hash.Hash]string {
+	found := make(chan *Chunk, len(hashes))
+	s.GetMany(hash.NewHashSet(hashes...), found)
+	close(found)
+	data := map[hash.Hash]string{}
+	for c := range found {
+		data[c.Hash()] = string(c.Data())
+	}
+	return data
+}
+
+func TestCachingStoreReadsOnce(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "caching_store_test")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ts := NewTestStore()
+	c1, c2, c3 := NewChunk([]byte("abc")), NewChunk([]byte("def")), NewChunk([]byte("ghi"))
+	ts.PutMany([]Chunk{c1, c2, c3})
+
+	for _, s := range []ChunkStore{NewMemoryCachingStore(ts, 1<<20), NewDiskCachingStore(ts, dir, 1<<20)} {
+		ts.Reads = 0
+		assert.Equal("abc", string(s.Get(c1.Hash()).Data()))
+		assert.Equal("abc", string(s.Get(c1.Hash()).Data()))
+		assert.Equal(1, ts.Reads)
+
+		assert.Equal(map[hash.Hash]string{c1.Hash(): "abc", c2.Hash(): "def"}, getMany(s, c1.Hash(), c2.Hash()))
+		assert.Equal(2, ts.Reads)
+		assert.Equal("def", string(s.Get(c2.Hash()).Data()))
+		assert.Equal(2, ts.Reads)
+
+		ts.Hases = 0
+		assert.True(s.Has(c1.Hash()))
+		assert.Equal(hash.NewHashSet(c1.Hash(), c3.Hash()), s.HasMany(hash.NewHashSet(c1.Hash(), c3.Hash())))
+		assert.Equal(1, ts.Hases)
+
+		// Absent chunks aren't cached.
+		absent := NewChunk([]byte("jkl")).Hash()
+		assert.True(s.Get(absent).IsEmpty())
+		assert.True(s.Get(absent).IsEmpty())
+		assert.Equal(4, ts.Reads)
+	}
+
+	// The chunks on disk are read by the next store with the same dir.
+	ts.Reads = 0
+	s := NewDiskCachingStore(ts, dir, 1<<20)
+	assert.Equal("abc", string(s.Get(c1.Hash()).Data()))
+	assert.Equal(0, ts.Reads)
+}
+
+func TestDiskCachingStoreExpires(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "caching_store_test")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ms := NewMemoryStore()
+	c1, c2, c3 := NewChunk([]byte("abc")), NewChunk([]byte("def")), NewChunk([]byte("ghi"))
+	ms.PutMany([]Chunk{c1, c2, c3})
+
+	s := NewDiskCachingStore(ms, dir, 6)
+	s.Get(c1.Hash())
+	s.Get(c2.Hash())
+	s.Get(c3.Hash())
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	assert.Len(names, 2)
+	assert.NotContains(names, c1.Hash().String())
+
+	// A smaller cache of the same dir expires more of them, least recently
+	// written first.
+	now := time.Now()
+	assert.NoError(os.Chtimes(filepath.Join(dir, c2.Hash().String()), now, now.Add(-time.Minute)))
+	NewDiskCachingStore(ms, dir, 3)
+	infos, err = ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(infos, 1)
+	assert.Equal(c3.Hash().String(), infos[0].Name())
+}
+
+func TestCachingStoreMetrics(t *testing.T) {
+	assert := assert.New(t)
+	r := metrics.NewRegistry()
+	metrics.SetCollector(r)
+	defer metrics.SetCollector(nil)
+
+	ms := NewMemoryStore()
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	ms.PutMany([]Chunk{c1, c2})
+	s := NewMemoryCachingStore(ms, 1<<20)
+	s.Get(c1.Hash())
+	s.Get(c1.Hash())
+	getMany(s, c1.Hash(), c2.Hash())
+	assert.Equal(int64(2), r.Counter("chunk_cache_hits"))
+	assert.Equal(int64(2), r.Counter("chunk_cache_misses"))
+}
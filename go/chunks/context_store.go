@@ -0,0 +1,118 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"context"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// ContextChunkStore is a ChunkStore which can make its requests with a
+// context, so that they stop when it's canceled or its deadline passes.
+type ContextChunkStore interface {
+	ChunkStore
+	// WithContext returns a ChunkStore of the same chunks and root, whose
+	// methods panic with ctx.Err() once ctx is done, and stop any requests
+	// they're making then. Closing it doesn't close this store.
+	WithContext(ctx context.Context) ChunkStore
+}
+
+// WithContext returns a ChunkStore of the same chunks and root as cs, whose
+// methods panic with ctx.Err() once ctx is done, e.g. so that a program
+// handling a request stops reading and writing the store when the request is
+// canceled. Use the Try functions to get the error rather than a panic. If
+// cs is a ContextChunkStore, its requests stop then too, otherwise each
+// method finishes before the next one panics. Closing the returned store
+// doesn't close cs, so that a store can be made for each request.
+func WithContext(cs ChunkStore, ctx context.Context) ChunkStore {
+	if ccs, ok := cs.(ContextChunkStore); ok {
+		return ccs.WithContext(ctx)
+	}
+	return contextStore{cs, ctx}
+}
+
+type contextStore struct {
+	ChunkStore
+	ctx context.Context
+}
+
+func (s contextStore) check() {
+	d.PanicIfError(s.ctx.Err())
+}
+
+func (s contextStore) Get(h hash.Hash) Chunk {
+	s.check()
+	return s.ChunkStore.Get(h)
+}
+
+func (s contextStore) GetMany(hashes hash.HashSet, foundChunks chan *Chunk) {
+	s.check()
+	s.ChunkStore.GetMany(hashes, foundChunks)
+}
+
+func (s contextStore) Has(h hash.Hash) bool {
+	s.check()
+	return s.ChunkStore.Has(h)
+}
+
+func (s contextStore) HasMany(hashes hash.HashSet) hash.HashSet {
+	s.check()
+	return s.ChunkStore.HasMany(hashes)
+}
+
+func (s contextStore) Put(c Chunk) {
+	s.check()
+	s.ChunkStore.Put(c)
+}
+
+func (s contextStore) PutMany(chunks []Chunk) {
+	s.check()
+	s.ChunkStore.PutMany(chunks)
+}
+
+func (s contextStore) Flush() {
+	s.check()
+	s.ChunkStore.Flush()
+}
+
+func (s contextStore) Root() hash.Hash {
+	s.check()
+	return s.ChunkStore.Root()
+}
+
+func (s contextStore) Close() error {
+	return nil
+}
+
+func (s contextStore) UpdateRoot(current, last hash.Hash) bool {
+	s.check()
+	return s.ChunkStore.UpdateRoot(current, last)
+}
+
+// GetContext is like TryGet(cs, h), but reads cs with ctx, as WithContext
+// does, so that a single call returns ctx.Err() once ctx is done, without
+// affecting the other calls of cs.
+func GetContext(ctx context.Context, cs ChunkStore, h hash.Hash) (Chunk, error) {
+	return TryGet(WithContext(cs, ctx), h)
+}
+
+// HasContext is like TryHas(cs, h), but reads cs with ctx, as GetContext does.
+func HasContext(ctx context.Context, cs ChunkStore, h hash.Hash) (bool, error) {
+	return TryHas(WithContext(cs, ctx), h)
+}
+
+// PutContext is like TryPut(cs, c), but writes cs with ctx, as GetContext
+// does.
+func PutContext(ctx context.Context, cs ChunkStore, c Chunk) error {
+	return TryPut(WithContext(cs, ctx), c)
+}
+
+// UpdateRootContext is like TryUpdateRoot(cs, current, last), but updates
+// cs with ctx, as GetContext does.
+func UpdateRootContext(ctx context.Context, cs ChunkStore, current, last hash.Hash) (bool, error) {
+	return TryUpdateRoot(WithContext(cs, ctx), current, last)
+}
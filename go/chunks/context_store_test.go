@@ -0,0 +1,87 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestContextStoreTestSuite(t *testing.T) {
+	suite.Run(t, &contextStoreTestSuite{})
+}
+
+type contextStoreTestSuite struct {
+	ChunkStoreTestSuite
+}
+
+func (suite *contextStoreTestSuite) SetupTest() {
+	suite.Store = WithContext(NewMemoryStore(), context.Background())
+}
+
+func (suite *contextStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+type testContextStore struct {
+	*MemoryStore
+	ctx context.Context
+}
+
+func (s testContextStore) WithContext(ctx context.Context) ChunkStore {
+	return testContextStore{s.MemoryStore, ctx}
+}
+
+func TestContextStoreCanceled(t *testing.T) {
+	assert := assert.New(t)
+	ms := NewMemoryStore()
+	c := NewChunk([]byte("abc"))
+	ms.Put(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := WithContext(ms, ctx)
+	assert.Equal("abc", string(s.Get(c.Hash()).Data()))
+
+	cancel()
+	_, err := TryGet(s, c.Hash())
+	assert.Equal(context.Canceled, err)
+	assert.Equal(context.Canceled, TryPut(s, NewChunk([]byte("def"))))
+	_, err = TryUpdateRoot(s, c.Hash(), hash.Hash{})
+	assert.Equal(context.Canceled, err)
+	assert.True(ms.Root().IsEmpty())
+
+	// ContextChunkStores bind the context themselves.
+	tcs := WithContext(testContextStore{ms, context.Background()}, ctx)
+	assert.Equal(ctx, tcs.(testContextStore).ctx)
+}
+
+func TestGetContext(t *testing.T) {
+	assert := assert.New(t)
+	ms := NewMemoryStore()
+	c := NewChunk([]byte("abc"))
+	assert.NoError(PutContext(context.Background(), ms, c))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := GetContext(ctx, ms, c.Hash())
+	assert.Equal(context.Canceled, err)
+	_, err = HasContext(ctx, ms, c.Hash())
+	assert.Equal(context.Canceled, err)
+	assert.Equal(context.Canceled, PutContext(ctx, ms, NewChunk([]byte("def"))))
+	_, err = UpdateRootContext(ctx, ms, c.Hash(), hash.Hash{})
+	assert.Equal(context.Canceled, err)
+
+	// The other calls of ms aren't canceled.
+	got, err := GetContext(context.Background(), ms, c.Hash())
+	assert.NoError(err)
+	assert.Equal("abc", string(got.Data()))
+	ok, err := UpdateRootContext(context.Background(), ms, c.Hash(), hash.Hash{})
+	assert.NoError(err)
+	assert.True(ok)
+}
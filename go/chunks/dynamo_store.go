@@ -15,6 +15,7 @@ import (
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -375,14 +376,18 @@ func (s *DynamoStore) Close() error {
 
 	if s.showStats {
 		if s.readBatchCount > 0 {
-			fmt.Printf("Read batch count: %d, Read batch latency: %dms\n", s.readBatchCount, s.readTime/s.readBatchCount/1e6)
+			verbose.Info("Read batches", verbose.Fields{"count": s.readBatchCount, "latency": time.Duration(s.readTime / s.readBatchCount)})
 		}
 		if s.writeBatchCount > 0 {
-			fmt.Printf("Write batch count: %d, Write batch latency: %dms\n", s.writeBatchCount, uint64(s.writeTime)/s.writeBatchCount/1e6)
+			verbose.Info("Wrote batches", verbose.Fields{"count": s.writeBatchCount, "latency": time.Duration(uint64(s.writeTime) / s.writeBatchCount)})
 		}
 		if s.writeCount > 0 {
-			fmt.Printf("Write chunk count: %d, Avg chunk size: %.3fK\n", s.writeCount, float64(s.writeTotal)/float64(s.writeCount)/1024.0)
-			fmt.Printf("Avg compression ratio: %.2fx, Avg compressed chunk size: %.3fK\n", float64(s.writeTotal)/float64(s.writeCompTotal), float64(s.writeCompTotal)/float64(s.writeCount)/1024.0)
+			verbose.Info("Wrote chunks", verbose.Fields{
+				"count":           s.writeCount,
+				"avgKB":           float64(s.writeTotal) / float64(s.writeCount) / 1024.0,
+				"avgCompressedKB": float64(s.writeCompTotal) / float64(s.writeCount) / 1024.0,
+				"compression":     float64(s.writeTotal) / float64(s.writeCompTotal),
+			})
 		}
 	}
 	return nil
@@ -399,7 +404,7 @@ func (s *DynamoStore) Version() string {
 
 	itemLen := len(result.Item)
 	if itemLen == 0 {
-		return constants.NomsVersion
+		return constants.DataVersion()
 	}
 	if itemLen != 2 {
 		d.Panic("Version should have 2 attributes on it: %+v", result.Item)
@@ -414,7 +419,7 @@ func (s *DynamoStore) setVersIfUnset() {
 		TableName: aws.String(s.table),
 		Item: map[string]*dynamodb.AttributeValue{
 			refAttr: {B: s.versionKey},
-			numAttr: {S: aws.String(constants.NomsVersion)},
+			numAttr: {S: aws.String(constants.DataVersion())},
 		},
 		ConditionExpression: aws.String(valueNotExistsExpression),
 	}
@@ -37,7 +37,7 @@ func createFakeDDB(a *assert.Assertions) *fakeDDB {
 	return &fakeDDB{
 		data:    map[string]record{},
 		assert:  a,
-		version: constants.NomsVersion,
+		version: constants.DataVersion(),
 	}
 }
 
@@ -0,0 +1,174 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultIPFSAPI is the address of the HTTP API served by `ipfs daemon`.
+const DefaultIPFSAPI = "http://127.0.0.1:5001"
+
+// ipfsGetTimeout is how long to look for a block on the IPFS network before
+// deciding that nobody has it.
+const ipfsGetTimeout = 30 * time.Second
+
+var errIPFSNotFound = errors.New("ipfs: not found")
+
+// ipfssvc is the part of the API of an IPFS node that IPFSStore uses.
+type ipfssvc interface {
+	// BlockGet returns the data of the block with cid, from the node or the
+	// IPFS network, or errIPFSNotFound.
+	BlockGet(cid string) ([]byte, error)
+
+	// BlockHas returns whether the node itself has the block with cid.
+	BlockHas(cid string) (bool, error)
+
+	// BlockPut stores data as a raw block, pinned so that it isn't garbage
+	// collected, and returns its cid.
+	BlockPut(data []byte) (string, error)
+
+	// KeyList returns the IPNS names of the node's keys, by key name.
+	KeyList() (map[string]string, error)
+
+	// NamePublish points the IPNS name of key at path.
+	NamePublish(key, path string) error
+
+	// NameResolve returns the path that the IPNS name points at, or
+	// errIPFSNotFound if nothing was ever published to it.
+	NameResolve(name string) (string, error)
+}
+
+// ipfsClient is the ipfssvc of the IPFS node whose HTTP API is at api.
+type ipfsClient struct {
+	client *http.Client
+	api    string
+}
+
+type ipfsError struct {
+	cmd     string
+	message string
+}
+
+func (e ipfsError) Error() string {
+	return fmt.Sprintf("ipfs %s: %s", e.cmd, e.message)
+}
+
+// notFound returns errIPFSNotFound if err says that a block or name wasn't
+// found, and otherwise err.
+func notFound(err error) error {
+	if e, ok := err.(ipfsError); ok {
+		for _, s := range []string{"not found", "could not find", "could not resolve", "deadline exceeded"} {
+			if strings.Contains(e.message, s) {
+				return errIPFSNotFound
+			}
+		}
+	}
+	return err
+}
+
+func (c ipfsClient) BlockGet(cid string) ([]byte, error) {
+	var data []byte
+	err := c.call("block/get", url.Values{"arg": {cid}, "timeout": {ipfsGetTimeout.String()}}, nil, &data)
+	return data, notFound(err)
+}
+
+func (c ipfsClient) BlockHas(cid string) (bool, error) {
+	err := notFound(c.call("block/stat", url.Values{"arg": {cid}, "offline": {"true"}}, nil, nil))
+	if err == errIPFSNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c ipfsClient) BlockPut(data []byte) (string, error) {
+	var out struct{ Key string }
+	args := url.Values{"cid-codec": {"raw"}, "mhtype": {"sha2-512"}, "mhlen": {"20"}, "pin": {"true"}}
+	err := c.call("block/put", args, data, &out)
+	return out.Key, err
+}
+
+func (c ipfsClient) KeyList() (map[string]string, error) {
+	var out struct{ Keys []struct{ Name, Id string } }
+	if err := c.call("key/list", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	keys := map[string]string{}
+	for _, k := range out.Keys {
+		keys[k.Name] = k.Id
+	}
+	return keys, nil
+}
+
+func (c ipfsClient) NamePublish(key, path string) error {
+	args := url.Values{"arg": {path}, "key": {key}, "resolve": {"false"}, "allow-offline": {"true"}}
+	return c.call("name/publish", args, nil, nil)
+}
+
+func (c ipfsClient) NameResolve(name string) (string, error) {
+	var out struct{ Path string }
+	err := c.call("name/resolve", url.Values{"arg": {name}, "recursive": {"true"}}, nil, &out)
+	return out.Path, notFound(err)
+}
+
+// call POSTs the command cmd with args, and the file body if it isn't nil,
+// to the API. The response is read into out, which is either a *[]byte or
+// the JSON the command returns.
+func (c ipfsClient) call(cmd string, args url.Values, body []byte, out interface{}) error {
+	var r io.Reader
+	contentType := ""
+	if body != nil {
+		buff := &bytes.Buffer{}
+		mw := multipart.NewWriter(buff)
+		fw, err := mw.CreateFormFile("data", "data")
+		if err != nil {
+			return err
+		}
+		fw.Write(body)
+		mw.Close()
+		r, contentType = buff, mw.FormDataContentType()
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(c.api, "/")+"/api/v0/"+cmd+"?"+args.Encode(), r)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct{ Message string }
+		data, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+		}
+		return ipfsError{cmd, e.Message}
+	}
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out, err = ioutil.ReadAll(resp.Body)
+		return err
+	default:
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
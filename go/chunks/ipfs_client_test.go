@@ -0,0 +1,90 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+// ipfsAPIServer serves the commands of ipfs's HTTP API that ipfsClient
+// uses, from ipfs.
+func ipfsAPIServer(assert *assert.Assertions, ipfs *fakeIPFS) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("POST", r.Method)
+		q := r.URL.Query()
+		fail := func(err error) {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"Message": err.Error(), "Code": 0, "Type": "error"})
+		}
+		switch r.URL.Path {
+		case "/api/v0/block/get":
+			data, err := ipfs.BlockGet(q.Get("arg"))
+			if err != nil {
+				fail(err)
+				return
+			}
+			w.Write(data)
+		case "/api/v0/block/stat":
+			assert.Equal("true", q.Get("offline"))
+			if ok, _ := ipfs.BlockHas(q.Get("arg")); !ok {
+				fail(errIPFSNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Key": q.Get("arg")})
+		case "/api/v0/block/put":
+			assert.Equal("raw", q.Get("cid-codec"))
+			assert.Equal("sha2-512", q.Get("mhtype"))
+			assert.Equal("20", q.Get("mhlen"))
+			f, _, err := r.FormFile("data")
+			assert.NoError(err)
+			data, _ := ioutil.ReadAll(f)
+			cid, _ := ipfs.BlockPut(data)
+			json.NewEncoder(w).Encode(map[string]interface{}{"Key": cid, "Size": len(data)})
+		case "/api/v0/key/list":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Keys": []map[string]string{{"Name": "self", "Id": "k51self"}}})
+		case "/api/v0/name/publish":
+			ipfs.NamePublish(q.Get("key"), q.Get("arg"))
+			json.NewEncoder(w).Encode(map[string]string{"Name": "k51self", "Value": q.Get("arg")})
+		case "/api/v0/name/resolve":
+			path, err := ipfs.NameResolve(q.Get("arg"))
+			if err != nil {
+				fail(errIPFSNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"Path": path})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("404 page not found"))
+		}
+	}))
+}
+
+func TestIPFSClient(t *testing.T) {
+	assert := assert.New(t)
+	server := ipfsAPIServer(assert, newFakeIPFS())
+	defer server.Close()
+
+	store := NewIPFSStore(server.URL, "self")
+	assert.True(store.Root().IsEmpty())
+	c := NewChunk([]byte("abc"))
+	assert.False(store.Has(c.Hash()))
+	store.Put(c)
+	assert.True(store.UpdateRoot(c.Hash(), store.Root()))
+
+	store = NewIPFSStore(server.URL, "k51self")
+	assert.Equal(c.Hash(), store.Root())
+	assert.True(store.Has(c.Hash()))
+	assert.Equal(c.Data(), store.Get(c.Hash()).Data())
+	assert.True(store.Get(NewChunk([]byte("def")).Hash()).IsEmpty())
+
+	ic := ipfsClient{http.DefaultClient, server.URL}
+	assert.Error(ic.call("bogus", nil, nil, nil))
+}
@@ -0,0 +1,218 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// ipfsWriteParallelism is the number of blocks put to the IPFS node at once.
+const ipfsWriteParallelism = 16
+
+// The CIDs of chunks are v1 CIDs of raw blocks, whose multihash is the
+// chunk's hash: the first 20 bytes of its sha2-512. They're written in
+// lower case base32, with a "b" multibase prefix.
+var (
+	ipfsCIDPrefix   = []byte{0x01, 0x55, 0x13, hash.ByteLen} // version, raw codec, sha2-512, length
+	ipfsCIDEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+func ipfsCID(h hash.Hash) string {
+	return "b" + ipfsCIDEncoding.EncodeToString(append(append([]byte{}, ipfsCIDPrefix...), h[:]...))
+}
+
+func parseIPFSCID(cid string) (hash.Hash, bool) {
+	if !strings.HasPrefix(cid, "b") {
+		return hash.Hash{}, false
+	}
+	data, err := ipfsCIDEncoding.DecodeString(cid[1:])
+	if err != nil || len(data) != len(ipfsCIDPrefix)+hash.ByteLen || string(data[:len(ipfsCIDPrefix)]) != string(ipfsCIDPrefix) {
+		return hash.Hash{}, false
+	}
+	return hash.New(data[len(ipfsCIDPrefix):]), true
+}
+
+// IPFSStore implements ChunkStore by storing chunks as blocks in IPFS, via the
+// HTTP API of an IPFS node. The blocks are pinned on the node, and the root is
+// published to the IPNS name of one of the node's keys, so that the database
+// can be read by anyone on the IPFS network from that name.
+//
+// IPNS names can't be updated atomically, so only one process should write to
+// an IPFSStore at a time. Stores of IPNS names whose key the node doesn't have,
+// e.g. other peers' databases, are read-only.
+type IPFSStore struct {
+	ipfs     ipfssvc
+	name     string // the key or IPNS name the store is named by
+	ipnsName string
+	key      string // the key to publish the root with, if the node has one
+
+	mu            sync.Mutex // guards unwrittenPuts and the root
+	unwrittenPuts map[hash.Hash]Chunk
+}
+
+// NewIPFSStore returns the IPFSStore named name on the IPFS node whose HTTP
+// API is at api, e.g. DefaultIPFSAPI. If name is one of the node's keys, e.g.
+// "self", the root is published with it. Otherwise name is an IPNS name, such
+// as a peer ID, whose database is read-only.
+func NewIPFSStore(api, name string) *IPFSStore {
+	return newIPFSStore(name, ipfsClient{http.DefaultClient, api})
+}
+
+func newIPFSStore(name string, ipfs ipfssvc) *IPFSStore {
+	keys, err := ipfs.KeyList()
+	d.PanicIfError(err)
+	s := &IPFSStore{ipfs: ipfs, name: name, ipnsName: name, unwrittenPuts: map[hash.Hash]Chunk{}}
+	if id, ok := keys[name]; ok {
+		s.ipnsName, s.key = id, name
+	}
+	return s
+}
+
+func (s *IPFSStore) Get(h hash.Hash) Chunk {
+	s.mu.Lock()
+	c, ok := s.unwrittenPuts[h]
+	s.mu.Unlock()
+	if ok {
+		return c
+	}
+
+	data, err := s.ipfs.BlockGet(ipfsCID(h))
+	if err == errIPFSNotFound {
+		return EmptyChunk
+	}
+	d.PanicIfError(err)
+	return NewChunkWithHash(h, data)
+}
+
+func (s *IPFSStore) GetMany(hashes hash.HashSet, foundChunks chan *Chunk) {
+	for h := range hashes {
+		c := s.Get(h)
+		if !c.IsEmpty() {
+			foundChunks <- &c
+		}
+	}
+}
+
+// Has returns whether the IPFS node has the chunk with hash h. Chunks which
+// are only elsewhere on the IPFS network aren't looked for.
+func (s *IPFSStore) Has(h hash.Hash) bool {
+	s.mu.Lock()
+	_, ok := s.unwrittenPuts[h]
+	s.mu.Unlock()
+	if ok {
+		return true
+	}
+
+	has, err := s.ipfs.BlockHas(ipfsCID(h))
+	d.PanicIfError(err)
+	return has
+}
+
+func (s *IPFSStore) HasMany(hashes hash.HashSet) hash.HashSet {
+	present := hash.HashSet{}
+	for h := range hashes {
+		if s.Has(h) {
+			present.Insert(h)
+		}
+	}
+	return present
+}
+
+func (s *IPFSStore) Version() string {
+	return constants.DataVersion()
+}
+
+// Put buffers c, which is put to the IPFS node by the next Flush or
+// UpdateRoot.
+func (s *IPFSStore) Put(c Chunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unwrittenPuts[c.Hash()] = c
+}
+
+func (s *IPFSStore) PutMany(chunks []Chunk) {
+	for _, c := range chunks {
+		s.Put(c)
+	}
+}
+
+func (s *IPFSStore) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush()
+}
+
+func (s *IPFSStore) flush() {
+	puts := make(chan Chunk, len(s.unwrittenPuts))
+	for _, c := range s.unwrittenPuts {
+		puts <- c
+	}
+	close(puts)
+
+	errs := make(chan error, ipfsWriteParallelism)
+	wg := sync.WaitGroup{}
+	for i := 0; i < ipfsWriteParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range puts {
+				cid, err := s.ipfs.BlockPut(c.Data())
+				if err == nil && cid != ipfsCID(c.Hash()) {
+					err = fmt.Errorf("IPFS stored chunk %s as %s, not %s", c.Hash(), cid, ipfsCID(c.Hash()))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	d.PanicIfError(<-errs)
+	s.unwrittenPuts = map[hash.Hash]Chunk{}
+}
+
+func (s *IPFSStore) Close() error {
+	return nil
+}
+
+func (s *IPFSStore) Root() hash.Hash {
+	path, err := s.ipfs.NameResolve(s.ipnsName)
+	if err == errIPFSNotFound {
+		return hash.Hash{}
+	}
+	d.PanicIfError(err)
+	root, ok := parseIPFSCID(strings.TrimPrefix(path, "/ipfs/"))
+	if !ok {
+		d.Panic("IPNS name %s points at %s, which isn't a noms root", s.ipnsName, path)
+	}
+	return root
+}
+
+// UpdateRoot puts any buffered chunks to the IPFS node, then publishes current
+// as the root if the root is still last.
+func (s *IPFSStore) UpdateRoot(current, last hash.Hash) bool {
+	if s.key == "" {
+		d.Panic("ipfs://%s is read-only, the IPFS node has no key named %s", s.name, s.name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush()
+	if s.Root() != last {
+		return false
+	}
+	d.PanicIfError(s.ipfs.NamePublish(s.key, "/ipfs/"+ipfsCID(current)))
+	return true
+}
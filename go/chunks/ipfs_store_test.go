@@ -0,0 +1,138 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+// fakeIPFS is an ipfssvc of a node with the key "self", which keeps its
+// blocks and IPNS names in memory.
+type fakeIPFS struct {
+	mu      sync.Mutex
+	blocks  map[string][]byte
+	names   map[string]string
+	numPuts int
+}
+
+func newFakeIPFS() *fakeIPFS {
+	return &fakeIPFS{blocks: map[string][]byte{}, names: map[string]string{}}
+}
+
+func (f *fakeIPFS) BlockGet(cid string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if data, ok := f.blocks[cid]; ok {
+		return data, nil
+	}
+	return nil, errIPFSNotFound
+}
+
+func (f *fakeIPFS) BlockHas(cid string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.blocks[cid]
+	return ok, nil
+}
+
+func (f *fakeIPFS) BlockPut(data []byte) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cid := ipfsCID(hash.Of(data))
+	f.blocks[cid] = data
+	f.numPuts++
+	return cid, nil
+}
+
+func (f *fakeIPFS) KeyList() (map[string]string, error) {
+	return map[string]string{"self": "k51self"}, nil
+}
+
+func (f *fakeIPFS) NamePublish(key, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys, _ := f.KeyList()
+	f.names[keys[key]] = path
+	return nil
+}
+
+func (f *fakeIPFS) NameResolve(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if path, ok := f.names[name]; ok {
+		return path, nil
+	}
+	return "", errIPFSNotFound
+}
+
+func TestIPFSStoreTestSuite(t *testing.T) {
+	suite.Run(t, &IPFSStoreTestSuite{})
+}
+
+type IPFSStoreTestSuite struct {
+	ChunkStoreTestSuite
+	ipfs *fakeIPFS
+}
+
+func (suite *IPFSStoreTestSuite) SetupTest() {
+	suite.ipfs = newFakeIPFS()
+	suite.Store = newIPFSStore("self", suite.ipfs)
+	suite.putCountFn = func() int {
+		return suite.ipfs.numPuts
+	}
+}
+
+func (suite *IPFSStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+func (suite *IPFSStoreTestSuite) TestUnflushedChunks() {
+	c := NewChunk([]byte("abc"))
+	suite.Store.Put(c)
+	suite.True(suite.Store.Has(c.Hash()))
+	suite.Equal(c.Data(), suite.Store.Get(c.Hash()).Data())
+	suite.Equal(0, suite.ipfs.numPuts)
+
+	suite.Store.Flush()
+	suite.Equal(1, suite.ipfs.numPuts)
+	suite.True(suite.Store.Has(c.Hash()))
+}
+
+func (suite *IPFSStoreTestSuite) TestRootPublished() {
+	c := NewChunk([]byte("abc"))
+	suite.Store.Put(c)
+	suite.True(suite.Store.UpdateRoot(c.Hash(), hash.Hash{}))
+	suite.Equal("/ipfs/"+ipfsCID(c.Hash()), suite.ipfs.names["k51self"])
+
+	// Another store of the same name reads the root, and its chunks.
+	other := newIPFSStore("k51self", suite.ipfs)
+	suite.Equal(c.Hash(), other.Root())
+	suite.Equal(c.Data(), other.Get(c.Hash()).Data())
+
+	// The node has no key to publish to that name with, though.
+	suite.Panics(func() { other.UpdateRoot(hash.Hash{}, c.Hash()) })
+}
+
+func TestIPFSCID(t *testing.T) {
+	assert := assert.New(t)
+	h := hash.Of([]byte("abc"))
+	cid := ipfsCID(h)
+	// The prefix of v1 raw CIDs whose multihash is a 20 byte sha2-512.
+	assert.Equal("bafkrg", cid[:6])
+
+	h2, ok := parseIPFSCID(cid)
+	assert.True(ok)
+	assert.Equal(h, h2)
+
+	for _, cid := range []string{"", "b", "Qmfoo", "bafkreifoo", cid[:len(cid)-1]} {
+		_, ok := parseIPFSCID(cid)
+		assert.False(ok, cid)
+	}
+}
@@ -63,7 +63,7 @@ func (ms *MemoryStore) HasMany(hashes hash.HashSet) hash.HashSet {
 }
 
 func (ms *MemoryStore) Version() string {
-	return constants.NomsVersion
+	return constants.DataVersion()
 }
 
 func (ms *MemoryStore) Put(c Chunk) {
@@ -81,6 +81,18 @@ func (ms *MemoryStore) PutMany(chunks []Chunk) {
 	}
 }
 
+func (ms *MemoryStore) Root() hash.Hash {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.memoryRootTracker.Root()
+}
+
+func (ms *MemoryStore) UpdateRoot(current, last hash.Hash) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.memoryRootTracker.UpdateRoot(current, last)
+}
+
 func (ms *MemoryStore) Len() int {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
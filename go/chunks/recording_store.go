@@ -0,0 +1,149 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// AccessRecord is a record of a chunk read from a RecordingStore.
+type AccessRecord struct {
+	Time time.Time
+	Hash hash.Hash
+	// Op is the operation which read the chunk, see SetOperation.
+	Op string
+}
+
+// String returns the line of r in an access log: the time, the hash and the
+// operation, separated by tabs.
+func (r AccessRecord) String() string {
+	return fmt.Sprintf("%s\t%s\t%s", r.Time.UTC().Format(time.RFC3339Nano), r.Hash, r.Op)
+}
+
+// ParseAccessRecord parses a line of an access log, as written by String.
+func ParseAccessRecord(line string) (AccessRecord, error) {
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) != 3 {
+		return AccessRecord{}, fmt.Errorf("Invalid access record: %s", line)
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return AccessRecord{}, fmt.Errorf("Invalid time in access record: %s", line)
+	}
+	h, ok := hash.MaybeParse(parts[1])
+	if !ok {
+		return AccessRecord{}, fmt.Errorf("Invalid hash in access record: %s", line)
+	}
+	return AccessRecord{t, h, parts[2]}, nil
+}
+
+// ReadAccessLog calls cb with each record of the access log read from r.
+func ReadAccessLog(r io.Reader, cb func(rec AccessRecord)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		rec, err := ParseAccessRecord(scanner.Text())
+		if err != nil {
+			return err
+		}
+		cb(rec)
+	}
+	return scanner.Err()
+}
+
+// RecordingStore is a ChunkStore which writes an access log, a record of each
+// chunk read from the ChunkStore it wraps, for finding the chunks which are
+// read the most, and the operations which read them. Chunks which aren't
+// found aren't recorded.
+type RecordingStore struct {
+	ChunkStore
+	mu sync.Mutex
+	w  *bufio.Writer
+	c  io.Closer
+	op string
+}
+
+// NewRecordingStore returns a RecordingStore which writes the access log of
+// cs to w, attributing the chunks read to the operation op. If w is an
+// io.Closer, it's closed when the store is.
+func NewRecordingStore(cs ChunkStore, w io.Writer, op string) *RecordingStore {
+	s := &RecordingStore{ChunkStore: cs, w: bufio.NewWriter(w)}
+	s.c, _ = w.(io.Closer)
+	s.SetOperation(op)
+	return s
+}
+
+// SetOperation attributes the chunks read from now on to op, e.g. the name of
+// a request being handled. Tabs and newlines in op are replaced by spaces.
+func (s *RecordingStore) SetOperation(op string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.op = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, op)
+}
+
+func (s *RecordingStore) record(h hash.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintln(s.w, AccessRecord{time.Now(), h, s.op})
+	d.PanicIfError(err)
+}
+
+func (s *RecordingStore) Get(h hash.Hash) Chunk {
+	c := s.ChunkStore.Get(h)
+	if !c.IsEmpty() {
+		s.record(h)
+	}
+	return c
+}
+
+func (s *RecordingStore) GetMany(hashes hash.HashSet, foundChunks chan *Chunk) {
+	found := make(chan *Chunk, len(hashes))
+	go func() {
+		defer close(found)
+		s.ChunkStore.GetMany(hashes, found)
+	}()
+	for c := range found {
+		s.record(c.Hash())
+		foundChunks <- c
+	}
+}
+
+// Flush writes the access log, as well as flushing the ChunkStore it wraps.
+func (s *RecordingStore) Flush() {
+	s.ChunkStore.Flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.PanicIfError(s.w.Flush())
+}
+
+func (s *RecordingStore) Close() error {
+	err := s.ChunkStore.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ferr := s.w.Flush(); err == nil {
+		err = ferr
+	}
+	if s.c != nil {
+		if cerr := s.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
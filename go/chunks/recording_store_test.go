@@ -0,0 +1,70 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestRecordingStoreTestSuite(t *testing.T) {
+	suite.Run(t, &recordingStoreTestSuite{})
+}
+
+type recordingStoreTestSuite struct {
+	ChunkStoreTestSuite
+}
+
+func (suite *recordingStoreTestSuite) SetupTest() {
+	suite.Store = NewRecordingStore(NewMemoryStore(), &bytes.Buffer{}, "test")
+}
+
+func (suite *recordingStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+func TestRecordingStore(t *testing.T) {
+	assert := assert.New(t)
+	buf := &bytes.Buffer{}
+	s := NewRecordingStore(NewMemoryStore(), buf, "first op")
+	a, b := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	s.PutMany([]Chunk{a, b})
+
+	start := time.Now()
+	s.Get(a.Hash())
+	s.Get(hash.Of([]byte("missing")))
+	s.SetOperation("second\top\n")
+	getMany(s, b.Hash(), hash.Of([]byte("missing")))
+	s.Get(a.Hash())
+	s.Flush()
+
+	records := []AccessRecord{}
+	assert.NoError(ReadAccessLog(buf, func(rec AccessRecord) {
+		records = append(records, rec)
+	}))
+	if assert.Len(records, 3) {
+		assert.Equal(a.Hash(), records[0].Hash)
+		assert.Equal("first op", records[0].Op)
+		assert.Equal(b.Hash(), records[1].Hash)
+		assert.Equal("second op ", records[1].Op)
+		assert.Equal(a.Hash(), records[2].Hash)
+		for _, rec := range records {
+			assert.False(rec.Time.Before(start.Truncate(time.Second)))
+		}
+	}
+
+	_, err := ParseAccessRecord("2017-01-01T00:00:00Z\tnothash\top")
+	assert.Error(err)
+	_, err = ParseAccessRecord("nottime\t" + a.Hash().String() + "\top")
+	assert.Error(err)
+	rec, err := ParseAccessRecord("2017-01-01T00:00:00Z\t" + a.Hash().String() + "\t")
+	assert.NoError(err)
+	assert.Equal("", rec.Op)
+}
@@ -0,0 +1,261 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/golang/snappy"
+)
+
+/*
+  Chunk Stream, version 1, see doc/chunk-stream.md:
+    Header
+    Record 0
+     ..
+    Record N
+    Trailer
+
+  Header:
+    Magic        // StreamMagic
+    Version      // 1-byte StreamVersion
+    DataLen      // 1-byte length of DataVersion
+    DataVersion  // the data version of the chunks, e.g. "7.9"
+
+  Record:
+    Kind         // 1-byte streamChunk
+    Hash         // 20-byte hash of the chunk
+    Len          // 4-byte length of Data
+    Data         // snappy-compressed data of the chunk
+
+  Trailer:
+    Kind         // 1-byte streamTrailer
+    Count        // 8-byte number of records
+    RootCount    // 4-byte number of roots
+    Roots        // 20-byte hashes of the roots
+    Magic        // StreamMagic
+
+  All integers are big-endian.
+*/
+
+const (
+	// StreamMagic starts and ends chunk streams.
+	StreamMagic = "NOMSCHNK"
+	// StreamVersion is the version of the format of the chunk streams
+	// written by StreamWriter.
+	StreamVersion = 1
+
+	streamChunk   = 1
+	streamTrailer = 2
+)
+
+var ErrStreamTruncated = errors.New("Chunk stream ends before its trailer")
+
+// StreamWriter writes chunks to a chunk stream. The chunks may be written in
+// any order, and the stream isn't complete until Close writes its trailer.
+type StreamWriter struct {
+	w     *bufio.Writer
+	buff  []byte
+	count uint64
+}
+
+// NewStreamWriter writes the header of a chunk stream of chunks of the
+// current data version to w, and returns a StreamWriter which writes the
+// rest.
+func NewStreamWriter(w io.Writer) (*StreamWriter, error) {
+	sw := &StreamWriter{w: bufio.NewWriter(w)}
+	version := constants.DataVersion()
+	sw.w.WriteString(StreamMagic)
+	sw.w.WriteByte(StreamVersion)
+	sw.w.WriteByte(byte(len(version)))
+	if _, err := sw.w.WriteString(version); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Write writes c to the stream.
+func (sw *StreamWriter) Write(c Chunk) error {
+	sw.buff = snappy.Encode(sw.buff[:cap(sw.buff)], c.Data())
+	h := c.Hash()
+	sw.w.WriteByte(streamChunk)
+	sw.w.Write(h[:])
+	binary.Write(sw.w, binary.BigEndian, uint32(len(sw.buff)))
+	if _, err := sw.w.Write(sw.buff); err != nil {
+		return err
+	}
+	sw.count++
+	return nil
+}
+
+// Close writes the trailer of the stream, which records roots, the hashes of
+// the chunks the chunks in the stream are reachable from, and flushes it. It
+// doesn't close the underlying io.Writer.
+func (sw *StreamWriter) Close(roots ...hash.Hash) error {
+	sw.w.WriteByte(streamTrailer)
+	binary.Write(sw.w, binary.BigEndian, sw.count)
+	binary.Write(sw.w, binary.BigEndian, uint32(len(roots)))
+	for _, r := range roots {
+		sw.w.Write(r[:])
+	}
+	sw.w.WriteString(StreamMagic)
+	return sw.w.Flush()
+}
+
+// StreamReader reads the chunks of a chunk stream.
+type StreamReader struct {
+	r           *bufio.Reader
+	dataVersion string
+	count       uint64
+	roots       []hash.Hash
+	done        bool
+}
+
+// NewStreamReader reads the header of the chunk stream in r, and returns a
+// StreamReader which reads the rest.
+func NewStreamReader(r io.Reader) (*StreamReader, error) {
+	sr := &StreamReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(StreamMagic)+2)
+	if _, err := io.ReadFull(sr.r, header); err != nil {
+		return nil, fmt.Errorf("Not a chunk stream: %s", err)
+	}
+	if string(header[:len(StreamMagic)]) != StreamMagic {
+		return nil, errors.New("Not a chunk stream: bad magic")
+	}
+	if v := header[len(StreamMagic)]; v != StreamVersion {
+		return nil, fmt.Errorf("Unsupported chunk stream version %d, expected %d", v, StreamVersion)
+	}
+	version := make([]byte, header[len(StreamMagic)+1])
+	if _, err := io.ReadFull(sr.r, version); err != nil {
+		return nil, streamError(err)
+	}
+	sr.dataVersion = string(version)
+	return sr, nil
+}
+
+// DataVersion returns the data version of the chunks in the stream, which is
+// constants.DataVersion() of the process which wrote it.
+func (sr *StreamReader) DataVersion() string {
+	return sr.dataVersion
+}
+
+// Next returns the next chunk in the stream, and io.EOF once the trailer has
+// been read. The hash of each chunk is checked against the one recorded for
+// it.
+func (sr *StreamReader) Next() (Chunk, error) {
+	if sr.done {
+		return EmptyChunk, io.EOF
+	}
+	kind, err := sr.r.ReadByte()
+	if err != nil {
+		return EmptyChunk, streamError(err)
+	}
+	switch kind {
+	case streamChunk:
+		return sr.readChunk()
+	case streamTrailer:
+		if err := sr.readTrailer(); err != nil {
+			return EmptyChunk, err
+		}
+		sr.done = true
+		return EmptyChunk, io.EOF
+	}
+	return EmptyChunk, fmt.Errorf("Invalid chunk stream record kind %d", kind)
+}
+
+func (sr *StreamReader) readChunk() (Chunk, error) {
+	h := hash.Hash{}
+	var n uint32
+	if _, err := io.ReadFull(sr.r, h[:]); err != nil {
+		return EmptyChunk, streamError(err)
+	}
+	if err := binary.Read(sr.r, binary.BigEndian, &n); err != nil {
+		return EmptyChunk, streamError(err)
+	}
+	compressed := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, compressed); err != nil {
+		return EmptyChunk, streamError(err)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return EmptyChunk, fmt.Errorf("Invalid data of chunk %s: %s", h, err)
+	} else if data == nil {
+		data = []byte{}
+	}
+	c := NewChunk(data)
+	if c.Hash() != h {
+		return EmptyChunk, fmt.Errorf("Data of chunk %s has hash %s", h, c.Hash())
+	}
+	sr.count++
+	return c, nil
+}
+
+func (sr *StreamReader) readTrailer() error {
+	var count uint64
+	var n uint32
+	if err := binary.Read(sr.r, binary.BigEndian, &count); err != nil {
+		return streamError(err)
+	}
+	if err := binary.Read(sr.r, binary.BigEndian, &n); err != nil {
+		return streamError(err)
+	}
+	roots := make([]hash.Hash, n)
+	for i := range roots {
+		if _, err := io.ReadFull(sr.r, roots[i][:]); err != nil {
+			return streamError(err)
+		}
+	}
+	magic := make([]byte, len(StreamMagic))
+	if _, err := io.ReadFull(sr.r, magic); err != nil {
+		return streamError(err)
+	}
+	if string(magic) != StreamMagic {
+		return errors.New("Invalid chunk stream trailer: bad magic")
+	}
+	if count != sr.count {
+		return fmt.Errorf("Chunk stream has %d chunks, but its trailer records %d", sr.count, count)
+	}
+	sr.roots = roots
+	return nil
+}
+
+// Roots returns the hashes of the roots recorded in the trailer of the
+// stream, once Next has returned io.EOF.
+func (sr *StreamReader) Roots() []hash.Hash {
+	return sr.roots
+}
+
+// ReadStream reads the chunk stream in r, calling cb with each of its chunks,
+// and returns its roots.
+func ReadStream(r io.Reader, cb func(c Chunk) error) ([]hash.Hash, error) {
+	sr, err := NewStreamReader(r)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		c, err := sr.Next()
+		if err == io.EOF {
+			return sr.Roots(), nil
+		} else if err != nil {
+			return nil, err
+		}
+		if err := cb(c); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func streamError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrStreamTruncated
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/golang/snappy"
+)
+
+func writeTestStream(assert *assert.Assertions, chnx []Chunk, roots ...hash.Hash) []byte {
+	buf := &bytes.Buffer{}
+	sw, err := NewStreamWriter(buf)
+	assert.NoError(err)
+	for _, c := range chnx {
+		assert.NoError(sw.Write(c))
+	}
+	assert.NoError(sw.Close(roots...))
+	return buf.Bytes()
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	chnx := []Chunk{NewChunk([]byte("abc")), NewChunk([]byte(strings.Repeat("def", 1000))), NewChunk([]byte{})}
+	roots := []hash.Hash{chnx[0].Hash(), chnx[1].Hash()}
+	data := writeTestStream(assert, chnx, roots...)
+	// The repeated chunk is compressed.
+	assert.True(len(data) < 1000)
+
+	sr, err := NewStreamReader(bytes.NewReader(data))
+	assert.NoError(err)
+	assert.Equal(constants.DataVersion(), sr.DataVersion())
+	for _, expected := range chnx {
+		c, err := sr.Next()
+		assert.NoError(err)
+		assert.Equal(expected.Hash(), c.Hash())
+		assert.Equal(expected.Data(), c.Data())
+	}
+	assert.Nil(sr.Roots())
+	_, err = sr.Next()
+	assert.Equal(io.EOF, err)
+	assert.Equal(roots, sr.Roots())
+	_, err = sr.Next()
+	assert.Equal(io.EOF, err)
+
+	n := 0
+	read, err := ReadStream(bytes.NewReader(data), func(c Chunk) error {
+		n++
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(roots, read)
+	assert.Equal(len(chnx), n)
+
+	// A stream with no chunks or roots.
+	read, err = ReadStream(bytes.NewReader(writeTestStream(assert, nil)), func(c Chunk) error {
+		assert.Fail("no chunks expected")
+		return nil
+	})
+	assert.NoError(err)
+	assert.Len(read, 0)
+}
+
+// TestStreamFormat writes a stream by hand, as documented in
+// doc/chunk-stream.md, so that the format doesn't change by accident.
+func TestStreamFormat(t *testing.T) {
+	assert := assert.New(t)
+	c := NewChunk([]byte("abc"))
+	h := c.Hash()
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("NOMSCHNK")
+	buf.WriteByte(1)
+	buf.WriteByte(byte(len(constants.DataVersion())))
+	buf.WriteString(constants.DataVersion())
+	compressed := snappy.Encode(nil, c.Data())
+	buf.WriteByte(1)
+	buf.Write(h[:])
+	binary.Write(buf, binary.BigEndian, uint32(len(compressed)))
+	buf.Write(compressed)
+	buf.WriteByte(2)
+	binary.Write(buf, binary.BigEndian, uint64(1))
+	binary.Write(buf, binary.BigEndian, uint32(1))
+	buf.Write(h[:])
+	buf.WriteString("NOMSCHNK")
+
+	assert.Equal(buf.Bytes(), writeTestStream(assert, []Chunk{c}, h))
+}
+
+func TestStreamErrors(t *testing.T) {
+	assert := assert.New(t)
+	chnx := []Chunk{NewChunk([]byte("abc")), NewChunk([]byte("def"))}
+	data := writeTestStream(assert, chnx, chnx[1].Hash())
+	read := func(data []byte) error {
+		_, err := ReadStream(bytes.NewReader(data), func(c Chunk) error { return nil })
+		return err
+	}
+	assert.NoError(read(data))
+
+	// Truncated anywhere, the stream is rejected.
+	for i := 0; i < len(data); i++ {
+		assert.Error(read(data[:i]), "truncated at %d", i)
+	}
+	assert.Equal(ErrStreamTruncated, read(data[:len(data)-1]))
+
+	corrupt := func(i int) []byte {
+		c := append([]byte{}, data...)
+		c[i] ^= 0xff
+		return c
+	}
+	assert.Error(read(corrupt(0)))
+	// The version.
+	assert.Error(read(corrupt(len(StreamMagic))))
+	// The kind of the first record.
+	headerLen := len(StreamMagic) + 2 + len(constants.DataVersion())
+	assert.Error(read(corrupt(headerLen)))
+	// The hash of the first chunk.
+	assert.Error(read(corrupt(headerLen + 1)))
+	// The data of the first chunk.
+	assert.Error(read(corrupt(headerLen + 1 + hash.ByteLen + 4 + 1)))
+	// The count of the trailer.
+	assert.Error(read(corrupt(len(data) - len(StreamMagic) - hash.ByteLen - 4 - 1)))
+	// The closing magic.
+	assert.Error(read(corrupt(len(data) - 1)))
+
+	// Errors from the callback are returned.
+	_, err := ReadStream(bytes.NewReader(data), func(c Chunk) error { return io.ErrShortWrite })
+	assert.Equal(io.ErrShortWrite, err)
+}
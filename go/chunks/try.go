@@ -0,0 +1,54 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// The Try functions are like the ChunkStore methods they call, but return the
+// errors that ChunkStores panic with, e.g. when a request to a remote store
+// fails, so that long-lived programs can handle them. Every panic is
+// returned as an error, see d.Recover, including failed assertions.
+// nomserrors.Class returns the class of the errors, e.g. nomserrors.ErrNetwork.
+
+// TryGet is like cs.Get(h).
+func TryGet(cs ChunkSource, h hash.Hash) (c Chunk, err error) {
+	defer d.Recover(&err)
+	return cs.Get(h), nil
+}
+
+// TryHas is like cs.Has(h).
+func TryHas(cs ChunkSource, h hash.Hash) (has bool, err error) {
+	defer d.Recover(&err)
+	return cs.Has(h), nil
+}
+
+// TryPut is like cs.Put(c).
+func TryPut(cs ChunkSink, c Chunk) (err error) {
+	defer d.Recover(&err)
+	cs.Put(c)
+	return nil
+}
+
+// TryFlush is like cs.Flush().
+func TryFlush(cs ChunkSink) (err error) {
+	defer d.Recover(&err)
+	cs.Flush()
+	return nil
+}
+
+// TryRoot is like rt.Root().
+func TryRoot(rt RootTracker) (root hash.Hash, err error) {
+	defer d.Recover(&err)
+	return rt.Root(), nil
+}
+
+// TryUpdateRoot is like rt.UpdateRoot(current, last).
+func TryUpdateRoot(rt RootTracker, current, last hash.Hash) (ok bool, err error) {
+	defer d.Recover(&err)
+	return rt.UpdateRoot(current, last), nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+var errTestStore = errors.New("test store failed")
+
+// failingStore is a MemoryStore whose methods panic with errTestStore once
+// failing is set, as ChunkStores do when they can't be read or written.
+type failingStore struct {
+	MemoryStore
+	failing bool
+}
+
+func (s *failingStore) fail() {
+	if s.failing {
+		d.PanicIfError(errTestStore)
+	}
+}
+
+func (s *failingStore) Get(h hash.Hash) Chunk { s.fail(); return s.MemoryStore.Get(h) }
+func (s *failingStore) Has(h hash.Hash) bool  { s.fail(); return s.MemoryStore.Has(h) }
+func (s *failingStore) Put(c Chunk)           { s.fail(); s.MemoryStore.Put(c) }
+func (s *failingStore) Flush()                { s.fail() }
+func (s *failingStore) Root() hash.Hash       { s.fail(); return s.MemoryStore.Root() }
+func (s *failingStore) UpdateRoot(current, last hash.Hash) bool {
+	s.fail()
+	return s.MemoryStore.UpdateRoot(current, last)
+}
+
+func TestTry(t *testing.T) {
+	assert := assert.New(t)
+	s := &failingStore{}
+	c := NewChunk([]byte("abc"))
+
+	assert.NoError(TryPut(s, c))
+	assert.NoError(TryFlush(s))
+	got, err := TryGet(s, c.Hash())
+	assert.NoError(err)
+	assert.Equal(c.Data(), got.Data())
+	has, err := TryHas(s, c.Hash())
+	assert.NoError(err)
+	assert.True(has)
+	ok, err := TryUpdateRoot(s, c.Hash(), hash.Hash{})
+	assert.NoError(err)
+	assert.True(ok)
+	root, err := TryRoot(s)
+	assert.NoError(err)
+	assert.Equal(c.Hash(), root)
+
+	s.failing = true
+	assert.Equal(errTestStore, TryPut(s, c))
+	assert.Equal(errTestStore, TryFlush(s))
+	_, err = TryGet(s, c.Hash())
+	assert.Equal(errTestStore, err)
+	_, err = TryHas(s, c.Hash())
+	assert.Equal(errTestStore, err)
+	_, err = TryUpdateRoot(s, hash.Hash{}, c.Hash())
+	assert.Equal(errTestStore, err)
+	_, err = TryRoot(s)
+	assert.Equal(errTestStore, err)
+}
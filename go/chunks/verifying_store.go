@@ -0,0 +1,57 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// NewVerifyingStore returns a ChunkStore of the same chunks and root as cs,
+// which hashes the data of each chunk it reads, and panics if it doesn't
+// match the hash the chunk was read by, e.g. because the data was corrupted
+// in storage. Use the Try functions to get the error rather than a panic.
+// Closing the returned store closes cs.
+func NewVerifyingStore(cs ChunkStore) ChunkStore {
+	return verifyingStore{cs}
+}
+
+type verifyingStore struct {
+	ChunkStore
+}
+
+func verifyChunk(h hash.Hash, c Chunk) {
+	if actual := hash.Of(c.Data()); actual != h {
+		d.Panic("Chunk read as %s has hash %s", h, actual)
+	}
+}
+
+func (s verifyingStore) Get(h hash.Hash) Chunk {
+	c := s.ChunkStore.Get(h)
+	if !c.IsEmpty() {
+		verifyChunk(h, c)
+	}
+	return c
+}
+
+func (s verifyingStore) GetMany(hashes hash.HashSet, foundChunks chan *Chunk) {
+	found := make(chan *Chunk, 16)
+	go func() {
+		defer close(found)
+		s.ChunkStore.GetMany(hashes, found)
+	}()
+	// If a chunk doesn't verify, let GetMany finish.
+	defer func() {
+		for range found {
+		}
+	}()
+	for c := range found {
+		if !hashes.Has(c.Hash()) {
+			d.Panic("Chunk %s was read, but not requested", c.Hash())
+		}
+		verifyChunk(c.Hash(), *c)
+		foundChunks <- c
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestVerifyingStoreTestSuite(t *testing.T) {
+	suite.Run(t, &verifyingStoreTestSuite{})
+}
+
+type verifyingStoreTestSuite struct {
+	ChunkStoreTestSuite
+}
+
+func (suite *verifyingStoreTestSuite) SetupTest() {
+	suite.Store = NewVerifyingStore(NewMemoryStore())
+}
+
+func (suite *verifyingStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+func TestVerifyingStoreCorrupt(t *testing.T) {
+	assert := assert.New(t)
+	ms := NewMemoryStore()
+	good := NewChunk([]byte("abc"))
+	ms.Put(good)
+	bad := NewChunkWithHash(hash.Of([]byte("def")), []byte("ghi"))
+	ms.Put(bad)
+
+	s := NewVerifyingStore(ms)
+	assert.Equal("abc", string(s.Get(good.Hash()).Data()))
+	assert.True(s.Get(hash.Of([]byte("missing"))).IsEmpty())
+	assert.Error(d.Try(func() { s.Get(bad.Hash()) }))
+
+	found := make(chan *Chunk, 2)
+	assert.Error(d.Try(func() { s.GetMany(hash.NewHashSet(good.Hash(), bad.Hash()), found) }))
+}
@@ -11,18 +11,67 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/attic-labs/noms/go/spec"
 )
 
 type Config struct {
-	File string
-	Db   map[string]DbConfig
+	File    string
+	Db      map[string]DbConfig
+	Profile map[string]ProfileConfig
+	Dataset map[string]DatasetConfig
+	// Include is the config files whose dbs, profiles and dataset aliases
+	// are used too, unless this one has some of the same names. Relative
+	// paths are relative to this file.
+	Include []string
 }
 
 type DbConfig struct {
+	Url     string
+	Profile string
+}
+
+// ProfileConfig is how to connect to a server: its URL, where to get
+// credentials from and its TLS settings. A profile is used for the databases
+// which name it, and for the other databases whose specs start with its URL.
+// Its name can also be used like a db alias for its URL.
+type ProfileConfig struct {
 	Url string
+	// TokenEnv is the environment variable the bearer token is in.
+	TokenEnv string `toml:"token_env"`
+	// CredentialHelper is the program which gets credentials, see
+	// credentialHelperCommand.
+	CredentialHelper string `toml:"credential_helper"`
+	// CaCert is a PEM file of the certificates of the CAs to trust, rather
+	// than the system's.
+	CaCert string `toml:"ca_cert"`
+	// ClientCert and ClientKey are PEM files of the certificate and key to
+	// authenticate with, if the server requires TLS client authentication.
+	ClientCert         string `toml:"client_cert"`
+	ClientKey          string `toml:"client_key"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+	// GCSCredentials is the service account key file to use for gs://
+	// databases, rather than the application default credentials.
+	GCSCredentials string `toml:"gcs_credentials"`
+	// IPFSAPI is the address of the HTTP API of the IPFS node to use for
+	// ipfs:// databases.
+	IPFSAPI string `toml:"ipfs_api"`
+}
+
+// DatasetConfig is a dataset alias, which can be used in place of the spec of
+// a dataset, or of the dataset a path starts at.
+type DatasetConfig struct {
+	// Db is the db alias or url of the dataset's database, or the default db
+	// if it's empty.
+	Db      string
+	Dataset string
+	// Profile is the profile to connect to the database with, rather than
+	// the one the database would otherwise use.
+	Profile string
 }
 
 const (
@@ -32,6 +81,10 @@ const (
 
 var NoConfig = errors.New(fmt.Sprintf("no %s found", NomsConfigFile))
 
+// envVarRe matches the ${VAR}s in config values, which are replaced by the
+// values of the environment variables.
+var envVarRe = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
 // Find the closest directory containing .nomsconfig starting
 // in cwd and then searching up ancestor tree.
 // Look first looking in cwd and then up through its ancestors
@@ -59,7 +112,14 @@ func FindNomsConfig() (*Config, error) {
 	}
 }
 
+// ReadConfig reads the config file name, and the files it includes.
 func ReadConfig(name string) (*Config, error) {
+	return readConfig(name, map[string]bool{})
+}
+
+// readConfig is ReadConfig, but fails if name is one of the files being read,
+// which include it.
+func readConfig(name string, reading map[string]bool) (*Config, error) {
 	data, err := ioutil.ReadFile(name)
 	if err != nil {
 		return nil, err
@@ -69,17 +129,93 @@ func ReadConfig(name string) (*Config, error) {
 		return nil, err
 	}
 	c.File = name
-	return qualifyPaths(name, c)
+	if c, err = qualifyPaths(name, c); err != nil {
+		return nil, err
+	}
+	if reading[c.File] {
+		return nil, fmt.Errorf("%s includes itself", c.File)
+	}
+	reading[c.File] = true
+	defer delete(reading, c.File)
+
+	// The files included later override the ones before them, and this one
+	// overrides them all.
+	merged := &Config{File: c.File, Include: c.Include}
+	for _, include := range c.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(c.File), include)
+		}
+		ic, err := readConfig(include, reading)
+		if err != nil {
+			return nil, err
+		}
+		merged.override(ic)
+	}
+	merged.override(c)
+	return merged, nil
+}
+
+// override adds the dbs, profiles and dataset aliases of o to c, replacing
+// those of c with the same names.
+func (c *Config) override(o *Config) {
+	if len(o.Db) > 0 && c.Db == nil {
+		c.Db = map[string]DbConfig{}
+	}
+	for k, db := range o.Db {
+		c.Db[k] = db
+	}
+	if len(o.Profile) > 0 && c.Profile == nil {
+		c.Profile = map[string]ProfileConfig{}
+	}
+	for k, p := range o.Profile {
+		c.Profile[k] = p
+	}
+	if len(o.Dataset) > 0 && c.Dataset == nil {
+		c.Dataset = map[string]DatasetConfig{}
+	}
+	for k, ds := range o.Dataset {
+		c.Dataset[k] = ds
+	}
 }
 
+// NewConfig parses the config in data, replacing the ${VAR}s in its values
+// with the values of the environment variables.
 func NewConfig(data string) (*Config, error) {
 	c := new(Config)
 	if _, err := toml.Decode(data, c); err != nil {
 		return nil, err
 	}
+	expandEnv(reflect.ValueOf(c).Elem())
 	return c, nil
 }
 
+// expandEnv replaces the ${VAR}s in the strings in v, which are expanded to
+// the empty string if VAR isn't set, like in the shell.
+func expandEnv(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(envVarRe.ReplaceAllStringFunc(v.String(), func(s string) string {
+			return os.Getenv(envVarRe.FindStringSubmatch(s)[1])
+		}))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			expandEnv(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandEnv(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			// Map values can't be set, so expand a copy and replace them.
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			expandEnv(e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
 func (c *Config) WriteTo(configHome string) (string, error) {
 	file := filepath.Join(configHome, NomsConfigFile)
 	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
@@ -117,7 +253,26 @@ func qualifyPaths(configPath string, c *Config) (*Config, error) {
 	qc := *c
 	qc.File = file
 	for k, r := range c.Db {
-		qc.Db[k] = DbConfig{absDbSpec(dir, r.Url)}
+		qc.Db[k] = DbConfig{absDbSpec(dir, r.Url), r.Profile}
+	}
+	absPath := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for k, ds := range c.Dataset {
+		if _, ok := c.Db[ds.Db]; !ok && ds.Db != "" {
+			if _, ok := c.Profile[ds.Db]; !ok {
+				ds.Db = absDbSpec(dir, ds.Db)
+			}
+		}
+		qc.Dataset[k] = ds
+	}
+	for k, p := range c.Profile {
+		p.CaCert, p.ClientCert, p.ClientKey = absPath(p.CaCert), absPath(p.ClientCert), absPath(p.ClientKey)
+		p.GCSCredentials = absPath(p.GCSCredentials)
+		qc.Profile[k] = p
 	}
 	return &qc, nil
 }
@@ -133,9 +288,51 @@ func (c *Config) String() string {
 
 func (c *Config) writeableString() string {
 	var buffer bytes.Buffer
+	if len(c.Include) > 0 {
+		quoted := make([]string, len(c.Include))
+		for i, include := range c.Include {
+			quoted[i] = fmt.Sprintf("%q", include)
+		}
+		buffer.WriteString(fmt.Sprintf("include = [%s]\n", strings.Join(quoted, ", ")))
+	}
 	for k, r := range c.Db {
 		buffer.WriteString(fmt.Sprintf("[db.%s]\n", k))
 		buffer.WriteString(fmt.Sprintf("\t"+`url = "%s"`+"\n", r.Url))
+		if r.Profile != "" {
+			buffer.WriteString(fmt.Sprintf("\t"+`profile = "%s"`+"\n", r.Profile))
+		}
+	}
+	for k, p := range c.Profile {
+		buffer.WriteString(fmt.Sprintf("[profile.%s]\n", k))
+		for _, f := range []struct{ name, value string }{
+			{"url", p.Url},
+			{"token_env", p.TokenEnv},
+			{"credential_helper", p.CredentialHelper},
+			{"ca_cert", p.CaCert},
+			{"client_cert", p.ClientCert},
+			{"client_key", p.ClientKey},
+			{"gcs_credentials", p.GCSCredentials},
+			{"ipfs_api", p.IPFSAPI},
+		} {
+			if f.value != "" {
+				buffer.WriteString(fmt.Sprintf("\t%s = %q\n", f.name, f.value))
+			}
+		}
+		if p.InsecureSkipVerify {
+			buffer.WriteString("\tinsecure_skip_verify = true\n")
+		}
+	}
+	for k, ds := range c.Dataset {
+		buffer.WriteString(fmt.Sprintf("[dataset.%s]\n", k))
+		for _, f := range []struct{ name, value string }{
+			{"db", ds.Db},
+			{"dataset", ds.Dataset},
+			{"profile", ds.Profile},
+		} {
+			if f.value != "" {
+				buffer.WriteString(fmt.Sprintf("\t%s = %q\n", f.name, f.value))
+			}
+		}
 	}
 	return buffer.String()
 }
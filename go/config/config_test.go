@@ -29,33 +29,45 @@ var (
 	ldbConfig = &Config{
 		"",
 		map[string]DbConfig{
-			DefaultDbAlias: {nbsSpec},
-			remoteAlias:    {httpSpec},
+			DefaultDbAlias: {nbsSpec, ""},
+			remoteAlias:    {httpSpec, ""},
 		},
+		nil,
+		nil,
+		nil,
 	}
 
 	httpConfig = &Config{
 		"",
 		map[string]DbConfig{
-			DefaultDbAlias: {httpSpec},
-			remoteAlias:    {nbsSpec},
+			DefaultDbAlias: {httpSpec, ""},
+			remoteAlias:    {nbsSpec, ""},
 		},
+		nil,
+		nil,
+		nil,
 	}
 
 	memConfig = &Config{
 		"",
 		map[string]DbConfig{
-			DefaultDbAlias: {memSpec},
-			remoteAlias:    {httpSpec},
+			DefaultDbAlias: {memSpec, ""},
+			remoteAlias:    {httpSpec, ""},
 		},
+		nil,
+		nil,
+		nil,
 	}
 
 	ldbAbsConfig = &Config{
 		"",
 		map[string]DbConfig{
-			DefaultDbAlias: {nbsAbsSpec},
-			remoteAlias:    {httpSpec},
+			DefaultDbAlias: {nbsAbsSpec, ""},
+			remoteAlias:    {httpSpec, ""},
 		},
+		nil,
+		nil,
+		nil,
 	}
 )
 
@@ -199,3 +211,56 @@ func TestCwd(t *testing.T) {
 
 	assert.Equal(cwd, abs)
 }
+
+func TestEnvAndIncludes(t *testing.T) {
+	assert := assert.New(t)
+	path := getPaths(assert, "home.includes")
+	shared := filepath.Join(filepath.Dir(path.home), "shared.includes")
+	assert.NoError(os.MkdirAll(path.home, os.ModePerm))
+	assert.NoError(os.MkdirAll(shared, os.ModePerm))
+	assert.NoError(os.Setenv("NOMS_TEST_HOST", "noms.example.com"))
+	defer os.Unsetenv("NOMS_TEST_HOST")
+	os.Unsetenv("NOMS_TEST_UNSET")
+
+	write := func(file, data string) {
+		assert.NoError(ioutil.WriteFile(file, []byte(data), os.ModePerm))
+	}
+	write(filepath.Join(shared, "shared.nomsconfig"), `
+[db.default]
+url = "nbs:./shared"
+[db.data]
+url = "nbs:./data"
+[db.origin]
+url = "https://${NOMS_TEST_HOST}/db${NOMS_TEST_UNSET}"
+[profile.origin]
+url = "https://${NOMS_TEST_HOST}"
+token_env = "NOMS_TEST_TOKEN"
+`)
+	write(path.config, `
+include = ["../shared.includes/shared.nomsconfig"]
+[db.default]
+url = "nbs:./local"
+[dataset.users]
+db = "origin"
+dataset = "$NOMS_TEST_HOST-${NOMS_TEST_HOST}"
+`)
+
+	assert.NoError(os.Chdir(path.home))
+	c, err := FindNomsConfig()
+	assert.NoError(err)
+	assert.Equal(path.config, c.File)
+	assert.Equal("nbs:"+filepath.Join(path.home, "local"), c.Db[DefaultDbAlias].Url)
+	assert.Equal("nbs:"+filepath.Join(shared, "data"), c.Db["data"].Url)
+	assert.Equal("https://noms.example.com/db", c.Db["origin"].Url)
+	assert.Equal(ProfileConfig{Url: "https://noms.example.com", TokenEnv: "NOMS_TEST_TOKEN"}, c.Profile["origin"])
+	assert.Equal("$NOMS_TEST_HOST-noms.example.com", c.Dataset["users"].Dataset)
+
+	// Including a file which includes it is an error, as is including a
+	// file which doesn't exist.
+	write(filepath.Join(shared, "shared.nomsconfig"), `include = ["../home.includes/.nomsconfig"]`)
+	_, err = FindNomsConfig()
+	assert.Error(err)
+	write(path.config, `include = ["missing.nomsconfig"]`)
+	_, err = FindNomsConfig()
+	assert.Error(err)
+}
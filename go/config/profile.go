@@ -0,0 +1,170 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/attic-labs/noms/go/spec"
+)
+
+// credentialHelperPrefix starts the names of the programs which credential
+// helpers that aren't paths or shell commands refer to.
+const credentialHelperPrefix = "noms-credential-"
+
+// profile returns the profile to connect to the database spelled by str,
+// which was resolved to dbSpec: the profile of the db alias str, if any, or
+// else the profile with the longest URL which dbSpec starts with.
+func (c *Config) profile(str, dbSpec string) (ProfileConfig, bool) {
+	if str == "" {
+		str = DefaultDbAlias
+	}
+	if db, ok := c.Db[str]; ok && db.Profile != "" {
+		p, ok := c.Profile[db.Profile]
+		return p, ok
+	}
+	if p, ok := c.Profile[str]; ok {
+		return p, true
+	}
+	var best ProfileConfig
+	found := false
+	for _, p := range c.Profile {
+		if isURLPrefix(p.Url, dbSpec) && len(p.Url) > len(best.Url) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// isURLPrefix returns whether the URL of the server or database prefix is a
+// prefix of dbSpec which ends at a path segment, so that the credentials of
+// https://example.com aren't sent to https://example.com.evil.org.
+func isURLPrefix(prefix, dbSpec string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || !strings.HasPrefix(dbSpec, prefix) {
+		return false
+	}
+	rest := dbSpec[len(prefix):]
+	return rest == "" || rest[0] == '/' || rest[0] == '?'
+}
+
+// specOptions returns the options to connect to dbSpec with the profile.
+func (p ProfileConfig) specOptions(dbSpec string) (spec.SpecOptions, error) {
+	auth, err := p.authorization(dbSpec)
+	if err != nil {
+		return spec.SpecOptions{}, err
+	}
+	tlsConfig, err := p.tlsConfig()
+	if err != nil {
+		return spec.SpecOptions{}, err
+	}
+	return spec.SpecOptions{Authorization: auth, TLSConfig: tlsConfig, GCSCredentials: p.GCSCredentials, IPFSAPI: p.IPFSAPI}, nil
+}
+
+// authorization returns the Authorization header of requests to dbSpec.
+func (p ProfileConfig) authorization(dbSpec string) (string, error) {
+	switch {
+	case p.TokenEnv != "":
+		token := os.Getenv(p.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("$%s is empty", p.TokenEnv)
+		}
+		return "Bearer " + token, nil
+	case p.CredentialHelper != "":
+		creds, err := runCredentialHelper(p.CredentialHelper, dbSpec)
+		if err != nil {
+			return "", err
+		}
+		if token := creds["token"]; token != "" {
+			return "Bearer " + token, nil
+		}
+		if creds["username"] != "" && creds["password"] != "" {
+			return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds["username"]+":"+creds["password"])), nil
+		}
+		return "", fmt.Errorf("credential helper %s returned no token or username and password", p.CredentialHelper)
+	}
+	return "", nil
+}
+
+// credentialHelperCommand returns the command which runs the credential
+// helper with the argument "get". As with git, helpers starting with "!" are
+// shell commands, helpers containing a slash are paths of programs, and
+// others are names of programs starting with "noms-credential-" in $PATH.
+func credentialHelperCommand(helper string) *exec.Cmd {
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		return exec.Command("sh", "-c", helper[1:]+" get")
+	case strings.ContainsRune(helper, filepath.Separator):
+		return exec.Command(helper, "get")
+	}
+	return exec.Command(credentialHelperPrefix+helper, "get")
+}
+
+// runCredentialHelper runs the credential helper, which is given the
+// protocol, host and path of dbSpec on stdin as "key=value" lines, like git's
+// credential helpers are, and writes the credentials as "key=value" lines,
+// with the keys token, or username and password.
+func runCredentialHelper(helper, dbSpec string) (map[string]string, error) {
+	u, err := url.Parse(dbSpec)
+	if err != nil {
+		return nil, err
+	}
+	in := fmt.Sprintf("protocol=%s\nhost=%s\npath=%s\n\n", u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/"))
+
+	cmd := credentialHelperCommand(helper)
+	cmd.Stdin = strings.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s failed: %s", helper, err)
+	}
+
+	creds := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if kv := strings.SplitN(scanner.Text(), "=", 2); len(kv) == 2 {
+			creds[kv[0]] = kv[1]
+		}
+	}
+	return creds, scanner.Err()
+}
+
+// tlsConfig returns the TLS config of the profile, or nil if it has no TLS
+// settings.
+func (p ProfileConfig) tlsConfig() (*tls.Config, error) {
+	if p.CaCert == "" && p.ClientCert == "" && p.ClientKey == "" && !p.InsecureSkipVerify {
+		return nil, nil
+	}
+	c := &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify}
+	if p.CaCert != "" {
+		pem, err := ioutil.ReadFile(p.CaCert)
+		if err != nil {
+			return nil, err
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s has no PEM certificates", p.CaCert)
+		}
+	}
+	if p.ClientCert != "" || p.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(p.ClientCert, p.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package config
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	assert := assert.New(t)
+	dir := filepath.Join(rtestRoot, "with-profiles")
+	assert.NoError(os.MkdirAll(dir, os.ModePerm))
+
+	// The helper echoes the host it's given as the token.
+	helper := filepath.Join(dir, "helper")
+	assert.NoError(ioutil.WriteFile(helper, []byte("#!/bin/sh\nsed -n 's/^host=/token=/p'\n"), 0755))
+
+	c := &Config{
+		"",
+		map[string]DbConfig{
+			DefaultDbAlias: {"https://work.example.com/photos", ""},
+			"home":         {"https://home.example.com", "home"},
+			"local":        {localSpec, ""},
+		},
+		map[string]ProfileConfig{
+			"work": {Url: "https://work.example.com", TokenEnv: "NOMS_TEST_TOKEN"},
+			"home": {CredentialHelper: helper, InsecureSkipVerify: true},
+			"user": {Url: "https://user.example.com", CredentialHelper: "!echo username=alice; echo password=secret; true"},
+			"gcs":  {Url: "gs://bucket", GCSCredentials: filepath.Join(dir, "key.json")},
+			"ipfs": {Url: "ipfs://self", IPFSAPI: "http://ipfs.example.com:5001"},
+		},
+		map[string]DatasetConfig{
+			"photos":        {Dataset: "photos"},
+			"home-photos":   {Db: "home", Dataset: "photos"},
+			"shared-photos": {Db: "https://work.example.com/shared", Dataset: "photos", Profile: "user"},
+		},
+		nil,
+	}
+	_, err := c.WriteTo(dir)
+	assert.NoError(err)
+	assert.NoError(os.Chdir(dir))
+	r := NewResolver()
+	assert.Equal(c.Profile, r.config.Profile)
+	assert.Equal(c.Dataset, r.config.Dataset)
+	assert.Equal("home", r.config.Db["home"].Profile)
+
+	assert.NoError(os.Setenv("NOMS_TEST_TOKEN", "t0ken"))
+	defer os.Unsetenv("NOMS_TEST_TOKEN")
+	for _, str := range []string{"", "work", "https://work.example.com", "https://work.example.com/other"} {
+		opts, err := r.specOptions(str, r.ResolveDbSpec(str))
+		assert.NoError(err)
+		assert.Equal("Bearer t0ken", opts.Authorization, str)
+		assert.Nil(opts.TLSConfig)
+	}
+
+	opts, err := r.pathSpecOptions("home::ds", r.ResolvePathSpec("home::ds"))
+	assert.NoError(err)
+	assert.Equal("Bearer home.example.com", opts.Authorization)
+	assert.True(opts.TLSConfig.InsecureSkipVerify)
+
+	// Dataset aliases use the profile of their database, unless they name one.
+	for str, auth := range map[string]string{
+		"photos":              "Bearer t0ken",
+		"home-photos.value":   "Bearer home.example.com",
+		"shared-photos~1":     "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret")),
+		"home::shared-photos": "Bearer home.example.com",
+	} {
+		opts, err = r.pathSpecOptions(str, r.ResolvePathSpec(str))
+		assert.NoError(err)
+		assert.Equal(auth, opts.Authorization, str)
+	}
+
+	opts, err = r.specOptions("user", r.ResolveDbSpec("user"))
+	assert.NoError(err)
+	assert.Equal("Basic "+base64.StdEncoding.EncodeToString([]byte("alice:secret")), opts.Authorization)
+
+	opts, err = r.specOptions("gs://bucket/db", r.ResolveDbSpec("gs://bucket/db"))
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "key.json"), opts.GCSCredentials)
+	assert.Equal("", opts.Authorization)
+
+	opts, err = r.specOptions("ipfs://self", r.ResolveDbSpec("ipfs://self"))
+	assert.NoError(err)
+	assert.Equal("http://ipfs.example.com:5001", opts.IPFSAPI)
+
+	// Profiles aren't used for other servers or databases.
+	for _, str := range []string{"local", "https://work.example.com.evil.org", "http://other.example.com"} {
+		opts, err = r.specOptions(str, r.ResolveDbSpec(str))
+		assert.NoError(err)
+		assert.Equal("", opts.Authorization, str)
+	}
+
+	url, auth, err := r.GetRemote("work")
+	assert.NoError(err)
+	assert.Equal("https://work.example.com", url)
+	assert.Equal("Bearer t0ken", auth)
+	_, _, err = r.GetRemote("local")
+	assert.Error(err)
+
+	assert.NoError(os.Unsetenv("NOMS_TEST_TOKEN"))
+	_, err = r.specOptions("work", r.ResolveDbSpec("work"))
+	assert.Error(err)
+}
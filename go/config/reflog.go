@@ -0,0 +1,176 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// ReflogEntry is a change to the head of a dataset made by a program which
+// opened the database with a Resolver. Old is empty if the change created
+// the dataset, and New is empty if it deleted it.
+type ReflogEntry struct {
+	Time     time.Time
+	Dataset  string
+	Old, New hash.Hash
+	Command  string
+}
+
+// reflogRecord is a ReflogEntry as it's written to the reflog file, a line of
+// JSON.
+type reflogRecord struct {
+	Time    time.Time `json:"time"`
+	Dataset string    `json:"dataset"`
+	Old     string    `json:"old,omitempty"`
+	New     string    `json:"new,omitempty"`
+	Command string    `json:"command"`
+}
+
+func hashString(h hash.Hash) string {
+	if h.IsEmpty() {
+		return ""
+	}
+	return h.String()
+}
+
+func parseHash(s string) hash.Hash {
+	if s == "" {
+		return hash.Hash{}
+	}
+	return hash.Parse(s)
+}
+
+// ReflogDir returns the directory the reflogs of databases are kept in. Like
+// git's, reflogs are kept on the machine which made the changes, and only
+// record the changes made from it.
+func ReflogDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".noms", "reflog")
+}
+
+// reflogFile returns the file the reflog of the database sp is kept in, or ""
+// if it has none, because it's in memory.
+func reflogFile(sp spec.Spec) string {
+	dir := ReflogDir()
+	if dir == "" || sp.Protocol == "mem" {
+		return ""
+	}
+	name := sp.Protocol + ":" + sp.DatabaseName
+	if sp.Protocol == "nbs" || sp.Protocol == "ldb" {
+		if abs, err := filepath.Abs(sp.DatabaseName); err == nil {
+			name = sp.Protocol + ":" + abs
+		}
+	}
+	return filepath.Join(dir, hash.Of([]byte(name)).String()+".log")
+}
+
+// reflogDatabase is a Database which appends the changes made to the heads
+// of its datasets since it was opened to its reflog when it's closed.
+type reflogDatabase struct {
+	datas.Database
+	file     string
+	datasets types.Map
+}
+
+func newReflogDatabase(sp spec.Spec) datas.Database {
+	db := sp.GetDatabase()
+	file := reflogFile(sp)
+	if file == "" {
+		return db
+	}
+	return &reflogDatabase{db, file, db.Datasets()}
+}
+
+func (db *reflogDatabase) Close() error {
+	err := db.log()
+	if cerr := db.Database.Close(); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+func (db *reflogDatabase) log() error {
+	current := db.Database.Datasets()
+	if current.Equals(db.datasets) {
+		return nil
+	}
+
+	now := time.Now()
+	command := strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+	records := []reflogRecord{}
+	add := func(k types.Value, old, new hash.Hash) {
+		records = append(records, reflogRecord{now, string(k.(types.String)), hashString(old), hashString(new), command})
+	}
+	current.IterAll(func(k, v types.Value) {
+		h := v.(types.Ref).TargetHash()
+		if old, ok := db.datasets.MaybeGet(k); !ok {
+			add(k, hash.Hash{}, h)
+		} else if old.(types.Ref).TargetHash() != h {
+			add(k, old.(types.Ref).TargetHash(), h)
+		}
+	})
+	db.datasets.IterAll(func(k, v types.Value) {
+		if !current.Has(k) {
+			add(k, v.(types.Ref).TargetHash(), hash.Hash{})
+		}
+	})
+
+	if err := os.MkdirAll(filepath.Dir(db.file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(db.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readReflog returns the entries of the reflog file about the dataset
+// datasetID, newest first.
+func readReflog(file, datasetID string) ([]ReflogEntry, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []ReflogEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r reflogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		if r.Dataset == datasetID {
+			entries = append(entries, ReflogEntry{r.Time, r.Dataset, parseHash(r.Old), parseHash(r.New), r.Command})
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, scanner.Err()
+}
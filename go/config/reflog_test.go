@@ -0,0 +1,84 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestReflog(t *testing.T) {
+	assert := assert.New(t)
+	home, err := ioutil.TempDir("", "reflog")
+	assert.NoError(err)
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	r := &Resolver{}
+	dsSpec := spec.CreateValueSpecString("nbs", home+"/db", "ds")
+	commit := func(v types.Value) hash.Hash {
+		db, ds, err := r.GetDataset(dsSpec)
+		assert.NoError(err)
+		ds, err = db.CommitValue(ds, v)
+		assert.NoError(err)
+		assert.NoError(db.Close())
+		return ds.HeadRef().TargetHash()
+	}
+	first := commit(types.Number(1))
+	second := commit(types.Number(2))
+
+	// Reading doesn't change the reflog.
+	db, _, err := r.GetPath(dsSpec + ".value")
+	assert.NoError(err)
+	assert.NoError(db.Close())
+
+	db, ds, err := r.GetDataset(dsSpec)
+	assert.NoError(err)
+	_, err = db.Delete(ds)
+	assert.NoError(err)
+	assert.NoError(db.Close())
+
+	entries, err := r.Reflog(dsSpec)
+	assert.NoError(err)
+	assert.Len(entries, 3)
+	assert.Equal([]hash.Hash{second, first, {}}, []hash.Hash{entries[0].Old, entries[1].Old, entries[2].Old})
+	assert.Equal([]hash.Hash{{}, second, first}, []hash.Hash{entries[0].New, entries[1].New, entries[2].New})
+	assert.Equal("ds", entries[0].Dataset)
+
+	entries, err = r.Reflog(spec.CreateValueSpecString("nbs", home+"/db", "other"))
+	assert.NoError(err)
+	assert.Empty(entries)
+	entries, err = r.Reflog("mem::ds")
+	assert.NoError(err)
+	assert.Empty(entries)
+}
+
+func TestReflogDatabaseValueReader(t *testing.T) {
+	assert := assert.New(t)
+	home, err := ioutil.TempDir("", "reflog")
+	assert.NoError(err)
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	// Collections compare the ValueReaders they were read from, which the
+	// database must allow.
+	db, err := (&Resolver{}).GetDatabase(spec.CreateDatabaseSpecString("nbs", home+"/db"))
+	assert.NoError(err)
+	defer db.Close()
+	values := make(chan types.Value, 2)
+	values <- types.Number(1)
+	values <- types.Number(2)
+	close(values)
+	l := <-types.NewStreamingList(db, values)
+	assert.Equal(uint64(4), l.Concat(l).Len())
+}
@@ -6,6 +6,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -15,12 +16,14 @@ import (
 	"github.com/attic-labs/noms/go/util/verbose"
 )
 
+var datasetAliasRe = regexp.MustCompile("^" + datas.DatasetRe.String())
+
 type Resolver struct {
 	config      *Config
 	dotDatapath string // set to the first datapath that was resolved
 }
 
-// A Resolver enables using db defaults, db aliases and dataset '.' replacement in command
+// A Resolver enables using db defaults, db and dataset aliases and dataset '.' replacement in command
 // line arguments when a .nomsconfig file is present. To use it, create a config resolver
 // before command line processing and use it to resolve each dataspec argument in
 // succession.
@@ -57,11 +60,70 @@ func (r *Resolver) ResolveDbSpec(str string) string {
 		if val, ok := r.config.Db[str]; ok {
 			return val.Url
 		}
+		if p, ok := r.config.Profile[str]; ok && p.Url != "" {
+			return p.Url
+		}
 	}
 	return str
 }
 
+// specOptions returns the options to connect to the database spelled by
+// str, which was resolved to dbSpec, with its profile, if any.
+func (r *Resolver) specOptions(str, dbSpec string) (spec.SpecOptions, error) {
+	if r.config == nil || !hasProfileScheme(dbSpec) {
+		return spec.SpecOptions{}, nil
+	}
+	p, ok := r.config.profile(str, dbSpec)
+	if !ok {
+		return spec.SpecOptions{}, nil
+	}
+	return p.specOptions(dbSpec)
+}
+
+// hasProfileScheme returns whether dbSpec is a database which profiles can
+// have options for.
+func hasProfileScheme(dbSpec string) bool {
+	for _, scheme := range []string{"http://", "https://", "gs://", "ipfs://"} {
+		if strings.HasPrefix(dbSpec, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// datasetAlias returns the dataset alias which the path or dataset spelled by
+// str starts with, if any, and the rest of str, e.g. the path from it.
+func (r *Resolver) datasetAlias(str string) (DatasetConfig, string, bool) {
+	if r.config == nil || strings.Contains(str, spec.Separator) {
+		return DatasetConfig{}, "", false
+	}
+	name := datasetAliasRe.FindString(str)
+	ds, ok := r.config.Dataset[name]
+	return ds, str[len(name):], ok
+}
+
+// pathSpecOptions is like specOptions, but for the path or dataset spelled by
+// str, which was resolved to pathSpec.
+func (r *Resolver) pathSpecOptions(str, pathSpec string) (spec.SpecOptions, error) {
+	if ds, _, ok := r.datasetAlias(str); ok {
+		dbSpec := strings.SplitN(pathSpec, spec.Separator, 2)[0]
+		if ds.Profile == "" {
+			return r.specOptions(ds.Db, dbSpec)
+		}
+		if p, ok := r.config.Profile[ds.Profile]; ok && hasProfileScheme(dbSpec) {
+			return p.specOptions(dbSpec)
+		}
+		return spec.SpecOptions{}, nil
+	}
+	db := ""
+	if split := strings.SplitN(str, spec.Separator, 2); len(split) > 1 {
+		db = split[0]
+	}
+	return r.specOptions(db, strings.SplitN(pathSpec, spec.Separator, 2)[0])
+}
+
 // Resolve string to dataset or path name.
+//   - replace a dataset alias with its database and dataset
 //   - replace database name as described in ResolveDatabase
 //   - if this is the first call to ResolvePath, remember the
 //     datapath part for subsequent calls.
@@ -69,6 +131,9 @@ func (r *Resolver) ResolveDbSpec(str string) string {
 //     it with the first datapath.
 func (r *Resolver) ResolvePathSpec(str string) string {
 	if r.config != nil {
+		if ds, rest, ok := r.datasetAlias(str); ok {
+			str = ds.Db + spec.Separator + ds.Dataset + rest
+		}
 		split := strings.SplitN(str, spec.Separator, 2)
 		db, rest := "", split[0]
 		if len(split) > 1 {
@@ -88,11 +153,16 @@ func (r *Resolver) ResolvePathSpec(str string) string {
 //   - resolve a db alias to its db spec
 //   - resolve "" to the default db spec
 func (r *Resolver) GetDatabase(str string) (datas.Database, error) {
-	sp, err := spec.ForDatabase(r.verbose(str, r.ResolveDbSpec(str)))
+	dbSpec := r.verbose(str, r.ResolveDbSpec(str))
+	opts, err := r.specOptions(str, dbSpec)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := spec.ForDatabaseOpts(dbSpec, opts)
 	if err != nil {
 		return nil, err
 	}
-	return sp.GetDatabase(), nil
+	return newReflogDatabase(sp), nil
 }
 
 // Resolve string to a chunkstore. Like ResolveDatabase, but returns the underlying ChunkStore
@@ -106,35 +176,83 @@ func (r *Resolver) GetChunkStore(str string) (chunks.ChunkStore, error) {
 
 // Resolve string to a RootTracker. Like ResolveDatabase, but returns a RootTracker instead
 func (r *Resolver) GetRootTracker(str string) (chunks.RootTracker, error) {
-	sp, err := spec.ForDatabase(r.verbose(str, r.ResolveDbSpec(str)))
+	dbSpec := r.verbose(str, r.ResolveDbSpec(str))
+	opts, err := r.specOptions(str, dbSpec)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := spec.ForDatabaseOpts(dbSpec, opts)
 	if err != nil {
 		return nil, err
 	}
 	var rt chunks.RootTracker = sp.NewChunkStore()
 	if rt == nil {
-		rt = datas.NewHTTPBatchStore(sp.String(), "")
+		if opts.TLSConfig != nil {
+			rt = datas.NewHTTPBatchStoreTLS(sp.String(), opts.Authorization, opts.TLSConfig)
+		} else {
+			rt = datas.NewHTTPBatchStore(sp.String(), opts.Authorization)
+		}
 	}
 	return rt, nil
 }
 
+// GetRemote resolves str to the URL of an HTTP database, and the
+// Authorization header of requests to it given by its profile, if any.
+func (r *Resolver) GetRemote(str string) (url, auth string, err error) {
+	dbSpec := r.verbose(str, r.ResolveDbSpec(str))
+	if !strings.HasPrefix(dbSpec, "http://") && !strings.HasPrefix(dbSpec, "https://") {
+		return "", "", fmt.Errorf("%s is not an HTTP database", dbSpec)
+	}
+	opts, err := r.specOptions(str, dbSpec)
+	if err != nil {
+		return "", "", err
+	}
+	return dbSpec, opts.Authorization, nil
+}
+
 // Resolve string to a dataset. If a config is present,
 //  - if no db prefix is present, assume the default db
 //  - if the db prefix is an alias, replace it
 func (r *Resolver) GetDataset(str string) (datas.Database, datas.Dataset, error) {
-	sp, err := spec.ForDataset(r.verbose(str, r.ResolvePathSpec(str)))
+	pathSpec := r.verbose(str, r.ResolvePathSpec(str))
+	opts, err := r.pathSpecOptions(str, pathSpec)
 	if err != nil {
 		return nil, datas.Dataset{}, err
 	}
-	return sp.GetDatabase(), sp.GetDataset(), nil
+	sp, err := spec.ForDatasetOpts(pathSpec, opts)
+	if err != nil {
+		return nil, datas.Dataset{}, err
+	}
+	return newReflogDatabase(sp), sp.GetDataset(), nil
 }
 
 // Resolve string to a value path. If a config is present,
 //  - if no db spec is present, assume the default db
 //  - if the db spec is an alias, replace it
 func (r *Resolver) GetPath(str string) (datas.Database, types.Value, error) {
-	sp, err := spec.ForPath(r.verbose(str, r.ResolvePathSpec(str)))
+	pathSpec := r.verbose(str, r.ResolvePathSpec(str))
+	opts, err := r.pathSpecOptions(str, pathSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+	sp, err := spec.ForPathOpts(pathSpec, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	return sp.GetDatabase(), sp.GetValue(), nil
+	return newReflogDatabase(sp), sp.GetValue(), nil
+}
+
+// Reflog returns the changes to the head of the dataset str recorded in the
+// reflog of its database, newest first. See ReflogDir.
+func (r *Resolver) Reflog(str string) ([]ReflogEntry, error) {
+	pathSpec := r.verbose(str, r.ResolvePathSpec(str))
+	sp, err := spec.ForDataset(pathSpec)
+	if err != nil {
+		return nil, err
+	}
+	file := reflogFile(sp)
+	if file == "" {
+		return nil, nil
+	}
+	return readReflog(file, sp.Path.Dataset)
 }
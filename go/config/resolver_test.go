@@ -32,9 +32,16 @@ var (
 	rtestConfig = &Config{
 		"",
 		map[string]DbConfig{
-			DefaultDbAlias: {localSpec},
-			remoteAlias:    {remoteSpec},
+			DefaultDbAlias: {localSpec, ""},
+			remoteAlias:    {remoteSpec, ""},
 		},
+		nil,
+		map[string]DatasetConfig{
+			"users":   {Db: remoteAlias, Dataset: "people"},
+			"scratch": {Dataset: "scratch-ds"},
+			"other":   {Db: "http://other.com/db", Dataset: "ds"},
+		},
+		nil,
 	}
 
 	dbTestsNoAliases = []testData{
@@ -57,6 +64,12 @@ var (
 		{remoteAlias + "::" + testDs, remoteSpec + "::" + testDs},
 		{testObject, localSpec + "::" + testObject},
 		{remoteAlias + "::" + testObject, remoteSpec + "::" + testObject},
+		{"users", remoteSpec + "::people"},
+		{"users.value[0]", remoteSpec + "::people.value[0]"},
+		{"users~1.value", remoteSpec + "::people~1.value"},
+		{"scratch", localSpec + "::scratch-ds"},
+		{"other", "http://other.com/db::ds"},
+		{remoteAlias + "::users", remoteSpec + "::users"},
 	}
 )
 
@@ -9,8 +9,19 @@ const (
 	GetRefsPath    = "/getRefs/"
 	GetBlobPath    = "/getBlob/"
 	HasRefsPath    = "/hasRefs/"
+	HasFilterPath  = "/hasFilter/"
+	PullPath       = "/pull/"
 	WriteValuePath = "/writeValue/"
 	BasePath       = "/"
+	HealthPath     = "/health/"
 
 	GraphQLPath = "/graphql/"
+
+	AdminDatasetsPath    = "/admin/datasets/"
+	AdminStatsPath       = "/admin/stats/"
+	AdminConnectionsPath = "/admin/connections/"
+	AdminGCPath          = "/admin/gc/"
+	AdminReadOnlyPath    = "/admin/read-only/"
+	AdminPromotePath     = "/admin/promote/"
+	AdminAuditPath       = "/admin/audit/"
 )
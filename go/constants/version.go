@@ -8,10 +8,12 @@ package constants
 import (
 	"fmt"
 	"os"
+
+	"github.com/attic-labs/noms/go/hash"
 )
 
 // TODO: generate this from some central thing with go generate.
-const NomsVersion = "7.8"
+const NomsVersion = "7.9"
 const NOMS_VERSION_NEXT_ENV_NAME = "NOMS_VERSION_NEXT"
 const NOMS_VERSION_NEXT_ENV_VALUE = "1"
 
@@ -31,3 +33,13 @@ func init() {
 		os.Exit(1)
 	}
 }
+
+// DataVersion returns the version of the data this process reads and writes.
+// It's NomsVersion, followed by a "+" and the name of the hash function if
+// hash.SetFunc chose another one than the default, e.g. "7.9+sha3".
+func DataVersion() string {
+	if name := hash.CurrentFunc().Name; name != hash.DefaultFuncName {
+		return NomsVersion + "+" + name
+	}
+	return NomsVersion
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package crdt implements convergent replicated data types as Noms structs:
+// PNCounter, LWWRegister, ORSet and RGA, a list. Each replica of a value,
+// e.g. the copy of a dataset on each of several devices, is edited on its
+// own, and any two replicas can be merged, in any order and as many times,
+// into the same value.
+//
+// Importing the package registers the merges of the types with
+// merge.RegisterStructMerge, so ThreeWay, and so Database.Commit with a merge
+// Policy, and noms merge, merge concurrent edits of them wherever they're
+// found in a value, without a ResolveFunc.
+//
+// Edits are made by a replica, identified by a string unique to it, such as
+// a device ID.
+package crdt
+
+import (
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+)
+
+func init() {
+	merge.RegisterStructMerge(pnCounterName, func(a, b types.Struct) (types.Value, bool) {
+		ac, aOk := PNCounterFromValue(a)
+		bc, bOk := PNCounterFromValue(b)
+		if !aOk || !bOk {
+			return nil, false
+		}
+		return ac.Merge(bc).Struct(), true
+	})
+	merge.RegisterStructMerge(lwwRegisterName, func(a, b types.Struct) (types.Value, bool) {
+		ar, aOk := LWWRegisterFromValue(a)
+		br, bOk := LWWRegisterFromValue(b)
+		if !aOk || !bOk {
+			return nil, false
+		}
+		return ar.Merge(br).Struct(), true
+	})
+	merge.RegisterStructMerge(orSetName, func(a, b types.Struct) (types.Value, bool) {
+		as, aOk := ORSetFromValue(a)
+		bs, bOk := ORSetFromValue(b)
+		if !aOk || !bOk {
+			return nil, false
+		}
+		return as.Merge(bs).Struct(), true
+	})
+	merge.RegisterStructMerge(rgaName, func(a, b types.Struct) (types.Value, bool) {
+		al, aOk := RGAFromValue(a)
+		bl, bOk := RGAFromValue(b)
+		if !aOk || !bOk {
+			return nil, false
+		}
+		return al.Merge(bl).Struct(), true
+	})
+}
+
+// fields returns the fields named names of v, if v is a struct named name
+// with exactly those fields, of the kinds given.
+func fields(v types.Value, name string, names []string, kinds []types.NomsKind) ([]types.Value, bool) {
+	s, ok := v.(types.Struct)
+	if !ok || s.Name() != name || s.Len() != len(names) {
+		return nil, false
+	}
+	values := make([]types.Value, len(names))
+	for i, n := range names {
+		fv, ok := s.MaybeGet(n)
+		if !ok || (kinds[i] != types.ValueKind && fv.Kind() != kinds[i]) {
+			return nil, false
+		}
+		values[i] = fv
+	}
+	return values, true
+}
+
+// mergeMaps returns the union of a and b, with the values of the keys of both
+// merged by f.
+func mergeMaps(a, b types.Map, f func(av, bv types.Value) types.Value) types.Map {
+	if a.Len() < b.Len() {
+		a, b = b, a
+		g := f
+		f = func(av, bv types.Value) types.Value { return g(bv, av) }
+	}
+	merged := a
+	b.IterAll(func(k, bv types.Value) {
+		if av, ok := a.MaybeGet(k); !ok {
+			merged = merged.Set(k, bv)
+		} else if mv := f(av, bv); !mv.Equals(av) {
+			merged = merged.Set(k, mv)
+		}
+	})
+	return merged
+}
+
+// unionSets returns the union of a and b.
+func unionSets(a, b types.Set) types.Set {
+	if a.Len() < b.Len() {
+		a, b = b, a
+	}
+	merged := a
+	b.IterAll(func(v types.Value) {
+		if !a.Has(v) {
+			merged = merged.Insert(v)
+		}
+	})
+	return merged
+}
@@ -0,0 +1,178 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package crdt
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestPNCounter(t *testing.T) {
+	assert := assert.New(t)
+	c := NewPNCounter().Add("a", 3).Add("a", -1)
+	assert.Equal(2.0, c.Value())
+
+	a := c.Add("a", 5)
+	b := c.Add("b", -4).Add("b", 1)
+	m := a.Merge(b)
+	assert.Equal(2.0+5-4+1, m.Value())
+	assert.True(m.Struct().Equals(b.Merge(a).Struct()))
+	assert.True(m.Struct().Equals(m.Merge(a).Struct()))
+
+	rt, ok := PNCounterFromValue(m.Struct())
+	assert.True(ok)
+	assert.Equal(m.Value(), rt.Value())
+	_, ok = PNCounterFromValue(types.NewStruct("PNCounter", types.StructData{"inc": types.Number(1)}))
+	assert.False(ok)
+}
+
+func TestLWWRegister(t *testing.T) {
+	assert := assert.New(t)
+	r := NewLWWRegister("a", 1, types.String("one"))
+	a := r.Set("a", 3, types.String("three"))
+	b := r.Set("b", 2, types.String("two"))
+	assert.True(types.String("three").Equals(a.Merge(b).Value()))
+	assert.True(types.String("three").Equals(b.Merge(a).Value()))
+
+	// An older Set doesn't replace a newer value.
+	assert.True(types.String("three").Equals(a.Set("b", 2, types.String("late")).Value()))
+
+	// Ties are broken by replica.
+	x, y := NewLWWRegister("x", 5, types.Number(1)), NewLWWRegister("y", 5, types.Number(2))
+	assert.True(x.Merge(y).Struct().Equals(y.Merge(x).Struct()))
+	assert.True(types.Number(2).Equals(x.Merge(y).Value()))
+}
+
+func TestORSet(t *testing.T) {
+	assert := assert.New(t)
+	s := NewORSet().Add("a", 1, types.String("x")).Add("a", 2, types.String("y"))
+	assert.True(s.Has(types.String("x")))
+
+	// a removes x while b adds it again concurrently: b's add survives.
+	a := s.Remove(types.String("x")).Remove(types.String("y"))
+	b := s.Add("b", 1, types.String("x"))
+	assert.False(a.Has(types.String("x")))
+	m := a.Merge(b)
+	assert.True(m.Has(types.String("x")))
+	assert.False(m.Has(types.String("y")))
+	assert.True(types.NewSet(types.String("x")).Equals(m.Elements()))
+	assert.True(m.Struct().Equals(b.Merge(a).Struct()))
+
+	rt, ok := ORSetFromValue(m.Struct())
+	assert.True(ok)
+	assert.True(m.Elements().Equals(rt.Elements()))
+}
+
+func TestRGA(t *testing.T) {
+	assert := assert.New(t)
+	list := func(vs ...string) types.List {
+		l := types.NewList()
+		for _, v := range vs {
+			l = l.Append(types.String(v))
+		}
+		return l
+	}
+	l := NewRGA().Append("a", types.String("x")).Append("a", types.String("y")).Append("a", types.String("z"))
+	assert.True(list("x", "y", "z").Equals(l.Elements()))
+
+	// a and b insert at the same position concurrently: each run of inserts
+	// stays together, the latest first.
+	a := l.Insert("a", 1, types.String("a1")).Insert("a", 2, types.String("a2"))
+	b := l.Insert("b", 1, types.String("b1"))
+	m := a.Merge(b)
+	assert.True(list("x", "a1", "a2", "b1", "y", "z").Equals(m.Elements()))
+	assert.True(m.Struct().Equals(b.Merge(a).Struct()))
+
+	// a removes y while b inserts after it: b's insert keeps its place.
+	a = m.Remove(4)
+	b = m.Insert("b", 5, types.String("b2"))
+	assert.True(list("x", "a1", "a2", "b1", "z").Equals(a.Elements()))
+	m = a.Merge(b)
+	assert.True(list("x", "a1", "a2", "b1", "b2", "z").Equals(m.Elements()))
+	assert.True(m.Struct().Equals(b.Merge(a).Struct()))
+	assert.Equal(uint64(6), m.Len())
+
+	// Both remove the same element.
+	m = m.Remove(0).Merge(m.Remove(0))
+	assert.True(list("a1", "a2", "b1", "b2", "z").Equals(m.Elements()))
+
+	rt, ok := RGAFromValue(m.Struct())
+	assert.True(ok)
+	assert.True(m.Elements().Equals(rt.Elements()))
+	_, ok = RGAFromValue(NewORSet().Struct())
+	assert.False(ok)
+}
+
+func TestThreeWayMergesCRDTs(t *testing.T) {
+	assert := assert.New(t)
+	vs := types.NewTestValueStore()
+	defer vs.Close()
+
+	parent := types.NewMap(
+		types.String("count"), NewPNCounter().Add("a", 1).Struct(),
+		types.String("name"), NewLWWRegister("a", 1, types.String("p")).Struct(),
+	)
+	a := parent.Set(types.String("count"), NewPNCounter().Add("a", 1).Add("a", 2).Struct()).
+		Set(types.String("name"), NewLWWRegister("a", 3, types.String("a")).Struct())
+	b := parent.Set(types.String("count"), NewPNCounter().Add("a", 1).Add("b", 5).Struct()).
+		Set(types.String("name"), NewLWWRegister("b", 2, types.String("b")).Struct())
+
+	merged, err := merge.ThreeWay(a, b, parent, vs, nil, nil)
+	assert.NoError(err)
+	mm := merged.(types.Map)
+	c, ok := PNCounterFromValue(mm.Get(types.String("count")))
+	assert.True(ok)
+	assert.Equal(8.0, c.Value())
+	r, ok := LWWRegisterFromValue(mm.Get(types.String("name")))
+	assert.True(ok)
+	assert.True(types.String("a").Equals(r.Value()))
+
+	// Both candidates add a set at a new key.
+	a = a.Set(types.String("tags"), NewORSet().Add("a", 1, types.String("x")).Struct())
+	b = b.Set(types.String("tags"), NewORSet().Add("b", 1, types.String("y")).Struct())
+	merged, err = merge.ThreeWay(a, b, parent, vs, nil, nil)
+	assert.NoError(err)
+	s, ok := ORSetFromValue(merged.(types.Map).Get(types.String("tags")))
+	assert.True(ok)
+	assert.True(types.NewSet(types.String("x"), types.String("y")).Equals(s.Elements()))
+
+	// Both candidates edit a list.
+	l := NewRGA().Append("p", types.String("x"))
+	parent = parent.Set(types.String("list"), l.Struct())
+	a = a.Set(types.String("list"), l.Append("a", types.String("a")).Struct())
+	b = b.Set(types.String("list"), l.Remove(0).Insert("b", 0, types.String("b")).Struct())
+	merged, err = merge.ThreeWay(a, b, parent, vs, nil, nil)
+	assert.NoError(err)
+	ml, ok := RGAFromValue(merged.(types.Map).Get(types.String("list")))
+	assert.True(ok)
+	assert.True(types.NewList(types.String("b"), types.String("a")).Equals(ml.Elements()))
+}
+
+func TestCommitMergesCRDTs(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	ds, err := db.CommitValue(db.GetDataset("ds"), NewPNCounter().Struct())
+	assert.NoError(err)
+	base := ds.HeadRef()
+	ds, err = db.CommitValue(ds, NewPNCounter().Add("a", 1).Struct())
+	assert.NoError(err)
+
+	// Another device commits on top of base without seeing a's increment.
+	ds, err = db.Commit(ds, NewPNCounter().Add("b", 2).Struct(), datas.CommitOptions{
+		Parents: types.NewSet(base),
+		Policy:  merge.NewThreeWay(merge.None),
+	})
+	assert.NoError(err)
+	c, ok := PNCounterFromValue(ds.HeadValue())
+	assert.True(ok)
+	assert.Equal(3.0, c.Value())
+}
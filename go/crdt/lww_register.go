@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package crdt
+
+import (
+	"github.com/attic-labs/noms/go/types"
+)
+
+const lwwRegisterName = "LWWRegister"
+
+var lwwRegisterFields = []string{"replica", "timestamp", "value"}
+var lwwRegisterKinds = []types.NomsKind{types.StringKind, types.NumberKind, types.ValueKind}
+
+// LWWRegister is a last-writer-wins register, which holds the value set with
+// the latest timestamp:
+//
+//	struct LWWRegister {
+//	  replica: String,
+//	  timestamp: Number,
+//	  value: Value,
+//	}
+//
+// Timestamps are chosen by the replicas setting the register, e.g. as their
+// clock in milliseconds, so the latest of two values set at nearly the same
+// time on different replicas is only as accurate as their clocks. Equal
+// timestamps are ordered by replica, so merges still converge.
+type LWWRegister struct {
+	replica   types.String
+	timestamp types.Number
+	value     types.Value
+}
+
+// NewLWWRegister returns a register of v, set by replica at timestamp.
+func NewLWWRegister(replica string, timestamp float64, v types.Value) LWWRegister {
+	return LWWRegister{types.String(replica), types.Number(timestamp), v}
+}
+
+// LWWRegisterFromValue returns the LWWRegister v is the Struct of, if it is
+// one.
+func LWWRegisterFromValue(v types.Value) (LWWRegister, bool) {
+	f, ok := fields(v, lwwRegisterName, lwwRegisterFields, lwwRegisterKinds)
+	if !ok {
+		return LWWRegister{}, false
+	}
+	return LWWRegister{f[0].(types.String), f[1].(types.Number), f[2]}, true
+}
+
+// Struct returns the Noms value of r.
+func (r LWWRegister) Struct() types.Struct {
+	return types.NewStruct(lwwRegisterName, types.StructData{
+		"replica":   r.replica,
+		"timestamp": r.timestamp,
+		"value":     r.value,
+	})
+}
+
+// Set returns r set to v by replica at timestamp, unless r was already set
+// later.
+func (r LWWRegister) Set(replica string, timestamp float64, v types.Value) LWWRegister {
+	return r.Merge(NewLWWRegister(replica, timestamp, v))
+}
+
+// Value returns the value of r.
+func (r LWWRegister) Value() types.Value {
+	return r.value
+}
+
+// Timestamp returns the timestamp the value of r was set at.
+func (r LWWRegister) Timestamp() float64 {
+	return float64(r.timestamp)
+}
+
+// Merge returns whichever of r and o was set later.
+func (r LWWRegister) Merge(o LWWRegister) LWWRegister {
+	if r.later(o) {
+		return r
+	}
+	return o
+}
+
+func (r LWWRegister) later(o LWWRegister) bool {
+	if !r.timestamp.Equals(o.timestamp) {
+		return o.timestamp.Less(r.timestamp)
+	}
+	if !r.replica.Equals(o.replica) {
+		return o.replica.Less(r.replica)
+	}
+	// A replica set two values at the same time; pick either, consistently.
+	return !r.value.Less(o.value)
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package crdt
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+const orSetName = "ORSet"
+
+var orSetFields = []string{"adds", "removes"}
+var orSetKinds = []types.NomsKind{types.MapKind, types.SetKind}
+
+// ORSet is an observed-remove set: removing an element removes only the adds
+// of it the replica removing it has seen, so an element added on one replica
+// while it's removed on another remains once they're merged. Each add is
+// tagged uniquely, and removes are kept as the tags they remove:
+//
+//	struct ORSet {
+//	  adds: Map<Value, Set<String>>,
+//	  removes: Set<String>,
+//	}
+//
+// The tags of removed elements are kept, so an ORSet grows with each add.
+type ORSet struct {
+	adds    types.Map
+	removes types.Set
+}
+
+// NewORSet returns an empty set.
+func NewORSet() ORSet {
+	return ORSet{types.NewMap(), types.NewSet()}
+}
+
+// ORSetFromValue returns the ORSet v is the Struct of, if it is one.
+func ORSetFromValue(v types.Value) (ORSet, bool) {
+	f, ok := fields(v, orSetName, orSetFields, orSetKinds)
+	if !ok {
+		return ORSet{}, false
+	}
+	return ORSet{f[0].(types.Map), f[1].(types.Set)}, true
+}
+
+// Struct returns the Noms value of s.
+func (s ORSet) Struct() types.Struct {
+	return types.NewStruct(orSetName, types.StructData{"adds": s.adds, "removes": s.removes})
+}
+
+// Add returns s with v added by replica. Each add by a replica must be
+// distinguished by seq, e.g. a counter kept by the replica or the time.
+func (s ORSet) Add(replica string, seq uint64, v types.Value) ORSet {
+	tags := types.NewSet()
+	if t, ok := s.adds.MaybeGet(v); ok {
+		tags = t.(types.Set)
+	}
+	s.adds = s.adds.Set(v, tags.Insert(types.String(fmt.Sprintf("%s:%d", replica, seq))))
+	return s
+}
+
+// Remove returns s with v removed.
+func (s ORSet) Remove(v types.Value) ORSet {
+	if t, ok := s.adds.MaybeGet(v); ok {
+		s.removes = unionSets(s.removes, t.(types.Set))
+	}
+	return s
+}
+
+// Has returns true if s contains v.
+func (s ORSet) Has(v types.Value) bool {
+	t, ok := s.adds.MaybeGet(v)
+	return ok && s.live(t.(types.Set))
+}
+
+// Elements returns the elements s contains.
+func (s ORSet) Elements() types.Set {
+	elems := []types.Value{}
+	s.adds.IterAll(func(k, t types.Value) {
+		if s.live(t.(types.Set)) {
+			elems = append(elems, k)
+		}
+	})
+	return types.NewSet(elems...)
+}
+
+// Merge returns the merge of s and o.
+func (s ORSet) Merge(o ORSet) ORSet {
+	adds := mergeMaps(s.adds, o.adds, func(a, b types.Value) types.Value {
+		return unionSets(a.(types.Set), b.(types.Set))
+	})
+	return ORSet{adds, unionSets(s.removes, o.removes)}
+}
+
+// live returns true if any of the tags of an element aren't removed.
+func (s ORSet) live(tags types.Set) (live bool) {
+	tags.Iter(func(t types.Value) bool {
+		live = !s.removes.Has(t)
+		return live
+	})
+	return
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package crdt
+
+import (
+	"github.com/attic-labs/noms/go/types"
+)
+
+const pnCounterName = "PNCounter"
+
+var pnCounterFields = []string{"dec", "inc"}
+var pnCounterKinds = []types.NomsKind{types.MapKind, types.MapKind}
+
+// PNCounter is a counter which can be incremented and decremented. It's kept
+// as the totals added and subtracted by each replica:
+//
+//	struct PNCounter {
+//	  dec: Map<String, Number>,
+//	  inc: Map<String, Number>,
+//	}
+//
+// Merged counters have the greater of the totals of each replica, so Value is
+// the sum of the changes made by all the replicas.
+type PNCounter struct {
+	inc, dec types.Map
+}
+
+// NewPNCounter returns a counter of 0.
+func NewPNCounter() PNCounter {
+	return PNCounter{types.NewMap(), types.NewMap()}
+}
+
+// PNCounterFromValue returns the PNCounter v is the Struct of, if it is one.
+func PNCounterFromValue(v types.Value) (PNCounter, bool) {
+	f, ok := fields(v, pnCounterName, pnCounterFields, pnCounterKinds)
+	if !ok {
+		return PNCounter{}, false
+	}
+	return PNCounter{f[1].(types.Map), f[0].(types.Map)}, true
+}
+
+// Struct returns the Noms value of c.
+func (c PNCounter) Struct() types.Struct {
+	return types.NewStruct(pnCounterName, types.StructData{"inc": c.inc, "dec": c.dec})
+}
+
+// Add returns c with delta, which may be negative, added by replica.
+func (c PNCounter) Add(replica string, delta float64) PNCounter {
+	k := types.String(replica)
+	if delta >= 0 {
+		c.inc = c.inc.Set(k, types.Number(total(c.inc, k)+delta))
+	} else {
+		c.dec = c.dec.Set(k, types.Number(total(c.dec, k)-delta))
+	}
+	return c
+}
+
+// Value returns the count of c.
+func (c PNCounter) Value() float64 {
+	sum := 0.0
+	c.inc.IterAll(func(k, v types.Value) { sum += float64(v.(types.Number)) })
+	c.dec.IterAll(func(k, v types.Value) { sum -= float64(v.(types.Number)) })
+	return sum
+}
+
+// Merge returns the merge of c and o.
+func (c PNCounter) Merge(o PNCounter) PNCounter {
+	return PNCounter{mergeMaps(c.inc, o.inc, maxNumber), mergeMaps(c.dec, o.dec, maxNumber)}
+}
+
+func total(m types.Map, replica types.String) float64 {
+	if v, ok := m.MaybeGet(replica); ok {
+		return float64(v.(types.Number))
+	}
+	return 0
+}
+
+func maxNumber(a, b types.Value) types.Value {
+	if a.Less(b) {
+		return b
+	}
+	return a
+}
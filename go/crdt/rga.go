@@ -0,0 +1,185 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package crdt
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const rgaName = "RGA"
+const rgaElementName = "RGAElement"
+
+var rgaFields = []string{"elements", "removes"}
+var rgaKinds = []types.NomsKind{types.MapKind, types.SetKind}
+
+// RGA is a replicated growable array, a list in which elements are inserted
+// and removed by position, and concurrent inserts and removes merge
+// deterministically. Each element has a stable ID, and is inserted after the
+// element which preceded it when it was inserted, so concurrent inserts at
+// the same position on different replicas stay in one run each, and inserts
+// next to an element which is removed concurrently keep their place. Removed
+// elements are kept as tombstones:
+//
+//	struct RGA {
+//	  elements: Map<String, struct RGAElement {
+//	    after: String,  // the ID of the element this was inserted after, "" if first
+//	    replica: String,
+//	    seq: Number,
+//	    value: Value,
+//	  }>,
+//	  removes: Set<String>,
+//	}
+//
+// The IDs of elements are "<replica>:<seq>", where seq is a Lamport timestamp:
+// greater than those of all the elements the inserting replica has seen.
+// Elements inserted after the same element are ordered by descending seq and
+// then replica, so the latest insert at a position comes first.
+//
+// An RGA grows with each insert, and its order is computed from all of its
+// elements, so it suits lists edited by people, such as the text or items of
+// a collaborative document, rather than long lists.
+type RGA struct {
+	elements types.Map
+	removes  types.Set
+}
+
+type rgaElement struct {
+	id      string
+	after   string
+	replica string
+	seq     uint64
+	value   types.Value
+}
+
+// NewRGA returns an empty list.
+func NewRGA() RGA {
+	return RGA{types.NewMap(), types.NewSet()}
+}
+
+// RGAFromValue returns the RGA v is the Struct of, if it is one.
+func RGAFromValue(v types.Value) (RGA, bool) {
+	f, ok := fields(v, rgaName, rgaFields, rgaKinds)
+	if !ok {
+		return RGA{}, false
+	}
+	return RGA{f[0].(types.Map), f[1].(types.Set)}, true
+}
+
+// Struct returns the Noms value of l.
+func (l RGA) Struct() types.Struct {
+	return types.NewStruct(rgaName, types.StructData{"elements": l.elements, "removes": l.removes})
+}
+
+// Len returns the number of elements of l which aren't removed.
+func (l RGA) Len() uint64 {
+	return l.elements.Len() - l.removes.Len()
+}
+
+// Elements returns the elements of l which aren't removed, in order.
+func (l RGA) Elements() types.List {
+	values := []types.Value{}
+	for _, e := range l.live() {
+		values = append(values, e.value)
+	}
+	return types.NewList(values...)
+}
+
+// Insert returns l with v inserted by replica at idx, before the element at
+// idx, or at the end if idx is Len().
+func (l RGA) Insert(replica string, idx uint64, v types.Value) RGA {
+	live := l.live()
+	d.PanicIfTrue(idx > uint64(len(live)))
+	after := ""
+	if idx > 0 {
+		after = live[idx-1].id
+	}
+	seq := uint64(0)
+	l.elements.IterAll(func(k, ev types.Value) {
+		if s := uint64(ev.(types.Struct).Get("seq").(types.Number)); s > seq {
+			seq = s
+		}
+	})
+	seq++
+	id := fmt.Sprintf("%s:%d", replica, seq)
+	l.elements = l.elements.Set(types.String(id), types.NewStruct(rgaElementName, types.StructData{
+		"after":   types.String(after),
+		"replica": types.String(replica),
+		"seq":     types.Number(seq),
+		"value":   v,
+	}))
+	return l
+}
+
+// Append returns l with v inserted by replica at the end.
+func (l RGA) Append(replica string, v types.Value) RGA {
+	return l.Insert(replica, l.Len(), v)
+}
+
+// Remove returns l with the element at idx removed.
+func (l RGA) Remove(idx uint64) RGA {
+	live := l.live()
+	d.PanicIfTrue(idx >= uint64(len(live)))
+	l.removes = l.removes.Insert(types.String(live[idx].id))
+	return l
+}
+
+// Merge returns the merge of l and o.
+func (l RGA) Merge(o RGA) RGA {
+	elements := mergeMaps(l.elements, o.elements, func(a, b types.Value) types.Value {
+		// Elements with the same ID are the same insert.
+		return a
+	})
+	return RGA{elements, unionSets(l.removes, o.removes)}
+}
+
+// live returns the elements of l which aren't removed, in order.
+func (l RGA) live() []rgaElement {
+	children := map[string][]rgaElement{}
+	l.elements.IterAll(func(k, ev types.Value) {
+		s := ev.(types.Struct)
+		e := rgaElement{
+			id:      string(k.(types.String)),
+			after:   string(s.Get("after").(types.String)),
+			replica: string(s.Get("replica").(types.String)),
+			seq:     uint64(s.Get("seq").(types.Number)),
+			value:   s.Get("value"),
+		}
+		children[e.after] = append(children[e.after], e)
+	})
+	for _, c := range children {
+		sort.Slice(c, func(i, j int) bool {
+			if c[i].seq != c[j].seq {
+				return c[i].seq > c[j].seq
+			}
+			return c[i].replica < c[j].replica
+		})
+	}
+
+	// The order is a depth first walk of the tree of elements by the element
+	// they were inserted after, with an explicit stack, as the tree of a list
+	// appended to one element at a time is as deep as it's long.
+	live := []rgaElement{}
+	stack := []rgaElement{}
+	push := func(id string) {
+		c := children[id]
+		for i := len(c) - 1; i >= 0; i-- {
+			stack = append(stack, c[i])
+		}
+	}
+	push("")
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !l.removes.Has(types.String(e.id)) {
+			live = append(live, e)
+		}
+		push(e.id)
+	}
+	return live
+}
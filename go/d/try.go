@@ -79,6 +79,27 @@ func TryCatch(f func(), catch func(err error) error) (err error) {
 	return
 }
 
+// Recover is deferred by functions which return errors, so that they return
+// what they panic with in *errp, rather than panicking: the cause of a
+// WrappedError, any other error as it is, and other values, such as the
+// messages of failed d.Chk assertions, as errors with their text.
+func Recover(errp *error) {
+	if r := recover(); r != nil {
+		*errp = recoveredError(r)
+	}
+}
+
+// recoveredError returns the error Recover returns for the panic value r.
+func recoveredError(r interface{}) error {
+	switch r := r.(type) {
+	case wrappedError:
+		return r.Cause()
+	case error:
+		return r
+	}
+	return fmt.Errorf("%v", r)
+}
+
 type WrappedError interface {
 	Error() string
 	Cause() error
@@ -112,6 +112,32 @@ func TestTryCatch(t *testing.T) {
 	}())
 }
 
+func TestRecover(t *testing.T) {
+	assert := assert.New(t)
+
+	f := func(v interface{}) (err error) {
+		defer Recover(&err)
+		if v != nil {
+			panic(v)
+		}
+		return nil
+	}
+
+	assert.NoError(f(nil))
+	assert.Equal(te, f(Wrap(te)))
+	assert.Equal(te, f(te))
+	assert.Equal("not an error", f("not an error").Error())
+
+	// Failed assertions panic with their messages.
+	err := func() (err error) {
+		defer Recover(&err)
+		Chk.True(false, "assertion")
+		return nil
+	}()
+	assert.Error(err)
+	assert.Contains(err.Error(), "assertion")
+}
+
 func TestUnwrap(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,236 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// defaultGCRetention protects chunks written by requests in progress from
+// being collected by admin GC requests which don't give a retention.
+const defaultGCRetention = time.Hour
+
+// connectionInfo describes a connection to a RemoteDatabaseServer.
+type connectionInfo struct {
+	state http.ConnState
+	since time.Time
+}
+
+// AdminDataset is an entry of the response of the admin datasets endpoint.
+// Len is the number of entries of the value of the head if it's a
+// collection, and 0 otherwise. Chunks and Bytes are the number and size of
+// the chunks reachable from the head, including those of its history, which
+// may be shared with other datasets.
+type AdminDataset struct {
+	ID     string `json:"id"`
+	Head   string `json:"head"`
+	Height uint64 `json:"height"`
+	Kind   string `json:"kind"`
+	Len    uint64 `json:"len"`
+	Chunks uint64 `json:"chunks"`
+	Bytes  uint64 `json:"bytes"`
+}
+
+// AdminStats is the response of the admin stats endpoint.
+type AdminStats struct {
+	Root        string      `json:"root"`
+	Uptime      float64     `json:"uptime"` // in seconds
+	Requests    uint64      `json:"requests"`
+	Connections int         `json:"connections"`
+	ReadOnly    bool        `json:"readOnly"`
+	Maintenance bool        `json:"maintenance"`
+	Primary     string      `json:"primary,omitempty"` // if a replica
+	Store       interface{} `json:"store,omitempty"`
+}
+
+// AdminConnection is an entry of the response of the admin connections
+// endpoint.
+type AdminConnection struct {
+	Remote string    `json:"remote"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+}
+
+// adminHandle authenticates requests to f, and rejects them unless the
+// client has AdminAccess. The admin API is unavailable without Auth, as
+// anyone could use it otherwise.
+func (s *RemoteDatabaseServer) adminHandle(f httprouter.Handle) httprouter.Handle {
+	authed := s.authHandle(AdminAccess, f)
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if s.Auth == nil {
+			http.Error(w, "The admin API requires authentication to be configured", http.StatusForbidden)
+			return
+		}
+		authed(w, req, ps)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *RemoteDatabaseServer) handleAdminDatasets(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(s.cs))
+	datasets := []AdminDataset{}
+	if root := s.cs.Root(); !root.IsEmpty() {
+		vs.ReadValue(root).(types.Map).IterAll(func(k, v types.Value) {
+			r := v.(types.Ref)
+			ds := AdminDataset{ID: string(k.(types.String)), Head: r.TargetHash().String(), Height: r.Height()}
+			value := r.TargetValue(vs).(types.Struct).Get(ValueField)
+			ds.Kind = value.Kind().String()
+			if c, ok := value.(types.Collection); ok {
+				ds.Len = c.Len()
+			}
+			ds.Chunks, ds.Bytes = reachableSize(s.cs, vs, r)
+			datasets = append(datasets, ds)
+		})
+	}
+	writeJSON(w, datasets)
+}
+
+// reachableSize returns the number and total size of the chunks reachable
+// from r, which are read a level at a time.
+func reachableSize(cs chunks.ChunkStore, vr types.ValueReader, r types.Ref) (count, size uint64) {
+	visited := hash.HashSet{}
+	level := hash.HashSet{r.TargetHash(): struct{}{}}
+	for len(level) > 0 {
+		next := hash.HashSet{}
+		found := make(chan *chunks.Chunk, 16)
+		go func() {
+			defer close(found)
+			cs.GetMany(level, found)
+		}()
+		for c := range found {
+			visited.Insert(c.Hash())
+			count++
+			size += uint64(len(c.Data()))
+			types.DecodeValue(*c, vr).WalkRefs(func(r types.Ref) {
+				if h := r.TargetHash(); !visited.Has(h) && !level.Has(h) {
+					next.Insert(h)
+				}
+			})
+		}
+		level = next
+	}
+	return
+}
+
+func (s *RemoteDatabaseServer) handleAdminStats(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	s.mu.Lock()
+	stats := AdminStats{
+		Root:        s.cs.Root().String(),
+		Uptime:      time.Since(s.started).Seconds(),
+		Requests:    atomic.LoadUint64(&s.requests),
+		Connections: len(s.conns),
+		ReadOnly:    s.ReadOnly,
+		Maintenance: s.Maintenance,
+	}
+	if s.Replica != nil {
+		stats.Primary = s.Replica.primaryURL
+	}
+	s.mu.Unlock()
+	if s.StoreStats != nil {
+		stats.Store = s.StoreStats()
+	}
+	writeJSON(w, stats)
+}
+
+func (s *RemoteDatabaseServer) handleAdminConnections(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	s.mu.Lock()
+	conns := make([]AdminConnection, 0, len(s.conns))
+	for c, ci := range s.conns {
+		conns = append(conns, AdminConnection{remoteAddr(c), ci.state.String(), ci.since})
+	}
+	s.mu.Unlock()
+	sort.Slice(conns, func(i, j int) bool { return conns[i].Since.Before(conns[j].Since) })
+	writeJSON(w, conns)
+}
+
+func remoteAddr(c net.Conn) string {
+	if addr := c.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
+
+// handleAdminGC runs GC, protecting the chunks written within the duration
+// given by the retention parameter, or defaultGCRetention.
+func (s *RemoteDatabaseServer) handleAdminGC(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if s.GC == nil {
+		http.Error(w, "The database doesn't support garbage collection", http.StatusNotImplemented)
+		return
+	}
+	retention := defaultGCRetention
+	if r := req.URL.Query().Get("retention"); r != "" {
+		var err error
+		if retention, err = time.ParseDuration(r); err != nil {
+			http.Error(w, "Invalid retention: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	running := s.gcRunning
+	s.gcRunning = true
+	s.mu.Unlock()
+	if running {
+		http.Error(w, "Garbage collection is already running", http.StatusConflict)
+		return
+	}
+	defer func() {
+		s.mu.Lock()
+		s.gcRunning = false
+		s.mu.Unlock()
+	}()
+
+	result, err := s.GC(retention)
+	if err != nil {
+		http.Error(w, "Garbage collection failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleAdminReadOnly sets ReadOnly to the enabled parameter, and responds
+// with the new value.
+func (s *RemoteDatabaseServer) handleAdminReadOnly(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	enabled, err := strconv.ParseBool(req.URL.Query().Get("enabled"))
+	if err != nil {
+		http.Error(w, `Expected "enabled" query param of true or false`, http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.ReadOnly = enabled
+	s.mu.Unlock()
+	writeJSON(w, map[string]bool{"readOnly": enabled})
+}
+
+// handleAdminPromote stops the server from following its primary, so that
+// it accepts writes, e.g. to fail over when the primary is lost. It responds
+// with the root the replica had when it was promoted.
+func (s *RemoteDatabaseServer) handleAdminPromote(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	s.mu.Lock()
+	replica := s.Replica
+	s.Replica = nil
+	s.mu.Unlock()
+	if replica == nil {
+		http.Error(w, "The server isn't a replica", http.StatusConflict)
+		return
+	}
+	replica.Stop()
+	writeJSON(w, map[string]string{"root": s.cs.Root().String()})
+}
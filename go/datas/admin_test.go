@@ -0,0 +1,115 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// adminRequest sends a request as the client name, decoding the JSON
+// response into v if it's OK, and returns the status.
+func adminRequest(assert *assert.Assertions, method, url, name string, v interface{}) int {
+	req, err := http.NewRequest(method, url, nil)
+	assert.NoError(err)
+	req.Header.Set("Authorization", "Bearer "+name)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK && v != nil {
+		assert.NoError(json.NewDecoder(res.Body).Decode(v))
+	}
+	return res.StatusCode
+}
+
+func TestRemoteDatabaseServerAdmin(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := NewDatabase(cs)
+	ds, err := db.CommitValue(db.GetDataset("a"), types.NewList(types.Number(1)))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, types.NewList(types.Number(1), types.Number(2)))
+	assert.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("b"), types.String("b"))
+	assert.NoError(err)
+
+	var retention time.Duration
+	server := startTestServer(cs, func(s *RemoteDatabaseServer) {
+		s.Auth = testServerAuth{
+			"writer": {"a": WriteAccess},
+			"admin":  {"a": AdminAccess},
+		}
+		s.GC = func(r time.Duration) (interface{}, error) {
+			retention = r
+			return map[string]int{"reclaimed": 3}, nil
+		}
+		s.StoreStats = func() interface{} {
+			return map[string]int{"chunks": 7}
+		}
+	})
+	defer server.Stop()
+	base := fmt.Sprintf("http://localhost:%d", server.Port())
+
+	assert.Equal(http.StatusUnauthorized, adminRequest(assert, "GET", base+constants.AdminStatsPath, "nobody", nil))
+	assert.Equal(http.StatusForbidden, adminRequest(assert, "GET", base+constants.AdminStatsPath, "writer", nil))
+
+	datasets := []AdminDataset{}
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", base+constants.AdminDatasetsPath, "admin", &datasets))
+	assert.Len(datasets, 2)
+	assert.Equal("a", datasets[0].ID)
+	assert.Equal(ds.HeadRef().TargetHash().String(), datasets[0].Head)
+	assert.Equal(ds.HeadRef().Height(), datasets[0].Height)
+	assert.Equal("List", datasets[0].Kind)
+	assert.Equal(uint64(2), datasets[0].Len)
+	assert.Equal(uint64(2), datasets[0].Chunks) // the head and its parent
+	assert.Equal("b", datasets[1].ID)
+	assert.Equal("String", datasets[1].Kind)
+	// The only chunk of b is its head, in which the string is inlined.
+	head := cs.Get(db.GetDataset("b").HeadRef().TargetHash())
+	assert.Equal(uint64(1), datasets[1].Chunks)
+	assert.Equal(uint64(len(head.Data())), datasets[1].Bytes)
+
+	var stats AdminStats
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", base+constants.AdminStatsPath, "admin", &stats))
+	assert.Equal(cs.Root().String(), stats.Root)
+	assert.False(stats.ReadOnly)
+	assert.True(stats.Requests >= 4)
+	assert.Equal(map[string]interface{}{"chunks": float64(7)}, stats.Store)
+
+	conns := []AdminConnection{}
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", base+constants.AdminConnectionsPath, "admin", &conns))
+	assert.NotEmpty(conns)
+
+	var gc map[string]int
+	assert.Equal(http.StatusOK, adminRequest(assert, "POST", base+constants.AdminGCPath, "admin", &gc))
+	assert.Equal(map[string]int{"reclaimed": 3}, gc)
+	assert.Equal(defaultGCRetention, retention)
+	assert.Equal(http.StatusOK, adminRequest(assert, "POST", base+constants.AdminGCPath+"?retention=5m", "admin", nil))
+	assert.Equal(5*time.Minute, retention)
+	assert.Equal(http.StatusBadRequest, adminRequest(assert, "POST", base+constants.AdminGCPath+"?retention=soon", "admin", nil))
+
+	var readOnly map[string]bool
+	assert.Equal(http.StatusOK, adminRequest(assert, "POST", base+constants.AdminReadOnlyPath+"?enabled=true", "admin", &readOnly))
+	assert.Equal(map[string]bool{"readOnly": true}, readOnly)
+	assert.Equal(http.StatusForbidden, adminRequest(assert, "POST", base+constants.WriteValuePath, "writer", nil))
+	assert.Equal(http.StatusOK, adminRequest(assert, "POST", base+constants.AdminReadOnlyPath+"?enabled=false", "admin", nil))
+	assert.Equal(http.StatusBadRequest, adminRequest(assert, "POST", base+constants.AdminReadOnlyPath, "admin", nil))
+}
+
+func TestRemoteDatabaseServerAdminWithoutAuth(t *testing.T) {
+	assert := assert.New(t)
+	server := startTestServer(chunks.NewTestStore(), func(s *RemoteDatabaseServer) {})
+	defer server.Stop()
+	base := fmt.Sprintf("http://localhost:%d", server.Port())
+	assert.Equal(http.StatusForbidden, adminRequest(assert, "GET", base+constants.AdminStatsPath, "admin", nil))
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/marshal"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// AuditEntry records an update of the root of a database served by a
+// RemoteDatabaseServer. Client is the name the client authenticated as, if
+// the server has Auth, and Remote is its address.
+type AuditEntry struct {
+	Old    string
+	New    string
+	Client string
+	Remote string
+	Date   string // RFC 3339, in UTC
+}
+
+// AuditLog appends an AuditEntry for each update of the root of a database
+// to a dataset of another database, as commits of one parent each. As the
+// hash of a commit covers its parents, the head of the dataset commits to
+// all of its entries, so that any change to them can be detected by
+// VerifyAuditLog, given a head recorded elsewhere.
+type AuditLog struct {
+	db Database
+	id string
+	mu sync.Mutex
+}
+
+// NewAuditLog returns an AuditLog which appends to the dataset datasetID of
+// db. db must not be the database whose updates are logged, as appending to
+// it would update its root too.
+func NewAuditLog(db Database, datasetID string) *AuditLog {
+	d.PanicIfFalse(DatasetFullRe.MatchString(datasetID))
+	return &AuditLog{db: db, id: datasetID}
+}
+
+// Append appends e to the log.
+func (l *AuditLog) Append(e AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.append(e)
+}
+
+func (l *AuditLog) append(e AuditEntry) error {
+	v, err := marshal.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = l.db.CommitValue(l.db.GetDataset(l.id), v)
+	return err
+}
+
+// Verify verifies the log with VerifyAuditLog.
+func (l *AuditLog) Verify(known ...hash.Hash) (AuditVerification, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.db.Rebase()
+	return VerifyAuditLog(l.db.GetDataset(l.id), known...)
+}
+
+// AuditVerification is the result of VerifyAuditLog.
+type AuditVerification struct {
+	Entries uint64 `json:"entries"`
+	// Head is the hash of the head of the log, and Root the new root of its
+	// last entry.
+	Head string `json:"head"`
+	Root string `json:"root"`
+}
+
+// VerifyAuditLog checks that the entries of the audit log in ds form an
+// unbroken chain: that each commit has the one before as its only parent,
+// that each entry is an update from the root the one before updated to, and
+// that their dates don't go backwards. If known hashes of commits of the
+// log are given, e.g. heads recorded by VerifyAuditLog before, it checks
+// that they're still in the chain, which they wouldn't be if the log had
+// been rewritten since.
+func VerifyAuditLog(ds Dataset, known ...hash.Hash) (AuditVerification, error) {
+	v := AuditVerification{}
+	head, ok := ds.MaybeHead()
+	if !ok {
+		if len(known) > 0 {
+			return v, fmt.Errorf("Audit log %s is empty", ds.ID())
+		}
+		return v, nil
+	}
+	v.Head = head.Hash().String()
+
+	missing := map[hash.Hash]bool{}
+	for _, h := range known {
+		missing[h] = true
+	}
+	vr := ds.Database()
+	var next *AuditEntry
+	var nextDate time.Time
+	for commit := head; ; {
+		v.Entries++
+		delete(missing, commit.Hash())
+		var e AuditEntry
+		if err := marshal.Unmarshal(commit.Get(ValueField), &e); err != nil {
+			return v, fmt.Errorf("Commit %s isn't an audit entry: %s", commit.Hash(), err)
+		}
+		date, err := time.Parse(time.RFC3339Nano, e.Date)
+		if err != nil {
+			return v, fmt.Errorf("Audit entry %s has an invalid date: %s", commit.Hash(), err)
+		}
+		if next == nil {
+			v.Root = e.New
+		} else {
+			if next.Old != e.New {
+				return v, fmt.Errorf("Audit entry %s updates from %s, but the entry before updated to %s", commit.Hash(), next.Old, e.New)
+			}
+			if date.After(nextDate) {
+				return v, fmt.Errorf("Audit entry %s is dated %s, after the entry after it", commit.Hash(), e.Date)
+			}
+		}
+		next, nextDate = &e, date
+
+		parents := commit.Get(ParentsField).(types.Set)
+		switch parents.Len() {
+		case 0:
+			for h := range missing {
+				return v, fmt.Errorf("Audit log %s doesn't contain %s", ds.ID(), h)
+			}
+			return v, nil
+		case 1:
+			commit = parents.First().(types.Ref).TargetValue(vr).(types.Struct)
+		default:
+			return v, fmt.Errorf("Audit entry %s has %d parents", commit.Hash(), parents.Len())
+		}
+	}
+}
+
+// auditHandle appends the root updates made by requests to f, which must be
+// the handler of root POST requests, to Audit. Requests are handled one at
+// a time, so that entries are in the order of the updates. If an update
+// succeeds but can't be logged, the response is 500 Internal Server Error.
+func (s *RemoteDatabaseServer) auditHandle(f httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if s.Audit == nil {
+			f(w, req, ps)
+			return
+		}
+		s.Audit.mu.Lock()
+		defer s.Audit.mu.Unlock()
+		sw := &statusWriter{w, http.StatusOK}
+		f(sw, req, ps)
+		if sw.status != http.StatusOK {
+			return
+		}
+		q := req.URL.Query()
+		client, _ := req.Context().Value(clientNameKey{}).(string)
+		err := s.Audit.append(AuditEntry{
+			Old:    q.Get("last"),
+			New:    q.Get("current"),
+			Client: client,
+			Remote: req.RemoteAddr,
+			Date:   time.Now().UTC().Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			http.Error(w, "The root was updated, but couldn't be logged: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleAdminAudit verifies Audit, and that its last entry updated to the
+// current root, given the hashes of commits of the log to check for in the
+// known parameters. It responds with the AuditVerification if so, and 409
+// Conflict otherwise.
+func (s *RemoteDatabaseServer) handleAdminAudit(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if s.Audit == nil {
+		http.Error(w, "The server doesn't have an audit log", http.StatusNotImplemented)
+		return
+	}
+	known := []hash.Hash{}
+	for _, str := range req.URL.Query()["known"] {
+		h, ok := hash.MaybeParse(str)
+		if !ok {
+			http.Error(w, "Invalid hash: "+str, http.StatusBadRequest)
+			return
+		}
+		known = append(known, h)
+	}
+	v, err := s.Audit.Verify(known...)
+	if err == nil && v.Entries > 0 && v.Root != s.cs.Root().String() {
+		err = fmt.Errorf("The last audit entry updated the root to %s, but it's %s", v.Root, s.cs.Root())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, v)
+}
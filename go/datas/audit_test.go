@@ -0,0 +1,91 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/marshal"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	auditDB := NewDatabase(chunks.NewTestStore())
+	defer auditDB.Close()
+
+	server := startTestServer(cs, func(s *RemoteDatabaseServer) {
+		s.Auth = testServerAuth{"admin": {"a": AdminAccess}}
+		s.Audit = NewAuditLog(auditDB, "audit")
+	})
+	defer server.Stop()
+	base := fmt.Sprintf("http://localhost:%d", server.Port())
+
+	var v AuditVerification
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", base+constants.AdminAuditPath, "admin", &v))
+	assert.Equal(AuditVerification{}, v)
+
+	db := NewRemoteDatabase(base, "Bearer admin")
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("a"), types.Number(1))
+	assert.NoError(err)
+	first := cs.Root()
+	_, err = db.CommitValue(ds, types.Number(2))
+	assert.NoError(err)
+
+	auditDB.Rebase()
+	log := auditDB.GetDataset("audit")
+	var e AuditEntry
+	assert.NoError(marshal.Unmarshal(log.HeadValue(), &e))
+	assert.Equal(first.String(), e.Old)
+	assert.Equal(cs.Root().String(), e.New)
+	assert.Equal("admin", e.Client)
+
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", base+constants.AdminAuditPath, "admin", &v))
+	assert.Equal(uint64(2), v.Entries)
+	assert.Equal(log.HeadRef().TargetHash().String(), v.Head)
+	assert.Equal(cs.Root().String(), v.Root)
+	head := log.HeadRef().TargetHash()
+
+	// Rewriting the log is detected given a head recorded before.
+	forged, err := marshal.Marshal(AuditEntry{Old: hash.Hash{}.String(), New: cs.Root().String(), Client: "admin", Date: e.Date})
+	assert.NoError(err)
+	_, err = auditDB.SetHead(log, auditDB.WriteValue(NewCommit(forged, types.NewSet(), types.EmptyStruct)))
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", base+constants.AdminAuditPath, "admin", &v))
+	assert.Equal(uint64(1), v.Entries)
+	assert.Equal(http.StatusConflict, adminRequest(assert, "GET", base+constants.AdminAuditPath+"?known="+head.String(), "admin", nil))
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewTestStore())
+	defer db.Close()
+	log := NewAuditLog(db, "audit")
+	a, b, c := hash.Of([]byte("a")), hash.Of([]byte("b")), hash.Of([]byte("c"))
+
+	assert.NoError(log.Append(AuditEntry{Old: a.String(), New: b.String(), Date: "2017-01-01T00:00:00Z"}))
+	known := db.GetDataset("audit").HeadRef().TargetHash()
+	assert.NoError(log.Append(AuditEntry{Old: b.String(), New: c.String(), Date: "2017-01-01T00:00:01.5Z"}))
+	v, err := log.Verify(known)
+	assert.NoError(err)
+	assert.Equal(uint64(2), v.Entries)
+	assert.Equal(c.String(), v.Root)
+
+	// An entry which doesn't follow the one before breaks the chain.
+	assert.NoError(log.Append(AuditEntry{Old: a.String(), New: c.String(), Date: "2017-01-01T00:00:02Z"}))
+	_, err = log.Verify()
+	assert.Error(err)
+
+	_, err = VerifyAuditLog(db.GetDataset("other"), known)
+	assert.Error(err)
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"github.com/attic-labs/noms/go/types"
+)
+
+// BatchPrefix starts the IDs of the datasets which the intermediate commits
+// of squashed batch commits are made to, which are followed by the ID of the
+// dataset the edits are committed to. See CommitMapEdits.
+const BatchPrefix = "batches/"
+
+// DefaultMaxPendingBytes is the default of BatchCommitOptions.MaxPendingBytes.
+const DefaultMaxPendingBytes = 1 << 26 // 64MB
+
+// MapEdit is an edit of a Map: Key is set to Value, or removed if Value is
+// nil.
+type MapEdit struct {
+	Key   types.Value
+	Value types.Value
+}
+
+// BatchCommitOptions are the options of CommitMapEdits.
+type BatchCommitOptions struct {
+	// MaxPendingBytes bounds the edits which aren't committed: once the
+	// encoded size of the keys and values of the edits since the last commit
+	// exceeds it, they're committed. If zero, DefaultMaxPendingBytes.
+	MaxPendingBytes uint64
+	// Meta is the meta of the commits, see CommitOptions.
+	Meta types.Struct
+	// Squash commits all the edits to the dataset in one commit. The
+	// intermediate commits are made to a dataset of their own, BatchPrefix
+	// followed by the ID of the dataset, which is deleted after the last
+	// commit.
+	Squash bool
+}
+
+// CommitMapEdits commits the edits read from edits, until it's closed, to the
+// Map at the head of ds, or to an empty Map if ds has no head, and returns ds
+// as of the last commit. So that the memory used by edits which haven't been
+// committed stays bounded, however many edits there are, the edits are
+// committed each time opts.MaxPendingBytes of them are pending, as well as at
+// the end: the edits of a large import are committed in a series of commits,
+// each of which has the edits before it, unless opts.Squash.
+//
+// If ds is committed to concurrently, CommitMapEdits stops and returns
+// ErrMergeNeeded, and ds as of then. The rest of edits is read and discarded,
+// and the intermediate commits before then stay, unless opts.Squash. If there
+// are no edits, nothing is committed. If the head of ds isn't a Map,
+// CommitMapEdits panics.
+func CommitMapEdits(db Database, ds Dataset, edits <-chan MapEdit, opts BatchCommitOptions) (Dataset, error) {
+	defer func() {
+		for range edits {
+		}
+	}()
+	maxPending := opts.MaxPendingBytes
+	if maxPending == 0 {
+		maxPending = DefaultMaxPendingBytes
+	}
+
+	m := types.NewMap()
+	if head, ok := ds.MaybeHeadValue(); ok {
+		m = head.(types.Map)
+	}
+	batch := ds
+	if opts.Squash {
+		// Intermediate commits left by an earlier squash which failed are
+		// discarded, as are these once they're squashed, or if they fail.
+		var err error
+		if batch, err = db.Delete(db.GetDataset(BatchPrefix + ds.ID())); err != nil {
+			return ds, err
+		}
+		defer func() { db.Delete(batch) }()
+	}
+
+	pending, edited := uint64(0), false
+	commit := func() (err error) {
+		if batch, err = db.Commit(batch, m, CommitOptions{Meta: opts.Meta}); err != nil {
+			return err
+		}
+		// Edits are applied to the committed Map, the chunks of which are
+		// read as they're needed, rather than held in memory.
+		m = batch.HeadValue().(types.Map)
+		pending = 0
+		return nil
+	}
+	for edit := range edits {
+		pending += types.EncodedSize(edit.Key)
+		if edit.Value == nil {
+			m = m.Remove(edit.Key)
+		} else {
+			pending += types.EncodedSize(edit.Value)
+			m = m.Set(edit.Key, edit.Value)
+		}
+		edited = true
+		if pending > maxPending {
+			if err := commit(); err != nil {
+				if opts.Squash {
+					return ds, err
+				}
+				return batch, err
+			}
+		}
+	}
+
+	switch {
+	case !edited:
+		return ds, nil
+	case opts.Squash:
+		return db.Commit(ds, m, CommitOptions{Meta: opts.Meta})
+	case pending > 0:
+		err := commit()
+		return batch, err
+	}
+	return batch, nil
+}
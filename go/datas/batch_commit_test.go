@@ -0,0 +1,90 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// sendMapEdits returns a channel of edits setting the keys 0 to n-1 to their
+// squares, and removing the key "old".
+func sendMapEdits(n int) <-chan MapEdit {
+	edits := make(chan MapEdit)
+	go func() {
+		defer close(edits)
+		edits <- MapEdit{types.String("old"), nil}
+		for i := 0; i < n; i++ {
+			edits <- MapEdit{types.Number(i), types.Number(i * i)}
+		}
+	}()
+	return edits
+}
+
+func commitDepth(db Database, ds Dataset, base types.Ref) int {
+	n := 0
+	for r := ds.HeadRef(); !r.Equals(base); n++ {
+		parents := r.TargetValue(db).(types.Struct).Get(ParentsField).(types.Set)
+		r = parents.First().(types.Ref)
+	}
+	return n
+}
+
+func TestCommitMapEdits(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.NewMap(types.String("old"), types.Bool(true)))
+	assert.NoError(err)
+	base := ds.HeadRef()
+
+	expected := types.NewMap()
+	for i := 0; i < 1000; i++ {
+		expected = expected.Set(types.Number(i), types.Number(i*i))
+	}
+
+	// The edits are committed in a series of commits.
+	meta := types.NewStruct("Meta", types.StructData{"desc": types.String("import")})
+	ds, err = CommitMapEdits(db, ds, sendMapEdits(1000), BatchCommitOptions{MaxPendingBytes: 1000, Meta: meta})
+	assert.NoError(err)
+	assert.True(expected.Equals(ds.HeadValue()))
+	assert.True(commitDepth(db, ds, base) > 5)
+	assert.True(meta.Equals(ds.Head().Get(MetaField)))
+
+	// The edits are squashed into one commit.
+	ds, err = db.CommitValue(ds, types.NewMap(types.String("old"), types.Bool(true)))
+	assert.NoError(err)
+	base = ds.HeadRef()
+	ds, err = CommitMapEdits(db, ds, sendMapEdits(1000), BatchCommitOptions{MaxPendingBytes: 1000, Squash: true})
+	assert.NoError(err)
+	assert.True(expected.Equals(ds.HeadValue()))
+	assert.Equal(1, commitDepth(db, ds, base))
+	_, ok := db.GetDataset(BatchPrefix + "ds").MaybeHeadRef()
+	assert.False(ok)
+
+	// Without edits, nothing is committed.
+	head := ds.HeadRef()
+	none := make(chan MapEdit)
+	close(none)
+	ds, err = CommitMapEdits(db, ds, none, BatchCommitOptions{})
+	assert.NoError(err)
+	assert.Equal(head, ds.HeadRef())
+
+	// Concurrent commits stop the edits, which are drained.
+	stale := ds
+	ds, err = db.CommitValue(ds, types.NewMap())
+	assert.NoError(err)
+	for _, squash := range []bool{false, true} {
+		_, err = CommitMapEdits(db, stale, sendMapEdits(1000), BatchCommitOptions{MaxPendingBytes: 1000, Squash: squash})
+		assert.Equal(ErrMergeNeeded, err)
+		assert.Equal(ds.HeadRef(), db.GetDataset("ds").HeadRef())
+	}
+	_, ok = db.GetDataset(BatchPrefix + "ds").MaybeHeadRef()
+	assert.False(ok)
+}
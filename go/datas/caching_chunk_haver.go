@@ -33,6 +33,12 @@ func (ccs *cachingChunkHaver) Has(r hash.Hash) bool {
 	return has
 }
 
+// Insert records that r is present, as it's been put since it was last
+// checked for.
+func (ccs *cachingChunkHaver) Insert(r hash.Hash) {
+	setCache(ccs, r, true)
+}
+
 func checkCache(ccs *cachingChunkHaver, r hash.Hash) (has, ok bool) {
 	ccs.mu.RLock()
 	defer ccs.mu.RUnlock()
@@ -0,0 +1,157 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// ErrUnknownResumeToken is returned by ChangeFeed if the token it's given
+// isn't the Token of a Change of the dataset, e.g. because the dataset was
+// reset to another commit since the token was saved.
+var ErrUnknownResumeToken = errors.New("resume token isn't the dataset head or one of its ancestors")
+
+// Change is the change made to the value of a dataset by Commit. The structured
+// differences between OldValue and NewValue are computed by diff.ChangeDiff.
+type Change struct {
+	Commit types.Struct
+	// Parent is the parent of Commit whose value Commit is compared to, or the
+	// zero Ref if Commit is the first commit of the dataset.
+	Parent types.Ref
+	vr     types.ValueReader
+}
+
+// Token returns the resume token of c: a ChangeFeed given it resumes after c.
+func (c Change) Token() hash.Hash {
+	return c.Commit.Hash()
+}
+
+// HasParent returns true unless c is the first commit of the dataset.
+func (c Change) HasParent() bool {
+	return !c.Parent.TargetHash().IsEmpty()
+}
+
+// OldValue returns the value of the Parent of c, or nil if it has none.
+func (c Change) OldValue() types.Value {
+	if !c.HasParent() {
+		return nil
+	}
+	return c.Parent.TargetValue(c.vr).(types.Struct).Get(ValueField)
+}
+
+// NewValue returns the value of the Commit of c.
+func (c Change) NewValue() types.Value {
+	return c.Commit.Get(ValueField)
+}
+
+// ChangeIterator yields the Changes of a ChangeFeed in order.
+type ChangeIterator struct {
+	vr   types.ValueReader
+	refs []types.Ref // the Commits not yet yielded, newest first
+	// since is the commit ChangeFeed resumed after, which is the Parent of
+	// the first Change, or the zero Ref.
+	since types.Ref
+}
+
+// ChangeFeed returns the Changes made by the commits of ds after the one whose
+// resume token is since, oldest first. If since is the empty hash, it returns
+// all the Changes of ds. The commits are those of ds when ChangeFeed is called;
+// a later call with the Token of the last Change yields the Changes of the
+// commits made since.
+//
+// Commits are followed through their parent of greatest height, so of the
+// commits merged into ds, only the merge commits are yielded, with Changes
+// from their first line of parents. The commit of since may be any ancestor of
+// the head of ds, though, including one on another side of a merge: the
+// Changes are those of the commits of the first line which descend from it,
+// and the first of them is from since, so that it includes everything merged
+// since.
+func ChangeFeed(ds Dataset, since hash.Hash) (*ChangeIterator, error) {
+	vr := ds.Database()
+	it := &ChangeIterator{vr: vr}
+	r, ok := ds.MaybeHeadRef()
+	if since.IsEmpty() {
+		for ; ok; r, ok = highestParentRef(r.TargetValue(vr).(types.Struct)) {
+			it.refs = append(it.refs, r)
+		}
+		return it, nil
+	}
+	if !ok {
+		return nil, ErrUnknownResumeToken
+	}
+	if r.TargetHash() == since {
+		return it, nil
+	}
+	sinceCommit := vr.ReadValue(since)
+	if sinceCommit == nil || !IsCommitType(types.TypeOf(sinceCommit)) {
+		return nil, ErrUnknownResumeToken
+	}
+	it.since = types.NewRef(sinceCommit)
+	after := descendants(it.since, r, vr)
+	for ok && after.Has(r.TargetHash()) {
+		it.refs = append(it.refs, r)
+		r, ok = highestParentRef(r.TargetValue(vr).(types.Struct))
+	}
+	if len(it.refs) == 0 {
+		return nil, ErrUnknownResumeToken
+	}
+	return it, nil
+}
+
+// descendants returns the commits which are head or its ancestors, and which
+// descend from the commit ancestor, through any of their parents. The history
+// of head taller than ancestor is walked tallest first, and then which of it
+// descends from ancestor is worked out shortest first.
+func descendants(ancestor, head types.Ref, vr types.ValueReader) hash.HashSet {
+	walked := []types.Ref{}
+	w := newCommitWalk(vr, head, head)
+	for r, _, ok := w.next(); ok && r.Height() > ancestor.Height(); r, _, ok = w.next() {
+		walked = append(walked, r)
+	}
+	after := hash.HashSet{}
+	for i := len(walked) - 1; i >= 0; i-- {
+		r := walked[i]
+		r.TargetValue(vr).(types.Struct).Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			if h := v.(types.Ref).TargetHash(); h == ancestor.TargetHash() || after.Has(h) {
+				after.Insert(r.TargetHash())
+			}
+		})
+	}
+	return after
+}
+
+// Len returns the number of Changes not yet yielded by Next.
+func (it *ChangeIterator) Len() int {
+	return len(it.refs)
+}
+
+// Next returns the next Change, and false when there are no more.
+func (it *ChangeIterator) Next() (Change, bool) {
+	if len(it.refs) == 0 {
+		return Change{}, false
+	}
+	r := it.refs[len(it.refs)-1]
+	it.refs = it.refs[:len(it.refs)-1]
+	commit := r.TargetValue(it.vr).(types.Struct)
+	parent, _ := highestParentRef(commit)
+	if !it.since.TargetHash().IsEmpty() {
+		parent, it.since = it.since, types.Ref{}
+	}
+	return Change{commit, parent, it.vr}, true
+}
+
+// highestParentRef returns the parent of commit with the greatest height, if
+// it has any.
+func highestParentRef(commit types.Struct) (parent types.Ref, ok bool) {
+	commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+		if r := v.(types.Ref); !ok || r.Height() > parent.Height() {
+			parent, ok = r, true
+		}
+	})
+	return
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestChangeFeed(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := db.GetDataset("ds")
+
+	feed, err := ChangeFeed(ds, hash.Hash{})
+	assert.NoError(err)
+	_, ok := feed.Next()
+	assert.False(ok)
+
+	for i := 1; i <= 3; i++ {
+		ds, err = db.CommitValue(ds, types.Number(i))
+		assert.NoError(err)
+	}
+
+	feed, err = ChangeFeed(ds, hash.Hash{})
+	assert.NoError(err)
+	assert.Equal(3, feed.Len())
+	c, ok := feed.Next()
+	assert.True(ok)
+	assert.False(c.HasParent())
+	assert.Nil(c.OldValue())
+	assert.True(types.Number(1).Equals(c.NewValue()))
+
+	c, ok = feed.Next()
+	assert.True(ok)
+	assert.True(c.HasParent())
+	assert.True(types.Number(1).Equals(c.OldValue()))
+	assert.True(types.Number(2).Equals(c.NewValue()))
+	token := c.Token()
+
+	// Resuming after the second commit yields only the third.
+	feed, err = ChangeFeed(ds, token)
+	assert.NoError(err)
+	assert.Equal(1, feed.Len())
+	c, ok = feed.Next()
+	assert.True(ok)
+	assert.Equal(ds.Head().Hash(), c.Token())
+	assert.Equal(token, c.Parent.TargetHash())
+	_, ok = feed.Next()
+	assert.False(ok)
+
+	feed, err = ChangeFeed(ds, ds.Head().Hash())
+	assert.NoError(err)
+	assert.Equal(0, feed.Len())
+
+	_, err = ChangeFeed(ds, hash.Of([]byte("not a commit")))
+	assert.Equal(ErrUnknownResumeToken, err)
+}
+
+func TestChangeFeedMerge(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	// ds:    |1| <- |2| <- |4|
+	//          \         /
+	// other:    <- |3| <-
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	other, err := db.Commit(db.GetDataset("other"), types.Number(3), CommitOptions{Parents: types.NewSet(ds.HeadRef())})
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, types.Number(2))
+	assert.NoError(err)
+	other, err = db.CommitValue(other, types.Number(3.5))
+	assert.NoError(err)
+	ds, err = db.Commit(ds, types.Number(4), CommitOptions{Parents: types.NewSet(ds.HeadRef(), other.HeadRef())})
+	assert.NoError(err)
+
+	feed, err := ChangeFeed(ds, hash.Hash{})
+	assert.NoError(err)
+	values := []types.Value{}
+	for c, ok := feed.Next(); ok; c, ok = feed.Next() {
+		values = append(values, c.NewValue())
+	}
+	// The merge follows the higher parent, from other.
+	assert.Equal([]types.Value{types.Number(1), types.Number(3), types.Number(3.5), types.Number(4)}, values)
+}
+
+func TestChangeFeedResumeAcrossMerge(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	// a:  |1| <- |2| <------------- |6|
+	//       \                      /
+	// b:     <- |3| <- |4| <- |5| <-
+	a, err := db.CommitValue(db.GetDataset("a"), types.Number(1))
+	assert.NoError(err)
+	b, err := db.Commit(db.GetDataset("b"), types.Number(3), CommitOptions{Parents: types.NewSet(a.HeadRef())})
+	assert.NoError(err)
+	bFirst := b.HeadRef()
+	for _, n := range []float64{4, 5} {
+		b, err = db.CommitValue(b, types.Number(n))
+		assert.NoError(err)
+	}
+	a, err = db.CommitValue(a, types.Number(2))
+	assert.NoError(err)
+	oldHead := a.HeadRef()
+	a, err = db.Commit(a, types.Number(6), CommitOptions{Parents: types.NewSet(a.HeadRef(), b.HeadRef())})
+	assert.NoError(err)
+
+	// The old head of a is on the shorter side of the merge, which isn't
+	// followed, so the merge is compared to it.
+	feed, err := ChangeFeed(a, oldHead.TargetHash())
+	assert.NoError(err)
+	assert.Equal(1, feed.Len())
+	c, ok := feed.Next()
+	assert.True(ok)
+	assert.Equal(a.Head().Hash(), c.Token())
+	assert.Equal(oldHead.TargetHash(), c.Parent.TargetHash())
+	assert.True(types.Number(2).Equals(c.OldValue()))
+	assert.True(types.Number(6).Equals(c.NewValue()))
+
+	// Resuming after the first commit of b yields the rest of b, then the
+	// merge.
+	feed, err = ChangeFeed(a, bFirst.TargetHash())
+	assert.NoError(err)
+	values := []types.Value{}
+	for c, ok := feed.Next(); ok; c, ok = feed.Next() {
+		values = append(values, c.OldValue(), c.NewValue())
+	}
+	assert.Equal([]types.Value{types.Number(3), types.Number(4), types.Number(4), types.Number(5), types.Number(5), types.Number(6)}, values)
+
+	_, err = ChangeFeed(b, oldHead.TargetHash())
+	assert.Equal(ErrUnknownResumeToken, err)
+}
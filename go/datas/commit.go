@@ -5,10 +5,7 @@
 package datas
 
 import (
-	"sort"
-
 	"github.com/attic-labs/noms/go/d"
-	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nomdl"
 	"github.com/attic-labs/noms/go/types"
 )
@@ -48,7 +45,7 @@ func NewCommit(value types.Value, parents types.Set, meta types.Struct) types.St
 
 // FindCommonAncestor returns the most recent common ancestor of c1 and c2, if
 // one exists, setting ok to true. If there is no common ancestor, ok is set
-// to false.
+// to false. It's the MergeBase of c1 and c2.
 func FindCommonAncestor(c1, c2 types.Ref, vr types.ValueReader) (a types.Ref, ok bool) {
 	if !IsRefOfCommitType(types.TypeOf(c1)) {
 		d.Panic("FindCommonAncestor() called on %s", types.TypeOf(c1).Describe())
@@ -56,53 +53,7 @@ func FindCommonAncestor(c1, c2 types.Ref, vr types.ValueReader) (a types.Ref, ok
 	if !IsRefOfCommitType(types.TypeOf(c2)) {
 		d.Panic("FindCommonAncestor() called on %s", types.TypeOf(c2).Describe())
 	}
-
-	c1Q, c2Q := &types.RefByHeight{c1}, &types.RefByHeight{c2}
-	for !c1Q.Empty() && !c2Q.Empty() {
-		c1Ht, c2Ht := c1Q.MaxHeight(), c2Q.MaxHeight()
-		if c1Ht == c2Ht {
-			c1Parents, c2Parents := c1Q.PopRefsOfHeight(c1Ht), c2Q.PopRefsOfHeight(c2Ht)
-			if common := findCommonRef(c1Parents, c2Parents); (common != types.Ref{}) {
-				return common, true
-			}
-			parentsToQueue(c1Parents, c1Q, vr)
-			parentsToQueue(c2Parents, c2Q, vr)
-		} else if c1Ht > c2Ht {
-			parentsToQueue(c1Q.PopRefsOfHeight(c1Ht), c1Q, vr)
-		} else {
-			parentsToQueue(c2Q.PopRefsOfHeight(c2Ht), c2Q, vr)
-		}
-	}
-	return
-}
-
-func parentsToQueue(refs types.RefSlice, q *types.RefByHeight, vr types.ValueReader) {
-	for _, r := range refs {
-		c := r.TargetValue(vr).(types.Struct)
-		p := c.Get(ParentsField).(types.Set)
-		p.IterAll(func(v types.Value) {
-			q.PushBack(v.(types.Ref))
-		})
-	}
-	sort.Sort(q)
-}
-
-func findCommonRef(a, b types.RefSlice) types.Ref {
-	toRefSet := func(s types.RefSlice) map[hash.Hash]types.Ref {
-		out := map[hash.Hash]types.Ref{}
-		for _, r := range s {
-			out[r.TargetHash()] = r
-		}
-		return out
-	}
-
-	aSet, bSet := toRefSet(a), toRefSet(b)
-	for s, r := range aSet {
-		if _, present := bSet[s]; present {
-			return r
-		}
-	}
-	return types.Ref{}
+	return MergeBase(c1, c2, vr)
 }
 
 func makeCommitStructType(metaType, parentsType, valueType *types.Type) *types.Type {
@@ -0,0 +1,150 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"container/heap"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// The flags of the commits of a commitWalk, marking which of the commits it
+// started from they're reachable from.
+const (
+	fromLeft  uint8 = 1
+	fromRight uint8 = 2
+	fromBoth        = fromLeft | fromRight
+)
+
+// commitWalk walks the history of commits tallest first. Since a commit is
+// taller than its parents, each commit is visited after all its descendants
+// that the walk visits, so its flags are those of all of them.
+type commitWalk struct {
+	vr    types.ValueReader
+	queue refHeap
+	flags map[hash.Hash]uint8
+	// partial is the number of queued commits not flagged fromBoth. Once it's
+	// 0, the rest of the history is reachable from both sides.
+	partial int
+}
+
+func newCommitWalk(vr types.ValueReader, left, right types.Ref) *commitWalk {
+	for _, r := range []types.Ref{left, right} {
+		if !IsRefOfCommitType(types.TypeOf(r)) {
+			d.Panic("Commit graph walked from %s", types.TypeOf(r).Describe())
+		}
+	}
+	w := &commitWalk{vr: vr, flags: map[hash.Hash]uint8{}}
+	w.mark(left, fromLeft)
+	w.mark(right, fromRight)
+	return w
+}
+
+// mark adds flags to the commit r, queueing it if it's new.
+func (w *commitWalk) mark(r types.Ref, flags uint8) {
+	h := r.TargetHash()
+	old, queued := w.flags[h]
+	if !queued {
+		heap.Push(&w.queue, r)
+		if flags != fromBoth {
+			w.partial++
+		}
+	} else if old != fromBoth && old|flags == fromBoth {
+		// Commits are only marked while they're queued.
+		w.partial--
+	}
+	w.flags[h] = old | flags
+}
+
+// next visits the tallest queued commit, queueing its parents with its
+// flags, and returns it and its flags.
+func (w *commitWalk) next() (types.Ref, uint8, bool) {
+	if w.queue.Len() == 0 {
+		return types.Ref{}, 0, false
+	}
+	r := heap.Pop(&w.queue).(types.Ref)
+	flags := w.flags[r.TargetHash()]
+	if flags != fromBoth {
+		w.partial--
+	}
+	r.TargetValue(w.vr).(types.Struct).Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+		w.mark(v.(types.Ref), flags)
+	})
+	return r, flags, true
+}
+
+// refHeap is a heap of Refs, tallest first.
+type refHeap []types.Ref
+
+func (h refHeap) Len() int           { return len(h) }
+func (h refHeap) Less(i, j int) bool { return types.HeightOrder(h[i], h[j]) }
+func (h refHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *refHeap) Push(x interface{}) {
+	*h = append(*h, x.(types.Ref))
+}
+
+func (h *refHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// MergeBase returns the best common ancestor of the commits c1 and c2, the
+// tallest commit that's c1 or one of its ancestors and c2 or one of its
+// ancestors, if they have one. Of common ancestors of the same height, the one
+// with the lowest hash is returned.
+func MergeBase(c1, c2 types.Ref, vr types.ValueReader) (types.Ref, bool) {
+	w := newCommitWalk(vr, c1, c2)
+	for {
+		r, flags, ok := w.next()
+		if !ok {
+			return types.Ref{}, false
+		}
+		if flags == fromBoth {
+			return r, true
+		}
+	}
+}
+
+// IsAncestor returns true if the commit ancestor is the commit descendant or
+// one of its ancestors. Only the history of descendant taller than ancestor
+// is walked.
+func IsAncestor(ancestor, descendant types.Ref, vr types.ValueReader) bool {
+	if !IsRefOfCommitType(types.TypeOf(ancestor)) {
+		d.Panic("IsAncestor() called on %s", types.TypeOf(ancestor).Describe())
+	}
+	w := newCommitWalk(vr, descendant, descendant)
+	for {
+		r, _, ok := w.next()
+		if !ok || r.Height() < ancestor.Height() {
+			return false
+		}
+		if r.TargetHash() == ancestor.TargetHash() {
+			return true
+		}
+	}
+}
+
+// AheadBehind returns the number of commits which are c1 or its ancestors but
+// not c2 or its ancestors, ahead, and the number which are c2 or its ancestors
+// but not c1 or its ancestors, behind. The history common to both is only
+// walked until the rest of it is known to be common.
+func AheadBehind(c1, c2 types.Ref, vr types.ValueReader) (ahead, behind int) {
+	w := newCommitWalk(vr, c1, c2)
+	for w.partial > 0 {
+		_, flags, _ := w.next()
+		switch flags {
+		case fromLeft:
+			ahead++
+		case fromRight:
+			behind++
+		}
+	}
+	return
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestCommitGraph(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewTestStore())
+	defer db.Close()
+
+	addCommit := func(datasetID string, val string, parents ...types.Struct) types.Ref {
+		ds, err := db.Commit(db.GetDataset(datasetID), types.String(val), CommitOptions{Parents: toRefSet(parents...)})
+		assert.NoError(err)
+		return ds.HeadRef()
+	}
+	commit := func(r types.Ref) types.Struct {
+		return r.TargetValue(db).(types.Struct)
+	}
+
+	// ds-a: a1<-a2<-a3<-a4<-a5
+	//        ^    ^         /
+	//        |     \       /
+	// ds-b:  |      b3<-b4
+	//        |
+	// ds-c:  c2<-c3
+	//
+	// ds-d:  d1
+	a1 := addCommit("ds-a", "a1")
+	a2 := addCommit("ds-a", "a2", commit(a1))
+	a3 := addCommit("ds-a", "a3", commit(a2))
+	b3 := addCommit("ds-b", "b3", commit(a2))
+	a4 := addCommit("ds-a", "a4", commit(a3))
+	b4 := addCommit("ds-b", "b4", commit(b3))
+	a5 := addCommit("ds-a", "a5", commit(a4), commit(b4))
+	c2 := addCommit("ds-c", "c2", commit(a1))
+	c3 := addCommit("ds-c", "c3", commit(c2))
+	d1 := addCommit("ds-d", "d1")
+
+	assertMergeBase := func(expected, c1, c2 types.Ref) {
+		if base, ok := MergeBase(c1, c2, db); assert.True(ok) {
+			assert.Equal(commit(expected).Get(ValueField), commit(base).Get(ValueField))
+		}
+	}
+	assertMergeBase(a1, a1, a1)
+	assertMergeBase(a2, a3, b3)
+	assertMergeBase(a2, a4, b4)
+	assertMergeBase(b4, a5, b4)
+	assertMergeBase(a1, a5, c3)
+	_, ok := MergeBase(a5, d1, db)
+	assert.False(ok)
+
+	assert.True(IsAncestor(a1, a5, db))
+	assert.True(IsAncestor(b3, a5, db))
+	assert.True(IsAncestor(a5, a5, db))
+	assert.False(IsAncestor(a5, a4, db))
+	assert.False(IsAncestor(b3, a4, db))
+	assert.False(IsAncestor(c2, a5, db))
+	assert.False(IsAncestor(d1, a5, db))
+
+	assertAheadBehind := func(ahead, behind int, c1, c2 types.Ref) {
+		a, b := AheadBehind(c1, c2, db)
+		assert.Equal(ahead, a, "ahead of %s", commit(c2).Get(ValueField))
+		assert.Equal(behind, b, "behind %s", commit(c2).Get(ValueField))
+	}
+	assertAheadBehind(0, 0, a5, a5)
+	assertAheadBehind(4, 0, a5, a3) // a4, a5, b3, b4
+	assertAheadBehind(0, 4, a3, a5)
+	assertAheadBehind(2, 2, a4, b4) // a3, a4 and b3, b4
+	assertAheadBehind(6, 2, a5, c3) // all but a1, and c2, c3
+	assertAheadBehind(7, 1, a5, d1)
+
+	assert.Error(d.Try(func() { MergeBase(types.NewRef(types.Number(1)), a1, db) }))
+}
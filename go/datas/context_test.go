@@ -0,0 +1,300 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestDatabaseContext(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	db := NewDatabaseContext(ctx, cs)
+
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+	r := db.WriteValue(types.String("b"))
+
+	cancel()
+	_, err = db.CommitValue(ds, r)
+	assert.Equal(context.Canceled, err)
+	_, err = TryReadValue(db, types.String("c").Hash())
+	assert.Equal(context.Canceled, err)
+	assert.Equal(context.Canceled, db.Close())
+
+	// The ChunkStore is still open, with the first commit.
+	db = NewDatabase(cs)
+	defer db.Close()
+	assert.True(types.String("a").Equals(db.GetDataset("ds").HeadValue()))
+}
+
+func TestPullContext(t *testing.T) {
+	assert := assert.New(t)
+	srcDB := NewDatabase(chunks.NewMemoryStore())
+	defer srcDB.Close()
+	sinkCS := chunks.NewMemoryStore()
+	sinkDB := NewDatabase(sinkCS)
+
+	ds, err := srcDB.CommitValue(srcDB.GetDataset("ds"), types.NewList(types.Number(1), types.Number(2)))
+	assert.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(context.Canceled, PullContext(ctx, srcDB, sinkDB, ds.HeadRef(), types.Ref{}, 2, nil))
+	assert.False(sinkCS.Has(ds.HeadRef().TargetHash()))
+
+	assert.NoError(PullContext(context.Background(), srcDB, sinkDB, ds.HeadRef(), types.Ref{}, 2, nil))
+	assert.NoError(sinkDB.Close())
+	assert.True(sinkCS.Has(ds.HeadRef().TargetHash()))
+}
+
+// cancelingStore cancels a context once it's read the chunk numbered after.
+type cancelingStore struct {
+	chunks.ChunkStore
+	gets, after int32
+	cancel      func()
+}
+
+func (s *cancelingStore) Get(h hash.Hash) chunks.Chunk {
+	if atomic.AddInt32(&s.gets, 1) == s.after {
+		s.cancel()
+	}
+	return s.ChunkStore.Get(h)
+}
+
+func TestPullContextWithinRound(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	srcCS := &cancelingStore{ChunkStore: chunks.NewMemoryStore(), cancel: cancel}
+	srcDB := NewDatabase(srcCS)
+	defer srcDB.Close()
+	sinkDB := NewDatabase(chunks.NewMemoryStore())
+	defer sinkDB.Close()
+
+	nums := make([]types.Value, 50000)
+	for i := range nums {
+		nums[i] = types.Number(i)
+	}
+	ds, err := srcDB.CommitValue(srcDB.GetDataset("ds"), types.NewList(nums...))
+	assert.NoError(err)
+
+	// The rounds of the pull read the commit, and then each level of the
+	// List, so the 10th read is in the middle of one. The chunks left in it
+	// aren't read.
+	atomic.StoreInt32(&srcCS.gets, 0)
+	srcCS.after = 10
+	assert.Equal(context.Canceled, PullContext(ctx, srcDB, sinkDB, ds.HeadRef(), types.Ref{}, 1, nil))
+	assert.Equal(int32(10), atomic.LoadInt32(&srcCS.gets))
+
+	// Pulling again reads the rest, so that sinkDB can be flushed.
+	srcCS.after = 0
+	assert.NoError(PullContext(context.Background(), srcDB, sinkDB, ds.HeadRef(), types.Ref{}, 1, nil))
+	assert.True(atomic.LoadInt32(&srcCS.gets) > 20)
+	sinkDS, err := sinkDB.FastForward(sinkDB.GetDataset("ds"), ds.HeadRef())
+	assert.NoError(err)
+	assert.True(ds.HeadValue().Equals(sinkDS.HeadValue()))
+}
+
+func TestDatabaseCallContext(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := NewDatabase(cs)
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.CommitContext(ctx, ds, types.String("b"), CommitOptions{})
+	assert.Equal(context.Canceled, err)
+	_, err = db.ReadValueContext(ctx, ds.HeadRef().TargetHash())
+	assert.Equal(context.Canceled, err)
+	_, err = db.WriteValueContext(ctx, types.String("c"))
+	assert.Equal(context.Canceled, err)
+	assert.True(types.String("a").Equals(db.GetDataset("ds").HeadValue()))
+
+	// The other calls of db aren't canceled, and share its buffered values.
+	r, err := db.WriteValueContext(context.Background(), types.String("d"))
+	assert.NoError(err)
+	v, err := db.ReadValueContext(context.Background(), ds.HeadRef().TargetHash())
+	assert.NoError(err)
+	assert.True(ds.Head().Equals(v))
+	ds, err = db.CommitContext(context.Background(), ds, r, CommitOptions{})
+	assert.NoError(err)
+	assert.True(r.Equals(ds.HeadValue()))
+	ds, err = db.CommitValue(ds, types.String("e"))
+	assert.NoError(err)
+	assert.True(types.String("e").Equals(db.GetDataset("ds").HeadValue()))
+	assert.True(cs.Has(r.TargetHash()))
+}
+
+func TestRemoteDatabaseContext(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+
+	// The server answers requests for the root, but never responds to reads
+	// or writes of chunks.
+	router := httprouter.New()
+	router.GET(constants.RootPath, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		HandleRootGet(w, req, ps, cs)
+	})
+	hang := func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		// The server only notices the client went away once the body's read.
+		ioutil.ReadAll(req.Body)
+		<-req.Context().Done()
+	}
+	router.POST(constants.GetRefsPath, hang)
+	router.POST(constants.HasRefsPath, hang)
+	router.POST(constants.WriteValuePath, hang)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db := NewRemoteDatabaseContext(ctx, server.URL, "")
+	ds := db.GetDataset("ds")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := db.CommitValue(ds, types.String("a"))
+	assert.Equal(context.Canceled, err)
+	_, err = TryReadValue(db, types.String("a").Hash())
+	assert.Equal(context.Canceled, err)
+	assert.NoError(db.Close())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	db = NewRemoteDatabaseContext(ctx, server.URL, "")
+	defer db.Close()
+	_, err = TryReadValue(db, types.String("b").Hash())
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.True(cs.Root().IsEmpty())
+}
+
+func TestRemoteDatabaseCallContext(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+
+	// While hanging is set, the server never responds to reads or writes of
+	// chunks, or updates of the root.
+	var hanging int32
+	maybeHang := func(handle httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			if atomic.LoadInt32(&hanging) == 0 {
+				handle(w, req, ps)
+				return
+			}
+			ioutil.ReadAll(req.Body)
+			<-req.Context().Done()
+		}
+	}
+	withCS := func(handler Handler) httprouter.Handle {
+		return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			handler(w, req, ps, cs)
+		}
+	}
+	router := httprouter.New()
+	router.GET(constants.RootPath, withCS(HandleRootGet))
+	router.POST(constants.RootPath, maybeHang(withCS(HandleRootPost)))
+	router.POST(constants.GetRefsPath, maybeHang(withCS(HandleGetRefs)))
+	router.POST(constants.HasRefsPath, maybeHang(withCS(HandleHasRefs)))
+	router.POST(constants.WriteValuePath, maybeHang(withCS(HandleWriteValue)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	db := NewRemoteDatabase(server.URL, "")
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+
+	atomic.StoreInt32(&hanging, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.CommitContext(ctx, ds, types.String("b"), CommitOptions{})
+	assert.Equal(context.DeadlineExceeded, err)
+	_, err = db.ReadValueContext(ctx, types.String("c").Hash())
+	assert.Equal(context.DeadlineExceeded, err)
+
+	// The other calls of db aren't canceled.
+	atomic.StoreInt32(&hanging, 0)
+	v, err := db.ReadValueContext(context.Background(), ds.HeadRef().TargetHash())
+	assert.NoError(err)
+	assert.True(ds.Head().Equals(v))
+	ds, err = db.CommitContext(context.Background(), ds, types.String("c"), CommitOptions{})
+	assert.NoError(err)
+	assert.True(types.String("c").Equals(ds.HeadValue()))
+	assert.True(types.String("c").Equals(db.GetDataset("ds").HeadValue()))
+}
+
+func TestRemoteDatabaseCommitAfterCallContext(t *testing.T) {
+	assert := assert.New(t)
+	// The put caches of the client are made in TMPDIR.
+	tmpDir, err := ioutil.TempDir("", "context_test")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmpDir)
+
+	cs := chunks.NewTestStore()
+	router := httprouter.New()
+	for path, handler := range map[string]Handler{
+		constants.GetRefsPath:    HandleGetRefs,
+		constants.HasRefsPath:    HandleHasRefs,
+		constants.WriteValuePath: HandleWriteValue,
+	} {
+		handler := handler
+		router.POST(path, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			handler(w, req, ps, cs)
+		})
+	}
+	router.GET(constants.RootPath, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		HandleRootGet(w, req, ps, cs)
+	})
+	router.POST(constants.RootPath, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		HandleRootPost(w, req, ps, cs)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	db := NewRemoteDatabase(server.URL, "")
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+
+	// Each of the commits with a parent writes the same chunks: its own, and
+	// the Map of datasets. The chunks written by CommitContext aren't
+	// written again by the Commit after it.
+	writes := cs.Writes
+	ds, err = db.CommitContext(context.Background(), ds, types.String("b"), CommitOptions{})
+	assert.NoError(err)
+	perCommit := cs.Writes - writes
+	assert.NotZero(perCommit)
+	writes = cs.Writes
+	ds, err = db.CommitValue(ds, types.String("c"))
+	assert.NoError(err)
+	assert.Equal(perCommit, cs.Writes-writes)
+	_, err = db.ReadValueContext(context.Background(), ds.HeadRef().TargetHash())
+	assert.NoError(err)
+
+	// The put caches the calls flushed are all removed once db is closed.
+	assert.NoError(db.Close())
+	entries, err := ioutil.ReadDir(tmpDir)
+	assert.NoError(err)
+	assert.Empty(entries)
+}
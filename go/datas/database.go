@@ -6,7 +6,9 @@
 package datas
 
 import (
+	"context"
 	"io"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/hash"
@@ -22,6 +24,12 @@ import (
 // The Database API is stateful, meaning that calls to GetDataset() or
 // Datasets() occurring after a call to Commit() (et al) will represent the
 // result of the Commit().
+// A Database is safe for concurrent use by multiple goroutines, e.g. by the
+// handlers of a server sharing one instance. Concurrent updates of different
+// Datasets all succeed; of concurrent updates of the same Dataset, those which
+// aren't fast-forwards of each other fail with 'ErrMergeNeeded', as they
+// would from separate processes. Close must not be called concurrently with
+// other methods.
 type Database interface {
 	// To implement types.ValueWriter, Database implementations provide
 	// WriteValue(). WriteValue() writes v to this Database, though v is not
@@ -38,6 +46,11 @@ type Database interface {
 	// datasetID in the above Datasets Map.
 	GetDataset(datasetID string) Dataset
 
+	// Rebase makes Datasets() and GetDataset() reflect the current root of
+	// the backing storage, including updates made through other Databases
+	// since this one last read or updated it.
+	Rebase()
+
 	// Commit updates the Commit that ds.ID() in this database points at. All
 	// Values that have been written to this Database are guaranteed to be
 	// persistent after Commit() returns.
@@ -63,6 +76,18 @@ type Database interface {
 	// of a conflict, Commit returns an 'ErrMergeNeeded' error.
 	CommitValue(ds Dataset, v types.Value) (Dataset, error)
 
+	// ReadValueContext is like TryReadValue, and WriteValueContext like
+	// TryWriteValue, but they read and write the backing storage with ctx,
+	// so that they return ctx.Err() once ctx is done, without affecting
+	// other calls, e.g. those of other requests a server is handling.
+	ReadValueContext(ctx context.Context, h hash.Hash) (types.Value, error)
+	WriteValueContext(ctx context.Context, v types.Value) (types.Ref, error)
+
+	// CommitContext is like Commit, but reads and writes the backing storage
+	// with ctx, as ReadValueContext does. If it returns ctx.Err(), the
+	// commit may still have been made.
+	CommitContext(ctx context.Context, ds Dataset, v types.Value, opts CommitOptions) (Dataset, error)
+
 	// Delete removes the Dataset named ds.ID() from the map at the root of
 	// the Database. The Dataset data is not necessarily cleaned up at this
 	// time, but may be garbage collected in the future.
@@ -94,6 +119,43 @@ type Database interface {
 	// Regardless, Datasets() is updated to match backing storage upon return.
 	FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error)
 
+	// Rename moves the Head of ds to the Dataset named newID, which must not
+	// exist yet, and removes ds, in a single update of the root of the
+	// Database, so the history of ds is kept as is. If ds has moved since it
+	// was read, Rename returns an 'ErrMergeNeeded' error, and if newID
+	// exists, an 'ErrDatasetExists' error.
+	// The newest snapshot of the Dataset named newID is always returned.
+	Rename(ds Dataset, newID string) (Dataset, error)
+
+	// Copy is like Rename, but doesn't remove ds, so that both Datasets share
+	// the history of ds.
+	Copy(ds Dataset, newID string) (Dataset, error)
+
+	// Snapshot records the heads of all Datasets, other than those of
+	// snapshots, as the snapshot called label, in a single update of the
+	// root of the Database. If there's a snapshot called label already,
+	// Snapshot returns an 'ErrDatasetExists' error.
+	Snapshot(label string) (Snapshot, error)
+
+	// Restore moves the heads of all Datasets, other than those of
+	// snapshots, back to those of s, and removes the Datasets created since,
+	// in a single update of the root of the Database.
+	Restore(s Snapshot) error
+
+	// Pin keeps the value h, which must be in the Database, from being
+	// collected until it's unpinned as many times as it's pinned, or the pin
+	// expires, after ttl, or never if ttl is 0. If h isn't in the Database,
+	// Pin returns an 'ErrValueNotFound' error.
+	Pin(h hash.Hash, ttl time.Duration) (Pin, error)
+
+	// Unpin undoes a Pin of the value h, and returns its pin as of after,
+	// which is removed if its Count is 0. If h isn't pinned, Unpin returns
+	// an 'ErrNotPinned' error.
+	Unpin(h hash.Hash) (Pin, error)
+
+	// ExpirePins removes the pins which have expired, and returns them.
+	ExpirePins() ([]Pin, error)
+
 	// validatingBatchStore returns the BatchStore used to read and write
 	// groups of values to the database efficiently. This interface is a low-
 	// level detail of the database that should infrequently be needed by
@@ -101,8 +163,43 @@ type Database interface {
 	validatingBatchStore() types.BatchStore
 
 	has(h hash.Hash) bool
+
+	// markPresent records that the chunk h has been put to the database, so
+	// that has returns true for it without asking the database.
+	markPresent(h hash.Hash)
 }
 
 func NewDatabase(cs chunks.ChunkStore) Database {
 	return newLocalDatabase(cs)
 }
+
+// NewDatabaseContext is like NewDatabase, but the Database reads and writes cs
+// with ctx, as chunks.WithContext does, so that it stops using cs when ctx is
+// done, e.g. when the request it's handling is canceled. Then the Database
+// methods which return errors, and the Try functions, return ctx.Err(), and
+// the others panic with it. Many Databases can be made of one ChunkStore
+// this way, one for each request; closing them doesn't close cs.
+func NewDatabaseContext(ctx context.Context, cs chunks.ChunkStore) Database {
+	return contextDatabase{newLocalDatabase(chunks.WithContext(cs, ctx))}
+}
+
+// NewVerifyingDatabase is like NewDatabase, but the Database verifies the
+// chunks it reads from cs, as chunks.NewVerifyingStore does, and the values
+// it decodes from them, as types.ValueStore.SetVerifyReads does, so that it
+// panics rather than returning corrupt data. Reading is much slower.
+func NewVerifyingDatabase(cs chunks.ChunkStore) Database {
+	db := newLocalDatabase(chunks.NewVerifyingStore(cs))
+	db.SetVerifyReads(true)
+	return db
+}
+
+// contextDatabase is a Database whose Close returns the error it panics with
+// when its context is done, since it can't write the values written to it
+// then.
+type contextDatabase struct {
+	Database
+}
+
+func (cdb contextDatabase) Close() error {
+	return tryUpdate(cdb.Database.Close)
+}
@@ -6,29 +6,35 @@ package datas
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/nomserrors"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/metrics"
 )
 
 type databaseCommon struct {
 	*types.ValueStore
 	cch      *cachingChunkHaver
 	rt       chunks.RootTracker
+	mu       *sync.Mutex // guards rootHash and datasets
 	rootHash hash.Hash
 	datasets *types.Map
 }
 
 var (
-	ErrOptimisticLockFailed = errors.New("Optimistic lock failed on database Root update")
+	ErrOptimisticLockFailed = nomserrors.Wrap(nomserrors.ErrRootConflict, errors.New("Optimistic lock failed on database Root update"))
 	ErrMergeNeeded          = errors.New("Dataset head is not ancestor of commit")
+	ErrDatasetNotFound      = errors.New("Dataset not found")
+	ErrDatasetExists        = errors.New("Dataset already exists")
 )
 
 func newDatabaseCommon(cch *cachingChunkHaver, vs *types.ValueStore, rt chunks.RootTracker) databaseCommon {
-	return databaseCommon{ValueStore: vs, cch: cch, rt: rt, rootHash: rt.Root()}
+	return databaseCommon{ValueStore: vs, cch: cch, rt: rt, mu: &sync.Mutex{}, rootHash: rt.Root()}
 }
 
 func (dbc *databaseCommon) validatingBatchStore() types.BatchStore {
@@ -36,6 +42,8 @@ func (dbc *databaseCommon) validatingBatchStore() types.BatchStore {
 }
 
 func (dbc *databaseCommon) Datasets() types.Map {
+	dbc.mu.Lock()
+	defer dbc.mu.Unlock()
 	if dbc.datasets == nil {
 		if dbc.rootHash.IsEmpty() {
 			emptyMap := types.NewMap()
@@ -48,6 +56,36 @@ func (dbc *databaseCommon) Datasets() types.Map {
 	return *dbc.datasets
 }
 
+// root returns the hash of the root as of the last update of dbc.
+func (dbc *databaseCommon) root() hash.Hash {
+	dbc.mu.Lock()
+	defer dbc.mu.Unlock()
+	return dbc.rootHash
+}
+
+func (dbc *databaseCommon) Rebase() {
+	dbc.resetRoot()
+}
+
+// resetRoot makes Datasets() reflect the current root of the store, after an
+// update of dbc.
+func (dbc *databaseCommon) resetRoot() {
+	root := dbc.rt.Root()
+	dbc.mu.Lock()
+	defer dbc.mu.Unlock()
+	dbc.rootHash, dbc.datasets = root, nil
+}
+
+// setRoot makes Datasets() reflect root, e.g. after a view of dbc updated
+// it.
+func (dbc *databaseCommon) setRoot(root hash.Hash) {
+	dbc.mu.Lock()
+	defer dbc.mu.Unlock()
+	if root != dbc.rootHash {
+		dbc.rootHash, dbc.datasets = root, nil
+	}
+}
+
 func (dbc *databaseCommon) datasetsFromRef(datasetsRef hash.Hash) *types.Map {
 	c := dbc.ReadValue(datasetsRef).(types.Map)
 	return &c
@@ -69,6 +107,10 @@ func (dbc *databaseCommon) has(h hash.Hash) bool {
 	return dbc.cch.Has(h)
 }
 
+func (dbc *databaseCommon) markPresent(h hash.Hash) {
+	dbc.cch.Insert(h)
+}
+
 func (dbc *databaseCommon) Close() error {
 	return dbc.ValueStore.Close()
 }
@@ -78,7 +120,7 @@ func (dbc *databaseCommon) doSetHead(ds Dataset, newHeadRef types.Ref) error {
 		return nil
 	}
 	commit := dbc.validateRefAsCommit(newHeadRef)
-	defer func() { dbc.rootHash, dbc.datasets = dbc.rt.Root(), nil }()
+	defer dbc.resetRoot()
 
 	currentRootHash, currentDatasets := dbc.getRootAndDatasets()
 	commitRef := dbc.WriteValue(commit) // will be orphaned if the tryUpdateRoot() below fails
@@ -100,10 +142,11 @@ func (dbc *databaseCommon) doFastForward(ds Dataset, newHeadRef types.Ref) error
 
 // doCommit manages concurrent access the single logical piece of mutable state: the current Root. doCommit is optimistic in that it is attempting to update head making the assumption that currentRootHash is the hash of the current head. The call to UpdateRoot below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again. This method will also fail and return an 'ErrMergeNeeded' error if the |commit| is not a descendent of the current dataset head
 func (dbc *databaseCommon) doCommit(datasetID string, commit types.Struct, mergePolicy merge.Policy) error {
+	defer metrics.Timer("datas_commit")()
 	if !IsCommitType(types.TypeOf(commit)) {
 		d.Panic("Can't commit a non-Commit struct to dataset %s", datasetID)
 	}
-	defer func() { dbc.rootHash, dbc.datasets = dbc.rt.Root(), nil }()
+	defer dbc.resetRoot()
 
 	// This could loop forever, given enough simultaneous committers. BUG 2565
 	var err error
@@ -149,7 +192,7 @@ func (dbc *databaseCommon) doCommit(datasetID string, commit types.Struct, merge
 
 // doDelete manages concurrent access the single logical piece of mutable state: the current Root. doDelete is optimistic in that it is attempting to update head making the assumption that currentRootHash is the hash of the current head. The call to UpdateRoot below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again.
 func (dbc *databaseCommon) doDelete(datasetIDstr string) error {
-	defer func() { dbc.rootHash, dbc.datasets = dbc.rt.Root(), nil }()
+	defer dbc.resetRoot()
 
 	datasetID := types.String(datasetIDstr)
 	currentRootHash, currentDatasets := dbc.getRootAndDatasets()
@@ -177,6 +220,37 @@ func (dbc *databaseCommon) doDelete(datasetIDstr string) error {
 	return err
 }
 
+// doMove points newID at the head of ds, and removes ds unless keep is set, in one update of the root. Like doDelete, it retries if the root changed, unless ds moved or newID was created meanwhile.
+func (dbc *databaseCommon) doMove(ds Dataset, newID string, keep bool) error {
+	if !DatasetFullRe.MatchString(newID) {
+		d.Panic("Invalid dataset ID: %s", newID)
+	}
+	head, ok := ds.MaybeHeadRef()
+	if !ok {
+		return ErrDatasetNotFound
+	}
+	head = types.ToRefOfValue(head)
+	defer dbc.resetRoot()
+
+	oldID, newKey := types.String(ds.ID()), types.String(newID)
+	for {
+		currentRootHash, currentDatasets := dbc.getRootAndDatasets()
+		if r, hasHead := currentDatasets.MaybeGet(oldID); !hasHead || !head.Equals(r) {
+			return ErrMergeNeeded
+		}
+		if currentDatasets.Has(newKey) {
+			return ErrDatasetExists
+		}
+		currentDatasets = currentDatasets.Set(newKey, head)
+		if !keep {
+			currentDatasets = currentDatasets.Remove(oldID)
+		}
+		if err := dbc.tryUpdateRoot(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return err
+		}
+	}
+}
+
 func (dbc *databaseCommon) getRootAndDatasets() (currentRootHash hash.Hash, currentDatasets types.Map) {
 	currentRootHash = dbc.rt.Root()
 	currentDatasets = dbc.Datasets()
@@ -5,14 +5,19 @@
 package datas
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -27,17 +32,63 @@ type RemoteDatabaseServer struct {
 	l       *net.Listener
 	csChan  chan *connectionState
 	closing bool
+	started time.Time
+	// mu guards conns, gcRunning, and ReadOnly and Replica once the server is
+	// running.
+	mu        sync.Mutex
+	conns     map[net.Conn]*connectionInfo
+	gcRunning bool
+	// requests counts the requests served, other than health checks.
+	requests uint64
 	// Called just before the server is started.
 	Ready func()
+	// If set, restricts the datasets that can be queried with GraphQL.
+	Authorize DatasetAuthorizer
+	// If set, authenticates requests other than health checks, and restricts
+	// the datasets clients can read and write.
+	Auth ServerAuth
+	// If set, the server serves HTTPS, and HTTP/2 to clients which support it.
+	// It must have Certificates or GetCertificate set.
+	TLSConfig *tls.Config
+	// If true, requests which write to the database are rejected with 403
+	// Forbidden. It can be changed while running with the admin API.
+	ReadOnly bool
+	// If true, all requests other than health checks are rejected with 503
+	// Service Unavailable, and a Retry-After header of RetryAfter.
+	Maintenance bool
+	RetryAfter  time.Duration
+	// If set, updates of the root which move the heads of datasets are sent
+	// to these webhooks.
+	Webhooks []Webhook
+	// If set, POST requests to the admin GC endpoint call GC to remove the
+	// chunks which aren't reachable from the root, protecting those written
+	// within retention, and respond with its result as JSON.
+	GC func(retention time.Duration) (interface{}, error)
+	// If set, its result is included in responses of the admin stats
+	// endpoint as "store".
+	StoreStats func() interface{}
+	// If set, the server serves a replica of another server, which it starts
+	// and stops. Requests which write to the database are proxied to the
+	// primary or rejected, see Replica.ProxyWrites. The replica can be
+	// promoted to a primary with the admin API.
+	Replica *Replica
+	// If set, updates of the root are appended to Audit.
+	Audit *AuditLog
+
+	webhooks *webhookSender
 }
 
 func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *RemoteDatabaseServer {
 	dataVersion := cs.Version()
-	if constants.NomsVersion != dataVersion {
-		d.Panic("SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
+	if constants.DataVersion() != dataVersion {
+		d.Panic("SDK version %s is incompatible with data of version %s", constants.DataVersion(), dataVersion)
 	}
 	return &RemoteDatabaseServer{
-		cs, port, nil, make(chan *connectionState, 16), false, func() {},
+		cs:     cs,
+		port:   port,
+		csChan: make(chan *connectionState, 16),
+		conns:  map[net.Conn]*connectionInfo{},
+		Ready:  func() {},
 	}
 }
 
@@ -56,50 +107,93 @@ func (s *RemoteDatabaseServer) Run() {
 	d.Chk.NoError(err)
 	s.port, err = strconv.Atoi(port)
 	d.Chk.NoError(err)
-	fmt.Printf("Listening on port %d...\n", s.port)
+	verbose.Info("Listening", verbose.Fields{"port": s.port, "https": s.TLSConfig != nil})
+
+	if len(s.Webhooks) > 0 {
+		s.webhooks = newWebhookSender(s.Webhooks, s.cs)
+	}
 
 	router := httprouter.New()
 
-	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle(HandleGetRefs)))
-	router.GET(constants.GetBlobPath, s.corsHandle(s.makeHandle(HandleGetBlob)))
+	router.POST(constants.GetRefsPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(HandleGetRefs))))
+	router.GET(constants.GetBlobPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(HandleGetBlob))))
 	router.OPTIONS(constants.GetRefsPath, s.corsHandle(noopHandle))
-	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle(HandleHasRefs)))
+	router.POST(constants.HasRefsPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(HandleHasRefs))))
 	router.OPTIONS(constants.HasRefsPath, s.corsHandle(noopHandle))
-	router.GET(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootGet)))
-	router.POST(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootPost)))
+	router.POST(constants.PullPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(HandlePull))))
+	router.OPTIONS(constants.PullPath, s.corsHandle(noopHandle))
+	router.POST(constants.HasFilterPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(HandleHasFilter))))
+	router.OPTIONS(constants.HasFilterPath, s.corsHandle(noopHandle))
+	router.GET(constants.RootPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(HandleRootGet))))
+	router.POST(constants.RootPath, s.corsHandle(s.writeHandle(s.authHandle(WriteAccess, s.rootPostAuthHandle(s.auditHandle(s.webhookHandle(s.makeHandle(HandleRootPost))))))))
 	router.OPTIONS(constants.RootPath, s.corsHandle(noopHandle))
-	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
+	router.POST(constants.WriteValuePath, s.corsHandle(s.writeHandle(s.authHandle(WriteAccess, s.makeHandle(HandleWriteValue)))))
 	router.OPTIONS(constants.WriteValuePath, s.corsHandle(noopHandle))
 	router.GET(constants.BasePath, s.corsHandle(s.makeHandle(HandleBaseGet)))
+	router.GET(constants.HealthPath, s.corsHandle(s.makeHandle(HandleHealthGet)))
+
+	router.GET(constants.AdminDatasetsPath, s.adminHandle(s.handleAdminDatasets))
+	router.GET(constants.AdminStatsPath, s.adminHandle(s.handleAdminStats))
+	router.GET(constants.AdminConnectionsPath, s.adminHandle(s.handleAdminConnections))
+	router.POST(constants.AdminGCPath, s.adminHandle(s.handleAdminGC))
+	router.POST(constants.AdminReadOnlyPath, s.adminHandle(s.handleAdminReadOnly))
+	router.POST(constants.AdminPromotePath, s.adminHandle(s.handleAdminPromote))
+	router.GET(constants.AdminAuditPath, s.adminHandle(s.handleAdminAudit))
 
-	router.GET(constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
-	router.POST(constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
+	handleGraphQL := NewGraphQLHandler(s.graphQLAuthorizer())
+	router.GET(constants.GraphQLPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(handleGraphQL))))
+	router.POST(constants.GraphQLPath, s.corsHandle(s.authHandle(ReadAccess, s.makeHandle(handleGraphQL))))
 	router.OPTIONS(constants.GraphQLPath, s.corsHandle(noopHandle))
 
 	srv := &http.Server{
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != constants.HealthPath {
+				atomic.AddUint64(&s.requests, 1)
+			}
+			if s.Maintenance && req.URL.Path != constants.HealthPath {
+				w.Header().Set("Retry-After", strconv.Itoa(int(s.RetryAfter.Seconds())))
+				http.Error(w, "Down for maintenance", http.StatusServiceUnavailable)
+				return
+			}
 			router.ServeHTTP(w, req)
 		}),
 		ConnState: s.connState,
+		TLSConfig: s.TLSConfig,
 	}
 
 	go func() {
-		m := map[net.Conn]http.ConnState{}
 		for connState := range s.csChan {
+			s.mu.Lock()
 			switch connState.cs {
 			case http.StateNew, http.StateActive, http.StateIdle:
-				m[connState.c] = connState.cs
+				if ci, ok := s.conns[connState.c]; ok {
+					ci.state = connState.cs
+				} else {
+					s.conns[connState.c] = &connectionInfo{connState.cs, time.Now()}
+				}
 			default:
-				delete(m, connState.c)
+				delete(s.conns, connState.c)
 			}
+			s.mu.Unlock()
 		}
-		for c := range m {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for c := range s.conns {
 			c.Close()
 		}
 	}()
 
+	if s.Replica != nil {
+		s.Replica.Start()
+	}
+	s.started = time.Now()
 	go s.Ready()
-	srv.Serve(l)
+	if s.TLSConfig != nil {
+		// ServeTLS configures HTTP/2, which Serve doesn't for a TLS listener.
+		srv.ServeTLS(l, "", "")
+	} else {
+		srv.Serve(l)
+	}
 }
 
 func (s *RemoteDatabaseServer) makeHandle(hndlr Handler) httprouter.Handle {
@@ -108,6 +202,26 @@ func (s *RemoteDatabaseServer) makeHandle(hndlr Handler) httprouter.Handle {
 	}
 }
 
+// writeHandle rejects requests to f if the server is read-only, and proxies
+// them to the primary, or rejects them, if it's a replica.
+func (s *RemoteDatabaseServer) writeHandle(f httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		s.mu.Lock()
+		readOnly, replica := s.ReadOnly, s.Replica
+		s.mu.Unlock()
+		switch {
+		case readOnly:
+			http.Error(w, "Database is read-only", http.StatusForbidden)
+		case replica != nil && replica.ProxyWrites:
+			replica.serveProxy(w, req)
+		case replica != nil:
+			http.Error(w, "Database is a replica, write to its primary", http.StatusForbidden)
+		default:
+			f(w, req, ps)
+		}
+	}
+}
+
 func noopHandle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 }
 
@@ -118,9 +232,9 @@ func (s *RemoteDatabaseServer) corsHandle(f httprouter.Handle) httprouter.Handle
 		// Can't use * when clients are using cookies.
 		w.Header().Add("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 		w.Header().Add("Access-Control-Allow-Methods", "GET, POST")
-		w.Header().Add("Access-Control-Allow-Headers", NomsVersionHeader)
+		w.Header().Add("Access-Control-Allow-Headers", NomsVersionHeader+", Content-Type, Authorization")
 		w.Header().Add("Access-Control-Expose-Headers", NomsVersionHeader)
-		w.Header().Add(NomsVersionHeader, constants.NomsVersion)
+		w.Header().Add(NomsVersionHeader, constants.DataVersion())
 		f(w, r, ps)
 	}
 }
@@ -137,6 +251,15 @@ func (s *RemoteDatabaseServer) connState(c net.Conn, cs http.ConnState) {
 func (s *RemoteDatabaseServer) Stop() {
 	s.closing = true
 	(*s.l).Close()
+	s.mu.Lock()
+	replica := s.Replica
+	s.mu.Unlock()
+	if replica != nil {
+		replica.Stop()
+	}
+	if s.webhooks != nil {
+		s.webhooks.close()
+	}
 	(s.cs).Close()
 	close(s.csChan)
 }
@@ -0,0 +1,178 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// selfSignedCert returns a certificate for localhost.
+func selfSignedCert(assert *assert.Assertions) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTestServer runs a server configured by configure, and returns once
+// it's ready.
+func startTestServer(cs chunks.ChunkStore, configure func(s *RemoteDatabaseServer)) *RemoteDatabaseServer {
+	server := NewRemoteDatabaseServer(cs, 0)
+	configure(server)
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+	return server
+}
+
+func testServerRequest(assert *assert.Assertions, client *http.Client, method, url string) *http.Response {
+	return testServerRequestAs(assert, client, method, url, "")
+}
+
+func testServerRequestAs(assert *assert.Assertions, client *http.Client, method, url, auth string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	assert.NoError(err)
+	req.Header.Set(NomsVersionHeader, constants.NomsVersion)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	res, err := client.Do(req)
+	assert.NoError(err)
+	res.Body.Close()
+	return res
+}
+
+func TestRemoteDatabaseServerTLS(t *testing.T) {
+	assert := assert.New(t)
+	server := startTestServer(chunks.NewTestStore(), func(s *RemoteDatabaseServer) {
+		s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{selfSignedCert(assert)}}
+	})
+	defer server.Stop()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	res := testServerRequest(assert, client, "GET", fmt.Sprintf("https://localhost:%d%s", server.Port(), constants.RootPath))
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(2, res.ProtoMajor)
+}
+
+func TestRemoteDatabaseServerReadOnly(t *testing.T) {
+	assert := assert.New(t)
+	server := startTestServer(chunks.NewTestStore(), func(s *RemoteDatabaseServer) { s.ReadOnly = true })
+	defer server.Stop()
+
+	base := fmt.Sprintf("http://localhost:%d", server.Port())
+	res := testServerRequest(assert, http.DefaultClient, "GET", base+constants.RootPath)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	res = testServerRequest(assert, http.DefaultClient, "POST", base+constants.RootPath)
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+	res = testServerRequest(assert, http.DefaultClient, "POST", base+constants.WriteValuePath)
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func TestRemoteDatabaseServerMaintenance(t *testing.T) {
+	assert := assert.New(t)
+	server := startTestServer(chunks.NewTestStore(), func(s *RemoteDatabaseServer) {
+		s.Maintenance = true
+		s.RetryAfter = 2 * time.Minute
+	})
+	defer server.Stop()
+
+	base := fmt.Sprintf("http://localhost:%d", server.Port())
+	res := testServerRequest(assert, http.DefaultClient, "GET", base+constants.RootPath)
+	assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal("120", res.Header.Get("Retry-After"))
+	res = testServerRequest(assert, http.DefaultClient, "GET", base+constants.HealthPath)
+	assert.Equal(http.StatusOK, res.StatusCode)
+}
+
+// testServerAuth authenticates clients by the bearer token, which is their
+// name, and gives them the access in its map.
+type testServerAuth map[string]map[string]Access
+
+func (a testServerAuth) Authenticate(req *http.Request) (string, error) {
+	name := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if _, ok := a[name]; !ok {
+		return "", errors.New("Invalid credentials")
+	}
+	return name, nil
+}
+
+func (a testServerAuth) Access(name, datasetID string) Access {
+	access := NoAccess
+	for id, acc := range a[name] {
+		if (datasetID == "" || id == datasetID) && acc > access {
+			access = acc
+		}
+	}
+	return access
+}
+
+func TestRemoteDatabaseServerAuth(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := NewDatabase(cs)
+	_, err := db.CommitValue(db.GetDataset("a"), types.Number(1))
+	assert.NoError(err)
+	root := cs.Root()
+
+	server := startTestServer(cs, func(s *RemoteDatabaseServer) {
+		s.Auth = testServerAuth{
+			"reader": {"a": ReadAccess},
+			"writer": {"a": WriteAccess},
+			"other":  {"b": WriteAccess},
+		}
+	})
+	defer server.Stop()
+
+	base := fmt.Sprintf("http://localhost:%d", server.Port())
+	res := testServerRequest(assert, http.DefaultClient, "GET", base+constants.RootPath)
+	assert.Equal(http.StatusUnauthorized, res.StatusCode)
+	res = testServerRequestAs(assert, http.DefaultClient, "GET", base+constants.RootPath, "Bearer nobody")
+	assert.Equal(http.StatusUnauthorized, res.StatusCode)
+	res = testServerRequest(assert, http.DefaultClient, "GET", base+constants.HealthPath)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	res = testServerRequestAs(assert, http.DefaultClient, "GET", base+constants.RootPath, "Bearer reader")
+	assert.Equal(http.StatusOK, res.StatusCode)
+	res = testServerRequestAs(assert, http.DefaultClient, "POST", base+constants.WriteValuePath, "Bearer reader")
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+
+	// Setting the root to the one it already has changes the dataset a, when
+	// compared to the empty root, so fails with a conflict if it's allowed.
+	setRoot := fmt.Sprintf("%s%s?last=%s&current=%s", base, constants.RootPath, hash.Hash{}.String(), root.String())
+	res = testServerRequestAs(assert, http.DefaultClient, "POST", setRoot, "Bearer other")
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+	res = testServerRequestAs(assert, http.DefaultClient, "POST", setRoot, "Bearer writer")
+	assert.Equal(http.StatusConflict, res.StatusCode)
+}
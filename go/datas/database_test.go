@@ -5,7 +5,10 @@
 package datas
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/hash"
@@ -36,6 +39,44 @@ func TestValidateRef(t *testing.T) {
 	assert.Panics(t, func() { db.validateRefAsCommit(types.NewRef(b)) })
 }
 
+func TestConcurrentDatabaseUse(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	const writers, commits = 4, 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		id := fmt.Sprintf("ds%d", i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < commits; j++ {
+				l := types.NewList(types.Number(j), types.String(id))
+				_, err := db.CommitValue(db.GetDataset(id), db.WriteValue(l))
+				assert.NoError(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < commits; j++ {
+				if head, ok := db.GetDataset(id).MaybeHeadValue(); ok {
+					assert.NotNil(head.(types.Ref).TargetValue(db))
+				}
+				db.Datasets()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(uint64(writers), db.Datasets().Len())
+	for i := 0; i < writers; i++ {
+		id := fmt.Sprintf("ds%d", i)
+		l := db.GetDataset(id).HeadValue().(types.Ref).TargetValue(db)
+		assert.True(types.NewList(types.Number(commits-1), types.String(id)).Equals(l))
+	}
+}
+
 type DatabaseSuite struct {
 	suite.Suite
 	cs     *chunks.TestStore
@@ -101,7 +142,8 @@ func (suite *DatabaseSuite) TestReadWriteCachePersists() {
 func (suite *RemoteDatabaseSuite) TestWriteRefToNonexistentValue() {
 	ds := suite.db.GetDataset("foo")
 	r := types.NewRef(types.Bool(true))
-	suite.Panics(func() { suite.db.CommitValue(ds, r) })
+	_, err := suite.db.CommitValue(ds, r)
+	suite.Error(err)
 }
 
 func (suite *DatabaseSuite) TestTolerateUngettableRefs() {
@@ -131,6 +173,20 @@ func (suite *DatabaseSuite) TestCommitProperlyTracksRoot() {
 	suite.False(ds1.HeadValue().Equals(db2HeadVal))
 }
 
+func (suite *DatabaseSuite) TestRebase() {
+	db1 := suite.makeDb(suite.cs)
+	defer db1.Close()
+	db2 := suite.makeDb(suite.cs)
+	defer db2.Close()
+
+	_, err := db1.CommitValue(db1.GetDataset("ds"), types.String("a"))
+	suite.NoError(err)
+	suite.False(db2.GetDataset("ds").HasHead())
+
+	db2.Rebase()
+	suite.True(db2.GetDataset("ds").HeadValue().Equals(types.String("a")))
+}
+
 func (suite *DatabaseSuite) TestDatabaseCommit() {
 	datasetID := "ds1"
 	datasets := suite.db.Datasets()
@@ -306,6 +362,160 @@ func (suite *DatabaseSuite) TestDatabaseDelete() {
 	suite.True(present, "Dataset %s should be present", datasetID2)
 }
 
+func (suite *DatabaseSuite) TestDatabaseRename() {
+	ds1, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+	ds1, err = suite.db.CommitValue(ds1, types.String("b"))
+	suite.NoError(err)
+	ds2, err := suite.db.CommitValue(suite.db.GetDataset("ds2"), types.String("c"))
+	suite.NoError(err)
+	head := ds1.HeadRef()
+
+	_, err = suite.db.Rename(ds1, ds2.ID())
+	suite.Equal(ErrDatasetExists, err)
+	_, err = suite.db.Rename(suite.db.GetDataset("none"), "ds3")
+	suite.Equal(ErrDatasetNotFound, err)
+	suite.Panics(func() { suite.db.Rename(ds1, "not a dataset") })
+
+	ds3, err := suite.db.Rename(ds1, "ds3")
+	suite.NoError(err)
+	suite.True(head.Equals(ds3.HeadRef()))
+	suite.True(ds3.HeadValue().Equals(types.String("b")))
+	_, present := suite.db.GetDataset("ds1").MaybeHeadRef()
+	suite.False(present)
+
+	// ds1 has moved, so it can't be renamed again.
+	_, err = suite.db.Rename(ds1, "ds4")
+	suite.Equal(ErrMergeNeeded, err)
+
+	newDB := suite.makeDb(suite.cs)
+	defer newDB.Close()
+	suite.Equal(uint64(2), newDB.Datasets().Len())
+	suite.True(head.Equals(newDB.GetDataset("ds3").HeadRef()))
+}
+
+func (suite *DatabaseSuite) TestDatabaseCopy() {
+	ds1, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+
+	ds2, err := suite.db.Copy(ds1, "ds2")
+	suite.NoError(err)
+	suite.True(ds1.HeadRef().Equals(ds2.HeadRef()))
+	suite.True(ds1.HeadRef().Equals(suite.db.GetDataset("ds1").HeadRef()))
+
+	_, err = suite.db.Copy(ds1, "ds2")
+	suite.Equal(ErrDatasetExists, err)
+
+	// The copies share history, so committing to one fast-forwards it.
+	ds2, err = suite.db.CommitValue(ds2, types.String("b"))
+	suite.NoError(err)
+	_, err = suite.db.FastForward(suite.db.GetDataset("ds1"), ds2.HeadRef())
+	suite.NoError(err)
+}
+
+func (suite *DatabaseSuite) TestDatabaseSnapshot() {
+	ds1, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+
+	s, err := suite.db.Snapshot("before")
+	suite.NoError(err)
+	suite.Equal("before", s.Label)
+	suite.False(s.Date.IsZero())
+	suite.Equal(uint64(1), s.Datasets.Len())
+	_, err = suite.db.Snapshot("before")
+	suite.Equal(ErrDatasetExists, err)
+
+	_, err = suite.db.CommitValue(ds1, types.String("b"))
+	suite.NoError(err)
+	_, err = suite.db.CommitValue(suite.db.GetDataset("ds2"), types.String("c"))
+	suite.NoError(err)
+	_, err = suite.db.Snapshot("after")
+	suite.NoError(err)
+
+	snapshots := Snapshots(suite.db)
+	suite.Len(snapshots, 2)
+	suite.Equal("after", snapshots[0].Label)
+	suite.Equal(uint64(2), snapshots[0].Datasets.Len())
+
+	// Restoring moves ds1 back, removes ds2, and keeps the snapshots.
+	s, ok := GetSnapshot(suite.db, "before")
+	suite.True(ok)
+	suite.NoError(suite.db.Restore(s))
+	suite.True(suite.db.GetDataset("ds1").HeadValue().Equals(types.String("a")))
+	suite.False(suite.db.GetDataset("ds2").HasHead())
+	suite.Len(Snapshots(suite.db), 2)
+
+	s, ok = GetSnapshot(suite.db, "after")
+	suite.True(ok)
+	suite.NoError(suite.db.Restore(s))
+	suite.True(suite.db.GetDataset("ds1").HeadValue().Equals(types.String("b")))
+	suite.True(suite.db.GetDataset("ds2").HeadValue().Equals(types.String("c")))
+}
+
+func (suite *DatabaseSuite) TestDatabasePin() {
+	l := types.NewList(types.String("a"), types.String("b"))
+	r := suite.db.WriteValue(l)
+
+	_, err := suite.db.Pin(types.String("missing").Hash(), 0)
+	suite.Equal(ErrValueNotFound, err)
+	_, err = suite.db.Unpin(r.TargetHash())
+	suite.Equal(ErrNotPinned, err)
+
+	p, err := suite.db.Pin(r.TargetHash(), time.Hour)
+	suite.NoError(err)
+	suite.Equal(r.TargetHash(), p.Value.TargetHash())
+	suite.Equal(uint64(1), p.Count)
+	suite.False(p.Expires.IsZero())
+	expires := p.Expires
+
+	// Pinning again counts the pin, which expires when the last does.
+	p, err = suite.db.Pin(r.TargetHash(), time.Minute)
+	suite.NoError(err)
+	suite.Equal(uint64(2), p.Count)
+	suite.Equal(expires, p.Expires)
+
+	pins := Pins(suite.db)
+	suite.Len(pins, 1)
+	suite.Equal(p, pins[0])
+	suite.True(suite.db.Datasets().Has(types.String(PinPrefix + r.TargetHash().String())))
+
+	p, err = suite.db.Unpin(r.TargetHash())
+	suite.NoError(err)
+	suite.Equal(uint64(1), p.Count)
+	p, ok := GetPin(suite.db, r.TargetHash())
+	suite.True(ok)
+	suite.Equal(uint64(1), p.Count)
+
+	p, err = suite.db.Unpin(r.TargetHash())
+	suite.NoError(err)
+	suite.Equal(uint64(0), p.Count)
+	_, ok = GetPin(suite.db, r.TargetHash())
+	suite.False(ok)
+
+	// Pins which don't expire keep the pin from expiring.
+	r2 := suite.db.WriteValue(types.String("c"))
+	_, err = suite.db.Pin(r.TargetHash(), time.Nanosecond)
+	suite.NoError(err)
+	_, err = suite.db.Pin(r2.TargetHash(), time.Nanosecond)
+	suite.NoError(err)
+	p, err = suite.db.Pin(r2.TargetHash(), 0)
+	suite.NoError(err)
+	suite.True(p.Expires.IsZero())
+	time.Sleep(time.Millisecond)
+
+	expired, err := suite.db.ExpirePins()
+	suite.NoError(err)
+	suite.Len(expired, 1)
+	suite.Equal(r.TargetHash(), expired[0].Value.TargetHash())
+	pins = Pins(suite.db)
+	suite.Len(pins, 1)
+	suite.Equal(r2.TargetHash(), pins[0].Value.TargetHash())
+
+	expired, err = suite.db.ExpirePins()
+	suite.NoError(err)
+	suite.Len(expired, 0)
+}
+
 type waitDuringUpdateRootChunkStore struct {
 	chunks.ChunkStore
 	preUpdateRootHook func()
@@ -8,11 +8,14 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +25,7 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/nomserrors"
 	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/golang/snappy"
 	"github.com/julienschmidt/httprouter"
@@ -38,6 +42,8 @@ var customHTTPTransport = http.Transport{
 	MaxIdleConnsPerHost: httpChunkSinkConcurrency,
 	// This sets, essentially, an idle-timeout. The timer starts counting AFTER the client has finished sending the entire request to the server. As soon as the client receives the server's response headers, the timeout is canceled.
 	ResponseHeaderTimeout: time.Duration(4) * time.Minute,
+	// Use HTTP/2 with https servers which support it, which a custom Transport otherwise doesn't.
+	ForceAttemptHTTP2: true,
 }
 
 // httpBatchStore implements types.BatchStore
@@ -45,6 +51,7 @@ type httpBatchStore struct {
 	host         *url.URL
 	httpClient   httpDoer
 	auth         string
+	ctx          context.Context
 	getQueue     chan chunks.ReadRequest
 	hasQueue     chan chunks.ReadRequest
 	finishedChan chan struct{}
@@ -52,11 +59,62 @@ type httpBatchStore struct {
 	requestWg    *sync.WaitGroup
 	workerWg     *sync.WaitGroup
 
-	cacheMu       *sync.RWMutex
-	unwrittenPuts *nbs.NomsBlockCache
+	// puts is shared with the views of the store withContext returns, so
+	// that their flushes replace the chunks written for all of them.
+	puts *putCache
+
+	// hasFilter is whether pushes ask for a bloom filter of the chunks the
+	// server has, see RemoteDatabaseClient.SetHasFilter.
+	hasFilter bool
 }
 
 func NewHTTPBatchStore(baseURL, auth string) *httpBatchStore {
+	return newHTTPBatchStore(baseURL, auth, &customHTTPTransport)
+}
+
+// NewHTTPBatchStoreTLS is like NewHTTPBatchStore, but makes https requests
+// with tlsConfig, e.g. to trust a private CA.
+func NewHTTPBatchStoreTLS(baseURL, auth string, tlsConfig *tls.Config) *httpBatchStore {
+	transport := customHTTPTransport.Clone()
+	transport.TLSClientConfig = tlsConfig
+	return newHTTPBatchStore(baseURL, auth, transport)
+}
+
+// NewHTTPBatchStoreContext is like NewHTTPBatchStore, but makes its requests
+// with ctx, so that they're canceled when it's done, or its deadline passes.
+// Then the methods of the store panic with ctx.Err(), rather than waiting on
+// a server which may never respond.
+func NewHTTPBatchStoreContext(ctx context.Context, baseURL, auth string) *httpBatchStore {
+	bhcs := newHTTPBatchStore(baseURL, auth, &customHTTPTransport)
+	bhcs.ctx = ctx
+	return bhcs
+}
+
+// withContext returns a view of bhcs which makes its requests with ctx, as
+// well as the context of bhcs, so that a single call can be canceled without
+// closing bhcs. The view shares the batches and unwritten chunks of bhcs,
+// whose reads it stops waiting on once ctx is done, rather than canceling
+// them for the other callers. It mustn't be closed; done must be called once
+// it's no longer used.
+func (bhcs *httpBatchStore) withContext(ctx context.Context) (view *httpBatchStore, done func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-bhcs.ctx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	v := *bhcs
+	v.ctx = ctx
+	return &v, func() {
+		close(stop)
+		cancel()
+	}
+}
+
+func newHTTPBatchStore(baseURL, auth string, transport *http.Transport) *httpBatchStore {
 	u, err := url.Parse(baseURL)
 	d.PanicIfError(err)
 	if u.Scheme != "http" && u.Scheme != "https" {
@@ -65,27 +123,69 @@ func NewHTTPBatchStore(baseURL, auth string) *httpBatchStore {
 	buffSink := &httpBatchStore{
 		host: u,
 		// Custom http.Client to give control of idle connections and timeouts
-		httpClient:    &http.Client{Transport: &customHTTPTransport},
-		auth:          auth,
-		getQueue:      make(chan chunks.ReadRequest, readBufferSize),
-		hasQueue:      make(chan chunks.ReadRequest, readBufferSize),
-		finishedChan:  make(chan struct{}),
-		rateLimit:     make(chan struct{}, httpChunkSinkConcurrency),
-		requestWg:     &sync.WaitGroup{},
-		workerWg:      &sync.WaitGroup{},
-		cacheMu:       &sync.RWMutex{},
-		unwrittenPuts: nbs.NewCache(),
+		httpClient:   &http.Client{Transport: transport},
+		auth:         auth,
+		ctx:          context.Background(),
+		getQueue:     make(chan chunks.ReadRequest, readBufferSize),
+		hasQueue:     make(chan chunks.ReadRequest, readBufferSize),
+		finishedChan: make(chan struct{}),
+		rateLimit:    make(chan struct{}, httpChunkSinkConcurrency),
+		requestWg:    &sync.WaitGroup{},
+		workerWg:     &sync.WaitGroup{},
+		puts:         &putCache{cache: nbs.NewCache()},
 	}
 	buffSink.batchGetRequests()
 	buffSink.batchHasRequests()
 	return buffSink
 }
 
+// putCache holds the chunks scheduled to be written by an httpBatchStore or
+// a localBatchStore. mu is held for reading while cache is used, and for
+// writing while a flush replaces it.
+type putCache struct {
+	mu    sync.RWMutex
+	cache *nbs.NomsBlockCache
+}
+
 type httpDoer interface {
 	Do(req *http.Request) (resp *http.Response, err error)
 }
 
+// check panics with the error of bhcs.ctx once it's done.
+func (bhcs *httpBatchStore) check() {
+	d.PanicIfError(bhcs.ctx.Err())
+}
+
+// panicIfError panics if err, the error of making a request or reading its
+// response, isn't nil: with the error of bhcs.ctx if it's done, since err is
+// then most likely due to the request being canceled, and otherwise with err
+// as an ErrNetwork.
+func (bhcs *httpBatchStore) panicIfError(err error) {
+	if err != nil {
+		bhcs.check()
+		d.PanicIfError(nomserrors.Wrap(nomserrors.ErrNetwork, err))
+	}
+}
+
+// do makes req with bhcs.ctx.
+func (bhcs *httpBatchStore) do(req *http.Request) *http.Response {
+	res, err := bhcs.httpClient.Do(req.WithContext(bhcs.ctx))
+	bhcs.panicIfError(err)
+	return res
+}
+
+// recoverIfDone is deferred by the goroutines which make batched read
+// requests, so that once bhcs.ctx is done, failing to read a response
+// doesn't crash the program. The requests in the batch are failed, and the
+// callers waiting on them panic with the error of the context instead.
+func (bhcs *httpBatchStore) recoverIfDone() {
+	if bhcs.ctx.Err() != nil {
+		recover()
+	}
+}
+
 func (bhcs *httpBatchStore) Flush() {
+	bhcs.check()
 	bhcs.sendWriteRequests()
 	bhcs.requestWg.Wait()
 	return
@@ -100,35 +200,48 @@ func (bhcs *httpBatchStore) Close() (e error) {
 	close(bhcs.hasQueue)
 	close(bhcs.rateLimit)
 
-	bhcs.cacheMu.Lock()
-	defer bhcs.cacheMu.Unlock()
-	bhcs.unwrittenPuts.Destroy()
+	bhcs.puts.mu.Lock()
+	defer bhcs.puts.mu.Unlock()
+	bhcs.puts.cache.Destroy()
 	return
 }
 
 func (bhcs *httpBatchStore) Get(h hash.Hash) chunks.Chunk {
 	checkCache := func(h hash.Hash) chunks.Chunk {
-		bhcs.cacheMu.RLock()
-		defer bhcs.cacheMu.RUnlock()
-		return bhcs.unwrittenPuts.Get(h)
+		bhcs.puts.mu.RLock()
+		defer bhcs.puts.mu.RUnlock()
+		return bhcs.puts.cache.Get(h)
 	}
+	bhcs.check()
 	if pending := checkCache(h); !pending.IsEmpty() {
 		return pending
 	}
 
-	ch := make(chan *chunks.Chunk)
+	// ch is buffered, so that the batch doesn't block on it if this stops
+	// waiting.
+	ch := make(chan *chunks.Chunk, 1)
 	bhcs.requestWg.Add(1)
 	bhcs.getQueue <- chunks.NewGetRequest(h, ch)
-	return *(<-ch)
+	select {
+	case c := <-ch:
+		if c.IsEmpty() {
+			bhcs.check()
+		}
+		return *c
+	case <-bhcs.ctx.Done():
+		bhcs.check()
+		panic("unreachable")
+	}
 }
 
 func (bhcs *httpBatchStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	bhcs.check()
 	cachedChunks := make(chan *chunks.Chunk)
 	go func() {
-		bhcs.cacheMu.RLock()
-		defer bhcs.cacheMu.RUnlock()
+		bhcs.puts.mu.RLock()
+		defer bhcs.puts.mu.RUnlock()
 		defer close(cachedChunks)
-		bhcs.unwrittenPuts.GetMany(hashes, cachedChunks)
+		bhcs.puts.cache.GetMany(hashes, cachedChunks)
 	}()
 	remaining := hash.HashSet{}
 	for h := range hashes {
@@ -147,6 +260,7 @@ func (bhcs *httpBatchStore) GetMany(hashes hash.HashSet, foundChunks chan *chunk
 	bhcs.requestWg.Add(1)
 	bhcs.getQueue <- chunks.NewGetManyRequest(remaining, wg, foundChunks)
 	wg.Wait()
+	bhcs.check()
 }
 
 func (bhcs *httpBatchStore) batchGetRequests() {
@@ -155,18 +269,28 @@ func (bhcs *httpBatchStore) batchGetRequests() {
 
 func (bhcs *httpBatchStore) Has(h hash.Hash) bool {
 	checkCache := func(h hash.Hash) bool {
-		bhcs.cacheMu.RLock()
-		defer bhcs.cacheMu.RUnlock()
-		return bhcs.unwrittenPuts.Has(h)
+		bhcs.puts.mu.RLock()
+		defer bhcs.puts.mu.RUnlock()
+		return bhcs.puts.cache.Has(h)
 	}
+	bhcs.check()
 	if checkCache(h) {
 		return true
 	}
 
-	ch := make(chan bool)
+	ch := make(chan bool, 1)
 	bhcs.requestWg.Add(1)
 	bhcs.hasQueue <- chunks.NewHasRequest(h, ch)
-	return <-ch
+	select {
+	case has := <-ch:
+		if !has {
+			bhcs.check()
+		}
+		return has
+	case <-bhcs.ctx.Done():
+		bhcs.check()
+		panic("unreachable")
+	}
 }
 
 func (bhcs *httpBatchStore) batchHasRequests() {
@@ -224,12 +348,13 @@ func (bhcs *httpBatchStore) sendReadRequests(req chunks.ReadRequest, queue <-cha
 	bhcs.rateLimit <- struct{}{}
 	go func() {
 		defer func() {
+			<-bhcs.rateLimit
 			bhcs.requestWg.Add(-count)
 			batch.Close()
 		}()
+		defer bhcs.recoverIfDone()
 
 		getter(hashes, batch)
-		<-bhcs.rateLimit
 	}()
 }
 
@@ -243,14 +368,13 @@ func (bhcs *httpBatchStore) getRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 		"Content-Type":    {"application/x-www-form-urlencoded"},
 	})
 
-	res, err := bhcs.httpClient.Do(req)
-	d.Chk.NoError(err)
+	res := bhcs.do(req)
 	expectVersion(res)
 	reader := resBodyReader(res)
 	defer closeResponse(reader)
 
 	if http.StatusOK != res.StatusCode {
-		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
+		d.PanicIfError(responseError(res, http.StatusText(res.StatusCode)))
 	}
 
 	chunkChan := make(chan *chunks.Chunk, 16)
@@ -274,14 +398,13 @@ func (bhcs *httpBatchStore) hasRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 		"Content-Type":    {"application/x-www-form-urlencoded"},
 	})
 
-	res, err := bhcs.httpClient.Do(req)
-	d.Chk.NoError(err)
+	res := bhcs.do(req)
 	expectVersion(res)
 	reader := resBodyReader(res)
 	defer closeResponse(reader)
 
 	if http.StatusOK != res.StatusCode {
-		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
+		d.PanicIfError(responseError(res, http.StatusText(res.StatusCode)))
 	}
 
 	scanner := bufio.NewScanner(reader)
@@ -303,6 +426,86 @@ func (bhcs *httpBatchStore) hasRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 	}
 }
 
+// requestPull asks the server for the chunks reachable from wants but not
+// from haves, see HandlePull, and returns the body of the response, which
+// streams them. ok is false if the server doesn't support it. The request is
+// canceled, and reading the body fails, once ctx is done.
+func (bhcs *httpBatchStore) requestPull(ctx context.Context, wants, haves hash.HashSlice) (body io.ReadCloser, ok bool, err error) {
+	// POST http://<host>/pull/. Post body: want=hash0&have=hash1& Response will be chunk data, 404 if the server predates the endpoint.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.PullPath)
+
+	values := &url.Values{}
+	for _, h := range wants {
+		values.Add("want", h.String())
+	}
+	for _, h := range haves {
+		values.Add("have", h.String())
+	}
+	req := newRequest("POST", bhcs.auth, u.String(), strings.NewReader(values.Encode()), http.Header{
+		"Accept-Encoding": {"x-snappy-framed"},
+		"Content-Type":    {"application/x-www-form-urlencoded"},
+	})
+
+	res, err := bhcs.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, false, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		closeResponse(res.Body)
+		return nil, false, nil
+	}
+	expectVersion(res)
+	if http.StatusOK != res.StatusCode {
+		defer closeResponse(res.Body)
+		d.PanicIfError(responseError(res, formatErrorResponse(res)))
+	}
+	// Closing the reader of a snappy body doesn't close the body.
+	return struct {
+		io.Reader
+		io.Closer
+	}{resBodyReader(res), res.Body}, true, nil
+}
+
+// requestHasFilter asks the server for a bloom filter of the chunks reachable
+// from roots, see HandleHasFilter, and returns it with the height it's
+// complete above. ok is false if the server doesn't support it.
+func (bhcs *httpBatchStore) requestHasFilter(roots hash.HashSlice) (f *hash.BloomFilter, height uint64, ok bool) {
+	// POST http://<host>/hasFilter/. Post body: root=hash0&root=hash1& Response will be the filter, 404 if the server predates the endpoint.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.HasFilterPath)
+
+	values := &url.Values{}
+	for _, h := range roots {
+		values.Add("root", h.String())
+	}
+	req := newRequest("POST", bhcs.auth, u.String(), strings.NewReader(values.Encode()), http.Header{
+		"Accept-Encoding": {"x-snappy-framed"},
+		"Content-Type":    {"application/x-www-form-urlencoded"},
+	})
+
+	res := bhcs.do(req)
+	if res.StatusCode == http.StatusNotFound {
+		closeResponse(res.Body)
+		return nil, 0, false
+	}
+	expectVersion(res)
+	if http.StatusOK != res.StatusCode {
+		defer closeResponse(res.Body)
+		d.PanicIfError(responseError(res, formatErrorResponse(res)))
+	}
+	reader := resBodyReader(res)
+	defer closeResponse(reader)
+
+	data, err := ioutil.ReadAll(reader)
+	bhcs.panicIfError(err)
+	f, err = hash.BloomFilterFromBytes(data)
+	d.PanicIfError(err)
+	height, err = strconv.ParseUint(res.Header.Get(HasFilterHeightHeader), 10, 64)
+	d.PanicIfError(err)
+	return f, height, true
+}
+
 func resBodyReader(res *http.Response) (reader io.ReadCloser) {
 	reader = res.Body
 	if strings.Contains(res.Header.Get("Content-Encoding"), "gzip") {
@@ -317,33 +520,34 @@ func resBodyReader(res *http.Response) (reader io.ReadCloser) {
 }
 
 func (bhcs *httpBatchStore) SchedulePut(c chunks.Chunk) {
-	bhcs.cacheMu.RLock()
-	defer bhcs.cacheMu.RUnlock()
-	bhcs.unwrittenPuts.Insert(c)
+	bhcs.check()
+	bhcs.puts.mu.RLock()
+	defer bhcs.puts.mu.RUnlock()
+	bhcs.puts.cache.Insert(c)
 }
 
 func (bhcs *httpBatchStore) sendWriteRequests() {
 	bhcs.rateLimit <- struct{}{}
 	defer func() { <-bhcs.rateLimit }()
 
-	bhcs.cacheMu.Lock()
+	bhcs.puts.mu.Lock()
 	defer func() {
-		bhcs.cacheMu.Unlock()
+		bhcs.puts.mu.Unlock()
 	}()
 
-	count := bhcs.unwrittenPuts.Count()
+	count := bhcs.puts.cache.Count()
 	if count == 0 {
 		return
 	}
 	defer func() {
-		bhcs.unwrittenPuts.Destroy()
-		bhcs.unwrittenPuts = nbs.NewCache()
+		bhcs.puts.cache.Destroy()
+		bhcs.puts.cache = nbs.NewCache()
 	}()
 
-	verbose.Log("Sending %d chunks", count)
+	verbose.Debug("Sending chunks", verbose.Fields{"chunks": count})
 	chunkChan := make(chan *chunks.Chunk, 1024)
 	go func() {
-		bhcs.unwrittenPuts.ExtractChunks(chunkChan)
+		bhcs.puts.cache.ExtractChunks(chunkChan)
 		close(chunkChan)
 	}()
 
@@ -357,31 +561,56 @@ func (bhcs *httpBatchStore) sendWriteRequests() {
 		"Content-Type":     {"application/octet-stream"},
 	})
 
-	res, err := bhcs.httpClient.Do(req)
-	d.PanicIfError(err)
+	res := bhcs.do(req)
 	expectVersion(res)
 	defer closeResponse(res.Body)
 
 	if http.StatusCreated != res.StatusCode {
-		d.Panic("Unexpected response: %s", formatErrorResponse(res))
+		d.PanicIfError(responseError(res, formatErrorResponse(res)))
 	}
-	verbose.Log("Finished sending %d hashes", count)
+	verbose.Debug("Finished sending hashes", verbose.Fields{"hashes": count})
 }
 
 func (bhcs *httpBatchStore) Root() hash.Hash {
+	bhcs.check()
 	// GET http://<host>/root. Response will be ref of root.
 	res := bhcs.requestRoot("GET", hash.Hash{}, hash.Hash{})
 	expectVersion(res)
 	defer closeResponse(res.Body)
 
 	if http.StatusOK != res.StatusCode {
-		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
+		d.PanicIfError(responseError(res, http.StatusText(res.StatusCode)))
 	}
 	data, err := ioutil.ReadAll(res.Body)
-	d.Chk.NoError(err)
+	bhcs.panicIfError(err)
 	return hash.Parse(string(data))
 }
 
+// WaitForRoot asks the server to hold the request until its Root is
+// something other than last, or timeout passes, and returns the Root. ok is
+// false if the server doesn't support waiting, in which case it returned the
+// Root right away.
+func (bhcs *httpBatchStore) WaitForRoot(last hash.Hash, timeout time.Duration) (root hash.Hash, ok bool) {
+	bhcs.check()
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.RootPath)
+	params := u.Query()
+	params.Add("wait", last.String())
+	params.Add("timeout", strconv.Itoa(int(timeout/time.Second)))
+	u.RawQuery = params.Encode()
+
+	res := bhcs.do(newRequest("GET", bhcs.auth, u.String(), nil, nil))
+	expectVersion(res)
+	defer closeResponse(res.Body)
+
+	if http.StatusOK != res.StatusCode {
+		d.PanicIfError(responseError(res, http.StatusText(res.StatusCode)))
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	bhcs.panicIfError(err)
+	return hash.Parse(string(data)), res.Header.Get(RootWaitHeader) != ""
+}
+
 // UpdateRoot flushes outstanding writes to the backing ChunkStore before updating its Root, because it's almost certainly the case that the caller wants to point that root at some recently-Put Chunk.
 func (bhcs *httpBatchStore) UpdateRoot(current, last hash.Hash) bool {
 	// POST http://<host>/root?current=<ref>&last=<ref>. Response will be 200 on success, 409 if current is outdated.
@@ -400,10 +629,7 @@ func (bhcs *httpBatchStore) UpdateRoot(current, last hash.Hash) bool {
 		buf := bytes.Buffer{}
 		buf.ReadFrom(res.Body)
 		body := buf.String()
-		d.Chk.Fail(
-			fmt.Sprintf("Unexpected response: %s: %s",
-				http.StatusText(res.StatusCode),
-				body))
+		d.PanicIfError(responseError(res, fmt.Sprintf("%s: %s", http.StatusText(res.StatusCode), body)))
 		return false
 	}
 }
@@ -422,17 +648,13 @@ func (bhcs *httpBatchStore) requestRoot(method string, current, last hash.Hash)
 	}
 
 	req := newRequest(method, bhcs.auth, u.String(), nil, nil)
-
-	res, err := bhcs.httpClient.Do(req)
-	d.PanicIfError(err)
-
-	return res
+	return bhcs.do(req)
 }
 
 func newRequest(method, auth, url string, body io.Reader, header http.Header) *http.Request {
 	req, err := http.NewRequest(method, url, body)
 	d.Chk.NoError(err)
-	req.Header.Set(NomsVersionHeader, constants.NomsVersion)
+	req.Header.Set(NomsVersionHeader, constants.DataVersion())
 	for k, vals := range header {
 		for _, v := range vals {
 			req.Header.Add(k, v)
@@ -444,6 +666,21 @@ func newRequest(method, auth, url string, body io.Reader, header http.Header) *h
 	return req
 }
 
+// responseError returns the error of an unexpected response, described by
+// desc, which is an ErrAuth if the server refused the credentials of the
+// request.
+func responseError(res *http.Response, desc string) error {
+	err := fmt.Errorf("Unexpected response: %s", desc)
+	if isAuthError(res) {
+		return nomserrors.Wrap(nomserrors.ErrAuth, err)
+	}
+	return err
+}
+
+func isAuthError(res *http.Response) bool {
+	return res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden
+}
+
 func formatErrorResponse(res *http.Response) string {
 	data, err := ioutil.ReadAll(res.Body)
 	d.Chk.NoError(err)
@@ -452,14 +689,18 @@ func formatErrorResponse(res *http.Response) string {
 
 func expectVersion(res *http.Response) {
 	dataVersion := res.Header.Get(NomsVersionHeader)
-	if constants.NomsVersion != dataVersion {
+	if isAuthError(res) && dataVersion == "" {
+		// A proxy in front of the server may have refused the request.
+		d.PanicIfError(responseError(res, formatErrorResponse(res)))
+	}
+	if constants.DataVersion() != dataVersion {
 		b, _ := ioutil.ReadAll(res.Body)
 		res.Body.Close()
-		d.PanicIfError(fmt.Errorf(
+		d.PanicIfError(nomserrors.Errorf(nomserrors.ErrVersionMismatch,
 			"Version mismatch\n\r"+
 				"\tSDK version '%s' is incompatible with data of version: '%s'\n\r"+
 				"\tHTTP Response: %d (%s): %s\n",
-			constants.NomsVersion, dataVersion,
+			constants.DataVersion(), dataVersion,
 			res.StatusCode, res.Status, string(b)))
 	}
 }
@@ -5,15 +5,18 @@
 package datas
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/suite"
 	"github.com/julienschmidt/httprouter"
@@ -53,7 +56,28 @@ func (suite *HTTPBatchStoreSuite) SetupTest() {
 }
 
 func NewHTTPBatchStoreForTest(cs chunks.ChunkStore) *httpBatchStore {
+	return newHTTPBatchStoreForTest(cs, true)
+}
+
+// newHTTPBatchStoreForTest returns a store of a server which only has the
+// pull/ and hasFilter/ endpoints if pull is true, like servers which predate
+// them.
+func newHTTPBatchStoreForTest(cs chunks.ChunkStore, pull bool) *httpBatchStore {
 	serv := inlineServer{httprouter.New()}
+	if pull {
+		serv.POST(
+			constants.PullPath,
+			func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+				HandlePull(w, req, ps, cs)
+			},
+		)
+		serv.POST(
+			constants.HasFilterPath,
+			func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+				HandleHasFilter(w, req, ps, cs)
+			},
+		)
+	}
 	serv.POST(
 		constants.WriteValuePath,
 		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -163,6 +187,36 @@ func (suite *HTTPBatchStoreSuite) TestVersionMismatch() {
 	c := types.EncodeValue(types.NewMap(), nil)
 	suite.cs.Put(c)
 	suite.Panics(func() { store.UpdateRoot(c.Hash(), hash.Hash{}) })
+	_, err := chunks.TryUpdateRoot(store, c.Hash(), hash.Hash{})
+	suite.Equal(nomserrors.ErrVersionMismatch, nomserrors.Class(err))
+}
+
+type failingDoer struct{}
+
+func (failingDoer) Do(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (suite *HTTPBatchStoreSuite) TestErrorClasses() {
+	serv := inlineServer{httprouter.New()}
+	serv.GET(
+		constants.RootPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			w.Header().Set(NomsVersionHeader, constants.DataVersion())
+			http.Error(w, "Bad credentials", http.StatusUnauthorized)
+		},
+	)
+	store := NewHTTPBatchStore("http://localhost", "")
+	defer store.Close()
+	store.httpClient = serv
+	_, err := chunks.TryRoot(store)
+	suite.Equal(nomserrors.ErrAuth, nomserrors.Class(err))
+	suite.True(errors.Is(err, nomserrors.ErrAuth))
+
+	store.httpClient = failingDoer{}
+	_, err = chunks.TryRoot(store)
+	suite.Equal(nomserrors.ErrNetwork, nomserrors.Class(err))
+	suite.Contains(err.Error(), "connection refused")
 }
 
 func (suite *HTTPBatchStoreSuite) TestUpdateRoot() {
@@ -182,6 +236,30 @@ func (suite *HTTPBatchStoreSuite) TestUpdateRootWithParams() {
 	suite.Equal(c.Hash(), suite.cs.Root())
 }
 
+func (suite *HTTPBatchStoreSuite) TestWaitForRoot() {
+	c := types.EncodeValue(types.NewMap(), nil)
+	suite.cs.Put(c)
+	suite.True(suite.cs.UpdateRoot(c.Hash(), hash.Hash{}))
+
+	root, ok := suite.store.WaitForRoot(hash.Hash{}, time.Minute)
+	suite.True(ok)
+	suite.Equal(c.Hash(), root)
+
+	root, ok = suite.store.WaitForRoot(c.Hash(), 0)
+	suite.True(ok)
+	suite.Equal(c.Hash(), root)
+
+	c2 := types.EncodeValue(types.NewList(), nil)
+	suite.cs.Put(c2)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		suite.cs.UpdateRoot(c2.Hash(), c.Hash())
+	}()
+	root, ok = suite.store.WaitForRoot(c.Hash(), time.Minute)
+	suite.True(ok)
+	suite.Equal(c2.Hash(), root)
+}
+
 func (suite *HTTPBatchStoreSuite) TestGet() {
 	chnx := []chunks.Chunk{
 		chunks.NewChunk([]byte("abc")),
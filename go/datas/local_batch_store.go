@@ -5,6 +5,7 @@
 package datas
 
 import (
+	"context"
 	"sync"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -12,21 +13,41 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/nomserrors"
 	"github.com/attic-labs/noms/go/types"
 )
 
+// localBatchStore is safe for concurrent use.
 type localBatchStore struct {
-	cs            chunks.ChunkStore
-	unwrittenPuts *nbs.NomsBlockCache
-	vbs           *types.ValidatingBatchingSink
-	once          sync.Once
+	cs   chunks.ChunkStore
+	vbs  *types.ValidatingBatchingSink
+	once *sync.Once
+
+	// puts is shared with the views of the store withContext returns, so
+	// that their flushes replace the chunks written for all of them.
+	puts *putCache
 }
 
 func newLocalBatchStore(cs chunks.ChunkStore) *localBatchStore {
 	return &localBatchStore{
-		cs:            cs,
-		unwrittenPuts: nbs.NewCache(),
-		vbs:           types.NewCompletenessCheckingBatchingSink(cs),
+		cs:   cs,
+		vbs:  types.NewCompletenessCheckingBatchingSink(cs),
+		once: &sync.Once{},
+		puts: &putCache{cache: nbs.NewCache()},
+	}
+}
+
+// withContext returns a view of lbs which reads and writes the ChunkStore of
+// lbs with ctx, as chunks.WithContext does, so that a single call stops
+// using it once ctx is done, without affecting the other callers. The view
+// shares the unwritten chunks of lbs, and mustn't be closed.
+func (lbs *localBatchStore) withContext(ctx context.Context) *localBatchStore {
+	cs := chunks.WithContext(lbs.cs, ctx)
+	return &localBatchStore{
+		cs:   cs,
+		vbs:  types.NewCompletenessCheckingBatchingSink(cs),
+		once: lbs.once,
+		puts: lbs.puts,
 	}
 }
 
@@ -34,7 +55,10 @@ func newLocalBatchStore(cs chunks.ChunkStore) *localBatchStore {
 // not present.
 func (lbs *localBatchStore) Get(h hash.Hash) chunks.Chunk {
 	lbs.once.Do(lbs.expectVersion)
-	if pending := lbs.unwrittenPuts.Get(h); !pending.IsEmpty() {
+	lbs.puts.mu.RLock()
+	pending := lbs.puts.cache.Get(h)
+	lbs.puts.mu.RUnlock()
+	if !pending.IsEmpty() {
 		return pending
 	}
 	return lbs.cs.Get(h)
@@ -45,8 +69,15 @@ func (lbs *localBatchStore) GetMany(hashes hash.HashSet, foundChunks chan *chunk
 	for h := range hashes {
 		remaining.Insert(h)
 	}
-	localChunks := make(chan *chunks.Chunk)
-	go func() { defer close(localChunks); lbs.unwrittenPuts.GetMany(hashes, localChunks) }()
+	// Collect the unwritten chunks before sending any, so that puts.mu isn't
+	// held while the caller handles them.
+	localChunks := make(chan *chunks.Chunk, len(hashes))
+	func() {
+		lbs.puts.mu.RLock()
+		defer lbs.puts.mu.RUnlock()
+		lbs.puts.cache.GetMany(hashes, localChunks)
+	}()
+	close(localChunks)
 	for c := range localChunks {
 		remaining.Remove(c.Hash())
 		foundChunks <- c
@@ -57,13 +88,15 @@ func (lbs *localBatchStore) GetMany(hashes hash.HashSet, foundChunks chan *chunk
 // SchedulePut simply calls Put on the underlying ChunkStore.
 func (lbs *localBatchStore) SchedulePut(c chunks.Chunk) {
 	lbs.once.Do(lbs.expectVersion)
-	lbs.unwrittenPuts.Insert(c)
+	lbs.puts.mu.RLock()
+	defer lbs.puts.mu.RUnlock()
+	lbs.puts.cache.Insert(c)
 }
 
 func (lbs *localBatchStore) expectVersion() {
 	dataVersion := lbs.cs.Version()
-	if constants.NomsVersion != dataVersion {
-		d.Panic("SDK version %s incompatible with data of version %s", constants.NomsVersion, dataVersion)
+	if constants.DataVersion() != dataVersion {
+		d.PanicIfError(nomserrors.Errorf(nomserrors.ErrVersionMismatch, "SDK version %s incompatible with data of version %s", constants.DataVersion(), dataVersion))
 	}
 }
 
@@ -83,11 +116,13 @@ func (lbs *localBatchStore) UpdateRoot(current, last hash.Hash) bool {
 
 func (lbs *localBatchStore) Flush() {
 	lbs.once.Do(lbs.expectVersion)
+	lbs.puts.mu.Lock()
+	defer lbs.puts.mu.Unlock()
 
 	chunkChan := make(chan *chunks.Chunk, 128)
 	go func() {
 		defer close(chunkChan)
-		lbs.unwrittenPuts.ExtractChunks(chunkChan)
+		lbs.puts.cache.ExtractChunks(chunkChan)
 	}()
 
 	for c := range chunkChan {
@@ -97,15 +132,17 @@ func (lbs *localBatchStore) Flush() {
 	lbs.vbs.PanicIfDangling()
 	lbs.vbs.Flush()
 
-	lbs.unwrittenPuts.Destroy()
-	lbs.unwrittenPuts = nbs.NewCache()
+	lbs.puts.cache.Destroy()
+	lbs.puts.cache = nbs.NewCache()
 }
 
 // Destroy blows away lbs' cache of unwritten chunks without flushing. Used
 // when the owning Database is closing and it isn't semantically correct to
 // flush.
 func (lbs *localBatchStore) Destroy() {
-	lbs.unwrittenPuts.Destroy()
+	lbs.puts.mu.Lock()
+	defer lbs.puts.mu.Unlock()
+	lbs.puts.cache.Destroy()
 }
 
 // Close closes the underlying ChunkStore.
@@ -5,7 +5,11 @@
 package datas
 
 import (
+	"context"
+
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -21,6 +25,39 @@ func newLocalDatabase(cs chunks.ChunkStore) *LocalDatabase {
 	}
 }
 
+// withContext returns a view of ldb which reads and writes its ChunkStore
+// with ctx, and which shares the values ldb has cached and buffered, for a
+// single call.
+func (ldb *LocalDatabase) withContext(ctx context.Context) *LocalDatabase {
+	lbs := ldb.rt.(*localBatchStore).withContext(ctx)
+	dbc := ldb.databaseCommon
+	dbc.ValueStore, dbc.rt, dbc.datasets = ldb.ValueStore.WithBatchStore(lbs), lbs, nil
+	dbc.rootHash = ldb.root()
+	return &LocalDatabase{dbc}
+}
+
+func (ldb *LocalDatabase) ReadValueContext(ctx context.Context, h hash.Hash) (v types.Value, err error) {
+	defer d.Recover(&err)
+	d.PanicIfError(ctx.Err())
+	return ldb.withContext(ctx).ReadValue(h), nil
+}
+
+func (ldb *LocalDatabase) WriteValueContext(ctx context.Context, v types.Value) (r types.Ref, err error) {
+	defer d.Recover(&err)
+	d.PanicIfError(ctx.Err())
+	return ldb.withContext(ctx).WriteValue(v), nil
+}
+
+func (ldb *LocalDatabase) CommitContext(ctx context.Context, ds Dataset, v types.Value, opts CommitOptions) (Dataset, error) {
+	view := ldb.withContext(ctx)
+	err := tryUpdate(func() error {
+		d.PanicIfError(ctx.Err())
+		return view.doCommit(ds.ID(), buildNewCommit(ds, v, opts), opts.Policy)
+	})
+	ldb.setRoot(view.root())
+	return ldb.GetDataset(ds.ID()), err
+}
+
 func (ldb *LocalDatabase) GetDataset(datasetID string) Dataset {
 	return getDataset(ldb, datasetID)
 }
@@ -48,8 +85,18 @@ func (ldb *LocalDatabase) FastForward(ds Dataset, newHeadRef types.Ref) (Dataset
 	return ldb.doHeadUpdate(ds, func(ds Dataset) error { return ldb.doFastForward(ds, newHeadRef) })
 }
 
+func (ldb *LocalDatabase) Rename(ds Dataset, newID string) (Dataset, error) {
+	err := tryUpdate(func() error { return ldb.doMove(ds, newID, false) })
+	return ldb.GetDataset(newID), err
+}
+
+func (ldb *LocalDatabase) Copy(ds Dataset, newID string) (Dataset, error) {
+	err := tryUpdate(func() error { return ldb.doMove(ds, newID, true) })
+	return ldb.GetDataset(newID), err
+}
+
 func (ldb *LocalDatabase) doHeadUpdate(ds Dataset, updateFunc func(ds Dataset) error) (Dataset, error) {
-	err := updateFunc(ds)
+	err := tryUpdate(func() error { return updateFunc(ds) })
 	return ldb.GetDataset(ds.ID()), err
 }
 
@@ -0,0 +1,199 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// PinPrefix starts the IDs of the datasets pins are stored in, which are
+// followed by the hash of the pinned value. The head of the dataset of a pin
+// is a commit of a Ref to the value, which has no parents, and the count and
+// expiry of the pin in its meta. As the value is reachable from the root of
+// the Database, it isn't collected by GC while it's pinned.
+const PinPrefix = "pins/"
+
+var (
+	ErrNotPinned     = errors.New("Value is not pinned")
+	ErrValueNotFound = nomserrors.Wrap(nomserrors.ErrChunkNotFound, errors.New("Value not found"))
+)
+
+// Pin keeps a value from being collected. Pins are counted: each time a value
+// is pinned, its count goes up, and each time it's unpinned, down, so that
+// the value stays pinned until all those who pinned it are done with it.
+type Pin struct {
+	Value types.Ref
+	Count uint64
+	// Expires is when the pin expires, or zero if it doesn't.
+	Expires time.Time
+}
+
+// Expired returns true if p has expired as of now.
+func (p Pin) Expired(now time.Time) bool {
+	return !p.Expires.IsZero() && now.After(p.Expires)
+}
+
+// Pins returns the pins of db, ordered by the hashes of their values.
+func Pins(db Database) []Pin {
+	pins := []Pin{}
+	db.Datasets().IterFrom(types.String(PinPrefix), func(k, v types.Value) bool {
+		if !strings.HasPrefix(string(k.(types.String)), PinPrefix) {
+			return true
+		}
+		pins = append(pins, pinOf(v.(types.Ref).TargetValue(db).(types.Struct)))
+		return false
+	})
+	return pins
+}
+
+// GetPin returns the pin of the value h in db, if it's pinned.
+func GetPin(db Database, h hash.Hash) (Pin, bool) {
+	r, ok := db.Datasets().MaybeGet(types.String(pinID(h)))
+	if !ok {
+		return Pin{}, false
+	}
+	return pinOf(r.(types.Ref).TargetValue(db).(types.Struct)), true
+}
+
+func pinID(h hash.Hash) string {
+	return PinPrefix + h.String()
+}
+
+func pinOf(commit types.Struct) Pin {
+	p := Pin{Value: commit.Get(ValueField).(types.Ref)}
+	if meta, ok := commit.Get(MetaField).(types.Struct); ok {
+		if count, ok := meta.MaybeGet("count"); ok {
+			p.Count = uint64(count.(types.Number))
+		}
+		if expires, ok := meta.MaybeGet("expires"); ok {
+			p.Expires, _ = time.Parse(time.RFC3339Nano, string(expires.(types.String)))
+		}
+	}
+	return p
+}
+
+func (dbc *databaseCommon) Pin(h hash.Hash, ttl time.Duration) (Pin, error) {
+	var p Pin
+	err := tryUpdate(func() (err error) {
+		p, err = dbc.doPin(h, ttl)
+		return
+	})
+	return p, err
+}
+
+func (dbc *databaseCommon) Unpin(h hash.Hash) (Pin, error) {
+	var p Pin
+	err := tryUpdate(func() (err error) {
+		p, err = dbc.doUnpin(h)
+		return
+	})
+	return p, err
+}
+
+func (dbc *databaseCommon) ExpirePins() ([]Pin, error) {
+	var expired []Pin
+	err := tryUpdate(func() (err error) {
+		expired, err = dbc.doExpirePins(time.Now())
+		return
+	})
+	return expired, err
+}
+
+// doPin counts a pin of the value h, which expires after ttl, or never if ttl
+// is 0, in one update of the root. The pin expires when the last of those
+// counted does.
+func (dbc *databaseCommon) doPin(h hash.Hash, ttl time.Duration) (Pin, error) {
+	defer dbc.resetRoot()
+	id := types.String(pinID(h))
+
+	for {
+		currentRootHash, currentDatasets := dbc.getRootAndDatasets()
+		var p Pin
+		if r, ok := currentDatasets.MaybeGet(id); ok {
+			p = pinOf(r.(types.Ref).TargetValue(dbc).(types.Struct))
+		} else {
+			v := dbc.ReadValue(h)
+			if v == nil {
+				return Pin{}, ErrValueNotFound
+			}
+			p = Pin{Value: types.NewRef(v)}
+		}
+		if ttl == 0 {
+			p.Expires = time.Time{}
+		} else if expires := time.Now().UTC().Add(ttl); p.Count == 0 || (!p.Expires.IsZero() && expires.After(p.Expires)) {
+			p.Expires = expires
+		}
+		p.Count++
+		currentDatasets = currentDatasets.Set(id, dbc.writePin(p))
+		if err := dbc.tryUpdateRoot(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return p, err
+		}
+	}
+}
+
+// doUnpin uncounts a pin of the value h, removing the pin if it was the last,
+// in one update of the root.
+func (dbc *databaseCommon) doUnpin(h hash.Hash) (Pin, error) {
+	defer dbc.resetRoot()
+	id := types.String(pinID(h))
+
+	for {
+		currentRootHash, currentDatasets := dbc.getRootAndDatasets()
+		r, ok := currentDatasets.MaybeGet(id)
+		if !ok {
+			return Pin{}, ErrNotPinned
+		}
+		p := pinOf(r.(types.Ref).TargetValue(dbc).(types.Struct))
+		if p.Count--; p.Count == 0 {
+			currentDatasets = currentDatasets.Remove(id)
+		} else {
+			currentDatasets = currentDatasets.Set(id, dbc.writePin(p))
+		}
+		if err := dbc.tryUpdateRoot(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return p, err
+		}
+	}
+}
+
+// doExpirePins removes the pins which have expired as of now, in one update
+// of the root.
+func (dbc *databaseCommon) doExpirePins(now time.Time) ([]Pin, error) {
+	defer dbc.resetRoot()
+
+	for {
+		currentRootHash, currentDatasets := dbc.getRootAndDatasets()
+		datasets, expired := currentDatasets, []Pin{}
+		currentDatasets.IterFrom(types.String(PinPrefix), func(k, v types.Value) bool {
+			if !strings.HasPrefix(string(k.(types.String)), PinPrefix) {
+				return true
+			}
+			if p := pinOf(v.(types.Ref).TargetValue(dbc).(types.Struct)); p.Expired(now) {
+				datasets = datasets.Remove(k)
+				expired = append(expired, p)
+			}
+			return false
+		})
+		if len(expired) == 0 {
+			return expired, nil
+		}
+		if err := dbc.tryUpdateRoot(datasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return expired, err
+		}
+	}
+}
+
+func (dbc *databaseCommon) writePin(p Pin) types.Ref {
+	meta := types.StructData{"count": types.Number(p.Count)}
+	if !p.Expires.IsZero() {
+		meta["expires"] = types.String(p.Expires.Format(time.RFC3339Nano))
+	}
+	return types.ToRefOfValue(dbc.WriteValue(NewCommit(p.Value, types.NewSet(), types.NewStruct("", meta))))
+}
@@ -5,14 +5,18 @@
 package datas
 
 import (
+	"context"
 	"math"
 	"math/rand"
 	"sort"
 	"sync"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/metrics"
 	"github.com/golang/snappy"
 )
 
@@ -39,11 +43,32 @@ func PullWithFlush(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, con
 // allows the algorithm to figure out which portions of data are already
 // present in sinkDB and skip copying them.
 func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) {
+	PullContext(context.Background(), srcDB, sinkDB, sourceRef, sinkHeadRef, concurrency, progressCh)
+}
+
+// PullContext is like Pull, but stops pulling when ctx is done, returning
+// ctx.Err(). The chunks pulled until then are left in sinkDB, but refer to
+// chunks which weren't, so flushing sinkDB, e.g. by committing to it, fails
+// until sourceRef is pulled again.
+func PullContext(ctx context.Context, srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) error {
 	srcQ, sinkQ := &types.RefByHeight{sourceRef}, &types.RefByHeight{sinkHeadRef}
 
 	// If the sourceRef points to an object already in sinkDB, there's nothing to do.
 	if sinkDB.has(sourceRef.TargetHash()) {
-		return
+		return nil
+	}
+
+	// A remote srcDB walks the graph itself, if it can, sparing the round trips of walking it from here.
+	if rdb, ok := srcDB.(*RemoteDatabaseClient); ok {
+		if pulled, err := pullFromServer(ctx, rdb, sinkDB, sourceRef, sinkHeadRef, concurrency, progressCh); pulled {
+			return err
+		}
+	}
+
+	// A remote sinkDB may be asked for a filter of the chunks it has, instead of whether it has each one.
+	sinkHas := func(r types.Ref) bool { return sinkDB.has(r.TargetHash()) }
+	if rdb, ok := sinkDB.(*RemoteDatabaseClient); ok {
+		sinkHas = rdb.filteredHas(sinkHeadRef)
 	}
 
 	// We generally expect that sourceRef descends from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then just clear out sinkQ right now and don't bother.
@@ -84,17 +109,31 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 			for {
 				select {
 				case srcRef := <-srcChan:
+					// Once ctx is done, the rest of the round is skipped rather
+					// than fetched, so that the loop below stops after it.
+					if ctx.Err() != nil {
+						srcResChan <- traverseSourceResult{}
+						continue
+					}
 					// Hook in here to estimate the bytes written to disk during pull (since
 					// srcChan contains all chunks to be written to the sink). Rather than measuring
 					// the serialized, compressed bytes of each chunk, we take a 10% sample.
 					// There's no immediately observable performance benefit to sampling here, but there's
 					// also no appreciable loss in accuracy, so we'll keep it around.
 					takeSample := rand.Float64() < bytesWrittenSampleRate
-					srcResChan <- traverseSource(srcRef, srcDB, sinkDB, takeSample)
+					srcResChan <- traverseSource(srcRef, srcDB, sinkDB, sinkHas, takeSample)
 				case sinkRef := <-sinkChan:
+					if ctx.Err() != nil {
+						sinkResChan <- traverseResult{}
+						continue
+					}
 					sinkResChan <- traverseSink(sinkRef, mostLocalDB)
 				case comRef := <-comChan:
-					comResChan <- traverseCommon(comRef, sinkHeadRef, mostLocalDB)
+					if ctx.Err() != nil {
+						comResChan <- traverseResult{}
+						continue
+					}
+					comResChan <- traverseCommon(comRef, comRef.TargetHash() == sinkHeadRef.TargetHash(), mostLocalDB)
 				case <-done:
 					workerWg.Done()
 					return
@@ -115,6 +154,10 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 		progressCh <- PullProgress{doneCount, knownCount + uint64(srcQ.Len()), approxBytesWritten}
 	}
 
+	// The chunks pulled are only marked present in sinkDB once the pull is
+	// done, so that pulling again after ctx is done doesn't skip them, and
+	// the chunks they reach.
+	pulled := hash.HashSlice{}
 	sampleSize := uint64(0)
 	sampleCount := uint64(0)
 	for !srcQ.Empty() {
@@ -136,6 +179,11 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 				for _, reachable := range res.reachables {
 					srcQ.PushBack(reachable)
 				}
+				if !res.readHash.IsEmpty() {
+					pulled = append(pulled, res.readHash)
+				}
+				metrics.Add("datas_pull_chunks", 1)
+				metrics.Add("datas_pull_bytes", int64(res.readBytes))
 				if res.writeBytes > 0 {
 					sampleSize += uint64(res.writeBytes)
 					sampleCount += 1
@@ -160,11 +208,237 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 				updateProgress(1, 0, uint64(res.readBytes), 0)
 			}
 		}
+		// The workers are all idle between rounds of work, so it's safe to
+		// stop here. Within a round, they skip the chunks left once ctx is
+		// done, so the chunks those reach weren't queued.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		sort.Sort(sinkQ)
 		sort.Sort(srcQ)
 		sinkQ.Unique()
 		srcQ.Unique()
 	}
+	for _, h := range pulled {
+		sinkDB.markPresent(h)
+	}
+	return nil
+}
+
+// pullFromServer pulls sourceRef from the remote srcDB by asking the server
+// for the chunks reachable from it but not from sinkHeadRef, see HandlePull.
+// The server walks the graph as Pull does, and streams the chunks in a single
+// response, instead of Pull making a round trip per height of the graph.
+// Chunks which sinkDB already has, as it does those shared with other values,
+// are streamed but not written. pulled is false if the server doesn't
+// support it.
+func pullFromServer(ctx context.Context, srcDB *RemoteDatabaseClient, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) (pulled bool, err error) {
+	haves := hash.HashSlice{}
+	if !sinkHeadRef.TargetHash().IsEmpty() {
+		haves = append(haves, sinkHeadRef.TargetHash())
+	}
+	body, ok, err := srcDB.rt.(*httpBatchStore).requestPull(ctx, hash.HashSlice{sourceRef.TargetHash()}, haves)
+	if !ok {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return true, ctxErr
+		}
+		d.PanicIfError(err)
+		return false, nil
+	}
+	defer body.Close()
+
+	chunkChan := make(chan *chunks.Chunk, concurrency)
+	streamErr := make(chan error, 1)
+	go func() {
+		defer close(chunkChan)
+		streamErr <- chunks.Deserialize(body, chunkChan)
+	}()
+
+	// The workers write the chunks which sinkDB doesn't have. Once ctx is
+	// done, the request is canceled, and they just drain chunkChan.
+	resChan := make(chan traverseSourceResult, concurrency)
+	workerWg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			for c := range chunkChan {
+				if ctx.Err() != nil {
+					continue
+				}
+				h := c.Hash()
+				v := types.DecodeValue(*c, srcDB)
+				if v == nil {
+					d.Panic("Expected decoded chunk to be non-nil.")
+				}
+				res := traverseSourceResult{traverseResult{h, getChunks(v), len(c.Data())}, 0}
+				if !sinkDB.has(h) {
+					sinkDB.validatingBatchStore().SchedulePut(*c)
+					res.writeBytes = len(snappy.Encode(nil, c.Data()))
+				}
+				resChan <- res
+			}
+		}()
+	}
+	go func() {
+		workerWg.Wait()
+		close(resChan)
+	}()
+
+	// How many chunks the server streams isn't known until it's done, so the
+	// chunks reachable from those streamed so far are counted as known, even
+	// though the server skips those which are common.
+	var doneCount, knownCount, approxBytesWritten uint64 = 0, 1, 0
+	updateProgress := func() {
+		if progressCh != nil {
+			if knownCount < doneCount {
+				knownCount = doneCount
+			}
+			progressCh <- PullProgress{doneCount, knownCount, approxBytesWritten}
+		}
+	}
+	updateProgress()
+	// As in PullContext, the chunks written, which are those with writeBytes,
+	// are only marked present once the pull is done.
+	written := hash.HashSlice{}
+	for res := range resChan {
+		if res.writeBytes > 0 {
+			written = append(written, res.readHash)
+		}
+		metrics.Add("datas_pull_chunks", 1)
+		metrics.Add("datas_pull_bytes", int64(res.readBytes))
+		doneCount++
+		knownCount += uint64(len(res.reachables))
+		approxBytesWritten += uint64(res.writeBytes)
+		updateProgress()
+	}
+	if err := ctx.Err(); err != nil {
+		return true, err
+	}
+	d.PanicIfError(<-streamErr)
+	for _, h := range written {
+		sinkDB.markPresent(h)
+	}
+	knownCount = doneCount
+	updateProgress()
+	return true, nil
+}
+
+// walkMissing calls cb with each chunk in cs which is reachable from wants
+// but not from haves, tallest first. Like Pull, it walks both graphs in order
+// of decreasing height, so only as much of the graph of haves is read as is
+// needed to tell which chunks are common, and the histories of the Commits
+// of haves are assumed to be common, as that of sinkHeadRef is by Pull.
+func walkMissing(cs chunks.ChunkStore, vr types.ValueReader, wants, haves types.RefSlice, cb func(c chunks.Chunk)) {
+	srcQ, sinkQ := &types.RefByHeight{}, &types.RefByHeight{}
+	isHave := hash.HashSet{}
+	for _, r := range wants {
+		srcQ.PushBack(r)
+	}
+	for _, r := range haves {
+		sinkQ.PushBack(r)
+		isHave.Insert(r.TargetHash())
+	}
+
+	sortQueues := func() {
+		sort.Sort(sinkQ)
+		sort.Sort(srcQ)
+		sinkQ.Unique()
+		srcQ.Unique()
+	}
+
+	for sortQueues(); !srcQ.Empty(); sortQueues() {
+		srcRefs, sinkRefs, comRefs := planWork(srcQ, sinkQ)
+
+		// All of srcRefs are of the same height, so they're read at once.
+		hashes := hash.HashSet{}
+		for _, r := range srcRefs {
+			hashes.Insert(r.TargetHash())
+		}
+		// GetMany ranges over hashes while the chunks are read, so the ones
+		// not yet found are kept in a set of their own.
+		remaining := hashes.Copy()
+		chunkChan := make(chan *chunks.Chunk, 16)
+		go func() {
+			defer close(chunkChan)
+			cs.GetMany(hashes, chunkChan)
+		}()
+		for c := range chunkChan {
+			remaining.Remove(c.Hash())
+			cb(*c)
+			for _, reachable := range getChunks(types.DecodeValue(*c, vr)) {
+				srcQ.PushBack(reachable)
+			}
+		}
+		if len(remaining) > 0 {
+			d.PanicIfError(nomserrors.Errorf(nomserrors.ErrChunkNotFound, "Missing chunks: %v", remaining.Sorted()))
+		}
+
+		for _, r := range sinkRefs {
+			for _, reachable := range traverseSink(r, vr).reachables {
+				sinkQ.PushBack(reachable)
+			}
+		}
+		for _, r := range comRefs {
+			isHead := isHave.Has(r.TargetHash())
+			for _, reachable := range traverseCommon(r, isHead, vr).reachables {
+				sinkQ.PushBack(reachable)
+				if !isHead {
+					srcQ.PushBack(reachable)
+				}
+			}
+		}
+	}
+}
+
+// hasFilter returns a bloom filter of the hashes of the chunks in cs which
+// are reachable from roots, which it walks tallest first. If there are more
+// than max, it stops at a height, which it returns: the filter has all the
+// chunks taller than that, and only some of the others. Otherwise, it returns
+// 0.
+func hasFilter(cs chunks.ChunkStore, vr types.ValueReader, roots types.RefSlice, max int) (*hash.BloomFilter, uint64) {
+	q := &types.RefByHeight{}
+	for _, r := range roots {
+		q.PushBack(r)
+	}
+	sortQueue := func() {
+		sort.Sort(q)
+		q.Unique()
+	}
+
+	found := hash.HashSet{}
+	stoppedAt := uint64(0)
+	for sortQueue(); !q.Empty(); sortQueue() {
+		height := q.MaxHeight()
+		hashes := hash.HashSet{}
+		for _, r := range q.PopRefsOfHeight(height) {
+			if !found.Has(r.TargetHash()) {
+				hashes.Insert(r.TargetHash())
+			}
+		}
+		if len(found)+len(hashes) > max {
+			stoppedAt = height
+			break
+		}
+
+		chunkChan := make(chan *chunks.Chunk, 16)
+		go func() {
+			defer close(chunkChan)
+			cs.GetMany(hashes, chunkChan)
+		}()
+		for c := range chunkChan {
+			found.Insert(c.Hash())
+			for _, reachable := range getChunks(types.DecodeValue(*c, vr)) {
+				q.PushBack(reachable)
+			}
+		}
+	}
+
+	f := hash.NewBloomFilter(len(found))
+	for h := range found {
+		f.Insert(h)
+	}
+	return f, stoppedAt
 }
 
 type traverseResult struct {
@@ -239,15 +513,18 @@ func getChunks(v types.Value) (chunks []types.Ref) {
 	return
 }
 
-func traverseSource(srcRef types.Ref, srcDB, sinkDB Database, estimateBytesWritten bool) traverseSourceResult {
+func traverseSource(srcRef types.Ref, srcDB, sinkDB Database, sinkHas func(r types.Ref) bool, estimateBytesWritten bool) traverseSourceResult {
 	h := srcRef.TargetHash()
-	if !sinkDB.has(h) {
+	if !sinkHas(srcRef) {
 		srcBS := srcDB.validatingBatchStore()
 		c := srcBS.Get(h)
 		v := types.DecodeValue(c, srcDB)
 		if v == nil {
 			d.Panic("Expected decoded chunk to be non-nil.")
 		}
+		// Later pulls to sinkDB, of other values which share this chunk, can
+		// skip it and the chunks it reaches, once PullContext marks it
+		// present.
 		sinkDB.validatingBatchStore().SchedulePut(c)
 		bytesWritten := 0
 		if estimateBytesWritten {
@@ -261,20 +538,20 @@ func traverseSource(srcRef types.Ref, srcDB, sinkDB Database, estimateBytesWritt
 	return traverseSourceResult{}
 }
 
-func traverseSink(sinkRef types.Ref, db Database) traverseResult {
+func traverseSink(sinkRef types.Ref, vr types.ValueReader) traverseResult {
 	if sinkRef.Height() > 1 {
-		return traverseResult{sinkRef.TargetHash(), getChunks(sinkRef.TargetValue(db)), 0}
+		return traverseResult{sinkRef.TargetHash(), getChunks(sinkRef.TargetValue(vr)), 0}
 	}
 	return traverseResult{}
 }
 
-func traverseCommon(comRef, sinkHead types.Ref, db Database) traverseResult {
+func traverseCommon(comRef types.Ref, isSinkHead bool, vr types.ValueReader) traverseResult {
 	// TODO: Add IsRefOfCommit?
 	if comRef.Height() > 1 && IsRefOfCommitType(types.TypeOf(comRef)) {
-		commit := comRef.TargetValue(db).(types.Struct)
+		commit := comRef.TargetValue(vr).(types.Struct)
 		// We don't want to traverse the parents of sinkHead, but we still want to traverse its Value on the sinkDB side. We also still want to traverse all children, in both the srcDB and sinkDB, of any common Commit that is not at the Head of sinkDB.
 		exclusionSet := types.NewSet()
-		if comRef.Equals(sinkHead) {
+		if isSinkHead {
 			exclusionSet = commit.Get(ParentsField).(types.Set)
 		}
 		chunks := types.RefSlice(getChunks(commit))
@@ -291,3 +568,90 @@ func traverseCommon(comRef, sinkHead types.Ref, db Database) traverseResult {
 	}
 	return traverseResult{}
 }
+
+// PullEstimate is how much data Pull would copy from one Database to another.
+type PullEstimate struct {
+	// Chunks is the number of chunks which are missing from the sink.
+	Chunks uint64
+	// Bytes is the length of their data, CompressedBytes roughly how much of
+	// it would be written, as in PullProgress.
+	Bytes, CompressedBytes uint64
+	// Probed is the number of chunks the sink was asked if it has.
+	Probed uint64
+}
+
+// EstimatePull returns how many of the chunks reachable from sourceRefs in
+// srcDB are missing from sinkDB, which Pull would copy to it, without writing
+// anything to sinkDB. Each chunk is probed for in sinkDB, concurrency at a
+// time, and only the chunks reachable from those which are missing are
+// visited, as sinkDB has all of the chunks reachable from those it has. A
+// chunk reachable from several of sourceRefs is only counted once.
+//
+// Progress is sent to progressCh if it isn't nil, with the estimated bytes
+// written so far as ApproxWrittenBytes.
+func EstimatePull(srcDB, sinkDB Database, sourceRefs types.RefSlice, concurrency int, progressCh chan PullProgress) PullEstimate {
+	type result struct {
+		missing    bool
+		reachables []types.Ref
+		bytes      int
+		compressed int
+	}
+	estimate := func(r types.Ref) (res result) {
+		h := r.TargetHash()
+		if sinkDB.has(h) {
+			return
+		}
+		c := srcDB.validatingBatchStore().Get(h)
+		v := types.DecodeValue(c, srcDB)
+		if v == nil {
+			d.Panic("Expected decoded chunk to be non-nil.")
+		}
+		return result{true, getChunks(v), len(c.Data()), len(snappy.Encode(nil, c.Data()))}
+	}
+
+	est := PullEstimate{}
+	visited := hash.HashSet{}
+	refs := types.RefSlice{}
+	for _, r := range sourceRefs {
+		if !visited.Has(r.TargetHash()) {
+			visited.Insert(r.TargetHash())
+			refs = append(refs, r)
+		}
+	}
+	for len(refs) > 0 {
+		results := make([]result, len(refs))
+		sem := make(chan struct{}, concurrency)
+		wg := &sync.WaitGroup{}
+		for i, r := range refs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, r types.Ref) {
+				defer func() { <-sem; wg.Done() }()
+				results[i] = estimate(r)
+			}(i, r)
+		}
+		wg.Wait()
+
+		next := types.RefSlice{}
+		for _, res := range results {
+			if !res.missing {
+				continue
+			}
+			est.Chunks++
+			est.Bytes += uint64(res.bytes)
+			est.CompressedBytes += uint64(res.compressed)
+			for _, r := range res.reachables {
+				if !visited.Has(r.TargetHash()) {
+					visited.Insert(r.TargetHash())
+					next = append(next, r)
+				}
+			}
+		}
+		est.Probed += uint64(len(refs))
+		if progressCh != nil {
+			progressCh <- PullProgress{est.Probed, est.Probed + uint64(len(next)), est.CompressedBytes}
+		}
+		refs = next
+	}
+	return est
+}
@@ -5,10 +5,12 @@
 package datas
 
 import (
+	"net/http"
 	"sort"
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
 	"github.com/attic-labs/testify/suite"
@@ -24,6 +26,12 @@ func TestRemoteToLocalPulls(t *testing.T) {
 	suite.Run(t, &RemoteToLocalSuite{})
 }
 
+// The source server predates the pull/ endpoint, so the graph is walked from
+// the client.
+func TestOldRemoteToLocalPulls(t *testing.T) {
+	suite.Run(t, &OldRemoteToLocalSuite{})
+}
+
 func TestLocalToRemotePulls(t *testing.T) {
 	suite.Run(t, &LocalToRemoteSuite{})
 }
@@ -38,6 +46,9 @@ type PullSuite struct {
 	sourceCS *chunks.TestStore
 	sink     Database
 	source   Database
+	// sourceWalks is true if the source server walks the graph, see
+	// HandlePull.
+	sourceWalks bool
 }
 
 type LocalToLocalSuite struct {
@@ -60,6 +71,19 @@ func (suite *RemoteToLocalSuite) SetupTest() {
 	suite.sourceCS = chunks.NewTestStore()
 	suite.sink = NewDatabase(suite.sinkCS)
 	suite.source = makeRemoteDb(suite.sourceCS)
+	suite.sourceWalks = true
+}
+
+type OldRemoteToLocalSuite struct {
+	PullSuite
+}
+
+func (suite *OldRemoteToLocalSuite) SetupTest() {
+	suite.sinkCS = chunks.NewTestStore()
+	suite.sourceCS = chunks.NewTestStore()
+	suite.sink = NewDatabase(suite.sinkCS)
+	hbs := newHTTPBatchStoreForTest(suite.sourceCS, false)
+	suite.source = &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(hbs), types.NewValueStore(hbs), hbs)}
 }
 
 type LocalToRemoteSuite struct {
@@ -82,6 +106,7 @@ func (suite *RemoteToRemoteSuite) SetupTest() {
 	suite.sourceCS = chunks.NewTestStore()
 	suite.sink = makeRemoteDb(suite.sinkCS)
 	suite.source = makeRemoteDb(suite.sourceCS)
+	suite.sourceWalks = true
 }
 
 func makeRemoteDb(cs chunks.ChunkStore) Database {
@@ -290,8 +315,8 @@ func (suite *PullSuite) TestPullUpdates() {
 
 	Pull(suite.source, suite.sink, sourceRef, sinkRef, 2, pt.Ch)
 
-	if suite.sinkIsLocal() {
-		// 2 objects read from sink: L3 and L2 (when considering the shared commit C1).
+	if suite.sinkIsLocal() && !suite.sourceWalks {
+		// 2 objects read from sink: L3 and L2 (when considering the shared commit C1). The source server reads them itself.
 		expectedReads += 2
 	}
 	suite.Equal(expectedReads, suite.sinkCS.Reads)
@@ -303,6 +328,67 @@ func (suite *PullSuite) TestPullUpdates() {
 	suite.True(srcL.Equals(v.Get(ValueField)))
 }
 
+// Source and sink as in TestPullMultiGeneration.
+func (suite *PullSuite) TestEstimatePull() {
+	sinkL := buildListOfHeight(2, suite.sink)
+	sinkRef := suite.commitToSink(sinkL, types.NewSet())
+	suite.sink.validatingBatchStore().Flush()
+
+	srcL := buildListOfHeight(2, suite.source)
+	sourceRef := suite.commitToSource(srcL, types.NewSet())
+	srcL = buildListOfHeight(4, suite.source)
+	sourceRef = suite.commitToSource(srcL, types.NewSet(sourceRef))
+	srcL = buildListOfHeight(5, suite.source)
+	sourceRef = suite.commitToSource(srcL, types.NewSet(sourceRef))
+
+	writes := suite.sinkCS.Writes
+	pt := startProgressTracker()
+	est := EstimatePull(suite.source, suite.sink, types.RefSlice{sourceRef}, 2, pt.Ch)
+	close(pt.Ch)
+	progress := <-pt.doneCh
+	suite.Equal(writes, suite.sinkCS.Writes)
+	suite.Equal(est.Probed, progress[len(progress)-1].DoneCount)
+	suite.Equal(est.CompressedBytes, progress[len(progress)-1].ApproxWrittenBytes)
+
+	// C2 and C3, their values, L3 and L4, and the numbers in them, are missing.
+	suite.Equal(uint64(2+2+2+2), est.Chunks)
+	suite.True(est.CompressedBytes > 0 && est.Bytes > 0)
+
+	PullWithFlush(suite.source, suite.sink, sourceRef, sinkRef, 2, nil)
+	suite.Equal(writes+int(est.Chunks), suite.sinkCS.Writes)
+
+	// The sink caches that it didn't have the chunks, so a new one is needed.
+	sink := NewDatabase(suite.sinkCS)
+	if !suite.sinkIsLocal() {
+		sink = makeRemoteDb(suite.sinkCS)
+	}
+	defer sink.Close()
+	est = EstimatePull(suite.source, sink, types.RefSlice{sourceRef}, 2, nil)
+	suite.Equal(PullEstimate{Probed: 1}, est)
+}
+
+// Pulling two values which share chunks to the same sink only copies, and
+// walks, the shared chunks once.
+func (suite *PullSuite) TestPullSharedChunks() {
+	l := buildListOfHeight(3, suite.source)
+	sourceRef1 := suite.commitToSource(l, types.NewSet())
+	meta := types.NewStruct("Meta", types.StructData{"ds": types.String("ds2")})
+	ds, err := suite.source.Commit(suite.source.GetDataset("ds2"), l, CommitOptions{Meta: meta})
+	suite.NoError(err)
+	sourceRef2 := ds.HeadRef()
+
+	est := EstimatePull(suite.source, suite.sink, types.RefSlice{sourceRef1, sourceRef2}, 2, nil)
+	est1 := EstimatePull(suite.source, suite.sink, types.RefSlice{sourceRef1}, 2, nil)
+	// Only the commit of ds2 isn't reachable from sourceRef1.
+	suite.Equal(est1.Chunks+1, est.Chunks)
+
+	writes := suite.sinkCS.Writes
+	PullWithFlush(suite.source, suite.sink, sourceRef1, types.Ref{}, 2, nil)
+	suite.Equal(writes+int(est1.Chunks), suite.sinkCS.Writes)
+	PullWithFlush(suite.source, suite.sink, sourceRef2, types.Ref{}, 2, nil)
+	suite.Equal(writes+int(est.Chunks), suite.sinkCS.Writes)
+}
+
 func (suite *PullSuite) commitToSource(v types.Value, p types.Set) types.Ref {
 	ds := suite.source.GetDataset(datasetID)
 	ds, err := suite.source.Commit(ds, v, CommitOptions{Parents: p})
@@ -357,3 +443,81 @@ func TestFindCommon(t *testing.T) {
 	assert.Equal(t, 0, len(*taller))
 	assert.Equal(t, 50, len(*shorter))
 }
+
+type recordingDoer struct {
+	httpDoer
+	paths []string
+}
+
+func (r *recordingDoer) Do(req *http.Request) (*http.Response, error) {
+	r.paths = append(r.paths, req.URL.Path)
+	return r.httpDoer.Do(req)
+}
+
+// Pulling from a server which walks the graph takes a single request.
+func TestPullFromServer(t *testing.T) {
+	assert := assert.New(t)
+	sourceCS := chunks.NewTestStore()
+	hbs := NewHTTPBatchStoreForTest(sourceCS)
+	source := &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(hbs), types.NewValueStore(hbs), hbs)}
+	defer source.Close()
+	sink := NewDatabase(chunks.NewTestStore())
+	defer sink.Close()
+
+	ds, err := source.CommitValue(source.GetDataset(datasetID), buildListOfHeight(2, source))
+	assert.NoError(err)
+	sinkRef := ds.HeadRef()
+	PullWithFlush(source, sink, sinkRef, types.Ref{}, 2, nil)
+	srcL := buildListOfHeight(4, source)
+	ds, err = source.CommitValue(ds, srcL)
+	assert.NoError(err)
+	sourceRef := ds.HeadRef()
+
+	doer := &recordingDoer{httpDoer: hbs.httpClient}
+	hbs.httpClient = doer
+	PullWithFlush(source, sink, sourceRef, sinkRef, 2, nil)
+	assert.Equal([]string{constants.PullPath}, doer.paths)
+
+	v := sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+	assert.True(srcL.Equals(v.Get(ValueField)))
+}
+
+// Pushing with a has filter asks the server whether it has only the chunks
+// which may be in the filter.
+func TestPullWithHasFilter(t *testing.T) {
+	assert := assert.New(t)
+	push := func(hasFilter bool) (hases int, paths []string) {
+		source := NewDatabase(chunks.NewTestStore())
+		defer source.Close()
+		sinkCS := chunks.NewTestStore()
+		hbs := NewHTTPBatchStoreForTest(sinkCS)
+		sink := &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(hbs), types.NewValueStore(hbs), hbs)}
+		defer sink.Close()
+		sink.SetHasFilter(hasFilter)
+
+		ds, err := source.CommitValue(source.GetDataset(datasetID), buildListOfHeight(2, source))
+		assert.NoError(err)
+		sinkRef := ds.HeadRef()
+		PullWithFlush(source, sink, sinkRef, types.Ref{}, 2, nil)
+		srcL := buildListOfHeight(5, source)
+		ds, err = source.CommitValue(ds, srcL)
+		assert.NoError(err)
+		sourceRef := ds.HeadRef()
+
+		doer := &recordingDoer{httpDoer: hbs.httpClient}
+		hbs.httpClient = doer
+		hases = sinkCS.Hases
+		PullWithFlush(source, sink, sourceRef, sinkRef, 2, nil)
+		hases = sinkCS.Hases - hases
+
+		v := sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+		assert.True(srcL.Equals(v.Get(ValueField)))
+		return hases, doer.paths
+	}
+
+	hases, paths := push(false)
+	assert.NotContains(paths, constants.HasFilterPath)
+	filteredHases, paths := push(true)
+	assert.Contains(paths, constants.HasFilterPath)
+	assert.True(filteredHases < hases, "%d has checks with the filter, %d without", filteredHases, hases)
+}
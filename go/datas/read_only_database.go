@@ -0,0 +1,87 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+var ErrReadOnly = errors.New("Database is read-only")
+
+// readOnlyDatabase is a Database whose Datasets can't be changed, and to which
+// no values can be written.
+type readOnlyDatabase struct {
+	Database
+}
+
+// NewReadOnlyDatabase returns a Database which reads from db, but whose
+// Commit, Delete, SetHead, FastForward, Rename, Copy, Snapshot, Restore,
+// Pin, Unpin and ExpirePins fail with an 'ErrReadOnly' error, and whose
+// WriteValue panics.
+func NewReadOnlyDatabase(db Database) Database {
+	return readOnlyDatabase{db}
+}
+
+func (rdb readOnlyDatabase) GetDataset(datasetID string) Dataset {
+	return getDataset(rdb, datasetID)
+}
+
+func (rdb readOnlyDatabase) WriteValue(v types.Value) types.Ref {
+	d.Panic("Can't write values to a read-only database")
+	return types.Ref{}
+}
+
+func (rdb readOnlyDatabase) Commit(ds Dataset, v types.Value, opts CommitOptions) (Dataset, error) {
+	return rdb.GetDataset(ds.ID()), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) CommitValue(ds Dataset, v types.Value) (Dataset, error) {
+	return rdb.GetDataset(ds.ID()), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Delete(ds Dataset) (Dataset, error) {
+	return rdb.GetDataset(ds.ID()), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) SetHead(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
+	return rdb.GetDataset(ds.ID()), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
+	return rdb.GetDataset(ds.ID()), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Rename(ds Dataset, newID string) (Dataset, error) {
+	return rdb.GetDataset(newID), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Copy(ds Dataset, newID string) (Dataset, error) {
+	return rdb.GetDataset(newID), ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Snapshot(label string) (Snapshot, error) {
+	return Snapshot{}, ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Restore(s Snapshot) error {
+	return ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Pin(h hash.Hash, ttl time.Duration) (Pin, error) {
+	return Pin{}, ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) Unpin(h hash.Hash) (Pin, error) {
+	return Pin{}, ErrReadOnly
+}
+
+func (rdb readOnlyDatabase) ExpirePins() ([]Pin, error) {
+	return nil, ErrReadOnly
+}
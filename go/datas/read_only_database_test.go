@@ -0,0 +1,44 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestReadOnlyDatabase(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := NewDatabase(cs)
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+	root := cs.Root()
+
+	rdb := NewReadOnlyDatabase(NewDatabase(cs))
+	rds := rdb.GetDataset("ds")
+	assert.True(rds.HeadValue().Equals(types.String("a")))
+	assert.True(rdb.ReadValue(ds.HeadRef().TargetHash()).Equals(ds.Head()))
+	assert.Equal(rdb, rds.Database())
+
+	for _, update := range []func() (Dataset, error){
+		func() (Dataset, error) { return rdb.CommitValue(rds, types.String("b")) },
+		func() (Dataset, error) { return rdb.Commit(rds, types.String("b"), CommitOptions{}) },
+		func() (Dataset, error) { return rdb.Delete(rds) },
+		func() (Dataset, error) { return rdb.SetHead(rds, ds.HeadRef()) },
+		func() (Dataset, error) { return rdb.FastForward(rds, ds.HeadRef()) },
+		func() (Dataset, error) { return rdb.Rename(rds, "other") },
+		func() (Dataset, error) { return rdb.Copy(rds, "other") },
+		func() (Dataset, error) { return rds.Database().CommitValue(rds, types.String("b")) },
+	} {
+		_, err := update()
+		assert.Equal(ErrReadOnly, err)
+	}
+	assert.Panics(func() { rdb.WriteValue(types.String("b")) })
+	assert.Equal(root, cs.Root())
+}
@@ -5,6 +5,11 @@
 package datas
 
 import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/julienschmidt/httprouter"
 )
@@ -19,12 +24,71 @@ func NewRemoteDatabase(baseURL, auth string) *RemoteDatabaseClient {
 	return &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(httpBS), types.NewValueStore(httpBS), httpBS)}
 }
 
+// NewRemoteDatabaseTLS is like NewRemoteDatabase, but makes https requests
+// with tlsConfig.
+func NewRemoteDatabaseTLS(baseURL, auth string, tlsConfig *tls.Config) *RemoteDatabaseClient {
+	httpBS := NewHTTPBatchStoreTLS(baseURL, auth, tlsConfig)
+	return &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(httpBS), types.NewValueStore(httpBS), httpBS)}
+}
+
+// NewRemoteDatabaseContext is like NewRemoteDatabase, but makes its requests
+// with ctx, so that they're canceled when it's done, or its deadline passes,
+// rather than waiting on a server which may never respond. Then the Database
+// methods which return errors, and the Try functions, return ctx.Err(), and
+// the others panic with it, as with NewDatabaseContext.
+func NewRemoteDatabaseContext(ctx context.Context, baseURL, auth string) *RemoteDatabaseClient {
+	httpBS := NewHTTPBatchStoreContext(ctx, baseURL, auth)
+	return &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(httpBS), types.NewValueStore(httpBS), httpBS)}
+}
+
+// withContext returns a view of rdb whose requests are made with ctx, as
+// well as the context of rdb, and which shares the values rdb has cached and
+// buffered, for a single call. done must be called once the call returns.
+func (rdb *RemoteDatabaseClient) withContext(ctx context.Context) (view *RemoteDatabaseClient, done func()) {
+	httpBS, done := rdb.rt.(*httpBatchStore).withContext(ctx)
+	dbc := rdb.databaseCommon
+	dbc.ValueStore, dbc.rt, dbc.datasets = rdb.ValueStore.WithBatchStore(httpBS), httpBS, nil
+	dbc.rootHash = rdb.root()
+	return &RemoteDatabaseClient{dbc}, done
+}
+
+// ReadValueContext is like TryReadValue(rdb, h), but its requests are made
+// with ctx, so that it returns ctx.Err() once ctx is done, without waiting
+// for the server, or canceling the other requests of rdb.
+func (rdb *RemoteDatabaseClient) ReadValueContext(ctx context.Context, h hash.Hash) (types.Value, error) {
+	view, done := rdb.withContext(ctx)
+	defer done()
+	return TryReadValue(view, h)
+}
+
+// WriteValueContext is like TryWriteValue(rdb, v), but the requests it makes
+// to write buffered values are made with ctx, so that it returns ctx.Err()
+// once ctx is done, without waiting for the server, or canceling the other
+// requests of rdb.
+func (rdb *RemoteDatabaseClient) WriteValueContext(ctx context.Context, v types.Value) (types.Ref, error) {
+	view, done := rdb.withContext(ctx)
+	defer done()
+	return TryWriteValue(view, v)
+}
+
+// CommitContext is like Commit, but its requests are made with ctx, so that
+// it returns ctx.Err() once ctx is done, without waiting for the server, or
+// canceling the other requests of rdb. The commit may still have been made
+// then.
+func (rdb *RemoteDatabaseClient) CommitContext(ctx context.Context, ds Dataset, v types.Value, opts CommitOptions) (Dataset, error) {
+	view, done := rdb.withContext(ctx)
+	defer done()
+	err := tryUpdate(func() error { return view.doCommit(ds.ID(), buildNewCommit(ds, v, opts), opts.Policy) })
+	rdb.setRoot(view.root())
+	return rdb.GetDataset(ds.ID()), err
+}
+
 func (rdb *RemoteDatabaseClient) GetDataset(datasetID string) Dataset {
 	return getDataset(rdb, datasetID)
 }
 
 func (rdb *RemoteDatabaseClient) Commit(ds Dataset, v types.Value, opts CommitOptions) (Dataset, error) {
-	err := rdb.doCommit(ds.ID(), buildNewCommit(ds, v, opts), opts.Policy)
+	err := tryUpdate(func() error { return rdb.doCommit(ds.ID(), buildNewCommit(ds, v, opts), opts.Policy) })
 	return rdb.GetDataset(ds.ID()), err
 }
 
@@ -33,20 +97,71 @@ func (rdb *RemoteDatabaseClient) CommitValue(ds Dataset, v types.Value) (Dataset
 }
 
 func (rdb *RemoteDatabaseClient) Delete(ds Dataset) (Dataset, error) {
-	err := rdb.doDelete(ds.ID())
+	err := tryUpdate(func() error { return rdb.doDelete(ds.ID()) })
 	return rdb.GetDataset(ds.ID()), err
 }
 
 func (rdb *RemoteDatabaseClient) SetHead(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
-	err := rdb.doSetHead(ds, newHeadRef)
+	err := tryUpdate(func() error { return rdb.doSetHead(ds, newHeadRef) })
 	return rdb.GetDataset(ds.ID()), err
 }
 
 func (rdb *RemoteDatabaseClient) FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
-	err := rdb.doFastForward(ds, newHeadRef)
+	err := tryUpdate(func() error { return rdb.doFastForward(ds, newHeadRef) })
 	return rdb.GetDataset(ds.ID()), err
 }
 
+func (rdb *RemoteDatabaseClient) Rename(ds Dataset, newID string) (Dataset, error) {
+	err := tryUpdate(func() error { return rdb.doMove(ds, newID, false) })
+	return rdb.GetDataset(newID), err
+}
+
+func (rdb *RemoteDatabaseClient) Copy(ds Dataset, newID string) (Dataset, error) {
+	err := tryUpdate(func() error { return rdb.doMove(ds, newID, true) })
+	return rdb.GetDataset(newID), err
+}
+
+// WaitForRoot waits for at most timeout for the root of the remote database
+// to move on from the moment in history rdb represents, and returns the
+// root. ok is false if the server doesn't support waiting, in which case it
+// returns the root right away. rdb itself doesn't change; open a new
+// Database to read the new root.
+func (rdb *RemoteDatabaseClient) WaitForRoot(timeout time.Duration) (root hash.Hash, ok bool) {
+	return rdb.rt.(*httpBatchStore).WaitForRoot(rdb.root(), timeout)
+}
+
+// SetHasFilter sets whether pulls to rdb, such as by noms sync to a remote
+// database, ask the server for a bloom filter of the chunks reachable from
+// the head of the dataset pulled to, see HandleHasFilter. Chunks which
+// aren't in the filter are sent without asking the server whether it has
+// them, which spares most of those round trips when pushing a lot of new
+// data, at the cost of sending again chunks the server has but which aren't
+// reachable from the head, e.g. those only other datasets share.
+func (rdb *RemoteDatabaseClient) SetHasFilter(enabled bool) {
+	rdb.rt.(*httpBatchStore).hasFilter = enabled
+}
+
+// filteredHas returns a func which returns whether rdb has the target of a
+// Ref, which only asks the server if it may be in the filter of the chunks
+// reachable from head, or the filter isn't complete at its height.
+func (rdb *RemoteDatabaseClient) filteredHas(head types.Ref) func(r types.Ref) bool {
+	has := func(r types.Ref) bool { return rdb.has(r.TargetHash()) }
+	hbs := rdb.rt.(*httpBatchStore)
+	if !hbs.hasFilter || head.TargetHash().IsEmpty() {
+		return has
+	}
+	f, height, ok := hbs.requestHasFilter(hash.HashSlice{head.TargetHash()})
+	if !ok {
+		return has
+	}
+	return func(r types.Ref) bool {
+		if r.Height() > height && !f.MayHave(r.TargetHash()) {
+			return false
+		}
+		return has(r)
+	}
+}
+
 func (f RemoteStoreFactory) CreateStore(ns string) Database {
 	return NewRemoteDatabase(f.host+httprouter.CleanPath(ns), f.auth)
 }
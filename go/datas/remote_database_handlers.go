@@ -6,6 +6,7 @@ package datas
 
 import (
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,9 +14,11 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/attic-labs/graphql"
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
@@ -38,6 +41,17 @@ const (
 	NomsVersionHeader = "x-noms-vers"
 	nomsBaseHTML      = "<html><head></head><body><p>Hi. This is a Noms HTTP server.</p><p>To learn more, visit <a href=\"https://github.com/attic-labs/noms\">our GitHub project</a>.</p></body></html>"
 	maxGetBatchSize   = 1 << 11 // Limit GetMany() to ~8MB of data
+
+	// HasFilterHeightHeader is set on responses to requests to the
+	// hasFilter/ endpoint to the height the filter is complete above.
+	HasFilterHeightHeader = "x-noms-filter-height"
+	maxHasFilterChunks    = 1 << 20
+
+	// RootWaitHeader is set on responses to GET requests to the root/
+	// endpoint which honored a "wait" query param.
+	RootWaitHeader = "x-noms-root-wait"
+	maxRootWait    = 30 * time.Second
+	rootWaitPoll   = 50 * time.Millisecond
 )
 
 var (
@@ -68,8 +82,30 @@ var (
 	// format, and responses.
 	HandleHasRefs = createHandler(handleHasRefs, true)
 
+	// HandlePull is meant to handle HTTP POST requests to the pull/ server
+	// endpoint. Given the hashes of the values a client wants, as "want"
+	// params, and of values it already has, as "have" params, the server
+	// walks the graph and streams the chunks reachable from the wants but not
+	// from the haves, tallest first, in the format of getRefs/. Haves which
+	// the server doesn't have are ignored.
+	HandlePull = createHandler(handlePull, true)
+
+	// HandleHasFilter is meant to handle HTTP POST requests to the
+	// hasFilter/ server endpoint. Given the hashes of values, as "root"
+	// params, the server walks the chunks reachable from them, tallest first,
+	// and returns a bloom filter of their hashes, see hash.BloomFilter, so
+	// that a client pushing values which build on them can skip asking
+	// whether the server has the chunks which aren't in it. If there are too
+	// many chunks, the walk stops at a height, which is returned in the
+	// HasFilterHeightHeader: the filter has all the chunks taller than that,
+	// and only some of the others. Roots which the server doesn't have are
+	// ignored.
+	HandleHasFilter = createHandler(handleHasFilter, true)
+
 	// HandleRootGet is meant to handle HTTP GET requests to the root/ server
-	// endpoint. The server returns the hash of the Root as a string.
+	// endpoint. The server returns the hash of the Root as a string. If the
+	// "wait" query param is a hash, the server holds the request until the
+	// Root is something else or "timeout" seconds (at most 30) pass.
 	// TODO: Nice comment about what headers it expects/honors, payload
 	// format, and responses.
 	HandleRootGet = createHandler(handleRootGet, true)
@@ -87,20 +123,29 @@ var (
 	// format, and error responses.
 	HandleBaseGet = handleBaseGet
 
-	HandleGraphQL = createHandler(handleGraphQL, false)
+	// HandleHealthGet is meant to handle HTTP GET requests to the health/
+	// server endpoint, which responds with 200 OK as long as the server is
+	// running, even in maintenance mode, for load balancers and monitoring.
+	HandleHealthGet = handleHealthGet
+
+	// HandleGraphQL is meant to handle HTTP GET and POST requests to the
+	// graphql/ server endpoint. It allows querying all datasets, see
+	// NewGraphQLHandler.
+	HandleGraphQL = NewGraphQLHandler(nil)
 
 	writeValueConcurrency = runtime.NumCPU()
 )
 
 func createHandler(hndlr Handler, versionCheck bool) Handler {
 	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
-		w.Header().Set(NomsVersionHeader, constants.NomsVersion)
+		w.Header().Set(NomsVersionHeader, constants.DataVersion())
 
-		if versionCheck && req.Header.Get(NomsVersionHeader) != constants.NomsVersion {
-			verbose.Log("Returning version mismatch error")
+		log := requestLogger(req)
+		if versionCheck && req.Header.Get(NomsVersionHeader) != constants.DataVersion() {
+			log.Log(verbose.DebugLevel, "Returning version mismatch error", verbose.Fields{"version": req.Header.Get(NomsVersionHeader)})
 			http.Error(
 				w,
-				fmt.Sprintf("Error: SDK version %s is incompatible with data of version %s", req.Header.Get(NomsVersionHeader), constants.NomsVersion),
+				fmt.Sprintf("Error: SDK version %s is incompatible with data of version %s", req.Header.Get(NomsVersionHeader), constants.DataVersion()),
 				http.StatusBadRequest,
 			)
 			return
@@ -109,13 +154,19 @@ func createHandler(hndlr Handler, versionCheck bool) Handler {
 		err := d.Try(func() { hndlr(w, req, ps, cs) })
 		if err != nil {
 			err = d.Unwrap(err)
-			verbose.Log("Returning bad request:\n%v\n", err)
+			log.Log(verbose.DebugLevel, "Returning bad request", verbose.Fields{"error": err})
 			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
 }
 
+// requestLogger returns a Logger which adds the client and path of req to the
+// messages logged while handling it.
+func requestLogger(req *http.Request) verbose.Logger {
+	return verbose.WithFields(verbose.GetLogger(), verbose.Fields{"remote": req.RemoteAddr, "path": req.URL.Path})
+}
+
 func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
 	if req.Method != "POST" {
 		d.Panic("Expected post method.")
@@ -125,9 +176,10 @@ func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs
 	totalDataWritten := 0
 	chunkCount := 0
 
-	verbose.Log("Handling WriteValue from " + req.RemoteAddr)
+	log := requestLogger(req)
+	log.Log(verbose.DebugLevel, "Handling WriteValue", nil)
 	defer func() {
-		verbose.Log("Wrote %d Kb as %d chunks from %s in %s", totalDataWritten/1024, chunkCount, req.RemoteAddr, time.Since(t1))
+		log.Log(verbose.DebugLevel, "Wrote chunks", verbose.Fields{"kb": totalDataWritten / 1024, "chunks": chunkCount, "duration": time.Since(t1)})
 	}()
 
 	reader := bodyReader(req)
@@ -170,7 +222,7 @@ func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs
 			vbs.Put(*dc.Chunk, *dc.Value)
 			chunkCount++
 			if chunkCount%100 == 0 {
-				verbose.Log("Enqueued %d chunks", chunkCount)
+				log.Log(verbose.DebugLevel, "Enqueued chunks", verbose.Fields{"chunks": chunkCount})
 			}
 		}
 	}
@@ -191,17 +243,34 @@ func buildWriteValueRequest(chunkChan chan *chunks.Chunk) io.Reader {
 	body, pw := io.Pipe()
 
 	go func() {
-		gw := snappy.NewBufferedWriter(pw)
+		// If the request fails, e.g. because it's canceled, the body is
+		// closed, and the rest of chunkChan is discarded.
+		w := &stickyErrWriter{w: pw}
+		gw := snappy.NewBufferedWriter(w)
 		for c := range chunkChan {
 			chunks.Serialize(*c, gw)
 		}
 		d.Chk.NoError(gw.Close())
-		d.Chk.NoError(pw.Close())
+		pw.CloseWithError(w.err)
 	}()
 
 	return body
 }
 
+// stickyErrWriter writes to w until a write fails, and then discards what's
+// written to it, rather than failing, keeping the error of the write.
+type stickyErrWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (sw *stickyErrWriter) Write(p []byte) (int, error) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(p)
+	}
+	return len(p), nil
+}
+
 func bodyReader(req *http.Request) (reader io.ReadCloser) {
 	reader = req.Body
 	if strings.Contains(req.Header.Get("Content-Encoding"), "gzip") {
@@ -334,12 +403,94 @@ func handleHasRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 	}
 }
 
+func handlePull(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	if req.Method != "POST" {
+		d.Panic("Expected post method.")
+	}
+	err := req.ParseForm()
+	d.PanicIfError(err)
+
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	wants, haves := types.RefSlice{}, types.RefSlice{}
+	for _, s := range req.PostForm["want"] {
+		h := hash.Parse(s)
+		v := vs.ReadValue(h)
+		if v == nil {
+			d.Panic("%s not found", h)
+		}
+		wants = append(wants, types.NewRef(v))
+	}
+	if len(wants) == 0 {
+		d.Panic("Expected want param")
+	}
+	for _, s := range req.PostForm["have"] {
+		if v := vs.ReadValue(hash.Parse(s)); v != nil {
+			haves = append(haves, types.NewRef(v))
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	writer := respWriter(req, w)
+	defer writer.Close()
+
+	walkMissing(cs, vs, wants, haves, func(c chunks.Chunk) {
+		chunks.Serialize(c, writer)
+	})
+}
+
+func handleHasFilter(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	if req.Method != "POST" {
+		d.Panic("Expected post method.")
+	}
+	err := req.ParseForm()
+	d.PanicIfError(err)
+
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	roots := types.RefSlice{}
+	for _, s := range req.PostForm["root"] {
+		if v := vs.ReadValue(hash.Parse(s)); v != nil {
+			roots = append(roots, types.NewRef(v))
+		}
+	}
+	f, height := hasFilter(cs, vs, roots, maxHasFilterChunks)
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	w.Header().Set(HasFilterHeightHeader, strconv.FormatUint(height, 10))
+	writer := respWriter(req, w)
+	defer writer.Close()
+	_, err = writer.Write(f.Bytes())
+	d.PanicIfError(err)
+}
+
 func handleRootGet(w http.ResponseWriter, req *http.Request, ps URLParams, rt chunks.ChunkStore) {
 	if req.Method != "GET" {
 		d.Panic("Expected get method.")
 	}
 
 	rootRef := rt.Root()
+	params := req.URL.Query()
+	if tokens := params["wait"]; len(tokens) == 1 {
+		last := hash.Parse(tokens[0])
+		timeout := maxRootWait
+		if secs, err := strconv.Atoi(params.Get("timeout")); err == nil && secs >= 0 && time.Duration(secs)*time.Second < timeout {
+			timeout = time.Duration(secs) * time.Second
+		}
+		deadline := time.After(timeout)
+		ticker := time.NewTicker(rootWaitPoll)
+		defer ticker.Stop()
+	wait:
+		for rootRef == last {
+			select {
+			case <-ticker.C:
+				rootRef = rt.Root()
+			case <-deadline:
+				break wait
+			case <-req.Context().Done():
+				return
+			}
+		}
+		w.Header().Set(RootWaitHeader, "true")
+	}
 	fmt.Fprintf(w, "%v", rootRef.String())
 	w.Header().Add("content-type", "text/plain")
 }
@@ -394,21 +545,41 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs c
 	}
 }
 
-func handleGraphQL(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+// DatasetAuthorizer reports whether the client making req may query the
+// dataset datasetID with GraphQL. datasetID is empty for queries of a value
+// by hash.
+type DatasetAuthorizer func(req *http.Request, datasetID string) bool
+
+// NewGraphQLHandler creates a handler for GraphQL requests. A request queries
+// the head of the dataset given by the ds parameter, the value given by the h
+// parameter or, if neither is given, the map of all datasets. If authorize is
+// not nil, only the datasets it allows can be queried.
+//
+// Requests are either given by the query, variables and operationName
+// parameters or, for POST requests with a Content-Type of application/json, by
+// a JSON object with these fields in the body. A JSON array of such objects
+// executes a batch of requests and returns an array of results.
+func NewGraphQLHandler(authorize DatasetAuthorizer) Handler {
+	return createHandler(func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		handleGraphQL(w, req, cs, authorize)
+	}, false)
+}
+
+func handleGraphQL(w http.ResponseWriter, req *http.Request, cs chunks.ChunkStore, authorize DatasetAuthorizer) {
 	if req.Method != http.MethodGet && req.Method != http.MethodPost {
 		d.Panic("Unexpected method")
 	}
 
+	requests, batch := readGraphQLRequests(req)
+
 	ds := req.FormValue("ds")
 	h := req.FormValue("h")
 
-	if (ds == "") == (h == "") {
-		d.Panic("Must specify one (and only one) of ds (dataset) or h (hash)")
+	if ds != "" && h != "" {
+		d.Panic("Must specify at most one of ds (dataset) or h (hash)")
 	}
-
-	query := req.FormValue("query")
-	if query == "" {
-		d.Panic("Expected query")
+	if authorize == nil {
+		authorize = func(req *http.Request, datasetID string) bool { return true }
 	}
 
 	// Note: we don't close this becaues |cs| will be closed by the generic endpoint handler
@@ -416,18 +587,33 @@ func handleGraphQL(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 
 	var rootValue types.Value
 	var err error
-	if ds != "" {
+	switch {
+	case ds != "":
+		if !authorize(req, ds) {
+			err = fmt.Errorf("Dataset %s not found", ds)
+			break
+		}
 		dataset := db.GetDataset(ds)
 		var ok bool
 		rootValue, ok = dataset.MaybeHead()
 		if !ok {
 			err = fmt.Errorf("Dataset %s not found", ds)
 		}
-	} else {
-		rootValue = db.ReadValue(hash.Parse(h))
+	case h != "":
+		if authorize(req, "") {
+			rootValue = db.ReadValue(hash.Parse(h))
+		}
 		if rootValue == nil {
 			err = errors.New("Root value not found")
 		}
+	default:
+		datasets := db.Datasets()
+		datasets.IterAll(func(k, v types.Value) {
+			if !authorize(req, string(k.(types.String))) {
+				datasets = datasets.Remove(k)
+			}
+		})
+		rootValue = datasets
 	}
 
 	w.Header().Add("Content-Type", "application/json")
@@ -436,9 +622,61 @@ func handleGraphQL(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 
 	if err != nil {
 		ngql.Error(err, writer)
+		return
+	}
+
+	results := make([]*graphql.Result, len(requests))
+	for i, r := range requests {
+		results[i] = ngql.DefaultSchemaCache.Do(ngql.NewContext(db), rootValue, r, ngql.DefaultLimits)
+	}
+	if batch {
+		err = json.NewEncoder(writer).Encode(results)
+	} else {
+		err = json.NewEncoder(writer).Encode(results[0])
+	}
+	d.PanicIfError(err)
+}
+
+// readGraphQLRequests returns the GraphQL requests in req and whether they
+// were sent as a batch.
+func readGraphQLRequests(req *http.Request) (requests []ngql.Request, batch bool) {
+	if req.Method == http.MethodPost && strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		reader := bodyReader(req)
+		defer reader.Close()
+		var body json.RawMessage
+		d.PanicIfError(json.NewDecoder(reader).Decode(&body))
+
+		if batch = strings.HasPrefix(strings.TrimSpace(string(body)), "["); batch {
+			d.PanicIfError(json.Unmarshal(body, &requests))
+		} else {
+			requests = make([]ngql.Request, 1)
+			d.PanicIfError(json.Unmarshal(body, &requests[0]))
+		}
 	} else {
-		ngql.Query(rootValue, query, db, writer)
+		r := ngql.Request{
+			Query:         req.FormValue("query"),
+			OperationName: req.FormValue("operationName"),
+		}
+		if vars := req.FormValue("variables"); vars != "" {
+			d.PanicIfError(json.Unmarshal([]byte(vars), &r.Variables))
+		}
+		requests = []ngql.Request{r}
+	}
+
+	if len(requests) == 0 {
+		d.Panic("Expected query")
+	}
+	for _, r := range requests {
+		if r.Query == "" {
+			d.Panic("Expected query")
+		}
 	}
+	return
+}
+
+func handleHealthGet(w http.ResponseWriter, req *http.Request, ps URLParams, rt chunks.ChunkStore) {
+	w.Header().Add("Content-Type", "text/plain")
+	fmt.Fprintln(w, "OK")
 }
 
 func handleBaseGet(w http.ResponseWriter, req *http.Request, ps URLParams, rt chunks.ChunkStore) {
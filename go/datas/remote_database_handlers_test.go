@@ -297,6 +297,123 @@ func TestHandleHasRefs(t *testing.T) {
 	}
 }
 
+func TestHandlePull(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := NewDatabase(cs)
+
+	ds, err := db.CommitValue(db.GetDataset("ds1"), buildListOfHeight(3, db))
+	assert.NoError(err)
+	have := ds.HeadRef()
+	l := buildListOfHeight(3, db).Append(types.String("oy!"))
+	ds, err = db.CommitValue(ds, l)
+	assert.NoError(err)
+	want := ds.HeadRef()
+	db.validatingBatchStore().Flush()
+
+	reachable := func(r types.Ref) hash.HashSet {
+		hashes := hash.HashSet{}
+		var walk func(r types.Ref)
+		walk = func(r types.Ref) {
+			hashes.Insert(r.TargetHash())
+			r.TargetValue(db).WalkRefs(walk)
+		}
+		walk(r)
+		return hashes
+	}
+	missing := reachable(want).Difference(reachable(have))
+
+	absent := hash.Parse("00000000000000000000000000000002")
+	body := strings.NewReader(fmt.Sprintf("want=%s&have=%s&have=%s", want.TargetHash(), have.TargetHash(), absent))
+	w := httptest.NewRecorder()
+	HandlePull(
+		w,
+		newRequest("POST", "", "", body, http.Header{
+			"Content-Type": {"application/x-www-form-urlencoded"},
+		}),
+		params{},
+		cs,
+	)
+
+	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		chunkChan := make(chan *chunks.Chunk, len(missing)+1)
+		assert.NoError(chunks.Deserialize(w.Body, chunkChan))
+		close(chunkChan)
+
+		found := hash.HashSet{}
+		height := want.Height()
+		for c := range chunkChan {
+			r := types.NewRef(types.DecodeValue(*c, db))
+			assert.True(r.Height() <= height, "%s after a shorter chunk", c.Hash())
+			found.Insert(c.Hash())
+			height = r.Height()
+		}
+		assert.True(missing.Equals(found))
+	}
+
+	w = httptest.NewRecorder()
+	HandlePull(
+		w,
+		newRequest("POST", "", "", strings.NewReader("want="+absent.String()), http.Header{
+			"Content-Type": {"application/x-www-form-urlencoded"},
+		}),
+		params{},
+		cs,
+	)
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestHandleHasFilter(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := NewDatabase(cs)
+
+	ds, err := db.CommitValue(db.GetDataset("ds1"), buildListOfHeight(3, db))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, buildListOfHeight(4, db))
+	assert.NoError(err)
+	head := ds.HeadRef()
+	db.validatingBatchStore().Flush()
+
+	heights := map[hash.Hash]uint64{}
+	var walk func(r types.Ref)
+	walk = func(r types.Ref) {
+		heights[r.TargetHash()] = r.Height()
+		r.TargetValue(db).WalkRefs(walk)
+	}
+	walk(head)
+
+	absent := hash.Parse("00000000000000000000000000000002")
+	body := strings.NewReader(fmt.Sprintf("root=%s&root=%s", head.TargetHash(), absent))
+	w := httptest.NewRecorder()
+	HandleHasFilter(
+		w,
+		newRequest("POST", "", "", body, http.Header{
+			"Content-Type": {"application/x-www-form-urlencoded"},
+		}),
+		params{},
+		cs,
+	)
+	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		assert.Equal("0", w.Header().Get(HasFilterHeightHeader))
+		f, err := hash.BloomFilterFromBytes(w.Body.Bytes())
+		assert.NoError(err)
+		for h := range heights {
+			assert.True(f.MayHave(h))
+		}
+	}
+
+	// Walks of too many chunks stop at a height, above which the filter is
+	// complete.
+	f, height := hasFilter(cs, db, types.RefSlice{head}, len(heights)/2)
+	assert.True(height > 0)
+	for h, ht := range heights {
+		if ht > height {
+			assert.True(f.MayHave(h))
+		}
+	}
+}
+
 func TestHandleGetRoot(t *testing.T) {
 	assert := assert.New(t)
 	cs := chunks.NewTestStore()
@@ -328,6 +445,51 @@ func TestHandleGetBase(t *testing.T) {
 	}
 }
 
+func TestHandleGraphQL(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	db := NewDatabase(cs)
+	_, err := db.CommitValue(db.GetDataset("public"), types.Number(42))
+	assert.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("secret"), types.String("shh"))
+	assert.NoError(err)
+
+	query := func(handler Handler, method, url, body string, header http.Header) string {
+		w := httptest.NewRecorder()
+		handler(w, newRequest(method, "", url, strings.NewReader(body), header), params{}, cs)
+		assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes()))
+		return w.Body.String()
+	}
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+
+	assert.JSONEq(`{"data":{"root":{"value":42}}}`,
+		query(HandleGraphQL, "GET", "/graphql/?ds=public&query="+url.QueryEscape("{root{value}}"), "", nil))
+
+	// Without ds or h all datasets are queried.
+	assert.JSONEq(`{"data":{"root":{"keys":["public","secret"]}}}`,
+		query(HandleGraphQL, "GET", "/graphql/?query="+url.QueryEscape("{root{keys}}"), "", nil))
+
+	// Variables and operation names.
+	assert.JSONEq(`{"data":{"root":{"keys":["secret"]}}}`,
+		query(HandleGraphQL, "POST", "/graphql/", `{"query":"query A {x:root{size}} query B($k: String) {root{keys(key: $k)}}","variables":{"k":"secret"},"operationName":"B"}`, jsonHeader))
+
+	// Batches.
+	assert.JSONEq(`[{"data":{"root":{"size":2}}},{"data":{"__schema":{"queryType":{"name":"Root"}}}}]`,
+		query(HandleGraphQL, "POST", "/graphql/", `[{"query":"{root{size}}"},{"query":"{__schema{queryType{name}}}"}]`, jsonHeader))
+
+	authorized := NewGraphQLHandler(func(req *http.Request, datasetID string) bool {
+		return datasetID == "public"
+	})
+	assert.JSONEq(`{"data":{"root":{"keys":["public"]}}}`,
+		query(authorized, "GET", "/graphql/?query="+url.QueryEscape("{root{keys}}"), "", nil))
+	assert.JSONEq(`{"data":null,"errors":[{"message":"Dataset secret not found","locations":null}]}`,
+		query(authorized, "GET", "/graphql/?ds=secret&query="+url.QueryEscape("{root{value}}"), "", nil))
+
+	w := httptest.NewRecorder()
+	HandleGraphQL(w, newRequest("GET", "", "/graphql/?ds=public", nil, nil), params{}, cs)
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
 func TestHandlePostRoot(t *testing.T) {
 	assert := assert.New(t)
 	cs := chunks.NewTestStore()
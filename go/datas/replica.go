@@ -0,0 +1,179 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+)
+
+// Replica keeps a ChunkStore a copy of the database served by a primary
+// RemoteDatabaseServer: it waits for the primary's root to change, pulls the
+// chunks of the new root, and then updates the root of the ChunkStore to it.
+// Serve the ChunkStore with the Replica set as the Replica of a
+// RemoteDatabaseServer, which starts and stops it, so that clients can't
+// write to it other than through the primary.
+type Replica struct {
+	cs         chunks.ChunkStore
+	db         Database
+	primaryURL string
+	auth       string
+	// If true, a RemoteDatabaseServer for the replica proxies requests which
+	// write to the database to the primary, and waits for the replica to
+	// catch up with updates of the root before responding, so that clients
+	// read their own writes. Otherwise, such requests are rejected with 403
+	// Forbidden.
+	ProxyWrites bool
+	// Wait is how long a request to the primary waits for its root to change
+	// before it's sent again. Servers which don't support waiting are polled
+	// this often.
+	Wait time.Duration
+	// Concurrency is the number of chunks pulled concurrently.
+	Concurrency int
+
+	proxy   *httputil.ReverseProxy
+	mu      sync.Mutex // held while syncing
+	started bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewReplica returns a Replica of the database at primaryURL in cs. auth is
+// sent as the Authorization header of requests to the primary, if set.
+func NewReplica(cs chunks.ChunkStore, primaryURL, auth string) *Replica {
+	u, err := url.Parse(primaryURL)
+	d.PanicIfError(err)
+	r := &Replica{
+		cs:          cs,
+		db:          NewDatabase(cs),
+		primaryURL:  primaryURL,
+		auth:        auth,
+		Wait:        30 * time.Second,
+		Concurrency: 4,
+		proxy:       httputil.NewSingleHostReverseProxy(u),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	r.proxy.ModifyResponse = func(res *http.Response) error {
+		if res.StatusCode != http.StatusOK || !strings.HasSuffix(res.Request.URL.Path, constants.RootPath) {
+			return nil
+		}
+		_, err := r.Sync()
+		return err
+	}
+	return r
+}
+
+// Sync makes the replica a copy of the primary as it is now, and returns the
+// root it copied.
+func (r *Replica) Sync() (root hash.Hash, err error) {
+	err = d.Try(func() {
+		primary := NewRemoteDatabase(r.primaryURL, r.auth)
+		defer primary.Close()
+		root = r.sync(primary)
+	})
+	return
+}
+
+// sync pulls the chunks reachable from the current root of primary, and
+// updates the root of the replica to it. The root is read once the previous
+// sync is done, so that the root of the replica only moves forward.
+func (r *Replica) sync(primary *RemoteDatabaseClient) hash.Hash {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	root := primary.rt.Root()
+	for {
+		last := r.cs.Root()
+		if last == root {
+			return root
+		}
+		if !root.IsEmpty() {
+			sourceRef := types.NewRef(primary.ReadValue(root))
+			sinkRef := types.Ref{}
+			if !last.IsEmpty() {
+				sinkRef = types.NewRef(r.db.ReadValue(last))
+			}
+			PullWithFlush(primary, r.db, sourceRef, sinkRef, r.Concurrency, nil)
+		}
+		if r.cs.UpdateRoot(root, last) {
+			return root
+		}
+	}
+}
+
+// Start follows the root of the primary on a new goroutine until Stop is
+// called. Failures to reach the primary are logged, and retried after Wait.
+func (r *Replica) Start() {
+	r.started = true
+	go r.run()
+}
+
+func (r *Replica) run() {
+	defer close(r.stopped)
+	primary := NewRemoteDatabase(r.primaryURL, r.auth)
+
+	type waited struct {
+		ok  bool
+		err error
+	}
+	for {
+		// The wait for the primary runs on its own goroutine, so that Stop
+		// doesn't have to wait for it.
+		result := make(chan waited, 1)
+		go func() {
+			var w waited
+			w.err = d.Try(func() { _, w.ok = primary.WaitForRoot(r.Wait) })
+			result <- w
+		}()
+
+		var w waited
+		select {
+		case w = <-result:
+		case <-r.stop:
+			return
+		}
+		if w.err == nil {
+			w.err = d.Try(func() { r.sync(primary) })
+		}
+		if w.err != nil {
+			verbose.Warn("Couldn't sync replica", verbose.Fields{"primary": r.primaryURL, "error": d.Unwrap(w.err)})
+		}
+		primary.Rebase()
+		if w.err != nil || !w.ok {
+			select {
+			case <-time.After(r.Wait):
+			case <-r.stop:
+				return
+			}
+		}
+	}
+}
+
+// Stop stops following the primary, and returns once any sync in progress
+// is done.
+func (r *Replica) Stop() {
+	close(r.stop)
+	if r.started {
+		<-r.stopped
+	}
+}
+
+// serveProxy sends req to the primary, with the credentials of the client.
+// Once an update of the root succeeds, the replica syncs before the response
+// is sent.
+func (r *Replica) serveProxy(w http.ResponseWriter, req *http.Request) {
+	r.proxy.ServeHTTP(w, req)
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// waitForRoot waits for the root of cs to be root.
+func waitForRoot(assert *assert.Assertions, cs chunks.ChunkStore, root hash.Hash) {
+	for start := time.Now(); cs.Root() != root; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			assert.Fail("Timed out waiting for the replica")
+			return
+		}
+	}
+}
+
+func TestReplica(t *testing.T) {
+	assert := assert.New(t)
+	primaryCS := chunks.NewTestStore()
+	primaryServer := startTestServer(primaryCS, func(s *RemoteDatabaseServer) {})
+	defer primaryServer.Stop()
+	primaryURL := fmt.Sprintf("http://localhost:%d", primaryServer.Port())
+
+	primary := NewRemoteDatabase(primaryURL, "")
+	defer primary.Close()
+	_, err := primary.CommitValue(primary.GetDataset("a"), types.NewList(types.Number(1)))
+	assert.NoError(err)
+
+	// Sync copies the primary as it is.
+	replicaCS := chunks.NewTestStore()
+	replica := NewReplica(replicaCS, primaryURL, "")
+	root, err := replica.Sync()
+	assert.NoError(err)
+	assert.Equal(primaryCS.Root(), root)
+	assert.Equal(root, replicaCS.Root())
+	assert.True(NewDatabase(replicaCS).GetDataset("a").HeadValue().Equals(types.NewList(types.Number(1))))
+
+	// The server of the replica follows the primary.
+	replica.Wait = 100 * time.Millisecond
+	replicaServer := startTestServer(replicaCS, func(s *RemoteDatabaseServer) {
+		s.Replica = replica
+	})
+	defer replicaServer.Stop()
+	replicaURL := fmt.Sprintf("http://localhost:%d", replicaServer.Port())
+
+	_, err = primary.CommitValue(primary.GetDataset("b"), types.String("b"))
+	assert.NoError(err)
+	waitForRoot(assert, replicaCS, primaryCS.Root())
+	db := NewRemoteDatabase(replicaURL, "")
+	defer db.Close()
+	assert.True(db.GetDataset("b").HeadValue().Equals(types.String("b")))
+
+	res := testServerRequest(assert, http.DefaultClient, "POST", replicaURL+constants.WriteValuePath)
+	assert.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func TestReplicaProxyWrites(t *testing.T) {
+	assert := assert.New(t)
+	primaryCS := chunks.NewTestStore()
+	primaryServer := startTestServer(primaryCS, func(s *RemoteDatabaseServer) {})
+	defer primaryServer.Stop()
+	primaryURL := fmt.Sprintf("http://localhost:%d", primaryServer.Port())
+
+	replicaCS := chunks.NewTestStore()
+	replicaServer := startTestServer(replicaCS, func(s *RemoteDatabaseServer) {
+		s.Replica = NewReplica(replicaCS, primaryURL, "")
+		s.Replica.ProxyWrites = true
+	})
+	defer replicaServer.Stop()
+
+	// Commits through the replica are written to the primary, and can be
+	// read from the replica right away.
+	db := NewRemoteDatabase(fmt.Sprintf("http://localhost:%d", replicaServer.Port()), "")
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("a"), types.Number(1))
+	assert.NoError(err)
+	assert.Equal(primaryCS.Root(), replicaCS.Root())
+	ds, err = db.CommitValue(ds, types.Number(2))
+	assert.NoError(err)
+	assert.Equal(primaryCS.Root(), replicaCS.Root())
+	assert.True(NewDatabase(primaryCS).GetDataset("a").HeadValue().Equals(types.Number(2)))
+}
+
+func TestReplicaPromote(t *testing.T) {
+	assert := assert.New(t)
+	primaryCS := chunks.NewTestStore()
+	primaryServer := startTestServer(primaryCS, func(s *RemoteDatabaseServer) {})
+	defer primaryServer.Stop()
+
+	replicaCS := chunks.NewTestStore()
+	replicaServer := startTestServer(replicaCS, func(s *RemoteDatabaseServer) {
+		s.Replica = NewReplica(replicaCS, fmt.Sprintf("http://localhost:%d", primaryServer.Port()), "")
+		s.Auth = testServerAuth{"admin": {"a": AdminAccess}}
+	})
+	defer replicaServer.Stop()
+	replicaURL := fmt.Sprintf("http://localhost:%d", replicaServer.Port())
+
+	var stats AdminStats
+	assert.Equal(http.StatusOK, adminRequest(assert, "GET", replicaURL+constants.AdminStatsPath, "admin", &stats))
+	assert.Equal(fmt.Sprintf("http://localhost:%d", primaryServer.Port()), stats.Primary)
+	assert.Equal(http.StatusForbidden, adminRequest(assert, "POST", replicaURL+constants.WriteValuePath, "admin", nil))
+
+	assert.Equal(http.StatusOK, adminRequest(assert, "POST", replicaURL+constants.AdminPromotePath, "admin", nil))
+	assert.NotEqual(http.StatusForbidden, adminRequest(assert, "POST", replicaURL+constants.WriteValuePath, "admin", nil))
+	assert.Equal(http.StatusConflict, adminRequest(assert, "POST", replicaURL+constants.AdminPromotePath, "admin", nil))
+}
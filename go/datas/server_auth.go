@@ -0,0 +1,146 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Access is the access a client of a RemoteDatabaseServer has to a dataset.
+type Access int
+
+const (
+	NoAccess Access = iota
+	ReadAccess
+	WriteAccess
+	// AdminAccess allows the admin API to be used, in addition to
+	// WriteAccess.
+	AdminAccess
+)
+
+func (a Access) String() string {
+	return [...]string{"none", "read", "write", "admin"}[a]
+}
+
+// ServerAuth authenticates the clients of a RemoteDatabaseServer and reports
+// the access they have to its datasets. Implementations must be safe to call
+// concurrently.
+type ServerAuth interface {
+	// Authenticate returns the name of the client making req, or an error if
+	// its credentials are missing or invalid.
+	Authenticate(req *http.Request) (string, error)
+
+	// Access returns the access the client called name has to the dataset
+	// datasetID. If datasetID is empty, it returns the most access the client
+	// has to any dataset, which is the access it has to chunks, as chunks
+	// can't be attributed to datasets.
+	Access(name, datasetID string) Access
+}
+
+type clientNameKey struct{}
+
+// authHandle authenticates requests to f, and rejects them unless the client
+// has at least the access need to chunks.
+func (s *RemoteDatabaseServer) authHandle(need Access, f httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if s.Auth == nil {
+			f(w, req, ps)
+			return
+		}
+		name, err := s.Auth.Authenticate(req)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="noms"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if s.Auth.Access(name, "") < need {
+			http.Error(w, fmt.Sprintf("%s doesn't have %s access", name, need), http.StatusForbidden)
+			return
+		}
+		f(w, req.WithContext(context.WithValue(req.Context(), clientNameKey{}, name)), ps)
+	}
+}
+
+// rootPostAuthHandle rejects requests to f which change datasets the client
+// doesn't have write access to. It must be wrapped by authHandle.
+func (s *RemoteDatabaseServer) rootPostAuthHandle(f httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if s.Auth == nil {
+			f(w, req, ps)
+			return
+		}
+		name := req.Context().Value(clientNameKey{}).(string)
+		last, lastOk := hash.MaybeParse(req.URL.Query().Get("last"))
+		current, currentOk := hash.MaybeParse(req.URL.Query().Get("current"))
+		// Malformed requests are rejected by f before anything is written.
+		if lastOk && currentOk {
+			for _, id := range changedDatasets(s.cs, last, current) {
+				if s.Auth.Access(name, id) < WriteAccess {
+					http.Error(w, fmt.Sprintf("%s doesn't have write access to %s", name, id), http.StatusForbidden)
+					return
+				}
+			}
+		}
+		f(w, req, ps)
+	}
+}
+
+// graphQLAuthorizer returns the DatasetAuthorizer of GraphQL requests, which
+// also requires the client to have read access to datasets if Auth is set.
+func (s *RemoteDatabaseServer) graphQLAuthorizer() DatasetAuthorizer {
+	if s.Auth == nil {
+		return s.Authorize
+	}
+	return func(req *http.Request, datasetID string) bool {
+		name, _ := req.Context().Value(clientNameKey{}).(string)
+		if s.Auth.Access(name, datasetID) < ReadAccess {
+			return false
+		}
+		return s.Authorize == nil || s.Authorize(req, datasetID)
+	}
+}
+
+// changedDatasets returns the IDs of the datasets which are added, removed or
+// moved between the roots last and current. If current isn't a Map in cs, it
+// returns nil, and the root can't be updated to it anyway.
+func changedDatasets(cs chunks.ChunkStore, last, current hash.Hash) []string {
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	currentDatasets, ok := vs.ReadValue(current).(types.Map)
+	if !ok {
+		return nil
+	}
+	lastDatasets := types.NewMap()
+	if !last.IsEmpty() {
+		if m, ok := vs.ReadValue(last).(types.Map); ok {
+			lastDatasets = m
+		}
+	}
+
+	// Keys which aren't Strings are rejected when the root is updated.
+	changed := []string{}
+	add := func(k types.Value) {
+		if id, ok := k.(types.String); ok {
+			changed = append(changed, string(id))
+		}
+	}
+	currentDatasets.IterAll(func(k, v types.Value) {
+		if lv, ok := lastDatasets.MaybeGet(k); !ok || !lv.Equals(v) {
+			add(k)
+		}
+	})
+	lastDatasets.IterAll(func(k, v types.Value) {
+		if !currentDatasets.Has(k) {
+			add(k)
+		}
+	})
+	return changed
+}
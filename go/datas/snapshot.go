@@ -0,0 +1,136 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// SnapshotPrefix starts the IDs of the datasets snapshots are stored in. The
+// head of the dataset of a snapshot is a commit of the Map of the heads of
+// the other datasets, which has no parents, and the time of the snapshot in
+// the date field of its meta.
+const SnapshotPrefix = "snapshots/"
+
+// Snapshot is the heads of all datasets of a Database at a moment, other
+// than those of snapshots.
+type Snapshot struct {
+	Label string
+	// Date is the time of the snapshot, if known.
+	Date time.Time
+	// Datasets is a Map<String, Ref<Commit>> of the IDs of the datasets to
+	// their heads.
+	Datasets types.Map
+}
+
+// Snapshots returns the snapshots of db, ordered by label.
+func Snapshots(db Database) []Snapshot {
+	snapshots := []Snapshot{}
+	db.Datasets().IterFrom(types.String(SnapshotPrefix), func(k, v types.Value) bool {
+		id := string(k.(types.String))
+		if !strings.HasPrefix(id, SnapshotPrefix) {
+			return true
+		}
+		snapshots = append(snapshots, snapshotOf(id, v.(types.Ref).TargetValue(db).(types.Struct)))
+		return false
+	})
+	return snapshots
+}
+
+// GetSnapshot returns the snapshot of db called label, if any.
+func GetSnapshot(db Database, label string) (Snapshot, bool) {
+	r, ok := db.Datasets().MaybeGet(types.String(SnapshotPrefix + label))
+	if !ok {
+		return Snapshot{}, false
+	}
+	return snapshotOf(SnapshotPrefix+label, r.(types.Ref).TargetValue(db).(types.Struct)), true
+}
+
+func snapshotOf(id string, commit types.Struct) Snapshot {
+	s := Snapshot{Label: strings.TrimPrefix(id, SnapshotPrefix), Datasets: commit.Get(ValueField).(types.Map)}
+	if meta, ok := commit.Get(MetaField).(types.Struct); ok {
+		if date, ok := meta.MaybeGet("date"); ok {
+			if str, ok := date.(types.String); ok {
+				s.Date, _ = time.Parse(time.RFC3339, string(str))
+			}
+		}
+	}
+	return s
+}
+
+func (dbc *databaseCommon) Snapshot(label string) (Snapshot, error) {
+	var s Snapshot
+	err := tryUpdate(func() (err error) {
+		s, err = dbc.doSnapshot(label)
+		return
+	})
+	return s, err
+}
+
+func (dbc *databaseCommon) Restore(s Snapshot) error {
+	return tryUpdate(func() error { return dbc.doRestore(s) })
+}
+
+// doSnapshot records the heads of the datasets other than snapshots as the
+// snapshot label, in one update of the root.
+func (dbc *databaseCommon) doSnapshot(label string) (Snapshot, error) {
+	id := SnapshotPrefix + label
+	if !DatasetFullRe.MatchString(id) {
+		d.Panic("Invalid snapshot label: %s", label)
+	}
+	defer dbc.resetRoot()
+
+	for {
+		currentRootHash, currentDatasets := dbc.getRootAndDatasets()
+		if currentDatasets.Has(types.String(id)) {
+			return Snapshot{}, ErrDatasetExists
+		}
+		s := Snapshot{Label: label, Date: time.Now().UTC().Truncate(time.Second), Datasets: withoutSnapshots(currentDatasets)}
+		meta := types.NewStruct("", types.StructData{"date": types.String(s.Date.Format(time.RFC3339))})
+		commitRef := dbc.WriteValue(NewCommit(s.Datasets, types.NewSet(), meta))
+		currentDatasets = currentDatasets.Set(types.String(id), types.ToRefOfValue(commitRef))
+		if err := dbc.tryUpdateRoot(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return s, err
+		}
+	}
+}
+
+// doRestore moves the heads of the datasets other than snapshots to those of
+// s, removing those created since, in one update of the root.
+func (dbc *databaseCommon) doRestore(s Snapshot) error {
+	defer dbc.resetRoot()
+
+	for {
+		currentRootHash, currentDatasets := dbc.getRootAndDatasets()
+		datasets := withoutSnapshots(s.Datasets)
+		currentDatasets.IterFrom(types.String(SnapshotPrefix), func(k, v types.Value) bool {
+			if !strings.HasPrefix(string(k.(types.String)), SnapshotPrefix) {
+				return true
+			}
+			datasets = datasets.Set(k, v)
+			return false
+		})
+		if err := dbc.tryUpdateRoot(datasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return err
+		}
+	}
+}
+
+// withoutSnapshots returns datasets without the datasets of snapshots.
+func withoutSnapshots(datasets types.Map) types.Map {
+	result := datasets
+	datasets.IterFrom(types.String(SnapshotPrefix), func(k, v types.Value) bool {
+		if !strings.HasPrefix(string(k.(types.String)), SnapshotPrefix) {
+			return true
+		}
+		result = result.Remove(k)
+		return false
+	})
+	return result
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/retry"
+)
+
+// ErrTooManyRetries is returned by StructEditor.Commit if the commit conflicted
+// with concurrent commits to the dataset more than StructEditor.Retries
+// times.
+var ErrTooManyRetries = errors.New("Too many concurrent commits to the dataset")
+
+// FieldMismatchError is returned by StructEditor.Commit if a field of the
+// head of the dataset doesn't have the value it was expected to have. A nil
+// Expected or Actual means the field is absent.
+type FieldMismatchError struct {
+	Field    string
+	Expected types.Value
+	Actual   types.Value
+}
+
+func (e FieldMismatchError) Error() string {
+	describe := func(v types.Value) string {
+		if v == nil {
+			return "absent"
+		}
+		return types.EncodedValueMaxLines(v, 1)
+	}
+	return fmt.Sprintf("Field %s is %s, expected %s", e.Field, describe(e.Actual), describe(e.Expected))
+}
+
+// StructEditor commits edits of the fields of the Struct at the head of a
+// dataset, each of which may be conditional on the values of fields: a
+// field-level compare-and-set. The edits are applied to the head as of the
+// commit, rather than the head they were made against, and retried if the
+// dataset was committed to concurrently, so that writers which edit disjoint
+// fields don't conflict, and writers which edit the same fields only do if
+// the values they expect are changed.
+type StructEditor struct {
+	db        Database
+	datasetID string
+	name      string
+	sets      map[string]types.Value
+	expected  map[string]types.Value
+	// Meta is the meta of the commit, see CommitOptions.
+	Meta types.Struct
+	// Retries is how many times Commit is retried after conflicting with
+	// concurrent commits to the dataset.
+	Retries int
+}
+
+// NewStructEditor returns a StructEditor of the head of the dataset
+// datasetID of db. If the dataset has no head, the edits are applied to an
+// empty Struct called name.
+func NewStructEditor(db Database, datasetID, name string) *StructEditor {
+	return &StructEditor{
+		db:        db,
+		datasetID: datasetID,
+		name:      name,
+		sets:      map[string]types.Value{},
+		expected:  map[string]types.Value{},
+		Retries:   20,
+	}
+}
+
+// Set sets the field to v, or removes it if v is nil.
+func (e *StructEditor) Set(field string, v types.Value) *StructEditor {
+	e.sets[field] = v
+	return e
+}
+
+// Expect makes the edits conditional on the field having the value v, or
+// being absent if v is nil, as of the commit.
+func (e *StructEditor) Expect(field string, v types.Value) *StructEditor {
+	e.expected[field] = v
+	return e
+}
+
+// Commit commits the edits to the dataset, and returns it as of the commit.
+// If a field doesn't have the value it's expected to have, Commit commits
+// nothing and returns a FieldMismatchError. If the head of the dataset isn't
+// a Struct, Commit panics.
+func (e *StructEditor) Commit() (ds Dataset, err error) {
+	ok := retry.Do(e.Retries, func() bool {
+		e.db.Rebase()
+		ds = e.db.GetDataset(e.datasetID)
+		s := types.NewStruct(e.name, types.StructData{})
+		if head, ok := ds.MaybeHeadValue(); ok {
+			s = head.(types.Struct)
+		}
+		if err = e.check(s); err != nil {
+			return false
+		}
+		for field, v := range e.sets {
+			if v == nil {
+				s = s.Delete(field)
+			} else {
+				s = s.Set(field, v)
+			}
+		}
+		ds, err = e.db.Commit(ds, s, CommitOptions{Meta: e.Meta})
+		return err == ErrMergeNeeded
+	})
+	if !ok {
+		return e.db.GetDataset(e.datasetID), ErrTooManyRetries
+	}
+	return ds, err
+}
+
+// check returns a FieldMismatchError for the first field, by name, of s
+// which doesn't have the value it's expected to have.
+func (e *StructEditor) check(s types.Struct) error {
+	fields := make([]string, 0, len(e.expected))
+	for field := range e.expected {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		expected := e.expected[field]
+		actual, ok := s.MaybeGet(field)
+		if !ok {
+			actual = nil
+		}
+		if (expected == nil) != (actual == nil) || (expected != nil && !expected.Equals(actual)) {
+			return FieldMismatchError{field, expected, actual}
+		}
+	}
+	return nil
+}
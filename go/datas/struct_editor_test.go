@@ -0,0 +1,130 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestStructEditor(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	ds, err := NewStructEditor(db, "ds", "Config").
+		Expect("a", nil).
+		Set("a", types.Number(1)).
+		Set("b", types.String("x")).
+		Commit()
+	assert.NoError(err)
+	assert.True(types.NewStruct("Config", types.StructData{"a": types.Number(1), "b": types.String("x")}).Equals(ds.HeadValue()))
+
+	// A mismatch commits nothing.
+	head := ds.HeadRef()
+	ds, err = NewStructEditor(db, "ds", "Config").
+		Expect("a", types.Number(1)).
+		Expect("b", types.String("y")).
+		Set("a", types.Number(2)).
+		Commit()
+	assert.Equal(FieldMismatchError{"b", types.String("y"), types.String("x")}, err)
+	assert.Equal(`Field b is "x", expected "y"`, err.Error())
+	assert.Equal(head, ds.HeadRef())
+	_, err = NewStructEditor(db, "ds", "Config").Expect("c", types.Bool(true)).Commit()
+	assert.Equal(FieldMismatchError{"c", types.Bool(true), nil}, err)
+	assert.Equal("Field c is absent, expected true", err.Error())
+
+	// Fields are removed by setting them to nil.
+	ds, err = NewStructEditor(db, "ds", "Config").
+		Expect("a", types.Number(1)).
+		Set("a", types.Number(2)).
+		Set("b", nil).
+		Commit()
+	assert.NoError(err)
+	assert.True(types.NewStruct("Config", types.StructData{"a": types.Number(2)}).Equals(ds.HeadValue()))
+	assert.True(ds.Head().Get(ParentsField).(types.Set).Has(head))
+}
+
+func TestStructEditorConcurrentCommits(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	w := &waitDuringUpdateRootChunkStore{cs, nil}
+	db, other := NewDatabase(w), NewDatabase(cs)
+	defer db.Close()
+	defer other.Close()
+
+	_, err := NewStructEditor(db, "ds", "").Set("a", types.Number(0)).Set("b", types.Number(0)).Commit()
+	assert.NoError(err)
+
+	// commitOnce commits edit, with other, just before db next updates its
+	// root.
+	commitOnce := func(edit func(e *StructEditor)) {
+		w.preUpdateRootHook = func() {
+			w.preUpdateRootHook = nil
+			e := NewStructEditor(other, "ds", "")
+			edit(e)
+			_, err := e.Commit()
+			assert.NoError(err)
+		}
+	}
+
+	// Edits of disjoint fields don't conflict, they're retried on the new
+	// head.
+	commitOnce(func(e *StructEditor) { e.Expect("b", types.Number(0)).Set("b", types.Number(1)) })
+	ds, err := NewStructEditor(db, "ds", "").Expect("a", types.Number(0)).Set("a", types.Number(1)).Commit()
+	assert.NoError(err)
+	assert.True(types.NewStruct("", types.StructData{"a": types.Number(1), "b": types.Number(1)}).Equals(ds.HeadValue()))
+
+	// Edits of the same field conflict if the value expected changed.
+	commitOnce(func(e *StructEditor) { e.Expect("a", types.Number(1)).Set("a", types.Number(2)) })
+	_, err = NewStructEditor(db, "ds", "").Expect("a", types.Number(1)).Set("a", types.Number(3)).Commit()
+	assert.Equal(FieldMismatchError{"a", types.Number(1), types.Number(2)}, err)
+
+	// Nothing is retried more than Retries times.
+	e := NewStructEditor(db, "ds", "").Set("c", types.Number(1))
+	e.Retries = 0
+	commitOnce(func(e *StructEditor) { e.Set("d", types.Number(1)) })
+	_, err = e.Commit()
+	assert.Equal(ErrTooManyRetries, err)
+}
+
+func TestStructEditorCounters(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	// Each writer increments its own field, using the value it read as the
+	// expected one.
+	const writers, increments = 4, 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		field := fmt.Sprintf("n%d", i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				e := NewStructEditor(db, "counters", "Counters")
+				if j == 0 {
+					e.Expect(field, nil)
+				} else {
+					e.Expect(field, types.Number(j))
+				}
+				_, err := e.Set(field, types.Number(j+1)).Commit()
+				assert.NoError(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	head := db.GetDataset("counters").HeadValue().(types.Struct)
+	for i := 0; i < writers; i++ {
+		assert.Equal(types.Number(increments), head.Get(fmt.Sprintf("n%d", i)))
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// The Try functions are like the Database and Dataset methods they call, but
+// return the errors that those panic with, e.g. when the ChunkStore of the
+// Database can't be read, or is missing chunks the values read refer to, so
+// that long-lived programs can handle them. The Database methods which
+// already return errors, like Commit, return these errors too.
+// nomserrors.Class returns the class of the errors, e.g. nomserrors.ErrAuth.
+
+// TryReadValue is like db.ReadValue(h).
+func TryReadValue(db Database, h hash.Hash) (v types.Value, err error) {
+	defer d.Recover(&err)
+	return db.ReadValue(h), nil
+}
+
+// TryWriteValue is like db.WriteValue(v).
+func TryWriteValue(db Database, v types.Value) (r types.Ref, err error) {
+	defer d.Recover(&err)
+	return db.WriteValue(v), nil
+}
+
+// TryDatasets is like db.Datasets().
+func TryDatasets(db Database) (m types.Map, err error) {
+	defer d.Recover(&err)
+	return db.Datasets(), nil
+}
+
+// TryGetDataset is like db.GetDataset(datasetID), but also returns an error
+// if datasetID isn't a valid dataset ID.
+func TryGetDataset(db Database, datasetID string) (ds Dataset, err error) {
+	defer d.Recover(&err)
+	return db.GetDataset(datasetID), nil
+}
+
+// TryHead is like ds.MaybeHead().
+func TryHead(ds Dataset) (head types.Struct, ok bool, err error) {
+	defer d.Recover(&err)
+	head, ok = ds.MaybeHead()
+	return head, ok, nil
+}
+
+// tryUpdate returns the error update returns, or panics with, so that the
+// Database methods which return errors don't also panic.
+func tryUpdate(update func() error) (err error) {
+	defer d.Recover(&err)
+	return update()
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+var errTestStore = errors.New("test store failed")
+
+// failingStore is a MemoryStore whose methods panic with errTestStore once
+// failing is set, as ChunkStores do when they can't be read or written.
+type failingStore struct {
+	*chunks.MemoryStore
+	failing bool
+}
+
+func (s *failingStore) fail() {
+	if s.failing {
+		d.PanicIfError(errTestStore)
+	}
+}
+
+func (s *failingStore) Get(h hash.Hash) chunks.Chunk {
+	s.fail()
+	return s.MemoryStore.Get(h)
+}
+
+func (s *failingStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	s.fail()
+	s.MemoryStore.GetMany(hashes, foundChunks)
+}
+
+func (s *failingStore) Put(c chunks.Chunk) {
+	s.fail()
+	s.MemoryStore.Put(c)
+}
+
+func (s *failingStore) UpdateRoot(current, last hash.Hash) bool {
+	s.fail()
+	return s.MemoryStore.UpdateRoot(current, last)
+}
+
+func TestTry(t *testing.T) {
+	assert := assert.New(t)
+	fs := &failingStore{MemoryStore: chunks.NewMemoryStore()}
+	db := NewDatabase(fs)
+	defer db.Close()
+
+	r, err := TryWriteValue(db, types.String("a"))
+	assert.NoError(err)
+	ds, err := TryGetDataset(db, "ds")
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, r)
+	assert.NoError(err)
+	head, ok, err := TryHead(ds)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.True(ds.Head().Equals(head))
+	v, err := TryReadValue(db, r.TargetHash())
+	assert.NoError(err)
+	assert.True(types.String("a").Equals(v))
+	m, err := TryDatasets(db)
+	assert.NoError(err)
+	assert.Equal(uint64(1), m.Len())
+
+	_, err = TryGetDataset(db, "invalid dataset")
+	assert.Error(err)
+
+	db2 := NewDatabase(fs)
+	defer db2.Close()
+	fs.failing = true
+	_, err = TryDatasets(db2)
+	assert.Equal(errTestStore, err)
+	_, err = TryGetDataset(db2, "ds")
+	assert.Equal(errTestStore, err)
+	_, err = TryReadValue(db2, r.TargetHash())
+	assert.Equal(errTestStore, err)
+
+	// Commit returns the errors of the ChunkStore too.
+	_, err = db.CommitValue(ds, types.String("b"))
+	assert.Equal(errTestStore, err)
+	fs.failing = false
+	assert.True(db.GetDataset("ds").Head().Equals(head))
+}
+
+// missingStore is a MemoryStore which doesn't have the chunk missing.
+type missingStore struct {
+	*chunks.MemoryStore
+	missing hash.Hash
+}
+
+func (s *missingStore) Get(h hash.Hash) chunks.Chunk {
+	if h == s.missing {
+		return chunks.EmptyChunk
+	}
+	return s.MemoryStore.Get(h)
+}
+
+func (s *missingStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	hashes = hashes.Copy()
+	hashes.Remove(s.missing)
+	s.MemoryStore.GetMany(hashes, foundChunks)
+}
+
+func (s *missingStore) Has(h hash.Hash) bool {
+	return h != s.missing && s.MemoryStore.Has(h)
+}
+
+func TestTryMissingChild(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := NewDatabase(cs)
+	for i := 0; i < 500; i++ {
+		_, err := db.CommitValue(db.GetDataset(fmt.Sprintf("ds%03d", i)), types.Number(i))
+		assert.NoError(err)
+	}
+	assert.NoError(db.Close())
+
+	// The Map of datasets has chunks of its own, one of which is missing.
+	ms := &missingStore{MemoryStore: cs}
+	db = NewDatabase(ms)
+	defer db.Close()
+	m, err := TryDatasets(db)
+	assert.NoError(err)
+	m.WalkRefs(func(r types.Ref) {
+		if ms.missing.IsEmpty() && r.Height() > 1 {
+			ms.missing = r.TargetHash()
+		}
+	})
+	assert.False(ms.missing.IsEmpty())
+
+	_, err = TryGetDataset(db, "ds000")
+	assert.Equal(nomserrors.ErrChunkNotFound, nomserrors.Class(err), "%v", err)
+	// The datasets in the other chunks can still be read.
+	ds, err := TryGetDataset(db, "ds499")
+	assert.NoError(err)
+	assert.True(types.Number(499).Equals(ds.HeadValue()))
+}
@@ -0,0 +1,359 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	"github.com/julienschmidt/httprouter"
+)
+
+// WebhookSignatureHeader is the header of webhook requests which has the
+// signature of the body, as "sha256=" followed by the hex HMAC-SHA256 of the
+// body keyed with the Secret of the Webhook.
+const WebhookSignatureHeader = "X-Noms-Signature"
+
+// Webhook is a URL a RemoteDatabaseServer POSTs a WebhookEvent to, as JSON,
+// whenever an update of its root moves the head of a dataset.
+type Webhook struct {
+	URL string
+	// If set, requests are signed with Secret, see WebhookSignatureHeader.
+	Secret string
+	// If set, only events of the datasets it matches are sent.
+	Datasets *regexp.Regexp
+}
+
+// WebhookEvent describes the move of the head of a dataset. OldHead is empty
+// if the dataset was created, and NewHead if it was deleted, in which case
+// Meta and Diff are omitted.
+type WebhookEvent struct {
+	Dataset string                 `json:"dataset"`
+	OldHead string                 `json:"oldHead,omitempty"`
+	NewHead string                 `json:"newHead,omitempty"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
+	Diff    *WebhookDiff           `json:"diff,omitempty"`
+}
+
+// WebhookDiff summarizes the changes between the values of the old and new
+// heads of a dataset. For Maps, Sets and Lists, it counts the entries added,
+// removed and modified; other values which differ count as one modification.
+type WebhookDiff struct {
+	Added    uint64 `json:"added"`
+	Removed  uint64 `json:"removed"`
+	Modified uint64 `json:"modified"`
+}
+
+const (
+	// webhookQueueSize bounds the number of root updates waiting to be sent to
+	// webhooks, after which updates are dropped rather than slowing commits.
+	webhookQueueSize = 256
+
+	// webhookAttempts is the number of times a request is sent to a webhook
+	// which fails or responds with an error.
+	webhookAttempts = 5
+)
+
+// webhookBackoff is how long to wait before sending a request to a webhook
+// again for the first time, which doubles for each further attempt.
+var webhookBackoff = time.Second
+
+type rootUpdate struct {
+	last, current hash.Hash
+}
+
+// webhookSender sends the events of root updates to webhooks, in the order
+// of the updates.
+type webhookSender struct {
+	hooks   []Webhook
+	cs      chunks.ChunkStore
+	client  *http.Client
+	updates chan rootUpdate
+	stop    chan struct{}
+	done    sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newWebhookSender(hooks []Webhook, cs chunks.ChunkStore) *webhookSender {
+	ws := &webhookSender{
+		hooks:   hooks,
+		cs:      cs,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		updates: make(chan rootUpdate, webhookQueueSize),
+		stop:    make(chan struct{}),
+	}
+	ws.done.Add(1)
+	go ws.run()
+	return ws
+}
+
+// updated queues the events of the root update from last to current.
+func (ws *webhookSender) updated(last, current hash.Hash) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return
+	}
+	select {
+	case ws.updates <- rootUpdate{last, current}:
+	default:
+		verbose.Warn("Webhook queue is full, dropping root update", verbose.Fields{"current": current.String()})
+	}
+}
+
+// close stops sending, abandoning events not yet sent, and returns when the
+// chunk store is no longer used.
+func (ws *webhookSender) close() {
+	ws.mu.Lock()
+	ws.closed = true
+	close(ws.stop)
+	close(ws.updates)
+	ws.mu.Unlock()
+	ws.done.Wait()
+}
+
+func (ws *webhookSender) run() {
+	defer ws.done.Done()
+	for u := range ws.updates {
+		for _, ev := range webhookEvents(ws.cs, u.last, u.current) {
+			body, err := json.Marshal(ev)
+			if err != nil {
+				verbose.Error("Couldn't encode webhook event", verbose.Fields{"dataset": ev.Dataset, "error": err})
+				continue
+			}
+			for _, hook := range ws.hooks {
+				if hook.Datasets != nil && !hook.Datasets.MatchString(ev.Dataset) {
+					continue
+				}
+				if !ws.send(hook, body) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// send POSTs body to hook, retrying with backoff on failure. It returns
+// false if the sender was closed meanwhile.
+func (ws *webhookSender) send(hook Webhook, body []byte) bool {
+	backoff := webhookBackoff
+	for i := 0; i < webhookAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ws.stop:
+				return false
+			}
+		}
+		err := ws.post(hook, body)
+		if err == nil {
+			return true
+		}
+		verbose.Warn("Webhook request failed", verbose.Fields{"url": hook.URL, "attempt": i + 1, "error": err})
+	}
+	verbose.Error("Giving up on webhook request", verbose.Fields{"url": hook.URL})
+	return true
+}
+
+func (ws *webhookSender) post(hook Webhook, body []byte) error {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, WebhookSignature(hook.Secret, body))
+	}
+	res, err := ws.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// WebhookSignature returns the value of the WebhookSignatureHeader of a
+// request with body, signed with secret. Receivers should compare it to the
+// header with hmac.Equal.
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookEvents returns the events of the datasets whose heads moved between
+// the roots last and current.
+func webhookEvents(cs chunks.ChunkStore, last, current hash.Hash) []WebhookEvent {
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	lastDatasets := types.NewMap()
+	if !last.IsEmpty() {
+		lastDatasets = vs.ReadValue(last).(types.Map)
+	}
+	currentDatasets := vs.ReadValue(current).(types.Map)
+
+	events := []WebhookEvent{}
+	for _, id := range changedDatasets(cs, last, current) {
+		ev := WebhookEvent{Dataset: id}
+		var oldValue types.Value
+		if r, ok := lastDatasets.MaybeGet(types.String(id)); ok {
+			ev.OldHead = r.(types.Ref).TargetHash().String()
+			oldValue = r.(types.Ref).TargetValue(vs).(types.Struct).Get(ValueField)
+		}
+		if r, ok := currentDatasets.MaybeGet(types.String(id)); ok {
+			ev.NewHead = r.(types.Ref).TargetHash().String()
+			newHead := r.(types.Ref).TargetValue(vs).(types.Struct)
+			ev.Meta = webhookMeta(newHead.Get(MetaField))
+			ev.Diff = &WebhookDiff{}
+			ev.Diff.add(oldValue, newHead.Get(ValueField))
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// webhookMeta returns the fields of the meta struct of a commit as JSON
+// values: Strings, Numbers and Bools as such, and other values encoded.
+func webhookMeta(meta types.Value) map[string]interface{} {
+	s, ok := meta.(types.Struct)
+	if !ok {
+		return nil
+	}
+	m := map[string]interface{}{}
+	s.IterFields(func(name string, v types.Value) {
+		switch v := v.(type) {
+		case types.String:
+			m[name] = string(v)
+		case types.Number:
+			m[name] = float64(v)
+		case types.Bool:
+			m[name] = bool(v)
+		default:
+			m[name] = types.EncodedValue(v)
+		}
+	})
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// add counts the changes from last, which is nil for a new dataset, to
+// current.
+func (wd *WebhookDiff) add(last, current types.Value) {
+	if last != nil && last.Equals(current) {
+		return
+	}
+	switch c := current.(type) {
+	case types.Map:
+		l, ok := last.(types.Map)
+		if last == nil {
+			l, ok = types.NewMap(), true
+		}
+		if ok {
+			changes := make(chan types.ValueChanged)
+			go func() {
+				c.Diff(l, changes, nil)
+				close(changes)
+			}()
+			wd.addChanges(changes)
+			return
+		}
+	case types.Set:
+		l, ok := last.(types.Set)
+		if last == nil {
+			l, ok = types.NewSet(), true
+		}
+		if ok {
+			changes := make(chan types.ValueChanged)
+			go func() {
+				c.Diff(l, changes, nil)
+				close(changes)
+			}()
+			wd.addChanges(changes)
+			return
+		}
+	case types.List:
+		l, ok := last.(types.List)
+		if last == nil {
+			l, ok = types.NewList(), true
+		}
+		if ok {
+			splices := make(chan types.Splice)
+			go func() {
+				c.Diff(l, splices, nil)
+				close(splices)
+			}()
+			for sp := range splices {
+				wd.Added += sp.SpAdded
+				wd.Removed += sp.SpRemoved
+			}
+			return
+		}
+	}
+	if last == nil {
+		wd.Added++
+	} else {
+		wd.Modified++
+	}
+}
+
+func (wd *WebhookDiff) addChanges(changes <-chan types.ValueChanged) {
+	for c := range changes {
+		switch c.ChangeType {
+		case types.DiffChangeAdded:
+			wd.Added++
+		case types.DiffChangeRemoved:
+			wd.Removed++
+		case types.DiffChangeModified:
+			wd.Modified++
+		}
+	}
+}
+
+// webhookHandle queues the events of the root updates made by requests to
+// f, which must be the handler of root POST requests.
+func (s *RemoteDatabaseServer) webhookHandle(f httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if s.webhooks == nil {
+			f(w, req, ps)
+			return
+		}
+		sw := &statusWriter{w, http.StatusOK}
+		f(sw, req, ps)
+		if sw.status == http.StatusOK {
+			q := req.URL.Query()
+			s.webhooks.updated(hash.Parse(q.Get("last")), hash.Parse(q.Get("current")))
+		}
+	}
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestRemoteDatabaseServerWebhooks(t *testing.T) {
+	assert := assert.New(t)
+	defer func(b time.Duration) { webhookBackoff = b }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+
+	events := make(chan WebhookEvent, 16)
+	failed := false
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NoError(err)
+		assert.True(hmac.Equal([]byte(WebhookSignature("s3cret", body)), []byte(req.Header.Get(WebhookSignatureHeader))))
+		// Fail the first request, which is sent again.
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var ev WebhookEvent
+		assert.NoError(json.Unmarshal(body, &ev))
+		events <- ev
+	}))
+	defer hook.Close()
+
+	server := startTestServer(chunks.NewTestStore(), func(s *RemoteDatabaseServer) {
+		s.Webhooks = []Webhook{{URL: hook.URL, Secret: "s3cret", Datasets: regexp.MustCompile("^data$")}}
+	})
+	defer server.Stop()
+	db := NewRemoteDatabase(fmt.Sprintf("http://localhost:%d", server.Port()), "")
+	defer db.Close()
+
+	next := func() WebhookEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			assert.Fail("Timed out waiting for webhook")
+			return WebhookEvent{}
+		}
+	}
+
+	ds, err := db.CommitValue(db.GetDataset("data"), types.NewMap(types.String("a"), types.Number(1)))
+	assert.NoError(err)
+	first := ds.Head().Hash().String()
+	ev := next()
+	assert.Equal("data", ev.Dataset)
+	assert.Empty(ev.OldHead)
+	assert.Equal(first, ev.NewHead)
+	assert.Equal(WebhookDiff{Added: 1}, *ev.Diff)
+
+	// Datasets the webhook doesn't match aren't sent.
+	_, err = db.CommitValue(db.GetDataset("other"), types.Number(1))
+	assert.NoError(err)
+
+	meta := types.NewStruct("Meta", types.StructData{"author": types.String("alice"), "n": types.Number(2)})
+	ds, err = db.Commit(ds, types.NewMap(types.String("a"), types.Number(2), types.String("b"), types.Number(3)), CommitOptions{Meta: meta})
+	assert.NoError(err)
+	ev = next()
+	assert.Equal("data", ev.Dataset)
+	assert.Equal(first, ev.OldHead)
+	assert.Equal(ds.Head().Hash().String(), ev.NewHead)
+	assert.Equal(map[string]interface{}{"author": "alice", "n": float64(2)}, ev.Meta)
+	assert.Equal(WebhookDiff{Added: 1, Modified: 1}, *ev.Diff)
+
+	_, err = db.Delete(ds)
+	assert.NoError(err)
+	ev = next()
+	assert.Equal(WebhookEvent{Dataset: "data", OldHead: ds.Head().Hash().String()}, ev)
+}
+
+func TestWebhookDiff(t *testing.T) {
+	assert := assert.New(t)
+	wd := WebhookDiff{}
+	wd.add(types.NewList(types.Number(1), types.Number(2)), types.NewList(types.Number(1), types.Number(3), types.Number(4)))
+	assert.Equal(WebhookDiff{Added: 2, Removed: 1}, wd)
+
+	wd = WebhookDiff{}
+	wd.add(types.NewSet(types.Number(1)), types.NewSet(types.Number(2)))
+	assert.Equal(WebhookDiff{Added: 1, Removed: 1}, wd)
+
+	wd = WebhookDiff{}
+	wd.add(types.Number(1), types.NewSet(types.Number(2)))
+	assert.Equal(WebhookDiff{Modified: 1}, wd)
+
+	wd = WebhookDiff{}
+	wd.add(nil, types.String("a"))
+	assert.Equal(WebhookDiff{Added: 1}, wd)
+}
@@ -0,0 +1,40 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// ChangeDiff sends the Differences between the values of a Change of a
+// datas.ChangeFeed to dChan, as Diff does with leftRight set. The value of the
+// first commit of a dataset is compared to the empty collection of its kind,
+// so each of its elements is added, or if it isn't a collection, it's added at
+// the root path.
+func ChangeDiff(c datas.Change, dChan chan<- Difference, stopChan chan struct{}) {
+	newValue, oldValue := c.NewValue(), c.OldValue()
+	if oldValue == nil {
+		oldValue = emptyCollection(newValue.Kind())
+	}
+	if oldValue == nil {
+		d := differ{diffChan: dChan, stopChan: stopChan}
+		d.sendDiff(Difference{ChangeType: types.DiffChangeAdded, NewValue: newValue})
+		return
+	}
+	Diff(oldValue, newValue, dChan, stopChan, true)
+}
+
+func emptyCollection(k types.NomsKind) types.Value {
+	switch k {
+	case types.ListKind:
+		return types.NewList()
+	case types.MapKind:
+		return types.NewMap()
+	case types.SetKind:
+		return types.NewSet()
+	}
+	return nil
+}
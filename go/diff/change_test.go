@@ -0,0 +1,80 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func changeDiffs(ds datas.Dataset) [][]Difference {
+	feed, err := datas.ChangeFeed(ds, hash.Hash{})
+	if err != nil {
+		panic(err)
+	}
+	all := [][]Difference{}
+	for c, ok := feed.Next(); ok; c, ok = feed.Next() {
+		dChan, stopChan := make(chan Difference), make(chan struct{})
+		go func() {
+			ChangeDiff(c, dChan, stopChan)
+			close(dChan)
+		}()
+		difs := []Difference{}
+		for dif := range dChan {
+			difs = append(difs, dif)
+		}
+		all = append(all, difs)
+	}
+	return all
+}
+
+func TestChangeDiff(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds := db.GetDataset("ds")
+
+	ds, err := db.CommitValue(ds, createMap("a", 1, "b", 2))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, createMap("a", 1, "b", 3))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, types.String("s"))
+	assert.NoError(err)
+
+	all := changeDiffs(ds)
+	assert.Len(all, 3)
+
+	// The first map is compared to an empty one.
+	assert.Len(all[0], 2)
+	assert.Equal(types.DiffChangeAdded, all[0][0].ChangeType)
+	assert.Equal(`["a"]`, all[0][0].Path.String())
+	assert.True(types.Number(1).Equals(all[0][0].NewValue))
+
+	assert.Len(all[1], 1)
+	assert.Equal(types.DiffChangeModified, all[1][0].ChangeType)
+	assert.Equal(`["b"]`, all[1][0].Path.String())
+
+	assert.Len(all[2], 1)
+	assert.Nil(all[2][0].Path)
+	assert.Equal(types.DiffChangeModified, all[2][0].ChangeType)
+	assert.True(types.String("s").Equals(all[2][0].NewValue))
+}
+
+func TestChangeDiffFirstPrimitive(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	all := changeDiffs(ds)
+	assert.Len(all, 1)
+	assert.Equal([]Difference{{ChangeType: types.DiffChangeAdded, NewValue: types.Number(1)}}, all[0])
+}
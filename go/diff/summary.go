@@ -21,21 +21,40 @@ func Summary(value1, value2 types.Value) {
 		value2 = value2.(types.Struct).Get(datas.ValueField)
 	}
 
-	var singular, plural string
+	singular, plural := summaryNouns(value1, value2)
+	acc := summarize(value1, value2, func(acc diffSummaryProgress) {
+		if status.WillPrint() {
+			formatStatus(acc, singular, plural)
+		}
+	})
+	formatStatus(acc, singular, plural)
+	status.Done()
+}
+
+// SummaryString returns the summary of the diff between two values which
+// Summary prints, without printing progress along the way.
+func SummaryString(value1, value2 types.Value) string {
+	singular, plural := summaryNouns(value1, value2)
+	return formatSummary(summarize(value1, value2, func(diffSummaryProgress) {}), singular, plural)
+}
+
+func summaryNouns(value1, value2 types.Value) (singular, plural string) {
 	if value1.Kind() == value2.Kind() {
 		switch value1.Kind() {
 		case types.StructKind:
-			singular = "field"
-			plural = "fields"
+			return "field", "fields"
 		case types.MapKind:
-			singular = "entry"
-			plural = "entries"
+			return "entry", "entries"
 		default:
-			singular = "value"
-			plural = "values"
+			return "value", "values"
 		}
 	}
+	return
+}
 
+// summarize diffs two values, calling progress with the changes accumulated
+// so far, and returns the total.
+func summarize(value1, value2 types.Value, progress func(acc diffSummaryProgress)) diffSummaryProgress {
 	ch := make(chan diffSummaryProgress)
 	go func() {
 		diffSummary(ch, value1, value2)
@@ -49,12 +68,9 @@ func Summary(value1, value2 types.Value) {
 		acc.Changes += p.Changes
 		acc.NewSize += p.NewSize
 		acc.OldSize += p.OldSize
-		if status.WillPrint() {
-			formatStatus(acc, singular, plural)
-		}
+		progress(acc)
 	}
-	formatStatus(acc, singular, plural)
-	status.Done()
+	return acc
 }
 
 type diffSummaryProgress struct {
@@ -152,6 +168,10 @@ func reportChanges(ch chan<- diffSummaryProgress, changeChan chan types.ValueCha
 }
 
 func formatStatus(acc diffSummaryProgress, singular, plural string) {
+	status.Printf("%s", formatSummary(acc, singular, plural))
+}
+
+func formatSummary(acc diffSummaryProgress, singular, plural string) string {
 	pluralize := func(singular, plural string, n uint64) string {
 		var noun string
 		if n != 1 {
@@ -169,5 +189,5 @@ func formatStatus(acc diffSummaryProgress, singular, plural string) {
 	oldValues := pluralize(singular, plural, acc.OldSize)
 	newValues := pluralize(singular, plural, acc.NewSize)
 
-	status.Printf("%s (%.2f%%), %s (%.2f%%), %s (%.2f%%), (%s vs %s)", insertions, (float64(100*acc.Adds) / float64(acc.OldSize)), deletions, (float64(100*acc.Removes) / float64(acc.OldSize)), changes, (float64(100*acc.Changes) / float64(acc.OldSize)), oldValues, newValues)
+	return fmt.Sprintf("%s (%.2f%%), %s (%.2f%%), %s (%.2f%%), (%s vs %s)", insertions, (float64(100*acc.Adds) / float64(acc.OldSize)), deletions, (float64(100*acc.Removes) / float64(acc.OldSize)), changes, (float64(100*acc.Changes) / float64(acc.OldSize)), oldValues, newValues)
 }
@@ -0,0 +1,74 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hash
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	bloomBitsPerHash = 10
+	bloomProbes      = 7
+)
+
+// BloomFilter is a compact set of Hashes, which can tell for sure that a Hash
+// isn't in it, but only that a Hash may be: about 1% of the Hashes which
+// weren't inserted into a filter sized for as many as were are reported as
+// maybe in it. As Hashes are uniformly distributed, they're probed for by
+// their bytes, rather than by hashing them again.
+type BloomFilter struct {
+	bits   []byte
+	probes uint8
+}
+
+// NewBloomFilter returns an empty filter sized for n Hashes.
+func NewBloomFilter(n int) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	return &BloomFilter{make([]byte, (n*bloomBitsPerHash+7)/8), bloomProbes}
+}
+
+// BloomFilterFromBytes decodes the filter f.Bytes() returned.
+func BloomFilterFromBytes(b []byte) (*BloomFilter, error) {
+	if len(b) < 2 || b[0] == 0 {
+		return nil, errors.New("Invalid bloom filter")
+	}
+	return &BloomFilter{b[1:], b[0]}, nil
+}
+
+// Bytes returns the encoding of f: the number of probes, followed by the bits.
+func (f *BloomFilter) Bytes() []byte {
+	return append([]byte{f.probes}, f.bits...)
+}
+
+// Insert adds h to f.
+func (f *BloomFilter) Insert(h Hash) {
+	f.probe(h, func(i uint64) bool {
+		f.bits[i/8] |= 1 << (i % 8)
+		return true
+	})
+}
+
+// MayHave returns false if h definitely isn't in f.
+func (f *BloomFilter) MayHave(h Hash) bool {
+	return f.probe(h, func(i uint64) bool {
+		return f.bits[i/8]&(1<<(i%8)) != 0
+	})
+}
+
+// probe calls cb with the index of each bit h is probed for, until it returns
+// false, and returns whether it returned true for all of them.
+func (f *BloomFilter) probe(h Hash, cb func(i uint64) bool) bool {
+	n := uint64(len(f.bits)) * 8
+	a, b := binary.BigEndian.Uint64(h[0:8]), binary.BigEndian.Uint64(h[8:16])|1
+	for i := uint64(0); i < uint64(f.probes); i++ {
+		if !cb((a + i*b) % n) {
+			return false
+		}
+	}
+	return true
+}
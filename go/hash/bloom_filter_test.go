@@ -0,0 +1,39 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hash
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestBloomFilter(t *testing.T) {
+	assert := assert.New(t)
+	hs := hashes(20000)
+	f := NewBloomFilter(10000)
+	for _, h := range hs[:10000] {
+		f.Insert(h)
+	}
+
+	// Hashes which were inserted are always found, and few others are.
+	for _, h := range hs[:10000] {
+		assert.True(f.MayHave(h))
+	}
+	falsePositives := 0
+	for _, h := range hs[10000:] {
+		if f.MayHave(h) {
+			falsePositives++
+		}
+	}
+	assert.True(falsePositives < 300, "%d false positives", falsePositives)
+
+	decoded, err := BloomFilterFromBytes(f.Bytes())
+	assert.NoError(err)
+	assert.Equal(f, decoded)
+	_, err = BloomFilterFromBytes(nil)
+	assert.Error(err)
+	assert.False(NewBloomFilter(0).MayHave(hs[0]))
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hash
+
+import (
+	"crypto/sha512"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/attic-labs/noms/go/d"
+	"golang.org/x/crypto/sha3"
+)
+
+// DefaultFuncName is the name of the hash function Noms uses unless another
+// one is chosen with SetFunc: the first ByteLen bytes of sha-512.
+const DefaultFuncName = "sha512"
+
+// Func is a hash function which Hashes can be computed with. Sum returns the
+// first ByteLen bytes of the digest of data, and shouldn't allocate, since Of
+// is called for every chunk read or written.
+type Func struct {
+	Name string
+	Sum  func(data []byte) Hash
+}
+
+var (
+	funcsMu sync.RWMutex
+	funcs   = map[string]Func{}
+	// current holds the Func of Of, which is loaded without locking or
+	// allocating.
+	current atomic.Value
+)
+
+func init() {
+	RegisterFunc(Func{DefaultFuncName, func(data []byte) (h Hash) {
+		r := sha512.Sum512(data)
+		copy(h[:], r[:ByteLen])
+		return
+	}})
+	RegisterFunc(Func{"sha3", func(data []byte) (h Hash) {
+		r := sha3.Sum512(data)
+		copy(h[:], r[:ByteLen])
+		return
+	}})
+	current.Store(funcs[DefaultFuncName])
+}
+
+// RegisterFunc makes f available to LookupFunc and SetFunc by its name. It
+// panics if another function was registered with the same name.
+func RegisterFunc(f Func) {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	d.PanicIfTrue(f.Name == "" || f.Sum == nil)
+	if _, ok := funcs[f.Name]; ok {
+		d.Panic("Hash function %s is already registered", f.Name)
+	}
+	funcs[f.Name] = f
+}
+
+// LookupFunc returns the registered hash function named name.
+func LookupFunc(name string) (Func, bool) {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	f, ok := funcs[name]
+	return f, ok
+}
+
+// FuncNames returns the names of the registered hash functions, sorted.
+func FuncNames() []string {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetFunc makes the registered hash function named name the one Of computes
+// Hashes with. The hash function is part of the data version of a database,
+// so it must be chosen before any values are hashed, and every database the
+// process opens must have been created with it. Hashes are ByteLen bytes
+// whichever function computes them.
+func SetFunc(name string) {
+	f, ok := LookupFunc(name)
+	if !ok {
+		d.Panic("Unknown hash function %s", name)
+	}
+	current.Store(f)
+}
+
+// CurrentFunc returns the hash function Of computes Hashes with.
+func CurrentFunc() Func {
+	return current.Load().(Func)
+}
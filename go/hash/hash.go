@@ -26,11 +26,14 @@
 // - Sorted hashes will be sorted textually, making it easy to scan for humans.
 //
 // In Noms, the hash function is a component of the serialization version, which is constant over the entire lifetime of a single database. So clients do not need to worry about encountering multiple hash functions in the same database.
+//
+// Databases can be created with another registered hash function, such as sha3, by calling SetFunc before any values are hashed. Their digests are truncated to ByteLen bytes as well, and the function's name is recorded in the data version of the database, so that a process using a different hash function can't open it. Since every function is truncated to the same size, choosing another one doesn't change the collision resistance of a database.
+//
+// TODO: Wider digests, e.g. 32 bytes of BLAKE3 or sha-512, to address the collision concerns of 20 byte hashes. ByteLen is part of the chunk encoding, the nbs table indexes and the keys of every ChunkStore, so this needs a NomsVersion of its own, and a migration of existing databases.
 package hash
 
 import (
 	"bytes"
-	"crypto/sha512"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -64,12 +67,9 @@ func (h Hash) String() string {
 	return encode(h[:])
 }
 
-// FromData computes a new Hash from data.
+// Of computes a new Hash of data with the current hash function.
 func Of(data []byte) Hash {
-	r := sha512.Sum512(data)
-	h := Hash{}
-	copy(h[:], r[:ByteLen])
-	return h
+	return CurrentFunc().Sum(data)
 }
 
 // FromSlice creates a new Hash backed by data, ensuring that data is an acceptable length.
@@ -110,30 +110,3 @@ func (h Hash) Greater(other Hash) bool {
 	// TODO: Remove this
 	return bytes.Compare(h[:], other[:]) > 0
 }
-
-// HashSet is a set of Hashes.
-type HashSet map[Hash]struct{}
-
-func NewHashSet(hashes ...Hash) HashSet {
-	out := HashSet{}
-	for _, h := range hashes {
-		out.Insert(h)
-	}
-	return out
-}
-
-// Insert adds a Hash to the set.
-func (hs HashSet) Insert(hash Hash) {
-	hs[hash] = struct{}{}
-}
-
-// Has returns true if the HashSet contains hash.
-func (hs HashSet) Has(hash Hash) (has bool) {
-	_, has = hs[hash]
-	return
-}
-
-// Remove removes hash from the HashSet.
-func (hs HashSet) Remove(hash Hash) {
-	delete(hs, hash)
-}
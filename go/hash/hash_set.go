@@ -0,0 +1,212 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hash
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// HashSet is a set of Hashes.
+type HashSet map[Hash]struct{}
+
+func NewHashSet(hashes ...Hash) HashSet {
+	out := HashSet{}
+	for _, h := range hashes {
+		out.Insert(h)
+	}
+	return out
+}
+
+// Insert adds a Hash to the set.
+func (hs HashSet) Insert(hash Hash) {
+	hs[hash] = struct{}{}
+}
+
+// Has returns true if the HashSet contains hash.
+func (hs HashSet) Has(hash Hash) (has bool) {
+	_, has = hs[hash]
+	return
+}
+
+// Remove removes hash from the HashSet.
+func (hs HashSet) Remove(hash Hash) {
+	delete(hs, hash)
+}
+
+// InsertAll adds hashes to the set.
+func (hs HashSet) InsertAll(hashes ...Hash) {
+	for _, h := range hashes {
+		hs[h] = struct{}{}
+	}
+}
+
+// RemoveAll removes hashes from the set.
+func (hs HashSet) RemoveAll(hashes ...Hash) {
+	for _, h := range hashes {
+		delete(hs, h)
+	}
+}
+
+// HasAll returns true if the HashSet contains all of hashes.
+func (hs HashSet) HasAll(hashes ...Hash) bool {
+	for _, h := range hashes {
+		if _, ok := hs[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Missing returns a new HashSet of the hashes which the HashSet doesn't
+// contain.
+func (hs HashSet) Missing(hashes ...Hash) HashSet {
+	missing := HashSet{}
+	for _, h := range hashes {
+		if _, ok := hs[h]; !ok {
+			missing[h] = struct{}{}
+		}
+	}
+	return missing
+}
+
+// Copy returns a new HashSet with the same hashes.
+func (hs HashSet) Copy() HashSet {
+	cp := make(HashSet, len(hs))
+	for h := range hs {
+		cp[h] = struct{}{}
+	}
+	return cp
+}
+
+// Equals returns true if hs and other contain the same hashes.
+func (hs HashSet) Equals(other HashSet) bool {
+	if len(hs) != len(other) {
+		return false
+	}
+	for h := range hs {
+		if _, ok := other[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns a new HashSet of the hashes in either hs or other.
+func (hs HashSet) Union(other HashSet) HashSet {
+	union := make(HashSet, len(hs)+len(other))
+	for h := range hs {
+		union[h] = struct{}{}
+	}
+	for h := range other {
+		union[h] = struct{}{}
+	}
+	return union
+}
+
+// Intersection returns a new HashSet of the hashes in both hs and other.
+func (hs HashSet) Intersection(other HashSet) HashSet {
+	if len(other) < len(hs) {
+		hs, other = other, hs
+	}
+	intersection := HashSet{}
+	for h := range hs {
+		if _, ok := other[h]; ok {
+			intersection[h] = struct{}{}
+		}
+	}
+	return intersection
+}
+
+// Difference returns a new HashSet of the hashes in hs which aren't in other.
+func (hs HashSet) Difference(other HashSet) HashSet {
+	difference := HashSet{}
+	for h := range hs {
+		if _, ok := other[h]; !ok {
+			difference[h] = struct{}{}
+		}
+	}
+	return difference
+}
+
+// Sorted returns the hashes of the set in ascending order.
+func (hs HashSet) Sorted() HashSlice {
+	sorted := make(HashSlice, 0, len(hs))
+	for h := range hs {
+		sorted = append(sorted, h)
+	}
+	sort.Sort(sorted)
+	return sorted
+}
+
+// IterSorted calls cb with each hash of the set in ascending order, until cb
+// returns true.
+func (hs HashSet) IterSorted(cb func(h Hash) (stop bool)) {
+	for _, h := range hs.Sorted() {
+		if cb(h) {
+			return
+		}
+	}
+}
+
+// ErrInvalidHashSet is returned by UnmarshalBinary if the data isn't the
+// serialized form of a HashSet.
+var ErrInvalidHashSet = errors.New("invalid serialized HashSet")
+
+// MarshalBinary returns the compact serialized form of hs: the number of
+// hashes as a uvarint, followed by the hashes in ascending order, each as the
+// number of leading bytes it shares with the previous hash, in one byte, and
+// the rest of its bytes.
+func (hs HashSet) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(hs)*(ByteLen+1))
+	buf = buf[:binary.PutUvarint(buf, uint64(len(hs)))]
+	prev := Hash{}
+	for i, h := range hs.Sorted() {
+		shared := 0
+		if i > 0 {
+			for shared < ByteLen-1 && h[shared] == prev[shared] {
+				shared++
+			}
+		}
+		buf = append(buf, byte(shared))
+		buf = append(buf, h[shared:]...)
+		prev = h
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the contents of hs with the hashes of data, the
+// serialized form returned by MarshalBinary.
+func (hs *HashSet) UnmarshalBinary(data []byte) error {
+	n, l := binary.Uvarint(data)
+	if l <= 0 || n > uint64(len(data)) {
+		return ErrInvalidHashSet
+	}
+	data = data[l:]
+	set := make(HashSet, n)
+	prev := Hash{}
+	for i := uint64(0); i < n; i++ {
+		if len(data) == 0 {
+			return ErrInvalidHashSet
+		}
+		shared := int(data[0])
+		if shared >= ByteLen || len(data) < 1+ByteLen-shared {
+			return ErrInvalidHashSet
+		}
+		h := prev
+		copy(h[shared:], data[1:1+ByteLen-shared])
+		if i > 0 && !prev.Less(h) {
+			return ErrInvalidHashSet
+		}
+		set[h] = struct{}{}
+		data, prev = data[1+ByteLen-shared:], h
+	}
+	if len(data) != 0 {
+		return ErrInvalidHashSet
+	}
+	*hs = set
+	return nil
+}
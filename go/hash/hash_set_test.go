@@ -0,0 +1,97 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hash
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func hashes(n int) HashSlice {
+	hs := make(HashSlice, n)
+	for i := range hs {
+		hs[i] = Of([]byte(fmt.Sprintf("%d", i)))
+	}
+	return hs
+}
+
+func TestHashSetBatch(t *testing.T) {
+	assert := assert.New(t)
+	h := hashes(4)
+
+	hs := NewHashSet()
+	hs.InsertAll(h[0], h[1], h[2])
+	assert.Len(hs, 3)
+	assert.True(hs.HasAll(h[0], h[2]))
+	assert.False(hs.HasAll(h[0], h[3]))
+	assert.True(hs.HasAll())
+	assert.Equal(NewHashSet(h[3]), hs.Missing(h[0], h[3]))
+
+	hs.RemoveAll(h[0], h[3])
+	assert.Equal(NewHashSet(h[1], h[2]), hs)
+}
+
+func TestHashSetAlgebra(t *testing.T) {
+	assert := assert.New(t)
+	h := hashes(4)
+	a, b := NewHashSet(h[0], h[1], h[2]), NewHashSet(h[1], h[2], h[3])
+
+	assert.True(a.Union(b).Equals(NewHashSet(h...)))
+	assert.True(a.Intersection(b).Equals(NewHashSet(h[1], h[2])))
+	assert.True(b.Intersection(NewHashSet(h[3])).Equals(NewHashSet(h[3])))
+	assert.True(a.Difference(b).Equals(NewHashSet(h[0])))
+	assert.True(b.Difference(a).Equals(NewHashSet(h[3])))
+	assert.False(a.Equals(b))
+	assert.False(a.Equals(NewHashSet(h[0])))
+
+	c := a.Copy()
+	c.Insert(h[3])
+	assert.Len(a, 3)
+	assert.Len(c, 4)
+}
+
+func TestHashSetSorted(t *testing.T) {
+	assert := assert.New(t)
+	hs := NewHashSet(hashes(10)...)
+	sorted := hs.Sorted()
+	assert.Len(sorted, 10)
+	for i := 1; i < len(sorted); i++ {
+		assert.True(sorted[i-1].Less(sorted[i]))
+	}
+
+	iterated := HashSlice{}
+	hs.IterSorted(func(h Hash) bool {
+		iterated = append(iterated, h)
+		return len(iterated) == 3
+	})
+	assert.True(sorted[:3].Equals(iterated))
+}
+
+func TestHashSetMarshalBinary(t *testing.T) {
+	assert := assert.New(t)
+	for _, n := range []int{0, 1, 2, 1000} {
+		hs := NewHashSet(hashes(n)...)
+		data, err := hs.MarshalBinary()
+		assert.NoError(err)
+		if n == 1000 {
+			// Sorted hashes share leading bytes.
+			assert.True(len(data) < n*(ByteLen+1))
+		}
+
+		var out HashSet
+		assert.NoError(out.UnmarshalBinary(data))
+		assert.True(hs.Equals(out), "%d hashes", n)
+	}
+
+	data, _ := NewHashSet(hashes(2)...).MarshalBinary()
+	var out HashSet
+	assert.Equal(ErrInvalidHashSet, out.UnmarshalBinary(nil))
+	assert.Equal(ErrInvalidHashSet, out.UnmarshalBinary(data[:len(data)-1]))
+	assert.Equal(ErrInvalidHashSet, out.UnmarshalBinary(append(data, 0)))
+	data[1+ByteLen+1] = ByteLen
+	assert.Equal(ErrInvalidHashSet, out.UnmarshalBinary(data))
+}
@@ -128,3 +128,30 @@ func TestGreater(t *testing.T) {
 	assert.False(r0.Greater(r2))
 	assert.True(r2.Greater(r0))
 }
+
+func TestFuncs(t *testing.T) {
+	assert := assert.New(t)
+	data := []byte("abc")
+	sha := Of(data)
+	assert.Equal(DefaultFuncName, CurrentFunc().Name)
+	assert.Equal([]string{"sha3", "sha512"}, FuncNames())
+
+	SetFunc("sha3")
+	defer SetFunc(DefaultFuncName)
+	assert.Equal("sha3", CurrentFunc().Name)
+	assert.NotEqual(sha, Of(data))
+	assert.Equal(Of(data), CurrentFunc().Sum(data))
+
+	SetFunc(DefaultFuncName)
+	assert.Equal(sha, Of(data))
+
+	assert.Panics(func() { SetFunc("md5") })
+	_, ok := LookupFunc("md5")
+	assert.False(ok)
+	assert.Panics(func() { RegisterFunc(Func{"sha3", CurrentFunc().Sum}) })
+}
+
+func TestOfDoesntAllocate(t *testing.T) {
+	data := []byte("abc")
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { Of(data) }))
+}
@@ -7,6 +7,7 @@ package marshal
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"sync"
 
 	"github.com/attic-labs/noms/go/types"
@@ -27,10 +28,24 @@ import (
 // fields also support the "original" tag which causes the Go field to receive
 // the entire original unmarshaled Noms struct.
 //
+// A pointer field is optional. If the Noms struct is missing the field, the Go
+// field is set to nil, otherwise it is set to point to the decoded value.
+//
+// A field tagged with `noms:"count,default=0"` may be missing from the Noms
+// struct, in which case the Go field is set to the default value. Defaults
+// are supported for bool, number and string fields, and cannot contain a
+// comma. A field tagged with "required" must be present in the Noms struct
+// even if it is also tagged with "omitempty", which allows empty values to be
+// omitted by Marshal while still being validated by Unmarshal.
+//
 // To unmarshal a Noms list or set into a slice, Unmarshal resets the slice
 // length to zero and then appends each element to the slice. If the Go slice
 // was nil a new slice is created when an element is added.
 //
+// To unmarshal a Noms map into a slice of structs, the field must be tagged
+// with `noms:",mapkey=<field>"`. Unmarshal appends the map values to the slice
+// in key order.
+//
 // To unmarshal a Noms list into a Go array, Unmarshal decodes Noms list
 // elements into corresponding Go array elements.
 //
@@ -42,6 +57,12 @@ import (
 // and it must have a type of map[<value-type>]struct{}. Unmarshal decodes into
 // Go map keys corresponding to the set values and assigns each key a value of struct{}{}.
 //
+// To unmarshal into a json.RawMessage, or a string or []byte field tagged with
+// `noms:",json"`, Unmarshal renders the Noms value as JSON. Structs and maps
+// with string keys become JSON objects, lists and sets become JSON arrays.
+// Such a field is optional, as Marshal omits it if it holds no JSON: if the
+// Noms struct is missing it, the Go field is set to its zero value.
+//
 // When unmarshalling onto interface{} the following rules are used:
 //  - types.Bool -> bool
 //  - types.List -> []T, where T is determined recursively using the same rules.
@@ -168,6 +189,10 @@ func typeDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 		return marshalerDecoder(t)
 	}
 
+	if shouldEncodeAsJSON(t, tags) {
+		return jsonDecoder
+	}
+
 	switch t.Kind() {
 	case reflect.Bool:
 		return boolDecoder
@@ -180,10 +205,19 @@ func typeDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 	case reflect.String:
 		return stringDecoder
 	case reflect.Struct:
+		if isProtoTimestamp(t) {
+			return protoTimestampDecoder
+		}
+		if isProtoDuration(t) {
+			return protoDurationDecoder
+		}
 		return structDecoder(t)
 	case reflect.Interface:
 		return interfaceDecoder(t)
 	case reflect.Slice:
+		if tags.mapKey != "" {
+			return keyedMapDecoder(t, tags.mapKey)
+		}
 		return sliceDecoder(t)
 	case reflect.Array:
 		return arrayDecoder(t)
@@ -278,11 +312,14 @@ func (c *decoderCacheT) set(t reflect.Type, d decoderFunc) {
 }
 
 type decField struct {
-	name      string
-	decoder   decoderFunc
-	index     int
-	omitEmpty bool
-	original  bool
+	name         string
+	decoder      decoderFunc
+	index        int
+	omitEmpty    bool
+	original     bool
+	required     bool
+	pointer      bool
+	defaultValue reflect.Value
 }
 
 func structDecoder(t reflect.Type) decoderFunc {
@@ -296,22 +333,43 @@ func structDecoder(t reflect.Type) decoderFunc {
 	}
 
 	fields := make([]decField, 0, t.NumField())
+	isProto := isProtoMessage(t)
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
-		tags := getTags(f)
+		var tags nomsTags
+		if isProto {
+			tags = protoTags(f, getTags(f))
+		} else {
+			tags = getTags(f)
+		}
 		if tags.skip {
 			continue
 		}
 
 		validateField(f, t)
 
-		fields = append(fields, decField{
+		df := decField{
 			name:      tags.name,
-			decoder:   typeDecoder(f.Type, tags),
 			index:     i,
 			omitEmpty: tags.omitEmpty,
 			original:  tags.original,
-		})
+			required:  tags.required,
+		}
+		if tags.oneof {
+			df.decoder = oneofDecoder(t, f)
+			df.pointer = true
+		} else if isOptionalPtr(f.Type) {
+			df.decoder = pointerDecoder(f.Type, tags)
+			df.pointer = true
+		} else {
+			df.decoder = typeDecoder(f.Type, tags)
+			// Marshal omits fields holding no JSON.
+			df.pointer = shouldEncodeAsJSON(f.Type, tags)
+		}
+		if tags.hasDefault {
+			df.defaultValue = parseDefault(f, tags.defaultValue)
+		}
+		fields = append(fields, df)
 	}
 
 	d = func(v types.Value, rv reflect.Value) {
@@ -332,6 +390,12 @@ func structDecoder(t reflect.Type) decoderFunc {
 			fv, ok := s.MaybeGet(f.name)
 			if ok {
 				f.decoder(fv, sf)
+			} else if f.required {
+				panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", missing required field \"" + f.name + "\""})
+			} else if f.defaultValue.IsValid() {
+				sf.Set(f.defaultValue)
+			} else if f.pointer {
+				sf.Set(reflect.Zero(sf.Type()))
 			} else if !f.omitEmpty {
 				panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", missing field \"" + f.name + "\""})
 			}
@@ -342,6 +406,55 @@ func structDecoder(t reflect.Type) decoderFunc {
 	return d
 }
 
+// pointerDecoder decodes into a newly allocated value and stores a pointer to
+// it. Like pointerEncoder, the element decoder is resolved lazily to allow
+// self referential structs.
+func pointerDecoder(t reflect.Type, tags nomsTags) decoderFunc {
+	var elemDecoder decoderFunc
+	var once sync.Once
+	return func(v types.Value, rv reflect.Value) {
+		once.Do(func() {
+			elemDecoder = typeDecoder(t.Elem(), tags)
+		})
+		ptr := reflect.New(t.Elem())
+		elemDecoder(v, ptr.Elem())
+		rv.Set(ptr)
+	}
+}
+
+// parseDefault parses the value of a `default=<value>` tag into a value of
+// the field's Go type.
+func parseDefault(f reflect.StructField, s string) reflect.Value {
+	rv := reflect.New(f.Type).Elem()
+	var err error
+	switch f.Type.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		rv.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		var fl float64
+		fl, err = strconv.ParseFloat(s, f.Type.Bits())
+		rv.SetFloat(fl)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, f.Type.Bits())
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(s, 10, f.Type.Bits())
+		rv.SetUint(u)
+	case reflect.String:
+		rv.SetString(s)
+	default:
+		panic(&InvalidTagError{fmt.Sprintf("Default values are not supported for field %s of type %s", f.Name, f.Type)})
+	}
+	if err != nil {
+		panic(&InvalidTagError{fmt.Sprintf("Invalid default value %q for field %s of type %s", s, f.Name, f.Type)})
+	}
+	return rv
+}
+
 func nomsValueDecoder(v types.Value, rv reflect.Value) {
 	if !reflect.TypeOf(v).AssignableTo(rv.Type()) {
 		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
@@ -407,6 +520,38 @@ func sliceDecoder(t reflect.Type) decoderFunc {
 	return d
 }
 
+// keyedMapDecoder decodes the values of a Noms map into a slice of structs.
+// The keys are ignored since they are duplicated in the struct values.
+func keyedMapDecoder(t reflect.Type, key string) decoderFunc {
+	mapKeyField(t, key)
+
+	var decoder decoderFunc
+	var once sync.Once
+	return func(v types.Value, rv reflect.Value) {
+		once.Do(func() {
+			decoder = typeDecoder(t.Elem(), nomsTags{})
+		})
+
+		nomsMap, ok := v.(types.Map)
+		if !ok {
+			panic(&UnmarshalTypeMismatchError{v, t, `, field has "mapkey" tag`})
+		}
+
+		var slice reflect.Value
+		if rv.IsNil() {
+			slice = rv
+		} else {
+			slice = rv.Slice(0, 0)
+		}
+		nomsMap.IterAll(func(_, v types.Value) {
+			elemRv := reflect.New(t.Elem()).Elem()
+			decoder(v, elemRv)
+			slice = reflect.Append(slice, elemRv)
+		})
+		rv.Set(slice)
+	}
+}
+
 func arrayDecoder(t reflect.Type) decoderFunc {
 	d := decoderCache.get(t)
 	if d != nil {
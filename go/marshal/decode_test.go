@@ -269,12 +269,6 @@ func TestDecodeInvalidTypes(tt *testing.T) {
 
 	var c chan bool
 	t(&c, "chan bool")
-
-	type Nested struct {
-		X *bool
-	}
-	var n Nested
-	t(&n, "*bool")
 }
 
 func TestDecodeOverflows(tt *testing.T) {
@@ -851,6 +845,144 @@ func TestDecodeOmitEmpty(t *testing.T) {
 	assert.Equal(expected, actual)
 }
 
+func TestDecodeDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Count   int     `noms:"count,default=10"`
+		Ratio   float64 `noms:",default=0.5"`
+		Enabled bool    `noms:",default=true"`
+		Name    string  `noms:",default=anon"`
+		Size    uint8   `noms:",default=3"`
+	}
+
+	var s S
+	err := Unmarshal(types.NewStruct("S", types.StructData{
+		"count": types.Number(42),
+	}), &s)
+	assert.NoError(err)
+	assert.Equal(S{42, 0.5, true, "anon", 3}, s)
+
+	type Bad struct {
+		N int `noms:",default=abc"`
+	}
+	var b Bad
+	err = Unmarshal(types.NewStruct("Bad", types.StructData{}), &b)
+	assert.Error(err)
+	assert.Equal(`Invalid default value "abc" for field N of type int`, err.Error())
+
+	type Unsupported struct {
+		L []int `noms:",default=1"`
+	}
+	var u Unsupported
+	err = Unmarshal(types.NewStruct("Unsupported", types.StructData{}), &u)
+	assert.Error(err)
+	assert.Equal("Default values are not supported for field L of type []int", err.Error())
+}
+
+func TestDecodeRequired(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		ID   string `noms:"id,omitempty,required"`
+		Note string `noms:",omitempty"`
+	}
+
+	var s S
+	err := Unmarshal(types.NewStruct("S", types.StructData{
+		"id": types.String("x"),
+	}), &s)
+	assert.NoError(err)
+	assert.Equal(S{ID: "x"}, s)
+
+	assertDecodeErrorMessage(t, types.NewStruct("S", types.StructData{
+		"note": types.String("hi"),
+	}), &s, "Cannot unmarshal struct S {\n  note: String,\n} into Go value of type marshal.S, missing required field \"id\"")
+
+	type Both struct {
+		N int `noms:",required,default=1"`
+	}
+	var b Both
+	err = Unmarshal(types.NewStruct("Both", types.StructData{}), &b)
+	assert.Error(err)
+	assert.Equal("Field n cannot be both required and have a default", err.Error())
+}
+
+func TestDecodePointerField(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Count *int
+		Name  *string
+	}
+
+	name := "stale"
+	s := S{Name: &name}
+	err := Unmarshal(types.NewStruct("S", types.StructData{
+		"count": types.Number(0),
+	}), &s)
+	assert.NoError(err)
+	assert.NotNil(s.Count)
+	assert.Equal(0, *s.Count)
+	assert.Nil(s.Name)
+
+	type Node struct {
+		Value int
+		Next  *Node
+	}
+	var n Node
+	err = Unmarshal(types.NewStruct("Node", types.StructData{
+		"value": types.Number(1),
+		"next": types.NewStruct("Node", types.StructData{
+			"value": types.Number(2),
+		}),
+	}), &n)
+	assert.NoError(err)
+	assert.Equal(Node{1, &Node{2, nil}}, n)
+}
+
+func TestDecodeMapKey(t *testing.T) {
+	assert := assert.New(t)
+
+	type Person struct {
+		ID   int
+		Name string
+	}
+	type People struct {
+		People []Person `noms:",mapkey=ID"`
+	}
+
+	in := People{[]Person{{1, "Alice"}, {2, "Bob"}}}
+	v, err := Marshal(in)
+	assert.NoError(err)
+
+	var out People
+	err = Unmarshal(v, &out)
+	assert.NoError(err)
+	assert.Equal(in, out)
+
+	assertDecodeErrorMessage(t, types.NewStruct("People", types.StructData{
+		"people": types.NewList(),
+	}), &out, `Cannot unmarshal List<> into Go value of type []marshal.Person, field has "mapkey" tag`)
+}
+
+func TestDecodeSetRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Tags []string `noms:",set"`
+	}
+
+	v, err := Marshal(S{[]string{"c", "a", "b"}})
+	assert.NoError(err)
+	assert.Equal(types.SetKind, v.(types.Struct).Get("tags").Kind())
+
+	var out S
+	err = Unmarshal(v, &out)
+	assert.NoError(err)
+	assert.Equal(S{[]string{"a", "b", "c"}}, out)
+}
+
 func TestDecodeOriginal(t *testing.T) {
 	assert := assert.New(t)
 
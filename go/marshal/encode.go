@@ -15,6 +15,7 @@ import (
 	"sync"
 	"unicode"
 
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -35,9 +36,22 @@ import (
 // field is tagged with `noms:"set", it will be encoded as Noms types.Set
 // instead.
 //
+// Slices of structs tagged with `noms:",mapkey=ID"` are encoded as a Noms
+// types.Map where each struct is keyed by the value of its Go field ID. Two
+// structs with the same ID cause Marshal to return an error.
+//
 // Maps are encoded as Noms types.Map, or a types.Set if the value type is
 // struct{} and the field is tagged with `noms:"set"`.
 //
+// Values of type json.RawMessage, and string or []byte fields tagged with
+// `noms:",json"`, are parsed as JSON and encoded as the equivalent Noms value.
+// JSON objects become Noms structs and JSON arrays become Noms lists. A
+// struct field holding no JSON, e.g. a nil json.RawMessage, is omitted, as a
+// nil pointer is. Invalid JSON, JSON null, even within an array or object,
+// and JSON object keys which aren't valid Noms struct field names cause
+// Marshal to return an error, rather than encoding JSON which Unmarshal
+// couldn't render again as it was.
+//
 // Struct values are encoded as Noms structs (types.Struct). Each exported Go
 // struct field becomes a member of the Noms struct unless
 //   - The field's tag is "-"
@@ -47,6 +61,13 @@ import (
 //     combined with the corresponding support for "original" in Unmarshal(),
 //     this allows one to find and modify any values of a known subtype.
 //
+// Go structs generated from protobuf messages are detected by their
+// "protobuf" field tags. Their generated XXX_ fields are skipped, fields are
+// named after the protobuf JSON names, oneof fields are encoded as optional
+// structs with a single field for the member that is set, and the well-known
+// Timestamp and Duration messages are encoded as a DateTime struct and a
+// Number of seconds respectively.
+//
 // Additionally, user-defined types can implement the Marshaler interface to
 // provide a custom encoding.
 //
@@ -75,6 +96,9 @@ import (
 //   //  omitted from the object if its value is empty, as defined above.
 //   Field int `noms:",omitempty"
 //
+// The "default" and "required" tag options only affect Unmarshal and are
+// ignored by Marshal.
+//
 // The name of the Noms struct is the name of the Go struct where the first
 // character is changed to upper case.
 //
@@ -88,8 +112,11 @@ import (
 //
 // When marshalling interface{} the dynamic type is used.
 //
-// Go pointers, complex, function are not supported. Attempting to encode such a
-// value causes Marshal to return an UnsupportedTypeError.
+// Pointer struct fields are encoded as optional Noms struct fields. A nil
+// pointer omits the field, otherwise the value pointed to is encoded.
+//
+// Other Go pointers, complex, function are not supported. Attempting to
+// encode such a value causes Marshal to return an UnsupportedTypeError.
 //
 func Marshal(v interface{}) (nomsValue types.Value, err error) {
 	defer func() {
@@ -165,6 +192,18 @@ type nomsTags struct {
 	original  bool
 	set       bool
 	skip      bool
+	json      bool
+	required  bool
+	oneof     bool
+
+	// mapKey is the name of the Go struct field to use as the key when a
+	// slice of structs is encoded as a Noms map, from `mapkey=<field>`.
+	mapKey string
+
+	// hasDefault is set if the field has a `default=<value>` tag, in which
+	// case defaultValue holds the unparsed value.
+	hasDefault   bool
+	defaultValue string
 }
 
 var nomsValueInterface = reflect.TypeOf((*types.Value)(nil)).Elem()
@@ -215,6 +254,10 @@ func typeEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsT
 		return marshalerEncoder(t)
 	}
 
+	if shouldEncodeAsJSON(t, tags) {
+		return jsonEncoder
+	}
+
 	switch t.Kind() {
 	case reflect.Bool:
 		return boolEncoder
@@ -227,8 +270,17 @@ func typeEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsT
 	case reflect.String:
 		return stringEncoder
 	case reflect.Struct:
+		if isProtoTimestamp(t) {
+			return protoTimestampEncoder
+		}
+		if isProtoDuration(t) {
+			return protoDurationEncoder
+		}
 		return structEncoder(t, seenStructs)
 	case reflect.Slice, reflect.Array:
+		if tags.mapKey != "" {
+			return keyedMapEncoder(t, seenStructs, tags.mapKey)
+		}
 		if shouldEncodeAsSet(t, tags) {
 			return setFromListEncoder(t, seenStructs)
 		}
@@ -331,7 +383,7 @@ func isEmptyValue(v reflect.Value) bool {
 	case reflect.Struct:
 		z := reflect.Zero(v.Type())
 		return z.Interface() == v.Interface()
-	case reflect.Interface:
+	case reflect.Interface, reflect.Ptr:
 		return v.IsNil()
 	}
 	return false
@@ -405,10 +457,30 @@ func getTags(f reflect.StructField) (tags nomsTags) {
 			tags.original = true
 		case "set":
 			tags.set = true
+		case "json":
+			tags.json = true
+		case "required":
+			tags.required = true
 		default:
+			if strings.HasPrefix(tag, "default=") {
+				tags.hasDefault = true
+				tags.defaultValue = tag[len("default="):]
+				continue
+			}
+			if strings.HasPrefix(tag, "mapkey=") {
+				tags.mapKey = tag[len("mapkey="):]
+				continue
+			}
 			panic(&InvalidTagError{"Unrecognized tag: " + tag})
 		}
 	}
+
+	if tags.required && tags.hasDefault {
+		panic(&InvalidTagError{"Field " + tags.name + " cannot be both required and have a default"})
+	}
+	if tags.set && tags.mapKey != "" {
+		panic(&InvalidTagError{"Field " + tags.name + " cannot have both the set and mapkey tags"})
+	}
 	return
 }
 
@@ -423,9 +495,15 @@ func validateField(f reflect.StructField, t reflect.Type) {
 
 func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, options encodeTypeOptions) (fields fieldSlice, structType *types.Type, originalFieldIndex []int) {
 	canComputeStructType := true
+	isProto := isProtoMessage(t)
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
-		tags := getTags(f)
+		var tags nomsTags
+		if isProto {
+			tags = protoTags(f, getTags(f))
+		} else {
+			tags = getTags(f)
+		}
 		if tags.skip {
 			continue
 		}
@@ -436,7 +514,24 @@ func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, options enc
 		}
 
 		validateField(f, t)
-		nt := encodeType(f.Type, seenStructs, tags, options)
+
+		var encoder encoderFunc
+		var nt *types.Type
+		if tags.oneof {
+			encoder = oneofEncoder(t, seenStructs)
+		} else if isOptionalPtr(f.Type) {
+			// Pointer fields are optional, a nil pointer omits the field.
+			tags.omitEmpty = true
+			nt = encodeType(f.Type.Elem(), seenStructs, tags, options)
+			encoder = pointerEncoder(f.Type, seenStructs, tags)
+		} else {
+			if shouldEncodeAsJSON(f.Type, tags) {
+				// Fields holding no JSON are omitted, as there's no Noms null.
+				tags.omitEmpty = true
+			}
+			nt = encodeType(f.Type, seenStructs, tags, options)
+			encoder = typeEncoder(f.Type, seenStructs, tags)
+		}
 		if nt == nil {
 			canComputeStructType = false
 		}
@@ -447,7 +542,7 @@ func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, options enc
 
 		fields = append(fields, field{
 			name:      tags.name,
-			encoder:   typeEncoder(f.Type, seenStructs, tags),
+			encoder:   encoder,
 			index:     i,
 			nomsType:  nt,
 			omitEmpty: tags.omitEmpty,
@@ -469,6 +564,27 @@ func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, options enc
 	return
 }
 
+// isOptionalPtr returns true if t is a pointer type that is used to represent
+// an optional struct field. Pointers implementing types.Value, like
+// *types.Type, are encoded as is.
+func isOptionalPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && !t.Implements(nomsValueInterface)
+}
+
+// pointerEncoder encodes the value a non nil pointer field points to. The
+// element encoder is resolved lazily so that a struct can refer to its own
+// type through a pointer.
+func pointerEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTags) encoderFunc {
+	var elemEncoder encoderFunc
+	var once sync.Once
+	return func(v reflect.Value) types.Value {
+		once.Do(func() {
+			elemEncoder = typeEncoder(t.Elem(), seenStructs, tags)
+		})
+		return elemEncoder(v.Elem())
+	}
+}
+
 func listEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
 	e := encoderCache.get(t)
 	if e != nil {
@@ -548,6 +664,50 @@ func setEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc
 	return e
 }
 
+// mapKeyField returns the field of the struct element type of t to use as the
+// key when encoding t with the mapkey tag.
+func mapKeyField(t reflect.Type, key string) reflect.StructField {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Struct {
+		panic(&UnsupportedTypeError{t, `Fields with the "mapkey" tag must be a slice of structs`})
+	}
+	f, ok := t.Elem().FieldByName(key)
+	if !ok || len(f.Index) != 1 {
+		panic(&InvalidTagError{"Invalid mapkey, " + t.Elem().String() + " has no field " + key})
+	}
+	validateField(f, t.Elem())
+	return f
+}
+
+// Encode map from a slice of structs, keyed by one of the struct fields
+func keyedMapEncoder(t reflect.Type, seenStructs map[string]reflect.Type, key string) encoderFunc {
+	kf := mapKeyField(t, key)
+
+	// The element encoder may refer back to this encoder so resolve the
+	// encoders lazily.
+	var keyEncoder encoderFunc
+	var elemEncoder encoderFunc
+	var once sync.Once
+	return func(v reflect.Value) types.Value {
+		once.Do(func() {
+			keyEncoder = typeEncoder(kf.Type, seenStructs, getTags(kf))
+			elemEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{})
+		})
+		kvs := make([]types.Value, 2*v.Len())
+		seen := hash.HashSet{}
+		for i := 0; i < v.Len(); i++ {
+			ev := v.Index(i)
+			k := keyEncoder(ev.Field(kf.Index[0]))
+			if seen.Has(k.Hash()) {
+				panic(&marshalNomsError{fmt.Errorf("Duplicate mapkey %s in %s", types.EncodedValue(k), t)})
+			}
+			seen.Insert(k.Hash())
+			kvs[2*i] = k
+			kvs[2*i+1] = elemEncoder(ev)
+		}
+		return types.NewMap(kvs...)
+	}
+}
+
 func mapEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
 	e := encoderCache.get(t)
 	if e != nil {
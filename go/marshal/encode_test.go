@@ -164,6 +164,42 @@ func TestInvalidTypes(t *testing.T) {
 	assertEncodeErrorMessage(t, &x, "Type is not supported, type: *int")
 }
 
+func TestEncodePointerField(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Count *int
+		Name  *string `noms:"n"`
+	}
+
+	zero := 0
+	v, err := Marshal(S{Count: &zero})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"count": types.Number(0),
+	}).Equals(v))
+
+	name := "x"
+	v, err = Marshal(S{Name: &name})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"n": types.String("x"),
+	}).Equals(v))
+
+	type Node struct {
+		Value int
+		Next  *Node
+	}
+	v, err = Marshal(Node{1, &Node{2, nil}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Node", types.StructData{
+		"value": types.Number(1),
+		"next": types.NewStruct("Node", types.StructData{
+			"value": types.Number(2),
+		}),
+	}).Equals(v))
+}
+
 func TestEncodeEmbeddedStruct(t *testing.T) {
 	type EmbeddedStruct struct{}
 	type TestStruct struct {
@@ -540,6 +576,52 @@ func TestEncodeInterface(t *testing.T) {
 	).Equals(v))
 }
 
+func TestEncodeMapKey(t *testing.T) {
+	assert := assert.New(t)
+
+	type Person struct {
+		ID   string `noms:"id"`
+		Name string
+	}
+	type People struct {
+		People []Person `noms:",mapkey=ID"`
+	}
+
+	v, err := Marshal(People{[]Person{{"b", "Bob"}, {"a", "Alice"}}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("People", types.StructData{
+		"people": types.NewMap(
+			types.String("a"), types.NewStruct("Person", types.StructData{
+				"id":   types.String("a"),
+				"name": types.String("Alice"),
+			}),
+			types.String("b"), types.NewStruct("Person", types.StructData{
+				"id":   types.String("b"),
+				"name": types.String("Bob"),
+			}),
+		),
+	}).Equals(v))
+
+	_, err = Marshal(People{[]Person{{"a", "Alice"}, {"a", "Ann"}}})
+	assert.Error(err)
+	assert.Equal(`Duplicate mapkey "a" in []marshal.Person`, err.Error())
+
+	type BadKey struct {
+		People []Person `noms:",mapkey=Age"`
+	}
+	assertEncodeErrorMessage(t, BadKey{}, "Invalid mapkey, marshal.Person has no field Age")
+
+	type NotStructs struct {
+		IDs []string `noms:",mapkey=ID"`
+	}
+	assertEncodeErrorMessage(t, NotStructs{}, `Fields with the "mapkey" tag must be a slice of structs, type: []string`)
+
+	type Both struct {
+		People []Person `noms:",set,mapkey=ID"`
+	}
+	assertEncodeErrorMessage(t, Both{}, "Field people cannot have both the set and mapkey tags")
+}
+
 func TestEncodeSet(t *testing.T) {
 	assert := assert.New(t)
 
@@ -95,6 +95,16 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 		return nil
 	}
 
+	if shouldEncodeAsJSON(t, tags) {
+		// The Noms type depends on the JSON being encoded.
+		if options.ReportErrors {
+			err := fmt.Errorf("Cannot marshal type %s, the Noms type of JSON depends on the value", t)
+			panic(&marshalNomsError{err})
+		}
+
+		return nil
+	}
+
 	if t.Implements(nomsValueInterface) {
 		if t == typeOfTypesType {
 			return types.TypeType
@@ -129,8 +139,23 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 	case reflect.String:
 		return types.StringType
 	case reflect.Struct:
+		if isProtoTimestamp(t) {
+			return protoDateTimeType
+		}
+		if isProtoDuration(t) {
+			return types.NumberType
+		}
 		return structEncodeType(t, seenStructs, options)
 	case reflect.Array, reflect.Slice:
+		if tags.mapKey != "" {
+			kf := mapKeyField(t, tags.mapKey)
+			keyType := encodeType(kf.Type, seenStructs, getTags(kf), options)
+			valueType := encodeType(t.Elem(), seenStructs, nomsTags{}, options)
+			if keyType != nil && valueType != nil {
+				return types.MakeMapType(keyType, valueType)
+			}
+			break
+		}
 		elemType := encodeType(t.Elem(), seenStructs, nomsTags{}, options)
 		if elemType == nil {
 			break
@@ -489,3 +489,37 @@ func TestTypeMarshalerErrors(t *testing.T) {
 	var m3 panicsMarshaler
 	assert.Panics(func() { MarshalType(m3) })
 }
+
+func TestMarshalTypePointerField(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Count *int
+		Name  string
+	}
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("S",
+		types.StructField{Name: "count", Type: types.NumberType, Optional: true},
+		types.StructField{Name: "name", Type: types.StringType, Optional: false},
+	).Equals(typ))
+}
+
+func TestMarshalTypeMapKey(t *testing.T) {
+	assert := assert.New(t)
+
+	type Person struct {
+		ID string
+	}
+	type People struct {
+		People []Person `noms:",mapkey=ID"`
+	}
+	typ, err := MarshalType(People{})
+	assert.NoError(err)
+	personType := types.MakeStructType("Person",
+		types.StructField{Name: "iD", Type: types.StringType},
+	)
+	assert.True(types.MakeStructType("People",
+		types.StructField{Name: "people", Type: types.MakeMapType(types.StringType, personType)},
+	).Equals(typ))
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// shouldEncodeAsJSON returns true if values of type t should be treated as
+// serialized JSON, either because t is json.RawMessage or because the field
+// is tagged with `noms:",json"`.
+func shouldEncodeAsJSON(t reflect.Type, tags nomsTags) bool {
+	if t == rawMessageType {
+		return true
+	}
+	if !tags.json {
+		return false
+	}
+	if !isJSONType(t) {
+		panic(&UnsupportedTypeError{t, `Fields with the "json" tag must be a string or []byte`})
+	}
+	return true
+}
+
+func isJSONType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// jsonEncoder parses the JSON held in a string or []byte and converts it to
+// the equivalent Noms value. JSON objects become Noms structs, arrays become
+// Noms lists. JSON which Unmarshal couldn't render again as it is, because it
+// has nulls or object keys which aren't Noms struct field names, is an error.
+func jsonEncoder(v reflect.Value) types.Value {
+	var data []byte
+	if v.Kind() == reflect.String {
+		data = []byte(v.String())
+	} else {
+		data = v.Bytes()
+	}
+
+	var o interface{}
+	if err := json.Unmarshal(data, &o); err != nil {
+		panic(&marshalNomsError{fmt.Errorf("Invalid JSON in %s: %s", v.Type(), err)})
+	}
+	return nomsValueFromJSON(o, v.Type())
+}
+
+// nomsValueFromJSON converts decoded JSON o, held in a value of type t, to
+// the equivalent Noms value.
+func nomsValueFromJSON(o interface{}, t reflect.Type) types.Value {
+	switch o := o.(type) {
+	case bool:
+		return types.Bool(o)
+	case float64:
+		return types.Number(o)
+	case string:
+		return types.String(o)
+	case []interface{}:
+		elems := make([]types.Value, len(o))
+		for i, v := range o {
+			elems[i] = nomsValueFromJSON(v, t)
+		}
+		return types.NewList(elems...)
+	case map[string]interface{}:
+		data := make(types.StructData, len(o))
+		for k, v := range o {
+			if !types.IsValidStructFieldName(k) {
+				panic(&marshalNomsError{fmt.Errorf("Cannot marshal JSON object key %q in %s, which is not a valid Noms struct field name", k, t)})
+			}
+			data[k] = nomsValueFromJSON(v, t)
+		}
+		return types.NewStruct("", data)
+	}
+	panic(&marshalNomsError{fmt.Errorf("Cannot marshal JSON null in %s", t)})
+}
+
+// jsonDecoder renders a Noms value as JSON and stores it in a string or
+// []byte.
+func jsonDecoder(v types.Value, rv reflect.Value) {
+	data, err := json.Marshal(jsonValueFromNoms(v, rv.Type()))
+	if err != nil {
+		panic(&unmarshalNomsError{err})
+	}
+	if rv.Kind() == reflect.String {
+		rv.SetString(string(data))
+	} else {
+		rv.SetBytes(data)
+	}
+}
+
+func jsonValueFromNoms(v types.Value, t reflect.Type) interface{} {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	case types.List:
+		arr := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value, _ uint64) {
+			arr = append(arr, jsonValueFromNoms(v, t))
+		})
+		return arr
+	case types.Set:
+		arr := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value) {
+			arr = append(arr, jsonValueFromNoms(v, t))
+		})
+		return arr
+	case types.Map:
+		obj := make(map[string]interface{}, v.Len())
+		v.IterAll(func(k, v types.Value) {
+			s, ok := k.(types.String)
+			if !ok {
+				panic(&UnmarshalTypeMismatchError{k, t, ", JSON object keys must be strings"})
+			}
+			obj[string(s)] = jsonValueFromNoms(v, t)
+		})
+		return obj
+	case types.Struct:
+		obj := make(map[string]interface{}, v.Len())
+		v.IterFields(func(name string, v types.Value) {
+			obj[name] = jsonValueFromNoms(v, t)
+		})
+		return obj
+	}
+	panic(&UnmarshalTypeMismatchError{v, t, ", value cannot be represented as JSON"})
+}
@@ -0,0 +1,190 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestEncodeJSONRawMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Raw json.RawMessage
+	}
+
+	v, err := Marshal(S{json.RawMessage(`{"a": [1, true, "x"], "b": {"c": {}}}`)})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"raw": types.NewStruct("", types.StructData{
+			"a": types.NewList(types.Number(1), types.Bool(true), types.String("x")),
+			"b": types.NewStruct("", types.StructData{"c": types.NewStruct("", types.StructData{})}),
+		}),
+	}).Equals(v))
+
+	v, err = Marshal(json.RawMessage(`42`))
+	assert.NoError(err)
+	assert.True(types.Number(42).Equals(v))
+}
+
+func TestEncodeJSONTag(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Str   string `noms:",json"`
+		Bytes []byte `noms:"b,json"`
+	}
+
+	v, err := Marshal(S{`["a", "b"]`, []byte(`{"x": 1}`)})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"str": types.NewList(types.String("a"), types.String("b")),
+		"b":   types.NewStruct("", types.StructData{"x": types.Number(1)}),
+	}).Equals(v))
+
+	type Bad struct {
+		N int `noms:",json"`
+	}
+	_, err = Marshal(Bad{})
+	assert.Error(err)
+	assert.Equal(`Fields with the "json" tag must be a string or []byte, type: int`, err.Error())
+}
+
+func TestEncodeJSONErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Raw json.RawMessage
+	}
+
+	_, err := Marshal(S{json.RawMessage(`{`)})
+	assert.Error(err)
+	assert.Contains(err.Error(), "Invalid JSON in "+rawMessageType.String())
+
+	_, err = Marshal(S{json.RawMessage(`null`)})
+	assert.Error(err)
+	assert.Equal("Cannot marshal JSON null in "+rawMessageType.String(), err.Error())
+
+	// Neither nulls within arrays and objects, nor keys which aren't Noms
+	// struct field names, could be unmarshaled again as they were.
+	_, err = Marshal(S{json.RawMessage(`[1, null]`)})
+	assert.Error(err)
+	assert.Equal("Cannot marshal JSON null in "+rawMessageType.String(), err.Error())
+	_, err = Marshal(S{json.RawMessage(`{"a": {"b": null}}`)})
+	assert.Error(err)
+	_, err = Marshal(S{json.RawMessage(`{"foo-bar": 1}`)})
+	assert.Error(err)
+	assert.Equal(`Cannot marshal JSON object key "foo-bar" in `+rawMessageType.String()+", which is not a valid Noms struct field name", err.Error())
+
+	type O struct {
+		Raw json.RawMessage `noms:",omitempty"`
+	}
+	v, err := Marshal(O{})
+	assert.NoError(err)
+	assert.True(types.NewStruct("O", types.StructData{}).Equals(v))
+}
+
+func TestEncodeJSONNil(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Raw json.RawMessage
+		Str string `noms:",json"`
+	}
+
+	// Fields holding no JSON are omitted, as nil pointers are.
+	v, err := Marshal(S{})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{}).Equals(v))
+
+	v, err = Marshal(S{Raw: json.RawMessage(`1`)})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{"raw": types.Number(1)}).Equals(v))
+
+	var out S
+	assert.NoError(Unmarshal(types.NewStruct("S", types.StructData{}), &out))
+	assert.Nil(out.Raw)
+	assert.Equal("", out.Str)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Raw   json.RawMessage
+		Str   string `noms:",json"`
+		Bytes []byte `noms:",json"`
+	}
+
+	var s S
+	err := Unmarshal(types.NewStruct("S", types.StructData{
+		"raw": types.NewStruct("Foo", types.StructData{
+			"a": types.NewList(types.Number(1), types.Bool(false)),
+		}),
+		"str":   types.NewMap(types.String("k"), types.String("v")),
+		"bytes": types.NewSet(types.Number(2), types.Number(1)),
+	}), &s)
+	assert.NoError(err)
+	assert.Equal(`{"a":[1,false]}`, string(s.Raw))
+	assert.Equal(`{"k":"v"}`, s.Str)
+	assert.Equal(`[1,2]`, string(s.Bytes))
+
+	var raw json.RawMessage
+	err = Unmarshal(types.String("hi"), &raw)
+	assert.NoError(err)
+	assert.Equal(`"hi"`, string(raw))
+
+	err = Unmarshal(types.NewMap(types.Number(1), types.Number(2)), &raw)
+	assert.Error(err)
+	assert.Equal("Cannot unmarshal Number into Go value of type "+rawMessageType.String()+", JSON object keys must be strings", err.Error())
+
+	err = Unmarshal(types.NewEmptyBlob(), &raw)
+	assert.Error(err)
+	assert.Equal("Cannot unmarshal Blob into Go value of type "+rawMessageType.String()+", value cannot be represented as JSON", err.Error())
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Doc string `noms:",json"`
+	}
+
+	for _, doc := range []string{
+		`{"list":[1,2,3],"name":"noms","ok":true}`,
+		`[{"a_b":[]},{"c1":{"d":"e"}},[1.5,"x"]]`,
+	} {
+		in := S{doc}
+		v, err := Marshal(in)
+		assert.NoError(err)
+
+		var out S
+		err = Unmarshal(v, &out)
+		assert.NoError(err)
+		assert.Equal(in, out)
+	}
+
+	// JSON which couldn't be rendered again as it is fails to marshal,
+	// rather than coming back changed.
+	for _, doc := range []string{`{"foo-bar":1}`, `[1,null]`, `{"a":null}`} {
+		_, err := Marshal(S{doc})
+		assert.Error(err, doc)
+	}
+}
+
+func TestMarshalTypeJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Raw json.RawMessage
+	}
+	_, err := MarshalType(S{})
+	assert.Error(err)
+	assert.Equal("Cannot marshal type "+rawMessageType.String()+", the Noms type of JSON depends on the value", err.Error())
+}
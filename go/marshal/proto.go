@@ -0,0 +1,238 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Go structs generated by protoc-gen-go are recognized by the "protobuf" and
+// "protobuf_oneof" tags on their fields. This file contains the special
+// handling for such structs, which does not depend on the protobuf runtime:
+//
+//  - Generated bookkeeping fields (XXX_* and non exported fields) are skipped.
+//  - Fields are named after the JSON name of the protobuf field, unless there
+//    is an explicit noms tag.
+//  - proto3 scalar fields are omitted when they hold the zero value, like they
+//    are on the wire, and proto2 required fields are required by Unmarshal.
+//  - Oneof fields are optional. A oneof is encoded as a Noms struct with a
+//    single field, named after the member that is set.
+//  - google.protobuf.Timestamp is encoded the same way as datetime.DateTime
+//    and google.protobuf.Duration is encoded as a Number of seconds.
+
+var protoDateTimeType = types.MakeStructTypeFromFields("DateTime", types.FieldMap{
+	"secSinceEpoch": types.NumberType,
+})
+
+// isProtoMessage returns true if t looks like a struct generated from a
+// protobuf message.
+func isProtoMessage(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		if tag.Get("protobuf") != "" || tag.Get("protobuf_oneof") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// protoTags adjusts the tags of a field of a protobuf message.
+func protoTags(f reflect.StructField, tags nomsTags) nomsTags {
+	if strings.HasPrefix(f.Name, "XXX_") || unicode.IsLower(rune(f.Name[0])) {
+		tags.skip = true
+		return tags
+	}
+
+	if f.Tag.Get("protobuf_oneof") != "" {
+		tags.oneof = true
+		tags.omitEmpty = true
+	}
+
+	for _, part := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		switch part {
+		case "proto3":
+			// proto3 fields without explicit presence are not serialized when
+			// they hold the zero value.
+			tags.omitEmpty = true
+		case "req":
+			tags.required = true
+		}
+	}
+
+	if f.Tag.Get("noms") == "" {
+		if name := protoFieldName(f); name != "" {
+			tags.name = name
+		}
+	}
+	return tags
+}
+
+// protoFieldName returns the JSON name of a protobuf field, or the protobuf
+// name if the field does not have a separate JSON name.
+func protoFieldName(f reflect.StructField) string {
+	if oneof := f.Tag.Get("protobuf_oneof"); oneof != "" {
+		return oneof
+	}
+	var name string
+	for _, part := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "json=") {
+			return part[len("json="):]
+		}
+		if strings.HasPrefix(part, "name=") {
+			name = part[len("name="):]
+		}
+	}
+	return name
+}
+
+func isProtoTimestamp(t reflect.Type) bool {
+	return t.Name() == "Timestamp" && isProtoSecondsNanos(t)
+}
+
+func isProtoDuration(t reflect.Type) bool {
+	return t.Name() == "Duration" && isProtoSecondsNanos(t)
+}
+
+func isProtoSecondsNanos(t reflect.Type) bool {
+	if !isProtoMessage(t) {
+		return false
+	}
+	s, ok := t.FieldByName("Seconds")
+	if !ok || s.Type.Kind() != reflect.Int64 {
+		return false
+	}
+	n, ok := t.FieldByName("Nanos")
+	return ok && n.Type.Kind() == reflect.Int32
+}
+
+func protoSeconds(v reflect.Value) float64 {
+	return float64(v.FieldByName("Seconds").Int()) + float64(v.FieldByName("Nanos").Int())*1e-9
+}
+
+func setProtoSeconds(rv reflect.Value, secs float64) {
+	s, frac := math.Modf(secs)
+	rv.FieldByName("Seconds").SetInt(int64(s))
+	rv.FieldByName("Nanos").SetInt(int64(math.Floor(frac*1e9 + 0.5)))
+}
+
+func protoTimestampEncoder(v reflect.Value) types.Value {
+	return types.NewStruct("DateTime", types.StructData{
+		"secSinceEpoch": types.Number(protoSeconds(v)),
+	})
+}
+
+func protoTimestampDecoder(v types.Value, rv reflect.Value) {
+	s, ok := v.(types.Struct)
+	if !ok {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct"})
+	}
+	secs, ok := s.MaybeGet("secSinceEpoch")
+	if !ok {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", missing field \"secSinceEpoch\""})
+	}
+	n, ok := secs.(types.Number)
+	if !ok {
+		panic(&UnmarshalTypeMismatchError{secs, rv.Type(), ""})
+	}
+	setProtoSeconds(rv, float64(n))
+}
+
+func protoDurationEncoder(v reflect.Value) types.Value {
+	return types.Number(protoSeconds(v))
+}
+
+func protoDurationDecoder(v types.Value, rv reflect.Value) {
+	n, ok := v.(types.Number)
+	if !ok {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+	}
+	setProtoSeconds(rv, float64(n))
+}
+
+// oneofMember returns the single field of a generated oneof wrapper struct.
+func oneofMember(wrapper reflect.Type) reflect.StructField {
+	if wrapper.Kind() != reflect.Ptr || wrapper.Elem().Kind() != reflect.Struct || wrapper.Elem().NumField() != 1 {
+		panic(&UnsupportedTypeError{wrapper, "Invalid protobuf oneof wrapper"})
+	}
+	return wrapper.Elem().Field(0)
+}
+
+func oneofMemberName(f reflect.StructField) string {
+	if name := protoFieldName(f); name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name[:1]) + f.Name[1:]
+}
+
+// oneofEncoder encodes the wrapper struct stored in a oneof field as a Noms
+// struct with a single field named after the member that is set.
+func oneofEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
+	return func(v reflect.Value) types.Value {
+		wrapper := v.Elem()
+		member := oneofMember(wrapper.Type())
+		mv := wrapper.Elem().Field(0)
+		var encoder encoderFunc
+		if isOptionalPtr(member.Type) {
+			if mv.IsNil() {
+				panic(&marshalNomsError{fmt.Errorf("Cannot marshal nil oneof member %s in %s", member.Name, t)})
+			}
+			encoder = pointerEncoder(member.Type, seenStructs, nomsTags{})
+		} else {
+			encoder = typeEncoder(member.Type, seenStructs, nomsTags{})
+		}
+		return types.NewStruct("", types.StructData{
+			oneofMemberName(member): encoder(mv),
+		})
+	}
+}
+
+// oneofDecoder decodes a oneof field. The possible wrapper types are found
+// using the XXX_OneofWrappers method that protoc-gen-go generates on the
+// message type.
+func oneofDecoder(msg reflect.Type, f reflect.StructField) decoderFunc {
+	m, ok := reflect.PtrTo(msg).MethodByName("XXX_OneofWrappers")
+	if !ok {
+		panic(&UnsupportedTypeError{msg, "Protobuf message is missing XXX_OneofWrappers"})
+	}
+	wrappers := map[string]reflect.Type{}
+	res := m.Func.Call([]reflect.Value{reflect.New(msg)})[0]
+	for i := 0; i < res.Len(); i++ {
+		wt := res.Index(i).Elem().Type()
+		if !wt.AssignableTo(f.Type) {
+			continue
+		}
+		wrappers[oneofMemberName(oneofMember(wt))] = wt
+	}
+
+	return func(v types.Value, rv reflect.Value) {
+		s, ok := v.(types.Struct)
+		if !ok || s.Len() != 1 {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct with a single field"})
+		}
+		s.IterFields(func(name string, mv types.Value) {
+			wt, ok := wrappers[name]
+			if !ok {
+				panic(&UnmarshalTypeMismatchError{v, rv.Type(), fmt.Sprintf(", unknown oneof member %q", name)})
+			}
+			wrapper := reflect.New(wt.Elem())
+			mt := wt.Elem().Field(0).Type
+			if isOptionalPtr(mt) {
+				pointerDecoder(mt, nomsTags{})(mv, wrapper.Elem().Field(0))
+			} else {
+				typeDecoder(mt, nomsTags{})(mv, wrapper.Elem().Field(0))
+			}
+			rv.Set(wrapper)
+		})
+	}
+}
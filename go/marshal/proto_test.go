@@ -0,0 +1,181 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// The types below mirror what protoc-gen-go generates for:
+//
+//   message Event {
+//     string event_id = 1;
+//     google.protobuf.Timestamp created = 2;
+//     google.protobuf.Duration elapsed = 3;
+//     oneof source {
+//       string url = 4;
+//       Device device = 5;
+//     }
+//   }
+//
+//   message Device {
+//     int32 id = 1;
+//   }
+
+type Timestamp struct {
+	Seconds              int64    `protobuf:"varint,1,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	Nanos                int32    `protobuf:"varint,2,opt,name=nanos,proto3" json:"nanos,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Duration struct {
+	Seconds              int64    `protobuf:"varint,1,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	Nanos                int32    `protobuf:"varint,2,opt,name=nanos,proto3" json:"nanos,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Device struct {
+	Id                   int32    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+type Event struct {
+	EventId string     `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	Created *Timestamp `protobuf:"bytes,2,opt,name=created,proto3" json:"created,omitempty"`
+	Elapsed *Duration  `protobuf:"bytes,3,opt,name=elapsed,proto3" json:"elapsed,omitempty"`
+	// Types that are valid to be assigned to Source:
+	//	*Event_Url
+	//	*Event_Device
+	Source               isEvent_Source `protobuf_oneof:"source"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+type isEvent_Source interface {
+	isEvent_Source()
+}
+
+type Event_Url struct {
+	Url string `protobuf:"bytes,4,opt,name=url,proto3,oneof"`
+}
+
+type Event_Device struct {
+	Device *Device `protobuf:"bytes,5,opt,name=device,proto3,oneof"`
+}
+
+func (*Event_Url) isEvent_Source()    {}
+func (*Event_Device) isEvent_Source() {}
+
+func (*Event) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*Event_Url)(nil),
+		(*Event_Device)(nil),
+	}
+}
+
+type Legacy struct {
+	Name             *string `protobuf:"bytes,1,req,name=name" json:"name,omitempty"`
+	Count            *int64  `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func TestEncodeProto(t *testing.T) {
+	assert := assert.New(t)
+
+	v, err := Marshal(Event{
+		EventId: "e1",
+		Created: &Timestamp{Seconds: 10, Nanos: 5e8},
+		Elapsed: &Duration{Seconds: 2},
+		Source:  &Event_Device{&Device{Id: 7}},
+	})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Event", types.StructData{
+		"eventId": types.String("e1"),
+		"created": types.NewStruct("DateTime", types.StructData{
+			"secSinceEpoch": types.Number(10.5),
+		}),
+		"elapsed": types.Number(2),
+		"source": types.NewStruct("", types.StructData{
+			"device": types.NewStruct("Device", types.StructData{
+				"id": types.Number(7),
+			}),
+		}),
+	}).Equals(v))
+
+	// Zero valued proto3 fields and unset oneofs are omitted.
+	v, err = Marshal(Event{})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Event", types.StructData{}).Equals(v))
+}
+
+func TestDecodeProto(t *testing.T) {
+	assert := assert.New(t)
+
+	in := Event{
+		EventId: "e1",
+		Created: &Timestamp{Seconds: 10, Nanos: 5e8},
+		Source:  &Event_Url{"http://example.com"},
+	}
+	v, err := Marshal(in)
+	assert.NoError(err)
+
+	var out Event
+	err = Unmarshal(v, &out)
+	assert.NoError(err)
+	assert.Equal(in, out)
+
+	err = Unmarshal(types.NewStruct("Event", types.StructData{
+		"source": types.NewStruct("", types.StructData{
+			"phone": types.String("555"),
+		}),
+	}), &out)
+	assert.Error(err)
+	assert.Contains(err.Error(), `unknown oneof member "phone"`)
+}
+
+func TestProtoPresence(t *testing.T) {
+	assert := assert.New(t)
+
+	name := "n"
+	v, err := Marshal(Legacy{Name: &name})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Legacy", types.StructData{
+		"name": types.String("n"),
+	}).Equals(v))
+
+	var l Legacy
+	err = Unmarshal(v, &l)
+	assert.NoError(err)
+	assert.Equal("n", *l.Name)
+	assert.Nil(l.Count)
+
+	err = Unmarshal(types.NewStruct("Legacy", types.StructData{}), &l)
+	assert.Error(err)
+	assert.Contains(err.Error(), `missing required field "name"`)
+}
+
+func TestMarshalTypeProto(t *testing.T) {
+	assert := assert.New(t)
+
+	typ, err := MarshalType(Device{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("Device",
+		types.StructField{Name: "id", Type: types.NumberType, Optional: true},
+	).Equals(typ))
+
+	typ, err = MarshalType(Timestamp{})
+	assert.NoError(err)
+	assert.True(protoDateTimeType.Equals(typ))
+}
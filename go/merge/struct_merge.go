@@ -0,0 +1,53 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// StructMergeFunc merges two structs of the name it's registered for, which
+// are both changed from a common ancestor, into one. It's given only the two
+// candidates, so it suits convergent types whose merge doesn't depend on the
+// ancestor. If a or b isn't of the shape it merges, it returns false, and the
+// structs are merged field by field like any others.
+type StructMergeFunc func(a, b types.Struct) (merged types.Value, ok bool)
+
+var (
+	structMergesMu sync.RWMutex
+	structMerges   = map[string]StructMergeFunc{}
+)
+
+// RegisterStructMerge makes ThreeWay merge two structs named name with f,
+// instead of field by field, wherever they're found in the values merged. It
+// panics if a StructMergeFunc was already registered for name.
+func RegisterStructMerge(name string, f StructMergeFunc) {
+	structMergesMu.Lock()
+	defer structMergesMu.Unlock()
+	if _, ok := structMerges[name]; ok {
+		d.Panic("A merge is already registered for struct %s", name)
+	}
+	structMerges[name] = f
+}
+
+// registeredStructMerge merges a and b with the StructMergeFunc registered for
+// their name, if they're structs of the same name and it can merge them.
+func registeredStructMerge(a, b types.Value) (merged types.Value, ok bool) {
+	aStruct, aOk := a.(types.Struct)
+	bStruct, bOk := b.(types.Struct)
+	if !aOk || !bOk || aStruct.Name() != bStruct.Name() {
+		return nil, false
+	}
+	structMergesMu.RLock()
+	f, ok := structMerges[aStruct.Name()]
+	structMergesMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return f(aStruct, bStruct)
+}
@@ -80,7 +80,8 @@ func NewThreeWay(resolve ResolveFunc) Policy {
 //     - if the two merged values are still different: conflict
 //   - if a key was inserted in one candidate and removed in the other: conflict
 // - If the values are structs:
-//   - Same as map, except using field names instead of map keys
+//   - If a StructMergeFunc is registered for their name: the result of it
+//   - Otherwise, same as map, except using field names instead of map keys
 // - If the values are sets:
 //   - Apply the changes from both candidates to the parent to get the result. No conflicts are possible.
 // - If the values are list:
@@ -208,6 +209,9 @@ func (m *merger) threeWay(a, b, parent types.Value, path types.Path) (merged typ
 		}
 
 	case types.StructKind:
+		if merged, ok := registeredStructMerge(a, b); ok {
+			return merged, nil
+		}
 		if aStruct, bStruct, pStruct, ok := structAssert(a, b, parent); ok {
 			return m.threeWayStructMerge(aStruct, bStruct, pStruct, path)
 		}
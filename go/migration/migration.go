@@ -0,0 +1,139 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package migration rewrites databases written by older versions of noms in
+// the format of the current one, constants.NomsVersion.
+//
+// Each version which changes the format registers a Migration from the
+// version before it, so that a database can be brought up to date by running
+// the migrations between its version and the current one in turn.
+package migration
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Migration rewrites data in the format of the noms version From in the
+// format of the version To.
+type Migration struct {
+	From, To string
+
+	// Migrate writes the chunks reachable from root in src to sink, in the
+	// format of To, and returns the hash of the new root. Chunks whose
+	// encoding didn't change should be written as they are, so that their
+	// hashes, and those of the chunks which refer to them, are kept.
+	Migrate func(src chunks.ChunkSource, root hash.Hash, sink chunks.ChunkSink) (hash.Hash, error)
+}
+
+// ErrUpToDate is returned by Run if the data is already in the format of the
+// current version.
+var ErrUpToDate = fmt.Errorf("the data is already at version %s", constants.NomsVersion)
+
+// migrations are the registered Migrations by the version they migrate from.
+var migrations = map[string]Migration{}
+
+// Register registers m, so it's used to migrate data of version m.From.
+// There can only be one Migration from a version.
+func Register(m Migration) {
+	d.PanicIfTrue(m.From == m.To)
+	if _, ok := migrations[m.From]; ok {
+		d.Panic("A migration from version %s is already registered", m.From)
+	}
+	migrations[m.From] = m
+}
+
+// Plan returns the Migrations which, run in order, migrate data of version
+// from to version to.
+func Plan(from, to string) ([]Migration, error) {
+	plan := []Migration{}
+	for v := from; v != to; {
+		m, ok := migrations[v]
+		if !ok || len(plan) == len(migrations) {
+			return nil, fmt.Errorf("there's no migration from version %s to %s", from, to)
+		}
+		plan = append(plan, m)
+		v = m.To
+	}
+	return plan, nil
+}
+
+// Run migrates the data in src to the current version, writing it to sink and
+// moving the root of sink, which must be empty, to the migrated root of src.
+// It returns the new root. The data between migrations is kept in memory, so
+// migrating across several versions needs memory for all of it.
+func Run(src, sink chunks.ChunkStore) (hash.Hash, error) {
+	if !sink.Root().IsEmpty() {
+		return hash.Hash{}, fmt.Errorf("the destination isn't empty")
+	}
+	if src.Version() == constants.NomsVersion {
+		return hash.Hash{}, ErrUpToDate
+	}
+	plan, err := Plan(src.Version(), constants.NomsVersion)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+
+	var from chunks.ChunkSource = src
+	root := src.Root()
+	for i, m := range plan {
+		var to chunks.ChunkStore = sink
+		if i < len(plan)-1 {
+			to = chunks.NewMemoryStore()
+		}
+		if root, err = m.Migrate(from, root, to); err != nil {
+			return hash.Hash{}, fmt.Errorf("Couldn't migrate from version %s to %s: %s", m.From, m.To, err)
+		}
+		to.Flush()
+		from = to
+	}
+
+	if !sink.UpdateRoot(root, hash.Hash{}) {
+		return hash.Hash{}, fmt.Errorf("the destination was changed during the migration")
+	}
+	return root, nil
+}
+
+// Copy is the Migrate func of a Migration between versions whose formats
+// are the same: it writes the chunks reachable from root in src to sink as
+// they are, keeping all the hashes.
+func Copy(src chunks.ChunkSource, root hash.Hash, sink chunks.ChunkSink) (hash.Hash, error) {
+	if root.IsEmpty() {
+		return root, nil
+	}
+	seen := hash.HashSet{}
+	next := hash.HashSet{root: struct{}{}}
+	for len(next) > 0 {
+		for h := range next {
+			seen.Insert(h)
+		}
+		found := make(chan *chunks.Chunk, len(next))
+		go func(hashes hash.HashSet) {
+			defer close(found)
+			src.GetMany(hashes, found)
+		}(next)
+
+		batch := make([]chunks.Chunk, 0, len(next))
+		refs := hash.HashSet{}
+		for c := range found {
+			batch = append(batch, *c)
+			types.DecodeValue(*c, nil).WalkRefs(func(r types.Ref) {
+				if h := r.TargetHash(); !seen.Has(h) {
+					refs.Insert(h)
+				}
+			})
+		}
+		if len(batch) < len(next) {
+			return hash.Hash{}, fmt.Errorf("%d chunks are missing", len(next)-len(batch))
+		}
+		sink.PutMany(batch)
+		next = refs
+	}
+	return root, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migration
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// oldStore is a MemoryStore with data of an older version.
+type oldStore struct {
+	*chunks.MemoryStore
+	version string
+}
+
+func (s oldStore) Version() string {
+	return s.version
+}
+
+func withMigrations(ms []Migration, f func()) {
+	old := migrations
+	defer func() { migrations = old }()
+	migrations = map[string]Migration{}
+	for _, m := range ms {
+		Register(m)
+	}
+	f()
+}
+
+func TestPlan(t *testing.T) {
+	assert := assert.New(t)
+	ms := []Migration{
+		{From: "1", To: "2", Migrate: Copy},
+		{From: "2", To: "3", Migrate: Copy},
+		{From: "4", To: "5", Migrate: Copy},
+		{From: "5", To: "4", Migrate: Copy},
+	}
+	withMigrations(ms, func() {
+		plan, err := Plan("1", "3")
+		assert.NoError(err)
+		if assert.Len(plan, 2) {
+			assert.Equal("1", plan[0].From)
+			assert.Equal("2", plan[1].From)
+		}
+		plan, err = Plan("3", "3")
+		assert.NoError(err)
+		assert.Empty(plan)
+
+		_, err = Plan("0", "3")
+		assert.Error(err)
+		_, err = Plan("4", "3")
+		assert.Error(err)
+
+		assert.Panics(func() { Register(Migration{From: "1", To: "3", Migrate: Copy}) })
+	})
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := datas.NewDatabase(cs)
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.NewList(types.String("a"), types.String("b")))
+	assert.NoError(err)
+	src := oldStore{cs, "1"}
+
+	ms := []Migration{
+		{From: "1", To: "2", Migrate: Copy},
+		{From: "2", To: constants.NomsVersion, Migrate: Copy},
+	}
+	withMigrations(ms, func() {
+		sink := chunks.NewMemoryStore()
+		root, err := Run(src, sink)
+		assert.NoError(err)
+		assert.Equal(cs.Root(), root)
+		assert.Equal(root, sink.Root())
+		assert.Equal(cs.Len(), sink.Len())
+
+		migrated := datas.NewDatabase(sink)
+		assert.True(ds.Head().Equals(migrated.GetDataset("ds").Head()))
+
+		_, err = Run(src, sink)
+		assert.Error(err)
+		_, err = Run(cs, chunks.NewMemoryStore())
+		assert.Equal(ErrUpToDate, err)
+		_, err = Run(oldStore{cs, "0"}, chunks.NewMemoryStore())
+		assert.Error(err)
+	})
+}
+
+func TestRunMissingChunks(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	r := types.NewRef(types.String("missing"))
+	c := types.EncodeValue(types.NewList(r), nil)
+	cs.Put(c)
+	assert.True(cs.UpdateRoot(c.Hash(), hash.Hash{}))
+
+	withMigrations([]Migration{{From: "1", To: constants.NomsVersion, Migrate: Copy}}, func() {
+		sink := chunks.NewMemoryStore()
+		_, err := Run(oldStore{cs, "1"}, sink)
+		assert.Error(err)
+		assert.True(sink.Root().IsEmpty())
+	})
+}
+
+func TestRewrite(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := datas.NewDatabase(cs)
+	blob := types.NewBlob(bytes.NewReader(bytes.Repeat([]byte("abc"), 1<<14)))
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.NewStruct("S", types.StructData{
+		"list": types.NewList(types.Number(1), types.String("a")),
+		"set":  types.NewSet(types.Bool(true)),
+		"blob": blob,
+		"ref":  db.WriteValue(types.NewMap(types.String("a"), types.Number(1))),
+	}))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, types.String("b"))
+	assert.NoError(err)
+
+	// Values whose encoding is the same are kept as they are.
+	sink := chunks.NewMemoryStore()
+	root, err := Rewrite(cs, cs.Root(), sink)
+	assert.NoError(err)
+	assert.Equal(cs.Root(), root)
+	assert.True(sink.UpdateRoot(root, hash.Hash{}))
+	migrated := datas.NewDatabase(sink)
+	assert.True(ds.Head().Equals(migrated.GetDataset("ds").Head()))
+	// All but the Map of datasets of the first commit, which isn't reachable.
+	assert.Equal(cs.Len()-1, sink.Len())
+
+	// A Map of 7.8, with a large value inlined, is rewritten with it boxed.
+	large := types.String(strings.Repeat("x", 1<<14))
+	data := types.EncodeValue(types.NewMap(types.String("k"), types.String("v")), nil).Data()
+	inlined := types.EncodeValue(large, nil).Data()
+	old := chunks.NewChunk(append(append([]byte{}, data[:len(data)-len(types.EncodeValue(types.String("v"), nil).Data())]...), inlined...))
+	old78 := chunks.NewMemoryStore()
+	old78.Put(old)
+	assert.True(large.Equals(types.DecodeValue(old, nil).(types.Map).Get(types.String("k"))))
+
+	sink = chunks.NewMemoryStore()
+	root, err = Rewrite(old78, old.Hash(), sink)
+	assert.NoError(err)
+	expected := types.NewMap(types.String("k"), large)
+	assert.NotEqual(old.Hash(), root)
+	assert.Equal(expected.Hash(), root)
+	assert.True(sink.Has(large.Hash()))
+
+	_, err = Rewrite(chunks.NewMemoryStore(), old.Hash(), chunks.NewMemoryStore())
+	assert.Error(err)
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migration
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+func init() {
+	// 7.9 boxes the large keys and values of Maps in chunks of their own.
+	Register(Migration{From: "7.8", To: "7.9", Migrate: Rewrite})
+}
+
+// Rewrite is the Migrate func of a Migration to a version which reads the
+// data of the version before it, but encodes some values differently: it
+// rebuilds the values reachable from root in src, and writes them to sink in
+// the format of the current version. Values are encoded canonically, so the
+// hashes of those whose encoding didn't change are kept. Each collection is
+// rebuilt in memory, so the largest one needs to fit in it.
+func Rewrite(src chunks.ChunkSource, root hash.Hash, sink chunks.ChunkSink) (newRoot hash.Hash, err error) {
+	if root.IsEmpty() {
+		return root, nil
+	}
+	defer d.Recover(&err)
+	// Values are read and written by ValueStores of their own, since one
+	// which has read a value doesn't write it again.
+	bs := rewriteStore{src, sink}
+	rw := &rewriter{types.NewValueStore(bs), types.NewValueStore(bs), src, sink, map[hash.Hash]types.Ref{}}
+	newRoot = rw.ref(root).TargetHash()
+	rw.out.Flush(newRoot)
+	return newRoot, nil
+}
+
+// rewriter rebuilds values, writing the chunks reachable from them to sink.
+type rewriter struct {
+	in   *types.ValueStore
+	out  *types.ValueStore
+	src  chunks.ChunkSource
+	sink chunks.ChunkSink
+	refs map[hash.Hash]types.Ref // the rewritten Refs, by the hash of the old ones
+}
+
+func (rw *rewriter) ref(h hash.Hash) types.Ref {
+	if r, ok := rw.refs[h]; ok {
+		return r
+	}
+	v := rw.in.ReadValue(h)
+	if v == nil {
+		d.PanicIfError(fmt.Errorf("chunk %s is missing", h))
+	}
+	r := rw.out.WriteValue(rw.value(v))
+	rw.refs[h] = r
+	return r
+}
+
+func (rw *rewriter) value(v types.Value) types.Value {
+	switch v := v.(type) {
+	case types.Ref:
+		r := rw.ref(v.TargetHash())
+		if v.TargetType().Equals(types.ValueType) {
+			// e.g. the Refs of the Map of datasets
+			return types.ToRefOfValue(r)
+		}
+		return r
+	case types.Struct:
+		data := types.StructData{}
+		v.IterFields(func(name string, fv types.Value) {
+			data[name] = rw.value(fv)
+		})
+		return types.NewStruct(v.Name(), data)
+	case types.List:
+		elems := make([]types.Value, 0, v.Len())
+		v.IterAll(func(elem types.Value, i uint64) {
+			elems = append(elems, rw.value(elem))
+		})
+		return types.NewList(elems...)
+	case types.Set:
+		elems := make([]types.Value, 0, v.Len())
+		v.IterAll(func(elem types.Value) {
+			elems = append(elems, rw.value(elem))
+		})
+		return types.NewSet(elems...)
+	case types.Map:
+		kvs := make([]types.Value, 0, 2*v.Len())
+		v.IterAll(func(k, mv types.Value) {
+			kvs = append(kvs, rw.value(k), rw.value(mv))
+		})
+		return types.NewMap(kvs...)
+	case types.Blob:
+		// Blobs are only bytes, so their chunks are copied as they are.
+		v.WalkRefs(func(r types.Ref) {
+			_, err := Copy(rw.src, r.TargetHash(), rw.sink)
+			d.PanicIfError(err)
+		})
+	}
+	return v
+}
+
+// rewriteStore is the BatchStore of a rewriter, which reads from src and
+// writes to sink.
+type rewriteStore struct {
+	src  chunks.ChunkSource
+	sink chunks.ChunkSink
+}
+
+func (s rewriteStore) Get(h hash.Hash) chunks.Chunk {
+	return s.src.Get(h)
+}
+
+func (s rewriteStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	s.src.GetMany(hashes, foundChunks)
+}
+
+func (s rewriteStore) SchedulePut(c chunks.Chunk) {
+	s.sink.Put(c)
+}
+
+func (s rewriteStore) Flush() {
+	s.sink.Flush()
+}
+
+func (s rewriteStore) Root() hash.Hash {
+	return hash.Hash{}
+}
+
+func (s rewriteStore) UpdateRoot(current, last hash.Hash) bool {
+	return false
+}
+
+func (s rewriteStore) Close() error {
+	return nil
+}
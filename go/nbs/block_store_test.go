@@ -120,6 +120,44 @@ func (suite *BlockStoreSuite) TestChunkStorePutMoreThanMemTable() {
 	suite.Len(suite.store.tables.ToSpecs(), 2)
 }
 
+func (suite *BlockStoreSuite) TestChunkStoreStats() {
+	input1, input2 := []byte("abc"), []byte("defg")
+	c1, c2 := chunks.NewChunk(input1), chunks.NewChunk(input2)
+	suite.store.PutMany([]chunks.Chunk{c1, c2})
+	suite.Equal(StoreStats{Chunks: 2, RawBytes: 7}, suite.store.Stats())
+
+	suite.store.UpdateRoot(c1.Hash(), suite.store.Root()) // Commit writes
+	stats := suite.store.Stats()
+	suite.Equal(1, stats.Tables)
+	suite.Equal(uint64(2), stats.Chunks)
+	suite.Equal(uint64(7), stats.RawBytes)
+	suite.True(stats.CompressedBytes > 0)
+}
+
+func (suite *BlockStoreSuite) TestChunkStoreInspect() {
+	input1, input2 := []byte("abc"), []byte("defg")
+	c1, c2 := chunks.NewChunk(input1), chunks.NewChunk(input2)
+	suite.store.PutMany([]chunks.Chunk{c1, c2})
+	info := suite.store.Inspect()
+	suite.Empty(info.Tables)
+	suite.True(info.Root.IsEmpty())
+
+	suite.store.UpdateRoot(c1.Hash(), suite.store.Root()) // Commit writes
+	info = suite.store.Inspect()
+	suite.Equal(StorageVersion, info.StorageVersion)
+	suite.Equal(suite.store.Version(), info.NomsVersion)
+	suite.Equal(suite.store.manifestLock.String(), info.Lock)
+	suite.Equal(c1.Hash(), info.Root)
+	suite.Equal(defaultMaxTables, info.MaxTables)
+	specs := suite.store.tables.ToSpecs()
+	if suite.Len(info.Tables, 1) {
+		suite.Equal(specs[0].name.String(), info.Tables[0].Name)
+		suite.Equal(uint32(2), info.Tables[0].Chunks)
+		suite.Equal(uint64(7), info.Tables[0].RawBytes)
+		suite.True(info.Tables[0].CompressedBytes > 0)
+	}
+}
+
 func (suite *BlockStoreSuite) TestChunkStoreGetMany() {
 	inputs := [][]byte{make([]byte, testMemTableSize/2+1), make([]byte, testMemTableSize/2+1), []byte("abc")}
 	rand.Read(inputs[0])
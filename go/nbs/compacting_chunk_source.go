@@ -87,6 +87,12 @@ func (ccs *compactingChunkSource) uncompressedLen() uint64 {
 	return ccs.cs.uncompressedLen()
 }
 
+func (ccs *compactingChunkSource) compressedLen() uint64 {
+	ccs.wg.Wait()
+	d.Chk.True(ccs.cs != nil)
+	return ccs.cs.compressedLen()
+}
+
 func (ccs *compactingChunkSource) hash() addr {
 	ccs.wg.Wait()
 	d.Chk.True(ccs.cs != nil)
@@ -135,6 +141,10 @@ func (ecs emptyChunkSource) uncompressedLen() uint64 {
 	return 0
 }
 
+func (ecs emptyChunkSource) compressedLen() uint64 {
+	return 0
+}
+
 func (ecs emptyChunkSource) hash() addr {
 	return addr{} // TODO: is this legal?
 }
@@ -94,7 +94,7 @@ func (dm dynamoManifest) Update(lastLock, newLock addr, specs []tableSpec, newRo
 		Item: map[string]*dynamodb.AttributeValue{
 			dbAttr:      {S: aws.String(dm.db)},
 			nbsVersAttr: {S: aws.String(StorageVersion)},
-			versAttr:    {S: aws.String(constants.NomsVersion)},
+			versAttr:    {S: aws.String(constants.DataVersion())},
 			rootAttr:    {B: newRoot[:]},
 			lockAttr:    {B: newLock[:]},
 		},
@@ -113,7 +113,7 @@ func (dm dynamoManifest) Update(lastLock, newLock addr, specs []tableSpec, newRo
 	putArgs.ConditionExpression = aws.String(expr)
 	putArgs.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
 		":prev": {B: lastLock[:]},
-		":vers": {S: aws.String(constants.NomsVersion)},
+		":vers": {S: aws.String(constants.DataVersion())},
 	}
 
 	_, ddberr := dm.ddbsvc.PutItem(&putArgs)
@@ -122,7 +122,7 @@ func (dm dynamoManifest) Update(lastLock, newLock addr, specs []tableSpec, newRo
 			if awsErr.Code() == "ConditionalCheckFailedException" {
 				exists, vers, lock, actual, tableSpecs := dm.ParseIfExists(nil)
 				d.Chk.True(exists)
-				d.Chk.True(vers == constants.NomsVersion)
+				d.Chk.True(vers == constants.DataVersion())
 				return lock, actual, tableSpecs
 			} // TODO handle other aws errors?
 		}
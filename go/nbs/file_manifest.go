@@ -112,7 +112,7 @@ func (fm fileManifest) Update(lastLock, newLock addr, specs []tableSpec, newRoot
 
 			var mVers string
 			mVers, lock, actual, tableSpecs = parseManifest(f)
-			d.PanicIfFalse(constants.NomsVersion == mVers)
+			d.PanicIfFalse(constants.DataVersion() == mVers)
 		} else {
 			d.Chk.True(lastLock == addr{})
 		}
@@ -128,7 +128,7 @@ func (fm fileManifest) Update(lastLock, newLock addr, specs []tableSpec, newRoot
 
 func writeManifest(temp io.Writer, lock addr, root hash.Hash, specs []tableSpec) {
 	strs := make([]string, 2*len(specs)+4)
-	strs[0], strs[1], strs[2], strs[3] = StorageVersion, constants.NomsVersion, lock.String(), root.String()
+	strs[0], strs[1], strs[2], strs[3] = StorageVersion, constants.DataVersion(), lock.String(), root.String()
 	tableInfo := strs[4:]
 	formatSpecs(specs, tableInfo)
 	_, err := io.WriteString(temp, strings.Join(strs, ":"))
@@ -93,6 +93,22 @@ func TestFileManifestUpdateWontClobberOldVersion(t *testing.T) {
 	assert.Panics(func() { fm.Update(addr{}, addr{}, nil, hash.Hash{}, nil) })
 }
 
+func TestFileManifestUpdateWontClobberOtherHashFunc(t *testing.T) {
+	assert := assert.New(t)
+	fm := makeFileManifestTempDir(t)
+	defer os.RemoveAll(fm.dir)
+
+	defer hash.SetFunc(hash.CurrentFunc().Name)
+	hash.SetFunc("sha3")
+	assert.Equal(constants.NomsVersion+"+sha3", constants.DataVersion())
+	fm.Update(addr{}, addr{}, nil, hash.Hash{}, nil)
+	hash.SetFunc(hash.DefaultFuncName)
+
+	_, vers, _, _, _ := fm.ParseIfExists(nil)
+	assert.Equal(constants.NomsVersion+"+sha3", vers)
+	assert.Panics(func() { fm.Update(addr{}, addr{}, nil, hash.Hash{}, nil) })
+}
+
 func TestFileManifestUpdateEmpty(t *testing.T) {
 	assert := assert.New(t)
 	fm := makeFileManifestTempDir(t)
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/attic-labs/noms/go/d"
 )
@@ -34,7 +35,10 @@ func (ftp fsTablePersister) persistTable(name addr, data []byte, chunkCount uint
 		io.Copy(temp, bytes.NewReader(data))
 		index := parseTableIndex(data)
 		if ftp.indexCache != nil {
-			ftp.indexCache.put(name, index)
+			// A table of the same name, but with its chunks in another order,
+			// may have been in dir before, e.g. if dir was moved aside.
+			ftp.indexCache.drop(ftp.dir, name)
+			ftp.indexCache.put(ftp.dir, name, index)
 		}
 		return temp.Name()
 	}()
@@ -52,3 +56,15 @@ func (ftp fsTablePersister) CompactAll(sources chunkSources) chunkSource {
 func (ftp fsTablePersister) Open(name addr, chunkCount uint32) chunkSource {
 	return newMmapTableReader(ftp.dir, name, chunkCount, ftp.indexCache)
 }
+
+func (ftp fsTablePersister) modTime(name addr) (time.Time, bool) {
+	fi, err := os.Stat(filepath.Join(ftp.dir, name.String()))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+func (ftp fsTablePersister) remove(name addr) error {
+	return os.Remove(filepath.Join(ftp.dir, name.String()))
+}
@@ -100,3 +100,37 @@ func TestFSTablePersisterCompactAllDups(t *testing.T) {
 		assert.EqualValues(len(testChunks), tr.count())
 	}
 }
+
+func TestFSTablePersisterSharedIndexCache(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(len(testChunks) > 1, "Whoops, this test isn't meaningful")
+	cache := newIndexCache(1024)
+
+	persist := func(chunks [][]byte) (fsTablePersister, addr) {
+		mt := newMemTable(testMemTableSize)
+		for _, c := range chunks {
+			assert.True(mt.addChunk(computeAddr(c), c))
+		}
+		fts := fsTablePersister{dir: makeTempDir(assert), indexCache: cache}
+		return fts, fts.Compact(mt, nil).hash()
+	}
+	reversed := make([][]byte, len(testChunks))
+	for i, c := range testChunks {
+		reversed[len(testChunks)-1-i] = c
+	}
+
+	// Tables with the same chunks in a different order have the same name.
+	fts1, name1 := persist(testChunks)
+	defer os.RemoveAll(fts1.dir)
+	fts2, name2 := persist(reversed)
+	defer os.RemoveAll(fts2.dir)
+	assert.Equal(name1, name2)
+
+	for _, fts := range []fsTablePersister{fts1, fts2} {
+		src := fts.Open(name1, uint32(len(testChunks)))
+		for _, c := range testChunks {
+			assert.Equal(string(c), string(src.get(computeAddr(c))))
+		}
+		src.close()
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
+)
+
+// GCOptions control a call to NomsBlockStore.GC().
+type GCOptions struct {
+	// Retention protects the tables written within this duration of the start
+	// of the GC; they're kept as they are. If the age of a table can't be
+	// determined, as for stores in S3, it's treated as recent.
+	Retention time.Duration
+	// Concurrency is the number of tables read concurrently. Defaults to 1.
+	Concurrency int
+	// DryRun only computes the GCStats, it doesn't change the store.
+	DryRun bool
+}
+
+// GCStats describes the chunks examined by a call to NomsBlockStore.GC().
+// Byte counts are of uncompressed chunk data.
+type GCStats struct {
+	Tables, CollectedTables int
+	Chunks, Bytes           uint64
+	ReclaimedChunks         uint64
+	ReclaimedBytes          uint64
+}
+
+// ErrGCConflict is returned by GC if the store was changed by someone else
+// while it was running. Nothing has been collected in that case.
+var ErrGCConflict = nomserrors.Wrap(nomserrors.ErrRootConflict, errors.New("the store was changed during garbage collection"))
+
+// Tables of persisters implementing tableAger can be protected by
+// GCOptions.Retention.
+type tableAger interface {
+	modTime(name addr) (time.Time, bool)
+}
+
+// Tables of persisters implementing tableRemover are removed once they're no
+// longer referenced by the manifest after a GC.
+type tableRemover interface {
+	remove(name addr) error
+}
+
+// GC removes the chunks for which keep returns false from the tables of nbs,
+// by rewriting the tables which hold any such chunk into a single new table.
+// keep must return true for every chunk reachable from nbs.Root(), and is
+// called concurrently if GCOptions.Concurrency > 1. Pending writes are
+// flushed first.
+//
+// GC must not run concurrently with writers in other processes: a writer
+// might skip writing a chunk because it's already in a table that's about to
+// be rewritten, then commit a root which references it. Changes to the
+// manifest while GC is running are detected, in which case it returns
+// ErrGCConflict.
+func (nbs *NomsBlockStore) GC(keep func(h hash.Hash) bool, opts GCOptions) (GCStats, error) {
+	if !opts.DryRun {
+		nbs.Flush()
+	}
+	start := time.Now()
+
+	nbs.mu.Lock()
+	defer nbs.mu.Unlock()
+
+	stats := GCStats{}
+	var candidates, kept chunkSources
+	for _, src := range append(append(chunkSources{}, nbs.tables.novel...), nbs.tables.upstream...) {
+		if src.count() == 0 {
+			continue
+		}
+		stats.Tables++
+		if opts.Retention > 0 && isRecentTable(nbs.tables.p, src.hash(), start.Add(-opts.Retention)) {
+			stats.Chunks += uint64(src.count())
+			stats.Bytes += src.uncompressedLen()
+			kept = append(kept, src)
+			continue
+		}
+		candidates = append(candidates, src)
+	}
+
+	// Collect the live chunks of all candidates. Tables without any garbage
+	// are kept as they are. Like compaction, this holds all of the live chunks
+	// in memory (BUG 3130).
+	var mt *memTable
+	if !opts.DryRun {
+		mt = newMemTable(^uint64(0))
+	}
+	var rewritten chunkSources
+	for i, tableStats := range sweepTables(candidates, keep, mt, opts.Concurrency) {
+		stats.Chunks += tableStats.Chunks
+		stats.Bytes += tableStats.Bytes
+		if tableStats.ReclaimedChunks == 0 {
+			kept = append(kept, candidates[i])
+			continue
+		}
+		stats.CollectedTables++
+		stats.ReclaimedChunks += tableStats.ReclaimedChunks
+		stats.ReclaimedBytes += tableStats.ReclaimedBytes
+		rewritten = append(rewritten, candidates[i])
+	}
+
+	if opts.DryRun || len(rewritten) == 0 {
+		return stats, nil
+	}
+
+	// Chunks of rewritten tables can also be in kept tables, don't write them
+	// again.
+	keptSet := tableSet{upstream: kept}
+	compacted := nbs.tables.p.Compact(mt, keptSet)
+	specs := keptSet.ToSpecs()
+	if compacted.count() > 0 {
+		specs = append([]tableSpec{{compacted.hash(), compacted.count()}}, specs...)
+	}
+
+	nl := generateLockHash(nbs.root, specs)
+	lock, actual, tableSpecs := nbs.mm.Update(nbs.manifestLock, nl, specs, nbs.root, nil)
+	if nl != lock {
+		compacted.close()
+		var dropped chunkSources
+		nbs.manifestLock, nbs.root = lock, actual
+		nbs.tables, dropped = nbs.tables.Rebase(tableSpecs)
+		dropped.close()
+		return GCStats{}, ErrGCConflict
+	}
+
+	nbs.manifestLock = lock
+	upstream := kept
+	if compacted.count() > 0 {
+		upstream = append(chunkSources{compacted}, kept...)
+	}
+	nbs.tables = tableSet{upstream: upstream, p: nbs.tables.p, rl: nbs.tables.rl}
+	rewritten.close()
+	if tr, ok := nbs.tables.p.(tableRemover); ok {
+		for _, src := range rewritten {
+			if err := tr.remove(src.hash()); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+func isRecentTable(p tablePersister, name addr, since time.Time) bool {
+	ta, ok := p.(tableAger)
+	if !ok {
+		return true
+	}
+	t, ok := ta.modTime(name)
+	return !ok || t.After(since)
+}
+
+// sweepTables adds the chunks of sources for which keep returns true to mt,
+// unless it's nil, and returns the stats of each source.
+func sweepTables(sources chunkSources, keep func(h hash.Hash) bool, mt *memTable, concurrency int) []GCStats {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	stats := make([]GCStats, len(sources))
+	rl := make(chan struct{}, concurrency)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i, src := range sources {
+		wg.Add(1)
+		rl <- struct{}{}
+		go func(i int, src chunkSource) {
+			defer func() { wg.Done(); <-rl }()
+			ch := make(chan extractRecord)
+			go func() {
+				defer close(ch)
+				src.extract(ch)
+			}()
+			for rec := range ch {
+				size := uint64(len(rec.data))
+				stats[i].Chunks++
+				stats[i].Bytes += size
+				if !keep(hash.Hash(rec.a)) {
+					stats[i].ReclaimedChunks++
+					stats[i].ReclaimedBytes += size
+					continue
+				}
+				if mt == nil {
+					continue
+				}
+				mu.Lock()
+				mt.addChunk(rec.a, rec.data)
+				mu.Unlock()
+			}
+		}(i, src)
+	}
+	wg.Wait()
+	return stats
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestGC(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocalStore(dir, testMemTableSize)
+	defer store.Close()
+
+	live := []chunks.Chunk{chunks.NewChunk([]byte("abc")), chunks.NewChunk([]byte("def"))}
+	dead := []chunks.Chunk{chunks.NewChunk([]byte("ghi")), chunks.NewChunk([]byte("jkl"))}
+	store.PutMany([]chunks.Chunk{live[0], dead[0]})
+	assert.True(store.UpdateRoot(live[0].Hash(), store.Root()))
+	store.PutMany([]chunks.Chunk{live[1], dead[1]})
+	assert.True(store.UpdateRoot(live[1].Hash(), store.Root()))
+	assert.Len(store.tables.ToSpecs(), 2)
+
+	keep := hash.HashSet{}
+	for _, c := range live {
+		keep.Insert(c.Hash())
+	}
+	expected := GCStats{Tables: 2, CollectedTables: 2, Chunks: 4, Bytes: 12, ReclaimedChunks: 2, ReclaimedBytes: 6}
+
+	stats, err := store.GC(keep.Has, GCOptions{DryRun: true})
+	assert.NoError(err)
+	assert.Equal(expected, stats)
+	assert.True(store.Has(dead[0].Hash()))
+
+	stats, err = store.GC(keep.Has, GCOptions{Retention: time.Hour})
+	assert.NoError(err)
+	assert.Equal(GCStats{Tables: 2, Chunks: 4, Bytes: 12}, stats)
+
+	stats, err = store.GC(keep.Has, GCOptions{Concurrency: 2})
+	assert.NoError(err)
+	assert.Equal(expected, stats)
+	for _, c := range live {
+		assert.True(store.Has(c.Hash()))
+	}
+	for _, c := range dead {
+		assert.False(store.Has(c.Hash()))
+	}
+	assert.Equal(live[1].Hash(), store.Root())
+
+	// The collected tables are removed, and the store can be reopened.
+	specs := store.tables.ToSpecs()
+	assert.Len(specs, 1)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(err)
+	assert.Len(files, 3) // LOCK, manifest and the new table
+
+	reopened := NewLocalStore(dir, testMemTableSize)
+	defer reopened.Close()
+	assert.Equal(live[1].Hash(), reopened.Root())
+	assertInputInStore([]byte("abc"), live[0].Hash(), reopened, assert)
+	assert.False(reopened.Has(dead[1].Hash()))
+
+	stats, err = store.GC(keep.Has, GCOptions{})
+	assert.NoError(err)
+	assert.Equal(GCStats{Tables: 1, Chunks: 2, Bytes: 6}, stats)
+}
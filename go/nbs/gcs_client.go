@@ -0,0 +1,131 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/util/verbose"
+	"github.com/jpillora/backoff"
+)
+
+const gcsEndpoint = "https://storage.googleapis.com"
+
+var (
+	errGCSNotFound           = errors.New("GCS object not found")
+	errGCSPreconditionFailed = errors.New("GCS object generation doesn't match")
+)
+
+type gcssvc interface {
+	// GetObject returns the bytes of the object name in bucket selected by the
+	// HTTP Range header rangeHeader, all of them if it's empty, and the
+	// generation of the object. If there's no such object, it returns
+	// errGCSNotFound.
+	GetObject(bucket, name, rangeHeader string) (data []byte, generation int64, err error)
+	// PutObject writes the object name in bucket. If ifGeneration isn't
+	// negative, it's only written if its generation is ifGeneration, 0 meaning
+	// that it doesn't exist, and errGCSPreconditionFailed is returned if not.
+	PutObject(bucket, name string, data []byte, ifGeneration int64) error
+}
+
+// gcsClient is the gcssvc of the Cloud Storage JSON API at endpoint. Its
+// client authenticates the requests, see googleauth.
+type gcsClient struct {
+	client   *http.Client
+	endpoint string
+}
+
+func newGCSClient(client *http.Client) gcsClient {
+	return gcsClient{client, gcsEndpoint}
+}
+
+func (gc gcsClient) GetObject(bucket, name, rangeHeader string) ([]byte, int64, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", gc.endpoint, url.PathEscape(bucket), url.PathEscape(name))
+	resp, data, err := gc.do(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", u, nil)
+		if err == nil && rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		generation, err := strconv.ParseInt(resp.Header.Get("X-Goog-Generation"), 10, 64)
+		return data, generation, err
+	case http.StatusNotFound:
+		return nil, 0, errGCSNotFound
+	}
+	return nil, 0, gcsError("GET", bucket, name, resp, data)
+}
+
+func (gc gcsClient) PutObject(bucket, name string, data []byte, ifGeneration int64) error {
+	q := url.Values{"uploadType": {"media"}, "name": {name}}
+	if ifGeneration >= 0 {
+		q.Set("ifGenerationMatch", strconv.FormatInt(ifGeneration, 10))
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", gc.endpoint, url.PathEscape(bucket), q.Encode())
+	resp, body, err := gc.do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", u, bytes.NewReader(data))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		return req, err
+	})
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusPreconditionFailed:
+		return errGCSPreconditionFailed
+	}
+	return gcsError("POST", bucket, name, resp, body)
+}
+
+// do sends the request made by newRequest, retrying it while it fails in a
+// way which is likely to be transient, and reads the body of the response.
+func (gc gcsClient) do(newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	b := &backoff.Backoff{
+		Min:    128 * time.Millisecond,
+		Max:    8 * time.Second,
+		Factor: 2,
+		Jitter: true,
+	}
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := gc.client.Do(req)
+		var data []byte
+		if err == nil {
+			data, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		retry := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retry || attempt == 5 {
+			return resp, data, err
+		}
+		dur := b.Duration()
+		verbose.Warn("Retrying GCS request", verbose.Fields{"in": dur})
+		time.Sleep(dur)
+	}
+}
+
+func gcsError(method, bucket, name string, resp *http.Response, body []byte) error {
+	return fmt.Errorf("%s gs://%s/%s failed with %s: %s", method, bucket, name, resp.Status, strings.TrimSpace(string(body)))
+}
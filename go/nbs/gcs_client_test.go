@@ -0,0 +1,69 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestGCSClient(t *testing.T) {
+	assert := assert.New(t)
+	gcs := makeFakeGCS()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/storage/v1/b/bucket/o/db/obj":
+			assert.Equal("media", r.URL.Query().Get("alt"))
+			data, generation, err := gcs.GetObject("bucket", "db/obj", r.Header.Get("Range"))
+			if err == errGCSNotFound {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("X-Goog-Generation", strconv.FormatInt(generation, 10))
+			w.Write(data)
+		case r.Method == "POST" && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+			q := r.URL.Query()
+			assert.Equal("media", q.Get("uploadType"))
+			ifGeneration := int64(-1)
+			if g := q.Get("ifGenerationMatch"); g != "" {
+				ifGeneration, _ = strconv.ParseInt(g, 10, 64)
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			if gcs.PutObject("bucket", q.Get("name"), data, ifGeneration) == errGCSPreconditionFailed {
+				w.WriteHeader(http.StatusPreconditionFailed)
+			}
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	gc := gcsClient{http.DefaultClient, server.URL}
+	_, _, err := gc.GetObject("bucket", "db/obj", "")
+	assert.Equal(errGCSNotFound, err)
+
+	assert.NoError(gc.PutObject("bucket", "db/obj", []byte("hello"), 0))
+	data, generation, err := gc.GetObject("bucket", "db/obj", "")
+	assert.NoError(err)
+	assert.Equal("hello", string(data))
+	assert.Equal(int64(1), generation)
+
+	data, _, err = gc.GetObject("bucket", "db/obj", "bytes=-3")
+	assert.NoError(err)
+	assert.Equal("llo", string(data))
+
+	assert.Equal(errGCSPreconditionFailed, gc.PutObject("bucket", "db/obj", []byte("bye"), 0))
+	assert.NoError(gc.PutObject("bucket", "db/obj", []byte("bye"), 1))
+	assert.NoError(gc.PutObject("bucket", "db/obj", []byte("bye!"), -1))
+
+	_, _, err = gc.GetObject("bucket", "other", "")
+	assert.Error(err)
+	assert.NotEqual(errGCSNotFound, err)
+}
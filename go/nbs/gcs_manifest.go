@@ -0,0 +1,81 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"bytes"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// gcsManifest provides access to a NomsBlockStore manifest stored in the
+// object |prefix|manifest of |bucket| in Google Cloud Storage, in the format
+// of fileManifest. Updates are only written if the object hasn't changed
+// since it was read, so concurrent Updates are safe.
+type gcsManifest struct {
+	bucket, prefix string
+	gcs            gcssvc
+}
+
+func newGCSManifest(bucket, prefix string, gcs gcssvc) manifest {
+	return gcsManifest{bucket: bucket, prefix: prefix, gcs: gcs}
+}
+
+func (gm gcsManifest) ParseIfExists(readHook func()) (exists bool, vers string, lock addr, root hash.Hash, tableSpecs []tableSpec) {
+	if readHook != nil {
+		readHook()
+	}
+	// !exists(manifest) => unitialized store
+	if data, _ := gm.read(); data != nil {
+		exists = true
+		vers, lock, root, tableSpecs = parseManifest(bytes.NewReader(data))
+	}
+	return
+}
+
+// read returns the manifest object and its generation, or nil and 0 if it
+// doesn't exist.
+func (gm gcsManifest) read() ([]byte, int64) {
+	data, generation, err := gm.gcs.GetObject(gm.bucket, gm.prefix+manifestFileName, "")
+	if err == errGCSNotFound {
+		return nil, 0
+	}
+	d.PanicIfError(err)
+	return data, generation
+}
+
+func (gm gcsManifest) Update(lastLock, newLock addr, specs []tableSpec, newRoot hash.Hash, writeHook func()) (lock addr, actual hash.Hash, tableSpecs []tableSpec) {
+	buff := &bytes.Buffer{}
+	writeManifest(buff, newLock, newRoot, specs)
+
+	for {
+		data, generation := gm.read()
+		if data != nil {
+			var mVers string
+			mVers, lock, actual, tableSpecs = parseManifest(bytes.NewReader(data))
+			d.PanicIfFalse(constants.DataVersion() == mVers)
+		} else {
+			d.Chk.True(lastLock == addr{})
+		}
+		if lastLock != lock {
+			return lock, actual, tableSpecs
+		}
+
+		// writeHook is for testing, allowing other code to slip in and try to do stuff between our read and write.
+		if writeHook != nil {
+			writeHook()
+			writeHook = nil
+		}
+		err := gm.gcs.PutObject(gm.bucket, gm.prefix+manifestFileName, buff.Bytes(), generation)
+		if err == nil {
+			return newLock, newRoot, specs
+		} else if err != errGCSPreconditionFailed {
+			d.PanicIfError(err)
+		}
+		// The manifest changed since it was read, so read it again to see how.
+	}
+}
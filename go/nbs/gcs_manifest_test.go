@@ -0,0 +1,154 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+const gcsPrefix = "db/"
+
+type fakeGCSObject struct {
+	data       []byte
+	generation int64
+}
+
+// fakeGCS is a gcssvc of objects kept in memory.
+type fakeGCS struct {
+	mu          sync.Mutex
+	objects     map[string]fakeGCSObject
+	generations int64
+}
+
+func makeFakeGCS() *fakeGCS {
+	return &fakeGCS{objects: map[string]fakeGCSObject{}}
+}
+
+func (m *fakeGCS) GetObject(bucket, name, rangeHeader string) ([]byte, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[bucket+"/"+name]
+	if !ok {
+		return nil, 0, errGCSNotFound
+	}
+	data := obj.data
+	if rangeHeader != "" {
+		start, end := parseRange(rangeHeader, len(data))
+		data = data[start:end]
+	}
+	return data, obj.generation, nil
+}
+
+func (m *fakeGCS) PutObject(bucket, name string, data []byte, ifGeneration int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ifGeneration >= 0 && m.objects[bucket+"/"+name].generation != ifGeneration {
+		return errGCSPreconditionFailed
+	}
+	m.generations++
+	m.objects[bucket+"/"+name] = fakeGCSObject{append([]byte(nil), data...), m.generations}
+	return nil
+}
+
+// putGCSManifest simulates another process writing a manifest to gcs.
+func putGCSManifest(gcs *fakeGCS, vers string, lock addr, root hash.Hash, specs []tableSpec) {
+	buff := &bytes.Buffer{}
+	writeManifest(buff, lock, root, specs)
+	data := buff.Bytes()
+	if vers != constants.NomsVersion {
+		data = bytes.Replace(data, []byte(constants.NomsVersion), []byte(vers), 1)
+	}
+	gcs.PutObject("bucket", gcsPrefix+manifestFileName, data, -1)
+}
+
+func TestGCSManifestParseIfExists(t *testing.T) {
+	assert := assert.New(t)
+	gcs := makeFakeGCS()
+	mm := newGCSManifest("bucket", gcsPrefix, gcs)
+
+	exists, _, _, _, _ := mm.ParseIfExists(nil)
+	assert.False(exists)
+
+	// Simulate another process writing a manifest (with an old Noms version).
+	newLock := computeAddr([]byte("locker"))
+	newRoot := hash.Of([]byte("new root"))
+	tableName := computeAddr([]byte("table1"))
+	putGCSManifest(gcs, "0", newLock, newRoot, []tableSpec{{tableName, 0}})
+
+	// ParseIfExists should now reflect the manifest written above.
+	exists, vers, lock, root, tableSpecs := mm.ParseIfExists(nil)
+	assert.True(exists)
+	assert.Equal("0", vers)
+	assert.Equal(newLock, lock)
+	assert.Equal(newRoot, root)
+	assert.Equal([]tableSpec{{tableName, 0}}, tableSpecs)
+}
+
+func TestGCSManifestUpdateWontClobberOldVersion(t *testing.T) {
+	assert := assert.New(t)
+	gcs := makeFakeGCS()
+	mm := newGCSManifest("bucket", gcsPrefix, gcs)
+
+	// Simulate another process having already put old Noms data in the bucket.
+	lock := computeAddr([]byte("locker"))
+	putGCSManifest(gcs, "0", lock, hash.Of([]byte("bad root")), nil)
+
+	assert.Panics(func() { mm.Update(lock, addr{}, nil, hash.Hash{}, nil) })
+}
+
+func TestGCSManifestUpdate(t *testing.T) {
+	assert := assert.New(t)
+	gcs := makeFakeGCS()
+	mm := newGCSManifest("bucket", gcsPrefix, gcs)
+
+	newLock, newRoot := computeAddr([]byte("locker")), hash.Of([]byte("new root"))
+	specs := []tableSpec{{computeAddr([]byte("a")), 3}}
+	lock, actual, tableSpecs := mm.Update(addr{}, newLock, specs, newRoot, nil)
+	assert.Equal(newLock, lock)
+	assert.Equal(newRoot, actual)
+	assert.Equal(specs, tableSpecs)
+
+	// Now, test losing the race against another process which updates the
+	// manifest between the read and the write of the Update.
+	jerkLock, jerkRoot := computeAddr([]byte("jerk")), hash.Of([]byte("jerk root"))
+	newLock2, newRoot2 := computeAddr([]byte("locker 2")), hash.Of([]byte("new root 2"))
+	lock, actual, tableSpecs = mm.Update(newLock, newLock2, nil, newRoot2, func() {
+		putGCSManifest(gcs, constants.NomsVersion, jerkLock, jerkRoot, nil)
+	})
+	assert.Equal(jerkLock, lock)
+	assert.Equal(jerkRoot, actual)
+	assert.Empty(tableSpecs)
+
+	lock, actual, tableSpecs = mm.Update(lock, newLock2, nil, newRoot2, nil)
+	assert.Equal(newLock2, lock)
+	assert.Equal(newRoot2, actual)
+	assert.Empty(tableSpecs)
+}
+
+func TestGCSStore(t *testing.T) {
+	assert := assert.New(t)
+	gcs := makeFakeGCS()
+
+	store := newGCSStore("bucket", gcsPrefix, gcs, 1<<20)
+	c := chunks.NewChunk([]byte("abc"))
+	store.Put(c)
+	assert.True(store.UpdateRoot(c.Hash(), store.Root()))
+
+	for k := range gcs.objects {
+		assert.True(strings.HasPrefix(k, "bucket/"+gcsPrefix), k)
+	}
+
+	store = newGCSStore("bucket", gcsPrefix, gcs, 1<<20)
+	assert.Equal(c.Hash(), store.Root())
+	assert.Equal(c.Data(), store.Get(c.Hash()).Data())
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/verbose"
+)
+
+type gcsTablePersister struct {
+	gcs        gcssvc
+	bucket     string
+	prefix     string // of the names of tables
+	indexCache *indexCache
+	readRl     chan struct{}
+}
+
+func (gp gcsTablePersister) Open(name addr, chunkCount uint32) chunkSource {
+	return newGCSTableReader(gp.gcs, gp.bucket, gp.prefix, name, chunkCount, gp.indexCache, gp.readRl)
+}
+
+func (gp gcsTablePersister) Compact(mt *memTable, haver chunkReader) chunkSource {
+	return gp.persistTable(mt.write(haver))
+}
+
+func (gp gcsTablePersister) CompactAll(sources chunkSources) chunkSource {
+	return gp.persistTable(compactSourcesToBuffer(sources, gp.readRl))
+}
+
+func (gp gcsTablePersister) persistTable(name addr, data []byte, chunkCount uint32) chunkSource {
+	if chunkCount > 0 {
+		t1 := time.Now()
+		d.PanicIfError(gp.gcs.PutObject(gp.bucket, gp.prefix+name.String(), data, -1))
+		verbose.Debug("Compacted table", verbose.Fields{"kb": len(data) / 1024, "duration": time.Since(t1)})
+
+		gtr := &gcsTableReader{gcs: gp.gcs, bucket: gp.bucket, prefix: gp.prefix, h: name, readRl: gp.readRl}
+		index := parseTableIndex(data)
+		if gp.indexCache != nil {
+			gp.indexCache.put(gtr.loc(), name, index)
+		}
+		gtr.tableReader = newTableReader(index, gtr, gcsBlockSize)
+		return gtr
+	}
+	return emptyChunkSource{}
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// gcsBlockSize is the size of the reads of tables in Google Cloud Storage,
+// which are ranged requests as for S3.
+const gcsBlockSize = s3BlockSize
+
+type gcsTableReader struct {
+	tableReader
+	gcs    gcssvc
+	bucket string
+	prefix string
+	h      addr
+	readRl chan struct{}
+}
+
+func newGCSTableReader(gcs gcssvc, bucket, prefix string, h addr, chunkCount uint32, indexCache *indexCache, readRl chan struct{}) chunkSource {
+	source := &gcsTableReader{gcs: gcs, bucket: bucket, prefix: prefix, h: h, readRl: readRl}
+
+	var index tableIndex
+	found := false
+	if indexCache != nil {
+		index, found = indexCache.get(source.loc(), h)
+	}
+
+	if !found {
+		size := indexSize(chunkCount) + footerSize
+		buff := make([]byte, size)
+
+		n, err := source.readRange(buff, fmt.Sprintf("%s=-%d", s3RangePrefix, size))
+		d.PanicIfError(err)
+		d.PanicIfFalse(size == uint64(n))
+		index = parseTableIndex(buff)
+
+		if indexCache != nil {
+			indexCache.put(source.loc(), h, index)
+		}
+	}
+
+	source.tableReader = newTableReader(index, source, gcsBlockSize)
+	d.PanicIfFalse(chunkCount == source.count())
+	return source
+}
+
+// loc is where the table is kept, for the indexCache.
+func (gtr *gcsTableReader) loc() string {
+	return "gs://" + gtr.bucket + "/" + gtr.prefix
+}
+
+func (gtr *gcsTableReader) close() error {
+	return nil
+}
+
+func (gtr *gcsTableReader) hash() addr {
+	return gtr.h
+}
+
+func (gtr *gcsTableReader) ReadAt(p []byte, off int64) (n int, err error) {
+	end := off + int64(len(p)) - 1 // HTTP ranges are inclusive.
+	return gtr.readRange(p, fmt.Sprintf("%s=%d-%d", s3RangePrefix, off, end))
+}
+
+func (gtr *gcsTableReader) readRange(p []byte, rangeHeader string) (n int, err error) {
+	if gtr.readRl != nil {
+		gtr.readRl <- struct{}{}
+		defer func() {
+			<-gtr.readRl
+		}()
+	}
+
+	data, _, err := gtr.gcs.GetObject(gtr.bucket, gtr.prefix+gtr.hash().String(), rangeHeader)
+	d.PanicIfError(err)
+	d.PanicIfFalse(len(data) == len(p))
+	return copy(p, data), nil
+}
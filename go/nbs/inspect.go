@@ -0,0 +1,54 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import "github.com/attic-labs/noms/go/hash"
+
+// ManifestInfo is the contents of the manifest of a NomsBlockStore.
+type ManifestInfo struct {
+	StorageVersion string
+	NomsVersion    string
+	Lock           string
+	Root           hash.Hash
+	Tables         []TableInfo
+	// MaxTables is the number of tables above which all of them are conjoined
+	// into a single table on the next write.
+	MaxTables int
+}
+
+// TableInfo describes a table listed in the manifest of a NomsBlockStore.
+type TableInfo struct {
+	Name   string
+	Chunks uint32
+	// RawBytes is the length of the chunk data, CompressedBytes its length as
+	// stored in the table.
+	RawBytes        uint64
+	CompressedBytes uint64
+}
+
+// Inspect returns the ManifestInfo of nbs as of the last time it read or
+// wrote its manifest. Chunks which haven't been written to the manifest yet
+// aren't included.
+func (nbs *NomsBlockStore) Inspect() ManifestInfo {
+	nbs.mu.RLock()
+	defer nbs.mu.RUnlock()
+
+	info := ManifestInfo{
+		StorageVersion: StorageVersion,
+		NomsVersion:    nbs.nomsVersion,
+		Lock:           nbs.manifestLock.String(),
+		Root:           nbs.root,
+		MaxTables:      nbs.maxTables,
+	}
+	for _, src := range nbs.tables.upstream {
+		info.Tables = append(info.Tables, TableInfo{
+			Name:            src.hash().String(),
+			Chunks:          src.count(),
+			RawBytes:        src.uncompressedLen(),
+			CompressedBytes: src.compressedLen(),
+		})
+	}
+	return info
+}
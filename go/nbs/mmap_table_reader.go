@@ -54,7 +54,7 @@ func newMmapTableReader(dir string, h addr, chunkCount uint32, indexCache *index
 	var index tableIndex
 	found := false
 	if indexCache != nil {
-		index, found = indexCache.get(h)
+		index, found = indexCache.get(dir, h)
 	}
 
 	var buff []byte
@@ -69,7 +69,7 @@ func newMmapTableReader(dir string, h addr, chunkCount uint32, indexCache *index
 		index = parseTableIndex(buff[indexOffset-aligned:])
 
 		if indexCache != nil {
-			indexCache.put(h, index)
+			indexCache.put(dir, h, index)
 		}
 	}
 	success = true
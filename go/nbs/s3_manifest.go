@@ -0,0 +1,90 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Manifest provides access to a NomsBlockStore manifest stored in the
+// object |prefix|manifest of |bucket|, in the format of fileManifest.
+//
+// S3 can't write an object only if it's unchanged, so Update checks the lock
+// of the manifest it reads just before writing the new one, but two processes
+// updating the manifest at the same time can clobber each other's update.
+// Stores which have several writers at once need the DynamoDB manifest.
+type s3Manifest struct {
+	bucket, prefix string
+	s3             s3svc
+}
+
+func newS3Manifest(bucket, prefix string, s3 s3svc) manifest {
+	return s3Manifest{bucket: bucket, prefix: prefix, s3: s3}
+}
+
+func (sm s3Manifest) ParseIfExists(readHook func()) (exists bool, vers string, lock addr, root hash.Hash, tableSpecs []tableSpec) {
+	if readHook != nil {
+		readHook()
+	}
+	// !exists(manifest) => unitialized store
+	if r := sm.openIfExists(); r != nil {
+		defer checkClose(r)
+		exists = true
+		vers, lock, root, tableSpecs = parseManifest(r)
+	}
+	return
+}
+
+// openIfExists returns nil if the manifest object doesn't exist.
+func (sm s3Manifest) openIfExists() io.ReadCloser {
+	result, err := sm.s3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(sm.bucket),
+		Key:    aws.String(sm.prefix + manifestFileName),
+	})
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return nil
+	}
+	d.PanicIfError(err)
+	return result.Body
+}
+
+func (sm s3Manifest) Update(lastLock, newLock addr, specs []tableSpec, newRoot hash.Hash, writeHook func()) (lock addr, actual hash.Hash, tableSpecs []tableSpec) {
+	// writeHook is for testing, allowing other code to slip in and try to do stuff before we check the lock.
+	if writeHook != nil {
+		writeHook()
+	}
+
+	if r := sm.openIfExists(); r != nil {
+		var mVers string
+		func() {
+			defer checkClose(r)
+			mVers, lock, actual, tableSpecs = parseManifest(r)
+		}()
+		d.PanicIfFalse(constants.DataVersion() == mVers)
+	} else {
+		d.Chk.True(lastLock == addr{})
+	}
+
+	if lastLock != lock {
+		return lock, actual, tableSpecs
+	}
+	buff := &bytes.Buffer{}
+	writeManifest(buff, newLock, newRoot, specs)
+	_, err := sm.s3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(sm.bucket),
+		Key:    aws.String(sm.prefix + manifestFileName),
+		Body:   bytes.NewReader(buff.Bytes()),
+	})
+	d.PanicIfError(err)
+	return newLock, newRoot, specs
+}
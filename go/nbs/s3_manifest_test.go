@@ -0,0 +1,121 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+const s3Prefix = "db/"
+
+func makeS3ManifestFake(t *testing.T) (mm manifest, s3 *fakeS3) {
+	s3 = makeFakeS3(assert.New(t))
+	mm = newS3Manifest("bucket", s3Prefix, s3)
+	return
+}
+
+// putS3Manifest simulates another process writing a manifest to s3.
+func putS3Manifest(s3 *fakeS3, vers string, lock addr, root hash.Hash, specs []tableSpec) {
+	buff := &bytes.Buffer{}
+	writeManifest(buff, lock, root, specs)
+	data := buff.Bytes()
+	if vers != constants.NomsVersion {
+		data = bytes.Replace(data, []byte(constants.NomsVersion), []byte(vers), 1)
+	}
+	s3.data[s3Prefix+manifestFileName] = data
+}
+
+func TestS3ManifestParseIfExists(t *testing.T) {
+	assert := assert.New(t)
+	mm, s3 := makeS3ManifestFake(t)
+
+	exists, _, _, _, _ := mm.ParseIfExists(nil)
+	assert.False(exists)
+
+	// Simulate another process writing a manifest (with an old Noms version).
+	newLock := computeAddr([]byte("locker"))
+	newRoot := hash.Of([]byte("new root"))
+	tableName := computeAddr([]byte("table1"))
+	putS3Manifest(s3, "0", newLock, newRoot, []tableSpec{{tableName, 0}})
+
+	// ParseIfExists should now reflect the manifest written above.
+	exists, vers, lock, root, tableSpecs := mm.ParseIfExists(nil)
+	assert.True(exists)
+	assert.Equal("0", vers)
+	assert.Equal(newLock, lock)
+	assert.Equal(newRoot, root)
+	if assert.Len(tableSpecs, 1) {
+		assert.Equal(tableName.String(), tableSpecs[0].name.String())
+		assert.Equal(uint32(0), tableSpecs[0].chunkCount)
+	}
+}
+
+func TestS3ManifestUpdateWontClobberOldVersion(t *testing.T) {
+	assert := assert.New(t)
+	mm, s3 := makeS3ManifestFake(t)
+
+	// Simulate another process having already put old Noms data in the bucket.
+	lock := computeAddr([]byte("locker"))
+	putS3Manifest(s3, "0", lock, hash.Of([]byte("bad root")), nil)
+
+	assert.Panics(func() { mm.Update(lock, addr{}, nil, hash.Hash{}, nil) })
+}
+
+func TestS3ManifestUpdate(t *testing.T) {
+	assert := assert.New(t)
+	mm, s3 := makeS3ManifestFake(t)
+
+	newLock, newRoot := computeAddr([]byte("locker")), hash.Of([]byte("new root"))
+	specs := []tableSpec{{computeAddr([]byte("a")), 3}}
+	lock, actual, tableSpecs := mm.Update(addr{}, newLock, specs, newRoot, nil)
+	assert.Equal(newLock, lock)
+	assert.Equal(newRoot, actual)
+	assert.Equal(specs, tableSpecs)
+
+	// Now, test the case where the optimistic lock fails, because someone else
+	// updated the manifest before it was checked.
+	jerkLock, jerkRoot := computeAddr([]byte("jerk")), hash.Of([]byte("jerk root"))
+	newLock2, newRoot2 := computeAddr([]byte("locker 2")), hash.Of([]byte("new root 2"))
+	lock, actual, tableSpecs = mm.Update(newLock, newLock2, nil, newRoot2, func() {
+		putS3Manifest(s3, constants.NomsVersion, jerkLock, jerkRoot, nil)
+	})
+	assert.Equal(jerkLock, lock)
+	assert.Equal(jerkRoot, actual)
+	assert.Empty(tableSpecs)
+
+	lock, actual, tableSpecs = mm.Update(lock, newLock2, nil, newRoot2, nil)
+	assert.Equal(newLock2, lock)
+	assert.Equal(newRoot2, actual)
+	assert.Empty(tableSpecs)
+
+	exists, _, lock, actual, _ := mm.ParseIfExists(nil)
+	assert.True(exists)
+	assert.Equal(newLock2, lock)
+	assert.Equal(newRoot2, actual)
+}
+
+func TestS3Store(t *testing.T) {
+	assert := assert.New(t)
+	s3 := makeFakeS3(assert)
+
+	store := NewS3Store("bucket", s3Prefix, s3, 1<<20)
+	c := chunks.NewChunk([]byte("abc"))
+	store.Put(c)
+	assert.True(store.UpdateRoot(c.Hash(), store.Root()))
+
+	for k := range s3.data {
+		assert.Equal(s3Prefix, k[:len(s3Prefix)], k)
+	}
+
+	store = NewS3Store("bucket", s3Prefix, s3, 1<<20)
+	assert.Equal(c.Hash(), store.Root())
+	assert.Equal(c.Data(), store.Get(c.Hash()).Data())
+}
@@ -21,13 +21,14 @@ const defaultS3PartSize = 5 * 1 << 20 // 5MiB, smallest allowed by S3
 type s3TablePersister struct {
 	s3         s3svc
 	bucket     string
+	prefix     string // of the keys of tables
 	partSize   int
 	indexCache *indexCache
 	readRl     chan struct{}
 }
 
 func (s3p s3TablePersister) Open(name addr, chunkCount uint32) chunkSource {
-	return newS3TableReader(s3p.s3, s3p.bucket, name, chunkCount, s3p.indexCache, s3p.readRl)
+	return newS3TableReader(s3p.s3, s3p.bucket, s3p.prefix, name, chunkCount, s3p.indexCache, s3p.readRl)
 }
 
 type s3UploadedPart struct {
@@ -42,13 +43,13 @@ func (s3p s3TablePersister) Compact(mt *memTable, haver chunkReader) chunkSource
 func (s3p s3TablePersister) persistTable(name addr, data []byte, chunkCount uint32) chunkSource {
 	if chunkCount > 0 {
 		t1 := time.Now()
-		s3p.multipartUpload(data, name.String())
-		verbose.Log("Compacted table of %d Kb in %s", len(data)/1024, time.Since(t1))
+		s3p.multipartUpload(data, s3p.prefix+name.String())
+		verbose.Debug("Compacted table", verbose.Fields{"kb": len(data) / 1024, "duration": time.Since(t1)})
 
-		s3tr := &s3TableReader{s3: s3p.s3, bucket: s3p.bucket, h: name}
+		s3tr := &s3TableReader{s3: s3p.s3, bucket: s3p.bucket, prefix: s3p.prefix, h: name}
 		index := parseTableIndex(data)
 		if s3p.indexCache != nil {
-			s3p.indexCache.put(name, index)
+			s3p.indexCache.put(s3p.bucket+"/"+s3p.prefix, name, index)
 		}
 		s3tr.tableReader = newTableReader(index, s3tr, s3BlockSize)
 		return s3tr
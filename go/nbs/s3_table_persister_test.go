@@ -26,7 +26,7 @@ func TestS3TablePersisterCompact(t *testing.T) {
 	s3p := s3TablePersister{s3: s3svc, bucket: "bucket", partSize: calcPartSize(mt, 3), indexCache: cache}
 
 	src := s3p.Compact(mt, nil)
-	assert.NotNil(cache.get(src.hash()))
+	assert.NotNil(cache.get("bucket/", src.hash()))
 
 	if assert.True(src.count() > 0) {
 		if r := s3svc.readerForTable(src.hash()); assert.NotNil(r) {
@@ -125,7 +125,7 @@ func TestS3TablePersisterCompactAll(t *testing.T) {
 
 	s3p := s3TablePersister{s3: s3svc, bucket: "bucket", partSize: 128, indexCache: cache, readRl: rl}
 	src := s3p.CompactAll(sources)
-	assert.NotNil(cache.get(src.hash()))
+	assert.NotNil(cache.get("bucket/", src.hash()))
 
 	if assert.True(src.count() > 0) {
 		if r := s3svc.readerForTable(src.hash()); assert.NotNil(r) {
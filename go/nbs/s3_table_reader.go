@@ -14,6 +14,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/jpillora/backoff"
@@ -28,6 +29,7 @@ type s3TableReader struct {
 	tableReader
 	s3     s3svc
 	bucket string
+	prefix string
 	h      addr
 	readRl chan struct{}
 }
@@ -41,13 +43,13 @@ type s3svc interface {
 	PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
 }
 
-func newS3TableReader(s3 s3svc, bucket string, h addr, chunkCount uint32, indexCache *indexCache, readRl chan struct{}) chunkSource {
-	source := &s3TableReader{s3: s3, bucket: bucket, h: h, readRl: readRl}
+func newS3TableReader(s3 s3svc, bucket, prefix string, h addr, chunkCount uint32, indexCache *indexCache, readRl chan struct{}) chunkSource {
+	source := &s3TableReader{s3: s3, bucket: bucket, prefix: prefix, h: h, readRl: readRl}
 
 	var index tableIndex
 	found := false
 	if indexCache != nil {
-		index, found = indexCache.get(h)
+		index, found = indexCache.get(bucket+"/"+prefix, h)
 	}
 
 	if !found {
@@ -60,7 +62,7 @@ func newS3TableReader(s3 s3svc, bucket string, h addr, chunkCount uint32, indexC
 		index = parseTableIndex(buff)
 
 		if indexCache != nil {
-			indexCache.put(h, index)
+			indexCache.put(bucket+"/"+prefix, h, index)
 		}
 	}
 
@@ -94,7 +96,7 @@ func (s3tr *s3TableReader) readRange(p []byte, rangeHeader string) (n int, err e
 
 		input := &s3.GetObjectInput{
 			Bucket: aws.String(s3tr.bucket),
-			Key:    aws.String(s3tr.hash().String()),
+			Key:    aws.String(s3tr.prefix + s3tr.hash().String()),
 			Range:  aws.String(rangeHeader),
 		}
 		result, err := s3tr.s3.GetObject(input)
@@ -103,7 +105,7 @@ func (s3tr *s3TableReader) readRange(p []byte, rangeHeader string) (n int, err e
 
 		n, err := io.ReadFull(result.Body, p)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed ranged read from S3\n%s\nerr type: %T\nerror: %v\n", input.GoString(), err, err)
+			verbose.Warn("Failed ranged read from S3", verbose.Fields{"input": input.GoString(), "error": err, "errorType": fmt.Sprintf("%T", err)})
 		}
 		return n, err
 	}
@@ -119,7 +121,7 @@ func (s3tr *s3TableReader) readRange(p []byte, rangeHeader string) (n int, err e
 		}
 		for ; isConnReset(err); n, err = read() {
 			dur := b.Duration()
-			fmt.Fprintf(os.Stderr, "Retrying S3 read in %s\n", dur.String())
+			verbose.Warn("Retrying S3 read", verbose.Fields{"in": dur})
 			time.Sleep(dur)
 		}
 	}
@@ -0,0 +1,41 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+// StoreStats summarizes the chunks held by a NomsBlockStore.
+type StoreStats struct {
+	Tables int
+	Chunks uint64
+	// RawBytes is the length of the chunk data.
+	RawBytes uint64
+	// CompressedBytes is the length of the chunk data as stored in the tables,
+	// which are snappy compressed. Chunks which haven't been written to a
+	// table yet aren't included.
+	CompressedBytes uint64
+}
+
+// Stats returns the StoreStats of nbs. Chunks stored in more than one table
+// are counted more than once.
+func (nbs *NomsBlockStore) Stats() (stats StoreStats) {
+	nbs.mu.RLock()
+	defer nbs.mu.RUnlock()
+
+	if nbs.mt != nil {
+		stats.Chunks += uint64(nbs.mt.count())
+		stats.RawBytes += nbs.mt.uncompressedLen()
+	}
+	for _, css := range []chunkSources{nbs.tables.novel, nbs.tables.upstream} {
+		for _, src := range css {
+			if src.count() == 0 {
+				continue
+			}
+			stats.Tables++
+			stats.Chunks += uint64(src.count())
+			stats.RawBytes += src.uncompressedLen()
+			stats.CompressedBytes += src.compressedLen()
+		}
+	}
+	return
+}
@@ -6,6 +6,7 @@ package nbs
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"sort"
@@ -16,6 +17,7 @@ import (
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/metrics"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -128,7 +130,32 @@ func NewAWSStore(table, ns, bucket string, s3 s3svc, ddb ddbsvc, memTableSize ui
 func newAWSStore(table, ns, bucket string, s3 s3svc, ddb ddbsvc, memTableSize uint64, indexCache *indexCache, readRl chan struct{}) *NomsBlockStore {
 	d.PanicIfTrue(ns == "")
 	mm := newDynamoManifest(table, ns, ddb)
-	ts := newS3TableSet(s3, bucket, indexCache, readRl)
+	ts := newS3TableSet(s3, bucket, "", indexCache, readRl)
+	return newNomsBlockStore(mm, ts, memTableSize, defaultMaxTables)
+}
+
+// NewS3Store returns a store whose tables and manifest are both kept in
+// bucket, under the keys prefix<name> and prefix"manifest". The manifest can't
+// be updated safely by several processes at once, see s3Manifest.
+func NewS3Store(bucket, prefix string, s3 s3svc, memTableSize uint64) *NomsBlockStore {
+	indexCacheOnce.Do(makeGlobalIndexCache)
+	mm := newS3Manifest(bucket, prefix, s3)
+	ts := newS3TableSet(s3, bucket, prefix, globalIndexCache, make(chan struct{}, 32))
+	return newNomsBlockStore(mm, ts, memTableSize, defaultMaxTables)
+}
+
+// NewGCSStore returns a store whose tables and manifest are kept in the
+// Google Cloud Storage bucket, under the names prefix<name> and
+// prefix"manifest". Its requests are sent with client, which is expected to
+// authenticate them, see googleauth.
+func NewGCSStore(bucket, prefix string, client *http.Client, memTableSize uint64) *NomsBlockStore {
+	return newGCSStore(bucket, prefix, newGCSClient(client), memTableSize)
+}
+
+func newGCSStore(bucket, prefix string, gcs gcssvc, memTableSize uint64) *NomsBlockStore {
+	indexCacheOnce.Do(makeGlobalIndexCache)
+	mm := newGCSManifest(bucket, prefix, gcs)
+	ts := newGCSTableSet(gcs, bucket, prefix, globalIndexCache, make(chan struct{}, 32))
 	return newNomsBlockStore(mm, ts, memTableSize, defaultMaxTables)
 }
 
@@ -150,7 +177,7 @@ func newNomsBlockStore(mm manifest, ts tableSet, memTableSize uint64, maxTables
 	nbs := &NomsBlockStore{
 		mm:          mm,
 		tables:      ts,
-		nomsVersion: constants.NomsVersion,
+		nomsVersion: constants.DataVersion(),
 		mtSize:      memTableSize,
 		maxTables:   maxTables,
 	}
@@ -167,6 +194,7 @@ func (nbs *NomsBlockStore) Put(c chunks.Chunk) {
 	a := addr(c.Hash())
 	d.PanicIfFalse(nbs.addChunk(a, c.Data()))
 	nbs.putCount++
+	metrics.Add("nbs_chunk_writes", 1)
 }
 
 func (nbs *NomsBlockStore) SchedulePut(c chunks.Chunk) {
@@ -195,6 +223,7 @@ func (nbs *NomsBlockStore) addChunk(h addr, data []byte) bool {
 }
 
 func (nbs *NomsBlockStore) Get(h hash.Hash) chunks.Chunk {
+	metrics.Add("nbs_chunk_reads", 1)
 	a := addr(h)
 	data, tables := func() (data []byte, tables chunkReader) {
 		nbs.mu.RLock()
@@ -214,6 +243,7 @@ func (nbs *NomsBlockStore) Get(h hash.Hash) chunks.Chunk {
 }
 
 func (nbs *NomsBlockStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	metrics.Add("nbs_chunk_reads", int64(len(hashes)))
 	reqs := toGetRecords(hashes)
 
 	wg := &sync.WaitGroup{}
@@ -386,6 +416,7 @@ var (
 )
 
 func (nbs *NomsBlockStore) updateManifest(current, last hash.Hash) error {
+	defer metrics.Timer("nbs_flush")()
 	nbs.mu.Lock()
 	defer nbs.mu.Unlock()
 	if nbs.root != last {
@@ -400,7 +431,9 @@ func (nbs *NomsBlockStore) updateManifest(current, last hash.Hash) error {
 	candidate := nbs.tables
 	var compactees chunkSources
 	if candidate.Size() > nbs.maxTables {
+		stop := metrics.Timer("nbs_conjoin")
 		candidate, compactees = candidate.Compact() // Compact() must only compact upstream tables (BUG 3142)
+		stop()
 	}
 
 	specs := candidate.ToSpecs()
@@ -424,7 +457,7 @@ func (nbs *NomsBlockStore) updateManifest(current, last hash.Hash) error {
 
 	nbs.tables = candidate.Flatten()
 	compactees.close()
-	nbs.nomsVersion, nbs.manifestLock, nbs.root = constants.NomsVersion, lock, current
+	nbs.nomsVersion, nbs.manifestLock, nbs.root = constants.DataVersion(), lock, current
 	return nil
 }
 
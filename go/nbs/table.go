@@ -214,6 +214,7 @@ type chunkSource interface {
 	chunkReader
 	close() error
 	hash() addr
+	compressedLen() uint64
 	calcReads(reqs []getRecord, blockSize uint64) (reads int, remaining bool)
 }
 
@@ -23,13 +23,22 @@ type indexCache struct {
 	cache *sizecache.SizeCache
 }
 
-// Returns an indexCache which will burn roughly |size| bytes of memory
+// indexCacheKey identifies a table by where it's kept as well as its name.
+// Table names only depend on the chunks in a table, not their order, so two
+// stores can have tables of the same name with different indices.
+type indexCacheKey struct {
+	loc  string
+	name addr
+}
+
+// Returns an indexCache which will burn roughly |size| bytes of memory, and
+// no more than is left of sizecache.GetBudget(), as "nbs_index_cache".
 func newIndexCache(size uint64) *indexCache {
-	return &indexCache{sizecache.New(size)}
+	return &indexCache{sizecache.NewWithBudget(size, sizecache.GetBudget(), "nbs_index_cache")}
 }
 
-func (sic indexCache) get(name addr) (tableIndex, bool) {
-	idx, found := sic.cache.Get(name)
+func (sic indexCache) get(loc string, name addr) (tableIndex, bool) {
+	idx, found := sic.cache.Get(indexCacheKey{loc, name})
 	if found {
 		return idx.(tableIndex), true
 	}
@@ -37,9 +46,13 @@ func (sic indexCache) get(name addr) (tableIndex, bool) {
 	return tableIndex{}, false
 }
 
-func (sic indexCache) put(name addr, idx tableIndex) {
+func (sic indexCache) put(loc string, name addr, idx tableIndex) {
 	indexSize := uint64(idx.chunkCount) * (addrSize + ordinalSize + lengthSize + uint64Size)
-	sic.cache.Add(name, indexSize, idx)
+	sic.cache.Add(indexCacheKey{loc, name}, indexSize, idx)
+}
+
+func (sic indexCache) drop(loc string, name addr) {
+	sic.cache.Drop(indexCacheKey{loc, name})
 }
 
 type chunkSourcesByDescendingCount chunkSources
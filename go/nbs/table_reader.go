@@ -192,6 +192,14 @@ func (tr tableReader) uncompressedLen() uint64 {
 	return tr.totalUncompressedData
 }
 
+// compressedLen returns the length of the chunk data in the table, as stored.
+func (tr tableReader) compressedLen() (l uint64) {
+	for _, length := range tr.lengths {
+		l += uint64(length)
+	}
+	return
+}
+
 // returns true iff |h| can be found in this table.
 func (tr tableReader) has(h addr) bool {
 	ordinal := tr.lookupOrdinal(h)
@@ -14,9 +14,16 @@ import (
 
 const concurrentCompactions = 5
 
-func newS3TableSet(s3 s3svc, bucket string, indexCache *indexCache, readRl chan struct{}) tableSet {
+func newS3TableSet(s3 s3svc, bucket, prefix string, indexCache *indexCache, readRl chan struct{}) tableSet {
 	return tableSet{
-		p:  s3TablePersister{s3, bucket, defaultS3PartSize, indexCache, readRl},
+		p:  s3TablePersister{s3, bucket, prefix, defaultS3PartSize, indexCache, readRl},
+		rl: make(chan struct{}, concurrentCompactions),
+	}
+}
+
+func newGCSTableSet(gcs gcssvc, bucket, prefix string, indexCache *indexCache, readRl chan struct{}) tableSet {
+	return tableSet{
+		p:  gcsTablePersister{gcs, bucket, prefix, indexCache, readRl},
 		rl: make(chan struct{}, concurrentCompactions),
 	}
 }
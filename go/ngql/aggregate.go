@@ -0,0 +1,241 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"context"
+
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Collections of Numbers and Structs, and Maps with such values, have an
+// "aggregate" field which is computed on the server by streaming through the
+// collection:
+//
+// type NumberAggregate {
+//   count: Float!
+//   sum: Float!
+//   min: Float
+//   max: Float
+//   avg: Float
+// }
+//
+// For Struct elements there is one NumberAggregate per Number field:
+//
+// type FooAggregate {
+//   count: Float!
+//   age: NumberAggregate!
+// }
+//
+// min, max and avg are null if there were no values. The aggregate field takes
+// the same "where" argument as the elements of the collection.
+
+const (
+	aggregateKey            = "aggregate"
+	aggregateTypeNameSuffix = "Aggregate"
+	avgKey                  = "avg"
+	maxKey                  = "max"
+	minKey                  = "min"
+	numberAggregateTypeName = "NumberAggregate"
+	sumKey                  = "sum"
+)
+
+type numberAggregate struct {
+	count, sum, min, max float64
+}
+
+func (a *numberAggregate) add(n float64) {
+	if a.count == 0 || n < a.min {
+		a.min = n
+	}
+	if a.count == 0 || n > a.max {
+		a.max = n
+	}
+	a.count++
+	a.sum += n
+}
+
+func (a numberAggregate) optional(v float64) interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	return v
+}
+
+type structAggregate struct {
+	count  float64
+	fields map[string]*numberAggregate
+}
+
+func (a *structAggregate) add(s types.Struct) {
+	a.count++
+	for name, fa := range a.fields {
+		if n, ok := s.MaybeGet(name); ok {
+			if n, ok := n.(types.Number); ok {
+				fa.add(float64(n))
+			}
+		}
+	}
+}
+
+// aggregatedType returns the type of the values aggregated over for the
+// collection type nomsType; the values for Maps.
+func aggregatedType(nomsType *types.Type) *types.Type {
+	elemTypes := nomsType.Desc.(types.CompoundDesc).ElemTypes
+	return elemTypes[len(elemTypes)-1]
+}
+
+func numberFieldNames(nomsType *types.Type) []string {
+	names := []string{}
+	nomsType.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
+		if t.TargetKind() == types.NumberKind {
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+func getAggregate(ctx context.Context, c types.Collection, nomsType *types.Type, args map[string]interface{}) interface{} {
+	valueOf := func(k, v types.Value) types.Value {
+		if v != nil {
+			return v
+		}
+		return k
+	}
+
+	if aggregatedType(nomsType).TargetKind() == types.NumberKind {
+		a := &numberAggregate{}
+		iterFiltered(ctx, c, nomsType, args, func(k, v types.Value) bool {
+			a.add(float64(valueOf(k, v).(types.Number)))
+			return false
+		})
+		return a
+	}
+
+	a := &structAggregate{fields: map[string]*numberAggregate{}}
+	for _, name := range numberFieldNames(aggregatedType(nomsType)) {
+		a.fields[name] = &numberAggregate{}
+	}
+	iterFiltered(ctx, c, nomsType, args, func(k, v types.Value) bool {
+		a.add(valueOf(k, v).(types.Struct))
+		return false
+	})
+	return a
+}
+
+func (tc *TypeConverter) numberAggregateToGraphQLObject() graphql.Type {
+	key := typeMapKey{numberAggregateTypeName, false}
+	if t, ok := tc.tm[key]; ok {
+		return t
+	}
+
+	t := newObject(graphql.ObjectConfig{
+		Name: numberAggregateTypeName,
+		Fields: graphql.Fields{
+			countKey: &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*numberAggregate).count, nil
+				},
+			},
+			sumKey: &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*numberAggregate).sum, nil
+				},
+			},
+			minKey: &graphql.Field{
+				Type: graphql.Float,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					a := p.Source.(*numberAggregate)
+					return a.optional(a.min), nil
+				},
+			},
+			maxKey: &graphql.Field{
+				Type: graphql.Float,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					a := p.Source.(*numberAggregate)
+					return a.optional(a.max), nil
+				},
+			},
+			avgKey: &graphql.Field{
+				Type: graphql.Float,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					a := p.Source.(*numberAggregate)
+					if a.count == 0 {
+						return nil, nil
+					}
+					return a.sum / a.count, nil
+				},
+			},
+		},
+	})
+	tc.tm[key] = t
+	return t
+}
+
+func (tc *TypeConverter) structAggregateToGraphQLObject(nomsType *types.Type) graphql.Type {
+	key := typeMapKey{tc.getTypeName(nomsType) + aggregateTypeNameSuffix, false}
+	if t, ok := tc.tm[key]; ok {
+		return t
+	}
+
+	fields := graphql.Fields{
+		countKey: &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Float),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*structAggregate).count, nil
+			},
+		},
+	}
+	numberAggregateType := graphql.NewNonNull(tc.numberAggregateToGraphQLObject())
+	for _, name := range numberFieldNames(nomsType) {
+		name := name
+		fields[name] = &graphql.Field{
+			Type: numberAggregateType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*structAggregate).fields[name], nil
+			},
+		}
+	}
+
+	t := newObject(graphql.ObjectConfig{
+		Name:   key.name,
+		Fields: fields,
+	})
+	tc.tm[key] = t
+	return t
+}
+
+// aggregateField creates the aggregate field for the collection type
+// nomsType, or returns nil if its values cannot be aggregated.
+func (tc *TypeConverter) aggregateField(nomsType *types.Type, args graphql.FieldConfigArgument) *graphql.Field {
+	if tc.isCustomScalar(aggregatedType(nomsType)) {
+		return nil
+	}
+
+	var t graphql.Type
+	switch vt := aggregatedType(nomsType); vt.TargetKind() {
+	case types.NumberKind:
+		t = tc.numberAggregateToGraphQLObject()
+	case types.StructKind:
+		t = tc.structAggregateToGraphQLObject(vt)
+	default:
+		return nil
+	}
+
+	fieldArgs := graphql.FieldConfigArgument{}
+	if where, ok := args[whereKey]; ok {
+		fieldArgs[whereKey] = where
+	}
+	return &graphql.Field{
+		Type: graphql.NewNonNull(t),
+		Args: fieldArgs,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return getAggregate(p.Context, p.Source.(types.Collection), nomsType, p.Args), nil
+		},
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// SetTypeName makes tc use name as the GraphQL type name of nomsType instead
+// of the name computed by NameFunc. The name is also used to name the
+// collections, refs and unions nomsType is part of, and with an "Input" suffix
+// for the input type. Since the default names of struct types include a hash
+// of the type, this can be used to keep a schema stable as types evolve.
+func (tc *TypeConverter) SetTypeName(nomsType *types.Type, name string) {
+	if tc.typeNames == nil {
+		tc.typeNames = map[hash.Hash]string{}
+	}
+	tc.typeNames[nomsType.Hash()] = name
+}
+
+// RegisterScalar makes tc represent values of nomsType as the custom GraphQL
+// scalar, both in output and input types. scalar's Serialize function is
+// passed the value to output; a Go bool, float64 or string for Noms Bool,
+// Number and String, and the types.Value otherwise. Its ParseValue and
+// ParseLiteral functions must return a types.Value of type nomsType.
+//
+// The name of the scalar is used as the type name of nomsType, see
+// SetTypeName.
+func (tc *TypeConverter) RegisterScalar(nomsType *types.Type, scalar *graphql.Scalar) {
+	if tc.scalars == nil {
+		tc.scalars = map[hash.Hash]*graphql.Scalar{}
+	}
+	tc.scalars[nomsType.Hash()] = scalar
+	tc.SetTypeName(nomsType, scalar.Name())
+}
+
+func (tc *TypeConverter) isCustomScalar(nomsType *types.Type) bool {
+	_, ok := tc.scalars[nomsType.Hash()]
+	return ok
+}
+
+func (tc *TypeConverter) typeName(nomsType *types.Type, isInputType bool) string {
+	suffix := ""
+	if isInputType {
+		suffix = "Input"
+	}
+	if name, ok := tc.typeNames[nomsType.Hash()]; ok {
+		if tc.isCustomScalar(nomsType) {
+			// Scalars are used as input types too.
+			return name
+		}
+		return name + suffix
+	}
+
+	if len(tc.typeNames) > 0 {
+		switch nomsType.TargetKind() {
+		case types.ListKind, types.SetKind, types.MapKind, types.RefKind, types.UnionKind:
+			return composeTypeName(nomsType, suffix, tc.getTypeName)
+		}
+	}
+	return tc.NameFunc(nomsType, isInputType)
+}
@@ -0,0 +1,350 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Collection fields support a "where" argument which only returns the
+// elements matching a filter. The type of the filter depends on the element
+// type:
+//
+// input NumberFilter {
+//   eq: Float
+//   ne: Float
+//   lt: Float
+//   lte: Float
+//   gt: Float
+//   gte: Float
+// }
+//
+// StringFilter has the same comparisons plus "prefix", and BooleanFilter only
+// supports "eq" and "ne". Structs are filtered by their scalar fields, e.g.
+//
+// input FooFilter {
+//   name: StringFilter
+//   age: NumberFilter
+// }
+//
+// and map entries by key and value:
+//
+// input StringToNumberMapFilter {
+//   key: StringFilter
+//   value: NumberFilter
+// }
+//
+// All conditions must match. When a Set or a Map is filtered on its own
+// Number or String keys only the matching key range is scanned, everything
+// else is evaluated by streaming through the collection. "where" can be
+// combined with "count" to limit the number of matching elements returned;
+// the other collection arguments are ignored.
+
+const (
+	whereKey  = "where"
+	eqKey     = "eq"
+	neKey     = "ne"
+	ltKey     = "lt"
+	lteKey    = "lte"
+	gtKey     = "gt"
+	gteKey    = "gte"
+	prefixKey = "prefix"
+)
+
+type filter interface {
+	matches(v types.Value) bool
+}
+
+// scalarFilter compares a Number, String or Bool against the bounds given in
+// the filter input.
+type scalarFilter struct {
+	eq, ne, lt, lte, gt, gte types.Value
+	prefix                   *string
+}
+
+func (f scalarFilter) matches(v types.Value) bool {
+	if f.eq != nil && !f.eq.Equals(v) {
+		return false
+	}
+	if f.ne != nil && f.ne.Equals(v) {
+		return false
+	}
+	if f.lt != nil && !v.Less(f.lt) {
+		return false
+	}
+	if f.lte != nil && f.lte.Less(v) {
+		return false
+	}
+	if f.gt != nil && !f.gt.Less(v) {
+		return false
+	}
+	if f.gte != nil && v.Less(f.gte) {
+		return false
+	}
+	if f.prefix != nil {
+		s, ok := v.(types.String)
+		if !ok || !strings.HasPrefix(string(s), *f.prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerBound returns the smallest value that can match, or nil.
+func (f scalarFilter) lowerBound() types.Value {
+	var lb types.Value
+	for _, v := range []types.Value{f.eq, f.gt, f.gte} {
+		if v != nil && (lb == nil || lb.Less(v)) {
+			lb = v
+		}
+	}
+	if f.prefix != nil {
+		if p := types.String(*f.prefix); lb == nil || lb.Less(p) {
+			lb = p
+		}
+	}
+	return lb
+}
+
+// pastUpperBound returns true if v and all values after it cannot match.
+func (f scalarFilter) pastUpperBound(v types.Value) bool {
+	if f.eq != nil && f.eq.Less(v) {
+		return true
+	}
+	if f.lt != nil && !v.Less(f.lt) {
+		return true
+	}
+	if f.lte != nil && f.lte.Less(v) {
+		return true
+	}
+	if f.prefix != nil {
+		p := types.String(*f.prefix)
+		if s, ok := v.(types.String); ok && p.Less(s) && !strings.HasPrefix(string(s), *f.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type structFilter map[string]filter
+
+func (f structFilter) matches(v types.Value) bool {
+	s, ok := v.(types.Struct)
+	if !ok {
+		return false
+	}
+	for name, ff := range f {
+		fv, ok := s.MaybeGet(name)
+		if !ok || !ff.matches(fv) {
+			return false
+		}
+	}
+	return true
+}
+
+type entryFilter struct {
+	key, value filter
+}
+
+func (f entryFilter) matches(k, v types.Value) bool {
+	return (f.key == nil || f.key.matches(k)) && (f.value == nil || f.value.matches(v))
+}
+
+func isFilterableScalar(nomsType *types.Type) bool {
+	switch nomsType.TargetKind() {
+	case types.BoolKind, types.NumberKind, types.StringKind:
+		return true
+	}
+	return false
+}
+
+// makeFilter converts the where argument to a filter for values of type
+// nomsType.
+func makeFilter(arg interface{}, nomsType *types.Type) filter {
+	m, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	switch nomsType.TargetKind() {
+	case types.BoolKind, types.NumberKind, types.StringKind:
+		f := scalarFilter{}
+		for k, v := range m {
+			if v == nil {
+				continue
+			}
+			if k == prefixKey {
+				s := v.(string)
+				f.prefix = &s
+				continue
+			}
+			nv := InputToNomsValue(v, nomsType)
+			switch k {
+			case eqKey:
+				f.eq = nv
+			case neKey:
+				f.ne = nv
+			case ltKey:
+				f.lt = nv
+			case lteKey:
+				f.lte = nv
+			case gtKey:
+				f.gt = nv
+			case gteKey:
+				f.gte = nv
+			}
+		}
+		return f
+	case types.StructKind:
+		f := structFilter{}
+		nomsType.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
+			if ff := makeFilter(m[name], t); ff != nil {
+				f[name] = ff
+			}
+		})
+		return f
+	}
+	return nil
+}
+
+// makeEntryFilter converts the where argument of a Map of type nomsType to a
+// filter on its entries.
+func makeEntryFilter(arg interface{}, nomsType *types.Type) entryFilter {
+	m, _ := arg.(map[string]interface{})
+	elemTypes := nomsType.Desc.(types.CompoundDesc).ElemTypes
+	return entryFilter{makeFilter(m[keyKey], elemTypes[0]), makeFilter(m[valueKey], elemTypes[1])}
+}
+
+// filterInputType returns the GraphQL input type used for the where argument
+// of values of type nomsType, or nil if such values cannot be filtered.
+func (tc *TypeConverter) filterInputType(nomsType *types.Type) graphql.Input {
+	if tc.isCustomScalar(nomsType) {
+		return nil
+	}
+
+	var name string
+	switch nomsType.TargetKind() {
+	case types.BoolKind, types.NumberKind, types.StringKind:
+		name = getTypeName(nomsType, "") + "Filter"
+	case types.StructKind, types.MapKind:
+		name = tc.getTypeName(nomsType) + "Filter"
+	default:
+		return nil
+	}
+
+	key := typeMapKey{name, false}
+	if t, ok := tc.tm[key]; ok {
+		if t == nil {
+			return nil
+		}
+		return t.(graphql.Input)
+	}
+
+	fields := graphql.InputObjectConfigFieldMap{}
+	switch nomsType.TargetKind() {
+	case types.BoolKind:
+		fields[eqKey] = &graphql.InputObjectFieldConfig{Type: graphql.Boolean}
+		fields[neKey] = &graphql.InputObjectFieldConfig{Type: graphql.Boolean}
+	case types.NumberKind, types.StringKind:
+		scalar := graphql.Float
+		if nomsType.TargetKind() == types.StringKind {
+			scalar = graphql.String
+			fields[prefixKey] = &graphql.InputObjectFieldConfig{Type: graphql.String}
+		}
+		for _, k := range []string{eqKey, neKey, ltKey, lteKey, gtKey, gteKey} {
+			fields[k] = &graphql.InputObjectFieldConfig{Type: scalar}
+		}
+	case types.StructKind:
+		nomsType.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
+			if isFilterableScalar(t) && !tc.isCustomScalar(t) {
+				fields[name] = &graphql.InputObjectFieldConfig{Type: tc.filterInputType(t)}
+			}
+		})
+	case types.MapKind:
+		elemTypes := nomsType.Desc.(types.CompoundDesc).ElemTypes
+		if kt := tc.filterInputType(elemTypes[0]); kt != nil {
+			fields[keyKey] = &graphql.InputObjectFieldConfig{Type: kt}
+		}
+		if vt := tc.filterInputType(elemTypes[1]); vt != nil {
+			fields[valueKey] = &graphql.InputObjectFieldConfig{Type: vt}
+		}
+	}
+
+	if len(fields) == 0 {
+		tc.tm[key] = nil
+		return nil
+	}
+	t := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   name,
+		Fields: fields,
+	})
+	tc.tm[key] = t
+	return t
+}
+
+// hasBounds returns true if f restricts the values to a range, which can be
+// scanned instead of all values.
+func (f scalarFilter) hasBounds() bool {
+	return f.lowerBound() != nil || f.lt != nil || f.lte != nil
+}
+
+// rangeFilter returns the scalar filter to use for a range scan of a Set or
+// Map with keys of type keyType, or nil if the collection has to be scanned.
+func rangeFilter(f filter, keyType *types.Type) *scalarFilter {
+	if keyType.TargetKind() != types.NumberKind && keyType.TargetKind() != types.StringKind {
+		return nil
+	}
+	if sf, ok := f.(scalarFilter); ok && sf.hasBounds() {
+		return &sf
+	}
+	return nil
+}
+
+func getFilteredCount(args map[string]interface{}) (count int, limited bool) {
+	if c, ok := args[countKey].(int); ok {
+		if c < 0 {
+			c = 0
+		}
+		return c, true
+	}
+	return 0, false
+}
+
+// getFilteredElements returns the elements, or for Maps the entries appended
+// by app, which match the where argument in args.
+func getFilteredElements(ctx context.Context, c types.Collection, nomsType *types.Type, args map[string]interface{}, app mapAppender) (interface{}, error) {
+	count, limited := getFilteredCount(args)
+	values := []interface{}{}
+	if limited && count == 0 {
+		return values, nil
+	}
+	var err error
+	iterFiltered(ctx, c, nomsType, args, func(k, v types.Value) bool {
+		if err = chargeBudget(ctx, 1); err != nil {
+			return true
+		}
+		if app != nil {
+			values = app(values, k, v)
+		} else {
+			values = append(values, MaybeGetScalar(k))
+		}
+		return limited && len(values) == count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func lowerBoundOf(rf *scalarFilter) types.Value {
+	if rf == nil {
+		return nil
+	}
+	return rf.lowerBound()
+}
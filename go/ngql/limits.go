@@ -0,0 +1,210 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/graphql/language/ast"
+	"github.com/attic-labs/graphql/language/parser"
+)
+
+// Limits bounds the work done to execute a single query. A zero value for any
+// of the fields means there is no limit.
+type Limits struct {
+	// MaxDepth is the maximum nesting of fields in the query, including the
+	// fields of fragments. Queries which are nested deeper are rejected
+	// without being executed.
+	MaxDepth int
+	// MaxNodes is the maximum number of fields resolved, and of elements of
+	// collections returned, while executing the query. Fields resolved after
+	// the limit is reached are null.
+	MaxNodes int64
+	// Timeout is the time budget for executing the query. Fields resolved
+	// after it has elapsed are null.
+	Timeout time.Duration
+}
+
+// DefaultLimits are the limits used by Query.
+var DefaultLimits = Limits{
+	MaxDepth: 64,
+	MaxNodes: 1 << 20,
+	Timeout:  30 * time.Second,
+}
+
+const (
+	depthLimit = "depth"
+	nodesLimit = "nodes"
+	timeLimit  = "time"
+)
+
+// contextKey is the type of the keys of the values ngql stores in the
+// context of a query, so that they can't collide with those of other
+// packages.
+type contextKey string
+
+const limitsKey contextKey = "limits"
+
+// LimitExceededError is the error reported when executing a query exceeds one
+// of its Limits.
+type LimitExceededError struct {
+	Limit string
+	Max   interface{}
+}
+
+func (e LimitExceededError) Error() string {
+	return fmt.Sprintf("Query exceeded the %s limit of %v", e.Limit, e.Max)
+}
+
+type queryBudget struct {
+	limits   Limits
+	deadline time.Time
+	nodes    int64
+}
+
+func withLimits(ctx context.Context, limits Limits) context.Context {
+	b := &queryBudget{limits: limits}
+	if limits.Timeout > 0 {
+		b.deadline = time.Now().Add(limits.Timeout)
+	}
+	return context.WithValue(ctx, limitsKey, b)
+}
+
+// checkBudget returns a LimitExceededError if resolving one more field would
+// exceed the limits stored in ctx.
+func checkBudget(ctx context.Context) error {
+	return chargeBudget(ctx, 1)
+}
+
+// chargeBudget returns a LimitExceededError if n more nodes, e.g. the
+// elements a collection field returns, would exceed the limits stored in
+// ctx.
+func chargeBudget(ctx context.Context, n int64) error {
+	b, ok := ctx.Value(limitsKey).(*queryBudget)
+	if !ok {
+		return nil
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return LimitExceededError{timeLimit, b.limits.Timeout}
+	}
+	if b.limits.MaxNodes > 0 && atomic.AddInt64(&b.nodes, n) > b.limits.MaxNodes {
+		return LimitExceededError{nodesLimit, b.limits.MaxNodes}
+	}
+	return nil
+}
+
+// newObject creates a GraphQL object whose field resolvers check the budget
+// of the query before resolving. All objects in the schema are created
+// through it so that Limits apply to every field.
+//
+// The graphql package evaluates a FieldsThunk every time it looks up a field
+// of the object, so the fields are computed only once here.
+func newObject(config graphql.ObjectConfig) *graphql.Object {
+	switch fields := config.Fields.(type) {
+	case graphql.Fields:
+		config.Fields = limitFields(fields)
+	case graphql.FieldsThunk:
+		var once sync.Once
+		var limited graphql.Fields
+		config.Fields = graphql.FieldsThunk(func() graphql.Fields {
+			once.Do(func() {
+				limited = limitFields(fields())
+			})
+			return limited
+		})
+	}
+	return graphql.NewObject(config)
+}
+
+func limitFields(fields graphql.Fields) graphql.Fields {
+	limited := make(graphql.Fields, len(fields))
+	for name, f := range fields {
+		resolve := f.Resolve
+		if resolve != nil {
+			lf := *f
+			lf.Resolve = func(p graphql.ResolveParams) (interface{}, error) {
+				if err := checkBudget(p.Context); err != nil {
+					return nil, err
+				}
+				return resolve(p)
+			}
+			f = &lf
+		}
+		limited[name] = f
+	}
+	return limited
+}
+
+// queryDepth returns the maximum nesting of fields in the operations of
+// query.
+func queryDepth(query string) (int, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return 0, err
+	}
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	var depth func(ss *ast.SelectionSet, visiting map[string]bool) int
+	depth = func(ss *ast.SelectionSet, visiting map[string]bool) int {
+		if ss == nil {
+			return 0
+		}
+		max := 0
+		for _, s := range ss.Selections {
+			d := 0
+			switch s := s.(type) {
+			case *ast.Field:
+				d = 1 + depth(s.SelectionSet, visiting)
+			case *ast.InlineFragment:
+				d = depth(s.SelectionSet, visiting)
+			case *ast.FragmentSpread:
+				name := s.Name.Value
+				if f, ok := fragments[name]; ok && !visiting[name] {
+					visiting[name] = true
+					d = depth(f.SelectionSet, visiting)
+					delete(visiting, name)
+				}
+			}
+			if d > max {
+				max = d
+			}
+		}
+		return max
+	}
+
+	max := 0
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			if d := depth(op.SelectionSet, map[string]bool{}); d > max {
+				max = d
+			}
+		}
+	}
+	return max, nil
+}
+
+// checkDepth returns a LimitExceededError if query is nested deeper than
+// allowed by limits. Queries that fail to parse are left for graphql.Do to
+// report.
+func checkDepth(query string, limits Limits) error {
+	if limits.MaxDepth <= 0 {
+		return nil
+	}
+	if d, err := queryDepth(query); err == nil && d > limits.MaxDepth {
+		return LimitExceededError{depthLimit, limits.MaxDepth}
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Collections also expose a Relay style "page" field which supports stable,
+// cursor based pagination:
+//
+// type FooListPage {
+//   edges: [FooListEdge!]!
+//   pageInfo: PageInfo!
+// }
+//
+// type FooListEdge {
+//   cursor: String!
+//   node: Foo!
+// }
+//
+// type PageInfo {
+//   hasNextPage: Boolean!
+//   hasPreviousPage: Boolean!
+//   startCursor: String
+//   endCursor: String
+// }
+//
+// Cursors are opaque strings. For Sets and Maps a cursor encodes the key of an
+// element so paging continues after that key even if the collection changed
+// between requests. For Lists a cursor encodes the index of an element.
+
+const (
+	afterKey           = "after"
+	cursorKey          = "cursor"
+	edgesKey           = "edges"
+	endCursorKey       = "endCursor"
+	firstKey           = "first"
+	hasNextPageKey     = "hasNextPage"
+	hasPreviousPageKey = "hasPreviousPage"
+	nodeKey            = "node"
+	pageInfoKey        = "pageInfo"
+	pageInfoTypeName   = "PageInfo"
+	pageKey            = "page"
+	startCursorKey     = "startCursor"
+)
+
+const (
+	indexCursorTag byte = 'i'
+	keyCursorTag   byte = 'k'
+)
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+type pageEdge struct {
+	cursor string
+	node   interface{}
+}
+
+type pageInfo struct {
+	hasNextPage, hasPreviousPage bool
+	startCursor, endCursor       string
+}
+
+type page struct {
+	edges []interface{}
+	info  pageInfo
+}
+
+var pageArgs = graphql.FieldConfigArgument{
+	firstKey: &graphql.ArgumentConfig{Type: graphql.Int},
+	afterKey: &graphql.ArgumentConfig{Type: graphql.String},
+}
+
+func encodeIndexCursor(idx uint64) string {
+	buf := make([]byte, 1+binary.MaxVarintLen64)
+	buf[0] = indexCursorTag
+	n := binary.PutUvarint(buf[1:], idx)
+	return base64.RawURLEncoding.EncodeToString(buf[:1+n])
+}
+
+func encodeKeyCursor(key types.Value) string {
+	data := types.EncodeValue(key, nil).Data()
+	buf := make([]byte, 1+len(data))
+	buf[0] = keyCursorTag
+	copy(buf[1:], data)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeCursor(cursor string) (tag byte, data []byte, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(buf) == 0 {
+		return 0, nil, errInvalidCursor
+	}
+	return buf[0], buf[1:], nil
+}
+
+func decodeIndexCursor(cursor string) (uint64, error) {
+	tag, data, err := decodeCursor(cursor)
+	if err != nil {
+		return 0, err
+	}
+	idx, n := binary.Uvarint(data)
+	if tag != indexCursorTag || n <= 0 || n != len(data) {
+		return 0, errInvalidCursor
+	}
+	return idx, nil
+}
+
+func decodeKeyCursor(cursor string, vr types.ValueReader) (key types.Value, err error) {
+	tag, data, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if tag != keyCursorTag {
+		return nil, errInvalidCursor
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			key, err = nil, errInvalidCursor
+		}
+	}()
+	return types.DecodeFromBytes(data, vr), nil
+}
+
+// getFirstArg returns the value of the first argument, or -1 if there is
+// none.
+func getFirstArg(args map[string]interface{}) (int, error) {
+	first, ok := args[firstKey].(int)
+	if !ok {
+		return -1, nil
+	}
+	if first < 0 {
+		return 0, fmt.Errorf("Argument %s must not be negative", firstKey)
+	}
+	return first, nil
+}
+
+func getListPage(ctx context.Context, l types.List, args map[string]interface{}) (interface{}, error) {
+	first, err := getFirstArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	length := l.Len()
+	start := uint64(0)
+	if after, ok := args[afterKey].(string); ok {
+		idx, err := decodeIndexCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		// The page after a cursor at or past the end, e.g. one of a List
+		// which has since shrunk, is empty. The cursor may be of any index,
+		// including math.MaxUint64, so start mustn't overflow.
+		start = length
+		if idx < length {
+			start = idx + 1
+		}
+	}
+
+	end := length
+	if first >= 0 && start+uint64(first) < end {
+		end = start + uint64(first)
+	}
+
+	p := page{edges: []interface{}{}}
+	p.info.hasPreviousPage = start > 0
+	if start < end {
+		if err := chargeBudget(ctx, int64(end-start)); err != nil {
+			return nil, err
+		}
+		iter := l.IteratorAt(start)
+		for i := start; i < end; i++ {
+			p.edges = append(p.edges, pageEdge{encodeIndexCursor(i), MaybeGetScalar(iter.Next())})
+		}
+		p.info.hasNextPage = end < length
+	}
+	p.setCursors()
+	return p, nil
+}
+
+// getKeyedPage pages through a Set or Map. iterFrom and iterAll return
+// functions that yield the key of the next element along with the node to
+// return for it, or nil when there are no more elements.
+func getKeyedPage(ctx context.Context, args map[string]interface{}, vr types.ValueReader, iterFrom func(from types.Value) func() (types.Value, interface{}), iterAll func() func() (types.Value, interface{})) (interface{}, error) {
+	first, err := getFirstArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var next func() (types.Value, interface{})
+	var after types.Value
+	if cursor, ok := args[afterKey].(string); ok {
+		if after, err = decodeKeyCursor(cursor, vr); err != nil {
+			return nil, err
+		}
+		next = iterFrom(after)
+	} else {
+		next = iterAll()
+	}
+
+	p := page{edges: []interface{}{}}
+	p.info.hasPreviousPage = after != nil
+	for {
+		k, node := next()
+		if k == nil {
+			break
+		}
+		if after != nil && !after.Less(k) {
+			// Skip the element the cursor points at, if it still exists.
+			continue
+		}
+		if first >= 0 && len(p.edges) == first {
+			p.info.hasNextPage = true
+			break
+		}
+		if err := chargeBudget(ctx, 1); err != nil {
+			return nil, err
+		}
+		p.edges = append(p.edges, pageEdge{encodeKeyCursor(k), node})
+	}
+	p.setCursors()
+	return p, nil
+}
+
+func getSetPage(ctx context.Context, s types.Set, args map[string]interface{}, vr types.ValueReader) (interface{}, error) {
+	wrap := func(iter types.SetIterator) func() (types.Value, interface{}) {
+		return func() (types.Value, interface{}) {
+			v := iter.Next()
+			if v == nil {
+				return nil, nil
+			}
+			return v, MaybeGetScalar(v)
+		}
+	}
+	return getKeyedPage(ctx, args, vr, func(from types.Value) func() (types.Value, interface{}) {
+		return wrap(s.IteratorFrom(from))
+	}, func() func() (types.Value, interface{}) {
+		return wrap(s.Iterator())
+	})
+}
+
+func getMapPage(ctx context.Context, m types.Map, args map[string]interface{}, vr types.ValueReader) (interface{}, error) {
+	wrap := func(iter types.MapIterator) func() (types.Value, interface{}) {
+		return func() (types.Value, interface{}) {
+			k, v := iter.Next()
+			if k == nil {
+				return nil, nil
+			}
+			return k, mapEntry{k, v}
+		}
+	}
+	return getKeyedPage(ctx, args, vr, func(from types.Value) func() (types.Value, interface{}) {
+		return wrap(m.IteratorFrom(from))
+	}, func() func() (types.Value, interface{}) {
+		return wrap(m.Iterator())
+	})
+}
+
+func (p *page) setCursors() {
+	if len(p.edges) > 0 {
+		p.info.startCursor = p.edges[0].(pageEdge).cursor
+		p.info.endCursor = p.edges[len(p.edges)-1].(pageEdge).cursor
+	}
+}
+
+func optionalCursor(c string) interface{} {
+	if c == "" {
+		return nil
+	}
+	return c
+}
+
+func (tc *TypeConverter) pageInfoToGraphQLObject() graphql.Type {
+	key := typeMapKey{pageInfoTypeName, false}
+	if t, ok := tc.tm[key]; ok {
+		return t
+	}
+
+	t := graphql.NewNonNull(newObject(graphql.ObjectConfig{
+		Name: pageInfoTypeName,
+		Fields: graphql.Fields{
+			hasNextPageKey: &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(pageInfo).hasNextPage, nil
+				},
+			},
+			hasPreviousPageKey: &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(pageInfo).hasPreviousPage, nil
+				},
+			},
+			startCursorKey: &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return optionalCursor(p.Source.(pageInfo).startCursor), nil
+				},
+			},
+			endCursorKey: &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return optionalCursor(p.Source.(pageInfo).endCursor), nil
+				},
+			},
+		},
+	}))
+	tc.tm[key] = t
+	return t
+}
+
+// pageToGraphQLObject creates the type of the page field of the collection
+// type nomsType. nodeType is the GraphQL type of the elements, or entries for
+// maps.
+func (tc *TypeConverter) pageToGraphQLObject(nomsType *types.Type, nodeType graphql.Type) graphql.Type {
+	name := tc.getTypeName(nomsType)
+	edgeType := graphql.NewNonNull(newObject(graphql.ObjectConfig{
+		Name: name + "Edge",
+		Fields: graphql.Fields{
+			cursorKey: &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(pageEdge).cursor, nil
+				},
+			},
+			nodeKey: &graphql.Field{
+				Type: nodeType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(pageEdge).node, nil
+				},
+			},
+		},
+	}))
+
+	return newObject(graphql.ObjectConfig{
+		Name: name + "Page",
+		Fields: graphql.Fields{
+			edgesKey: &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(edgeType)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(page).edges, nil
+				},
+			},
+			pageInfoKey: &graphql.Field{
+				Type: tc.pageInfoToGraphQLObject(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(page).info, nil
+				},
+			},
+		},
+	})
+}
+
+// pageField creates the page field for the collection type nomsType.
+func (tc *TypeConverter) pageField(nomsType *types.Type, nodeType graphql.Type) *graphql.Field {
+	return &graphql.Field{
+		Type: tc.pageToGraphQLObject(nomsType, nodeType),
+		Args: pageArgs,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			vr, _ := p.Context.Value(vrKey).(types.ValueReader)
+			switch c := p.Source.(type) {
+			case types.List:
+				return getListPage(p.Context, c, p.Args)
+			case types.Set:
+				return getSetPage(p.Context, c, p.Args, vr)
+			case types.Map:
+				return getMapPage(p.Context, c, p.Args, vr)
+			}
+			panic("not reached")
+		},
+	}
+}
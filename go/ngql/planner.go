@@ -0,0 +1,226 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// The elements matching a "where" argument are found in one of three ways:
+//
+// * key range scan: Sets and Maps filtered on their Number or String keys
+//   only iterate over the matching range of keys.
+// * index scan: Lists and Sets of structs filtered on a field for which an
+//   Index is available only read the matching range of the index. The
+//   elements are returned in the order of the indexed field.
+// * full scan: everything else iterates over all elements.
+//
+// Collections with a "where" argument also have an "explain" field which
+// takes the same argument and returns a description of the plan.
+
+const explainKey = "explain"
+
+const indexesKey contextKey = "indexes"
+
+// Index is a secondary index of the struct elements of a List or Set on one
+// of their Number or String fields, in the format built by nomdex: a Map from
+// the field values to Sets of Refs of the elements with that value.
+type Index struct {
+	Field string
+	Map   types.Map
+}
+
+// Indexes maps the hash of a collection to the indexes of its elements.
+type Indexes map[hash.Hash][]Index
+
+// WithIndexes returns a copy of ctx which makes indexes available to the
+// queries executed with it.
+func WithIndexes(ctx context.Context, indexes Indexes) context.Context {
+	return context.WithValue(ctx, indexesKey, indexes)
+}
+
+type scanPlan struct {
+	c           types.Collection
+	filter      filter      // for Lists and Sets
+	entryFilter entryFilter // for Maps
+	keyRange    *scalarFilter
+	index       *Index
+	indexRange  *scalarFilter
+	vr          types.ValueReader
+}
+
+func planScan(ctx context.Context, c types.Collection, nomsType *types.Type, args map[string]interface{}) scanPlan {
+	elemTypes := nomsType.Desc.(types.CompoundDesc).ElemTypes
+	p := scanPlan{c: c}
+	p.vr, _ = ctx.Value(vrKey).(types.ValueReader)
+
+	switch c.(type) {
+	case types.List, types.Set:
+		p.filter = makeFilter(args[whereKey], elemTypes[0])
+		if _, ok := c.(types.Set); ok {
+			p.keyRange = rangeFilter(p.filter, elemTypes[0])
+		}
+		if p.keyRange == nil && p.vr != nil {
+			p.index, p.indexRange = findIndex(ctx, c, p.filter)
+		}
+	case types.Map:
+		p.entryFilter = makeEntryFilter(args[whereKey], nomsType)
+		p.keyRange = rangeFilter(p.entryFilter.key, elemTypes[0])
+	default:
+		panic("not reached")
+	}
+	return p
+}
+
+// findIndex returns the first index of c on a field that f restricts to a
+// range.
+func findIndex(ctx context.Context, c types.Collection, f filter) (*Index, *scalarFilter) {
+	sf, ok := f.(structFilter)
+	if !ok {
+		return nil, nil
+	}
+	indexes, _ := ctx.Value(indexesKey).(Indexes)
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	for _, idx := range indexes[c.Hash()] {
+		if ff, ok := sf[idx.Field].(scalarFilter); ok && ff.hasBounds() {
+			idx := idx
+			return &idx, &ff
+		}
+	}
+	return nil, nil
+}
+
+func (p scanPlan) String() string {
+	switch {
+	case p.index != nil:
+		return fmt.Sprintf("index scan on %s where %s", p.index.Field, p.indexRange)
+	case p.keyRange != nil:
+		return fmt.Sprintf("key range scan where %s", p.keyRange)
+	}
+	return "full scan"
+}
+
+func (f scalarFilter) String() string {
+	conds := []string{}
+	for _, c := range []struct {
+		op string
+		v  types.Value
+	}{{eqKey, f.eq}, {neKey, f.ne}, {gtKey, f.gt}, {gteKey, f.gte}, {ltKey, f.lt}, {lteKey, f.lte}} {
+		if c.v != nil {
+			conds = append(conds, fmt.Sprintf("%s %s", c.op, types.EncodedValue(c.v)))
+		}
+	}
+	if f.prefix != nil {
+		conds = append(conds, fmt.Sprintf("%s %q", prefixKey, *f.prefix))
+	}
+	return strings.Join(conds, " and ")
+}
+
+// iterFiltered calls cb with the elements of the List or Set c, or the entries
+// of the Map c, which match the where argument in args, until cb returns true.
+// For Lists and Sets k is the element and v is nil.
+func iterFiltered(ctx context.Context, c types.Collection, nomsType *types.Type, args map[string]interface{}, cb func(k, v types.Value) (stop bool)) {
+	planScan(ctx, c, nomsType, args).iter(cb)
+}
+
+func (p scanPlan) matches(v types.Value) bool {
+	return p.filter == nil || p.filter.matches(v)
+}
+
+func (p scanPlan) iter(cb func(k, v types.Value) (stop bool)) {
+	if p.index != nil {
+		p.iterIndex(cb)
+		return
+	}
+
+	switch c := p.c.(type) {
+	case types.List:
+		c.Iter(func(v types.Value, _ uint64) bool {
+			return p.matches(v) && cb(v, nil)
+		})
+	case types.Set:
+		var iter types.SetIterator
+		if lb := lowerBoundOf(p.keyRange); lb != nil {
+			iter = c.IteratorFrom(lb)
+		} else {
+			iter = c.Iterator()
+		}
+		for v := iter.Next(); v != nil; v = iter.Next() {
+			if p.keyRange != nil && p.keyRange.pastUpperBound(v) {
+				break
+			}
+			if p.matches(v) && cb(v, nil) {
+				break
+			}
+		}
+	case types.Map:
+		var iter types.MapIterator
+		if lb := lowerBoundOf(p.keyRange); lb != nil {
+			iter = c.IteratorFrom(lb)
+		} else {
+			iter = c.Iterator()
+		}
+		for k, v := iter.Next(); k != nil; k, v = iter.Next() {
+			if p.keyRange != nil && p.keyRange.pastUpperBound(k) {
+				break
+			}
+			if p.entryFilter.matches(k, v) && cb(k, v) {
+				break
+			}
+		}
+	}
+}
+
+func (p scanPlan) iterIndex(cb func(k, v types.Value) (stop bool)) {
+	var iter types.MapIterator
+	if lb := p.indexRange.lowerBound(); lb != nil {
+		iter = p.index.Map.IteratorFrom(lb)
+	} else {
+		iter = p.index.Map.Iterator()
+	}
+
+	stop := false
+	for k, elems := iter.Next(); k != nil && !stop; k, elems = iter.Next() {
+		if p.indexRange.pastUpperBound(k) {
+			break
+		}
+		if !p.indexRange.matches(k) {
+			continue
+		}
+		elems.(types.Set).Iter(func(v types.Value) bool {
+			if r, ok := v.(types.Ref); ok {
+				v = r.TargetValue(p.vr)
+			}
+			// The index only narrows down the candidates, the other conditions
+			// still have to be checked.
+			stop = p.matches(v) && cb(v, nil)
+			return stop
+		})
+	}
+}
+
+// explainField creates the explain field for the collection type nomsType,
+// or returns nil if it can't be filtered.
+func (tc *TypeConverter) explainField(nomsType *types.Type, args graphql.FieldConfigArgument) *graphql.Field {
+	where, ok := args[whereKey]
+	if !ok {
+		return nil
+	}
+	return &graphql.Field{
+		Type: graphql.NewNonNull(graphql.String),
+		Args: graphql.FieldConfigArgument{whereKey: where},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return planScan(p.Context, p.Source.(types.Collection), nomsType, p.Args).String(), nil
+		},
+	}
+}
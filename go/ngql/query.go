@@ -31,29 +31,35 @@ const (
 	throughKey     = "through"
 	valueKey       = "value"
 	valuesKey      = "values"
-	vrKey          = "vr"
 )
 
+const vrKey contextKey = "vr"
+
 // NewRootQueryObject creates a "root" query object that can be used to
 // traverse the value tree of rootValue.
 func NewRootQueryObject(rootValue types.Value, tm *TypeMap) *graphql.Object {
-	tc := TypeConverter{*tm, DefaultNameFunc}
+	tc := TypeConverter{tm: *tm, NameFunc: DefaultNameFunc}
 	return tc.NewRootQueryObject(rootValue)
 }
 
 // NewRootQueryObject creates a "root" query object that can be used to
 // traverse the value tree of rootValue.
 func (tc *TypeConverter) NewRootQueryObject(rootValue types.Value) *graphql.Object {
-	rootNomsType := types.TypeOf(rootValue)
+	return tc.newRootQueryObject(types.TypeOf(rootValue), func(ctx context.Context) types.Value {
+		return rootValue
+	})
+}
+
+func (tc *TypeConverter) newRootQueryObject(rootNomsType *types.Type, getRootValue func(ctx context.Context) types.Value) *graphql.Object {
 	rootType := tc.NomsTypeToGraphQLType(rootNomsType)
 
-	return graphql.NewObject(graphql.ObjectConfig{
+	return newObject(graphql.ObjectConfig{
 		Name: rootQueryKey,
 		Fields: graphql.Fields{
 			rootKey: &graphql.Field{
 				Type: rootType,
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					return MaybeGetScalar(rootValue), nil
+					return MaybeGetScalar(getRootValue(p.Context)), nil
 				},
 			},
 		}})
@@ -66,36 +72,63 @@ func NewContext(vr types.ValueReader) context.Context {
 }
 
 // Query takes |rootValue|, builds a GraphQL scheme from rootValue.Type() and
-// executes |query| against it, encoding the result to |w|.
+// executes |query| against it, encoding the result to |w|. The execution is
+// bounded by DefaultLimits. Schemas are cached in DefaultSchemaCache.
 func Query(rootValue types.Value, query string, vr types.ValueReader, w io.Writer) {
-	schemaConfig := graphql.SchemaConfig{}
-	tc := NewTypeConverter()
-	queryWithSchemaConfig(rootValue, query, schemaConfig, vr, tc, w)
+	QueryWithLimits(rootValue, query, vr, DefaultLimits, w)
+}
+
+// QueryWithLimits is like Query but bounds the execution of |query| by
+// |limits|. If a limit is exceeded the result contains a LimitExceededError
+// along with the fields that were resolved before that.
+func QueryWithLimits(rootValue types.Value, query string, vr types.ValueReader, limits Limits, w io.Writer) {
+	DefaultSchemaCache.Query(rootValue, query, vr, limits, w)
 }
 
-func queryWithSchemaConfig(rootValue types.Value, query string, schemaConfig graphql.SchemaConfig, vr types.ValueReader, tc *TypeConverter, w io.Writer) {
+func queryWithSchemaConfig(rootValue types.Value, query string, schemaConfig graphql.SchemaConfig, vr types.ValueReader, tc *TypeConverter, limits Limits, w io.Writer) {
 	schemaConfig.Query = tc.NewRootQueryObject(rootValue)
 	schema, _ := graphql.NewSchema(schemaConfig)
-	ctx := NewContext(vr)
-
-	r := graphql.Do(graphql.Params{
-		Schema:        schema,
-		RequestString: query,
-		Context:       ctx,
-	})
+	execute(schema, query, NewContext(vr), limits, w)
+}
 
+func execute(schema graphql.Schema, query string, ctx context.Context, limits Limits, w io.Writer) {
+	r := do(schema, Request{Query: query}, ctx, limits)
 	err := json.NewEncoder(w).Encode(r)
 	d.PanicIfError(err)
 }
 
-// Error writes an error as a GraphQL error to a writer.
-func Error(err error, w io.Writer) {
-	r := graphql.Result{
+func do(schema graphql.Schema, r Request, ctx context.Context, limits Limits) *graphql.Result {
+	if err := checkDepth(r.Query, limits); err != nil {
+		return errorResult(err)
+	}
+
+	return graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  r.Query,
+		VariableValues: r.Variables,
+		OperationName:  r.OperationName,
+		Context:        withLimits(ctx, limits),
+	})
+}
+
+// Request is a GraphQL request as sent by clients over HTTP, see
+// http://graphql.org/learn/serving-over-http/.
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+func errorResult(err error) *graphql.Result {
+	return &graphql.Result{
 		Errors: []gqlerrors.FormattedError{
 			{Message: err.Error()},
 		},
 	}
+}
 
-	jsonErr := json.NewEncoder(w).Encode(r)
+// Error writes an error as a GraphQL error to a writer.
+func Error(err error, w io.Writer) {
+	jsonErr := json.NewEncoder(w).Encode(errorResult(err))
 	d.PanicIfError(jsonErr)
 }
@@ -6,12 +6,16 @@ package ngql
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/graphql/language/ast"
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/marshal"
 	"github.com/attic-labs/noms/go/types"
@@ -697,6 +701,265 @@ func (suite *QueryGraphQLSuite) TestSetArgs() {
 	}
 }
 
+func (suite *QueryGraphQLSuite) TestListPage() {
+	list := types.NewList(types.String("a"), types.String("b"), types.String("c"))
+	c0, c1, c2 := encodeIndexCursor(0), encodeIndexCursor(1), encodeIndexCursor(2)
+
+	suite.assertQueryResult(list, `{root{page(first:2){edges{cursor node} pageInfo{hasNextPage hasPreviousPage startCursor endCursor}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"cursor":"`+c0+`","node":"a"},{"cursor":"`+c1+`","node":"b"}],"pageInfo":{"hasNextPage":true,"hasPreviousPage":false,"startCursor":"`+c0+`","endCursor":"`+c1+`"}}}}}`)
+	suite.assertQueryResult(list, `{root{page(first:2,after:"`+c1+`"){edges{cursor node} pageInfo{hasNextPage hasPreviousPage}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"cursor":"`+c2+`","node":"c"}],"pageInfo":{"hasNextPage":false,"hasPreviousPage":true}}}}}`)
+	suite.assertQueryResult(list, `{root{page(after:"`+c2+`"){edges{node} pageInfo{hasNextPage endCursor}}}}`,
+		`{"data":{"root":{"page":{"edges":[],"pageInfo":{"hasNextPage":false,"endCursor":null}}}}}`)
+	suite.assertQueryResult(list, `{root{page(first:2,after:"`+encodeIndexCursor(math.MaxUint64)+`"){edges{node} pageInfo{hasNextPage hasPreviousPage}}}}`,
+		`{"data":{"root":{"page":{"edges":[],"pageInfo":{"hasNextPage":false,"hasPreviousPage":true}}}}}`)
+	suite.assertQueryResult(list, `{root{page{edges{node}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"node":"a"},{"node":"b"},{"node":"c"}]}}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestSetPage() {
+	s := types.NewSet(types.Number(1), types.Number(3), types.Number(5), types.Number(7))
+	c3, c5 := encodeKeyCursor(types.Number(3)), encodeKeyCursor(types.Number(5))
+
+	suite.assertQueryResult(s, `{root{page(first:2){edges{node} pageInfo{hasNextPage endCursor}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"node":1},{"node":3}],"pageInfo":{"hasNextPage":true,"endCursor":"`+c3+`"}}}}}`)
+	suite.assertQueryResult(s, `{root{page(first:1,after:"`+c3+`"){edges{cursor node} pageInfo{hasNextPage}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"cursor":"`+c5+`","node":5}],"pageInfo":{"hasNextPage":true}}}}}`)
+
+	// Paging is stable when the set changes between requests, even if the
+	// element the cursor points at is removed.
+	s = s.Remove(types.Number(3)).Insert(types.Number(0), types.Number(4))
+	suite.assertQueryResult(s, `{root{page(first:2,after:"`+c3+`"){edges{node} pageInfo{hasNextPage}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"node":4},{"node":5}],"pageInfo":{"hasNextPage":true}}}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestMapPage() {
+	m := types.NewMap(
+		types.String("a"), types.Number(1),
+		types.String("b"), types.Number(2),
+		types.String("c"), types.Number(3),
+	)
+	ca := encodeKeyCursor(types.String("a"))
+
+	suite.assertQueryResult(m, `{root{page(first:1){edges{node{key value}} pageInfo{endCursor}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"node":{"key":"a","value":1}}],"pageInfo":{"endCursor":"`+ca+`"}}}}}`)
+	suite.assertQueryResult(m, `{root{page(after:"`+ca+`"){edges{node{key value}} pageInfo{hasNextPage hasPreviousPage}}}}`,
+		`{"data":{"root":{"page":{"edges":[{"node":{"key":"b","value":2}},{"node":{"key":"c","value":3}}],"pageInfo":{"hasNextPage":false,"hasPreviousPage":true}}}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestPageErrors() {
+	m := types.NewMap(types.String("a"), types.Number(1))
+	suite.assertQueryResult(m, `{root{page(after:"!!"){edges{cursor}}}}`,
+		`{"data":{"root":{"page":null}},"errors":[{"message":"Invalid cursor","locations":[]}]}`)
+	suite.assertQueryResult(m, `{root{page(after:"`+encodeIndexCursor(1)+`"){edges{cursor}}}}`,
+		`{"data":{"root":{"page":null}},"errors":[{"message":"Invalid cursor","locations":[]}]}`)
+	suite.assertQueryResult(m, `{root{page(first:-1){edges{cursor}}}}`,
+		`{"data":{"root":{"page":null}},"errors":[{"message":"Argument first must not be negative","locations":[]}]}`)
+}
+
+func (suite *QueryGraphQLSuite) TestListWhere() {
+	list := types.NewList(types.Number(5), types.Number(1), types.Number(4), types.Number(2), types.Number(3))
+	suite.assertQueryResult(list, `{root{values(where:{gt:1,lte:4})}}`,
+		`{"data":{"root":{"values":[4,2,3]}}}`)
+	suite.assertQueryResult(list, `{root{values(where:{ne:4},count:2)}}`,
+		`{"data":{"root":{"values":[5,1]}}}`)
+	suite.assertQueryResult(list, `{root{values(where:{eq:7})}}`,
+		`{"data":{"root":{"values":[]}}}`)
+
+	people := types.NewList(
+		types.NewStruct("Person", types.StructData{"name": types.String("ann"), "age": types.Number(30), "admin": types.Bool(true)}),
+		types.NewStruct("Person", types.StructData{"name": types.String("bob"), "age": types.Number(20), "admin": types.Bool(false)}),
+		types.NewStruct("Person", types.StructData{"name": types.String("anna"), "age": types.Number(40), "admin": types.Bool(false)}),
+	)
+	suite.assertQueryResult(people, `{root{values(where:{name:{prefix:"an"},admin:{eq:false}}){name}}}`,
+		`{"data":{"root":{"values":[{"name":"anna"}]}}}`)
+	suite.assertQueryResult(people, `{root{values(where:{age:{lt:35}}){name}}}`,
+		`{"data":{"root":{"values":[{"name":"ann"},{"name":"bob"}]}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestSetWhere() {
+	s := types.NewSet(types.String("apple"), types.String("apricot"), types.String("banana"), types.String("cherry"))
+	suite.assertQueryResult(s, `{root{values(where:{prefix:"ap"})}}`,
+		`{"data":{"root":{"values":["apple","apricot"]}}}`)
+	suite.assertQueryResult(s, `{root{values(where:{gte:"b",lt:"cz"})}}`,
+		`{"data":{"root":{"values":["banana","cherry"]}}}`)
+	suite.assertQueryResult(s, `{root{values(where:{eq:"banana"})}}`,
+		`{"data":{"root":{"values":["banana"]}}}`)
+	suite.assertQueryResult(s, `{root{values(where:{gt:"apple"},count:1)}}`,
+		`{"data":{"root":{"values":["apricot"]}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestMapWhere() {
+	m := types.NewMap(
+		types.Number(1), types.String("a"),
+		types.Number(2), types.String("b"),
+		types.Number(3), types.String("a"),
+		types.Number(4), types.String("b"),
+	)
+	suite.assertQueryResult(m, `{root{keys(where:{key:{gte:2,lte:3}})}}`,
+		`{"data":{"root":{"keys":[2,3]}}}`)
+	suite.assertQueryResult(m, `{root{entries(where:{value:{eq:"b"}}){key value}}}`,
+		`{"data":{"root":{"entries":[{"key":2,"value":"b"},{"key":4,"value":"b"}]}}}`)
+	suite.assertQueryResult(m, `{root{values(where:{key:{gt:1},value:{eq:"a"}})}}`,
+		`{"data":{"root":{"values":["a"]}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestAggregate() {
+	list := types.NewList(types.Number(4), types.Number(1), types.Number(7))
+	suite.assertQueryResult(list, `{root{aggregate{count sum min max avg}}}`,
+		`{"data":{"root":{"aggregate":{"count":3,"sum":12,"min":1,"max":7,"avg":4}}}}`)
+	suite.assertQueryResult(list, `{root{aggregate(where:{gt:10}){count sum min max avg}}}`,
+		`{"data":{"root":{"aggregate":{"count":0,"sum":0,"min":null,"max":null,"avg":null}}}}`)
+
+	s := types.NewSet(types.Number(1), types.Number(2), types.Number(3), types.Number(4))
+	suite.assertQueryResult(s, `{root{aggregate(where:{gte:2,lt:4}){count sum}}}`,
+		`{"data":{"root":{"aggregate":{"count":2,"sum":5}}}}`)
+
+	m := types.NewMap(
+		types.String("a"), types.NewStruct("Item", types.StructData{"price": types.Number(2), "name": types.String("x")}),
+		types.String("b"), types.NewStruct("Item", types.StructData{"price": types.Number(6), "name": types.String("y")}),
+		types.String("c"), types.NewStruct("Item", types.StructData{"price": types.Number(1), "name": types.String("z")}),
+	)
+	suite.assertQueryResult(m, `{root{aggregate{count price{sum max avg}}}}`,
+		`{"data":{"root":{"aggregate":{"count":3,"price":{"sum":9,"max":6,"avg":3}}}}}`)
+	suite.assertQueryResult(m, `{root{aggregate(where:{key:{lte:"b"}}){price{min}}}}`,
+		`{"data":{"root":{"aggregate":{"price":{"min":2}}}}}`)
+}
+
+func (suite *QueryGraphQLSuite) assertQueryResultWithLimits(v types.Value, q string, limits Limits, expect string) {
+	buf := &bytes.Buffer{}
+	QueryWithLimits(v, q, suite.vs, limits, buf)
+	suite.JSONEq(test.RemoveHashes(expect), test.RemoveHashes(buf.String()))
+}
+
+func (suite *QueryGraphQLSuite) TestLimits() {
+	s := types.NewStruct("Foo", types.StructData{
+		"a": types.NewStruct("Bar", types.StructData{"b": types.Number(1)}),
+		"c": types.Number(2),
+	})
+
+	suite.assertQueryResultWithLimits(s, `{root{a{b} c}}`, Limits{MaxDepth: 3},
+		`{"data":{"root":{"a":{"b":1},"c":2}}}`)
+	suite.assertQueryResultWithLimits(s, `{root{a{b}}}`, Limits{MaxDepth: 2},
+		`{"data":null,"errors":[{"message":"Query exceeded the depth limit of 2","locations":null}]}`)
+	suite.assertQueryResultWithLimits(s, `{root{...F} } fragment F on FooStruct {a{b}}`, Limits{MaxDepth: 2},
+		`{"data":null,"errors":[{"message":"Query exceeded the depth limit of 2","locations":null}]}`)
+
+	// Fields resolved after MaxNodes are null.
+	suite.assertQueryResultWithLimits(s, `{root{c}}`, Limits{MaxNodes: 2},
+		`{"data":{"root":{"c":2}}}`)
+	suite.assertQueryResultWithLimits(s, `{root{a{b}}}`, Limits{MaxNodes: 2},
+		`{"data":{"root":null},"errors":[{"message":"Query exceeded the nodes limit of 2","locations":[]}]}`)
+
+	// So are collections whose elements would exceed MaxNodes, each element
+	// counting as a node.
+	l := types.NewList(types.Number(1), types.Number(2), types.Number(3), types.Number(4))
+	suite.assertQueryResultWithLimits(l, `{root{values}}`, Limits{MaxNodes: 6},
+		`{"data":{"root":{"values":[1,2,3,4]}}}`)
+	suite.assertQueryResultWithLimits(l, `{root{values}}`, Limits{MaxNodes: 5},
+		`{"data":{"root":{"values":null}},"errors":[{"message":"Query exceeded the nodes limit of 5","locations":[]}]}`)
+	suite.assertQueryResultWithLimits(l, `{root{page{edges{node}}}}`, Limits{MaxNodes: 5},
+		`{"data":{"root":{"page":null}},"errors":[{"message":"Query exceeded the nodes limit of 5","locations":[]}]}`)
+	m := types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2))
+	suite.assertQueryResultWithLimits(m, `{root{keys}}`, Limits{MaxNodes: 3},
+		`{"data":{"root":{"keys":null}},"errors":[{"message":"Query exceeded the nodes limit of 3","locations":[]}]}`)
+	suite.assertQueryResultWithLimits(types.NewSet(types.Number(1), types.Number(2)), `{root{values}}`, Limits{MaxNodes: 3},
+		`{"data":{"root":{"values":null}},"errors":[{"message":"Query exceeded the nodes limit of 3","locations":[]}]}`)
+
+	suite.assertQueryResultWithLimits(s, `{root{c}}`, Limits{Timeout: time.Nanosecond},
+		`{"data":{"root":null},"errors":[{"message":"Query exceeded the time limit of 1ns","locations":[]}]}`)
+}
+
+func (suite *QueryGraphQLSuite) TestQueryDepth() {
+	test := func(q string, expected int) {
+		d, err := queryDepth(q)
+		suite.NoError(err)
+		suite.Equal(expected, d)
+	}
+	test(`{root}`, 1)
+	test(`{root{a{b}} other:root{c}}`, 3)
+	test(`{root{... on Foo{a{b{c}}}}}`, 4)
+	test(`query Q {root{...F}} fragment F on Foo {a{...G}} fragment G on Bar {b}`, 3)
+	test(`{root{...F}} fragment F on Foo {a{...F}}`, 2)
+}
+
+func (suite *QueryGraphQLSuite) TestSchemaCache() {
+	sc := NewSchemaCache(1)
+	query := func(v types.Value, q string) string {
+		buf := &bytes.Buffer{}
+		sc.Query(v, q, suite.vs, Limits{}, buf)
+		return buf.String()
+	}
+
+	l1 := types.NewList(types.Number(1), types.Number(2))
+	l2 := types.NewList(types.Number(3))
+	suite.JSONEq(`{"data":{"root":{"values":[1,2]}}}`, query(l1, `{root{values}}`))
+	s1, err := sc.Schema(types.TypeOf(l1))
+	suite.NoError(err)
+
+	// Values of the same type reuse the schema.
+	suite.JSONEq(`{"data":{"root":{"values":[3]}}}`, query(l2, `{root{values}}`))
+	s2, err := sc.Schema(types.TypeOf(l2))
+	suite.NoError(err)
+	suite.True(s1.QueryType() == s2.QueryType())
+
+	// Other types evict the least recently used schema.
+	suite.JSONEq(`{"data":{"root":"a"}}`, query(types.String("a"), `{root}`))
+	s3, err := sc.Schema(types.TypeOf(l1))
+	suite.NoError(err)
+	suite.False(s1.QueryType() == s3.QueryType())
+}
+
+func (suite *QueryGraphQLSuite) TestIndexScan() {
+	newPerson := func(name string, age float64) types.Struct {
+		return types.NewStruct("Person", types.StructData{"name": types.String(name), "age": types.Number(age)})
+	}
+	people := []types.Struct{newPerson("bob", 20), newPerson("ann", 30), newPerson("cat", 40), newPerson("amy", 50)}
+	list := types.NewList(people[0], people[1], people[2], people[3])
+
+	kvs := []types.Value{}
+	for _, p := range people {
+		kvs = append(kvs, p.Get("name"), types.NewSet(suite.vs.WriteValue(p)))
+	}
+	index := types.NewMap(kvs...)
+
+	sc := NewSchemaCache(1)
+	query := func(q string) string {
+		ctx := WithIndexes(NewContext(suite.vs), Indexes{list.Hash(): {{"name", index}}})
+		buf := &bytes.Buffer{}
+		sc.QueryContext(ctx, list, q, Limits{}, buf)
+		return buf.String()
+	}
+
+	suite.JSONEq(`{"data":{"root":{"explain":"index scan on name where prefix \"a\"","values":[{"name":"amy"},{"name":"ann"}]}}}`,
+		query(`{root{explain(where:{name:{prefix:"a"}}) values(where:{name:{prefix:"a"}}){name}}}`))
+	suite.JSONEq(`{"data":{"root":{"values":[{"name":"ann"}]}}}`,
+		query(`{root{values(where:{name:{prefix:"a"},age:{lt:40}}){name}}}`))
+	suite.JSONEq(`{"data":{"root":{"aggregate":{"age":{"sum":60}}}}}`,
+		query(`{root{aggregate(where:{name:{gte:"b"}}){age{sum}}}}`))
+
+	// Fields without an index are scanned.
+	suite.JSONEq(`{"data":{"root":{"explain":"full scan","values":[{"name":"cat"},{"name":"amy"}]}}}`,
+		query(`{root{explain(where:{age:{gt:30}}) values(where:{age:{gt:30}}){name}}}`))
+
+	s := types.NewSet(types.Number(1), types.Number(2), types.Number(3))
+	suite.assertQueryResult(s, `{root{a:explain(where:{gte:2}) b:explain(where:{ne:2})}}`,
+		`{"data":{"root":{"a":"key range scan where gte 2","b":"full scan"}}}`)
+}
+
+func (suite *QueryGraphQLSuite) TestScalarFilterBounds() {
+	f := scalarFilter{gt: types.Number(1), gte: types.Number(3), lt: types.Number(5)}
+	suite.True(types.Number(3).Equals(f.lowerBound()))
+	suite.False(f.pastUpperBound(types.Number(4)))
+	suite.True(f.pastUpperBound(types.Number(5)))
+
+	p := "ab"
+	f = scalarFilter{prefix: &p}
+	suite.True(types.String("ab").Equals(f.lowerBound()))
+	suite.False(f.pastUpperBound(types.String("abz")))
+	suite.True(f.pastUpperBound(types.String("ac")))
+}
+
 func (suite *QueryGraphQLSuite) TestMapValues() {
 	m := types.NewMap(
 		types.String("a"), types.Number(1),
@@ -965,7 +1228,7 @@ func (suite *QueryGraphQLSuite) assertMutationTypes(query, expected string, tc *
 			},
 		}),
 	}
-	queryWithSchemaConfig(root, query, schemaConfig, suite.vs, tc, buf)
+	queryWithSchemaConfig(root, query, schemaConfig, suite.vs, tc, Limits{}, buf)
 	suite.JSONEq(expected, buf.String())
 }
 
@@ -1481,20 +1744,109 @@ func (suite *QueryGraphQLSuite) TestNameFunc() {
 	})
 }
 
+func (suite *QueryGraphQLSuite) TestSetTypeName() {
+	test := func(tc *TypeConverter, rootValue types.Value, expected string, query string, vars map[string]interface{}) {
+		schema, err := graphql.NewSchema(graphql.SchemaConfig{
+			Query: tc.NewRootQueryObject(rootValue),
+		})
+		suite.NoError(err)
+
+		r := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  query,
+			Context:        NewContext(suite.vs),
+			VariableValues: vars,
+		})
+
+		b, err := json.Marshal(r)
+		suite.NoError(err)
+		suite.JSONEq(expected, string(b))
+	}
+
+	person := types.NewStruct("Person", types.StructData{
+		"name": types.String("ann"),
+	})
+	set := types.NewSet(person)
+
+	tc := NewTypeConverter()
+	tc.SetTypeName(types.TypeOf(person), "Human")
+	test(tc, set, `{"data":{"root":{"__typename":"HumanSet","values":[{"__typename":"Human","name":"ann"}]}}}`,
+		`{root{__typename values{__typename name}}}`, nil)
+	test(tc, set, `{"data":{"root":{"values":[{"name":"ann"}]}}}`,
+		`query ($key: HumanInput!) {root{values(key: $key){name}}}`,
+		map[string]interface{}{"key": map[string]interface{}{"name": "ann"}})
+}
+
+func (suite *QueryGraphQLSuite) TestRegisterScalar() {
+	pointType := types.MakeStructType("Point",
+		types.StructField{Name: "x", Type: types.NumberType},
+		types.StructField{Name: "y", Type: types.NumberType},
+	)
+	parsePoint := func(s string) interface{} {
+		var x, y float64
+		if _, err := fmt.Sscanf(s, "%g,%g", &x, &y); err != nil {
+			return nil
+		}
+		return types.NewStruct("Point", types.StructData{"x": types.Number(x), "y": types.Number(y)})
+	}
+	pointScalar := graphql.NewScalar(graphql.ScalarConfig{
+		Name: "Point",
+		Serialize: func(value interface{}) interface{} {
+			p := value.(types.Struct)
+			return fmt.Sprintf("%g,%g", p.Get("x"), p.Get("y"))
+		},
+		ParseValue: func(value interface{}) interface{} {
+			s, _ := value.(string)
+			return parsePoint(s)
+		},
+		ParseLiteral: func(valueAST ast.Value) interface{} {
+			if s, ok := valueAST.(*ast.StringValue); ok {
+				return parsePoint(s.Value)
+			}
+			return nil
+		},
+	})
+
+	p1 := parsePoint("1,2").(types.Struct)
+	p2 := parsePoint("3,4").(types.Struct)
+	m := types.NewMap(p1, types.String("a"), p2, types.String("b"))
+	suite.True(pointType.Equals(types.TypeOf(p1)))
+
+	tc := NewTypeConverter()
+	tc.RegisterScalar(pointType, pointScalar)
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: tc.NewRootQueryObject(m),
+	})
+	suite.NoError(err)
+
+	r := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{root{__typename entries{key value} values(key:"3,4")}}`,
+		Context:       NewContext(suite.vs),
+	})
+	b, err := json.Marshal(r)
+	suite.NoError(err)
+	suite.JSONEq(`{"data":{"root":{"__typename":"PointToStringMap","entries":[{"key":"1,2","value":"a"},{"key":"3,4","value":"b"}],"values":["b"]}}}`, string(b))
+}
+
 func TestGetListElementsWithSet(t *testing.T) {
 	assert := assert.New(t)
 	v := types.NewSet(types.Number(0), types.Number(1), types.Number(2))
-	r := getListElements(v, map[string]interface{}{})
+	ctx := context.Background()
+	r, err := getListElements(ctx, v, map[string]interface{}{})
+	assert.NoError(err)
 	assert.Equal([]interface{}{float64(0), float64(1), float64(2)}, r)
 
-	r = getListElements(v, map[string]interface{}{
+	r, err = getListElements(ctx, v, map[string]interface{}{
 		atKey: 1,
 	})
+	assert.NoError(err)
 	assert.Equal([]interface{}{float64(1), float64(2)}, r)
 
-	r = getListElements(v, map[string]interface{}{
+	r, err = getListElements(ctx, v, map[string]interface{}{
 		countKey: 2,
 	})
+	assert.NoError(err)
 	assert.Equal([]interface{}{float64(0), float64(1)}, r)
 }
 
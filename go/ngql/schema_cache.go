@@ -0,0 +1,107 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package ngql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/attic-labs/graphql"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/sizecache"
+)
+
+const rootValueKey contextKey = "rootValue"
+
+// DefaultSchemaCache is the SchemaCache used by Query and QueryWithLimits.
+var DefaultSchemaCache = NewSchemaCache(64)
+
+// SchemaCache caches the GraphQL schemas built for the types of root values,
+// so that repeated queries against values of the same type, e.g. the same
+// head of a dataset, skip building the schema.
+type SchemaCache struct {
+	mu    sync.Mutex
+	cache *sizecache.SizeCache
+	// NewTypeConverter creates the TypeConverter used to build each schema.
+	// It can be replaced to customize the schemas, but must then be set before
+	// the first query.
+	NewTypeConverter func() *TypeConverter
+}
+
+type cachedSchema struct {
+	// The graphql package is not safe for concurrent use of a schema, so
+	// queries against the same schema are serialized.
+	mu     sync.Mutex
+	schema graphql.Schema
+	err    error
+}
+
+// NewSchemaCache creates a SchemaCache which keeps the schemas for at most
+// maxSchemas root value types, evicting the least recently used ones.
+func NewSchemaCache(maxSchemas uint64) *SchemaCache {
+	return &SchemaCache{
+		cache:            sizecache.New(maxSchemas),
+		NewTypeConverter: NewTypeConverter,
+	}
+}
+
+// Schema returns the schema for root values of type rootNomsType, building it
+// if it is not cached. The root value is read from the context of the query,
+// see Query.
+func (sc *SchemaCache) Schema(rootNomsType *types.Type) (graphql.Schema, error) {
+	cs := sc.get(rootNomsType)
+	return cs.schema, cs.err
+}
+
+func (sc *SchemaCache) get(rootNomsType *types.Type) *cachedSchema {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	h := rootNomsType.Hash()
+	if cs, ok := sc.cache.Get(h); ok {
+		return cs.(*cachedSchema)
+	}
+
+	tc := sc.NewTypeConverter()
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: tc.newRootQueryObject(rootNomsType, func(ctx context.Context) types.Value {
+			return ctx.Value(rootValueKey).(types.Value)
+		}),
+	})
+	cs := &cachedSchema{schema: schema, err: err}
+	sc.cache.Add(h, 1, cs)
+	return cs
+}
+
+// Query is like QueryWithLimits but uses the schemas cached in sc.
+func (sc *SchemaCache) Query(rootValue types.Value, query string, vr types.ValueReader, limits Limits, w io.Writer) {
+	sc.QueryContext(NewContext(vr), rootValue, query, limits, w)
+}
+
+// QueryContext is like Query but executes query with ctx, which must have
+// been created by NewContext. This allows passing additional data, like
+// Indexes, to the query.
+func (sc *SchemaCache) QueryContext(ctx context.Context, rootValue types.Value, query string, limits Limits, w io.Writer) {
+	r := sc.Do(ctx, rootValue, Request{Query: query}, limits)
+	err := json.NewEncoder(w).Encode(r)
+	d.PanicIfError(err)
+}
+
+// Do executes the GraphQL request r against rootValue with ctx, which must
+// have been created by NewContext, and returns the result.
+func (sc *SchemaCache) Do(ctx context.Context, rootValue types.Value, r Request, limits Limits) *graphql.Result {
+	cs := sc.get(types.TypeOf(rootValue))
+	if cs.err != nil {
+		return errorResult(cs.err)
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	ctx = context.WithValue(ctx, rootValueKey, rootValue)
+	return do(cs.schema, r, ctx, limits)
+}
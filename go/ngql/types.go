@@ -13,21 +13,24 @@ import (
 
 	"github.com/attic-labs/graphql"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
 // TypeConverter provides functions to convert between Noms types and GraphQL
 // types.
 type TypeConverter struct {
-	tm       TypeMap
-	NameFunc NameFunc
+	tm        TypeMap
+	NameFunc  NameFunc
+	typeNames map[hash.Hash]string
+	scalars   map[hash.Hash]*graphql.Scalar
 }
 
 // NewTypeConverter creates a new TypeConverter.
 func NewTypeConverter() *TypeConverter {
 	return &TypeConverter{
-		TypeMap{},
-		DefaultNameFunc,
+		tm:       TypeMap{},
+		NameFunc: DefaultNameFunc,
 	}
 }
 
@@ -35,11 +38,11 @@ func NewTypeConverter() *TypeConverter {
 type NameFunc func(nomsType *types.Type, isInputType bool) string
 
 func (tc *TypeConverter) getTypeName(nomsType *types.Type) string {
-	return tc.NameFunc(nomsType, false)
+	return tc.typeName(nomsType, false)
 }
 
 func (tc *TypeConverter) getInputTypeName(nomsType *types.Type) string {
-	return tc.NameFunc(nomsType, true)
+	return tc.typeName(nomsType, true)
 }
 
 // NomsTypeToGraphQLType creates a GraphQL type from a Noms type that knows how
@@ -96,7 +99,7 @@ type getFieldFn func(v interface{}, fieldName string, ctx context.Context) types
 // When a field name is resolved, it may take key:value arguments. A
 // getSubvaluesFn handles returning one or more *noms* values whose presence is
 // indicated by the provided arguments.
-type getSubvaluesFn func(v types.Value, args map[string]interface{}) interface{}
+type getSubvaluesFn func(ctx context.Context, v types.Value, args map[string]interface{}) (interface{}, error)
 
 // GraphQL requires all memberTypes in a Union to be Structs, so when a noms
 // union contains a scalar, we represent it in that context as a "boxed" value.
@@ -106,7 +109,7 @@ type getSubvaluesFn func(v types.Value, args map[string]interface{}) interface{}
 //   scalarValue: Boolean!
 // }
 func (tc *TypeConverter) scalarToValue(nomsType *types.Type, scalarType graphql.Type) graphql.Type {
-	return graphql.NewObject(graphql.ObjectConfig{
+	return newObject(graphql.ObjectConfig{
 		Name: fmt.Sprintf("%sValue", tc.getTypeName(nomsType)),
 		Fields: graphql.Fields{
 			scalarValue: &graphql.Field{
@@ -130,18 +133,28 @@ func isScalar(nomsType *types.Type) bool {
 // NomsTypeToGraphQLType creates a GraphQL type from a Noms type that knows how
 // to resolve the Noms values.
 func NomsTypeToGraphQLType(nomsType *types.Type, boxedIfScalar bool, tm *TypeMap) graphql.Type {
-	tc := TypeConverter{*tm, DefaultNameFunc}
+	tc := TypeConverter{tm: *tm, NameFunc: DefaultNameFunc}
 	return tc.nomsTypeToGraphQLType(nomsType, boxedIfScalar)
 }
 
 func (tc *TypeConverter) nomsTypeToGraphQLType(nomsType *types.Type, boxedIfScalar bool) graphql.Type {
+	scalar, isCustomScalar := tc.scalars[nomsType.Hash()]
 	name := tc.getTypeName(nomsType)
-	key := typeMapKey{name, boxedIfScalar && isScalar(nomsType)}
+	key := typeMapKey{name, boxedIfScalar && (isScalar(nomsType) || isCustomScalar)}
 	gqlType, ok := tc.tm[key]
 	if ok {
 		return gqlType
 	}
 
+	if isCustomScalar {
+		gqlType = scalar
+		if boxedIfScalar {
+			gqlType = tc.scalarToValue(nomsType, gqlType)
+		}
+		tc.tm[key] = gqlType
+		return gqlType
+	}
+
 	// The graphql package has built in support for recursive types using
 	// FieldsThunk which allows the inner type to refer to an outer type by
 	// lazily initializing the fields.
@@ -198,11 +211,15 @@ func (tc *TypeConverter) nomsTypeToGraphQLType(nomsType *types.Type, boxedIfScal
 // Input types may not be unions or cyclic structs. If we encounter those
 // this returns an error.
 func NomsTypeToGraphQLInputType(nomsType *types.Type, tm *TypeMap) (graphql.Input, error) {
-	tc := TypeConverter{*tm, DefaultNameFunc}
+	tc := TypeConverter{tm: *tm, NameFunc: DefaultNameFunc}
 	return tc.nomsTypeToGraphQLInputType(nomsType)
 }
 
 func (tc *TypeConverter) nomsTypeToGraphQLInputType(nomsType *types.Type) (graphql.Input, error) {
+	if scalar, ok := tc.scalars[nomsType.Hash()]; ok {
+		return scalar, nil
+	}
+
 	// GraphQL input types do not support cycles.
 	if types.HasStructCycles(nomsType) {
 		return nil, errors.New("GraphQL input type cannot contain cycles")
@@ -304,7 +321,7 @@ func (tc *TypeConverter) unionToGQLUnion(nomsType *types.Type) *graphql.Union {
 }
 
 func (tc *TypeConverter) structToGQLObject(nomsType *types.Type) *graphql.Object {
-	return graphql.NewObject(graphql.ObjectConfig{
+	return newObject(graphql.ObjectConfig{
 		Name: tc.getTypeName(nomsType),
 		Fields: graphql.FieldsThunk(func() graphql.Fields {
 			structDesc := nomsType.Desc.(types.StructDesc)
@@ -404,7 +421,7 @@ var listArgs = graphql.FieldConfigArgument{
 	countKey: &graphql.ArgumentConfig{Type: graphql.Int},
 }
 
-func getListElements(v types.Value, args map[string]interface{}) interface{} {
+func getListElements(ctx context.Context, v types.Value, args map[string]interface{}) (interface{}, error) {
 	l := v.(types.Collection)
 	idx := 0
 	count := int(l.Len())
@@ -420,7 +437,7 @@ func getListElements(v types.Value, args map[string]interface{}) interface{} {
 
 	// Clamp ranges
 	if count <= 0 || idx >= len {
-		return ([]interface{})(nil)
+		return ([]interface{})(nil), nil
 	}
 	if idx < 0 {
 		idx = 0
@@ -428,6 +445,9 @@ func getListElements(v types.Value, args map[string]interface{}) interface{} {
 	if idx+count > len {
 		count = len - idx
 	}
+	if err := chargeBudget(ctx, int64(count)); err != nil {
+		return nil, err
+	}
 
 	values := make([]interface{}, count)
 
@@ -447,10 +467,10 @@ func getListElements(v types.Value, args map[string]interface{}) interface{} {
 		values[i] = MaybeGetScalar(iter.Next())
 	}
 
-	return values
+	return values, nil
 }
 
-func getSetElements(v types.Value, args map[string]interface{}) interface{} {
+func getSetElements(ctx context.Context, v types.Value, args map[string]interface{}) (interface{}, error) {
 	s := v.(types.Set)
 
 	iter, nomsKey, nomsThrough, count, singleExactMatch := getCollectionArgs(s, args, iteratorFactory{
@@ -466,7 +486,7 @@ func getSetElements(v types.Value, args map[string]interface{}) interface{} {
 	})
 
 	if count == 0 {
-		return ([]interface{})(nil)
+		return ([]interface{})(nil), nil
 	}
 
 	setIter := iter.(types.SetIterator)
@@ -476,6 +496,9 @@ func getSetElements(v types.Value, args map[string]interface{}) interface{} {
 		if v == nil {
 			break
 		}
+		if err := chargeBudget(ctx, 1); err != nil {
+			return nil, err
+		}
 		if singleExactMatch {
 			if nomsKey.Equals(v) {
 				values = append(values, MaybeGetScalar(v))
@@ -494,7 +517,7 @@ func getSetElements(v types.Value, args map[string]interface{}) interface{} {
 		}
 	}
 
-	return values
+	return values, nil
 }
 
 func getCollectionArgs(col types.Collection, args map[string]interface{}, factory iteratorFactory) (iter interface{}, nomsKey, nomsThrough types.Value, count uint64, singleExactMatch bool) {
@@ -546,7 +569,7 @@ func getCollectionArgs(col types.Collection, args map[string]interface{}, factor
 
 type mapAppender func(slice []interface{}, k, v types.Value) []interface{}
 
-func getMapElements(v types.Value, args map[string]interface{}, app mapAppender) (interface{}, error) {
+func getMapElements(ctx context.Context, v types.Value, args map[string]interface{}, app mapAppender) (interface{}, error) {
 	m := v.(types.Map)
 
 	iter, nomsKey, nomsThrough, count, singleExactMatch := getCollectionArgs(m, args, iteratorFactory{
@@ -572,6 +595,9 @@ func getMapElements(v types.Value, args map[string]interface{}, app mapAppender)
 		if k == nil {
 			break
 		}
+		if err := chargeBudget(ctx, 1); err != nil {
+			return nil, err
+		}
 
 		if singleExactMatch {
 			if nomsKey.Equals(k) {
@@ -637,7 +663,7 @@ type mapEntry struct {
 //	 value: <ValueType>!
 // }
 func (tc *TypeConverter) mapEntryToGraphQLObject(keyType, valueType graphql.Type, nomsKeyType, nomsValueType *types.Type) graphql.Type {
-	return graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+	return graphql.NewNonNull(newObject(graphql.ObjectConfig{
 		Name: fmt.Sprintf("%s%sEntry", tc.getTypeName(nomsKeyType), tc.getTypeName(nomsValueType)),
 		Fields: graphql.FieldsThunk(func() graphql.Fields {
 			return graphql.Fields{
@@ -696,6 +722,12 @@ func GetInputTypeName(nomsType *types.Type) string {
 }
 
 func getTypeName(nomsType *types.Type, suffix string) string {
+	return composeTypeName(nomsType, suffix, GetTypeName)
+}
+
+// composeTypeName returns the name of nomsType, using elemName for the names
+// of the types it is composed of.
+func composeTypeName(nomsType *types.Type, suffix string, elemName func(*types.Type) string) string {
 	switch nomsType.TargetKind() {
 	case types.BoolKind:
 		return "Boolean"
@@ -717,7 +749,7 @@ func getTypeName(nomsType *types.Type, suffix string) string {
 		if isEmptyNomsUnion(nomsValueType) {
 			return "EmptyList"
 		}
-		return fmt.Sprintf("%sList%s", GetTypeName(nomsValueType), suffix)
+		return fmt.Sprintf("%sList%s", elemName(nomsValueType), suffix)
 
 	case types.MapKind:
 		nomsKeyType := nomsType.Desc.(types.CompoundDesc).ElemTypes[0]
@@ -727,10 +759,10 @@ func getTypeName(nomsType *types.Type, suffix string) string {
 			return "EmptyMap"
 		}
 
-		return fmt.Sprintf("%sTo%sMap%s", GetTypeName(nomsKeyType), GetTypeName(nomsValueType), suffix)
+		return fmt.Sprintf("%sTo%sMap%s", elemName(nomsKeyType), elemName(nomsValueType), suffix)
 
 	case types.RefKind:
-		return fmt.Sprintf("%sRef%s", GetTypeName(nomsType.Desc.(types.CompoundDesc).ElemTypes[0]), suffix)
+		return fmt.Sprintf("%sRef%s", elemName(nomsType.Desc.(types.CompoundDesc).ElemTypes[0]), suffix)
 
 	case types.SetKind:
 		nomsValueType := nomsType.Desc.(types.CompoundDesc).ElemTypes[0]
@@ -738,7 +770,7 @@ func getTypeName(nomsType *types.Type, suffix string) string {
 			return "EmptySet"
 		}
 
-		return fmt.Sprintf("%sSet%s", GetTypeName(nomsValueType), suffix)
+		return fmt.Sprintf("%sSet%s", elemName(nomsValueType), suffix)
 
 	case types.StructKind:
 		// GraphQL Name cannot start with a number.
@@ -754,7 +786,7 @@ func getTypeName(nomsType *types.Type, suffix string) string {
 		unionMemberTypes := nomsType.Desc.(types.CompoundDesc).ElemTypes
 		names := make([]string, len(unionMemberTypes))
 		for i, unionMemberType := range unionMemberTypes {
-			names[i] = GetTypeName(unionMemberType)
+			names[i] = elemName(unionMemberType)
 		}
 		return strings.Join(names, "Or") + suffix
 
@@ -789,7 +821,7 @@ func (tc *TypeConverter) listAndSetToGraphQLObject(nomsType *types.Type) *graphq
 		listType = graphql.NewNonNull(valueType)
 	}
 
-	return graphql.NewObject(graphql.ObjectConfig{
+	return newObject(graphql.ObjectConfig{
 		Name: tc.getTypeName(nomsType),
 		Fields: graphql.FieldsThunk(func() graphql.Fields {
 			fields := argsWithSize()
@@ -800,7 +832,10 @@ func (tc *TypeConverter) listAndSetToGraphQLObject(nomsType *types.Type) *graphq
 
 				switch nomsType.TargetKind() {
 				case types.ListKind:
-					args = listArgs
+					args = graphql.FieldConfigArgument{}
+					for k, v := range listArgs {
+						args[k] = v
+					}
 					getSubvalues = getListElements
 
 				case types.SetKind:
@@ -814,16 +849,29 @@ func (tc *TypeConverter) listAndSetToGraphQLObject(nomsType *types.Type) *graphq
 					}
 					getSubvalues = getSetElements
 				}
+				if filterType := tc.filterInputType(nomsValueType); filterType != nil {
+					args[whereKey] = &graphql.ArgumentConfig{Type: filterType}
+				}
 				valuesField := &graphql.Field{
 					Type: graphql.NewList(listType),
 					Args: args,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						if _, ok := p.Args[whereKey]; ok {
+							return getFilteredElements(p.Context, p.Source.(types.Collection), nomsType, p.Args, nil)
+						}
 						c := p.Source.(types.Collection)
-						return getSubvalues(c, p.Args), nil
+						return getSubvalues(p.Context, c, p.Args)
 					},
 				}
 				fields[valuesKey] = valuesField
 				fields[elementsKey] = valuesField
+				fields[pageKey] = tc.pageField(nomsType, listType)
+				if aggregate := tc.aggregateField(nomsType, args); aggregate != nil {
+					fields[aggregateKey] = aggregate
+				}
+				if explain := tc.explainField(nomsType, args); explain != nil {
+					fields[explainKey] = explain
+				}
 			}
 
 			return fields
@@ -832,7 +880,7 @@ func (tc *TypeConverter) listAndSetToGraphQLObject(nomsType *types.Type) *graphq
 }
 
 func (tc *TypeConverter) mapToGraphQLObject(nomsType *types.Type) *graphql.Object {
-	return graphql.NewObject(graphql.ObjectConfig{
+	return newObject(graphql.ObjectConfig{
 		Name: tc.getTypeName(nomsType),
 		Fields: graphql.FieldsThunk(func() graphql.Fields {
 			nomsKeyType := nomsType.Desc.(types.CompoundDesc).ElemTypes[0]
@@ -856,32 +904,46 @@ func (tc *TypeConverter) mapToGraphQLObject(nomsType *types.Type) *graphql.Objec
 					args[keysKey] = &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(keyInputType))}
 					args[throughKey] = &graphql.ArgumentConfig{Type: keyInputType}
 				}
+				if filterType := tc.filterInputType(nomsType); filterType != nil {
+					args[whereKey] = &graphql.ArgumentConfig{Type: filterType}
+				}
+				getElements := func(p graphql.ResolveParams, app mapAppender) (interface{}, error) {
+					c := p.Source.(types.Map)
+					if _, ok := p.Args[whereKey]; ok {
+						return getFilteredElements(p.Context, c, nomsType, p.Args, app)
+					}
+					return getMapElements(p.Context, c, p.Args, app)
+				}
 
 				entriesField := &graphql.Field{
 					Type: graphql.NewList(entryType),
 					Args: args,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-						c := p.Source.(types.Collection)
-						return getMapElements(c, p.Args, mapAppendEntry)
+						return getElements(p, mapAppendEntry)
 					},
 				}
 				fields[entriesKey] = entriesField
 				fields[elementsKey] = entriesField
+				fields[pageKey] = tc.pageField(nomsType, entryType)
+				if aggregate := tc.aggregateField(nomsType, args); aggregate != nil {
+					fields[aggregateKey] = aggregate
+				}
+				if explain := tc.explainField(nomsType, args); explain != nil {
+					fields[explainKey] = explain
+				}
 
 				fields[keysKey] = &graphql.Field{
 					Type: graphql.NewList(keyType),
 					Args: args,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-						c := p.Source.(types.Collection)
-						return getMapElements(c, p.Args, mapAppendKey)
+						return getElements(p, mapAppendKey)
 					},
 				}
 				fields[valuesKey] = &graphql.Field{
 					Type: graphql.NewList(valueType),
 					Args: args,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-						c := p.Source.(types.Collection)
-						return getMapElements(c, p.Args, mapAppendValue)
+						return getElements(p, mapAppendValue)
 					},
 				}
 			}
@@ -910,7 +972,7 @@ func mapAppendEntry(slice []interface{}, k, v types.Value) []interface{} {
 //	 targetValue: <ValueType>!
 // }
 func (tc *TypeConverter) refToGraphQLObject(nomsType *types.Type) *graphql.Object {
-	return graphql.NewObject(graphql.ObjectConfig{
+	return newObject(graphql.ObjectConfig{
 		Name: tc.getTypeName(nomsType),
 		Fields: graphql.FieldsThunk(func() graphql.Fields {
 			nomsTargetType := nomsType.Desc.(types.CompoundDesc).ElemTypes[0]
@@ -956,6 +1018,10 @@ func MaybeGetScalar(v types.Value) interface{} {
 // InputToNomsValue converts a GraphQL input value (as used in arguments and
 // variables) to a Noms value.
 func InputToNomsValue(arg interface{}, nomsType *types.Type) types.Value {
+	if v, ok := arg.(types.Value); ok {
+		// Custom scalars parse their input to Noms values.
+		return v
+	}
 	switch nomsType.TargetKind() {
 	case types.BoolKind:
 		return types.Bool(arg.(bool))
@@ -16,6 +16,8 @@ import (
 // Parser provides ways to parse Noms types.
 type Parser struct {
 	lex *lexer
+	// names are the types declared by a schema file, if it's being parsed.
+	names map[string]*types.Type
 }
 
 // ParserOptions allows passing options into New.
@@ -28,10 +30,10 @@ type ParserOptions struct {
 func New(r io.Reader, options ParserOptions) *Parser {
 	s := scanner.Scanner{}
 	s.Filename = options.Filename
-	s.Mode = scanner.ScanIdents | scanner.ScanComments | scanner.SkipComments
+	s.Mode = scanner.ScanIdents | scanner.ScanStrings | scanner.ScanComments | scanner.SkipComments
 	s.Init(r)
 	lex := lexer{scanner: &s}
-	return &Parser{&lex}
+	return &Parser{lex: &lex}
 }
 
 // ParseType parses a string describing a Noms type.
@@ -72,6 +74,7 @@ func (p *Parser) ensureAtEnd() {
 //   RefType
 //   SetType
 //   StructType
+//   TypeName
 //
 // CycleType :
 //   `Cycle` `<` StructName `>`
@@ -157,6 +160,9 @@ func (p *Parser) parseTypeWithoutUnion() *types.Type {
 		case "Cycle":
 			return p.parseCycleType()
 		}
+		if p.names != nil {
+			return p.parseTypeName()
+		}
 	}
 	p.lex.unexpectedToken(tok)
 	return nil
@@ -0,0 +1,230 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nomdl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/scanner"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Schema is the types declared by a schema file, by name. Schema files
+// declare types with the syntax of ParseType, and can use the names of the
+// types declared before them, and those of the schema files they import:
+//
+//   // Comments are like Go's.
+//   import "geo.noms"         // declares Point
+//   import time "time.noms"   // declares time.Date
+//
+//   type Photo = struct Photo {
+//     location?: Point,
+//     taken: time.Date,
+//     tags: Set<String>,
+//   }
+type Schema struct {
+	// Names are the names of the types the schema file declares, in order,
+	// without those of the files it imports.
+	Names []string
+	// Types are the types the schema file declares, by name.
+	Types map[string]*types.Type
+}
+
+// ParseSchemaFile parses the schema file at path, and the files it imports,
+// whose paths are relative to the directory of the file importing them.
+func ParseSchemaFile(path string) (*Schema, error) {
+	return newSchemaLoader().load(path, scanner.Position{})
+}
+
+// ParseSchema parses a schema file read from r. Imports are relative to the
+// directory of options.Filename.
+func ParseSchema(r io.Reader, options ParserOptions) (schema *Schema, err error) {
+	l := newSchemaLoader()
+	err = catchSyntaxError(func() {
+		schema = l.parse(r, options)
+	})
+	return
+}
+
+// String returns the schema as a schema file which declares the same types.
+// Types declared by other types are written out in full, so the file has no
+// imports.
+func (s *Schema) String() string {
+	buf := &bytes.Buffer{}
+	for i, name := range s.Names {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "type %s = %s\n", name, s.Types[name].Describe())
+	}
+	return buf.String()
+}
+
+// schemaLoader parses schema files and their imports, parsing each file once.
+type schemaLoader struct {
+	loaded  map[string]*Schema
+	loading map[string]bool
+}
+
+func newSchemaLoader() *schemaLoader {
+	return &schemaLoader{map[string]*Schema{}, map[string]bool{}}
+}
+
+// load returns the schema of the file at path, which is imported at pos, if
+// it's imported.
+func (l *schemaLoader) load(path string, pos scanner.Position) (schema *Schema, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if schema, ok := l.loaded[path]; ok {
+		return schema, nil
+	}
+	if l.loading[path] {
+		return nil, syntaxError{fmt.Sprintf("Import cycle of %s", path), pos}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l.loading[path] = true
+	defer delete(l.loading, path)
+	err = catchSyntaxError(func() {
+		schema = l.parse(f, ParserOptions{Filename: path})
+	})
+	if err == nil {
+		l.loaded[path] = schema
+	}
+	return
+}
+
+// Schema :
+//   Import* Declaration*
+//
+// Import :
+//   `import` ImportName? String
+//
+// ImportName :
+//   Ident
+//
+// Declaration :
+//   `type` Ident `=` Type
+//
+// Types can also be the names of declared types:
+//
+// TypeName :
+//   (ImportName `.`)? Ident
+
+func (l *schemaLoader) parse(r io.Reader, options ParserOptions) *Schema {
+	p := New(r, options)
+	p.names = map[string]*types.Type{}
+	schema := &Schema{Types: map[string]*types.Type{}}
+
+	for p.lex.peek() == scanner.Ident {
+		p.lex.next()
+		keyword := p.lex.tokenText()
+		if keyword != "import" {
+			p.parseDeclaration(keyword, schema)
+			break
+		}
+		p.parseImport(l)
+	}
+	for p.lex.peek() != scanner.EOF {
+		p.lex.eat(scanner.Ident)
+		p.parseDeclaration(p.lex.tokenText(), schema)
+	}
+	p.ensureAtEnd()
+	return schema
+}
+
+func (p *Parser) parseImport(l *schemaLoader) {
+	pos := p.lex.pos()
+	alias := ""
+	if p.lex.peek() == scanner.Ident {
+		p.lex.next()
+		alias = p.lex.tokenText()
+	}
+	p.lex.eat(scanner.String)
+	path, err := strconv.Unquote(p.lex.tokenText())
+	if err != nil {
+		raiseSyntaxError("Invalid import path "+p.lex.tokenText(), p.lex.pos())
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.lex.scanner.Filename), path)
+	}
+
+	imported, err := l.load(path, pos)
+	if err != nil {
+		if err, ok := err.(syntaxError); ok {
+			panic(err)
+		}
+		raiseSyntaxError(fmt.Sprintf("Can't import %s: %s", path, err), pos)
+	}
+	for _, name := range imported.Names {
+		if alias != "" {
+			p.declare(alias+"."+name, imported.Types[name], pos)
+		} else {
+			p.declare(name, imported.Types[name], pos)
+		}
+	}
+}
+
+func (p *Parser) parseDeclaration(keyword string, schema *Schema) {
+	if keyword == "import" {
+		raiseSyntaxError("Imports must come before the declarations", p.lex.pos())
+	}
+	if keyword != "type" {
+		raiseSyntaxError(fmt.Sprintf(`Unexpected %s, expected "type"`, keyword), p.lex.pos())
+	}
+	p.lex.eat(scanner.Ident)
+	name := p.lex.tokenText()
+	pos := p.lex.pos()
+	p.lex.eat('=')
+	t := p.parseType()
+	p.declare(name, t, pos)
+	schema.Names = append(schema.Names, name)
+	schema.Types[name] = t
+}
+
+// declare makes name refer to t in the types parsed by p.
+func (p *Parser) declare(name string, t *types.Type, pos scanner.Position) {
+	if isTypeKeyword(name) {
+		raiseSyntaxError(fmt.Sprintf("Can't declare %s, which is a built-in type", name), pos)
+	}
+	if _, ok := p.names[name]; ok {
+		raiseSyntaxError(fmt.Sprintf("%s is declared twice", name), pos)
+	}
+	p.names[name] = t
+}
+
+// parseTypeName returns the type declared as the name which was just read,
+// which is qualified by the name of its import if it's followed by a `.`.
+func (p *Parser) parseTypeName() *types.Type {
+	name, pos := p.lex.tokenText(), p.lex.pos()
+	if p.lex.eatIf('.') {
+		p.lex.eat(scanner.Ident)
+		name += "." + p.lex.tokenText()
+	}
+	t, ok := p.names[name]
+	if !ok {
+		raiseSyntaxError(fmt.Sprintf("Undeclared type %s", name), pos)
+	}
+	return t
+}
+
+func isTypeKeyword(name string) bool {
+	switch name {
+	case "Blob", "Bool", "Number", "String", "Type", "Value", "struct", "Map", "List", "Set", "Ref", "Cycle":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nomdl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestParseSchema(t *testing.T) {
+	assert := assert.New(t)
+	schema, err := ParseSchema(strings.NewReader(`
+		// A person.
+		type Person = struct Person {
+			name: String,
+			friends: Set<Ref<Cycle<Person>>>,
+		}
+		/* People by name. */
+		type People = Map<String, Person>
+		type Id = Number | String
+	`), ParserOptions{})
+	assert.NoError(err)
+	assert.Equal([]string{"Person", "People", "Id"}, schema.Names)
+
+	person := MustParseType(`struct Person {
+		name: String,
+		friends: Set<Ref<Cycle<Person>>>,
+	}`)
+	assert.True(person.Equals(schema.Types["Person"]))
+	assert.True(types.MakeMapType(types.StringType, person).Equals(schema.Types["People"]))
+	assert.True(types.MakeUnionType(types.NumberType, types.StringType).Equals(schema.Types["Id"]))
+
+	// Printing a schema round-trips.
+	printed, err := ParseSchema(strings.NewReader(schema.String()), ParserOptions{})
+	assert.NoError(err)
+	assert.Equal(schema.Names, printed.Names)
+	for _, name := range schema.Names {
+		assert.True(schema.Types[name].Equals(printed.Types[name]), name)
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	for code, msg := range map[string]string{
+		"type A = B":                         "Undeclared type B, example:1:11",
+		"type A = Number\ntype A = String":   "A is declared twice, example:2:7",
+		"type List = Number":                 "Can't declare List, which is a built-in type, example:1:10",
+		"type A = Number\nimport \"b.noms\"": "Imports must come before the declarations, example:2:7",
+		"typo A = Number":                    `Unexpected typo, expected "type", example:1:5`,
+		`import "does-not-exist.noms"`:       "",
+	} {
+		_, err := ParseSchema(strings.NewReader(code), ParserOptions{Filename: "example"})
+		if assert.Error(t, err, code) && msg != "" {
+			assert.Equal(t, msg, err.Error(), code)
+		}
+	}
+}
+
+func TestParseSchemaFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "schema")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	write := func(name, code string) {
+		assert.NoError(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0777))
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(code), 0666))
+	}
+
+	write("geo/point.noms", `type Point = struct Point { lat: Number, lon: Number }`)
+	write("geo/geo.noms", `
+		import "point.noms"
+		type Place = struct Place { name: String, at: Point }
+	`)
+	write("photo.noms", `
+		import "geo/geo.noms"
+		import p "geo/point.noms"
+		type Photo = struct Photo { place?: Place, center: p.Point }
+	`)
+	schema, err := ParseSchemaFile(filepath.Join(dir, "photo.noms"))
+	assert.NoError(err)
+	assert.Equal([]string{"Photo"}, schema.Names)
+	assert.True(MustParseType(`struct Photo {
+		place?: struct Place { name: String, at: struct Point { lat: Number, lon: Number } },
+		center: struct Point { lat: Number, lon: Number },
+	}`).Equals(schema.Types["Photo"]))
+
+	// Names of imports aren't declared by the files importing them.
+	write("bad.noms", `
+		import "photo.noms"
+		type A = Place
+	`)
+	_, err = ParseSchemaFile(filepath.Join(dir, "bad.noms"))
+	assert.Error(err)
+
+	write("a.noms", `import "b.noms"`)
+	write("b.noms", `import "a.noms"`)
+	_, err = ParseSchemaFile(filepath.Join(dir, "a.noms"))
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "Import cycle")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package nomserrors has the classes of the errors that reading, writing and
+// syncing noms databases fail with, so that programs can handle each class,
+// e.g. by retrying after network errors, rather than matching on the text of
+// the errors. The errors the Try functions of packages chunks and datas
+// return, and those the Database methods return, are of one of the classes,
+// which Class returns, if they're of any:
+//
+//	switch nomserrors.Class(err) {
+//	case nomserrors.ErrNetwork:
+//	  // retry
+//	case nomserrors.ErrAuth:
+//	  // ask for credentials
+//	}
+//
+// errors.Is(err, class) reports whether err is of class too.
+package nomserrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrChunkNotFound is the class of errors due to chunks which should be
+	// in a store not being in it, e.g. the chunks a value refers to.
+	ErrChunkNotFound = errors.New("chunk not found")
+	// ErrRootConflict is the class of errors due to the root of a store
+	// being changed by someone else while it was being updated.
+	ErrRootConflict = errors.New("root changed concurrently")
+	// ErrVersionMismatch is the class of errors due to a store being of a
+	// version of the noms format this one can't read or write.
+	ErrVersionMismatch = errors.New("noms version mismatch")
+	// ErrAuth is the class of errors due to a server refusing the
+	// credentials of a request, or the lack of them.
+	ErrAuth = errors.New("not authorized")
+	// ErrNetwork is the class of errors due to failing to reach a server,
+	// or to read its response.
+	ErrNetwork = errors.New("network error")
+	// ErrDatabaseNotFound is the class of errors due to a database which
+	// should exist not existing, e.g. because its name is misspelled.
+	ErrDatabaseNotFound = errors.New("database not found")
+	// ErrDatabaseExists is the class of errors due to a database which
+	// should be created already existing.
+	ErrDatabaseExists = errors.New("database already exists")
+)
+
+var classes = []error{ErrChunkNotFound, ErrRootConflict, ErrVersionMismatch, ErrAuth, ErrNetwork, ErrDatabaseNotFound, ErrDatabaseExists}
+
+// Error is an error of one of the classes, which is caused by another error,
+// e.g. the one an http request failed with.
+type Error struct {
+	class error
+	err   error
+}
+
+// Wrap returns err as an error of class, or nil if err is nil.
+func Wrap(class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{class, err}
+}
+
+// Errorf returns an error of class, with the text fmt.Sprintf returns.
+func Errorf(class error, format string, args ...interface{}) error {
+	return Wrap(class, fmt.Errorf(format, args...))
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error which caused e.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is returns whether target is the class of e.
+func (e *Error) Is(target error) bool {
+	return target == e.class
+}
+
+// Class returns the class of err, or nil if it isn't of one. The errors err
+// wraps are looked through, including those it's the cause of, as with the
+// errors package d panics with.
+func Class(err error) error {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e.class
+		}
+		for _, class := range classes {
+			if err == class {
+				return class
+			}
+		}
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Cause() error }:
+			err = e.Cause()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
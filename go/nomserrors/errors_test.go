@@ -0,0 +1,39 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nomserrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestClass(t *testing.T) {
+	assert := assert.New(t)
+	cause := errors.New("connection refused")
+	err := Wrap(ErrNetwork, cause)
+	assert.Equal("connection refused", err.Error())
+	assert.Equal(ErrNetwork, Class(err))
+	assert.True(errors.Is(err, ErrNetwork))
+	assert.True(errors.Is(err, cause))
+	assert.False(errors.Is(err, ErrAuth))
+
+	err = Errorf(ErrChunkNotFound, "Missing chunks: %d", 2)
+	assert.Equal("Missing chunks: 2", err.Error())
+	assert.Equal(ErrChunkNotFound, Class(err))
+
+	// Errors are looked through for their class.
+	assert.Equal(ErrAuth, Class(fmt.Errorf("reading root: %w", Wrap(ErrAuth, cause))))
+	assert.Equal(ErrVersionMismatch, Class(d.Wrap(Errorf(ErrVersionMismatch, "bad version"))))
+	assert.Equal(ErrRootConflict, Class(ErrRootConflict))
+	assert.Equal(ErrDatabaseNotFound, Class(Errorf(ErrDatabaseNotFound, "Database not found: %s", "/tmp/db")))
+
+	assert.Nil(Class(cause))
+	assert.Nil(Class(nil))
+	assert.Nil(Wrap(ErrNetwork, nil))
+}
@@ -2,12 +2,14 @@
 // Licensed under the Apache License, version 2.0:
 // http://www.apache.org/licenses/LICENSE-2.0
 
-package main
+package nomsfs
 
 import (
 	"os"
 	"testing"
 
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/util/clienttest"
 	"github.com/attic-labs/testify/assert"
@@ -21,6 +23,15 @@ type fuseTestSuite struct {
 	clienttest.ClientTestSuite
 }
 
+// start opens the filesystem of dataset, and passes it to mount.
+func start(dataset string, mount func(fs pathfs.FileSystem)) {
+	db, ds, err := config.NewResolver().GetDataset(dataset)
+	d.PanicIfError(err)
+	fs, err := New(db, ds, Options{})
+	d.PanicIfError(err)
+	mount(fs)
+}
+
 func assertAttr(s *fuseTestSuite, fs pathfs.FileSystem, path string, mode uint32, size uint64) {
 	attr, code := fs.GetAttr(path, nil)
 	assert.Equal(s.T(), fuse.OK, code)
@@ -2,14 +2,17 @@
 // Licensed under the Apache License, version 2.0:
 // http://www.apache.org/licenses/LICENSE-2.0
 
-package main
+package nomsfs
 
 import (
 	"bytes"
 	"os"
 	"testing"
 
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
 	"github.com/attic-labs/testify/suite"
 
@@ -126,3 +129,41 @@ func (s *fuseTestSuite) TestMultipleOpens() {
 	assert.Equal(s.T(), 11, rr.Size())
 	assert.Equal(s.T(), "321 contact", string(data))
 }
+
+func (s *fuseTestSuite) TestCommitOnFsync() {
+	datasetName := "TestCommitOnFsync"
+	str := spec.CreateValueSpecString("nbs", s.DBDir, datasetName)
+
+	db, ds, err := config.NewResolver().GetDataset(str)
+	s.NoError(err)
+	defer db.Close()
+	message := "saved by nomsfs"
+	testfs, err := New(db, ds, Options{Meta: func() (types.Struct, error) {
+		return types.NewStruct("", types.StructData{"message": types.String(message)}), nil
+	}})
+	s.NoError(err)
+
+	file, code := testfs.Create("draft", uint32(os.O_CREATE|os.O_RDWR), 0644, nil)
+	s.Equal(fuse.OK, code)
+	file.Write([]byte("staged"), 0)
+	s.Equal(fuse.OK, file.Flush())
+	_, ok := db.GetDataset(datasetName).MaybeHead()
+	s.False(ok, "changes are only staged until fsync")
+
+	s.Equal(fuse.OK, file.Fsync(0))
+	head, ok := db.GetDataset(datasetName).MaybeHead()
+	s.True(ok)
+	s.Equal(types.String(message), head.Get(datas.MetaField).(types.Struct).Get("message"))
+
+	// Commit only commits again after more changes.
+	s.NoError(testfs.Commit())
+	s.True(db.GetDataset(datasetName).Head().Equals(head))
+	s.Equal(fuse.OK, testfs.Mkdir("notes", 0755, nil))
+	testfs.OnUnmount()
+	s.False(db.GetDataset(datasetName).Head().Equals(head))
+
+	reopened, err := New(db, db.GetDataset(datasetName), Options{})
+	s.NoError(err)
+	assertAttr(s, reopened, "draft", 0644|fuse.S_IFREG, 6)
+	assertAttr(s, reopened, "notes", 0755|fuse.S_IFDIR, 0)
+}
@@ -0,0 +1,284 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package p2p replicates datasets between noms nodes on the same network,
+// without a server. Each Node serves its chunk store read-only over the noms
+// HTTP protocol, and periodically multicasts an Announcement of the heads of
+// its datasets to the other Nodes. A Node which hears of a head it doesn't
+// have pulls the chunks of the head from the announcing Node and fast-forwards
+// its dataset to it.
+//
+// Announcements are signed with a secret shared by the Nodes, and a Node only
+// replicates the datasets it's told to, so that another host on the network
+// can't make it pull from it, or move the heads of its other datasets.
+//
+// Only fast-forwards are replicated: if two Nodes commit to a dataset
+// concurrently, each keeps its own head until one of them merges the other's,
+// e.g. with noms merge.
+package p2p
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+)
+
+// DefaultGroup is the multicast address Nodes announce themselves to unless
+// another is set.
+const DefaultGroup = "239.192.77.77:7645"
+
+var (
+	// ErrNoSecret is returned by Run and HandleAnnouncement if the Node has
+	// no Secret.
+	ErrNoSecret = errors.New("p2p: the node has no secret")
+	// ErrBadMAC is returned by HandleAnnouncement if the Announcement isn't
+	// signed with the Secret of the Node.
+	ErrBadMAC = errors.New("p2p: the announcement isn't signed with the secret of the node")
+)
+
+// Announcement is multicast by a Node to tell its peers where it serves its
+// chunk store and the heads of its datasets.
+type Announcement struct {
+	ID    string            `json:"id"`
+	Port  int               `json:"port"`
+	Heads map[string]string `json:"heads"` // dataset ID to head hash
+	// MAC is the hex HMAC-SHA256 of the rest of the Announcement, keyed with
+	// the Secret of the Node.
+	MAC string `json:"mac"`
+}
+
+// sign returns the MAC of a, keyed with secret.
+func (a Announcement) sign(secret string) string {
+	a.MAC = ""
+	data, err := json.Marshal(a)
+	d.PanicIfError(err)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Node replicates Datasets with its peers. Its fields must be set before Run
+// is called.
+type Node struct {
+	// ID distinguishes the Node from its peers, and defaults to a random one.
+	ID string
+	// Datasets are the IDs of the datasets announced and replicated. Peers
+	// can't fast-forward any others, and if it's empty, none are.
+	Datasets []string
+	// Secret signs the Announcements of the Node, and those of its peers
+	// must be signed with it as well. It must be set, and only known to the
+	// Nodes, since a Node pulls the heads announced with it from any host.
+	Secret string
+	// Port is the port the chunk store is served on; 0 picks a free one.
+	Port int
+	// Group is the multicast address of the Node and its peers, DefaultGroup
+	// if empty.
+	Group string
+	// Interval is the time between Announcements, 10 seconds if 0.
+	Interval time.Duration
+
+	cs     chunks.ChunkStore
+	db     datas.Database
+	server *datas.RemoteDatabaseServer
+	mu     sync.Mutex // serializes syncs
+	stop   chan struct{}
+}
+
+// NewNode returns a Node which replicates the datasets of cs.
+func NewNode(cs chunks.ChunkStore) *Node {
+	return &Node{
+		ID:   hash.Of([]byte(fmt.Sprintf("%d", time.Now().UnixNano()))).String()[:8],
+		cs:   cs,
+		db:   datas.NewDatabase(cs),
+		stop: make(chan struct{}),
+	}
+}
+
+// Database returns the database of the Node. Commits made through it are
+// announced to the peers of the Node.
+func (n *Node) Database() datas.Database {
+	return n.db
+}
+
+// Run serves the chunk store, announces the Node and replicates the heads its
+// peers announce until Stop is called.
+func (n *Node) Run() error {
+	if n.Secret == "" {
+		return ErrNoSecret
+	}
+	group := n.Group
+	if group == "" {
+		group = DefaultGroup
+	}
+	addr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return err
+	}
+	listener, err := net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		return err
+	}
+	sender, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	defer sender.Close()
+
+	n.Serve()
+	go func() {
+		<-n.stop
+		listener.Close()
+	}()
+	go n.receive(listener)
+
+	interval := n.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		data, err := json.Marshal(n.Announcement())
+		d.PanicIfError(err)
+		if _, err := sender.Write(data); err != nil {
+			verbose.Log("Announcing %s failed: %s", n.ID, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-n.stop:
+			return nil
+		}
+	}
+}
+
+// Serve starts serving the chunk store read-only, and returns once it's
+// ready. Run calls it; it's only needed to replicate without multicast, by
+// passing Announcements to HandleAnnouncement.
+func (n *Node) Serve() {
+	if n.server != nil {
+		return
+	}
+	n.server = datas.NewRemoteDatabaseServer(n.cs, n.Port)
+	n.server.ReadOnly = true
+	ready := make(chan struct{})
+	n.server.Ready = func() { close(ready) }
+	go n.server.Run()
+	<-ready
+	n.Port = n.server.Port()
+}
+
+// Stop stops the Node and closes its chunk store.
+func (n *Node) Stop() {
+	close(n.stop)
+	if n.server != nil {
+		n.server.Stop()
+	} else {
+		n.db.Close()
+	}
+}
+
+func (n *Node) receive(conn *net.UDPConn) {
+	buf := make([]byte, 64*1024)
+	for {
+		size, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return // closed by Stop
+		}
+		var a Announcement
+		if err := json.Unmarshal(buf[:size], &a); err != nil || a.ID == n.ID {
+			continue
+		}
+		if _, err := n.HandleAnnouncement(from.IP.String(), a); err != nil {
+			verbose.Log("Replicating from %s failed: %s", a.ID, err)
+		}
+	}
+}
+
+// Announcement returns the Announcement of the current heads of the Node,
+// signed with its Secret.
+func (n *Node) Announcement() Announcement {
+	a := Announcement{ID: n.ID, Port: n.Port, Heads: map[string]string{}}
+	n.db.Datasets().IterAll(func(k, v types.Value) {
+		if id := string(k.(types.String)); n.replicates(id) {
+			a.Heads[id] = v.(types.Ref).TargetHash().String()
+		}
+	})
+	a.MAC = a.sign(n.Secret)
+	return a
+}
+
+func (n *Node) replicates(id string) bool {
+	for _, ds := range n.Datasets {
+		if ds == id {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleAnnouncement pulls the heads announced by the peer at host which the
+// Node doesn't have, and fast-forwards its datasets to them. It returns the
+// IDs of the datasets updated. Heads which aren't descendants of the Node's
+// are left for the application to merge, and those of datasets which aren't
+// in Datasets are ignored. If a isn't signed with the Secret of the Node,
+// HandleAnnouncement returns ErrBadMAC.
+func (n *Node) HandleAnnouncement(host string, a Announcement) (updated []string, err error) {
+	if n.Secret == "" {
+		return nil, ErrNoSecret
+	}
+	if !hmac.Equal([]byte(a.MAC), []byte(a.sign(n.Secret))) {
+		return nil, ErrBadMAC
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var peer datas.Database
+	defer func() {
+		if peer != nil {
+			peer.Close()
+		}
+	}()
+	err = d.Try(func() {
+		for id, head := range a.Heads {
+			h, ok := hash.MaybeParse(head)
+			if !n.replicates(id) || !ok {
+				continue
+			}
+			ds := n.db.GetDataset(id)
+			sinkRef, hasHead := ds.MaybeHeadRef()
+			if hasHead && sinkRef.TargetHash() == h {
+				continue
+			}
+			if peer == nil {
+				peer = datas.NewRemoteDatabase(fmt.Sprintf("http://%s", net.JoinHostPort(host, fmt.Sprintf("%d", a.Port))), "")
+			}
+			sourceRef, ok := peer.GetDataset(id).MaybeHeadRef()
+			if !ok || sourceRef.TargetHash() != h {
+				continue // the peer's head moved on since it announced it
+			}
+			datas.PullWithFlush(peer, n.db, sourceRef, sinkRef, 4, nil)
+			if _, err := n.db.FastForward(ds, sourceRef); err == datas.ErrMergeNeeded {
+				continue
+			} else {
+				d.PanicIfError(err)
+			}
+			updated = append(updated, id)
+		}
+	})
+	return
+}
@@ -0,0 +1,133 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package p2p
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+const testSecret = "s3cret"
+
+func newTestNode(datasets ...string) *Node {
+	n := NewNode(chunks.NewMemoryStore())
+	n.Datasets, n.Secret = datasets, testSecret
+	return n
+}
+
+func TestHandleAnnouncement(t *testing.T) {
+	assert := assert.New(t)
+	src, sink := newTestNode("ds", "other"), newTestNode("ds")
+	src.Serve()
+	defer src.Stop()
+	defer sink.Stop()
+
+	srcDB := src.Database()
+	ds, err := srcDB.CommitValue(srcDB.GetDataset("ds"), types.NewList(types.Number(1), types.Number(2)))
+	assert.NoError(err)
+	_, err = srcDB.CommitValue(srcDB.GetDataset("other"), types.Number(1))
+	assert.NoError(err)
+
+	a := src.Announcement()
+	assert.Len(a.Heads, 2)
+	updated, err := sink.HandleAnnouncement("localhost", a)
+	assert.NoError(err)
+	assert.Equal([]string{"ds"}, updated)
+	assert.True(ds.HeadValue().Equals(sink.Database().GetDataset("ds").HeadValue()))
+	assert.False(sink.Database().GetDataset("other").HasHead())
+
+	// Announcing the same head again is a no-op.
+	updated, err = sink.HandleAnnouncement("localhost", src.Announcement())
+	assert.NoError(err)
+	assert.Empty(updated)
+
+	ds, err = srcDB.CommitValue(ds, types.NewList(types.Number(3)))
+	assert.NoError(err)
+	updated, err = sink.HandleAnnouncement("localhost", src.Announcement())
+	assert.NoError(err)
+	assert.Equal([]string{"ds"}, updated)
+	assert.Equal(ds.HeadRef(), sink.Database().GetDataset("ds").HeadRef())
+}
+
+func TestHandleAnnouncementDiverged(t *testing.T) {
+	assert := assert.New(t)
+	src, sink := newTestNode("ds"), newTestNode("ds")
+	src.Serve()
+	defer src.Stop()
+	defer sink.Stop()
+
+	_, err := src.Database().CommitValue(src.Database().GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	sinkDS, err := sink.Database().CommitValue(sink.Database().GetDataset("ds"), types.Number(2))
+	assert.NoError(err)
+
+	updated, err := sink.HandleAnnouncement("localhost", src.Announcement())
+	assert.NoError(err)
+	assert.Empty(updated)
+	assert.Equal(sinkDS.HeadRef(), sink.Database().GetDataset("ds").HeadRef())
+}
+
+func TestHandleAnnouncementUnreachable(t *testing.T) {
+	assert := assert.New(t)
+	sink := newTestNode("ds")
+	defer sink.Stop()
+
+	a := Announcement{ID: "gone", Port: 1, Heads: map[string]string{"ds": "0123456789abcdefghijklmnopqrstuv"}}
+	a.MAC = a.sign(testSecret)
+	_, err := sink.HandleAnnouncement("localhost", a)
+	assert.Error(err)
+}
+
+func TestHandleAnnouncementUnsigned(t *testing.T) {
+	assert := assert.New(t)
+	src, sink := newTestNode("ds"), newTestNode("ds")
+	src.Serve()
+	defer src.Stop()
+	defer sink.Stop()
+
+	_, err := src.Database().CommitValue(src.Database().GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+
+	a := src.Announcement()
+	a.MAC = ""
+	_, err = sink.HandleAnnouncement("localhost", a)
+	assert.Equal(ErrBadMAC, err)
+
+	src.Secret = "other"
+	_, err = sink.HandleAnnouncement("localhost", src.Announcement())
+	assert.Equal(ErrBadMAC, err)
+
+	a = src.Announcement()
+	a.Heads["ds"] = a.Heads["ds"][1:] + "0"
+	_, err = sink.HandleAnnouncement("localhost", a)
+	assert.Equal(ErrBadMAC, err)
+
+	sink.Secret = ""
+	_, err = sink.HandleAnnouncement("localhost", src.Announcement())
+	assert.Equal(ErrNoSecret, err)
+	assert.Equal(ErrNoSecret, sink.Run())
+	assert.False(sink.Database().GetDataset("ds").HasHead())
+}
+
+func TestHandleAnnouncementOnlyDatasets(t *testing.T) {
+	assert := assert.New(t)
+	src, sink := newTestNode("ds"), newTestNode()
+	src.Serve()
+	defer src.Stop()
+	defer sink.Stop()
+
+	_, err := src.Database().CommitValue(src.Database().GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	assert.Len(src.Announcement().Heads, 1)
+	assert.Empty(sink.Announcement().Heads)
+
+	updated, err := sink.HandleAnnouncement("localhost", src.Announcement())
+	assert.NoError(err)
+	assert.Empty(updated)
+	assert.False(sink.Database().GetDataset("ds").HasHead())
+}
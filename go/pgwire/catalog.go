@@ -0,0 +1,150 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package pgwire
+
+import (
+	"strconv"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/query"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Postgres type OIDs and names of the columns.
+const (
+	oidBool   = 16
+	oidText   = 25
+	oidFloat8 = 701
+)
+
+var typeNames = map[int32]string{
+	oidBool:   "boolean",
+	oidText:   "text",
+	oidFloat8: "double precision",
+}
+
+// columnType returns the OID of the Postgres type of the values at path in the
+// rows of coll: float8 for Numbers, bool for Bools and otherwise text.
+func columnType(coll types.Value, path []string) int32 {
+	desc := types.TypeOf(coll).Desc.(types.CompoundDesc)
+	var t *types.Type
+	switch path[0] {
+	case query.IndexField:
+		return oidFloat8
+	case query.KeyField:
+		if coll.Kind() == types.ListKind {
+			return oidText
+		}
+		t = desc.ElemTypes[0]
+	default:
+		t = desc.ElemTypes[len(desc.ElemTypes)-1]
+		if path[0] != query.ValueField {
+			t = fieldType(t, path)
+		}
+	}
+	if t == nil {
+		return oidText
+	}
+	switch t.TargetKind() {
+	case types.NumberKind:
+		return oidFloat8
+	case types.BoolKind:
+		return oidBool
+	}
+	return oidText
+}
+
+// fieldType returns the type of the field at path of structs of type t, or nil
+// if it isn't known to be a single type.
+func fieldType(t *types.Type, path []string) *types.Type {
+	for _, name := range path {
+		if t.TargetKind() != types.StructKind {
+			return nil
+		}
+		var ft *types.Type
+		t.Desc.(types.StructDesc).IterFields(func(n string, typ *types.Type, optional bool) {
+			if n == name && !optional {
+				ft = typ
+			}
+		})
+		if ft == nil {
+			return nil
+		}
+		t = ft
+	}
+	return t
+}
+
+// formatValue returns the text format of v as a value of the Postgres type
+// oid.
+func formatValue(v types.Value, oid int32) []byte {
+	switch v := v.(type) {
+	case types.Number:
+		if oid == oidFloat8 {
+			return []byte(strconv.FormatFloat(float64(v), 'g', -1, 64))
+		}
+	case types.Bool:
+		if oid == oidBool {
+			if v {
+				return []byte("t")
+			}
+			return []byte("f")
+		}
+	case types.String:
+		return []byte(v)
+	}
+	return []byte(types.EncodedValue(v))
+}
+
+// isTable returns true if v can be queried as a table.
+func isTable(v types.Value) bool {
+	k := v.Kind()
+	return k == types.ListKind || k == types.SetKind || k == types.MapKind
+}
+
+// catalogTable returns the value of the information_schema table named name,
+// which describes the tables of db.
+func catalogTable(db datas.Database, name string) (types.Value, bool) {
+	switch name {
+	case "information_schema.tables":
+		rows := []types.Value{}
+		eachTable(db, func(table string, v types.Value) {
+			rows = append(rows, types.NewStruct("", types.StructData{
+				"table_schema": types.String("public"),
+				"table_name":   types.String(table),
+				"table_type":   types.String("BASE TABLE"),
+			}))
+		})
+		return types.NewList(rows...), true
+	case "information_schema.columns":
+		star := &query.Query{}
+		rows := []types.Value{}
+		eachTable(db, func(table string, v types.Value) {
+			columns, _ := star.Columns(v)
+			for i, c := range columns {
+				rows = append(rows, types.NewStruct("", types.StructData{
+					"table_schema":     types.String("public"),
+					"table_name":       types.String(table),
+					"column_name":      types.String(c.Name),
+					"ordinal_position": types.Number(i + 1),
+					"data_type":        types.String(typeNames[columnType(v, c.Path)]),
+				}))
+			}
+		})
+		return types.NewList(rows...), true
+	}
+	return nil, false
+}
+
+// eachTable calls cb with the ID and head value of each dataset of db which
+// can be queried as a table.
+func eachTable(db datas.Database, cb func(table string, v types.Value)) {
+	db.Datasets().IterAll(func(k, _ types.Value) {
+		id := string(k.(types.String))
+		if v, ok := db.GetDataset(id).MaybeHeadValue(); ok && isTable(v) {
+			cb(id, v)
+		}
+	})
+}
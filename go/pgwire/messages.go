@@ -0,0 +1,112 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package pgwire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	protocolVersion = 196608 // 3.0
+	sslRequest      = 80877103
+	cancelRequest   = 80877102
+
+	maxMessageSize = 1 << 24
+)
+
+// SQLSTATE codes of the errors sent to clients.
+const (
+	codeSyntaxError       = "42601"
+	codeUndefinedTable    = "42P01"
+	codeWrongObjectType   = "42809"
+	codeFeatureNotSupport = "0A000"
+	codeProtocolViolation = "08P01"
+	codeInternalError     = "XX000"
+)
+
+// readStartup reads the length-prefixed startup message, which has no type
+// byte, and returns its code and the rest of it.
+func readStartup(r io.Reader) (code uint32, body []byte, err error) {
+	var hdr [8]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(hdr[:4])
+	if size < 8 || size > maxMessageSize {
+		return 0, nil, fmt.Errorf("invalid startup message length %d", size)
+	}
+	body = make([]byte, size-8)
+	_, err = io.ReadFull(r, body)
+	return binary.BigEndian.Uint32(hdr[4:]), body, err
+}
+
+// readMessage reads a message of the frontend, and returns its type and body.
+func readMessage(r io.Reader) (typ byte, body []byte, err error) {
+	var hdr [5]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size < 4 || size > maxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length %d", size)
+	}
+	body = make([]byte, size-4)
+	_, err = io.ReadFull(r, body)
+	return hdr[0], body, err
+}
+
+// cString returns the NUL-terminated string at the start of b, and the rest of
+// b after it.
+func cString(b []byte) (s string, rest []byte, ok bool) {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i]), b[i+1:], true
+		}
+	}
+	return "", nil, false
+}
+
+// message builds a message of the backend.
+type message struct {
+	buf []byte
+}
+
+func newMessage(typ byte) *message {
+	return &message{[]byte{typ, 0, 0, 0, 0}}
+}
+
+func (m *message) byte(b byte) *message {
+	m.buf = append(m.buf, b)
+	return m
+}
+
+func (m *message) int16(i int16) *message {
+	m.buf = append(m.buf, byte(i>>8), byte(i))
+	return m
+}
+
+func (m *message) int32(i int32) *message {
+	m.buf = append(m.buf, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+	return m
+}
+
+func (m *message) string(s string) *message {
+	m.buf = append(append(m.buf, s...), 0)
+	return m
+}
+
+func (m *message) bytes(b []byte) *message {
+	m.buf = append(m.buf, b...)
+	return m
+}
+
+func (m *message) writeTo(w *bufio.Writer) error {
+	binary.BigEndian.PutUint32(m.buf[1:5], uint32(len(m.buf)-1))
+	_, err := w.Write(m.buf)
+	return err
+}
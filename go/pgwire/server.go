@@ -0,0 +1,396 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package pgwire serves the datasets of a database over the PostgreSQL wire
+// protocol, so that SQL clients and BI tools can query them. Each dataset
+// whose head is a List, Set or Map is a table, whose rows are the elements of
+// the collection, as for package query:
+//
+//	SELECT name, age FROM people WHERE _key >= 'm' ORDER BY age LIMIT 10
+//
+// Queries are run by package query, so conditions on _key or _index scan only
+// the part of the collection in range. The tables are described by
+// information_schema.tables and information_schema.columns.
+//
+// Only the simple query protocol is supported, with no authentication or SSL.
+// SET, BEGIN, COMMIT and ROLLBACK are accepted and ignored, and any other
+// statements than SELECT are errors. Columns of Numbers are float8, of Bools
+// bool, and of anything else text, in which Strings are sent as they are and
+// other values in their human readable encoding.
+package pgwire
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/query"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+)
+
+// Server serves the database opened by Open to Postgres clients.
+type Server struct {
+	// Open opens the database for each query, so that each sees the current
+	// heads of the datasets. The database is closed after the query.
+	Open func() (datas.Database, error)
+
+	mu    sync.Mutex
+	l     net.Listener
+	conns map[net.Conn]bool
+}
+
+// NewServer returns a Server of the databases opened by open.
+func NewServer(open func() (datas.Database, error)) *Server {
+	return &Server{Open: open, conns: map[net.Conn]bool{}}
+}
+
+// Serve serves the connections accepted by l until Stop is called.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.l = l
+	s.mu.Unlock()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			stopped := s.l == nil
+			s.mu.Unlock()
+			if stopped {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, c)
+				s.mu.Unlock()
+				c.Close()
+			}()
+			if err := newConn(s, c).serve(); err != nil {
+				verbose.Log("Connection from %s failed: %s", c.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Stop stops serving and closes the open connections.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.l != nil {
+		s.l.Close()
+		s.l = nil
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// conn is a connection of a client.
+type conn struct {
+	s *Server
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func newConn(s *Server, c net.Conn) *conn {
+	return &conn{s, c, bufio.NewReader(c), bufio.NewWriter(c)}
+}
+
+// pgError is an error sent to the client as an ErrorResponse.
+type pgError struct {
+	code string
+	msg  string
+}
+
+func (e pgError) Error() string {
+	return e.msg
+}
+
+func errorf(code, format string, args ...interface{}) pgError {
+	return pgError{code, fmt.Sprintf(format, args...)}
+}
+
+func (pc *conn) serve() error {
+	if ok, err := pc.startup(); !ok || err != nil {
+		return err
+	}
+
+	// After an error in the extended query protocol, messages are discarded
+	// until Sync.
+	failed := false
+	for {
+		typ, body, err := readMessage(pc.r)
+		if err != nil {
+			return nil // the client went away
+		}
+		switch typ {
+		case 'Q':
+			q, _, ok := cString(body)
+			if !ok {
+				return pc.fatal(errorf(codeProtocolViolation, "invalid Query message"))
+			}
+			if err := pc.simpleQuery(q); err != nil {
+				return err
+			}
+		case 'S':
+			failed = false
+			if err := pc.ready(); err != nil {
+				return err
+			}
+		case 'X':
+			return nil
+		case 'P', 'B', 'D', 'E', 'C', 'H':
+			if !failed {
+				failed = true
+				if err := pc.sendError(errorf(codeFeatureNotSupport, "the extended query protocol isn't supported")); err != nil {
+					return err
+				}
+			}
+		default:
+			return pc.fatal(errorf(codeProtocolViolation, "unexpected message type %q", typ))
+		}
+	}
+}
+
+// startup reads the startup message and accepts the connection. It returns
+// false if the connection was only to cancel a query.
+func (pc *conn) startup() (bool, error) {
+	for {
+		code, _, err := readStartup(pc.r)
+		if err != nil {
+			return false, err
+		}
+		switch code {
+		case sslRequest:
+			if _, err := pc.c.Write([]byte{'N'}); err != nil {
+				return false, err
+			}
+			continue
+		case cancelRequest:
+			return false, nil // queries can't be cancelled
+		case protocolVersion:
+		default:
+			return false, pc.fatal(errorf(codeFeatureNotSupport, "unsupported protocol version %d.%d", code>>16, code&0xffff))
+		}
+		break
+	}
+
+	msgs := []*message{newMessage('R').int32(0)} // AuthenticationOk
+	for _, p := range [][2]string{
+		{"server_version", "9.6.0"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+	} {
+		msgs = append(msgs, newMessage('S').string(p[0]).string(p[1]))
+	}
+	msgs = append(msgs, newMessage('K').int32(0).int32(0))
+	for _, m := range msgs {
+		if err := m.writeTo(pc.w); err != nil {
+			return false, err
+		}
+	}
+	return true, pc.ready()
+}
+
+func (pc *conn) ready() error {
+	if err := newMessage('Z').byte('I').writeTo(pc.w); err != nil {
+		return err
+	}
+	return pc.w.Flush()
+}
+
+func (pc *conn) sendError(err error) error {
+	pe, ok := err.(pgError)
+	if !ok {
+		pe = pgError{codeInternalError, err.Error()}
+	}
+	return newMessage('E').
+		byte('S').string("ERROR").
+		byte('V').string("ERROR").
+		byte('C').string(pe.code).
+		byte('M').string(pe.msg).
+		byte(0).writeTo(pc.w)
+}
+
+// fatal sends err and ends the connection.
+func (pc *conn) fatal(err pgError) error {
+	newMessage('E').
+		byte('S').string("FATAL").
+		byte('V').string("FATAL").
+		byte('C').string(err.code).
+		byte('M').string(err.msg).
+		byte(0).writeTo(pc.w)
+	pc.w.Flush()
+	return err
+}
+
+// simpleQuery runs the statements of q until one fails.
+func (pc *conn) simpleQuery(q string) error {
+	stmts := splitStatements(q)
+	if len(stmts) == 0 {
+		if err := newMessage('I').writeTo(pc.w); err != nil {
+			return err
+		}
+		return pc.ready()
+	}
+	for _, stmt := range stmts {
+		if err := pc.statement(stmt); err != nil {
+			if _, ok := err.(pgError); !ok {
+				if _, ok := err.(d.WrappedError); !ok {
+					return err
+				}
+			}
+			if err := pc.sendError(err); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return pc.ready()
+}
+
+// statement runs stmt. It returns a pgError, or a d.WrappedError from reading
+// the database, if stmt failed, and any other error if the connection did.
+func (pc *conn) statement(stmt string) error {
+	verb := strings.ToUpper(strings.Fields(stmt)[0])
+	switch verb {
+	case "SELECT":
+		return pc.selectQuery(stmt)
+	case "SET", "BEGIN", "COMMIT", "ROLLBACK":
+		return newMessage('C').string(verb).writeTo(pc.w)
+	case "START":
+		return newMessage('C').string("BEGIN").writeTo(pc.w)
+	case "END":
+		return newMessage('C').string("COMMIT").writeTo(pc.w)
+	}
+	return errorf(codeFeatureNotSupport, "only SELECT statements are supported")
+}
+
+func (pc *conn) selectQuery(stmt string) (err error) {
+	q, err := query.Parse(stmt)
+	if err != nil {
+		return errorf(codeSyntaxError, "%s", err)
+	}
+	db, err := pc.s.Open()
+	if err != nil {
+		return errorf(codeInternalError, "%s", err)
+	}
+	defer db.Close()
+
+	var value types.Value
+	if terr := d.Try(func() { value, err = resolveTable(db, q.From) }); terr != nil {
+		return terr
+	} else if err != nil {
+		return err
+	}
+	columns, err := q.Columns(value)
+	if err != nil {
+		return errorf(codeWrongObjectType, "%s", err)
+	}
+
+	desc := newMessage('T').int16(int16(len(columns)))
+	oids := make([]int32, len(columns))
+	for i, c := range columns {
+		oids[i] = columnType(value, c.Path)
+		size := int16(-1)
+		if oids[i] == oidBool {
+			size = 1
+		} else if oids[i] == oidFloat8 {
+			size = 8
+		}
+		desc.string(c.Name).int32(0).int16(0).int32(oids[i]).int16(size).int32(-1).int16(0)
+	}
+	if err := desc.writeTo(pc.w); err != nil {
+		return err
+	}
+
+	n := 0
+	var werr error
+	err = d.Try(func() {
+		d.PanicIfError(q.Run(value, func(values []types.Value) bool {
+			row := newMessage('D').int16(int16(len(values)))
+			for i, v := range values {
+				if v == nil {
+					row.int32(-1)
+					continue
+				}
+				b := formatValue(v, oids[i])
+				row.int32(int32(len(b))).bytes(b)
+			}
+			werr = row.writeTo(pc.w)
+			n++
+			return werr != nil
+		}))
+	})
+	if werr != nil {
+		return werr
+	} else if err != nil {
+		return err
+	}
+	return newMessage('C').string(fmt.Sprintf("SELECT %d", n)).writeTo(pc.w)
+}
+
+// resolveTable returns the value of the table named name: a dataset, which
+// may be double-quoted, or an information_schema table.
+func resolveTable(db datas.Database, name string) (types.Value, error) {
+	if v, ok := catalogTable(db, strings.ToLower(name)); ok {
+		return v, nil
+	}
+	id := name
+	if len(id) > 1 && id[0] == '"' && id[len(id)-1] == '"' {
+		id = id[1 : len(id)-1]
+	}
+	if !datas.IsValidDatasetName(id) {
+		return nil, errorf(codeUndefinedTable, "relation %q does not exist", name)
+	}
+	v, ok := db.GetDataset(id).MaybeHeadValue()
+	if !ok {
+		return nil, errorf(codeUndefinedTable, "relation %q does not exist", name)
+	}
+	if !isTable(v) {
+		return nil, errorf(codeWrongObjectType, "%q is a %s, not a table", name, types.TypeOf(v).Describe())
+	}
+	return v, nil
+}
+
+// splitStatements splits q into its non-empty statements, separated by
+// semicolons outside of quotes.
+func splitStatements(q string) []string {
+	stmts := []string{}
+	start, quote := 0, byte(0)
+	add := func(end int) {
+		if s := strings.TrimSpace(q[start:end]); s != "" {
+			stmts = append(stmts, s)
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(q); i++ {
+		switch c := q[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';':
+			add(i)
+		}
+	}
+	add(len(q))
+	return stmts
+}
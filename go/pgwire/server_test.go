@@ -0,0 +1,275 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package pgwire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/suite"
+)
+
+// testClient speaks the simple query protocol.
+type testClient struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+// result is the response to a query.
+type result struct {
+	columns []string
+	oids    []int32
+	rows    [][]*string // nil for NULL
+	tags    []string
+	errCode string
+	errMsg  string
+}
+
+func (tc *testClient) send(typ byte, body []byte) {
+	buf := []byte{}
+	if typ != 0 {
+		buf = append(buf, typ)
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(body)+4))
+	buf = append(append(buf, size[:]...), body...)
+	tc.c.Write(buf)
+}
+
+func (tc *testClient) read() (byte, []byte) {
+	typ, body, err := readMessage(tc.r)
+	if err != nil {
+		panic(err)
+	}
+	return typ, body
+}
+
+func (tc *testClient) query(q string) result {
+	tc.send('Q', append([]byte(q), 0))
+	return tc.readResult()
+}
+
+func (tc *testClient) readResult() (res result) {
+	for {
+		typ, body := tc.read()
+		switch typ {
+		case 'T':
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			for i := 0; i < n; i++ {
+				name, rest, _ := cString(body)
+				res.columns = append(res.columns, name)
+				res.oids = append(res.oids, int32(binary.BigEndian.Uint32(rest[6:])))
+				body = rest[18:]
+			}
+		case 'D':
+			n := int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+			row := []*string{}
+			for i := 0; i < n; i++ {
+				size := int32(binary.BigEndian.Uint32(body))
+				body = body[4:]
+				if size < 0 {
+					row = append(row, nil)
+					continue
+				}
+				s := string(body[:size])
+				row = append(row, &s)
+				body = body[size:]
+			}
+			res.rows = append(res.rows, row)
+		case 'C':
+			tag, _, _ := cString(body)
+			res.tags = append(res.tags, tag)
+		case 'I':
+			res.tags = append(res.tags, "")
+		case 'E':
+			for body[0] != 0 {
+				field := body[0]
+				val, rest, _ := cString(body[1:])
+				if field == 'C' {
+					res.errCode = val
+				} else if field == 'M' {
+					res.errMsg = val
+				}
+				body = rest
+			}
+		case 'Z':
+			return
+		}
+	}
+}
+
+type ServerSuite struct {
+	suite.Suite
+	cs     *chunks.MemoryStore
+	server *Server
+	addr   string
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, &ServerSuite{})
+}
+
+func (s *ServerSuite) SetupTest() {
+	s.cs = chunks.NewMemoryStore()
+	db := datas.NewDatabase(s.cs)
+	person := func(name string, age float64) types.Value {
+		return types.NewStruct("Person", types.StructData{"name": types.String(name), "age": types.Number(age)})
+	}
+	_, err := db.CommitValue(db.GetDataset("people"), types.NewMap(
+		types.String("al"), person("Al", 40),
+		types.String("bo"), person("Bo", 17),
+		types.String("cy"), person("Cy", 30),
+	))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("flags"), types.NewList(types.Bool(true), types.Bool(false)))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("scalar"), types.Number(1))
+	s.NoError(err)
+
+	s.server = NewServer(func() (datas.Database, error) { return datas.NewDatabase(s.cs), nil })
+	l, err := net.Listen("tcp", "localhost:0")
+	s.NoError(err)
+	s.addr = l.Addr().String()
+	go s.server.Serve(l)
+}
+
+func (s *ServerSuite) TearDownTest() {
+	s.server.Stop()
+}
+
+func (s *ServerSuite) connect() *testClient {
+	c, err := net.Dial("tcp", s.addr)
+	s.NoError(err)
+	tc := &testClient{c, bufio.NewReader(c)}
+
+	// Clients ask for SSL first, which is refused.
+	tc.send(0, []byte{0x04, 0xd2, 0x16, 0x2f})
+	b, err := tc.r.ReadByte()
+	s.NoError(err)
+	s.Equal(byte('N'), b)
+
+	startup := []byte{0, 3, 0, 0}
+	startup = append(startup, "user\x00noms\x00database\x00db\x00\x00"...)
+	tc.send(0, startup)
+	typ, body := tc.read()
+	s.Equal(byte('R'), typ)
+	s.Equal([]byte{0, 0, 0, 0}, body)
+	for typ != 'Z' {
+		typ, _ = tc.read()
+	}
+	return tc
+}
+
+func (s *ServerSuite) TestSelect() {
+	tc := s.connect()
+	defer tc.c.Close()
+
+	res := tc.query("SELECT _key, name, age FROM people WHERE _key > 'al' ORDER BY age")
+	s.Empty(res.errMsg)
+	s.Equal([]string{"_key", "name", "age"}, res.columns)
+	s.Equal([]int32{oidText, oidText, oidFloat8}, res.oids)
+	s.Len(res.rows, 2)
+	s.Equal("Bo", *res.rows[0][1])
+	s.Equal("17", *res.rows[0][2])
+	s.Equal("cy", *res.rows[1][0])
+	s.Equal([]string{"SELECT 2"}, res.tags)
+
+	res = tc.query("SELECT * FROM flags")
+	s.Equal([]string{"_value"}, res.columns)
+	s.Equal([]int32{oidBool}, res.oids)
+	s.Equal("t", *res.rows[0][0])
+	s.Equal("f", *res.rows[1][0])
+
+	res = tc.query("SELECT missing FROM people LIMIT 1")
+	s.Len(res.rows, 1)
+	s.Nil(res.rows[0][0])
+}
+
+func (s *ServerSuite) TestStatements() {
+	tc := s.connect()
+	defer tc.c.Close()
+
+	res := tc.query("SET client_encoding = 'UTF8'; BEGIN; SELECT name FROM people LIMIT 1; COMMIT")
+	s.Empty(res.errMsg)
+	s.Equal([]string{"SET", "BEGIN", "SELECT 1", "COMMIT"}, res.tags)
+
+	res = tc.query(" ; ")
+	s.Equal([]string{""}, res.tags)
+
+	res = tc.query("DELETE FROM people; SELECT name FROM people")
+	s.Equal(codeFeatureNotSupport, res.errCode)
+	s.Empty(res.tags) // statements after an error aren't run
+
+	res = tc.query("SELECT FROM")
+	s.Equal(codeSyntaxError, res.errCode)
+	res = tc.query("SELECT * FROM nope")
+	s.Equal(codeUndefinedTable, res.errCode)
+	res = tc.query("SELECT * FROM scalar")
+	s.Equal(codeWrongObjectType, res.errCode)
+
+	// The connection is still usable after errors.
+	res = tc.query("SELECT name FROM \"people\" WHERE name = 'Al;'")
+	s.Empty(res.errMsg)
+	s.Equal([]string{"SELECT 0"}, res.tags)
+}
+
+func (s *ServerSuite) TestExtendedProtocolRejected() {
+	tc := s.connect()
+	defer tc.c.Close()
+
+	tc.send('P', []byte("\x00SELECT 1\x00\x00\x00"))
+	tc.send('B', []byte("\x00\x00\x00\x00\x00\x00\x00\x00"))
+	tc.send('S', nil)
+	res := tc.readResult()
+	s.Equal(codeFeatureNotSupport, res.errCode)
+
+	res = tc.query("SELECT name FROM people LIMIT 1")
+	s.Empty(res.errMsg)
+	s.Len(res.rows, 1)
+}
+
+func (s *ServerSuite) TestCatalog() {
+	tc := s.connect()
+	defer tc.c.Close()
+
+	res := tc.query("SELECT table_name FROM information_schema.tables ORDER BY table_name")
+	s.Len(res.rows, 2)
+	s.Equal("flags", *res.rows[0][0])
+	s.Equal("people", *res.rows[1][0])
+
+	res = tc.query("SELECT column_name, data_type FROM information_schema.columns WHERE table_name = 'people' ORDER BY ordinal_position")
+	s.Len(res.rows, 3)
+	s.Equal("_key", *res.rows[0][0])
+	s.Equal("age", *res.rows[1][0])
+	s.Equal("double precision", *res.rows[1][1])
+	s.Equal("name", *res.rows[2][0])
+	s.Equal("text", *res.rows[2][1])
+}
+
+func (s *ServerSuite) TestFreshHeads() {
+	tc := s.connect()
+	defer tc.c.Close()
+
+	db := datas.NewDatabase(s.cs)
+	_, err := db.CommitValue(db.GetDataset("people"), types.NewMap())
+	s.NoError(err)
+	res := tc.query("SELECT * FROM people")
+	s.Empty(res.rows)
+}
+
+func (s *ServerSuite) TestTerminate() {
+	tc := s.connect()
+	tc.send('X', nil)
+	_, err := tc.r.ReadByte()
+	s.Equal(io.EOF, err)
+}
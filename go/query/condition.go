@@ -0,0 +1,37 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import "github.com/attic-labs/noms/go/types"
+
+// Condition is an expression like those in WHERE clauses, for matching single
+// values rather than rows of a collection.
+type Condition struct {
+	e expr
+}
+
+// ParseCondition parses an expression like those in WHERE clauses, e.g.
+// "count > 10 AND status = 'ok'". Fields are the fields of the value being
+// matched, and _value is the value itself.
+func ParseCondition(s string) (*Condition, error) {
+	p := &parser{lex: &lexer{s: s}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("end of condition")
+	}
+	return &Condition{e}, nil
+}
+
+// Match returns whether c is true for v. NULL, like any other value which
+// isn't true, doesn't match.
+func (c *Condition) Match(v types.Value) bool {
+	return c.e.eval(row{value: v}) == types.Bool(true)
+}
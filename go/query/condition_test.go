@@ -0,0 +1,40 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestCondition(t *testing.T) {
+	assert := assert.New(t)
+	v := types.NewStruct("", types.StructData{
+		"count":  types.Number(12),
+		"status": types.String("ok"),
+	})
+	for s, match := range map[string]bool{
+		"count > 10 AND status = 'ok'":   true,
+		"count > 20 OR status LIKE 'o%'": true,
+		"NOT count > 10":                 false,
+		"missing = 1":                    false,
+		"missing IS NULL":                true,
+		"count":                          false,
+	} {
+		c, err := ParseCondition(s)
+		assert.NoError(err)
+		assert.Equal(match, c.Match(v), s)
+	}
+
+	c, err := ParseCondition("_value >= 3")
+	assert.NoError(err)
+	assert.True(c.Match(types.Number(3)))
+	assert.False(c.Match(types.String("3")))
+
+	_, err = ParseCondition("count > 1 LIMIT 2")
+	assert.EqualError(err, `syntax error at position 10: expected end of condition, found "LIMIT"`)
+}
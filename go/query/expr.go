@@ -0,0 +1,169 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"regexp"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Pseudo fields, which can't clash with struct fields because those can't
+// start with an underscore.
+const (
+	// KeyField is the key of a Map entry or the value of a Set element.
+	KeyField = "_key"
+	// IndexField is the index of a List element.
+	IndexField = "_index"
+	// ValueField is the element itself: the value of a Map entry, or the
+	// element of a List or Set.
+	ValueField = "_value"
+)
+
+// row is an element of the collection being queried.
+type row struct {
+	key    types.Value // nil for Lists
+	index  uint64      // only for Lists
+	isList bool
+	value  types.Value
+}
+
+func (r row) get(path []string) types.Value {
+	var v types.Value
+	switch path[0] {
+	case KeyField:
+		v = r.key
+	case IndexField:
+		if !r.isList {
+			return nil
+		}
+		v = types.Number(r.index)
+	case ValueField:
+		v = r.value
+	default:
+		v = r.value
+		path = append([]string{""}, path...)
+	}
+	for _, name := range path[1:] {
+		s, ok := v.(types.Struct)
+		if !ok {
+			return nil
+		}
+		if v, ok = s.MaybeGet(name); !ok {
+			return nil
+		}
+	}
+	return v
+}
+
+// expr is an expression of a WHERE clause. Evaluating it returns nil for
+// NULL, which is what missing fields and comparisons of values of different
+// kinds evaluate to.
+type expr interface {
+	eval(r row) types.Value
+}
+
+type literal struct {
+	v types.Value
+}
+
+func (e literal) eval(r row) types.Value {
+	return e.v
+}
+
+type field struct {
+	path []string
+}
+
+func (e field) eval(r row) types.Value {
+	return r.get(e.path)
+}
+
+type comparison struct {
+	op   string
+	l, r expr
+}
+
+func (e comparison) eval(r row) types.Value {
+	l, rv := e.l.eval(r), e.r.eval(r)
+	if l == nil || rv == nil || l.Kind() != rv.Kind() {
+		return nil
+	}
+	return types.Bool(compare(e.op, l, rv))
+}
+
+func compare(op string, l, r types.Value) bool {
+	switch op {
+	case "=":
+		return l.Equals(r)
+	case "!=":
+		return !l.Equals(r)
+	case "<":
+		return l.Less(r)
+	case "<=":
+		return !r.Less(l)
+	case ">":
+		return r.Less(l)
+	case ">=":
+		return !l.Less(r)
+	}
+	panic("not reached")
+}
+
+// logical is AND or OR, with SQL's three-valued logic.
+type logical struct {
+	op   string
+	l, r expr
+}
+
+func (e logical) eval(r row) types.Value {
+	short := types.Bool(e.op == "OR")
+	l := e.l.eval(r)
+	if l == short {
+		return short
+	}
+	rv := e.r.eval(r)
+	if rv == short {
+		return short
+	}
+	if l == nil || rv == nil || l.Kind() != types.BoolKind || rv.Kind() != types.BoolKind {
+		return nil
+	}
+	return !short
+}
+
+type not struct {
+	e expr
+}
+
+func (e not) eval(r row) types.Value {
+	if b, ok := e.e.eval(r).(types.Bool); ok {
+		return !b
+	}
+	return nil
+}
+
+type isNull struct {
+	e      expr
+	negate bool
+}
+
+func (e isNull) eval(r row) types.Value {
+	return types.Bool((e.e.eval(r) == nil) != e.negate)
+}
+
+type like struct {
+	e      expr
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (e like) eval(r row) types.Value {
+	s, ok := e.e.eval(r).(types.String)
+	if !ok {
+		return nil
+	}
+	return types.Bool(e.re.MatchString(string(s)) != e.negate)
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokNumber
+	tokString
+	tokOp
+)
+
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "ORDER": true, "BY": true,
+	"ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true,
+	"LIKE": true, "TRUE": true, "FALSE": true,
+}
+
+type token struct {
+	kind tokenKind
+	text string // upper case for keywords, unquoted for strings
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.s) && unicode.IsSpace(rune(l.s[l.pos])) {
+		l.pos++
+	}
+}
+
+func isIdentChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpace()
+	start := l.pos
+	if l.pos >= len(l.s) {
+		return token{tokEOF, "", start}, nil
+	}
+
+	c := l.s[l.pos]
+	switch {
+	case isIdentChar(c, true):
+		for l.pos < len(l.s) && isIdentChar(l.s[l.pos], false) {
+			l.pos++
+		}
+		text := l.s[start:l.pos]
+		if upper := strings.ToUpper(text); keywords[upper] {
+			return token{tokKeyword, upper, start}, nil
+		}
+		return token{tokIdent, text, start}, nil
+	case c >= '0' && c <= '9' || c == '-' && l.pos+1 < len(l.s) && (l.s[l.pos+1] >= '0' && l.s[l.pos+1] <= '9' || l.s[l.pos+1] == '.'):
+		l.pos++
+		for l.pos < len(l.s) && (l.s[l.pos] >= '0' && l.s[l.pos] <= '9' || strings.IndexByte(".eE", l.s[l.pos]) >= 0 ||
+			(l.s[l.pos] == '-' || l.s[l.pos] == '+') && (l.s[l.pos-1] == 'e' || l.s[l.pos-1] == 'E')) {
+			l.pos++
+		}
+		return token{tokNumber, l.s[start:l.pos], start}, nil
+	case c == '\'':
+		s, err := l.quoted()
+		return token{tokString, s, start}, err
+	case c == '"':
+		s, err := l.quoted()
+		return token{tokIdent, s, start}, err
+	}
+
+	for _, op := range []string{"<=", ">=", "<>", "!=", "=", "<", ">", "(", ")", ",", ".", "*"} {
+		if strings.HasPrefix(l.s[l.pos:], op) {
+			l.pos += len(op)
+			return token{tokOp, op, start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected character %q at position %d", c, start)
+}
+
+// quoted reads a string quoted with the character at the current position,
+// in which the quote character is escaped by doubling it.
+func (l *lexer) quoted() (string, error) {
+	q := l.s[l.pos]
+	start := l.pos
+	l.pos++
+	buf := []byte{}
+	for l.pos < len(l.s) {
+		c := l.s[l.pos]
+		l.pos++
+		if c == q {
+			if l.pos < len(l.s) && l.s[l.pos] == q {
+				l.pos++
+			} else {
+				return string(buf), nil
+			}
+		}
+		buf = append(buf, c)
+	}
+	return "", fmt.Errorf("unterminated string at position %d", start)
+}
+
+// word reads a quoted string or a run of non-space characters, which is how
+// the path after FROM is spelled.
+func (l *lexer) word() (string, error) {
+	l.skipSpace()
+	if l.pos < len(l.s) && l.s[l.pos] == '\'' {
+		return l.quoted()
+	}
+	start := l.pos
+	for l.pos < len(l.s) && !unicode.IsSpace(rune(l.s[l.pos])) {
+		l.pos++
+	}
+	return l.s[start:l.pos], nil
+}
@@ -0,0 +1,348 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse parses a query of the form:
+//
+//	SELECT <fields> FROM <path> [WHERE <expr>] [ORDER BY <field> [ASC|DESC], ...] [LIMIT <n> [OFFSET <m>]]
+//
+// See the package documentation for details.
+func Parse(q string) (*Query, error) {
+	p := &parser{lex: &lexer{s: q}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return p.parseQuery()
+}
+
+func (p *parser) next() (err error) {
+	p.tok, err = p.lex.next()
+	return
+}
+
+func (p *parser) errorf(expected string) error {
+	return fmt.Errorf("syntax error at position %d: expected %s, found %s", p.tok.pos, expected, p.tok)
+}
+
+func (p *parser) is(kind tokenKind, text string) bool {
+	return p.tok.kind == kind && p.tok.text == text
+}
+
+// accept consumes the current token if it's the keyword or operator text.
+func (p *parser) accept(text string) (bool, error) {
+	if (p.tok.kind == tokKeyword || p.tok.kind == tokOp) && p.tok.text == text {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *parser) expect(text string) error {
+	ok, err := p.accept(text)
+	if err == nil && !ok {
+		err = p.errorf(text)
+	}
+	return err
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	q := &Query{Limit: -1}
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	if ok, err := p.accept("*"); err != nil {
+		return nil, err
+	} else if !ok {
+		for {
+			f := Field{}
+			var err error
+			if f.Path, err = p.parsePath(); err != nil {
+				return nil, err
+			}
+			f.Name = strings.Join(f.Path, ".")
+			if ok, err := p.accept("AS"); err != nil {
+				return nil, err
+			} else if ok {
+				if p.tok.kind != tokIdent {
+					return nil, p.errorf("column name")
+				}
+				f.Name = p.tok.text
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+			q.Fields = append(q.Fields, f)
+			if ok, err := p.accept(","); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+		}
+	}
+
+	// The path after FROM is read as a single word, so check for FROM without
+	// reading the token after it.
+	if !p.is(tokKeyword, "FROM") {
+		return nil, p.errorf("FROM")
+	}
+	from, err := p.lex.word()
+	if err != nil {
+		return nil, err
+	}
+	if from == "" {
+		return nil, fmt.Errorf("syntax error at position %d: expected path after FROM", p.lex.pos)
+	}
+	q.From = from
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if ok, err := p.accept("WHERE"); err != nil {
+		return nil, err
+	} else if ok {
+		if q.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.accept("ORDER"); err != nil {
+		return nil, err
+	} else if ok {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			o := Order{}
+			if o.Path, err = p.parsePath(); err != nil {
+				return nil, err
+			}
+			// ORDER BY can refer to the name a field is selected as.
+			if len(o.Path) == 1 {
+				for _, f := range q.Fields {
+					if f.Name == o.Path[0] {
+						o.Path = f.Path
+					}
+				}
+			}
+			if ok, err := p.accept("DESC"); err != nil {
+				return nil, err
+			} else if ok {
+				o.Desc = true
+			} else if _, err := p.accept("ASC"); err != nil {
+				return nil, err
+			}
+			q.OrderBy = append(q.OrderBy, o)
+			if ok, err := p.accept(","); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+		}
+	}
+
+	if ok, err := p.accept("LIMIT"); err != nil {
+		return nil, err
+	} else if ok {
+		if q.Limit, err = p.parseCount(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.accept("OFFSET"); err != nil {
+			return nil, err
+		} else if ok {
+			if q.Offset, err = p.parseCount(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("end of query")
+	}
+	return q, nil
+}
+
+func (p *parser) parseCount() (int, error) {
+	if p.tok.kind != tokNumber {
+		return 0, p.errorf("number")
+	}
+	n, err := strconv.Atoi(p.tok.text)
+	if err != nil || n < 0 {
+		return 0, p.errorf("non-negative integer")
+	}
+	return n, p.next()
+}
+
+func (p *parser) parsePath() ([]string, error) {
+	path := []string{}
+	for {
+		if p.tok.kind != tokIdent {
+			return nil, p.errorf("field name")
+		}
+		path = append(path, p.tok.text)
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.accept("."); err != nil {
+			return nil, err
+		} else if !ok {
+			return path, nil
+		}
+	}
+}
+
+func (p *parser) parseOr() (expr, error) {
+	l, err := p.parseAnd()
+	for err == nil {
+		var ok bool
+		if ok, err = p.accept("OR"); err != nil || !ok {
+			break
+		}
+		var r expr
+		if r, err = p.parseAnd(); err == nil {
+			l = logical{"OR", l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	l, err := p.parseNot()
+	for err == nil {
+		var ok bool
+		if ok, err = p.accept("AND"); err != nil || !ok {
+			break
+		}
+		var r expr
+		if r, err = p.parseNot(); err == nil {
+			l = logical{"AND", l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if ok, err := p.accept("NOT"); err != nil {
+		return nil, err
+	} else if ok {
+		e, err := p.parseNot()
+		return not{e}, err
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	l, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokOp {
+		switch op := p.tok.text; op {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			if op == "<>" {
+				op = "!="
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			r, err := p.parseOperand()
+			return comparison{op, l, r}, err
+		}
+	}
+
+	if ok, err := p.accept("IS"); err != nil {
+		return nil, err
+	} else if ok {
+		negate, err := p.accept("NOT")
+		if err != nil {
+			return nil, err
+		}
+		return isNull{l, negate}, p.expect("NULL")
+	}
+
+	negate, err := p.accept("NOT")
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.accept("LIKE"); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind != tokString {
+			return nil, p.errorf("pattern")
+		}
+		e := like{l, likePattern(p.tok.text), negate}
+		return e, p.next()
+	} else if negate {
+		return nil, p.errorf("LIKE")
+	}
+	return l, nil
+}
+
+func (p *parser) parseOperand() (expr, error) {
+	switch p.tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("number")
+		}
+		return literal{types.Number(f)}, p.next()
+	case tokString:
+		s := p.tok.text
+		return literal{types.String(s)}, p.next()
+	case tokIdent:
+		path, err := p.parsePath()
+		return field{path}, err
+	case tokKeyword:
+		switch p.tok.text {
+		case "TRUE", "FALSE":
+			b := p.tok.text == "TRUE"
+			return literal{types.Bool(b)}, p.next()
+		case "NULL":
+			return literal{nil}, p.next()
+		}
+	case tokOp:
+		if p.tok.text == "(" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	}
+	return nil, p.errorf("value")
+}
+
+// likePattern converts a LIKE pattern, in which % matches any string and _
+// any character, to a regexp.
+func likePattern(pattern string) *regexp.Regexp {
+	re := []string{"(?s)^"}
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			re = append(re, ".*")
+		case '_':
+			re = append(re, ".")
+		default:
+			re = append(re, regexp.QuoteMeta(string(r)))
+		}
+	}
+	return regexp.MustCompile(strings.Join(re, "") + "$")
+}
@@ -0,0 +1,346 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package query implements a small subset of SQL for querying Noms
+// collections:
+//
+//	SELECT name, address.city AS city FROM db::people.value
+//	WHERE age >= 18 AND NOT (name LIKE 'A%' OR email IS NULL)
+//	ORDER BY city DESC, name LIMIT 10 OFFSET 20
+//
+// The rows of a query are the elements of the List, Set or Map at the path
+// after FROM, and its fields are the fields of the elements if they're
+// structs. Dotted fields select fields of nested structs. The pseudo fields
+// _key, _index and _value are the key of a Map entry or Set element, the
+// index of a List element and the element itself. SELECT * selects _key for
+// Maps and Sets, then every field of the elements if they're structs, and
+// otherwise _value for Lists and Maps.
+//
+// Missing fields are NULL, and so are comparisons of values of different
+// kinds. Values are ordered as Noms orders them, with NULL first.
+//
+// WHERE clauses comparing _key or _index to constants are executed by
+// scanning only the part of the collection in range.
+package query
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Query is a parsed query.
+type Query struct {
+	// Fields are the selected fields, or empty for SELECT *.
+	Fields []Field
+	// From is the path to the queried collection, as it was spelled.
+	From    string
+	OrderBy []Order
+	// Limit is the maximum number of rows returned, or -1 for no limit.
+	Limit  int
+	Offset int
+
+	where expr
+}
+
+// Field is a selected field.
+type Field struct {
+	// Name is the name of the column the field is returned as.
+	Name string
+	// Path is the path of fields to the value.
+	Path []string
+}
+
+// Order is a field rows are ordered by.
+type Order struct {
+	Path []string
+	Desc bool
+}
+
+func elemType(v types.Value) (*types.Type, error) {
+	switch v.Kind() {
+	case types.ListKind, types.SetKind:
+		return types.TypeOf(v).Desc.(types.CompoundDesc).ElemTypes[0], nil
+	case types.MapKind:
+		return types.TypeOf(v).Desc.(types.CompoundDesc).ElemTypes[1], nil
+	}
+	return nil, fmt.Errorf("expected a List, Set or Map, found %s", types.TypeOf(v).Describe())
+}
+
+// Columns returns the selected fields of q when querying v, which must be a
+// List, Set or Map.
+func (q *Query) Columns(v types.Value) ([]Field, error) {
+	t, err := elemType(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.Fields) > 0 {
+		return q.Fields, nil
+	}
+
+	fields := []Field{}
+	if v.Kind() != types.ListKind {
+		fields = append(fields, Field{KeyField, []string{KeyField}})
+	}
+	if v.Kind() == types.SetKind && t.TargetKind() != types.StructKind {
+		// The _value of Set elements is the same as their _key.
+		return fields, nil
+	}
+	if t.TargetKind() == types.StructKind {
+		t.Desc.(types.StructDesc).IterFields(func(name string, _ *types.Type, _ bool) {
+			fields = append(fields, Field{name, []string{name}})
+		})
+	} else {
+		fields = append(fields, Field{ValueField, []string{ValueField}})
+	}
+	return fields, nil
+}
+
+// Run runs q against v, which must be a List, Set or Map, and calls cb with
+// the values of the columns of each resulting row, see Columns. Values are
+// nil for NULL. Unless the order of the rows is the order of v, all matching
+// rows are read before cb is first called. Run stops early if cb returns
+// true.
+func (q *Query) Run(v types.Value, cb func(values []types.Value) (stop bool)) error {
+	columns, err := q.Columns(v)
+	if err != nil {
+		return err
+	}
+	values := func(r row) []types.Value {
+		vs := make([]types.Value, len(columns))
+		for i, c := range columns {
+			vs[i] = r.get(c.Path)
+		}
+		return vs
+	}
+
+	skip, limit := q.Offset, q.Limit
+	emit := func(r row) bool {
+		if skip > 0 {
+			skip--
+			return false
+		}
+		if limit == 0 {
+			return true
+		}
+		limit--
+		return cb(values(r)) || limit == 0
+	}
+
+	if q.inScanOrder(v) {
+		q.scan(v, emit)
+		return nil
+	}
+
+	rows := []row{}
+	q.scan(v, func(r row) bool {
+		rows = append(rows, r)
+		return false
+	})
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, o := range q.OrderBy {
+			a, b := rows[i].get(o.Path), rows[j].get(o.Path)
+			if o.Desc {
+				a, b = b, a
+			}
+			if less(a, b) {
+				return true
+			} else if less(b, a) {
+				return false
+			}
+		}
+		return false
+	})
+	for _, r := range rows {
+		if emit(r) {
+			break
+		}
+	}
+	return nil
+}
+
+func less(a, b types.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Less(b)
+}
+
+// inScanOrder returns whether rows are ordered by q.OrderBy if v is scanned
+// in order.
+func (q *Query) inScanOrder(v types.Value) bool {
+	if len(q.OrderBy) == 0 {
+		return true
+	}
+	o := q.OrderBy[0]
+	if o.Desc || len(o.Path) != 1 {
+		return false
+	}
+	if v.Kind() == types.ListKind {
+		return o.Path[0] == IndexField
+	}
+	// Keys are unique, so any further orders don't matter.
+	return o.Path[0] == KeyField || v.Kind() == types.SetKind && o.Path[0] == ValueField
+}
+
+// scan calls cb with the rows of v which match q.where, in order, until cb
+// returns true.
+func (q *Query) scan(v types.Value, cb func(r row) (stop bool)) {
+	match := func(r row) bool {
+		return q.where == nil || q.where.eval(r) == types.Bool(true)
+	}
+	lo, hi := q.scanRange(v.Kind())
+
+	switch v := v.(type) {
+	case types.List:
+		start, end := uint64(0), v.Len()
+		if lo.v != nil {
+			f := float64(lo.v.(types.Number))
+			if f > float64(end) {
+				return
+			}
+			if f > 0 {
+				start = uint64(f)
+				if float64(start) < f || !lo.inclusive && float64(start) == f {
+					start++
+				}
+			}
+		}
+		if hi.v != nil {
+			f := float64(hi.v.(types.Number))
+			if f < 0 {
+				return
+			}
+			if f < float64(end) {
+				end = uint64(f)
+				if hi.inclusive || float64(end) < f {
+					end++
+				}
+			}
+		}
+		if start >= end {
+			return
+		}
+		it := v.IteratorAt(start)
+		for i := start; i < end; i++ {
+			r := row{index: i, isList: true, value: it.Next()}
+			if match(r) && cb(r) {
+				return
+			}
+		}
+	case types.Set:
+		var it types.SetIterator
+		if lo.v != nil {
+			it = v.IteratorFrom(lo.v)
+		} else {
+			it = v.Iterator()
+		}
+		for k := it.Next(); k != nil && hi.admits(k); k = it.Next() {
+			r := row{key: k, value: k}
+			if lo.admits(k) && match(r) && cb(r) {
+				return
+			}
+		}
+	case types.Map:
+		var it types.MapIterator
+		if lo.v != nil {
+			it = v.IteratorFrom(lo.v)
+		} else {
+			it = v.Iterator()
+		}
+		for k, val := it.Next(); k != nil && hi.admits(k); k, val = it.Next() {
+			r := row{key: k, value: val}
+			if lo.admits(k) && match(r) && cb(r) {
+				return
+			}
+		}
+	}
+}
+
+// bound is a lower or upper bound of the keys or indices to scan. v is nil
+// for no bound.
+type bound struct {
+	v         types.Value
+	inclusive bool
+	upper     bool
+}
+
+// admits returns whether k is within b.
+func (b bound) admits(k types.Value) bool {
+	if b.v == nil {
+		return true
+	}
+	if k.Equals(b.v) {
+		return b.inclusive
+	}
+	return b.upper == k.Less(b.v)
+}
+
+// tighten returns the tighter of b and the bound v.
+func (b bound) tighten(v types.Value, inclusive bool) bound {
+	n := bound{v, inclusive, b.upper}
+	switch {
+	case b.v == nil:
+		return n
+	case b.v.Equals(v):
+		b.inclusive = b.inclusive && inclusive
+		return b
+	case b.upper == v.Less(b.v):
+		return n
+	}
+	return b
+}
+
+// scanRange returns the range of keys, or indices for Lists, which rows
+// matching q.where can have, from the comparisons of _key or _index to
+// constants which all matching rows have to satisfy.
+func (q *Query) scanRange(k types.NomsKind) (lo, hi bound) {
+	hi.upper = true
+	name := KeyField
+	if k == types.ListKind {
+		name = IndexField
+	}
+
+	var visit func(e expr)
+	visit = func(e expr) {
+		switch e := e.(type) {
+		case logical:
+			if e.op == "AND" {
+				visit(e.l)
+				visit(e.r)
+			}
+		case comparison:
+			op, f, l := e.op, e.l, e.r
+			if _, ok := f.(literal); ok {
+				f, l = l, f
+				op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}[op]
+				if op == "" {
+					op = e.op
+				}
+			}
+			fe, ok := f.(field)
+			if !ok || len(fe.path) != 1 || fe.path[0] != name {
+				return
+			}
+			lit, ok := l.(literal)
+			if !ok || lit.v == nil || k == types.ListKind && lit.v.Kind() != types.NumberKind {
+				return
+			}
+			switch op {
+			case "=":
+				lo = lo.tighten(lit.v, true)
+				hi = hi.tighten(lit.v, true)
+			case ">", ">=":
+				lo = lo.tighten(lit.v, op == ">=")
+			case "<", "<=":
+				hi = hi.tighten(lit.v, op == "<=")
+			}
+		}
+	}
+	if q.where != nil {
+		visit(q.where)
+	}
+	return
+}
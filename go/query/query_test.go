@@ -0,0 +1,199 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func people() types.List {
+	person := func(name string, age float64, city string) types.Value {
+		data := types.StructData{"name": types.String(name), "age": types.Number(age)}
+		if city != "" {
+			data["address"] = types.NewStruct("Address", types.StructData{"city": types.String(city)})
+		}
+		return types.NewStruct("Person", data)
+	}
+	return types.NewList(
+		person("Ann", 42, "Oakland"),
+		person("Bob", 7, "Boston"),
+		person("Cat", 30, ""),
+		person("Dan", 42, "Austin"),
+		person("Eve", 19, "Boston"),
+	)
+}
+
+// run runs q against v and returns its rows, each formatted as its values
+// separated by spaces.
+func run(t *testing.T, v types.Value, q string) []string {
+	parsed, err := Parse(q)
+	assert.NoError(t, err)
+	rows := []string{}
+	assert.NoError(t, parsed.Run(v, func(values []types.Value) bool {
+		s := make([]string, len(values))
+		for i, v := range values {
+			if v == nil {
+				s[i] = "NULL"
+			} else {
+				s[i] = types.EncodedValue(v)
+			}
+		}
+		rows = append(rows, strings.Join(s, " "))
+		return false
+	}))
+	return rows
+}
+
+func TestQueryList(t *testing.T) {
+	assert := assert.New(t)
+	l := people()
+
+	assert.Equal([]string{`"Ann" "Oakland"`, `"Bob" "Boston"`, `"Cat" NULL`, `"Dan" "Austin"`, `"Eve" "Boston"`},
+		run(t, l, "SELECT name, address.city FROM x"))
+	assert.Equal([]string{`"Ann"`, `"Dan"`}, run(t, l, "SELECT name FROM x WHERE age = 42"))
+	assert.Equal([]string{`"Bob"`, `"Eve"`}, run(t, l, "select name from x where address.city = 'Boston'"))
+	assert.Equal([]string{`"Ann"`, `"Dan"`}, run(t, l, "SELECT name FROM x WHERE age >= 30 AND NOT address.city = 'Boston'"),
+		"NOT NULL is NULL, so Cat doesn't match")
+	assert.Equal([]string{`"Cat"`}, run(t, l, "SELECT name FROM x WHERE address IS NULL"))
+	assert.Equal([]string{`"Bob"`, `"Eve"`}, run(t, l, "SELECT name FROM x WHERE age < 20 OR name LIKE 'E%'"))
+	assert.Equal([]string{`"Ann"`, `"Cat"`, `"Dan"`}, run(t, l, "SELECT name FROM x WHERE name NOT LIKE '_o_' AND name <> 'Eve'"))
+	assert.Equal([]string{}, run(t, l, "SELECT name FROM x WHERE age = '42'"))
+	assert.Equal([]string{`"Ann"`, `"Dan"`}, run(t, l, "SELECT name FROM x WHERE (age > 40 OR age < 10) AND (name = 'Ann' OR name = 'Dan')"))
+
+	assert.Equal([]string{`1 "Bob"`, `2 "Cat"`}, run(t, l, "SELECT _index, name FROM x WHERE _index >= 1 AND _index < 3"))
+	assert.Equal([]string{`4 "Eve"`}, run(t, l, "SELECT _index, name FROM x WHERE 3 < _index"))
+	assert.Equal([]string{}, run(t, l, "SELECT _index FROM x WHERE _index > 10"))
+
+	assert.Equal([]string{`"Bob" 7`, `"Eve" 19`, `"Cat" 30`, `"Ann" 42`, `"Dan" 42`}, run(t, l, "SELECT name, age FROM x ORDER BY age"))
+	assert.Equal([]string{`"Dan" "Austin"`, `"Ann" "Oakland"`}, run(t, l, "SELECT name, address.city AS city FROM x ORDER BY age DESC, city LIMIT 2"))
+	assert.Equal([]string{`"Cat"`, `"Dan"`}, run(t, l, "SELECT name FROM x ORDER BY address.city LIMIT 2"), "NULL is first")
+	assert.Equal([]string{`"Cat"`, `"Dan"`}, run(t, l, "SELECT name FROM x LIMIT 2 OFFSET 2"))
+	assert.Equal([]string{`"Eve"`, `"Dan"`}, run(t, l, "SELECT name FROM x ORDER BY _index DESC LIMIT 2"))
+	assert.Equal([]string{}, run(t, l, "SELECT name FROM x LIMIT 0"))
+
+	assert.Equal([]string{
+		`Address {` + "\n" + `  city: "Oakland",` + "\n" + `} 42 "Ann"`,
+	}, run(t, l, "SELECT * FROM x LIMIT 1"))
+}
+
+func TestQueryMap(t *testing.T) {
+	assert := assert.New(t)
+	kvs := []types.Value{}
+	for i := 0; i < 100; i++ {
+		kvs = append(kvs, types.Number(i), types.String(fmt.Sprintf("v%d", i)))
+	}
+	m := types.NewMap(kvs...)
+
+	assert.Equal([]string{`10 "v10"`, `11 "v11"`, `12 "v12"`}, run(t, m, "SELECT * FROM x WHERE _key >= 10 AND _key <= 12"))
+	assert.Equal([]string{`11 "v11"`}, run(t, m, "SELECT * FROM x WHERE _key > 10 AND _key < 12"))
+	assert.Equal([]string{`"v42"`}, run(t, m, "SELECT _value FROM x WHERE _key = 42"))
+	assert.Equal([]string{`97`, `98`, `99`}, run(t, m, "SELECT _key FROM x WHERE _key > 50 AND _key > 96"))
+	assert.Equal([]string{}, run(t, m, "SELECT _key FROM x WHERE _key > 50 AND _key < 20"))
+	assert.Equal([]string{}, run(t, m, "SELECT _key FROM x WHERE _key = 'v1'"))
+	assert.Equal([]string{`99`, `98`}, run(t, m, "SELECT _key FROM x ORDER BY _key DESC LIMIT 2"))
+	assert.Equal([]string{`"v5"`, `"v50"`}, run(t, m, "SELECT _value FROM x WHERE _value LIKE 'v5%' LIMIT 2"))
+}
+
+func TestQuerySet(t *testing.T) {
+	assert := assert.New(t)
+	s := types.NewSet(types.String("a"), types.String("b"), types.String("c"), types.Number(1))
+	assert.Equal([]string{`1`, `"a"`, `"b"`, `"c"`}, run(t, s, "SELECT * FROM x"))
+	assert.Equal([]string{`"b"`, `"c"`}, run(t, s, "SELECT _key FROM x WHERE _key > 'a'"))
+	assert.Equal([]string{`1`}, run(t, s, "SELECT _value FROM x WHERE _key < 2"))
+}
+
+func TestQueryNotCollection(t *testing.T) {
+	q, err := Parse("SELECT * FROM x")
+	assert.NoError(t, err)
+	err = q.Run(types.String("x"), func(values []types.Value) bool { return false })
+	assert.EqualError(t, err, "expected a List, Set or Map, found String")
+}
+
+func TestScanRange(t *testing.T) {
+	assert := assert.New(t)
+	scanned := 0
+	kvs := []types.Value{}
+	for i := 0; i < 1000; i++ {
+		kvs = append(kvs, types.Number(i), types.Bool(true))
+	}
+	m := types.NewMap(kvs...)
+
+	q, err := Parse("SELECT _key FROM x WHERE _key >= 500 AND _key < 510 AND _value")
+	assert.NoError(err)
+	q.scan(m, func(r row) bool {
+		scanned++
+		return false
+	})
+	assert.Equal(10, scanned)
+
+	lo, hi := q.scanRange(types.MapKind)
+	assert.Equal(types.Number(500), lo.v)
+	assert.True(lo.inclusive)
+	assert.Equal(types.Number(510), hi.v)
+	assert.False(hi.inclusive)
+
+	// Conditions under OR can't narrow the range.
+	q, err = Parse("SELECT _key FROM x WHERE _key = 1 OR _key = 2")
+	assert.NoError(err)
+	lo, hi = q.scanRange(types.MapKind)
+	assert.Nil(lo.v)
+	assert.Nil(hi.v)
+}
+
+func TestParseErrors(t *testing.T) {
+	assert := assert.New(t)
+	for q, msg := range map[string]string{
+		"":                                   "syntax error at position 0: expected SELECT, found end of query",
+		"SELECT FROM x":                      `syntax error at position 7: expected field name, found "FROM"`,
+		"SELECT a":                           "syntax error at position 8: expected FROM, found end of query",
+		"SELECT a FROM":                      "syntax error at position 13: expected path after FROM",
+		"SELECT a FROM x WHERE":              "syntax error at position 21: expected value, found end of query",
+		"SELECT a FROM x WHERE a = 'b":       "unterminated string at position 26",
+		"SELECT a FROM x WHERE a NOT 1":      `syntax error at position 28: expected LIKE, found "1"`,
+		"SELECT a FROM x WHERE a LIKE b":     `syntax error at position 29: expected pattern, found "b"`,
+		"SELECT a FROM x WHERE (a = 1":       "syntax error at position 28: expected ), found end of query",
+		"SELECT a FROM x LIMIT -1":           `syntax error at position 22: expected non-negative integer, found "-1"`,
+		"SELECT a FROM x ORDER a":            `syntax error at position 22: expected BY, found "a"`,
+		"SELECT a FROM x LIMIT 1 OFFSET 1 2": `syntax error at position 33: expected end of query, found "2"`,
+		"SELECT a FROM x WHERE a = #":        "unexpected character '#' at position 26",
+	} {
+		_, err := Parse(q)
+		assert.EqualError(err, msg, q)
+	}
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	q, err := Parse(`SELECT a, b.c AS "d e", "ORDER" FROM 'db::my ds.value' WHERE a IS NOT NULL ORDER BY "d e" DESC, a ASC LIMIT 5 OFFSET 10`)
+	assert.NoError(err)
+	assert.Equal([]Field{{"a", []string{"a"}}, {"d e", []string{"b", "c"}}, {"ORDER", []string{"ORDER"}}}, q.Fields)
+	assert.Equal("db::my ds.value", q.From)
+	assert.Equal([]Order{{[]string{"b", "c"}, true}, {[]string{"a"}, false}}, q.OrderBy)
+	assert.Equal(5, q.Limit)
+	assert.Equal(10, q.Offset)
+	assert.Equal(isNull{field{[]string{"a"}}, true}, q.where)
+
+	q, err = Parse("select * from db::ds.value")
+	assert.NoError(err)
+	assert.Nil(q.Fields)
+	assert.Equal("db::ds.value", q.From)
+	assert.Equal(-1, q.Limit)
+	assert.Nil(q.where)
+
+	q, err = Parse("SELECT a FROM x WHERE a = 1 OR b = 2 AND NOT c")
+	assert.NoError(err)
+	assert.Equal(logical{"OR",
+		comparison{"=", field{[]string{"a"}}, literal{types.Number(1)}},
+		logical{"AND",
+			comparison{"=", field{[]string{"b"}}, literal{types.Number(2)}},
+			not{field{[]string{"c"}}},
+		},
+	}, q.where)
+}
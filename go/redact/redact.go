@@ -0,0 +1,289 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package redact makes copies of values with the values in them which match
+// rules hashed, masked or dropped, e.g. to make copies of production data
+// which are safe for developers to use.
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Action is what a Redactor does with the values which match a Rule.
+type Action int
+
+const (
+	// Drop removes the values from the Structs and collections they're in.
+	Drop Action = iota
+	// Mask replaces Strings with MaskString, Numbers with 0, Bools with
+	// false, and Blobs, Lists, Maps and Sets with empty ones. The fields of
+	// Structs are masked in turn. Refs and Types, which can't be masked, are
+	// dropped.
+	Mask
+	// Hash replaces the values with a String of a hash of them keyed by the
+	// Salt of the Redactor, so that equal values stay equal, e.g. to keep
+	// joining on them, but can't be recovered.
+	Hash
+)
+
+// MaskString is what Mask replaces Strings with.
+const MaskString = "***"
+
+var actionNames = map[string]Action{"drop": Drop, "mask": Mask, "hash": Hash}
+
+func (a Action) String() string {
+	switch a {
+	case Drop:
+		return "drop"
+	case Mask:
+		return "mask"
+	case Hash:
+		return "hash"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Rule matches the values to redact, either by their path or, if Pattern is
+// nil, by the name of the Struct field they're in.
+type Rule struct {
+	Pattern Pattern
+	Field   string
+	Action  Action
+}
+
+// ParseRule parses a rule of the form <action>:<pattern>, where action is
+// drop, mask or hash, and pattern is either a Pattern, if it starts with ".",
+// "[" or "**", or else a field name, e.g. "hash:email" or
+// "drop:.users[*].ssn".
+func ParseRule(str string) (Rule, error) {
+	parts := strings.SplitN(str, ":", 2)
+	if len(parts) != 2 {
+		return Rule{}, fmt.Errorf("Invalid rule %s, expected <action>:<pattern>", str)
+	}
+	action, ok := actionNames[parts[0]]
+	if !ok {
+		return Rule{}, fmt.Errorf("Invalid action %s, expected drop, mask or hash", parts[0])
+	}
+	pattern := parts[1]
+	if strings.HasPrefix(pattern, ".") || strings.HasPrefix(pattern, "[") || strings.HasPrefix(pattern, "**") {
+		p, err := ParsePattern(pattern)
+		if err != nil {
+			return Rule{}, err
+		}
+		return Rule{Pattern: p, Action: action}, nil
+	}
+	if !types.IsValidStructFieldName(pattern) {
+		return Rule{}, fmt.Errorf("Invalid field name %s", pattern)
+	}
+	return Rule{Field: pattern, Action: action}, nil
+}
+
+// Matches returns true if the value at p, from the root of the value being
+// redacted, matches r.
+func (r Rule) Matches(p types.Path) bool {
+	if r.Pattern != nil {
+		return r.Pattern.Matches(p)
+	}
+	if len(p) == 0 {
+		return false
+	}
+	fp, ok := p[len(p)-1].(types.FieldPath)
+	return ok && fp.Name == r.Field
+}
+
+// Pattern matches Paths. It's spelled as a Path, see
+// https://github.com/attic-labs/noms/blob/master/doc/spelling.md, in which
+// ".*" matches any field, "[*]" any element of a List, Map or Set, "[*]@key"
+// any key of a Map, and "**" any number of parts, including none.
+type Pattern []patternPart
+
+type patternPart struct {
+	anyDepth bool
+	// field is the name of the field, or "*" for any, if this part matches
+	// a FieldPath.
+	field string
+	// anyIndex, idx or h are the index this part matches, if it doesn't
+	// match a FieldPath.
+	anyIndex bool
+	idx      types.Value
+	h        hash.Hash
+	intoKey  bool
+}
+
+var fieldNameRe = regexp.MustCompile(`^[a-zA-Z0-9_]+`)
+
+// ParsePattern parses str into a Pattern.
+func ParsePattern(str string) (Pattern, error) {
+	if str == "" {
+		return nil, errors.New("Empty pattern")
+	}
+	p := Pattern{}
+	for rest := str; rest != ""; {
+		var part patternPart
+		switch {
+		case strings.HasPrefix(rest, "**"):
+			part.anyDepth = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, ".*"):
+			part.field = "*"
+			rest = rest[2:]
+		case rest[0] == '.':
+			name := fieldNameRe.FindString(rest[1:])
+			if !types.IsValidStructFieldName(name) {
+				return nil, fmt.Errorf("Invalid field in pattern %s: %s", str, rest[1:])
+			}
+			part.field = name
+			rest = rest[1+len(name):]
+		case strings.HasPrefix(rest, "[*]"):
+			part.anyIndex = true
+			rest = rest[3:]
+		case rest == "[":
+			return nil, fmt.Errorf("Invalid pattern %s: ends in [", str)
+		case rest[0] == '[':
+			idx, h, rem, err := types.ParsePathIndex(rest[1:])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid index in pattern %s: %s", str, err)
+			}
+			if !strings.HasPrefix(rem, "]") {
+				return nil, fmt.Errorf("Invalid pattern %s: [ is missing closing ]", str)
+			}
+			part.idx, part.h = idx, h
+			rest = rem[1:]
+		default:
+			return nil, fmt.Errorf("Invalid pattern %s at %s", str, rest)
+		}
+		if strings.HasPrefix(rest, "@key") {
+			if part.anyDepth || part.field != "" {
+				return nil, fmt.Errorf("Invalid pattern %s: @key must follow an index", str)
+			}
+			part.intoKey = true
+			rest = rest[4:]
+		}
+		p = append(p, part)
+	}
+	return p, nil
+}
+
+// Matches returns true if path matches p.
+func (p Pattern) Matches(path types.Path) bool {
+	if len(p) == 0 {
+		return len(path) == 0
+	}
+	if p[0].anyDepth {
+		for i := 0; i <= len(path); i++ {
+			if p[1:].Matches(path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(path) > 0 && p[0].matches(path[0]) && p[1:].Matches(path[1:])
+}
+
+func (pp patternPart) matches(part types.PathPart) bool {
+	switch part := part.(type) {
+	case types.FieldPath:
+		return pp.field == "*" || pp.field == part.Name
+	case types.IndexPath:
+		if pp.field != "" || pp.intoKey != part.IntoKey {
+			return false
+		}
+		return pp.anyIndex || (pp.idx != nil && pp.idx.Equals(part.Index)) || pp.h == part.Index.Hash()
+	case types.HashIndexPath:
+		if pp.field != "" || pp.intoKey != part.IntoKey {
+			return false
+		}
+		return pp.anyIndex || pp.h == part.Hash || (pp.idx != nil && pp.idx.Hash() == part.Hash)
+	case types.AtAnnotation:
+		return pp.field == "" && pp.anyIndex && pp.intoKey == part.IntoKey
+	}
+	return false
+}
+
+// Redactor redacts values according to Rules, of which the first that a
+// value matches applies.
+type Redactor struct {
+	Rules []Rule
+	// Salt keys the hashes of the values redacted by Hash.
+	Salt []byte
+}
+
+// Value returns a copy of v, in which the values which match the rules of r
+// are redacted, or nil if v itself is dropped. The values which Refs in v
+// point to are read from vrw and redacted in turn, as if they were at the
+// path of the Ref, and written to vrw if they changed.
+func (r Redactor) Value(v types.Value, vrw types.ValueReadWriter) types.Value {
+	return r.redact(types.Path{}, v, vrw)
+}
+
+func (r Redactor) redact(base types.Path, v types.Value, vrw types.ValueReadWriter) types.Value {
+	return types.Transform(v, types.Transformer{
+		Pre: func(p types.Path, v types.Value) (types.Value, bool) {
+			full := append(base[:len(base):len(base)], p...)
+			for _, rule := range r.Rules {
+				if rule.Matches(full) {
+					return r.apply(rule.Action, v), false
+				}
+			}
+			if ref, ok := v.(types.Ref); ok {
+				target := ref.TargetValue(vrw)
+				redacted := r.redact(full, target, vrw)
+				if redacted == nil {
+					return nil, false
+				}
+				if redacted.Equals(target) {
+					return ref, false
+				}
+				return vrw.WriteValue(redacted), false
+			}
+			return v, true
+		},
+	})
+}
+
+func (r Redactor) apply(action Action, v types.Value) types.Value {
+	switch action {
+	case Mask:
+		return mask(v)
+	case Hash:
+		h := v.Hash()
+		return types.String(hash.Of(append(append([]byte{}, r.Salt...), h[:]...)).String())
+	}
+	return nil
+}
+
+func mask(v types.Value) types.Value {
+	switch v := v.(type) {
+	case types.String:
+		return types.String(MaskString)
+	case types.Number:
+		return types.Number(0)
+	case types.Bool:
+		return types.Bool(false)
+	case types.Blob:
+		return types.NewEmptyBlob()
+	case types.List:
+		return types.NewList()
+	case types.Map:
+		return types.NewMap()
+	case types.Set:
+		return types.NewSet()
+	case types.Struct:
+		data := types.StructData{}
+		v.IterFields(func(name string, fv types.Value) {
+			if mv := mask(fv); mv != nil {
+				data[name] = mv
+			}
+		})
+		return types.NewStruct(v.Name(), data)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package redact
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := ParseRule("hash:email")
+	assert.NoError(err)
+	assert.Equal(Rule{Field: "email", Action: Hash}, r)
+
+	r, err = ParseRule("drop:.users[*].ssn")
+	assert.NoError(err)
+	assert.Equal(Drop, r.Action)
+	assert.Len(r.Pattern, 3)
+
+	for _, str := range []string{"email", "erase:email", "mask:", "mask:not-a-field", "drop:.users[", "drop:.users[1", "drop:.a@key", "drop:.a!"} {
+		_, err := ParseRule(str)
+		assert.Error(err, str)
+	}
+}
+
+func TestPatternMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	matches := func(pattern, path string) bool {
+		p, err := ParsePattern(pattern)
+		assert.NoError(err)
+		return p.Matches(types.MustParsePath(path))
+	}
+	h := types.String("bob").Hash().String()
+
+	assert.True(matches(".users", ".users"))
+	assert.False(matches(".users", ".users.ssn"))
+	assert.True(matches(".users[*].ssn", `.users["bob"].ssn`))
+	assert.True(matches(".users[*].ssn", ".users[0].ssn"))
+	assert.True(matches(".users[*].ssn", ".users@at(0).ssn"))
+	assert.True(matches(".users[*].ssn", ".users[#"+h+"].ssn"))
+	assert.False(matches(".users[*].ssn", `.users["bob"]@key.ssn`))
+	assert.True(matches(".users[*]@key", `.users["bob"]@key`))
+	assert.True(matches(`.users["bob"].ssn`, `.users["bob"].ssn`))
+	assert.True(matches(`.users["bob"].ssn`, ".users[#"+h+"].ssn"))
+	assert.True(matches(".users[#"+h+"].ssn", `.users["bob"].ssn`))
+	assert.False(matches(`.users["bob"].ssn`, `.users["alice"].ssn`))
+	assert.True(matches(".*.ssn", ".people.ssn"))
+	assert.False(matches(".*.ssn", `.people["bob"].ssn`))
+	assert.True(matches("**.ssn", ".ssn"))
+	assert.True(matches("**.ssn", `.people["bob"].ssn`))
+	assert.False(matches("**.ssn", `.people["bob"].ssn.last4`))
+	assert.True(matches(".people**", `.people["bob"].ssn`))
+}
+
+func TestRedactor(t *testing.T) {
+	assert := assert.New(t)
+	vs := types.NewTestValueStore()
+
+	person := func(name, ssn, email string) types.Struct {
+		return types.NewStruct("Person", types.StructData{
+			"name":  types.String(name),
+			"ssn":   types.String(ssn),
+			"email": types.String(email),
+			"age":   types.Number(30),
+		})
+	}
+	alice, bob := person("alice", "123", "a@example.com"), person("bob", "456", "b@example.com")
+	v := types.NewStruct("", types.StructData{
+		"people": types.NewMap(types.String("alice"), alice, types.String("bob"), bob),
+		"best":   vs.WriteValue(alice),
+		"other":  vs.WriteValue(types.String("untouched")),
+		"secret": types.NewStruct("Secret", types.StructData{"key": types.String("k"), "n": types.Number(1), "r": vs.WriteValue(types.Bool(true))}),
+	})
+
+	rules := []Rule{}
+	for _, str := range []string{"drop:ssn", "hash:email", "mask:.secret", "mask:.people[*].age"} {
+		rule, err := ParseRule(str)
+		assert.NoError(err)
+		rules = append(rules, rule)
+	}
+	r := Redactor{Rules: rules, Salt: []byte("salt")}
+	redacted := r.Value(v, vs).(types.Struct)
+
+	hashed := r.apply(Hash, types.String("a@example.com"))
+	assert.NotEqual(types.String("a@example.com"), hashed)
+	assert.NotEqual(hashed, Redactor{Salt: []byte("pepper")}.apply(Hash, types.String("a@example.com")))
+
+	a := redacted.Get("people").(types.Map).Get(types.String("alice")).(types.Struct)
+	_, ok := a.MaybeGet("ssn")
+	assert.False(ok)
+	assert.Equal(hashed, a.Get("email"))
+	assert.Equal(types.String("alice"), a.Get("name"))
+	assert.Equal(types.Number(0), a.Get("age"))
+
+	// Refs are followed, and the values they point to redacted as if they
+	// were at the path of the Ref.
+	best := redacted.Get("best").(types.Ref).TargetValue(vs).(types.Struct)
+	_, ok = best.MaybeGet("ssn")
+	assert.False(ok)
+	assert.Equal(hashed, best.Get("email"))
+	assert.Equal(types.Number(30), best.Get("age"))
+	assert.True(v.Get("other").Equals(redacted.Get("other")))
+
+	assert.True(types.NewStruct("Secret", types.StructData{
+		"key": types.String(MaskString),
+		"n":   types.Number(0),
+	}).Equals(redacted.Get("secret")))
+
+	assert.Nil(Redactor{Rules: []Rule{{Pattern: Pattern{{anyDepth: true}}, Action: Drop}}}.Value(v, vs))
+	assert.True(v.Equals(Redactor{}.Value(v, vs)))
+}
@@ -8,13 +8,21 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
-var datasetCapturePrefixRe = regexp.MustCompile("^(" + datas.DatasetRe.String() + ")")
+var (
+	datasetCapturePrefixRe = regexp.MustCompile("^(" + datas.DatasetRe.String() + ")")
+	atTimeRe               = regexp.MustCompile(`^@(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2}))`)
+	commitsBackRe          = regexp.MustCompile(`^~(\d+)`)
+)
 
 // AbsolutePath describes the location of a Value within a Noms database.
 //
@@ -30,6 +38,13 @@ type AbsolutePath struct {
 	// Hash is the hash this AbsolutePath is rooted at. Only one of Dataset and
 	// Hash should be set.
 	Hash hash.Hash
+	// At, if it isn't zero, roots this AbsolutePath at the state of Dataset
+	// at that time, i.e. the most recent of its commits whose meta date is at
+	// or before At, rather than its head. It's spelled ds@2017-06-01T00:00:00Z.
+	At time.Time
+	// Back roots this AbsolutePath that many commits before the head of
+	// Dataset, or before its commit At. It's spelled ds~3.
+	Back int
 	// Path is the relative path from Dataset or Hash. This can be empty. In
 	// that case, the AbsolutePath describes the value at either Dataset or
 	// Hash.
@@ -45,6 +60,8 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 	var h hash.Hash
 	var dataset string
 	var pathStr string
+	var at time.Time
+	var back int
 
 	if str[0] == '#' {
 		tail := str[1:]
@@ -68,10 +85,34 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 
 		dataset = datasetParts[1]
 		pathStr = str[len(dataset):]
+
+		if strings.HasPrefix(pathStr, "@") {
+			m := atTimeRe.FindStringSubmatch(pathStr)
+			var err error
+			if m != nil {
+				at, err = time.Parse(time.RFC3339, m[1])
+			}
+			if m == nil || err != nil {
+				return AbsolutePath{}, fmt.Errorf("Invalid time in %s, which should be like %s@2017-06-01T00:00:00Z", str, dataset)
+			}
+			pathStr = pathStr[len(m[0]):]
+		}
+
+		if strings.HasPrefix(pathStr, "~") {
+			m := commitsBackRe.FindStringSubmatch(pathStr)
+			var err error
+			if m != nil {
+				back, err = strconv.Atoi(m[1])
+			}
+			if m == nil || err != nil {
+				return AbsolutePath{}, fmt.Errorf("Invalid number of commits back in %s, which should be like %s~3", str, dataset)
+			}
+			pathStr = pathStr[len(m[0]):]
+		}
 	}
 
 	if len(pathStr) == 0 {
-		return AbsolutePath{Hash: h, Dataset: dataset}, nil
+		return AbsolutePath{Hash: h, Dataset: dataset, At: at, Back: back}, nil
 	}
 
 	path, err := types.ParsePath(pathStr)
@@ -79,15 +120,14 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 		return AbsolutePath{}, err
 	}
 
-	return AbsolutePath{Hash: h, Dataset: dataset, Path: path}, nil
+	return AbsolutePath{Hash: h, Dataset: dataset, At: at, Back: back, Path: path}, nil
 }
 
 // Resolve returns the Value reachable by 'p' in 'db'.
 func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
 	if len(p.Dataset) > 0 {
 		var ok bool
-		ds := db.GetDataset(p.Dataset)
-		if val, ok = ds.MaybeHead(); !ok {
+		if val, ok = p.resolveCommit(db); !ok {
 			val = nil
 		}
 	} else if !p.Hash.IsEmpty() {
@@ -102,6 +142,81 @@ func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
 	return
 }
 
+// resolveCommit returns the commit of p.Dataset which p is rooted at, which
+// is its head unless p travels back in time.
+func (p AbsolutePath) resolveCommit(db datas.Database) (commit types.Struct, ok bool) {
+	commit, ok = db.GetDataset(p.Dataset).MaybeHead()
+	if ok && !p.At.IsZero() {
+		commit, ok = commitAt(db, commit, p.At)
+	}
+	for i := 0; ok && i < p.Back; i++ {
+		commit, ok = highestParent(db, commit)
+	}
+	return
+}
+
+// IsHistorical returns whether p is rooted at a commit of Dataset other than
+// its head, as ds@2017-06-01T00:00:00Z and ds~3 are.
+func (p AbsolutePath) IsHistorical() bool {
+	return !p.At.IsZero() || p.Back > 0
+}
+
+// commitAt returns the most recent of commit and its ancestors whose meta date
+// is at or before t. Commits without a date are skipped.
+func commitAt(vr types.ValueReader, commit types.Struct, t time.Time) (types.Struct, bool) {
+	q := &types.RefByHeight{types.NewRef(commit)}
+	queued := map[hash.Hash]bool{}
+	for !q.Empty() {
+		for _, r := range q.PopRefsOfHeight(q.MaxHeight()) {
+			c := r.TargetValue(vr).(types.Struct)
+			if date, ok := commitDate(c); ok && !date.After(t) {
+				return c, true
+			}
+			c.Get(datas.ParentsField).(types.Set).IterAll(func(v types.Value) {
+				if r := v.(types.Ref); !queued[r.TargetHash()] {
+					queued[r.TargetHash()] = true
+					q.PushBack(r)
+				}
+			})
+		}
+		sort.Sort(q)
+	}
+	return types.Struct{}, false
+}
+
+// commitDate returns the date in the meta of commit, if there is one.
+func commitDate(commit types.Struct) (time.Time, bool) {
+	meta, ok := commit.MaybeGet(datas.MetaField)
+	if !ok {
+		return time.Time{}, false
+	}
+	date, ok := meta.(types.Struct).MaybeGet("date")
+	if !ok || date.Kind() != types.StringKind {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{CommitMetaDateFormat, time.RFC3339} {
+		if t, err := time.Parse(layout, string(date.(types.String))); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// highestParent returns the parent of commit with the longest history, which
+// for merges is usually the branch that was merged into.
+func highestParent(vr types.ValueReader, commit types.Struct) (types.Struct, bool) {
+	var parent types.Ref
+	commit.Get(datas.ParentsField).(types.Set).IterAll(func(v types.Value) {
+		if r := v.(types.Ref); r.Height() > parent.Height() {
+			parent = r
+		}
+	})
+	if parent.Height() == 0 {
+		return types.Struct{}, false
+	}
+	return parent.TargetValue(vr).(types.Struct), true
+}
+
 func (p AbsolutePath) IsEmpty() bool {
 	return p.Dataset == "" && p.Hash.IsEmpty()
 }
@@ -113,6 +228,12 @@ func (p AbsolutePath) String() (str string) {
 
 	if len(p.Dataset) > 0 {
 		str = p.Dataset
+		if !p.At.IsZero() {
+			str += "@" + p.At.Format(time.RFC3339Nano)
+		}
+		if p.Back > 0 {
+			str += "~" + strconv.Itoa(p.Back)
+		}
 	} else if !p.Hash.IsEmpty() {
 		str = "#" + p.Hash.String()
 	} else {
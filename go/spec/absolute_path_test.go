@@ -28,6 +28,9 @@ func TestAbsolutePathToAndFromString(t *testing.T) {
 	h := types.Number(42).Hash() // arbitrary hash
 	test(fmt.Sprintf("foo.bar[#%s]", h.String()))
 	test(fmt.Sprintf("#%s.bar[42]", h.String()))
+	test("foo@2017-06-01T00:00:00Z")
+	test("foo@2017-06-01T12:30:00.5-07:00~3.value")
+	test("foo~3")
 }
 
 func TestAbsolutePaths(t *testing.T) {
@@ -124,4 +127,44 @@ func TestAbsolutePathParseErrors(t *testing.T) {
 	test("#abc", "Invalid hash: abc")
 	invHash := strings.Repeat("z", hash.StringLen)
 	test("#"+invHash, "Invalid hash: "+invHash)
+	test("ds@2017-13-01T00:00:00Z", "Invalid time in ds@2017-13-01T00:00:00Z, which should be like ds@2017-06-01T00:00:00Z")
+	test("ds@yesterday", "Invalid time in ds@yesterday, which should be like ds@2017-06-01T00:00:00Z")
+	test("ds~x", "Invalid number of commits back in ds~x, which should be like ds~3")
+	test("ds~3@2017-06-01T00:00:00Z", "Unsupported annotation: @")
+}
+
+func TestHistoricalAbsolutePaths(t *testing.T) {
+	assert := assert.New(t)
+
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	ds := db.GetDataset("ds")
+	for i, date := range []string{"2017-01-01T00:00:00+0000", "2017-02-01T00:00:00+0000", "2017-03-01T00:00:00+0000"} {
+		meta, err := CreateCommitMetaStruct(db, date, "", nil, nil)
+		assert.NoError(err)
+		ds, err = db.Commit(ds, types.Number(i), datas.CommitOptions{Meta: meta})
+		assert.NoError(err)
+	}
+
+	resolvesTo := func(exp types.Value, str string) {
+		p, err := NewAbsolutePath(str)
+		assert.NoError(err)
+		act := p.Resolve(db)
+		if exp == nil {
+			assert.Nil(act)
+		} else {
+			assert.True(exp.Equals(act), "%s: %s != %s", str, types.EncodedValue(exp), types.EncodedValue(act))
+		}
+	}
+
+	resolvesTo(types.Number(2), "ds~0.value")
+	resolvesTo(types.Number(1), "ds~1.value")
+	resolvesTo(types.Number(0), "ds~2.value")
+	resolvesTo(nil, "ds~3")
+	resolvesTo(types.Number(2), "ds@2017-06-01T00:00:00Z.value")
+	resolvesTo(types.Number(1), "ds@2017-02-01T00:00:00Z.value")
+	resolvesTo(types.Number(1), "ds@2017-02-01T01:00:00+01:00.value")
+	resolvesTo(types.Number(0), "ds@2017-01-31T23:59:59Z.value")
+	resolvesTo(types.Number(0), "ds@2017-02-15T00:00:00Z~1.value")
+	resolvesTo(nil, "ds@2016-12-31T00:00:00Z")
+	resolvesTo(nil, "missing~1")
 }
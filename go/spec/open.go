@@ -0,0 +1,153 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/migration"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/nomserrors"
+)
+
+// OpenMode is whether OpenDatabase creates the database of a Spec if it
+// doesn't exist, or fails. A database exists once something has been
+// committed to it.
+type OpenMode int
+
+const (
+	// OpenOrCreate opens the database, which is created if it doesn't exist.
+	OpenOrCreate OpenMode = iota
+	// OpenExisting opens the database, and fails with an
+	// nomserrors.ErrDatabaseNotFound if it doesn't exist, e.g. because its
+	// name is misspelled.
+	OpenExisting
+	// CreateNew creates the database, and fails with an
+	// nomserrors.ErrDatabaseExists if it exists.
+	CreateNew
+	// OpenAndMigrate is like OpenExisting, but first migrates the database
+	// to the current version of the noms format, if it's of an older one, see
+	// package migration. Only nbs databases can be migrated: the old data is
+	// kept next to the database, in a directory named for its version, e.g.
+	// /tmp/db.v7.8, which mustn't exist. Other databases of another version
+	// fail with an nomserrors.ErrVersionMismatch.
+	OpenAndMigrate
+)
+
+var openModeNames = map[OpenMode]string{
+	OpenOrCreate:   "create",
+	OpenExisting:   "existing",
+	CreateNew:      "new",
+	OpenAndMigrate: "migrate",
+}
+
+// String returns the name of m, as in the spec option open.
+func (m OpenMode) String() string {
+	return openModeNames[m]
+}
+
+func parseOpenMode(s string) (OpenMode, error) {
+	for m, name := range openModeNames {
+		if s == name {
+			return m, nil
+		}
+	}
+	return OpenOrCreate, fmt.Errorf(`Invalid open option %s, which should be "create", "existing", "new" or "migrate"`, s)
+}
+
+// OpenDatabase is like GetDatabase, but opens the database as the Open
+// option says, and returns the errors of opening it, rather than panicking.
+// Most errors are of one of the classes in package nomserrors, e.g.
+// ErrDatabaseNotFound, or ErrNetwork for a failed request to an http database.
+func (sp Spec) OpenDatabase() (db datas.Database, err error) {
+	if *sp.db != nil {
+		return *sp.db, nil
+	}
+	defer d.Recover(&err)
+	if db, err = sp.openDatabase(); err == nil {
+		*sp.db = db
+	}
+	return db, err
+}
+
+func (sp Spec) openDatabase() (datas.Database, error) {
+	mode := sp.Options.Open
+	if mode == OpenOrCreate {
+		return sp.createDatabase(), nil
+	}
+	if sp.Protocol == "nbs" && mode != CreateNew {
+		// Creating the directory of a misspelled database is what's to be
+		// avoided.
+		if _, err := os.Stat(sp.DatabaseName); os.IsNotExist(err) {
+			return nil, nomserrors.Errorf(nomserrors.ErrDatabaseNotFound, "Database not found: %s", sp.DatabaseName)
+		}
+	}
+	if mode == OpenAndMigrate {
+		if err := sp.migrate(); err != nil {
+			return nil, err
+		}
+	}
+
+	db := sp.createDatabase()
+	datasets, err := datas.TryDatasets(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	switch {
+	case datasets.Empty() && mode != CreateNew:
+		db.Close()
+		return nil, nomserrors.Errorf(nomserrors.ErrDatabaseNotFound, "Database not found: %s", sp.DatabaseName)
+	case !datasets.Empty() && mode == CreateNew:
+		db.Close()
+		return nil, nomserrors.Errorf(nomserrors.ErrDatabaseExists, "Database already exists: %s", sp.DatabaseName)
+	}
+	return db, nil
+}
+
+// migrate migrates the ChunkStore of sp to the current version, if it's of
+// another one. The directory of an nbs database is moved aside, and the
+// database is migrated from there to a new one in its place, which is
+// removed if the migration fails.
+func (sp Spec) migrate() error {
+	cs := sp.newChunkStore()
+	if cs == nil {
+		// Servers check the version of requests to http databases.
+		return nil
+	}
+	version := cs.Version()
+	if version == constants.DataVersion() {
+		return cs.Close()
+	}
+	cs.Close()
+	if sp.Protocol != "nbs" {
+		return nomserrors.Errorf(nomserrors.ErrVersionMismatch, "%s is of version %s, and only nbs databases can be migrated", sp.DatabaseName, version)
+	}
+
+	// The new tables are written where the old ones were, so that nbs
+	// replaces the indices it cached of any of the same name.
+	dir := filepath.Clean(sp.DatabaseName)
+	old := dir + ".v" + version
+	if err := os.Rename(dir, old); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0777); err != nil {
+		return err
+	}
+	src, sink := nbs.NewLocalStore(old, 1<<28), nbs.NewLocalStore(dir, 1<<28)
+	_, err := migration.Run(src, sink)
+	src.Close()
+	sink.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		os.Rename(old, dir)
+	}
+	return err
+}
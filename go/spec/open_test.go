@@ -0,0 +1,116 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/migration"
+	"github.com/attic-labs/noms/go/nomserrors"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func openDatabase(spec string) error {
+	sp, err := ForDatabase(spec)
+	if err != nil {
+		return err
+	}
+	defer sp.Close()
+	_, err = sp.OpenDatabase()
+	return err
+}
+
+func TestOpenDatabase(t *testing.T) {
+	assert := assert.New(t)
+	tmpDir, err := ioutil.TempDir("", "spec_test")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, "db")
+
+	// A misspelled database isn't created.
+	err = openDatabase(dir + "?open=existing")
+	assert.True(errors.Is(err, nomserrors.ErrDatabaseNotFound), "%s", err)
+	_, err = os.Stat(dir)
+	assert.True(os.IsNotExist(err))
+	sp, err := ForDatabase(dir + "?open=existing")
+	assert.NoError(err)
+	assert.Panics(func() { sp.GetDatabase() })
+
+	sp, err = ForDatabase(dir + "?open=new")
+	assert.NoError(err)
+	assert.Equal("nbs:"+dir+"?open=new", sp.String())
+	db, err := sp.OpenDatabase()
+	assert.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+	assert.NoError(sp.Close())
+
+	err = openDatabase(dir + "?open=new")
+	assert.True(errors.Is(err, nomserrors.ErrDatabaseExists), "%s", err)
+	assert.NoError(openDatabase(dir + "?open=existing"))
+	assert.NoError(openDatabase(dir + "?open=migrate"))
+	assert.NoError(openDatabase(dir))
+
+	// An empty directory isn't a database.
+	empty := filepath.Join(tmpDir, "empty")
+	assert.NoError(os.Mkdir(empty, 0777))
+	assert.True(errors.Is(openDatabase(empty+"?open=existing"), nomserrors.ErrDatabaseNotFound))
+	assert.True(errors.Is(openDatabase("mem?open=existing"), nomserrors.ErrDatabaseNotFound))
+
+	_, err = ForDatabase(dir + "?open=maybe")
+	assert.Error(err)
+}
+
+func TestOpenAndMigrateDatabase(t *testing.T) {
+	assert := assert.New(t)
+	tmpDir, err := ioutil.TempDir("", "spec_test")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, "db")
+
+	sp, err := ForDatabase(dir)
+	assert.NoError(err)
+	db := sp.GetDatabase()
+	_, err = db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+	assert.NoError(sp.Close())
+
+	// Make the database of an older version.
+	manifest := filepath.Join(dir, "manifest")
+	data, err := ioutil.ReadFile(manifest)
+	assert.NoError(err)
+	fields := strings.Split(string(data), ":")
+	fields[1] = "spec-test"
+	assert.NoError(ioutil.WriteFile(manifest, []byte(strings.Join(fields, ":")), 0666))
+
+	// There's no migration from the version yet.
+	err = openDatabase(dir + "?open=migrate")
+	assert.Error(err)
+	_, err = os.Stat(dir + ".vspec-test")
+	assert.True(os.IsNotExist(err))
+
+	migration.Register(migration.Migration{From: "spec-test", To: constants.NomsVersion, Migrate: migration.Copy})
+	sp, err = ForDatabase(dir + "?open=migrate")
+	assert.NoError(err)
+	db, err = sp.OpenDatabase()
+	assert.NoError(err)
+	assert.True(types.String("a").Equals(db.GetDataset("ds").HeadValue()))
+	assert.NoError(sp.Close())
+	_, err = os.Stat(dir + ".vspec-test")
+	assert.NoError(err)
+
+	sp, err = ForDatabase(dir)
+	assert.NoError(err)
+	cs := sp.NewChunkStore()
+	defer cs.Close()
+	assert.Equal(constants.DataVersion(), cs.Version())
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/sizecache"
+)
+
+// PathCache memoizes the values AbsolutePaths resolve to in a Database, e.g.
+// for servers which resolve the same paths for many requests. Values are
+// cached by the path and the head of its dataset, or its hash, so a path is
+// resolved again once the head of its dataset moves, and the values of old
+// heads are expired as they stop being used. The Database isn't rebased by
+// the cache, so call its Rebase method to see updates made elsewhere. A
+// PathCache is safe for concurrent use by multiple goroutines.
+type PathCache struct {
+	db    datas.Database
+	cache *sizecache.SizeCache
+}
+
+type pathCacheKey struct {
+	root hash.Hash // the head of the dataset of the path, or its hash
+	path string
+}
+
+// NewPathCache returns a PathCache of the values of up to size paths in db.
+func NewPathCache(db datas.Database, size uint64) *PathCache {
+	return &PathCache{db, sizecache.New(size)}
+}
+
+// Resolve returns the Value reachable by p in the Database of c, as
+// p.Resolve does.
+func (c *PathCache) Resolve(p AbsolutePath) types.Value {
+	key := pathCacheKey{p.Hash, p.String()}
+	if len(p.Dataset) > 0 {
+		head, ok := c.db.GetDataset(p.Dataset).MaybeHeadRef()
+		if !ok {
+			return nil
+		}
+		key.root = head.TargetHash()
+	}
+	if v, ok := c.cache.Get(key); ok {
+		if v == nil {
+			return nil
+		}
+		return v.(types.Value)
+	}
+	v := p.Resolve(c.db)
+	c.cache.Add(key, 1, v)
+	return v
+}
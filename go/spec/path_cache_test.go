@@ -0,0 +1,53 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestPathCache(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.NewMap(types.String("a"), types.Number(1)))
+	assert.NoError(err)
+
+	c := NewPathCache(db, 16)
+	resolve := func(str string) types.Value {
+		p, err := NewAbsolutePath(str)
+		assert.NoError(err)
+		return c.Resolve(p)
+	}
+	isCached := func(str string, root types.Ref) bool {
+		_, ok := c.cache.Get(pathCacheKey{root.TargetHash(), str})
+		return ok
+	}
+
+	assert.Nil(resolve("missing.value"))
+	assert.Equal(types.Number(1), resolve(`ds.value["a"]`))
+	assert.True(isCached(`ds.value["a"]`, ds.HeadRef()))
+	assert.Nil(resolve(`ds.value["b"]`))
+	assert.True(isCached(`ds.value["b"]`, ds.HeadRef()))
+
+	// Moving the head resolves the paths again.
+	old := ds.HeadRef()
+	ds, err = db.CommitValue(ds, types.NewMap(types.String("a"), types.Number(2), types.String("b"), types.Number(3)))
+	assert.NoError(err)
+	assert.Equal(types.Number(2), resolve(`ds.value["a"]`))
+	assert.Equal(types.Number(3), resolve(`ds.value["b"]`))
+	assert.Equal(types.Number(1), resolve(`ds~1.value["a"]`))
+	assert.True(isCached(`ds.value["a"]`, ds.HeadRef()))
+	assert.True(isCached(`ds.value["a"]`, old))
+
+	h := old.TargetHash()
+	assert.Equal(types.Number(1), resolve("#"+h.String()+`.value["a"]`))
+	assert.True(isCached("#"+h.String()+`.value["a"]`, old))
+}
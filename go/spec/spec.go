@@ -7,21 +7,31 @@
 package spec
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
-	"os"
+	"sync"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/googleauth"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
+	humanize "github.com/dustin/go-humanize"
 )
 
 const Separator = "::"
@@ -30,18 +40,59 @@ var datasetRe = regexp.MustCompile("^" + datas.DatasetRe.String() + "$")
 
 // SpecOptions customize Spec behavior.
 type SpecOptions struct {
-	// Authorization token for requests. For example, if the database is HTTP
-	// this will used for an `Authorization: Bearer ${authorization}` header.
+	// Authorization header of requests, if the database is HTTP, e.g.
+	// "Bearer ${token}".
 	Authorization string
+
+	// TLSConfig of requests, if the database is HTTPS and it isn't nil.
+	TLSConfig *tls.Config
+
+	// GCSCredentials is the service account key file of a gs database. If
+	// it and Authorization are empty, the application default credentials
+	// are used.
+	GCSCredentials string
+
+	// IPFSAPI is the address of the HTTP API of the IPFS node of an ipfs
+	// database. If it's empty, chunks.DefaultIPFSAPI is used.
+	IPFSAPI string
+
+	// ReadOnly makes GetDatabase return a Database which can't be changed, as
+	// the spec option readonly=1 does.
+	ReadOnly bool
+
+	// Cache is where to keep the chunks read from the ChunkStore, and how many
+	// bytes of them, as the spec option cache does: either "mem:<size>" or
+	// "disk:<size>", e.g. "disk:1GB". Empty means not to cache them. http
+	// databases have no ChunkStore, so this is ignored for them.
+	Cache string
+
+	// Verify makes GetDatabase return a Database which verifies every chunk
+	// and value it reads, as the spec option verify=1 does, see
+	// datas.NewVerifyingDatabase.
+	Verify bool
+
+	// Record is a file to append a record of each chunk read from the
+	// ChunkStore to, as the spec option record does, see
+	// chunks.RecordingStore. The operation of the records is the command line
+	// of the process. Chunks read from the cache are recorded too. http
+	// databases have no ChunkStore, so this is ignored for them.
+	Record string
+
+	// Open is whether GetDatabase and OpenDatabase create the database if it
+	// doesn't exist, or fail, as the spec option open does, e.g.
+	// open=existing.
+	Open OpenMode
 }
 
 // Spec locates a Noms database, dataset, or value globally.
 type Spec struct {
-	// Protocol is one of "mem", "ldb", "http", or "https".
+	// Protocol is one of "mem", "nbs", "aws", "s3", "gs", "ipfs", "http", or
+	// "https".
 	Protocol string
 
 	// DatabaseName is the name of the Spec's database, which is the string after
-	// "protocol:". http/https specs include their leading "//" characters.
+	// "protocol:". http/https specs include their leading "//" characters. It's
+	// empty for the unnamed in-memory database "mem".
 	DatabaseName string
 
 	// Options are the SpecOptions that the Spec was constructed with.
@@ -55,10 +106,18 @@ type Spec struct {
 }
 
 func newSpec(dbSpec string, opts SpecOptions) (Spec, error) {
+	dbSpec, opts, err := parseSpecOptions(dbSpec, opts)
+	if err != nil {
+		return Spec{}, err
+	}
+
 	protocol, dbName, err := parseDatabaseSpec(dbSpec)
 	if err != nil {
 		return Spec{}, err
 	}
+	if opts.Cache != "" && (protocol == "http" || protocol == "https") {
+		return Spec{}, fmt.Errorf("The cache option isn't supported by %s databases", protocol)
+	}
 
 	return Spec{
 		Protocol:     protocol,
@@ -105,6 +164,10 @@ func ForDatasetOpts(spec string, opts SpecOptions) (Spec, error) {
 		return Spec{}, errors.New("path is not allowed for dataset spec")
 	}
 
+	if path.IsHistorical() {
+		return Spec{}, errors.New("time travel is not allowed for dataset spec")
+	}
+
 	sp.Path = path
 	return sp, nil
 }
@@ -140,9 +203,16 @@ func ForPathOpts(spec string, opts SpecOptions) (Spec, error) {
 
 func (sp Spec) String() string {
 	s := sp.Protocol
-	if s != "mem" {
+	if s != "mem" || sp.DatabaseName != "" {
 		s += ":" + sp.DatabaseName
 	}
+	if q := sp.Options.query(); q != "" {
+		if strings.Contains(sp.DatabaseName, "?") {
+			s += "&" + q
+		} else {
+			s += "?" + q
+		}
+	}
 	p := sp.Path.String()
 	if p != "" {
 		s += Separator + p
@@ -152,28 +222,64 @@ func (sp Spec) String() string {
 
 // GetDatabase returns the Database instance that this Spec's DatabaseName
 // describes. The same Database instance is returned every time, unless Close
-// is called. If the Spec is closed, it is re-opened with a new Database.
+// is called. If the Spec is closed, it is re-opened with a new Database. If
+// the database can't be opened as the Open option says, GetDatabase panics
+// with the error OpenDatabase returns.
 func (sp Spec) GetDatabase() datas.Database {
-	if *sp.db == nil {
-		*sp.db = sp.createDatabase()
-	}
-	return *sp.db
+	db, err := sp.OpenDatabase()
+	d.PanicIfError(err)
+	return db
 }
 
 // NewChunkStore returns a new ChunkStore instance that this Spec's
 // DatabaseName describes. It's unusual to call this method, GetDatabase is
 // more useful. Unlike GetDatabase, a new ChunkStore instance is returned every
-// time. If there is no ChunkStore, for example remote databases, returns nil.
+// time, except for named in-memory databases such as mem:mydb, whose store is
+// shared by the process. If there is no ChunkStore, for example remote
+// databases, returns nil. The ChunkStore caches chunks as the Cache option
+// says, and records the chunks read as the Record option says.
 func (sp Spec) NewChunkStore() chunks.ChunkStore {
+	cs := sp.newChunkStore()
+	if cs == nil {
+		return nil
+	}
+	if sp.Options.Cache != "" {
+		onDisk, size, err := parseCacheOption(sp.Options.Cache)
+		d.PanicIfError(err)
+		if onDisk {
+			// Chunks never change, so the cache of a database can be kept
+			// between processes.
+			dir := filepath.Join(os.TempDir(), "noms-cache", hash.Of([]byte(sp.Protocol+":"+sp.DatabaseName)).String())
+			cs = chunks.NewDiskCachingStore(cs, dir, size)
+		} else {
+			cs = chunks.NewMemoryCachingStore(cs, size)
+		}
+	}
+	if sp.Options.Record != "" {
+		f, err := os.OpenFile(sp.Options.Record, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		d.PanicIfError(err)
+		op := append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...)
+		cs = chunks.NewRecordingStore(cs, f, strings.Join(op, " "))
+	}
+	return cs
+}
+
+func (sp Spec) newChunkStore() chunks.ChunkStore {
 	switch sp.Protocol {
 	case "http", "https":
 		return nil
 	case "aws":
 		return parseAWSSpec(sp.Href())
+	case "s3":
+		return parseS3Spec(sp.Href())
+	case "gs":
+		return parseGCSSpec(sp.Href(), sp.Options)
+	case "ipfs":
+		return parseIPFSSpec(sp.Href(), sp.Options)
 	case "nbs":
 		return nbs.NewLocalStore(sp.DatabaseName, 1<<28)
 	case "mem":
-		return chunks.NewMemoryStore()
+		return memStore(sp.DatabaseName)
 	}
 	panic("unreachable")
 }
@@ -189,6 +295,92 @@ func parseAWSSpec(awsURL string) chunks.ChunkStore {
 	return nbs.NewAWSStore(parts[0], u.Path, parts[1], s3.New(sess), ddb, 1<<28)
 }
 
+// parseS3Spec returns the store of an s3://bucket/prefix spec, whose tables
+// and manifest are kept under prefix/ in bucket. The region and credentials
+// are those of the standard AWS environment variables and config files. If no
+// region is configured, that of the bucket is used.
+func parseS3Spec(s3URL string) chunks.ChunkStore {
+	u, _ := url.Parse(s3URL)
+	sess := session.Must(session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable}))
+	if aws.StringValue(sess.Config.Region) == "" {
+		sess.Config.Region = aws.String(s3BucketRegion(sess, u.Host))
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return nbs.NewS3Store(u.Host, prefix, s3.New(sess), 1<<28)
+}
+
+func s3BucketRegion(sess *session.Session, bucket string) string {
+	// The location of a bucket can be asked of any region.
+	svc := s3.New(sess, aws.NewConfig().WithRegion("us-east-1"))
+	result, err := svc.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	d.PanicIfError(err)
+	switch region := aws.StringValue(result.LocationConstraint); region {
+	case "":
+		return "us-east-1"
+	case "EU":
+		return "eu-west-1"
+	default:
+		return region
+	}
+}
+
+// parseGCSSpec returns the store of a gs://bucket/prefix spec, whose tables
+// and manifest are kept under prefix/ in bucket, authenticated as opts say.
+func parseGCSSpec(gsURL string, opts SpecOptions) chunks.ChunkStore {
+	u, _ := url.Parse(gsURL)
+	var client *http.Client
+	if opts.Authorization != "" {
+		client = googleauth.NewStaticClient(opts.Authorization)
+	} else {
+		var err error
+		client, err = googleauth.NewClient(opts.GCSCredentials, googleauth.DevStorageScope)
+		d.PanicIfError(err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return nbs.NewGCSStore(u.Host, prefix, client, 1<<28)
+}
+
+// parseIPFSSpec returns the store of an ipfs://name spec, whose root is
+// published to the IPNS name of the key called name on the IPFS node that
+// opts say, or which is the read-only database of the IPNS name name.
+func parseIPFSSpec(ipfsURL string, opts SpecOptions) chunks.ChunkStore {
+	u, _ := url.Parse(ipfsURL)
+	api := opts.IPFSAPI
+	if api == "" {
+		api = chunks.DefaultIPFSAPI
+	}
+	return chunks.NewIPFSStore(api, u.Host)
+}
+
+// memStores are the stores of named in-memory databases, e.g. mem:mydb,
+// which are shared by every Spec of that name in the process.
+var memStores = struct {
+	sync.Mutex
+	m map[string]*chunks.MemoryStore
+}{m: map[string]*chunks.MemoryStore{}}
+
+// memStore returns the store of the in-memory database named name, or a new
+// unshared store if name is empty, as for the spec "mem".
+func memStore(name string) chunks.ChunkStore {
+	if name == "" {
+		return chunks.NewMemoryStore()
+	}
+	memStores.Lock()
+	defer memStores.Unlock()
+	ms, ok := memStores.m[name]
+	if !ok {
+		ms = chunks.NewMemoryStore()
+		memStores.m[name] = ms
+	}
+	return ms
+}
+
 // GetDataset returns the current Dataset instance for this Spec's Database.
 // GetDataset is live, so if Commit is called on this Spec's Database later, a
 // new up-to-date Dataset will returned on the next call to GetDataset.  If
@@ -215,7 +407,7 @@ func (sp Spec) GetValue() (val types.Value) {
 // an empty string.
 func (sp Spec) Href() string {
 	switch proto := sp.Protocol; proto {
-	case "http", "https", "aws":
+	case "http", "https", "aws", "s3", "gs", "ipfs":
 		return proto + ":" + sp.DatabaseName
 	default:
 		return ""
@@ -223,11 +415,13 @@ func (sp Spec) Href() string {
 }
 
 // Pin returns a Spec in which the dataset component, if any, has been replaced
-// with the hash of the HEAD of that dataset. This "pins" the path to the state
-// of the database at the current moment in time.  Returns itself if the
-// PathSpec is already "pinned".
+// with the hash of the HEAD of that dataset, or of the commit that the path
+// travels back in time to. This "pins" the path to the state of the database
+// at the current moment in time.  Returns itself if the PathSpec is already
+// "pinned".
 func (sp Spec) Pin() (Spec, bool) {
-	var ds datas.Dataset
+	var commit types.Struct
+	var ok bool
 
 	if !sp.Path.IsEmpty() {
 		if !sp.Path.Hash.IsEmpty() {
@@ -235,19 +429,18 @@ func (sp Spec) Pin() (Spec, bool) {
 			return sp, true
 		}
 
-		ds = sp.GetDatabase().GetDataset(sp.Path.Dataset)
+		commit, ok = sp.Path.resolveCommit(sp.GetDatabase())
 	} else {
-		ds = sp.GetDataset()
+		commit, ok = sp.GetDataset().MaybeHead()
 	}
 
-	commit, ok := ds.MaybeHead()
 	if !ok {
 		return Spec{}, false
 	}
 
 	r := sp
 	r.Path.Hash = commit.Hash()
-	r.Path.Dataset = ""
+	r.Path.Dataset, r.Path.At, r.Path.Back = "", time.Time{}, 0
 
 	return r, true
 }
@@ -262,19 +455,33 @@ func (sp Spec) Close() error {
 	return db.Close()
 }
 
-func (sp Spec) createDatabase() datas.Database {
+func (sp Spec) createDatabase() (db datas.Database) {
 	switch sp.Protocol {
 	case "http", "https":
-		return datas.NewRemoteDatabase(sp.Href(), sp.Options.Authorization)
-	case "aws":
-		return datas.NewDatabase(parseAWSSpec(sp.Href()))
+		var rdb *datas.RemoteDatabaseClient
+		if sp.Options.TLSConfig != nil {
+			rdb = datas.NewRemoteDatabaseTLS(sp.Href(), sp.Options.Authorization, sp.Options.TLSConfig)
+		} else {
+			rdb = datas.NewRemoteDatabase(sp.Href(), sp.Options.Authorization)
+		}
+		// There's no ChunkStore to verify, but verifying the values read
+		// verifies the hashes of their chunks too.
+		rdb.SetVerifyReads(sp.Options.Verify)
+		db = rdb
 	case "nbs":
 		os.Mkdir(sp.DatabaseName, 0777)
-		return datas.NewDatabase(nbs.NewLocalStore(sp.DatabaseName, 1<<28))
-	case "mem":
-		return datas.NewDatabase(chunks.NewMemoryStore())
+		fallthrough
+	default:
+		if sp.Options.Verify {
+			db = datas.NewVerifyingDatabase(sp.NewChunkStore())
+		} else {
+			db = datas.NewDatabase(sp.NewChunkStore())
+		}
 	}
-	panic("unreachable")
+	if sp.Options.ReadOnly {
+		db = datas.NewReadOnlyDatabase(db)
+	}
+	return
 }
 
 func parseDatabaseSpec(spec string) (protocol, name string, err error) {
@@ -301,7 +508,7 @@ func parseDatabaseSpec(spec string) (protocol, name string, err error) {
 	case "nbs":
 		protocol, name = parts[0], parts[1]
 
-	case "http", "https", "aws":
+	case "http", "https", "aws", "s3", "gs", "ipfs":
 		u, perr := url.Parse(spec)
 		if perr != nil {
 			err = perr
@@ -309,12 +516,20 @@ func parseDatabaseSpec(spec string) (protocol, name string, err error) {
 			err = fmt.Errorf("%s has empty host", spec)
 		} else if parts[0] == "aws" && u.Path == "" {
 			err = fmt.Errorf("%s does not specify a database ID", spec)
+		} else if parts[0] == "ipfs" && strings.Trim(u.Path, "/") != "" {
+			err = fmt.Errorf("%s has a path, but ipfs databases are named by just a key or IPNS name", spec)
 		} else {
 			protocol, name = parts[0], parts[1]
 		}
 
 	case "mem":
-		err = fmt.Errorf(`In-memory database must be specified as "mem", not "mem:"`)
+		if parts[1] == "" {
+			err = fmt.Errorf(`In-memory database must be specified as "mem" or "mem:name", not "mem:"`)
+		} else if !datasetRe.MatchString(parts[1]) {
+			err = fmt.Errorf("Invalid in-memory database name %s in %s", parts[1], spec)
+		} else {
+			protocol, name = parts[0], parts[1]
+		}
 
 	default:
 		err = fmt.Errorf("Invalid database protocol %s in %s", protocol, spec)
@@ -322,6 +537,108 @@ func parseDatabaseSpec(spec string) (protocol, name string, err error) {
 	return
 }
 
+// parseSpecOptions returns dbSpec without the options in its query, e.g.
+// "?readonly=1&cache=disk:1GB", and opts with them set. The rest of the query
+// of http and https databases is part of their URL, but other databases have
+// no other options.
+func parseSpecOptions(dbSpec string, opts SpecOptions) (string, SpecOptions, error) {
+	i := strings.Index(dbSpec, "?")
+	if i < 0 {
+		return dbSpec, opts, nil
+	}
+	isHTTP := strings.HasPrefix(dbSpec, "http:") || strings.HasPrefix(dbSpec, "https:")
+
+	rest := []string{}
+	for _, kv := range strings.Split(dbSpec[i+1:], "&") {
+		k, v := kv, ""
+		if j := strings.Index(kv, "="); j >= 0 {
+			k, v = kv[:j], kv[j+1:]
+		}
+		switch k {
+		case "readonly":
+			readOnly, err := strconv.ParseBool(v)
+			if v == "" {
+				readOnly, err = true, nil
+			}
+			if err != nil {
+				return "", SpecOptions{}, fmt.Errorf("Invalid readonly option %s in %s", v, dbSpec)
+			}
+			opts.ReadOnly = readOnly
+		case "cache":
+			if _, _, err := parseCacheOption(v); err != nil {
+				return "", SpecOptions{}, fmt.Errorf("%s in %s", err, dbSpec)
+			}
+			opts.Cache = v
+		case "verify":
+			verify, err := strconv.ParseBool(v)
+			if v == "" {
+				verify, err = true, nil
+			}
+			if err != nil {
+				return "", SpecOptions{}, fmt.Errorf("Invalid verify option %s in %s", v, dbSpec)
+			}
+			opts.Verify = verify
+		case "record":
+			if v == "" {
+				return "", SpecOptions{}, fmt.Errorf("Missing file of record option in %s", dbSpec)
+			}
+			opts.Record = v
+		case "open":
+			mode, err := parseOpenMode(v)
+			if err != nil {
+				return "", SpecOptions{}, fmt.Errorf("%s in %s", err, dbSpec)
+			}
+			opts.Open = mode
+		default:
+			if !isHTTP {
+				return "", SpecOptions{}, fmt.Errorf("Unknown option %s in %s", k, dbSpec)
+			}
+			rest = append(rest, kv)
+		}
+	}
+
+	dbSpec = dbSpec[:i]
+	if len(rest) > 0 {
+		dbSpec += "?" + strings.Join(rest, "&")
+	}
+	return dbSpec, opts, nil
+}
+
+// parseCacheOption parses the cache option, e.g. "disk:1GB", into where to
+// cache chunks and how many bytes of them.
+func parseCacheOption(cache string) (onDisk bool, size uint64, err error) {
+	parts := strings.SplitN(cache, ":", 2)
+	if len(parts) != 2 || (parts[0] != "mem" && parts[0] != "disk") {
+		return false, 0, fmt.Errorf(`Invalid cache option %s, which should be "mem:<size>" or "disk:<size>"`, cache)
+	}
+	size, err = humanize.ParseBytes(parts[1])
+	if err != nil {
+		return false, 0, fmt.Errorf("Invalid cache size %s", parts[1])
+	}
+	return parts[0] == "disk", size, nil
+}
+
+// query returns the spec options of opts, as in a spec's query.
+func (opts SpecOptions) query() string {
+	q := []string{}
+	if opts.ReadOnly {
+		q = append(q, "readonly=1")
+	}
+	if opts.Cache != "" {
+		q = append(q, "cache="+opts.Cache)
+	}
+	if opts.Verify {
+		q = append(q, "verify=1")
+	}
+	if opts.Record != "" {
+		q = append(q, "record="+opts.Record)
+	}
+	if opts.Open != OpenOrCreate {
+		q = append(q, "open="+opts.Open.String())
+	}
+	return strings.Join(q, "&")
+}
+
 func splitDatabaseSpec(spec string) (string, string, error) {
 	lastIdx := strings.LastIndex(spec, Separator)
 	if lastIdx == -1 {
@@ -9,9 +9,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
@@ -153,6 +156,184 @@ func TestNBSDatabaseSpec(t *testing.T) {
 // TestLDBDatabaseSpec, TestMemDatasetSpec/TestMem*PathSpec cover general
 // dataset/path behaviour, and ForDataset/ForPath test LDB parsing.
 
+func TestNamedMemDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	sp1, err := ForDataset("mem:TestNamedMemDatabase::ds")
+	assert.NoError(err)
+	defer sp1.Close()
+	db := sp1.GetDatabase()
+	_, err = db.CommitValue(sp1.GetDataset(), types.String("hello"))
+	assert.NoError(err)
+
+	// Every spec of the same name shares the database, even once closed...
+	sp2, err := ForPath("mem:TestNamedMemDatabase::ds.value")
+	assert.NoError(err)
+	assert.Equal(types.String("hello"), sp2.GetValue())
+	sp2.Close()
+	assert.Equal(types.String("hello"), sp2.GetValue())
+	assert.Equal(db.Datasets().Hash(), sp2.NewChunkStore().Root())
+
+	// ...but not other names, or the unnamed mem database.
+	for _, str := range []string{"mem:TestNamedMemDatabase2::ds.value", "mem::ds.value"} {
+		sp, err := ForPath(str)
+		assert.NoError(err)
+		assert.Nil(sp.GetValue(), str)
+	}
+}
+
+func TestSpecOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		spec, databaseName, canonicalSpec string
+		readOnly                          bool
+		cache                             string
+	}{
+		{"mem?readonly=1", "", "mem?readonly=1", true, ""},
+		{"mem:db?readonly", "db", "mem:db?readonly=1", true, ""},
+		{"nbs:/tmp/db?cache=mem:1MB&readonly=false", "/tmp/db", "nbs:/tmp/db?cache=mem:1MB", false, "mem:1MB"},
+		{"s3://bucket/db?readonly=true&cache=disk:1GB", "//bucket/db", "s3://bucket/db?readonly=1&cache=disk:1GB", true, "disk:1GB"},
+		{"http://example.com/db?access_token=abc&readonly=1", "//example.com/db?access_token=abc", "http://example.com/db?access_token=abc&readonly=1", true, ""},
+		{"https://example.com/db?readonly=1", "//example.com/db", "https://example.com/db?readonly=1", true, ""},
+	}
+	for _, tc := range testCases {
+		sp, err := ForDatabase(tc.spec)
+		if !assert.NoError(err, tc.spec) {
+			continue
+		}
+		assert.Equal(tc.databaseName, sp.DatabaseName)
+		assert.Equal(tc.readOnly, sp.Options.ReadOnly)
+		assert.Equal(tc.cache, sp.Options.Cache)
+		assert.Equal(tc.canonicalSpec, sp.String())
+	}
+
+	sp, err := ForDatabase("nbs:/tmp/db?verify")
+	assert.NoError(err)
+	assert.True(sp.Options.Verify)
+	assert.Equal("nbs:/tmp/db?verify=1", sp.String())
+
+	sp, err = ForDatabase("nbs:/tmp/db?record=/tmp/access.log&readonly")
+	assert.NoError(err)
+	assert.Equal("/tmp/access.log", sp.Options.Record)
+	assert.Equal("nbs:/tmp/db?readonly=1&record=/tmp/access.log", sp.String())
+
+	sp, err = ForPath("mem:db?readonly=1::ds.value")
+	assert.NoError(err)
+	assert.True(sp.Options.ReadOnly)
+	assert.Equal("ds", sp.Path.Dataset)
+
+	for _, spec := range []string{
+		"mem?foo=bar",
+		"mem?readonly=maybe",
+		"mem?verify=maybe",
+		"mem?cache=disk",
+		"mem?cache=tape:1GB",
+		"mem?cache=mem:lots",
+		"mem?record",
+		"http://example.com?cache=mem:1MB",
+	} {
+		_, err := ForDatabase(spec)
+		assert.Error(err, spec)
+	}
+}
+
+func TestVerifySpec(t *testing.T) {
+	assert := assert.New(t)
+
+	sp, err := ForDataset("mem:TestVerifySpec?verify=1::ds")
+	assert.NoError(err)
+	defer sp.Close()
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.NewList(types.String("hello")))
+	assert.NoError(err)
+	sp.GetDatabase().Rebase()
+	assert.True(types.NewList(types.String("hello")).Equals(sp.GetDataset().HeadValue()))
+}
+
+func TestRecordSpec(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "TestRecordSpec")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "access.log")
+
+	sp, err := ForDataset("mem:TestRecordSpec?record=" + log + "::ds")
+	assert.NoError(err)
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.String("hello"))
+	assert.NoError(err)
+	assert.NoError(sp.Close())
+
+	sp, err = ForDataset("mem:TestRecordSpec?record=" + log + "::ds")
+	assert.NoError(err)
+	assert.Equal(types.String("hello"), sp.GetDataset().HeadValue())
+	head := sp.GetDataset().HeadRef().TargetHash()
+	assert.NoError(sp.Close())
+
+	f, err := os.Open(log)
+	assert.NoError(err)
+	defer f.Close()
+	read := hash.HashSet{}
+	assert.NoError(chunks.ReadAccessLog(f, func(rec chunks.AccessRecord) {
+		read.Insert(rec.Hash)
+		assert.Contains(rec.Op, filepath.Base(os.Args[0]))
+	}))
+	assert.True(read.Has(head))
+}
+
+func TestReadOnlySpec(t *testing.T) {
+	assert := assert.New(t)
+
+	sp, err := ForDataset("mem:TestReadOnlySpec::ds")
+	assert.NoError(err)
+	defer sp.Close()
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.String("hello"))
+	assert.NoError(err)
+
+	ro, err := ForDataset("mem:TestReadOnlySpec?readonly=1::ds")
+	assert.NoError(err)
+	defer ro.Close()
+	assert.Equal(types.String("hello"), ro.GetDataset().HeadValue())
+	_, err = ro.GetDatabase().CommitValue(ro.GetDataset(), types.String("bye"))
+	assert.Equal(datas.ErrReadOnly, err)
+}
+
+func TestCacheSpec(t *testing.T) {
+	assert := assert.New(t)
+	tmpDir, err := ioutil.TempDir("", "spec_test")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmpDir)
+
+	dbDir := path.Join(tmpDir, "db")
+	sp, err := ForDataset(dbDir + "::ds")
+	assert.NoError(err)
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.String("hello"))
+	assert.NoError(err)
+	sp.Close()
+
+	head := types.String("hello")
+	for _, cache := range []string{"mem:1MB", "disk:1MB"} {
+		sp, err := ForDataset(dbDir + "?cache=" + cache + "::ds")
+		assert.NoError(err)
+		assert.Equal(head, sp.GetDataset().HeadValue())
+		head = types.String(cache)
+		_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), head)
+		assert.NoError(err)
+		assert.Equal(head, sp.GetDataset().HeadValue())
+		sp.Close()
+	}
+
+	// The disk cache is kept in the temporary directory.
+	infos, err := ioutil.ReadDir(path.Join(tmpDir, "noms-cache"))
+	assert.NoError(err)
+	if assert.Len(infos, 1) {
+		chunks, err := ioutil.ReadDir(path.Join(tmpDir, "noms-cache", infos[0].Name()))
+		assert.NoError(err)
+		assert.NotEmpty(chunks)
+	}
+}
+
 func TestCloseSpecWithoutOpen(t *testing.T) {
 	s, err := ForDatabase("mem")
 	assert.NoError(t, err)
@@ -182,6 +363,13 @@ func TestHref(t *testing.T) {
 	sp, _ = ForPath("aws://table:bucket/foo/bar/baz::myds.my.path")
 	assert.Equal("aws://table:bucket/foo/bar/baz", sp.Href())
 
+	sp, _ = ForDataset("s3://bucket/foo/bar::myds")
+	assert.Equal("s3://bucket/foo/bar", sp.Href())
+	sp, _ = ForDataset("gs://bucket/foo/bar::myds")
+	assert.Equal("gs://bucket/foo/bar", sp.Href())
+	sp, _ = ForDataset("ipfs://self::myds")
+	assert.Equal("ipfs://self", sp.Href())
+
 	sp, err := ForPath("mem::myds.my.path")
 	assert.NoError(err)
 	assert.Equal("", sp.Href())
@@ -191,7 +379,7 @@ func TestForDatabase(t *testing.T) {
 	assert := assert.New(t)
 
 	badSpecs := []string{
-		"mem:stuff",
+		"mem:stuff.etc",
 		"mem::",
 		"mem:",
 		"http:",
@@ -207,6 +395,15 @@ func TestForDatabase(t *testing.T) {
 		"aws://t:b",
 		"aws://t",
 		"aws://t:",
+		"s3:",
+		"s3://",
+		"s3:///prefix",
+		"gs:",
+		"gs://",
+		"gs:///prefix",
+		"ipfs:",
+		"ipfs://",
+		"ipfs://self/db",
 	}
 
 	for _, spec := range badSpecs {
@@ -225,6 +422,7 @@ func TestForDatabase(t *testing.T) {
 		{"http://localhost:8000/fff", "http", "//localhost:8000/fff", ""},
 		{"https://local.attic.io/john/doe", "https", "//local.attic.io/john/doe", ""},
 		{"mem", "mem", "", ""},
+		{"mem:stuff", "mem", "stuff", ""},
 		{tmpDir, "nbs", tmpDir, "nbs:" + tmpDir},
 		{"nbs:" + tmpDir, "nbs", tmpDir, ""},
 		{"http://server.com/john/doe?access_token=jane", "http", "//server.com/john/doe?access_token=jane", ""},
@@ -238,6 +436,12 @@ func TestForDatabase(t *testing.T) {
 		{"http://::ffff::1e::9a", "http", "//::ffff::1e::9a", ""},
 		{"aws://table:bucket/db", "aws", "//table:bucket/db", ""},
 		{"aws://table/db", "aws", "//table/db", ""},
+		{"s3://bucket/db", "s3", "//bucket/db", ""},
+		{"s3://bucket", "s3", "//bucket", ""},
+		{"gs://bucket/db", "gs", "//bucket/db", ""},
+		{"gs://bucket", "gs", "//bucket", ""},
+		{"ipfs://self", "ipfs", "//self", ""},
+		{"ipfs://k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8", "ipfs", "//k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8", ""},
 	}
 
 	for _, tc := range testCases {
@@ -303,6 +507,7 @@ func TestForDataset(t *testing.T) {
 		spec, protocol, databaseName, datasetName, canonicalSpecIfAny string
 	}{
 		{"http://localhost:8000::ds1", "http", "//localhost:8000", "ds1", ""},
+		{"mem:db/one::ds", "mem", "db/one", "ds", ""},
 		{"http://localhost:8000/john/doe/::ds2", "http", "//localhost:8000/john/doe/", "ds2", ""},
 		{"https://local.attic.io/john/doe::ds3", "https", "//local.attic.io/john/doe", "ds3", ""},
 		{"http://local.attic.io/john/doe::ds1", "http", "//local.attic.io/john/doe", "ds1", ""},
@@ -318,6 +523,9 @@ func TestForDataset(t *testing.T) {
 		{"http://::ffff::1e::9a::foo", "http", "//::ffff::1e::9a", "foo", ""},
 		{"aws://table:bucket/db::ds", "aws", "//table:bucket/db", "ds", ""},
 		{"aws://table/db::ds", "aws", "//table/db", "ds", ""},
+		{"s3://bucket/db::ds", "s3", "//bucket/db", "ds", ""},
+		{"gs://bucket/db::ds", "gs", "//bucket/db", "ds", ""},
+		{"ipfs://self::ds", "ipfs", "//self", "ds", ""},
 	}
 
 	for _, tc := range testCases {
@@ -448,6 +656,30 @@ func TestPinDatasetSpec(t *testing.T) {
 	assert.Equal(types.Number(43), unpinned.GetDataset().HeadValue())
 }
 
+func TestPinHistoricalPathSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	unpinned, err := ForPath("mem::foo~1.value")
+	assert.NoError(err)
+	defer unpinned.Close()
+
+	db := unpinned.GetDatabase()
+	ds, _ := db.CommitValue(db.GetDataset("foo"), types.Number(42))
+	parent := ds.Head()
+	db.CommitValue(ds, types.Number(43))
+
+	pinned, ok := unpinned.Pin()
+	assert.True(ok)
+	defer pinned.Close()
+
+	assert.Equal(parent.Hash(), pinned.Path.Hash)
+	assert.Equal(fmt.Sprintf("mem::#%s.value", parent.Hash().String()), pinned.String())
+	assert.Equal(types.Number(42), pinned.GetValue())
+
+	_, err = ForDataset("mem::foo~1")
+	assert.Error(err)
+}
+
 func TestAlreadyPinnedPathSpec(t *testing.T) {
 	assert := assert.New(t)
 
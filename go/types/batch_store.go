@@ -12,6 +12,7 @@ import (
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
 )
 
 // BatchStore provides an interface similar to chunks.ChunkStore, but batch-
@@ -76,8 +77,8 @@ func (bsa *BatchStoreAdaptor) SchedulePut(c chunks.Chunk) {
 
 func (bsa *BatchStoreAdaptor) expectVersion() {
 	dataVersion := bsa.cs.Version()
-	if constants.NomsVersion != dataVersion {
-		d.Panic("SDK version %s incompatible with data of version %s", constants.NomsVersion, dataVersion)
+	if constants.DataVersion() != dataVersion {
+		d.PanicIfError(nomserrors.Errorf(nomserrors.ErrVersionMismatch, "SDK version %s incompatible with data of version %s", constants.DataVersion(), dataVersion))
 	}
 }
 
@@ -249,6 +249,7 @@ func readBlobsP(vrw ValueReadWriter, rs ...io.Reader) Blob {
 	}
 
 	blobs := make([]Blob, len(rs))
+	errs := make([]error, len(rs))
 
 	wg := &sync.WaitGroup{}
 	wg.Add(len(rs))
@@ -256,12 +257,15 @@ func readBlobsP(vrw ValueReadWriter, rs ...io.Reader) Blob {
 	for i, r := range rs {
 		i2, r2 := i, r
 		go func() {
-			blobs[i2] = readBlob(r2, vrw)
-			wg.Done()
+			defer wg.Done()
+			errs[i2] = d.Try(func() { blobs[i2] = readBlob(r2, vrw) })
 		}()
 	}
 
 	wg.Wait()
+	for _, err := range errs {
+		d.PanicIfError(err)
+	}
 
 	b := blobs[0]
 	for i := 1; i < len(blobs); i++ {
@@ -316,6 +320,9 @@ func readBlob(r io.Reader, vrw ValueReadWriter) Blob {
 		offset = 0
 	}
 
+	// An error reading r is panicked with once the chunks read so far are
+	// done, so that the caller can recover it.
+	var readErr error
 	go func() {
 		readBuff := [8192]byte{}
 		for {
@@ -328,9 +335,8 @@ func readBlob(r io.Reader, vrw ValueReadWriter) Blob {
 			}
 			if err != nil {
 				if err != io.EOF {
-					panic(err)
-				}
-				if offset > 0 {
+					readErr = err
+				} else if offset > 0 {
 					makeChunk()
 				}
 				close(mtChan)
@@ -346,6 +352,7 @@ func readBlob(r io.Reader, vrw ValueReadWriter) Blob {
 		}
 		sc.parent.Append(mt)
 	}
+	d.PanicIfError(readErr)
 
 	return newBlob(sc.Done())
 }
@@ -57,6 +57,7 @@ func DecodeValue(c chunks.Chunk, vr ValueReader) Value {
 type nomsReader interface {
 	pos() uint32
 	readBytes() []byte
+	skipBytes()
 	readUint8() uint8
 	readCount() uint64
 	readNumber() Number
@@ -93,6 +94,12 @@ func (b *binaryNomsReader) readBytes() []byte {
 	return buff
 }
 
+// skipBytes skips over the bytes, or string, readBytes or readString would
+// read.
+func (b *binaryNomsReader) skipBytes() {
+	b.offset += uint32(b.readCount())
+}
+
 func (b *binaryNomsReader) readUint8() uint8 {
 	v := uint8(b.buff[b.offset])
 	b.offset++
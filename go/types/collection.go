@@ -10,3 +10,11 @@ type Collection interface {
 	Empty() bool
 	sequence() sequence
 }
+
+// IsChunked returns true if the chunk of c is the root of a prolly tree,
+// which refers to the chunks of its subtrees, rather than holding all of the
+// elements of c itself.
+func IsChunked(c Collection) bool {
+	_, ok := c.sequence().(metaSequence)
+	return ok
+}
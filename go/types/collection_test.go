@@ -46,6 +46,10 @@ func (suite *collectionTestSuite) TestChunkCountAndType() {
 	}
 }
 
+func (suite *collectionTestSuite) TestIsChunked() {
+	suite.Equal(suite.expectChunkCount > 0, IsChunked(suite.col))
+}
+
 func (suite *collectionTestSuite) TestRoundTripAndValidate() {
 	vs := NewTestValueStore()
 	r := vs.WriteValue(suite.col)
@@ -59,6 +59,10 @@ func (r *nomsTestReader) readBytes() []byte {
 	return r.read().([]byte)
 }
 
+func (r *nomsTestReader) skipBytes() {
+	r.read()
+}
+
 func (r *nomsTestReader) readHash() hash.Hash {
 	return hash.Parse(r.readString())
 }
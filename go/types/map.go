@@ -23,8 +23,8 @@ func newMap(seq orderedSequence) Map {
 
 func mapHashValueBytes(item sequenceItem, rv *rollingValueHasher) {
 	entry := item.(mapEntry)
-	hashValueBytes(entry.key, rv)
-	hashValueBytes(entry.value, rv)
+	rv.enc.writeMapItem(box(entry.key))
+	rv.enc.writeMapItem(box(entry.value))
 }
 
 func NewMap(kv ...Value) Map {
@@ -242,6 +242,23 @@ func (m Map) Get(key Value) Value {
 	return v
 }
 
+// GetMany returns the values of keys in m, in the same order, with nil for
+// the keys which aren't in m. Unlike calling Get for each key, the chunks of
+// each level of m which any of the keys are in are read in a single batch.
+func (m Map) GetMany(keys []Value) []Value {
+	okeys := make([]orderedKey, len(keys))
+	for i, k := range keys {
+		okeys[i] = newOrderedKey(k)
+	}
+	values := make([]Value, len(keys))
+	for i, item := range getManyItems(m.seq, okeys) {
+		if item != nil {
+			values[i] = item.(mapEntry).value
+		}
+	}
+	return values
+}
+
 type mapIterCallback func(key, value Value) (stop bool)
 
 func (m Map) Iter(cb mapIterCallback) {
@@ -340,7 +357,7 @@ func makeMapLeafChunkFn(vr ValueReader) makeChunkFn {
 			mapData[i] = v.(mapEntry)
 		}
 
-		m := newMap(newMapLeafSequence(vr, mapData...))
+		m := newMap(newMapLeafSequence(vr, boxEntries(mapData)...))
 		var key orderedKey
 		if len(mapData) > 0 {
 			key = newOrderedKey(mapData[len(mapData)-1].key)
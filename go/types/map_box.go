@@ -0,0 +1,154 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"encoding/binary"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// The keys and values of a Map which are larger than maxInlineMapItemSize are
+// boxed: rather than being inlined in the chunks of the Map - the leaf they're
+// in and, for the keys ordered by value, its parents - they're written to
+// chunks of their own, and encoded as a Ref to those, following boxKind.
+// Inlining them would bloat those chunks and throw off the balance of the
+// tree.
+//
+// Boxing is transparent, the entries of a Map are unboxed as they're read,
+// and boxed keys keep being ordered as if they were inlined, by value or by
+// hash. Whether an item is boxed only depends on the item, so the chunking of
+// a Map stays canonical.
+
+// maxInlineMapItemSize is the size in bytes of the encoding of the largest
+// key or value of a Map which is inlined, the average size of a chunk.
+var maxInlineMapItemSize = uint64(defaultChunkPattern + 1)
+
+// boxKind is written in place of the kind of a boxed key or value of a Map.
+// It isn't the kind of any Value.
+const boxKind NomsKind = 0xff
+
+// mapBox stands in for a boxed key or value of a Map, in the leaves of the
+// Map. Its Hash and type are those of the boxed Value. The Value of a box
+// made by box is kept in it until it's written, and that of a decoded box is
+// read when it's unboxed.
+type mapBox struct {
+	Ref
+	v Value
+}
+
+func (b mapBox) Hash() hash.Hash {
+	return b.TargetHash()
+}
+
+func (b mapBox) Equals(other Value) bool {
+	return b.Hash() == other.Hash()
+}
+
+func (b mapBox) typeOf() *Type {
+	return b.TargetType()
+}
+
+// box returns v, a key or value of a Map, or a mapBox of it if it's boxed.
+// Whether it is is worked out once, as the entries of a leaf are put in it,
+// so that the leaf isn't sized again as it's walked or encoded.
+func box(v Value) Value {
+	switch v := v.(type) {
+	case mapBox, Bool, Number, Ref:
+		return v
+	case String:
+		if uint64(len(v)) <= maxInlineMapItemSize {
+			return v
+		}
+	default:
+		if EncodedSize(v) <= maxInlineMapItemSize {
+			return v
+		}
+	}
+	return mapBox{NewRef(v), v}
+}
+
+// boxEntries returns entries with their keys and values boxed, see box.
+func boxEntries(entries []mapEntry) []mapEntry {
+	boxed := make([]mapEntry, len(entries))
+	for i, e := range entries {
+		boxed[i] = mapEntry{box(e.key), box(e.value)}
+	}
+	return boxed
+}
+
+// unbox returns the Value which v stands in for, reading it from vr if it's a
+// decoded mapBox.
+func unbox(v Value, vr ValueReader) Value {
+	b, ok := v.(mapBox)
+	if !ok {
+		return v
+	}
+	if b.v != nil {
+		return b.v
+	}
+	d.PanicIfTrue(vr == nil)
+	v = vr.ReadValue(b.TargetHash())
+	d.PanicIfTrue(v == nil)
+	return v
+}
+
+// walkMapItemRefs calls cb with the Refs of v, a key or value of a Map in a
+// leaf, as it is encoded.
+func walkMapItemRefs(v Value, cb RefCallback) {
+	if b, ok := v.(mapBox); ok {
+		cb(b.Ref)
+		return
+	}
+	v.WalkRefs(cb)
+}
+
+// EncodedSize returns the size in bytes of the encoding of v, without
+// encoding it. It walks all of v, so it's best called once per value.
+func EncodedSize(v Value) uint64 {
+	sc := &sizeCounter{}
+	newValueEncoder(sc, nil, false).writeValue(v)
+	return sc.size
+}
+
+// sizeCounter is a nomsWriter which only counts the bytes written to it.
+type sizeCounter struct {
+	size uint64
+}
+
+func (sc *sizeCounter) writeBytes(v []byte) {
+	sc.writeCount(uint64(len(v)))
+	sc.size += uint64(len(v))
+}
+
+func (sc *sizeCounter) writeUint8(v uint8) {
+	sc.size++
+}
+
+func (sc *sizeCounter) writeCount(v uint64) {
+	buff := [binary.MaxVarintLen64]byte{}
+	sc.size += uint64(binary.PutUvarint(buff[:], v))
+}
+
+func (sc *sizeCounter) writeNumber(v Number) {
+	buff := [binary.MaxVarintLen64]byte{}
+	i, exp := float64ToIntExp(float64(v))
+	sc.size += uint64(binary.PutVarint(buff[:], i))
+	sc.size += uint64(binary.PutVarint(buff[:], int64(exp)))
+}
+
+func (sc *sizeCounter) writeBool(b bool) {
+	sc.size++
+}
+
+func (sc *sizeCounter) writeString(v string) {
+	sc.writeCount(uint64(len(v)))
+	sc.size += uint64(len(v))
+}
+
+func (sc *sizeCounter) writeHash(h hash.Hash) {
+	sc.size += hash.ByteLen
+}
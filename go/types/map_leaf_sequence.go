@@ -40,13 +40,14 @@ func newMapLeafSequence(vr ValueReader, data ...mapEntry) orderedSequence {
 // sequence interface
 
 func (ml mapLeafSequence) getItem(idx int) sequenceItem {
-	return ml.data[idx]
+	entry := ml.data[idx]
+	return mapEntry{unbox(entry.key, ml.vr), unbox(entry.value, ml.vr)}
 }
 
 func (ml mapLeafSequence) WalkRefs(cb RefCallback) {
 	for _, entry := range ml.data {
-		entry.key.WalkRefs(cb)
-		entry.value.WalkRefs(cb)
+		walkMapItemRefs(entry.key, cb)
+		walkMapItemRefs(entry.value, cb)
 	}
 }
 
@@ -72,7 +73,7 @@ func (ml mapLeafSequence) typeOf() *Type {
 // orderedSequence interface
 
 func (ml mapLeafSequence) getKey(idx int) orderedKey {
-	return newOrderedKey(ml.data[idx].key)
+	return newOrderedKey(unbox(ml.data[idx].key, ml.vr))
 }
 
 // Collection interface
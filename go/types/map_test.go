@@ -9,9 +9,12 @@ import (
 	"fmt"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/testify/assert"
 	"github.com/attic-labs/testify/suite"
 )
@@ -1243,6 +1246,157 @@ func TestMapRemoveLastWhenNotLoaded(t *testing.T) {
 	}
 }
 
+type getCountingStore struct {
+	*chunks.TestStore
+	gets, getManys int
+}
+
+func (s *getCountingStore) Get(h hash.Hash) chunks.Chunk {
+	s.gets++
+	return s.TestStore.Get(h)
+}
+
+func (s *getCountingStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	s.getManys++
+	s.TestStore.GetMany(hashes, foundChunks)
+}
+
+func TestMapGetMany(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	cs := &getCountingStore{TestStore: chunks.NewTestStore()}
+	tm := getTestNativeOrderMap(16)
+	vs := newLocalValueStore(cs)
+	h := vs.WriteValue(tm.toMap()).TargetHash()
+	vs.Flush(h)
+
+	m := newLocalValueStore(cs).ReadValue(h).(Map)
+	depth := newCursorAt(m.seq, emptyKey, false, false, false).depth()
+	assert.True(depth > 2)
+
+	keys := []Value{tm.knownBadKey}
+	for i := len(tm.entries) - 1; i >= 0; i -= 3 {
+		keys = append(keys, tm.entries[i].key)
+	}
+	m = newLocalValueStore(cs).ReadValue(h).(Map)
+	cs.gets, cs.getManys = 0, 0
+	values := m.GetMany(keys)
+	assert.Equal(0, cs.gets)
+	assert.Equal(depth-1, cs.getManys)
+
+	assert.Len(values, len(keys))
+	assert.Nil(values[0])
+	for i, k := range keys[1:] {
+		assert.True(m.Get(k).Equals(values[i+1]))
+	}
+
+	assert.Equal([]Value{nil}, NewMap().GetMany([]Value{Number(1)}))
+	assert.Equal([]Value{}, m.GetMany([]Value{}))
+}
+
+func TestMapLargeKeysAndValues(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+	defer func(size uint64) { maxInlineMapItemSize = size }(maxInlineMapItemSize)
+	maxInlineMapItemSize = 512
+
+	large := func(prefix string, i int) string {
+		return fmt.Sprintf("%s%04d", prefix, i) + strings.Repeat("x", 1000)
+	}
+	kvs := []Value{}
+	for i := 0; i < 200; i++ {
+		var k, v Value = String(fmt.Sprintf("k%04d", i)), Number(i)
+		switch i % 10 {
+		case 1:
+			k = String(large("k", i))
+		case 2:
+			v = String(large("v", i))
+		case 3:
+			k = NewStruct("S", StructData{"s": String(large("k", i))})
+		case 4:
+			k, v = String(large("k", i)), NewStruct("S", StructData{"s": String(large("v", i))})
+		}
+		kvs = append(kvs, k, v)
+	}
+	m := NewMap(kvs...)
+	assert.True(IsChunked(m))
+
+	cs := chunks.NewTestStore()
+	vs := newLocalValueStore(cs)
+	h := vs.WriteValue(m).TargetHash()
+	vs.Flush(h)
+	reloaded := newLocalValueStore(cs).ReadValue(h).(Map)
+
+	// Large items are written to chunks of their own, and not inlined in the
+	// chunks of the Map.
+	assertNoneInlined := func(r Ref) {
+		data := cs.Get(r.TargetHash()).Data()
+		for i := 0; i < 200; i++ {
+			assert.False(bytes.Contains(data, []byte(large("k", i))))
+			assert.False(bytes.Contains(data, []byte(large("v", i))))
+		}
+	}
+	var walk func(col Collection)
+	walk = func(col Collection) {
+		col.WalkRefs(func(r Ref) {
+			if r.TargetType().TargetKind() == MapKind {
+				assertNoneInlined(r)
+				walk(r.TargetValue(vs).(Collection))
+			}
+		})
+	}
+	assertNoneInlined(NewRef(m))
+	walk(m)
+	for i := 1; i < 200; i += 10 {
+		k := kvs[2*i]
+		assert.True(k.Equals(vs.ReadValue(k.Hash())))
+	}
+
+	// Boxing is transparent, and the order of the keys is kept.
+	assert.True(m.Equals(reloaded))
+	assert.True(TypeOf(m).Equals(TypeOf(reloaded)))
+	assert.Equal(m.Len(), reloaded.Len())
+	i := uint64(0)
+	reloaded.IterAll(func(k, v Value) {
+		ek, ev := m.At(i)
+		assert.True(ek.Equals(k))
+		assert.True(ev.Equals(v))
+		assert.Equal(ek.Kind(), k.Kind())
+		assert.Equal(ev.Kind(), v.Kind())
+		i++
+	})
+	for i := 0; i < len(kvs); i += 2 {
+		assert.True(reloaded.Has(kvs[i]))
+		assert.True(kvs[i+1].Equals(reloaded.Get(kvs[i])))
+	}
+	assert.False(reloaded.Has(String(large("k", 200))))
+
+	// Editing a reloaded Map is the same as editing it before it's written.
+	edited := reloaded.Set(String(large("k", 200)), String(large("v", 200))).Remove(kvs[2])
+	expected := m.Set(String(large("k", 200)), String(large("v", 200))).Remove(kvs[2])
+	assert.True(expected.Equals(edited))
+
+	changes := make(chan ValueChanged)
+	go func() {
+		edited.Diff(reloaded, changes, nil)
+		close(changes)
+	}()
+	diff := []ValueChanged{}
+	for c := range changes {
+		diff = append(diff, c)
+	}
+	if assert.Len(diff, 2) {
+		assert.True(kvs[2].Equals(diff[0].V))
+		assert.Equal(StringKind, diff[0].V.Kind())
+		assert.True(String(large("k", 200)).Equals(diff[1].V))
+	}
+}
+
 func TestMapIterFrom(t *testing.T) {
 	assert := assert.New(t)
 
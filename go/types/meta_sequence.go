@@ -7,6 +7,7 @@ package types
 import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomserrors"
 )
 
 const (
@@ -34,7 +35,11 @@ func (mt metaTuple) getChildSequence(vr ValueReader) sequence {
 	if mt.child != nil {
 		return mt.child.sequence()
 	}
-	return mt.ref.TargetValue(vr).(Collection).sequence()
+	v := mt.ref.TargetValue(vr)
+	if v == nil {
+		d.PanicIfError(nomserrors.Errorf(nomserrors.ErrChunkNotFound, "Missing chunk %s", mt.ref.TargetHash()))
+	}
+	return v.(Collection).sequence()
 }
 
 // orderedKey is a key in a Prolly Tree level, which is a metaTuple in a metaSequence, or a value in a leaf sequence.
@@ -93,7 +98,11 @@ func (ms metaSequence) data() []metaTuple {
 }
 
 func (ms metaSequence) getKey(idx int) orderedKey {
-	return ms.tuples[idx].key
+	key := ms.tuples[idx].key
+	if b, ok := key.v.(mapBox); ok {
+		return newOrderedKey(unbox(b, ms.vr))
+	}
+	return key
 }
 
 func (ms metaSequence) cumulativeNumberOfLeaves(idx int) uint64 {
@@ -127,6 +136,11 @@ func (ms metaSequence) valueReader() ValueReader {
 func (ms metaSequence) WalkRefs(cb RefCallback) {
 	for _, tuple := range ms.tuples {
 		cb(tuple.ref)
+		if ms.kind == MapKind && tuple.key.isOrderedByValue {
+			if b, ok := box(tuple.key.v).(mapBox); ok {
+				cb(b.Ref)
+			}
+		}
 	}
 }
 
@@ -208,13 +222,19 @@ func (ms metaSequence) getChildren(start, end uint64) (seqs []sequence) {
 	d.Chk.True(end <= uint64(len(ms.tuples)))
 	d.Chk.True(start <= end)
 
-	seqs = make([]sequence, end-start)
+	return readChildSequences(ms.tuples[start:end], ms.vr)
+}
+
+// readChildSequences returns the child sequences of tuples, fetching those
+// which are committed in a single batch from vr. The tuples may be from
+// different metaSequences.
+func readChildSequences(tuples []metaTuple, vr ValueReader) (seqs []sequence) {
+	seqs = make([]sequence, len(tuples))
 	hs := make(hash.HashSet, len(seqs))
 
-	for i := start; i < end; i++ {
-		mt := ms.tuples[i]
+	for i, mt := range tuples {
 		if mt.child != nil {
-			seqs[i-start] = mt.child.sequence()
+			seqs[i] = mt.child.sequence()
 		} else {
 			hs[mt.ref.TargetHash()] = struct{}{}
 		}
@@ -227,7 +247,7 @@ func (ms metaSequence) getChildren(start, end uint64) (seqs []sequence) {
 	// Fetch committed child sequences in a single batch
 	valueChan := make(chan Value, len(hs))
 	go func() {
-		ms.vr.ReadManyValues(hs, valueChan)
+		vr.ReadManyValues(hs, valueChan)
 		close(valueChan)
 	}()
 	children := make(map[hash.Hash]sequence, len(hs))
@@ -235,15 +255,16 @@ func (ms metaSequence) getChildren(start, end uint64) (seqs []sequence) {
 		children[value.Hash()] = value.(Collection).sequence()
 	}
 
-	for i := start; i < end; i++ {
-		mt := ms.tuples[i]
+	for i, mt := range tuples {
 		if mt.child != nil {
 			continue
 		}
 
 		childSeq := children[mt.ref.TargetHash()]
-		d.Chk.NotNil(childSeq)
-		seqs[i-start] = childSeq
+		if childSeq == nil {
+			d.PanicIfError(nomserrors.Errorf(nomserrors.ErrChunkNotFound, "Missing chunk %s", mt.ref.TargetHash()))
+		}
+		seqs[i] = childSeq
 	}
 
 	return
@@ -275,7 +296,11 @@ func metaHashValueBytes(item sequenceItem, rv *rollingValueHasher) {
 	}
 
 	hashValueBytes(mt.ref, rv)
-	hashValueBytes(v, rv)
+	if mt.ref.TargetType().TargetKind() == MapKind {
+		rv.enc.writeMapItem(box(v))
+	} else {
+		hashValueBytes(v, rv)
+	}
 }
 
 type emptySequence struct{}
@@ -81,6 +81,63 @@ func getCurrentKey(cur *sequenceCursor) orderedKey {
 	return seq.getKey(cur.idx)
 }
 
+// getManyItems returns the leaf items of seq at each of keys, in the same
+// order, with nil for the keys which aren't in seq. Rather than seeking a
+// cursor to each key, seq is descended a level at a time, fetching the
+// children of each level which any of the keys are in with a single
+// ReadManyValues.
+func getManyItems(seq orderedSequence, keys []orderedKey) []sequenceItem {
+	type lookup struct {
+		seq  orderedSequence
+		idxs []int // into keys
+	}
+
+	items := make([]sequenceItem, len(keys))
+	all := make([]int, len(keys))
+	for i := range all {
+		all[i] = i
+	}
+	vr := seq.valueReader()
+	level := []lookup{{seq, all}}
+	for len(level) > 0 {
+		tuples := []metaTuple{}
+		childIdxs := [][]int{}
+		for _, l := range level {
+			ms, isMeta := l.seq.(metaSequence)
+			byTuple := map[int]int{} // tuple index to index into tuples
+			for _, ki := range l.idxs {
+				// Find smallest idx in seq where key(idx) >= key, as seekTo does.
+				idx := sort.Search(l.seq.seqLen(), func(i int) bool {
+					return !l.seq.getKey(i).Less(keys[ki])
+				})
+				if idx == l.seq.seqLen() {
+					continue
+				}
+				if !isMeta {
+					if !keys[ki].Less(l.seq.getKey(idx)) {
+						items[ki] = l.seq.getItem(idx)
+					}
+					continue
+				}
+				ti, ok := byTuple[idx]
+				if !ok {
+					ti = len(tuples)
+					byTuple[idx] = ti
+					tuples = append(tuples, ms.tuples[idx])
+					childIdxs = append(childIdxs, nil)
+				}
+				childIdxs[ti] = append(childIdxs[ti], ki)
+			}
+		}
+
+		level = make([]lookup, len(tuples))
+		for i, child := range readChildSequences(tuples, vr) {
+			level[i] = lookup{child.(orderedSequence), childIdxs[i]}
+		}
+	}
+	return items
+}
+
 // If |vw| is not nil, chunks will be eagerly written as they're created. Otherwise they are
 // written when the root is written.
 func newOrderedMetaSequenceChunkFn(kind NomsKind, vr ValueReader) makeChunkFn {
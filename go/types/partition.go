@@ -0,0 +1,127 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+// Partition is the range [Start, End) of the indexes of the elements of a
+// List or the entries of a Map, which can be scanned independently of the
+// other Partitions of the collection, e.g. in parallel.
+type Partition struct {
+	Start, End uint64
+}
+
+// Len returns the number of elements in p.
+func (p Partition) Len() uint64 {
+	return p.End - p.Start
+}
+
+// Partitions splits l into at most n Partitions of about equal length, which
+// together cover l. See PartitionIterator.
+func (l List) Partitions(n int) []Partition {
+	return partitions(l.seq, n)
+}
+
+// PartitionIterator returns an iterator of the elements of l in p.
+func (l List) PartitionIterator(p Partition) *ListPartitionIterator {
+	return &ListPartitionIterator{l.IteratorAt(p.Start), p.Len()}
+}
+
+// Partitions splits m into at most n Partitions of about equal length, which
+// together cover m. See PartitionIterator.
+func (m Map) Partitions(n int) []Partition {
+	return partitions(m.seq, n)
+}
+
+// PartitionIterator returns an iterator of the entries of m in p.
+func (m Map) PartitionIterator(p Partition) MapIterator {
+	return &mapPartitionIterator{m.IteratorAt(p.Start), p.Len()}
+}
+
+// ListPartitionIterator iterates through the elements of a Partition of a
+// List.
+type ListPartitionIterator struct {
+	it        ListIterator
+	remaining uint64
+}
+
+// Next returns the next element of the Partition, or nil after the last one.
+func (li *ListPartitionIterator) Next() Value {
+	if li.remaining == 0 {
+		return nil
+	}
+	li.remaining--
+	return li.it.Next()
+}
+
+type mapPartitionIterator struct {
+	it        MapIterator
+	remaining uint64
+}
+
+func (mi *mapPartitionIterator) Next() (k, v Value) {
+	if mi.remaining == 0 {
+		return nil, nil
+	}
+	mi.remaining--
+	return mi.it.Next()
+}
+
+// subtreesPerPartition is how many subtrees partitions looks for per
+// Partition, so that subtrees of uneven sizes can be grouped evenly.
+const subtreesPerPartition = 4
+
+// partitions splits seq at the boundaries of its subtrees, from the
+// shallowest level of the prolly tree which has subtreesPerPartition of them
+// per Partition, into at most n Partitions of about numLeaves/n elements. Only
+// the chunks above that level are read.
+func partitions(seq sequence, n int) []Partition {
+	total := seq.numLeaves()
+	if total == 0 || n < 1 {
+		return []Partition{}
+	}
+
+	// The sizes of the subtrees of the sequences of a level, descending until
+	// there are enough subtrees or the level is of leaves.
+	var sizes []uint64
+	level := []sequence{seq}
+	for {
+		sizes = []uint64{}
+		leaves := false
+		for _, s := range level {
+			if ms, ok := s.(metaSequence); ok {
+				for _, mt := range ms.tuples {
+					sizes = append(sizes, mt.numLeaves)
+				}
+			} else {
+				leaves = true
+				for i := 0; i < s.seqLen(); i++ {
+					sizes = append(sizes, 1)
+				}
+			}
+		}
+		if leaves || len(sizes) >= n*subtreesPerPartition {
+			break
+		}
+		children := []sequence{}
+		for _, s := range level {
+			ms := s.(metaSequence)
+			for i := range ms.tuples {
+				children = append(children, ms.getChildSequence(i))
+			}
+		}
+		level = children
+	}
+
+	// Cut after the subtree which reaches each multiple of total/n.
+	parts := make([]Partition, 0, n)
+	start, end := uint64(0), uint64(0)
+	for _, size := range sizes {
+		end += size
+		if end*uint64(n) >= total*uint64(len(parts)+1) {
+			parts = append(parts, Partition{start, end})
+			start = end
+		}
+	}
+	return parts
+}
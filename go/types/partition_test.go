@@ -0,0 +1,101 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func assertPartitionsCover(assert *assert.Assertions, parts []Partition, n int, total uint64) {
+	assert.True(len(parts) <= n)
+	end := uint64(0)
+	for _, p := range parts {
+		assert.Equal(end, p.Start)
+		assert.True(p.Len() > 0)
+		end = p.End
+	}
+	assert.Equal(total, end)
+}
+
+func TestListPartitions(t *testing.T) {
+	assert := assert.New(t)
+	vs := NewTestValueStore()
+	defer vs.Close()
+
+	values := generateNumbersAsValues(20000)
+	l := vs.ReadValue(vs.WriteValue(NewList(values...)).TargetHash()).(List)
+	assert.True(isMetaSequence(l.seq))
+
+	for _, n := range []int{1, 2, 3, 8, 100} {
+		parts := l.Partitions(n)
+		assertPartitionsCover(assert, parts, n, l.Len())
+		if n > 1 {
+			assert.True(len(parts) > 1)
+		}
+	}
+
+	// Scan the partitions in parallel.
+	parts := l.Partitions(4)
+	sums := make([]float64, len(parts))
+	wg := sync.WaitGroup{}
+	for i, p := range parts {
+		wg.Add(1)
+		go func(i int, p Partition) {
+			defer wg.Done()
+			it := l.PartitionIterator(p)
+			for v := it.Next(); v != nil; v = it.Next() {
+				sums[i] += float64(v.(Number))
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	sum := 0.0
+	for _, s := range sums {
+		sum += s
+	}
+	assert.Equal(float64(20000*19999/2), sum)
+}
+
+func TestListPartitionsSmall(t *testing.T) {
+	assert := assert.New(t)
+	l := NewList(Number(1), Number(2), Number(3))
+	parts := l.Partitions(8)
+	assertPartitionsCover(assert, parts, 8, 3)
+	assert.Len(parts, 3)
+
+	it := l.PartitionIterator(parts[1])
+	assert.Equal(Number(2), it.Next())
+	assert.Nil(it.Next())
+
+	assert.Empty(NewList().Partitions(4))
+}
+
+func TestMapPartitions(t *testing.T) {
+	assert := assert.New(t)
+	vs := NewTestValueStore()
+	defer vs.Close()
+
+	kvs := []Value{}
+	for i := 0; i < 10000; i++ {
+		kvs = append(kvs, Number(i), String("v"))
+	}
+	m := vs.ReadValue(vs.WriteValue(NewMap(kvs...)).TargetHash()).(Map)
+	parts := m.Partitions(5)
+	assertPartitionsCover(assert, parts, 5, m.Len())
+
+	next := Number(0)
+	for _, p := range parts {
+		it := m.PartitionIterator(p)
+		for k, v := it.Next(); k != nil; k, v = it.Next() {
+			assert.Equal(next, k)
+			assert.Equal(String("v"), v)
+			next++
+		}
+	}
+	assert.Equal(Number(10000), next)
+}
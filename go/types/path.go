@@ -152,6 +152,97 @@ func (p Path) Resolve(v Value) (resolved Value) {
 	return
 }
 
+// ResolvePaths resolves each of paths in v, returning the resolved values in
+// the same order, with nil for the paths which don't resolve. The paths are
+// resolved together a part at a time, so that the lookups of all of the paths
+// which index into the same Map, or Set by hash, are batched as by
+// Map.GetMany.
+func ResolvePaths(v Value, paths []Path) []Value {
+	type batch struct {
+		seq  orderedSequence
+		keys []orderedKey
+		idxs []int // into paths
+	}
+
+	resolved := make([]Value, len(paths))
+	for i := range resolved {
+		resolved[i] = v
+	}
+	for depth := 0; ; depth++ {
+		batches := map[hash.Hash]*batch{}
+		order := []hash.Hash{}
+		more := false
+		for i, p := range paths {
+			if resolved[i] == nil || depth >= len(p) {
+				continue
+			}
+			more = true
+			seq, key, ok := orderedLookup(resolved[i], p[depth])
+			if !ok {
+				resolved[i] = p[depth].Resolve(resolved[i])
+				continue
+			}
+			h := resolved[i].Hash()
+			b, ok := batches[h]
+			if !ok {
+				b = &batch{seq: seq}
+				batches[h] = b
+				order = append(order, h)
+			}
+			b.keys = append(b.keys, key)
+			b.idxs = append(b.idxs, i)
+		}
+		if !more {
+			return resolved
+		}
+
+		for _, h := range order {
+			b := batches[h]
+			for j, item := range getManyItems(b.seq, b.keys) {
+				i := b.idxs[j]
+				switch item := item.(type) {
+				case nil:
+					resolved[i] = nil
+				case mapEntry:
+					intoKey := false
+					switch part := paths[i][depth].(type) {
+					case IndexPath:
+						intoKey = part.IntoKey
+					case HashIndexPath:
+						intoKey = part.IntoKey
+					}
+					if intoKey {
+						resolved[i] = item.key
+					} else {
+						resolved[i] = item.value
+					}
+				case Value:
+					resolved[i] = item
+				}
+			}
+		}
+	}
+}
+
+// orderedLookup returns the sequence and key which resolving part in v looks
+// up, if it's a lookup of a key of a Map or a hash in a Set.
+func orderedLookup(v Value, part PathPart) (seq orderedSequence, key orderedKey, ok bool) {
+	switch part := part.(type) {
+	case IndexPath:
+		if m, isMap := v.(Map); isMap {
+			return m.seq, newOrderedKey(part.Index), true
+		}
+	case HashIndexPath:
+		switch v := v.(type) {
+		case Map:
+			return v.seq, orderedKeyFromHash(part.Hash), true
+		case Set:
+			return v.seq, orderedKeyFromHash(part.Hash), true
+		}
+	}
+	return
+}
+
 func (p Path) Equals(o Path) bool {
 	if len(p) != len(o) {
 		return false
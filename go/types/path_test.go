@@ -197,6 +197,55 @@ func TestPathMulti(t *testing.T) {
 	assertResolvesTo(assert, String("car"), s, `.foo[1]@at(-1)@key@at(-1)`)
 }
 
+func TestResolvePaths(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMap(
+		String("a"), String("foo"),
+		String("b"), NewSet(Number(1), Number(2)),
+		Number(1), String("one"),
+	)
+	s := NewStruct("", StructData{
+		"map":  m,
+		"list": NewList(m, String("bar")),
+	})
+
+	strs := []string{
+		`.map["a"]`,
+		`.map["b"]`,
+		`.map["b"]` + hashIdx(Number(2)),
+		`.map["b"]` + hashIdx(Number(3)),
+		`.map[1]`,
+		`.map[1]@key`,
+		`.map["c"]`,
+		`.map["c"]@key`,
+		`.map` + hashIdx(String("a")),
+		`.map` + hashIdx(String("a")) + `@key`,
+		`.map@at(0)`,
+		`.list[0]["a"]`,
+		`.list[1]["a"]`,
+		`.list[2]`,
+		`.notHere["a"]`,
+		`.map`,
+	}
+	paths := make([]Path, len(strs))
+	for i, str := range strs {
+		paths[i] = MustParsePath(str)
+	}
+	resolved := ResolvePaths(s, paths)
+	assert.Len(resolved, len(paths))
+	for i, p := range paths {
+		if expect := p.Resolve(s); expect == nil {
+			assert.Nil(resolved[i], strs[i])
+		} else if assert.NotNil(resolved[i], strs[i]) {
+			assert.True(expect.Equals(resolved[i]), strs[i])
+		}
+	}
+
+	assert.Equal([]Value{s}, ResolvePaths(s, []Path{{}}))
+	assert.Equal([]Value{}, ResolvePaths(s, nil))
+}
+
 func TestPathParseSuccess(t *testing.T) {
 	assert := assert.New(t)
 
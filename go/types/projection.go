@@ -0,0 +1,306 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// Project returns v pruned to the parts of it named by projection, a Type
+// which describes the parts of v to keep:
+//
+//   - A Struct is pruned to the fields of a Struct projection, each pruned by
+//     the type of the field. Fields of the projection which the Struct doesn't
+//     have are left out, so every field of a projection is as if optional. The
+//     name of the Struct is kept.
+//   - The elements of a List or Set are pruned by the element type of a List or
+//     Set projection, and the values of a Map by the value type of a Map
+//     projection. The keys of Maps are kept whole.
+//   - A value is pruned by the member of a Union projection of its kind, and, if
+//     it's a Struct, preferably by the Struct member with its name.
+//   - Any other value, or a value which isn't of the kind of the projection, is
+//     kept whole. In particular, Refs aren't followed, and Value projects a
+//     value to itself.
+//
+// For example, the projection Struct { title: String, tags: Set<String> }
+// prunes a Struct to its title and tags, and List<Struct { title: String }>
+// prunes a List of Structs to their titles.
+//
+// Project reads all of v which is projected, including the chunks of
+// collections in it. ValueReader.ReadValueProjection reads only that of a
+// value, see there.
+func Project(v Value, projection *Type) Value {
+	t := projectionFor(projection, v.Kind(), structName(v))
+	if t == nil || !prunes(t) {
+		return v
+	}
+	switch v := v.(type) {
+	case Struct:
+		desc := t.Desc.(StructDesc)
+		fieldNames, values := []string{}, []Value{}
+		for i, name := range v.fieldNames {
+			if ft, _ := desc.Field(name); ft != nil {
+				fieldNames = append(fieldNames, name)
+				values = append(values, Project(v.values[i], ft))
+			}
+		}
+		return newStruct(v.name, fieldNames, values)
+	case List:
+		et := t.Desc.(CompoundDesc).ElemTypes[0]
+		values := make([]Value, 0, v.Len())
+		v.IterAll(func(ev Value, i uint64) {
+			values = append(values, Project(ev, et))
+		})
+		return NewList(values...)
+	case Set:
+		et := t.Desc.(CompoundDesc).ElemTypes[0]
+		values := make([]Value, 0, v.Len())
+		v.IterAll(func(ev Value) {
+			values = append(values, Project(ev, et))
+		})
+		return NewSet(values...)
+	case Map:
+		vt := t.Desc.(CompoundDesc).ElemTypes[1]
+		kvs := make([]Value, 0, 2*v.Len())
+		v.IterAll(func(k, mv Value) {
+			kvs = append(kvs, k, Project(mv, vt))
+		})
+		return NewMap(kvs...)
+	}
+	panic("not reachable")
+}
+
+// projectionFor returns the type which prunes a value of kind k, with name if
+// it's a Struct, in projection: projection itself, or one of its members if
+// it's a Union. It returns nil if the value is kept whole.
+func projectionFor(projection *Type, k NomsKind, name string) *Type {
+	if projection.TargetKind() != UnionKind {
+		if projection.TargetKind() != k {
+			return nil
+		}
+		return projection
+	}
+	var match *Type
+	for _, t := range projection.Desc.(CompoundDesc).ElemTypes {
+		if t.TargetKind() != k {
+			continue
+		}
+		if k != StructKind || t.Desc.(StructDesc).Name == name {
+			return t
+		}
+		if match == nil {
+			match = t
+		}
+	}
+	return match
+}
+
+// prunes returns whether t prunes any value, that is, whether it has a Struct
+// type in it which isn't the target of a Ref.
+func prunes(t *Type) bool {
+	switch desc := t.Desc.(type) {
+	case StructDesc:
+		return true
+	case CompoundDesc:
+		if desc.Kind() == RefKind {
+			return false
+		}
+		for _, et := range desc.ElemTypes {
+			if prunes(et) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func structName(v Value) string {
+	if s, ok := v.(Struct); ok {
+		return s.name
+	}
+	return ""
+}
+
+// decodeProjection decodes the value encoded in data pruned to projection, as
+// Project does, skipping over the parts of it which aren't projected.
+func decodeProjection(data []byte, vr ValueReader, projection *Type) Value {
+	br := &binaryNomsReader{data, 0}
+	dec := newValueDecoder(br, vr)
+	v := dec.readProjectedValue(projection)
+	d.PanicIfFalse(br.pos() == uint32(len(data)))
+	return v
+}
+
+func (r *valueDecoder) readProjectedValue(projection *Type) Value {
+	if !prunes(projection) {
+		return r.readValue()
+	}
+	k := r.readKind()
+	if k == StructKind {
+		return r.readProjectedStruct(projection)
+	}
+	t := projectionFor(projection, k, "")
+	if t == nil || !prunes(t) {
+		return r.readValueOfKind(k)
+	}
+	if r.readBool() {
+		// The chunks of a chunked collection are pruned as they're read.
+		switch k {
+		case ListKind:
+			return Project(newList(r.readMetaSequence(k)), t)
+		case SetKind:
+			return Project(newSet(r.readMetaSequence(k)), t)
+		case MapKind:
+			return Project(newMap(r.readMetaSequence(k)), t)
+		}
+		panic("not reachable")
+	}
+
+	count := r.readCount()
+	et := t.Desc.(CompoundDesc).ElemTypes
+	switch k {
+	case ListKind:
+		values := make([]Value, count)
+		for i := range values {
+			values[i] = r.readProjectedValue(et[0])
+		}
+		return NewList(values...)
+	case SetKind:
+		values := make([]Value, count)
+		for i := range values {
+			values[i] = r.readProjectedValue(et[0])
+		}
+		return NewSet(values...)
+	case MapKind:
+		kvs := make([]Value, 0, 2*count)
+		for i := uint64(0); i < count; i++ {
+			kvs = append(kvs, r.readValue(), r.readProjectedValue(et[1]))
+		}
+		return NewMap(kvs...)
+	}
+	panic("not reachable")
+}
+
+func (r *valueDecoder) readProjectedStruct(projection *Type) Value {
+	name := r.readString()
+	t := projectionFor(projection, StructKind, name)
+	count := r.readCount()
+	fieldNames := make([]string, count)
+	for i := uint64(0); i < count; i++ {
+		fieldNames[i] = r.readString()
+	}
+
+	if t == nil {
+		values := make([]Value, count)
+		for i := uint64(0); i < count; i++ {
+			values[i] = r.readValue()
+		}
+		return Struct{name, fieldNames, values, &hash.Hash{}}
+	}
+
+	desc := t.Desc.(StructDesc)
+	projected, values := []string{}, []Value{}
+	for _, fieldName := range fieldNames {
+		ft, _ := desc.Field(fieldName)
+		if ft == nil {
+			r.skipValue()
+			continue
+		}
+		projected = append(projected, fieldName)
+		values = append(values, r.readProjectedValue(ft))
+	}
+	return Struct{name, projected, values, &hash.Hash{}}
+}
+
+// skipValue skips over the value readValue would read, without decoding it.
+func (r *valueDecoder) skipValue() {
+	k := r.readKind()
+	switch k {
+	case BlobKind, ListKind, MapKind, SetKind:
+		if r.readBool() {
+			r.skipMetaSequence()
+			return
+		}
+		if k == BlobKind {
+			r.skipBytes()
+			return
+		}
+		count := r.readCount()
+		if k == MapKind {
+			count *= 2
+		}
+		for i := uint64(0); i < count; i++ {
+			r.skipValue()
+		}
+	case BoolKind:
+		r.readBool()
+	case NumberKind:
+		r.readNumber()
+	case StringKind:
+		r.skipBytes()
+	case RefKind, boxKind:
+		r.skipRef()
+	case StructKind:
+		r.skipBytes()
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.skipBytes()
+		}
+		for i := uint64(0); i < count; i++ {
+			r.skipValue()
+		}
+	case TypeKind:
+		r.skipType()
+	default:
+		d.Chk.Fail(fmt.Sprintf("A value instance can never have type %s", k))
+	}
+}
+
+func (r *valueDecoder) skipMetaSequence() {
+	count := r.readCount()
+	for i := uint64(0); i < count; i++ {
+		r.skipValue()
+		r.skipValue()
+		r.readCount()
+	}
+}
+
+func (r *valueDecoder) skipRef() {
+	r.readHash()
+	r.skipType()
+	r.readCount()
+}
+
+func (r *valueDecoder) skipType() {
+	switch k := r.readKind(); k {
+	case ListKind, RefKind, SetKind:
+		r.skipType()
+	case MapKind:
+		r.skipType()
+		r.skipType()
+	case StructKind:
+		r.skipBytes()
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.skipBytes()
+		}
+		for i := uint64(0); i < count; i++ {
+			r.skipType()
+		}
+		for i := uint64(0); i < count; i++ {
+			r.readBool()
+		}
+	case UnionKind:
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.skipType()
+		}
+	case CycleKind:
+		r.skipBytes()
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestProject(t *testing.T) {
+	assert := assert.New(t)
+	v := visitTestValue()
+
+	projection := MakeStructType("",
+		StructField{"name", StringType, false},
+		StructField{"friends", MakeMapType(ValueType, MakeStructType("", StructField{"name", StringType, false})), false},
+		StructField{"ids", MakeSetType(MakeStructType("Key")), false},
+		StructField{"ref", MakeRefType(MakeStructType("Key")), false},
+		StructField{"missing", NumberType, false},
+	)
+	expected := NewStruct("Person", StructData{
+		"name": String("Alice"),
+		"friends": NewMap(
+			String("bob"), NewStruct("Person", StructData{"name": String("Bob")}),
+			NewStruct("Key", StructData{"id": Number(1)}), String("keyed"),
+		),
+		"ids": NewSet(Number(7), NewStruct("Key", StructData{})),
+		"ref": NewRef(Number(42)),
+	})
+	assert.True(expected.Equals(Project(v, projection)), EncodedValue(Project(v, projection)))
+
+	// Union projections prune each value by the member of its kind, and name.
+	l := NewList(
+		NewStruct("A", StructData{"a": Number(1), "x": Number(2)}),
+		NewStruct("B", StructData{"b": Number(3), "x": Number(4)}),
+		Number(5),
+	)
+	projection = MakeListType(MakeUnionType(
+		MakeStructType("A", StructField{"a", NumberType, false}),
+		MakeStructType("B", StructField{"x", NumberType, false}),
+		NumberType,
+	))
+	expected2 := NewList(
+		NewStruct("A", StructData{"a": Number(1)}),
+		NewStruct("B", StructData{"x": Number(4)}),
+		Number(5),
+	)
+	assert.True(expected2.Equals(Project(l, projection)))
+
+	// Projections without Structs keep values whole.
+	assert.True(v.Equals(Project(v, ValueType)))
+	assert.True(l.Equals(Project(l, MakeListType(ValueType))))
+}
+
+func TestReadValueProjection(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	vs := newLocalValueStore(cs)
+
+	items := []Value{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, NewStruct("Item", StructData{"i": Number(i), "label": String("item")}))
+	}
+	big := NewList(items...)
+	v := NewStruct("Doc", StructData{
+		"title": String("doc"),
+		"items": big,
+		"small": NewList(items[:3]...),
+		"blob":  NewBlob(),
+		"type":  TypeOf(big),
+		"ref":   vs.WriteValue(Number(1)),
+		"props": NewMap(String("k"), items[0], Number(1), Bool(true)),
+	})
+	h := vs.WriteValue(v).TargetHash()
+	vs.Flush(h)
+
+	// Each projection is read as it's projected from the whole value.
+	projections := []*Type{
+		MakeStructType("", StructField{"title", StringType, false}),
+		MakeStructType("", StructField{"items", MakeListType(MakeStructType("", StructField{"i", NumberType, false})), false}),
+		MakeStructType("",
+			StructField{"small", MakeListType(MakeStructType("", StructField{"label", StringType, false})), false},
+			StructField{"props", MakeMapType(ValueType, MakeStructType("")), false},
+		),
+		MakeStructType(""),
+		ValueType,
+	}
+	for _, projection := range projections {
+		vs := newLocalValueStore(cs)
+		projected := vs.ReadValueProjection(h, projection)
+		assert.True(Project(v, projection).Equals(projected), projection.Describe())
+	}
+
+	// The chunks of fields which aren't projected aren't read.
+	vs = newLocalValueStore(cs)
+	reads := cs.Reads
+	projected := vs.ReadValueProjection(h, MakeStructType("", StructField{"title", StringType, false}))
+	assert.Equal(1, cs.Reads-reads)
+	assert.True(NewStruct("Doc", StructData{"title": String("doc")}).Equals(projected))
+
+	// Cached values are projected.
+	assert.True(v.Equals(vs.ReadValue(h)))
+	reads = cs.Reads
+	assert.True(projected.Equals(vs.ReadValueProjection(h, MakeStructType("", StructField{"title", StringType, false}))))
+	assert.Equal(0, cs.Reads-reads)
+	assert.Nil(vs.ReadValueProjection(Number(2).Hash(), ValueType))
+}
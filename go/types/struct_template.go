@@ -0,0 +1,187 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// StructTemplate precompiles the fields of a struct type, so that the fields
+// of its structs can be read and written by their index, the position of the
+// field in the type, without searching for them by name.
+type StructTemplate struct {
+	name       string
+	fieldNames []string
+	optional   []bool
+}
+
+// MakeStructTemplate returns the StructTemplate of the struct type t.
+func MakeStructTemplate(t *Type) StructTemplate {
+	desc, ok := t.Desc.(StructDesc)
+	d.PanicIfFalse(ok)
+	st := StructTemplate{desc.Name, make([]string, len(desc.fields)), make([]bool, len(desc.fields))}
+	for i, f := range desc.fields {
+		st.fieldNames[i] = f.Name
+		st.optional[i] = f.Optional
+	}
+	return st
+}
+
+// Len is the number of fields of the type.
+func (st StructTemplate) Len() int {
+	return len(st.fieldNames)
+}
+
+// FieldIndex returns the index of the field name, or -1 if the type has no
+// such field.
+func (st StructTemplate) FieldIndex(name string) int {
+	i := sort.SearchStrings(st.fieldNames, name)
+	if i == len(st.fieldNames) || st.fieldNames[i] != name {
+		return -1
+	}
+	return i
+}
+
+// FieldName returns the name of the field at index i.
+func (st StructTemplate) FieldName(i int) string {
+	return st.fieldNames[i]
+}
+
+// NewStruct returns a struct of the type, with values in the order of the
+// fields of the type. The values of optional fields may be nil, leaving the
+// fields out of the struct.
+func (st StructTemplate) NewStruct(values []Value) Struct {
+	d.PanicIfFalse(len(values) == len(st.fieldNames))
+	missing := false
+	for i, v := range values {
+		if v == nil {
+			d.PanicIfFalse(st.optional[i])
+			missing = true
+		}
+	}
+	if !missing {
+		return newStruct(st.name, st.fieldNames, append([]Value{}, values...))
+	}
+
+	fieldNames, vs := []string{}, []Value{}
+	for i, v := range values {
+		if v != nil {
+			fieldNames, vs = append(fieldNames, st.fieldNames[i]), append(vs, v)
+		}
+	}
+	return newStruct(st.name, fieldNames, vs)
+}
+
+// Bind returns a StructAccessor of s, which must be a struct of the type.
+func (st StructTemplate) Bind(s Struct) StructAccessor {
+	d.PanicIfFalse(s.name == st.name)
+	if len(s.fieldNames) == len(st.fieldNames) {
+		return StructAccessor{st, s, nil}
+	}
+	// Optional fields are missing from s, so the fields of s aren't at the
+	// indices of the template.
+	offsets := make([]int, len(st.fieldNames))
+	j := 0
+	for i, name := range st.fieldNames {
+		if j < len(s.fieldNames) && s.fieldNames[j] == name {
+			offsets[i] = j
+			j++
+		} else {
+			offsets[i] = -1
+		}
+	}
+	d.PanicIfFalse(j == len(s.fieldNames))
+	return StructAccessor{st, s, offsets}
+}
+
+// StructAccessor reads and writes the fields of a Struct by their index in a
+// StructTemplate.
+type StructAccessor struct {
+	st StructTemplate
+	s  Struct
+	// offsets are the indices in s of the fields of the template, -1 for
+	// missing optional fields, or nil if s has all the fields.
+	offsets []int
+}
+
+// Struct returns the struct a accesses.
+func (a StructAccessor) Struct() Struct {
+	return a.s
+}
+
+func (a StructAccessor) offset(i int) int {
+	if a.offsets == nil {
+		return i
+	}
+	return a.offsets[i]
+}
+
+// MaybeGet returns the value of the field at index i, or (nil, false) if it's
+// a missing optional field.
+func (a StructAccessor) MaybeGet(i int) (Value, bool) {
+	j := a.offset(i)
+	if j == -1 {
+		return nil, false
+	}
+	return a.s.values[j], true
+}
+
+// Get returns the value of the field at index i, and panics if it's missing.
+func (a StructAccessor) Get(i int) Value {
+	v, ok := a.MaybeGet(i)
+	if !ok {
+		d.Chk.Fail(fmt.Sprintf(`Struct has no field "%s"`, a.st.fieldNames[i]))
+	}
+	return v
+}
+
+// GetBool returns the Bool value of the field at index i.
+func (a StructAccessor) GetBool(i int) bool {
+	return bool(a.Get(i).(Bool))
+}
+
+// GetNumber returns the Number value of the field at index i.
+func (a StructAccessor) GetNumber(i int) float64 {
+	return float64(a.Get(i).(Number))
+}
+
+// GetString returns the String value of the field at index i.
+func (a StructAccessor) GetString(i int) string {
+	return string(a.Get(i).(String))
+}
+
+// Set returns an accessor of a new struct where the field at index i has
+// been set to v.
+func (a StructAccessor) Set(i int, v Value) StructAccessor {
+	j := a.offset(i)
+	if j == -1 {
+		return a.st.Bind(a.s.Set(a.st.fieldNames[i], v))
+	}
+	values := make([]Value, len(a.s.values))
+	copy(values, a.s.values)
+	values[j] = v
+	return StructAccessor{a.st, newStruct(a.s.name, a.s.fieldNames, values), a.offsets}
+}
+
+// SetBool returns an accessor of a new struct where the field at index i has
+// been set to the Bool b.
+func (a StructAccessor) SetBool(i int, b bool) StructAccessor {
+	return a.Set(i, Bool(b))
+}
+
+// SetNumber returns an accessor of a new struct where the field at index i
+// has been set to the Number n.
+func (a StructAccessor) SetNumber(i int, n float64) StructAccessor {
+	return a.Set(i, Number(n))
+}
+
+// SetString returns an accessor of a new struct where the field at index i
+// has been set to the String s.
+func (a StructAccessor) SetString(i int, s string) StructAccessor {
+	return a.Set(i, String(s))
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+var personType = MakeStructType("Person",
+	StructField{"age", NumberType, false},
+	StructField{"married", BoolType, false},
+	StructField{"name", StringType, false},
+	StructField{"nick", StringType, true},
+)
+
+func TestStructTemplateNewStruct(t *testing.T) {
+	assert := assert.New(t)
+	st := MakeStructTemplate(personType)
+	assert.Equal(4, st.Len())
+	assert.Equal(2, st.FieldIndex("name"))
+	assert.Equal("nick", st.FieldName(3))
+	assert.Equal(-1, st.FieldIndex("x"))
+
+	s := st.NewStruct([]Value{Number(42), Bool(true), String("Alice"), String("Al")})
+	assert.True(s.Equals(NewStruct("Person", StructData{
+		"age":     Number(42),
+		"married": Bool(true),
+		"name":    String("Alice"),
+		"nick":    String("Al"),
+	})))
+	assert.True(IsSubtype(personType, TypeOf(s)))
+
+	s = st.NewStruct([]Value{Number(42), Bool(true), String("Alice"), nil})
+	assert.True(s.Equals(NewStruct("Person", StructData{
+		"age":     Number(42),
+		"married": Bool(true),
+		"name":    String("Alice"),
+	})))
+	assert.True(IsSubtype(personType, TypeOf(s)))
+
+	assert.Panics(func() { st.NewStruct([]Value{nil, Bool(true), String("Alice"), nil}) })
+	assert.Panics(func() { st.NewStruct([]Value{Number(42)}) })
+}
+
+func TestStructTemplateAccessor(t *testing.T) {
+	assert := assert.New(t)
+	st := MakeStructTemplate(personType)
+	age, married, name, nick := st.FieldIndex("age"), st.FieldIndex("married"), st.FieldIndex("name"), st.FieldIndex("nick")
+
+	a := st.Bind(NewStruct("Person", StructData{
+		"age":     Number(42),
+		"married": Bool(true),
+		"name":    String("Alice"),
+	}))
+	assert.Equal(float64(42), a.GetNumber(age))
+	assert.True(a.GetBool(married))
+	assert.Equal("Alice", a.GetString(name))
+	_, ok := a.MaybeGet(nick)
+	assert.False(ok)
+	assert.Panics(func() { a.Get(nick) })
+
+	b := a.SetString(nick, "Al").SetNumber(age, 43)
+	assert.Equal("Al", b.GetString(nick))
+	assert.Equal(float64(43), b.GetNumber(age))
+	assert.Equal("Alice", b.GetString(name))
+	assert.True(b.Struct().Equals(NewStruct("Person", StructData{
+		"age":     Number(43),
+		"married": Bool(true),
+		"name":    String("Alice"),
+		"nick":    String("Al"),
+	})))
+	// a is unchanged.
+	assert.Equal(float64(42), a.GetNumber(age))
+
+	b = b.SetBool(married, false)
+	assert.False(b.GetBool(married))
+	assert.Equal("Al", b.GetString(nick))
+
+	assert.Panics(func() { st.Bind(NewStruct("Other", StructData{})) })
+	assert.Panics(func() { st.Bind(NewStruct("Person", StructData{"x": Number(1)})) })
+}
+
+func BenchmarkStructGet(b *testing.B) {
+	s := NewStruct("Person", StructData{
+		"age":     Number(42),
+		"married": Bool(true),
+		"name":    String("Alice"),
+		"nick":    String("Al"),
+	})
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = s.Get("name").(String)
+		}
+	})
+	b.Run("Template", func(b *testing.B) {
+		st := MakeStructTemplate(personType)
+		name := st.FieldIndex("name")
+		a := st.Bind(s)
+		for i := 0; i < b.N; i++ {
+			_ = a.GetString(name)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"io"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// The Try functions are like the constructors they call, but return the
+// errors those panic with, e.g. for invalid struct names or an odd number of
+// map keys and values, rather than panicking.
+
+// TryNewStruct is like NewStruct.
+func TryNewStruct(name string, data StructData) (s Struct, err error) {
+	defer d.Recover(&err)
+	return NewStruct(name, data), nil
+}
+
+// TryNewList is like NewList.
+func TryNewList(values ...Value) (l List, err error) {
+	defer d.Recover(&err)
+	return NewList(values...), nil
+}
+
+// TryNewSet is like NewSet.
+func TryNewSet(v ...Value) (s Set, err error) {
+	defer d.Recover(&err)
+	return NewSet(v...), nil
+}
+
+// TryNewMap is like NewMap.
+func TryNewMap(kv ...Value) (m Map, err error) {
+	defer d.Recover(&err)
+	return NewMap(kv...), nil
+}
+
+// TryNewBlob is like NewBlob, but also returns the errors reading rs.
+func TryNewBlob(rs ...io.Reader) (b Blob, err error) {
+	defer d.Recover(&err)
+	return NewBlob(rs...), nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestTryConstructors(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := TryNewStruct("S", StructData{"x": Number(1)})
+	assert.NoError(err)
+	assert.True(NewStruct("S", StructData{"x": Number(1)}).Equals(s))
+	_, err = TryNewStruct("S", StructData{"x y": Number(1)})
+	assert.Error(err)
+	_, err = TryNewStruct("1S", StructData{})
+	assert.Error(err)
+
+	l, err := TryNewList(Number(1), Number(2))
+	assert.NoError(err)
+	assert.True(NewList(Number(1), Number(2)).Equals(l))
+
+	set, err := TryNewSet(Number(1), Number(2))
+	assert.NoError(err)
+	assert.True(NewSet(Number(1), Number(2)).Equals(set))
+
+	m, err := TryNewMap(Number(1), String("a"))
+	assert.NoError(err)
+	assert.True(NewMap(Number(1), String("a")).Equals(m))
+	_, err = TryNewMap(Number(1), String("a"), Number(2))
+	assert.Error(err)
+
+	b, err := TryNewBlob(bytes.NewBufferString("abc"), bytes.NewBufferString("def"))
+	assert.NoError(err)
+	assert.True(NewBlob(bytes.NewBufferString("abcdef")).Equals(b))
+	readErr := errors.New("read failed")
+	_, err = TryNewBlob(failingReader{readErr})
+	assert.Equal(readErr, err)
+	_, err = TryNewBlob(bytes.NewBufferString("abc"), io.MultiReader(bytes.NewBufferString("def"), failingReader{readErr}))
+	assert.Equal(readErr, err)
+}
@@ -82,14 +82,8 @@ func (vbs *ValidatingBatchingSink) Flush() {
 // while enqueuing novel chunks. It panics if any of these refs point
 // to Chunks that don't exist in the backing ChunkStore.
 func (vbs *ValidatingBatchingSink) PanicIfDangling() {
-	present := vbs.cs.HasMany(vbs.unresolved)
-	absent := hash.HashSlice{}
-	for h := range vbs.unresolved {
-		if !present.Has(h) {
-			absent = append(absent, h)
-		}
-	}
+	absent := vbs.unresolved.Difference(vbs.cs.HasMany(vbs.unresolved))
 	if len(absent) != 0 {
-		d.Panic("Found dangling references to %v", absent)
+		d.Panic("Found dangling references to %v", absent.Sorted())
 	}
 }
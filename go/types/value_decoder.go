@@ -119,7 +119,10 @@ func (r *valueDecoder) readMetaSequence(k NomsKind) metaSequence {
 		ref := r.readValue().(Ref)
 		v := r.readValue()
 		var key orderedKey
-		if r, ok := v.(Ref); ok {
+		if b, ok := v.(mapBox); ok {
+			// Only keys ordered by value are boxed in a metaSequence, see mapBox.
+			key = orderedKey{true, b, hash.Hash{}}
+		} else if r, ok := v.(Ref); ok {
 			// See https://github.com/attic-labs/noms/issues/1688#issuecomment-227528987
 			key = orderedKeyFromHash(r.TargetHash())
 		} else {
@@ -133,7 +136,11 @@ func (r *valueDecoder) readMetaSequence(k NomsKind) metaSequence {
 }
 
 func (r *valueDecoder) readValue() Value {
-	k := r.readKind()
+	return r.readValueOfKind(r.readKind())
+}
+
+// readValueOfKind reads the rest of a value after its kind, k.
+func (r *valueDecoder) readValueOfKind(k NomsKind) Value {
 	switch k {
 	case BlobKind:
 		isMeta := r.readBool()
@@ -175,6 +182,8 @@ func (r *valueDecoder) readValue() Value {
 		return r.readStruct()
 	case TypeKind:
 		return r.readType()
+	case boxKind:
+		return mapBox{Ref: r.readRef()}
 	case CycleKind, UnionKind, ValueKind:
 		d.Chk.Fail(fmt.Sprintf("A value instance can never have type %s", k))
 	}
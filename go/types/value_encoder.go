@@ -85,11 +85,27 @@ func (w *valueEncoder) writeMapLeafSequence(seq mapLeafSequence) {
 	w.writeCount(uint64(count))
 
 	for i := uint32(0); i < count; i++ {
-		w.writeValue(seq.data[i].key)
-		w.writeValue(seq.data[i].value)
+		w.writeMapItem(seq.data[i].key)
+		w.writeMapItem(seq.data[i].value)
 	}
 }
 
+// writeMapItem writes v, a key or value of a Map, which is a mapBox if it's
+// boxed, see box. The Value of a box made in memory is written to w.vw.
+func (w *valueEncoder) writeMapItem(v Value) {
+	b, ok := v.(mapBox)
+	if !ok {
+		w.writeValue(v)
+		return
+	}
+	r := b.Ref
+	if b.v != nil && w.vw != nil {
+		r = w.vw.WriteValue(b.v)
+	}
+	w.writeKind(boxKind)
+	w.writeRef(r)
+}
+
 func (w *valueEncoder) maybeWriteMetaSequence(seq sequence) bool {
 	ms, ok := seq.(metaSequence)
 	if !ok {
@@ -114,7 +130,11 @@ func (w *valueEncoder) maybeWriteMetaSequence(seq sequence) bool {
 			d.PanicIfTrue(tuple.key.h.IsEmpty())
 			v = constructRef(tuple.key.h, BoolType, 0)
 		}
-		w.writeValue(v)
+		if ms.kind == MapKind {
+			w.writeMapItem(box(v))
+		} else {
+			w.writeValue(v)
+		}
 		w.writeCount(tuple.numLeaves)
 	}
 	return true
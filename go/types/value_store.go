@@ -20,6 +20,7 @@ import (
 // package that implements Value reading.
 type ValueReader interface {
 	ReadValue(h hash.Hash) Value
+	ReadValueProjection(h hash.Hash, projection *Type) Value
 	ReadManyValues(hashes hash.HashSet, foundValues chan<- Value)
 }
 
@@ -45,21 +46,45 @@ type ValueReadWriter interface {
 // Flush.
 // Currently, WriteValue validates the following properties of a Value v:
 // - v can be correctly serialized and its Ref taken
+// A ValueStore is safe for concurrent use by multiple goroutines, as long as
+// its BatchStore is.
 type ValueStore struct {
-	bs                   BatchStore
+	bs BatchStore
+	*valueStoreState
+}
+
+// valueStoreState is the state of a ValueStore, which it shares with those
+// WithBatchStore returns.
+type valueStoreState struct {
 	bufferMu             sync.RWMutex
 	bufferedChunks       map[hash.Hash]chunks.Chunk
 	bufferedChunksMax    uint64
 	bufferedChunkSize    uint64
 	withBufferedChildren map[hash.Hash]uint64 // chunk Hash -> ref height
+	budget               *sizecache.Budget
+	bufferedReserved     uint64 // bytes of budget reserved for bufferedChunks
 	valueCache           *sizecache.SizeCache
 	opcStore             opCacheStore
 	once                 sync.Once
+	verifyReads          bool
+	declaredRefs         *sizecache.SizeCache // target Hash -> Ref read by lvs
 }
 
 const (
 	defaultValueCacheSize = 1 << 25 // 32MB
 	defaultPendingPutMax  = 1 << 28 // 256MB
+
+	// pendingWritesShare is the part of sizecache.GetBudget() set aside for
+	// pending writes, a quarter of it, so that caches which fill the rest
+	// don't force each chunk written to be flushed right away, which would
+	// lose the locality of the chunks bufferChunk keeps together.
+	pendingWritesShare = 4
+
+	// declaredRefsSize bounds the memory used to remember the Refs read by a
+	// ValueStore which verifies reads, so that their targets can be checked
+	// against them when they're read. Refs are forgotten beyond it.
+	declaredRefsSize = 1 << 23 // 8MB
+	declaredRefSize  = 128
 )
 
 // NewTestValueStore creates a simple struct that satisfies ValueReadWriter
@@ -83,17 +108,86 @@ func NewValueStoreWithCache(bs BatchStore, cacheSize uint64) *ValueStore {
 	return newValueStoreWithCacheAndPending(bs, cacheSize, defaultPendingPutMax)
 }
 
+// NewValueStoreWithCache and NewValueStore account the value cache and the
+// buffer of pending writes to sizecache.GetBudget(), if it's set, as
+// "value_cache" and "pending_writes", of which the latter has a share of its
+// own.
 func newValueStoreWithCacheAndPending(bs BatchStore, cacheSize, pendingMax uint64) *ValueStore {
+	budget := sizecache.GetBudget()
+	if budget != nil {
+		budget.SetShare("pending_writes", budget.Stats().Max/pendingWritesShare)
+	}
 	return &ValueStore{
 		bs: bs,
+		valueStoreState: &valueStoreState{
+			bufferMu:             sync.RWMutex{},
+			bufferedChunks:       map[hash.Hash]chunks.Chunk{},
+			bufferedChunksMax:    pendingMax,
+			withBufferedChildren: map[hash.Hash]uint64{},
+			budget:               budget,
+
+			valueCache: sizecache.NewWithBudget(cacheSize, budget, "value_cache"),
+			once:       sync.Once{},
+		},
+	}
+}
+
+// WithBatchStore returns a ValueStore which shares the values lvs has cached
+// and buffered, but reads and writes them through bs, e.g. a view of the
+// BatchStore of lvs which makes its requests with another context. It
+// mustn't be closed; closing lvs is enough.
+func (lvs *ValueStore) WithBatchStore(bs BatchStore) *ValueStore {
+	return &ValueStore{bs, lvs.valueStoreState}
+}
+
+// SetValueCache replaces the cache of the values lvs reads with one of up to
+// size bytes of their chunks, which expires them by policy. A size of 0
+// disables the cache, e.g. for a ValueStore which only writes. It must be
+// called before lvs is used.
+func (lvs *ValueStore) SetValueCache(size uint64, policy sizecache.Policy) {
+	if lvs.valueCache != nil {
+		lvs.valueCache.Purge()
+		lvs.valueCache = nil
+	}
+	if size > 0 {
+		lvs.valueCache = sizecache.NewWithBudget(size, lvs.budget, "value_cache")
+		lvs.valueCache.SetPolicy(policy)
+	}
+}
+
+// ValueCacheStats returns the sizecache.Stats of the cache of the values lvs
+// reads, which are all zero if the cache is disabled. Its Hits and Misses
+// count the values looked up by reads, and by writes, which don't buffer
+// values that are cached.
+func (lvs *ValueStore) ValueCacheStats() sizecache.Stats {
+	if lvs.valueCache == nil {
+		return sizecache.Stats{}
+	}
+	return lvs.valueCache.Stats()
+}
 
-		bufferMu:             sync.RWMutex{},
-		bufferedChunks:       map[hash.Hash]chunks.Chunk{},
-		bufferedChunksMax:    pendingMax,
-		withBufferedChildren: map[hash.Hash]uint64{},
+func (lvs *ValueStore) cacheGet(h hash.Hash) (interface{}, bool) {
+	if lvs.valueCache == nil {
+		return nil, false
+	}
+	return lvs.valueCache.Get(h)
+}
+
+func (lvs *ValueStore) cacheAdd(h hash.Hash, size uint64, v Value) {
+	if lvs.valueCache != nil {
+		lvs.valueCache.Add(h, size, v)
+	}
+}
 
-		valueCache: sizecache.New(cacheSize),
-		once:       sync.Once{},
+// SetVerifyReads makes lvs verify each value it reads, at the cost of
+// decoding it more slowly and encoding it again: that the types in its
+// chunk are valid, that it encodes to the chunk's hash, and that its type
+// and height match those of the Refs to it lvs has read. lvs panics if one
+// doesn't. It must be called before lvs is used.
+func (lvs *ValueStore) SetVerifyReads(verify bool) {
+	lvs.verifyReads = verify
+	if verify && lvs.declaredRefs == nil {
+		lvs.declaredRefs = sizecache.New(declaredRefsSize)
 	}
 }
 
@@ -105,13 +199,53 @@ func (lvs *ValueStore) BatchStore() BatchStore {
 // for the requested chunk to be empty; in this case, the function simply
 // returns nil.
 func (lvs *ValueStore) ReadValue(h hash.Hash) Value {
-	if v, ok := lvs.valueCache.Get(h); ok {
+	if v, ok := lvs.cacheGet(h); ok {
 		if v == nil {
 			return nil
 		}
 		return v.(Value)
 	}
 
+	chunk := lvs.getChunk(h)
+	if chunk.IsEmpty() {
+		lvs.cacheAdd(h, 0, nil)
+		return nil
+	}
+
+	v := lvs.decode(chunk)
+	lvs.cacheAdd(h, uint64(len(chunk.Data())), v)
+	return v
+}
+
+// ReadValueProjection reads the value h, as ReadValue does, pruned to
+// projection as by Project, but decodes only the parts of it which are
+// projected: the values of fields which aren't are skipped over, and the
+// chunks of the collections in them aren't read at all. Values which are
+// pruned aren't cached.
+func (lvs *ValueStore) ReadValueProjection(h hash.Hash, projection *Type) Value {
+	if v, ok := lvs.cacheGet(h); ok {
+		if v == nil {
+			return nil
+		}
+		return Project(v.(Value), projection)
+	}
+	if lvs.verifyReads {
+		// Reads are verified against the hash of the whole value.
+		if v := lvs.ReadValue(h); v != nil {
+			return Project(v, projection)
+		}
+		return nil
+	}
+
+	chunk := lvs.getChunk(h)
+	if chunk.IsEmpty() {
+		return nil
+	}
+	return decodeProjection(chunk.Data(), lvs, projection)
+}
+
+// getChunk returns the chunk h, buffered or from lvs.bs, or an empty chunk.
+func (lvs *ValueStore) getChunk(h hash.Hash) chunks.Chunk {
 	chunk := func() chunks.Chunk {
 		lvs.bufferMu.RLock()
 		defer lvs.bufferMu.RUnlock()
@@ -123,13 +257,32 @@ func (lvs *ValueStore) ReadValue(h hash.Hash) Value {
 	if chunk.IsEmpty() {
 		chunk = lvs.bs.Get(h)
 	}
-	if chunk.IsEmpty() {
-		lvs.valueCache.Add(h, 0, nil)
-		return nil
-	}
+	return chunk
+}
 
-	v := DecodeValue(chunk, lvs)
-	lvs.valueCache.Add(h, uint64(len(chunk.Data())), v)
+// decode decodes c, verifying it first if lvs verifies reads.
+func (lvs *ValueStore) decode(c chunks.Chunk) Value {
+	if !lvs.verifyReads {
+		return DecodeValue(c, lvs)
+	}
+	h := c.Hash()
+	d.PanicIfTrue(c.IsEmpty())
+	v := decodeFromBytesWithValidation(c.Data(), lvs)
+	if actual := getHash(v); actual != h {
+		d.Panic("Value read as %s has hash %s", h, actual)
+	}
+	if declared, ok := lvs.declaredRefs.Get(h); ok {
+		r := declared.(Ref)
+		if t := TypeOf(v); !IsSubtype(r.TargetType(), t) {
+			d.Panic("Value %s is of type %s, but a Ref to it is of %s", h, t.Describe(), r.TargetType().Describe())
+		}
+		if height := maxChunkHeight(v) + 1; height != r.Height() {
+			d.Panic("Value %s has height %d, but a Ref to it has %d", h, height, r.Height())
+		}
+	}
+	v.WalkRefs(func(r Ref) {
+		lvs.declaredRefs.Add(r.TargetHash(), declaredRefSize, r)
+	})
 	return v
 }
 
@@ -138,15 +291,15 @@ func (lvs *ValueStore) ReadValue(h hash.Hash) Value {
 // found. Any non-present Values will silently be ignored.
 func (lvs *ValueStore) ReadManyValues(hashes hash.HashSet, foundValues chan<- Value) {
 	decode := func(h hash.Hash, chunk *chunks.Chunk, toPending bool) Value {
-		v := DecodeValue(*chunk, lvs)
-		lvs.valueCache.Add(h, uint64(len(chunk.Data())), v)
+		v := lvs.decode(*chunk)
+		lvs.cacheAdd(h, uint64(len(chunk.Data())), v)
 		return v
 	}
 
 	// First, see which hashes can be found in either the Value cache or bufferedChunks. Put the rest into a new HashSet to be requested en masse from the BatchStore.
 	remaining := hash.HashSet{}
 	for h := range hashes {
-		if v, ok := lvs.valueCache.Get(h); ok {
+		if v, ok := lvs.cacheGet(h); ok {
 			if v != nil {
 				foundValues <- v.(Value)
 			}
@@ -190,7 +343,7 @@ func (lvs *ValueStore) ReadManyValues(hashes hash.HashSet, foundValues chan<- Va
 
 	// Any remaining hashes weren't found in the BatchStore should be recorded as not present.
 	for h := range remaining {
-		lvs.valueCache.Add(h, 0, nil)
+		lvs.cacheAdd(h, 0, nil)
 	}
 }
 
@@ -205,12 +358,14 @@ func (lvs *ValueStore) WriteValue(v Value) Ref {
 	h := c.Hash()
 	height := maxChunkHeight(v) + 1
 	r := constructRef(h, TypeOf(v), height)
-	if v, ok := lvs.valueCache.Get(h); ok && v != nil {
+	if v, ok := lvs.cacheGet(h); ok && v != nil {
 		return r
 	}
 
 	lvs.bufferChunk(v, c, height)
-	lvs.valueCache.Drop(h) // valueCache may have an entry saying h is not present. Clear that.
+	if lvs.valueCache != nil {
+		lvs.valueCache.Drop(h) // valueCache may have an entry saying h is not present. Clear that.
+	}
 	return r
 }
 
@@ -223,7 +378,7 @@ func (lvs *ValueStore) WriteValue(v Value) Ref {
 //    chunk may also be presently buffered (any grandchildren will have been
 //    flushed).
 // 2. The total data occupied by buffered chunks does not exceed
-//    lvs.bufferedChunksMax
+//    lvs.bufferedChunksMax, nor what's left of lvs.budget
 func (lvs *ValueStore) bufferChunk(v Value, c chunks.Chunk, height uint64) {
 	lvs.bufferMu.Lock()
 	defer lvs.bufferMu.Unlock()
@@ -265,7 +420,7 @@ func (lvs *ValueStore) bufferChunk(v Value, c chunks.Chunk, height uint64) {
 	}
 
 	// Enforce invariant (2)
-	for lvs.bufferedChunkSize > lvs.bufferedChunksMax {
+	for lvs.bufferedChunkSize > lvs.bufferedChunksMax || !lvs.reserveBuffered() {
 		var tallest hash.Hash
 		var height uint64 = 0
 		for parent, ht := range lvs.withBufferedChildren {
@@ -290,6 +445,19 @@ func (lvs *ValueStore) bufferChunk(v Value, c chunks.Chunk, height uint64) {
 	}
 }
 
+// reserveBuffered reserves or releases budget to match the size of the
+// buffered chunks, and returns false if there isn't enough of it left.
+// Callers should hold bufferMu.
+func (lvs *ValueStore) reserveBuffered() bool {
+	if lvs.bufferedChunkSize <= lvs.bufferedReserved {
+		lvs.budget.Release("pending_writes", lvs.bufferedReserved-lvs.bufferedChunkSize)
+	} else if !lvs.budget.Reserve("pending_writes", lvs.bufferedChunkSize-lvs.bufferedReserved) {
+		return false
+	}
+	lvs.bufferedReserved = lvs.bufferedChunkSize
+	return true
+}
+
 func (lvs *ValueStore) Flush(root hash.Hash) {
 	func() {
 		lvs.bufferMu.Lock()
@@ -313,12 +481,21 @@ func (lvs *ValueStore) Flush(root hash.Hash) {
 		})
 		delete(lvs.withBufferedChildren, root) // If not present, this is idempotent
 		lvs.bufferedChunkSize -= put(root, pending)
+		lvs.reserveBuffered()
 	}()
 	lvs.bs.Flush()
 }
 
 // Close closes the underlying BatchStore
 func (lvs *ValueStore) Close() error {
+	lvs.bufferMu.Lock()
+	lvs.budget.Release("pending_writes", lvs.bufferedReserved)
+	lvs.bufferedReserved = 0
+	lvs.bufferMu.Unlock()
+	if lvs.valueCache != nil {
+		lvs.valueCache.Purge()
+	}
+
 	if lvs.opcStore != nil {
 		err := lvs.opcStore.destroy()
 		d.Chk.NoError(err, "Attempt to clean up opCacheStore failed, error: %s\n", err)
@@ -8,7 +8,9 @@ import (
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/sizecache"
 	"github.com/attic-labs/testify/assert"
 )
 
@@ -157,6 +159,51 @@ func TestFlushOverSize(t *testing.T) {
 	vs.Flush(l.Hash())
 }
 
+func TestFlushOverBudget(t *testing.T) {
+	assert := assert.New(t)
+	b := sizecache.NewBudget(10)
+	sizecache.SetBudget(b)
+	defer sizecache.SetBudget(nil)
+
+	bs := &checkingBatchStore{NewBatchStoreAdaptor(chunks.NewTestStore()), assert, nil}
+	vs := newValueStoreWithCacheAndPending(bs, 1<<20, 1<<20)
+
+	s := String("oy")
+	sr := vs.WriteValue(s)
+	l := NewList(sr)
+	bs.expect(sr, NewRef(l))
+
+	vs.WriteValue(l)
+	assert.True(b.Stats().Used <= 10)
+	vs.Flush(l.Hash())
+	vs.Close()
+	assert.Equal(uint64(0), b.Stats().Used)
+}
+
+func TestPendingWritesShareOfBudget(t *testing.T) {
+	assert := assert.New(t)
+	b := sizecache.NewBudget(1000)
+	sizecache.SetBudget(b)
+	defer sizecache.SetBudget(nil)
+
+	bs := &checkingBatchStore{NewBatchStoreAdaptor(chunks.NewTestStore()), assert, nil}
+	vs := newValueStoreWithCacheAndPending(bs, 1<<20, 1<<20)
+	defer vs.Close()
+
+	// The value cache can't fill the share of the pending writes, so writing
+	// doesn't flush anything.
+	for i := 0; i < 10; i++ {
+		vs.valueCache.Add(i, 100, nil)
+	}
+	assert.Equal(uint64(700), b.Stats().ByName["value_cache"])
+	s := String("oy")
+	vs.WriteValue(s)
+	assert.NotZero(b.Stats().ByName["pending_writes"])
+
+	bs.expect(NewRef(s))
+	vs.Flush(s.Hash())
+}
+
 func TestTolerateTopDown(t *testing.T) {
 	assert := assert.New(t)
 	bs := &checkingBatchStore{NewBatchStoreAdaptor(chunks.NewTestStore()), assert, nil}
@@ -211,3 +258,82 @@ type badVersionStore struct {
 func (b *badVersionStore) Version() string {
 	return "BAD"
 }
+
+func TestVerifyReads(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	vs := newLocalValueStore(cs)
+	vs.SetVerifyReads(true)
+
+	l := NewList(String("a"), Number(1))
+	r := vs.WriteValue(l)
+	vs.Flush(r.TargetHash())
+	assert.True(l.Equals(vs.ReadValue(r.TargetHash())))
+
+	// A chunk whose data doesn't match its hash.
+	bad := chunks.NewChunkWithHash(hash.Of([]byte("bad")), EncodeValue(String("b"), nil).Data())
+	cs.Put(bad)
+	assert.Error(d.Try(func() { vs.ReadValue(bad.Hash()) }))
+
+	// A Ref whose target type doesn't match its target.
+	s := String("s")
+	cs.Put(EncodeValue(s, nil))
+	wrongRef := constructRef(s.Hash(), NumberType, 1)
+	c := EncodeValue(NewList(wrongRef), nil)
+	cs.Put(c)
+	vs = newLocalValueStore(cs)
+	vs.SetVerifyReads(true)
+	vs.ReadValue(c.Hash())
+	assert.Error(d.Try(func() { vs.ReadValue(s.Hash()) }))
+
+	// A Ref whose height doesn't match its target.
+	wrongRef = constructRef(s.Hash(), StringType, 2)
+	c = EncodeValue(NewList(wrongRef), nil)
+	cs.Put(c)
+	vs = newLocalValueStore(cs)
+	vs.SetVerifyReads(true)
+	vs.ReadValue(c.Hash())
+	assert.Error(d.Try(func() { vs.ReadValue(s.Hash()) }))
+
+	// Without verification, the value is read as is.
+	vs = newLocalValueStore(cs)
+	vs.ReadValue(c.Hash())
+	assert.True(s.Equals(vs.ReadValue(s.Hash())))
+}
+
+func TestValueCache(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	s := String("hello")
+	cs.Put(EncodeValue(s, nil))
+
+	vs := newLocalValueStore(cs)
+	assert.True(s.Equals(vs.ReadValue(s.Hash())))
+	assert.True(s.Equals(vs.ReadValue(s.Hash())))
+	assert.Equal(1, cs.Reads)
+	stats := vs.ValueCacheStats()
+	assert.Equal(uint64(1), stats.Hits)
+	assert.Equal(uint64(1), stats.Misses)
+	assert.Equal(1, stats.Entries)
+	assert.Equal(uint64(defaultValueCacheSize), stats.MaxSize)
+
+	// Without the cache, every read gets the chunk.
+	vs = newLocalValueStore(cs)
+	vs.SetValueCache(0, sizecache.LRU)
+	assert.True(s.Equals(vs.ReadValue(s.Hash())))
+	assert.True(s.Equals(vs.ReadValue(s.Hash())))
+	assert.Nil(vs.ReadValue(String("other").Hash()))
+	assert.Equal(4, cs.Reads)
+	assert.Equal(sizecache.Stats{}, vs.ValueCacheStats())
+	r := vs.WriteValue(Number(1))
+	vs.Flush(r.TargetHash())
+	assert.True(Number(1).Equals(vs.ReadValue(r.TargetHash())))
+	assert.Equal(5, cs.Reads)
+
+	vs = newLocalValueStore(cs)
+	vs.SetValueCache(1<<10, sizecache.FIFO)
+	vs.ReadValue(s.Hash())
+	vs.ReadValue(s.Hash())
+	assert.Equal(6, cs.Reads)
+	assert.Equal(uint64(1<<10), vs.ValueCacheStats().MaxSize)
+}
@@ -0,0 +1,203 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+// Visit and Transform walk a Value and the values in it: the fields of
+// Structs, and the elements of Lists, Sets and Maps, both keys and values.
+// Each value is visited with the Path to it from the root, such that
+// resolving the Path in the root gives the value. The elements of a Set which
+// can be a path index are at @at(<position>), the others at [#<hash>], and
+// the keys of a Map are at [<key>]@key, or [#<hash>]@key. Refs and Blobs
+// aren't walked into, see WalkValues for following Refs.
+
+// Visitor is a pair of callbacks for Visit, either of which may be nil.
+type Visitor struct {
+	// Pre is called with each value before the values in it are visited. If
+	// it returns false, neither those nor Post are visited for the value.
+	Pre func(p Path, v Value) bool
+	// Post is called with each value after the values in it have been
+	// visited.
+	Post func(p Path, v Value)
+}
+
+// Visit calls the callbacks of visitor with v and each of the values in it,
+// depth first, in the order of the fields of Structs and the elements of
+// collections.
+func Visit(v Value, visitor Visitor) {
+	visit(Path{}, v, visitor)
+}
+
+func visit(p Path, v Value, visitor Visitor) {
+	if visitor.Pre != nil && !visitor.Pre(p, v) {
+		return
+	}
+	forEachChild(p, v, func(cp Path, cv Value) {
+		visit(cp, cv, visitor)
+	})
+	if visitor.Post != nil {
+		visitor.Post(p, v)
+	}
+}
+
+// Transformer is a pair of callbacks for Transform, either of which may be
+// nil. Either callback can return nil to remove a value from the Struct or
+// collection it's in, e.g. to remove the field of a Struct, the element of a
+// List or the entry of a Map with that key or value.
+type Transformer struct {
+	// Pre is called with each value before the values in it are transformed,
+	// and returns the value to replace it with, which may be v itself, and
+	// whether to transform the values in that.
+	Pre func(p Path, v Value) (Value, bool)
+	// Post is called with each value after the values in it have been
+	// transformed, and returns the value to replace it with.
+	Post func(p Path, v Value) Value
+}
+
+// Transform returns v rewritten by the callbacks of t, which are called with
+// v and each of the values in it, depth first, as for Visit. The Paths passed
+// to the callbacks are those of the values in v, before any are rewritten.
+//
+// Values in which nothing is rewritten are returned as they are, and
+// collections are rewritten by editing only the elements which changed, so
+// the chunks of the subtrees which didn't are shared with v.
+func Transform(v Value, t Transformer) Value {
+	return transform(Path{}, v, t)
+}
+
+func transform(p Path, v Value, t Transformer) Value {
+	descend := true
+	if t.Pre != nil {
+		if v, descend = t.Pre(p, v); v == nil {
+			return nil
+		}
+	}
+	if descend {
+		v = transformChildren(p, v, t)
+	}
+	if t.Post != nil {
+		v = t.Post(p, v)
+	}
+	return v
+}
+
+func transformChildren(p Path, v Value, t Transformer) Value {
+	switch v := v.(type) {
+	case Struct:
+		s := v
+		v.IterFields(func(name string, fv Value) {
+			if nv := transform(p.Append(NewFieldPath(name)), fv, t); nv == nil {
+				s = s.Delete(name)
+			} else if !nv.Equals(fv) {
+				s = s.Set(name, nv)
+			}
+		})
+		return s
+
+	case List:
+		type edit struct {
+			idx uint64
+			v   Value
+		}
+		edits := []edit{}
+		v.IterAll(func(ev Value, idx uint64) {
+			if nv := transform(p.Append(NewIndexPath(Number(idx))), ev, t); nv == nil || !nv.Equals(ev) {
+				edits = append(edits, edit{idx, nv})
+			}
+		})
+		// Edit from the back, so that removals don't shift the indices of the
+		// edits still to make.
+		l := v
+		for i := len(edits) - 1; i >= 0; i-- {
+			if e := edits[i]; e.v == nil {
+				l = l.RemoveAt(e.idx)
+			} else {
+				l = l.Set(e.idx, e.v)
+			}
+		}
+		return l
+
+	case Map:
+		removed, set := []Value{}, []Value{}
+		v.IterAll(func(k, mv Value) {
+			kp, vp := mapEntryPaths(k)
+			nk, nv := transform(p.Append(kp), k, t), transform(p.Append(vp), mv, t)
+			switch {
+			case nk == nil || nv == nil:
+				removed = append(removed, k)
+			case !nk.Equals(k):
+				removed = append(removed, k)
+				set = append(set, nk, nv)
+			case !nv.Equals(mv):
+				set = append(set, k, nv)
+			}
+		})
+		m := v
+		for _, k := range removed {
+			m = m.Remove(k)
+		}
+		return m.SetM(set...)
+
+	case Set:
+		removed, inserted := []Value{}, []Value{}
+		i := int64(0)
+		v.IterAll(func(ev Value) {
+			if nv := transform(p.Append(setElementPath(ev, i)), ev, t); nv == nil {
+				removed = append(removed, ev)
+			} else if !nv.Equals(ev) {
+				removed = append(removed, ev)
+				inserted = append(inserted, nv)
+			}
+			i++
+		})
+		if len(removed) == 0 && len(inserted) == 0 {
+			return v
+		}
+		return v.Remove(removed...).Insert(inserted...)
+	}
+	return v
+}
+
+// forEachChild calls cb with each of the values in v, and their paths from p.
+func forEachChild(p Path, v Value, cb func(p Path, v Value)) {
+	switch v := v.(type) {
+	case Struct:
+		v.IterFields(func(name string, fv Value) {
+			cb(p.Append(NewFieldPath(name)), fv)
+		})
+	case List:
+		v.IterAll(func(ev Value, idx uint64) {
+			cb(p.Append(NewIndexPath(Number(idx))), ev)
+		})
+	case Map:
+		v.IterAll(func(k, mv Value) {
+			kp, vp := mapEntryPaths(k)
+			cb(p.Append(kp), k)
+			cb(p.Append(vp), mv)
+		})
+	case Set:
+		i := int64(0)
+		v.IterAll(func(ev Value) {
+			cb(p.Append(setElementPath(ev, i)), ev)
+			i++
+		})
+	}
+}
+
+// mapEntryPaths returns the path parts of the key k of a Map, and its value.
+func mapEntryPaths(k Value) (key, value PathPart) {
+	if ValueCanBePathIndex(k) {
+		return NewIndexIntoKeyPath(k), NewIndexPath(k)
+	}
+	return NewHashIndexIntoKeyPath(k.Hash()), NewHashIndexPath(k.Hash())
+}
+
+// setElementPath returns the path part of v, the element of a Set at
+// position i.
+func setElementPath(v Value, i int64) PathPart {
+	if ValueCanBePathIndex(v) {
+		return NewAtAnnotation(i)
+	}
+	return NewHashIndexPath(v.Hash())
+}
@@ -0,0 +1,227 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func visitTestValue() Struct {
+	key := NewStruct("Key", StructData{"id": Number(1)})
+	return NewStruct("Person", StructData{
+		"name": String("Alice"),
+		"ssn":  String("123-45-6789"),
+		"tags": NewList(String("a"), String("b")),
+		"friends": NewMap(
+			String("bob"), NewStruct("Person", StructData{"name": String("Bob"), "ssn": String("987-65-4321")}),
+			key, String("keyed"),
+		),
+		"ids": NewSet(Number(7), key),
+		"ref": NewRef(Number(42)),
+	})
+}
+
+func TestVisit(t *testing.T) {
+	assert := assert.New(t)
+	v := visitTestValue()
+
+	pre, post := []string{}, []string{}
+	Visit(v, Visitor{
+		Pre: func(p Path, pv Value) bool {
+			pre = append(pre, p.String())
+			// Each value is at its path.
+			if assert.NotNil(p.Resolve(v), p.String()) {
+				assert.True(pv.Equals(p.Resolve(v)), p.String())
+			}
+			return true
+		},
+		Post: func(p Path, pv Value) {
+			post = append(post, p.String())
+		},
+	})
+	key := NewStruct("Key", StructData{"id": Number(1)})
+	keyIdx := "[#" + key.Hash().String() + "]"
+	expected := []string{
+		"",
+		".friends",
+		`.friends["bob"]@key`,
+		`.friends["bob"]`,
+		`.friends["bob"].name`,
+		`.friends["bob"].ssn`,
+		".friends" + keyIdx + "@key",
+		".friends" + keyIdx + "@key.id",
+		".friends" + keyIdx,
+		".ids",
+		".ids@at(0)",
+		".ids" + keyIdx,
+		".ids" + keyIdx + ".id",
+		".name",
+		".ref",
+		".ssn",
+		".tags",
+		".tags[0]",
+		".tags[1]",
+	}
+	assert.Equal(expected, pre)
+	assert.Len(post, len(pre))
+	assert.Equal(".friends", post[7])
+	assert.Equal("", post[len(post)-1])
+
+	// Returning false from Pre skips the values in a value, and its Post.
+	pre, post = []string{}, []string{}
+	Visit(v, Visitor{
+		Pre: func(p Path, pv Value) bool {
+			pre = append(pre, p.String())
+			return p.String() != ".friends" && p.String() != ".ids"
+		},
+		Post: func(p Path, pv Value) {
+			post = append(post, p.String())
+		},
+	})
+	assert.Equal([]string{"", ".friends", ".ids", ".name", ".ref", ".ssn", ".tags", ".tags[0]", ".tags[1]"}, pre)
+	assert.Equal([]string{".name", ".ref", ".ssn", ".tags[0]", ".tags[1]", ".tags", ""}, post)
+
+	// Either callback may be nil.
+	n := 0
+	Visit(v, Visitor{Post: func(p Path, pv Value) { n++ }})
+	assert.Equal(len(expected), n)
+}
+
+func TestTransformRedact(t *testing.T) {
+	assert := assert.New(t)
+	v := visitTestValue()
+
+	redacted := Transform(v, Transformer{
+		Post: func(p Path, pv Value) Value {
+			if len(p) > 0 && p[len(p)-1] == (FieldPath{"ssn"}) {
+				return String("***")
+			}
+			return pv
+		},
+	}).(Struct)
+	assert.Equal(String("***"), redacted.Get("ssn"))
+	bob := redacted.Get("friends").(Map).Get(String("bob")).(Struct)
+	assert.Equal(String("***"), bob.Get("ssn"))
+	assert.Equal(String("Bob"), bob.Get("name"))
+	assert.True(v.Get("tags").Equals(redacted.Get("tags")))
+	assert.True(v.Get("ids").Equals(redacted.Get("ids")))
+
+	// Nothing rewritten gives back the same value.
+	assert.True(v.Equals(Transform(v, Transformer{})))
+}
+
+func TestTransformRewriteAndRemove(t *testing.T) {
+	assert := assert.New(t)
+	v := visitTestValue()
+
+	// Rename the name fields of Persons, upper case Strings which are the
+	// elements of collections, and remove Structs from collections.
+	transformed := Transform(v, Transformer{
+		Pre: func(p Path, pv Value) (Value, bool) {
+			if len(p) > 0 && p[0] == (FieldPath{"ref"}) {
+				return nil, false
+			}
+			return pv, true
+		},
+		Post: func(p Path, pv Value) Value {
+			if len(p) == 0 {
+				if s, ok := pv.(Struct); ok && s.Name() == "Person" {
+					return s.Delete("name").Set("fullName", s.Get("name"))
+				}
+				return pv
+			}
+			switch last := p[len(p)-1].(type) {
+			case FieldPath:
+				if s, ok := pv.(Struct); ok && s.Name() == "Person" {
+					return s.Delete("name").Set("fullName", s.Get("name"))
+				}
+				return pv
+			case IndexPath:
+				if s, ok := pv.(String); ok && !last.IntoKey {
+					return String(strings.ToUpper(string(s)))
+				}
+			}
+			if _, ok := pv.(Struct); ok {
+				return nil
+			}
+			return pv
+		},
+	}).(Struct)
+
+	_, ok := transformed.MaybeGet("ref")
+	assert.False(ok)
+	_, ok = transformed.MaybeGet("name")
+	assert.False(ok)
+	assert.Equal(String("Alice"), transformed.Get("fullName"))
+	assert.True(NewList(String("A"), String("B")).Equals(transformed.Get("tags")))
+	// Bob is removed, as is the entry with a Struct key.
+	assert.True(NewMap().Equals(transformed.Get("friends")))
+	assert.True(NewSet(Number(7)).Equals(transformed.Get("ids")))
+
+	// A Map key can be rewritten.
+	m := Transform(NewMap(String("a"), Number(1), String("b"), Number(2)), Transformer{
+		Post: func(p Path, pv Value) Value {
+			if len(p) == 1 {
+				if ip, ok := p[0].(IndexPath); ok && ip.IntoKey && pv.Equals(String("a")) {
+					return String("c")
+				}
+			}
+			return pv
+		},
+	})
+	assert.True(NewMap(String("b"), Number(2), String("c"), Number(1)).Equals(m))
+
+	// Removing the root gives nil.
+	assert.Nil(Transform(v, Transformer{Post: func(p Path, pv Value) Value { return nil }}))
+}
+
+func TestTransformSharesUnchangedChunks(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	vs := NewTestValueStore()
+	nums := make([]Value, 1000)
+	for i := range nums {
+		nums[i] = Number(i)
+	}
+	s := NewStruct("", StructData{
+		"list": NewList(nums...),
+		"map":  NewMap(nums...),
+	})
+	s = vs.ReadValue(vs.WriteValue(s).TargetHash()).(Struct)
+
+	transformed := Transform(s, Transformer{
+		Post: func(p Path, pv Value) Value {
+			if p.String() == ".list[500]" {
+				return String("changed")
+			}
+			return pv
+		},
+	}).(Struct)
+	assert.True(s.Get("map").Equals(transformed.Get("map")))
+	assert.Equal(String("changed"), transformed.Get("list").(List).Get(500))
+
+	leaves := func(l List) hash.HashSet {
+		hs := hash.HashSet{}
+		for _, seq := range l.sequence().(metaSequence).getChildren(0, uint64(l.sequence().seqLen())) {
+			hs.Insert(newList(seq).Hash())
+		}
+		return hs
+	}
+	before, after := leaves(s.Get("list").(List)), leaves(transformed.Get("list").(List))
+	shared := 0
+	for h := range after {
+		if before.Has(h) {
+			shared++
+		}
+	}
+	assert.True(shared > 0 && len(after)-shared <= 2, "%d of %d shared", shared, len(after))
+}
@@ -25,6 +25,7 @@ type ClientTestSuite struct {
 	ExitStatus int
 	out        *os.File
 	err        *os.File
+	home       string
 }
 
 type ExitError struct {
@@ -48,11 +49,17 @@ func (suite *ClientTestSuite) SetupSuite() {
 
 	os.Mkdir(suite.DBDir, 0777)
 	os.Mkdir(suite.DBDir2, 0777)
+
+	// Keep what commands write to the home directory, such as reflogs, out of
+	// the real one.
+	suite.home = os.Getenv("HOME")
+	os.Setenv("HOME", dir)
 }
 
 func (suite *ClientTestSuite) TearDownSuite() {
 	suite.out.Close()
 	suite.err.Close()
+	os.Setenv("HOME", suite.home)
 	defer d.Chk.NoError(os.RemoveAll(suite.TempDir))
 }
 
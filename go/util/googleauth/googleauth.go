@@ -0,0 +1,272 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package googleauth authenticates requests to Google Cloud APIs with OAuth2
+// access tokens, got with a service account key file or Google's application
+// default credentials.
+package googleauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DevStorageScope is the scope of reading and writing Cloud Storage.
+	DevStorageScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+	defaultTokenURL  = "https://oauth2.googleapis.com/token"
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// Tokens are refreshed this long before they expire.
+	expiryDelta = time.Minute
+)
+
+// credentialsFile is a service account key file, or the application default
+// credentials of a user, as written by `gcloud auth application-default
+// login`.
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// Service account fields.
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	// User fields.
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// tokenResponse is the response of a token endpoint or the metadata server.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// tokenFunc gets a new access token, which expires at expiry.
+type tokenFunc func() (token string, expiry time.Time, err error)
+
+// NewClient returns a client which authenticates its requests with tokens for
+// scope. They're got with the service account key file keyFile or, if it's
+// empty, with the application default credentials: the file named by
+// $GOOGLE_APPLICATION_CREDENTIALS, that written by gcloud, or the service
+// account of the Compute Engine instance it's running on.
+func NewClient(keyFile, scope string) (*http.Client, error) {
+	if keyFile == "" {
+		keyFile = defaultCredentialsFile()
+	}
+	if keyFile == "" {
+		return newClient(metadataToken), nil
+	}
+
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("Invalid credentials file %s: %s", keyFile, err)
+	}
+	if f.TokenURI == "" {
+		f.TokenURI = defaultTokenURL
+	}
+	switch f.Type {
+	case "service_account":
+		key, err := parsePrivateKey(f.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid private key in %s: %s", keyFile, err)
+		}
+		return newClient(func() (string, time.Time, error) {
+			return serviceAccountToken(f, key, scope)
+		}), nil
+	case "authorized_user":
+		return newClient(func() (string, time.Time, error) {
+			return postForToken(f.TokenURI, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {f.ClientID},
+				"client_secret": {f.ClientSecret},
+				"refresh_token": {f.RefreshToken},
+			})
+		}), nil
+	}
+	return nil, fmt.Errorf("Unknown type %q of credentials file %s", f.Type, keyFile)
+}
+
+// NewStaticClient returns a client which sets the Authorization header of its
+// requests to authorization, e.g. "Bearer <token>".
+func NewStaticClient(authorization string) *http.Client {
+	return newClient(func() (string, time.Time, error) {
+		return strings.TrimPrefix(authorization, "Bearer "), time.Time{}, nil
+	})
+}
+
+// defaultCredentialsFile returns the file of the application default
+// credentials, if there is one.
+func defaultCredentialsFile() string {
+	if f := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); f != "" {
+		return f
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		f := filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return ""
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// serviceAccountToken exchanges a JWT signed by the service account of f for
+// an access token.
+func serviceAccountToken(f credentialsFile, key *rsa.PrivateKey, scope string) (string, time.Time, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": f.PrivateKeyID})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   f.ClientEmail,
+		"scope": scope,
+		"aud":   f.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return postForToken(f.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {signed + "." + enc.EncodeToString(sig)},
+	})
+}
+
+func postForToken(tokenURL string, form url.Values) (string, time.Time, error) {
+	resp, err := http.PostForm(tokenURL, form)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return readToken(resp)
+}
+
+func metadataToken() (string, time.Time, error) {
+	req, err := http.NewRequest("GET", metadataTokenURL, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("No Google credentials found, and the metadata server can't be reached: %s", err)
+	}
+	return readToken(resp)
+}
+
+func readToken(resp *http.Response) (string, time.Time, error) {
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("Getting an access token failed with %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var tr tokenResponse
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return "", time.Time{}, err
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, errors.New("Getting an access token failed: the response has none")
+	}
+	return tr.AccessToken, time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second), nil
+}
+
+func newClient(f tokenFunc) *http.Client {
+	return &http.Client{Transport: &transport{base: http.DefaultTransport, tokenFunc: f}}
+}
+
+// transport adds an access token to requests, getting a new one when the
+// last one is about to expire. A zero expiry never expires.
+type transport struct {
+	base      http.RoundTripper
+	tokenFunc tokenFunc
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.currentToken()
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers mustn't modify the request.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(r)
+}
+
+func (t *transport) currentToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && (t.expiry.IsZero() || time.Now().Add(expiryDelta).Before(t.expiry)) {
+		return t.token, nil
+	}
+	token, expiry, err := t.tokenFunc()
+	if err != nil {
+		return "", err
+	}
+	t.token, t.expiry = token, expiry
+	return token, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package googleauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func writeCredentials(assert *assert.Assertions, dir string, f credentialsFile) string {
+	data, err := json.Marshal(map[string]string{
+		"type":           f.Type,
+		"client_email":   f.ClientEmail,
+		"private_key_id": f.PrivateKeyID,
+		"private_key":    f.PrivateKey,
+		"token_uri":      f.TokenURI,
+		"client_id":      f.ClientID,
+		"client_secret":  f.ClientSecret,
+		"refresh_token":  f.RefreshToken,
+	})
+	assert.NoError(err)
+	file := filepath.Join(dir, "credentials.json")
+	assert.NoError(ioutil.WriteFile(file, data, 0600))
+	return file
+}
+
+// echoServer responds with the Authorization header of each request.
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+}
+
+func get(assert *assert.Assertions, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	if !assert.NoError(err) {
+		return ""
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(err)
+	return string(data)
+}
+
+func TestServiceAccount(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "googleauth")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tokens := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("urn:ietf:params:oauth:grant-type:jwt-bearer", r.FormValue("grant_type"))
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if !assert.Len(parts, 3) {
+			return
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		assert.NoError(err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+
+		claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+		assert.NoError(err)
+		var claims map[string]interface{}
+		assert.NoError(json.Unmarshal(claimsJSON, &claims))
+		assert.Equal("noms@example.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(DevStorageScope, claims["scope"])
+
+		tokens++
+		fmt.Fprintf(w, `{"access_token": "token%d", "expires_in": 3600, "token_type": "Bearer"}`, tokens)
+	}))
+	defer tokenServer.Close()
+
+	file := writeCredentials(assert, dir, credentialsFile{
+		Type:         "service_account",
+		ClientEmail:  "noms@example.iam.gserviceaccount.com",
+		PrivateKeyID: "key1",
+		PrivateKey:   string(keyPEM),
+		TokenURI:     tokenServer.URL,
+	})
+	client, err := NewClient(file, DevStorageScope)
+	assert.NoError(err)
+
+	server := echoServer()
+	defer server.Close()
+	assert.Equal("Bearer token1", get(assert, client, server.URL))
+	// The token is reused until it's about to expire.
+	assert.Equal("Bearer token1", get(assert, client, server.URL))
+	assert.Equal(1, tokens)
+}
+
+func TestAuthorizedUser(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "googleauth")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("refresh_token", r.FormValue("grant_type"))
+		assert.Equal("id", r.FormValue("client_id"))
+		assert.Equal("secret", r.FormValue("client_secret"))
+		if r.FormValue("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Tokens which are about to expire are got again each time.
+		fmt.Fprint(w, `{"access_token": "user token", "expires_in": 1}`)
+	}))
+	defer tokenServer.Close()
+
+	server := echoServer()
+	defer server.Close()
+
+	f := credentialsFile{Type: "authorized_user", ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh", TokenURI: tokenServer.URL}
+	client, err := NewClient(writeCredentials(assert, dir, f), DevStorageScope)
+	assert.NoError(err)
+	assert.Equal("Bearer user token", get(assert, client, server.URL))
+
+	// The default credentials are used if there's no key file.
+	f.RefreshToken = "expired"
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeCredentials(assert, dir, f))
+	defer os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+	client, err = NewClient("", DevStorageScope)
+	assert.NoError(err)
+	_, err = client.Get(server.URL)
+	assert.Error(err)
+}
+
+func TestBadCredentials(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "googleauth")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewClient(filepath.Join(dir, "missing.json"), DevStorageScope)
+	assert.Error(err)
+	_, err = NewClient(writeCredentials(assert, dir, credentialsFile{Type: "service_account", PrivateKey: "nope"}), DevStorageScope)
+	assert.Error(err)
+	_, err = NewClient(writeCredentials(assert, dir, credentialsFile{Type: "external_account"}), DevStorageScope)
+	assert.Error(err)
+}
+
+func TestStaticClient(t *testing.T) {
+	assert := assert.New(t)
+	server := echoServer()
+	defer server.Close()
+	assert.Equal("Bearer t0ken", get(assert, NewStaticClient("Bearer t0ken"), server.URL))
+}
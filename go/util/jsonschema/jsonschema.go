@@ -0,0 +1,422 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package jsonschema converts Noms types to JSON Schema documents and back.
+//
+// The generated documents use JSON Schema draft-07. Noms kinds that have no
+// JSON equivalent are annotated with an "x-noms-kind" keyword so that FromType
+// followed by ToType returns the original type:
+//
+//  - Bool, Number and String map to boolean, number and string.
+//  - Blob maps to a base64 encoded string.
+//  - List<T> maps to an array of T, Set<T> to an array of T with unique items.
+//  - Map<String, V> maps to an object whose additional properties are V. Maps
+//    with other key types map to an array of [key, value] pairs.
+//  - Ref<T> maps to a string holding the hash of the target.
+//  - Structs map to objects. Non optional fields are required. Named structs
+//    are placed in "definitions" and referenced with "$ref", which is also how
+//    cyclic types are represented.
+//  - Unions map to "anyOf" and Value maps to the empty schema.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	// SchemaVersion is the JSON Schema draft used by the generated documents.
+	SchemaVersion = "http://json-schema.org/draft-07/schema#"
+
+	kindKeyword   = "x-noms-kind"
+	targetKeyword = "x-noms-target"
+	defsPrefix    = "#/definitions/"
+)
+
+// Schema is a JSON Schema document, or a sub schema of one.
+type Schema map[string]interface{}
+
+// FromType returns a JSON Schema document describing the values of type t.
+func FromType(t *types.Type) (Schema, error) {
+	enc := &schemaEncoder{
+		defs: Schema{},
+		keys: map[hash.Hash]string{},
+	}
+	s, err := enc.encode(t)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := Schema{"$schema": SchemaVersion}
+	for k, v := range s {
+		doc[k] = v
+	}
+	if len(enc.defs) > 0 {
+		doc["definitions"] = enc.defs
+	}
+	return doc, nil
+}
+
+// FromTypeJSON is like FromType but returns the document serialized as JSON.
+func FromTypeJSON(t *types.Type) ([]byte, error) {
+	s, err := FromType(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+type structEntry struct {
+	name, key string
+}
+
+type schemaEncoder struct {
+	defs  Schema
+	keys  map[hash.Hash]string
+	stack []structEntry
+}
+
+func (enc *schemaEncoder) encode(t *types.Type) (Schema, error) {
+	switch t.TargetKind() {
+	case types.BoolKind:
+		return Schema{"type": "boolean"}, nil
+	case types.NumberKind:
+		return Schema{"type": "number"}, nil
+	case types.StringKind:
+		return Schema{"type": "string"}, nil
+	case types.BlobKind:
+		return Schema{"type": "string", "contentEncoding": "base64", kindKeyword: "Blob"}, nil
+	case types.ValueKind:
+		return Schema{}, nil
+	case types.TypeKind:
+		return Schema{"type": "string", kindKeyword: "Type"}, nil
+	case types.ListKind, types.SetKind, types.RefKind:
+		elem, err := enc.encode(t.Desc.(types.CompoundDesc).ElemTypes[0])
+		if err != nil {
+			return nil, err
+		}
+		switch t.TargetKind() {
+		case types.ListKind:
+			return Schema{"type": "array", "items": elem}, nil
+		case types.SetKind:
+			return Schema{"type": "array", "items": elem, "uniqueItems": true, kindKeyword: "Set"}, nil
+		default:
+			return Schema{"type": "string", kindKeyword: "Ref", targetKeyword: elem}, nil
+		}
+	case types.MapKind:
+		elemTypes := t.Desc.(types.CompoundDesc).ElemTypes
+		key, err := enc.encode(elemTypes[0])
+		if err != nil {
+			return nil, err
+		}
+		val, err := enc.encode(elemTypes[1])
+		if err != nil {
+			return nil, err
+		}
+		if elemTypes[0].TargetKind() == types.StringKind {
+			return Schema{"type": "object", "additionalProperties": val}, nil
+		}
+		return Schema{
+			"type": "array",
+			"items": Schema{
+				"type":     "array",
+				"items":    []interface{}{key, val},
+				"minItems": 2,
+				"maxItems": 2,
+			},
+			kindKeyword: "Map",
+		}, nil
+	case types.UnionKind:
+		elemTypes := t.Desc.(types.CompoundDesc).ElemTypes
+		if len(elemTypes) == 0 {
+			return Schema{"not": Schema{}}, nil
+		}
+		anyOf := make([]interface{}, len(elemTypes))
+		for i, et := range elemTypes {
+			s, err := enc.encode(et)
+			if err != nil {
+				return nil, err
+			}
+			anyOf[i] = s
+		}
+		return Schema{"anyOf": anyOf}, nil
+	case types.CycleKind:
+		name := string(t.Desc.(types.CycleDesc))
+		for i := len(enc.stack) - 1; i >= 0; i-- {
+			if enc.stack[i].name == name {
+				return Schema{"$ref": defsPrefix + enc.stack[i].key}, nil
+			}
+		}
+		return nil, fmt.Errorf("Unresolved cycle to struct %s", name)
+	case types.StructKind:
+		return enc.encodeStruct(t)
+	}
+	return nil, fmt.Errorf("Unsupported type %s", t.Describe())
+}
+
+func (enc *schemaEncoder) encodeStruct(t *types.Type) (Schema, error) {
+	desc := t.Desc.(types.StructDesc)
+
+	var key string
+	if desc.Name != "" {
+		h := t.Hash()
+		if k, ok := enc.keys[h]; ok {
+			return Schema{"$ref": defsPrefix + k}, nil
+		}
+		key = desc.Name
+		for i := 2; enc.defs[key] != nil; i++ {
+			key = fmt.Sprintf("%s_%d", desc.Name, i)
+		}
+		enc.keys[h] = key
+		// Reserve the key while the fields are encoded.
+		enc.defs[key] = Schema{}
+		enc.stack = append(enc.stack, structEntry{desc.Name, key})
+		defer func() {
+			enc.stack = enc.stack[:len(enc.stack)-1]
+		}()
+	}
+
+	props := Schema{}
+	required := []interface{}{}
+	var err error
+	desc.IterFields(func(name string, ft *types.Type, optional bool) {
+		if err != nil {
+			return
+		}
+		var fs Schema
+		fs, err = enc.encode(ft)
+		props[name] = fs
+		if !optional {
+			required = append(required, name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	if desc.Name == "" {
+		return s, nil
+	}
+	s["title"] = desc.Name
+	enc.defs[key] = s
+	return Schema{"$ref": defsPrefix + key}, nil
+}
+
+// ToType converts a JSON Schema document to a Noms type. It understands the
+// documents generated by FromType as well as plain schemas using the same
+// subset of JSON Schema. Objects without a title become unnamed structs.
+func ToType(doc Schema) (*types.Type, error) {
+	dec := &schemaDecoder{}
+	if defs, ok := doc["definitions"].(map[string]interface{}); ok {
+		dec.defs = defs
+	} else if defs, ok := doc["definitions"].(Schema); ok {
+		dec.defs = defs
+	}
+	return dec.decode(doc)
+}
+
+// ToTypeJSON is like ToType but takes a serialized JSON document.
+func ToTypeJSON(data []byte) (*types.Type, error) {
+	var doc Schema
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return ToType(doc)
+}
+
+type schemaDecoder struct {
+	defs map[string]interface{}
+
+	// stack holds the definition keys and struct names of the structs
+	// currently being decoded, so that references back to them become cycles.
+	stack []structEntry
+}
+
+func asSchema(v interface{}) (Schema, bool) {
+	switch v := v.(type) {
+	case Schema:
+		return v, true
+	case map[string]interface{}:
+		return Schema(v), true
+	}
+	return nil, false
+}
+
+func (dec *schemaDecoder) decodeSub(v interface{}) (*types.Type, error) {
+	s, ok := asSchema(v)
+	if !ok {
+		return nil, fmt.Errorf("Expected schema object, got %v", v)
+	}
+	return dec.decode(s)
+}
+
+func (dec *schemaDecoder) decode(s Schema) (*types.Type, error) {
+	if ref, ok := s["$ref"].(string); ok {
+		return dec.decodeRef(ref)
+	}
+
+	for _, k := range []string{"anyOf", "oneOf"} {
+		if alts, ok := s[k].([]interface{}); ok {
+			ts := make([]*types.Type, len(alts))
+			for i, a := range alts {
+				t, err := dec.decodeSub(a)
+				if err != nil {
+					return nil, err
+				}
+				ts[i] = t
+			}
+			return types.MakeUnionType(ts...), nil
+		}
+	}
+	if _, ok := s["not"]; ok {
+		return types.MakeUnionType(), nil
+	}
+
+	kind, _ := s[kindKeyword].(string)
+	switch kind {
+	case "Blob":
+		return types.BlobType, nil
+	case "Type":
+		return types.TypeType, nil
+	case "Ref":
+		target, err := dec.decodeSub(s[targetKeyword])
+		if err != nil {
+			return nil, err
+		}
+		return types.MakeRefType(target), nil
+	case "Map":
+		items, _ := asSchema(s["items"])
+		pair, ok := items["items"].([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("Map schema must have [key, value] items")
+		}
+		kt, err := dec.decodeSub(pair[0])
+		if err != nil {
+			return nil, err
+		}
+		vt, err := dec.decodeSub(pair[1])
+		if err != nil {
+			return nil, err
+		}
+		return types.MakeMapType(kt, vt), nil
+	}
+
+	typ, _ := s["type"].(string)
+	switch typ {
+	case "":
+		return types.ValueType, nil
+	case "boolean":
+		return types.BoolType, nil
+	case "number", "integer":
+		return types.NumberType, nil
+	case "string":
+		return types.StringType, nil
+	case "array":
+		var elem *types.Type
+		if items, ok := s["items"]; ok {
+			var err error
+			if elem, err = dec.decodeSub(items); err != nil {
+				return nil, err
+			}
+		} else {
+			elem = types.ValueType
+		}
+		if unique, _ := s["uniqueItems"].(bool); unique || kind == "Set" {
+			return types.MakeSetType(elem), nil
+		}
+		return types.MakeListType(elem), nil
+	case "object":
+		if _, ok := s["properties"]; !ok {
+			if ap, ok := s["additionalProperties"]; ok {
+				if vt, err := dec.decodeSub(ap); err == nil {
+					return types.MakeMapType(types.StringType, vt), nil
+				}
+			}
+		}
+		return dec.decodeStruct(s)
+	}
+	return nil, fmt.Errorf("Unsupported JSON Schema type %q", typ)
+}
+
+func (dec *schemaDecoder) decodeRef(ref string) (*types.Type, error) {
+	if !strings.HasPrefix(ref, defsPrefix) {
+		return nil, fmt.Errorf("Unsupported $ref %q", ref)
+	}
+	key := ref[len(defsPrefix):]
+	for i := len(dec.stack) - 1; i >= 0; i-- {
+		if dec.stack[i].key == key {
+			return types.MakeCycleType(dec.stack[i].name), nil
+		}
+	}
+
+	def, ok := asSchema(dec.defs[key])
+	if !ok {
+		return nil, fmt.Errorf("Missing definition for $ref %q", ref)
+	}
+	name, _ := def["title"].(string)
+	if name == "" {
+		// Cycles need a name to refer to.
+		name = key
+		def = copySchema(def)
+		def["title"] = name
+	}
+	dec.stack = append(dec.stack, structEntry{name, key})
+	defer func() {
+		dec.stack = dec.stack[:len(dec.stack)-1]
+	}()
+	return dec.decode(def)
+}
+
+func (dec *schemaDecoder) decodeStruct(s Schema) (*types.Type, error) {
+	name, _ := s["title"].(string)
+	if name != "" && !types.IsValidStructFieldName(name) {
+		return nil, fmt.Errorf("Invalid struct name %q", name)
+	}
+
+	required := map[string]bool{}
+	if req, ok := s["required"].([]interface{}); ok {
+		for _, r := range req {
+			if rs, ok := r.(string); ok {
+				required[rs] = true
+			}
+		}
+	}
+
+	props, _ := asSchema(s["properties"])
+	names := make([]string, 0, len(props))
+	for n := range props {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fields := make([]types.StructField, 0, len(names))
+	for _, n := range names {
+		if !types.IsValidStructFieldName(n) {
+			return nil, fmt.Errorf("Invalid struct field name %q", n)
+		}
+		ft, err := dec.decodeSub(props[n])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, types.StructField{Name: n, Type: ft, Optional: !required[n]})
+	}
+	return types.MakeStructType(name, fields...), nil
+}
+
+func copySchema(s Schema) Schema {
+	c := make(Schema, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
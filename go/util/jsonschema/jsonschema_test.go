@@ -0,0 +1,125 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestFromTypePrimitives(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(typ *types.Type, expected string) {
+		s, err := FromType(typ)
+		assert.NoError(err)
+		delete(s, "$schema")
+		data, err := json.Marshal(s)
+		assert.NoError(err)
+		assert.JSONEq(expected, string(data))
+	}
+
+	test(types.BoolType, `{"type": "boolean"}`)
+	test(types.NumberType, `{"type": "number"}`)
+	test(types.StringType, `{"type": "string"}`)
+	test(types.ValueType, `{}`)
+	test(types.MakeListType(types.NumberType), `{"type": "array", "items": {"type": "number"}}`)
+	test(types.MakeSetType(types.StringType), `{"type": "array", "items": {"type": "string"}, "uniqueItems": true, "x-noms-kind": "Set"}`)
+	test(types.MakeMapType(types.StringType, types.BoolType), `{"type": "object", "additionalProperties": {"type": "boolean"}}`)
+	test(types.MakeUnionType(types.NumberType, types.StringType), `{"anyOf": [{"type": "number"}, {"type": "string"}]}`)
+	test(types.MakeStructType("",
+		types.StructField{Name: "a", Type: types.NumberType},
+		types.StructField{Name: "b", Type: types.StringType, Optional: true},
+	), `{"type": "object", "properties": {"a": {"type": "number"}, "b": {"type": "string"}}, "required": ["a"]}`)
+}
+
+func TestFromTypeNamedAndCyclic(t *testing.T) {
+	assert := assert.New(t)
+
+	typ := types.MakeStructType("Node",
+		types.StructField{Name: "value", Type: types.NumberType},
+		types.StructField{Name: "children", Type: types.MakeListType(types.MakeCycleType("Node"))},
+	)
+	data, err := FromTypeJSON(typ)
+	assert.NoError(err)
+	assert.JSONEq(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"$ref": "#/definitions/Node",
+		"definitions": {
+			"Node": {
+				"title": "Node",
+				"type": "object",
+				"properties": {
+					"children": {"type": "array", "items": {"$ref": "#/definitions/Node"}},
+					"value": {"type": "number"}
+				},
+				"required": ["children", "value"]
+			}
+		}
+	}`, string(data))
+}
+
+func TestRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(typ *types.Type) {
+		data, err := FromTypeJSON(typ)
+		assert.NoError(err)
+		actual, err := ToTypeJSON(data)
+		assert.NoError(err)
+		assert.True(typ.Equals(actual), "%s != %s", typ.Describe(), actual.Describe())
+	}
+
+	test(types.BoolType)
+	test(types.BlobType)
+	test(types.TypeType)
+	test(types.ValueType)
+	test(types.MakeRefType(types.NumberType))
+	test(types.MakeSetType(types.NumberType))
+	test(types.MakeMapType(types.NumberType, types.StringType))
+	test(types.MakeMapType(types.StringType, types.MakeListType(types.BoolType)))
+	test(types.MakeUnionType())
+	test(types.MakeStructType("Person",
+		types.StructField{Name: "name", Type: types.StringType},
+		types.StructField{Name: "age", Type: types.NumberType, Optional: true},
+		types.StructField{Name: "address", Type: types.MakeStructType("",
+			types.StructField{Name: "city", Type: types.StringType},
+		)},
+	))
+	test(types.MakeStructType("Node",
+		types.StructField{Name: "value", Type: types.NumberType},
+		types.StructField{Name: "next", Type: types.MakeUnionType(types.MakeCycleType("Node"), types.BoolType)},
+	))
+}
+
+func TestToTypePlainSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	typ, err := ToTypeJSON([]byte(`{
+		"type": "object",
+		"title": "Item",
+		"properties": {
+			"id": {"type": "integer"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"attrs": {"type": "object", "additionalProperties": {"type": "string"}}
+		},
+		"required": ["id"]
+	}`))
+	assert.NoError(err)
+	assert.True(types.MakeStructType("Item",
+		types.StructField{Name: "attrs", Type: types.MakeMapType(types.StringType, types.StringType), Optional: true},
+		types.StructField{Name: "id", Type: types.NumberType},
+		types.StructField{Name: "tags", Type: types.MakeListType(types.StringType), Optional: true},
+	).Equals(typ))
+
+	_, err = ToTypeJSON([]byte(`{"type": "null"}`))
+	assert.Error(err)
+
+	_, err = ToTypeJSON([]byte(`{"$ref": "#/definitions/Missing"}`))
+	assert.Error(err)
+}
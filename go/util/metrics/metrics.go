@@ -0,0 +1,81 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package metrics counts and times the work noms does, e.g. the chunks read
+// from stores and the commits made, for programs which use noms to report.
+// It's disabled until a Collector is set, and costs next to nothing until
+// then.
+//
+// The metrics are:
+//
+//	nbs_chunk_reads, nbs_chunk_writes: chunks read from and put to NBS stores
+//	nbs_flush: the time NBS stores take to persist their chunks and root
+//	nbs_conjoin: the time NBS stores take to conjoin their tables
+//	chunk_cache_hits, chunk_cache_misses: reads of caching chunk stores
+//	datas_commit: the time commits to Databases take
+//	datas_pull_chunks, datas_pull_bytes: the chunks and bytes read by Pull
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Collector receives the metrics, e.g. to keep them in a Registry, or to
+// report them to another metrics system.
+type Collector interface {
+	// Add adds n to the counter named name.
+	Add(name string, n int64)
+	// Observe records that an operation named name took d.
+	Observe(name string, d time.Duration)
+}
+
+// collectorHolder wraps the Collector in collector, since atomic.Values can't
+// hold nil.
+type collectorHolder struct {
+	c Collector
+}
+
+var collector atomic.Value
+
+func init() {
+	collector.Store(collectorHolder{})
+}
+
+// SetCollector sets the Collector which receives the metrics, or disables
+// them if c is nil.
+func SetCollector(c Collector) {
+	collector.Store(collectorHolder{c})
+}
+
+// Enabled returns whether there is a Collector to receive the metrics.
+func Enabled() bool {
+	return getCollector() != nil
+}
+
+func getCollector() Collector {
+	return collector.Load().(collectorHolder).c
+}
+
+// Add adds n to the counter named name, if metrics are enabled.
+func Add(name string, n int64) {
+	if c := getCollector(); c != nil {
+		c.Add(name, n)
+	}
+}
+
+func noop() {}
+
+// Timer starts timing an operation named name, and returns the func which
+// stops timing it, so that it can be used like:
+//
+//	defer metrics.Timer("nbs_flush")()
+func Timer(name string) func() {
+	c := getCollector()
+	if c == nil {
+		return noop
+	}
+	start := time.Now()
+	return func() { c.Observe(name, time.Since(start)) }
+}
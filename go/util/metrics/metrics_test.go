@@ -0,0 +1,63 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestDisabled(t *testing.T) {
+	assert := assert.New(t)
+	assert.False(Enabled())
+	Add("reads", 1)
+	Timer("flush")()
+}
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRegistry()
+	SetCollector(r)
+	defer SetCollector(nil)
+	assert.True(Enabled())
+
+	Add("reads", 2)
+	Add("reads", 3)
+	Add("writes", 1)
+	stop := Timer("flush")
+	time.Sleep(time.Millisecond)
+	stop()
+	r.Observe("flush", time.Second)
+
+	assert.Equal(int64(5), r.Counter("reads"))
+	assert.Equal(int64(0), r.Counter("missing"))
+	flush := r.Timing("flush")
+	assert.Equal(int64(2), flush.Count)
+	assert.True(flush.Total > time.Second)
+
+	w := httptest.NewRecorder()
+	r.Observe("commit", 1500*time.Millisecond)
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(w.Body.String(), `# TYPE noms_reads_total counter
+noms_reads_total 5
+# TYPE noms_writes_total counter
+noms_writes_total 1
+# TYPE noms_commit_seconds summary
+noms_commit_seconds_sum 1.5
+noms_commit_seconds_count 1
+# TYPE noms_flush_seconds summary
+`)
+
+	r.Publish("noms_test")
+	var values map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(expvar.Get("noms_test").String()), &values))
+	assert.Equal(float64(5), values["reads"])
+	assert.Equal(map[string]interface{}{"count": float64(1), "seconds": 1.5}, values["commit"])
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry is a Collector which keeps the totals of the metrics, which it
+// serves to Prometheus or publishes with expvar.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	timings  map[string]Timing
+}
+
+// Timing is the number of times an operation was timed, and the total time
+// it took.
+type Timing struct {
+	Count int64
+	Total time.Duration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{counters: map[string]int64{}, timings: map[string]Timing{}}
+}
+
+func (r *Registry) Add(name string, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name] += n
+}
+
+func (r *Registry) Observe(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.timings[name]
+	t.Count++
+	t.Total += d
+	r.timings[name] = t
+}
+
+// Counter returns the value of the counter named name.
+func (r *Registry) Counter(name string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[name]
+}
+
+// Timing returns the timing of the operation named name.
+func (r *Registry) Timing(name string) Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timings[name]
+}
+
+// Values returns the counters and timings by name, the timings as a map of
+// their count and total seconds.
+func (r *Registry) Values() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := make(map[string]interface{}, len(r.counters)+len(r.timings))
+	for name, n := range r.counters {
+		values[name] = n
+	}
+	for name, t := range r.timings {
+		values[name] = map[string]interface{}{"count": t.Count, "seconds": t.Total.Seconds()}
+	}
+	return values
+}
+
+// Publish publishes the Values of r with expvar as name, so that they're
+// served by its /debug/vars handler. Like expvar.Publish, it panics if name
+// has been published already.
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return r.Values() }))
+}
+
+// ServeHTTP writes the metrics in the text format that Prometheus scrapes,
+// the counters as noms_<name>_total and the timings as summaries named
+// noms_<name>_seconds.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	names := make([]string, 0, len(r.counters))
+	for name := range r.counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "# TYPE noms_%s_total counter\n", name)
+		fmt.Fprintf(w, "noms_%s_total %d\n", name, r.counters[name])
+	}
+
+	names = names[:0]
+	for name := range r.timings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := r.timings[name]
+		fmt.Fprintf(w, "# TYPE noms_%s_seconds summary\n", name)
+		fmt.Fprintf(w, "noms_%s_seconds_sum %g\n", name, t.Total.Seconds())
+		fmt.Fprintf(w, "noms_%s_seconds_count %d\n", name, t.Count)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package retry retries operations which conflict with concurrent ones, such
+// as commits to a dataset.
+package retry
+
+import (
+	"math/rand"
+	"time"
+)
+
+// maxBackoff is the longest Do backs off for between calls.
+const maxBackoff = 64 * time.Millisecond
+
+// Do calls f, and calls it again while it returns true, up to retries more
+// times. Between calls it backs off for a random time, up to a limit which
+// doubles with each call until it's 64ms, so that contending processes don't
+// keep colliding. Do returns false if f returned true on its last call.
+func Do(retries int, f func() (retry bool)) bool {
+	for i := 0; i <= retries; i++ {
+		if !f() {
+			return true
+		}
+		if i < retries {
+			time.Sleep(time.Duration(rand.Int63n(int64(backoff(i)))))
+		}
+	}
+	return false
+}
+
+// backoff returns the limit of the time Do backs off for after the call i.
+func backoff(i int) time.Duration {
+	if i > 6 {
+		return maxBackoff
+	}
+	return time.Millisecond << uint(i)
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	assert.True(Do(3, func() bool {
+		calls++
+		return calls < 3
+	}))
+	assert.Equal(3, calls)
+
+	calls = 0
+	assert.False(Do(3, func() bool {
+		calls++
+		return true
+	}))
+	assert.Equal(4, calls)
+
+	calls = 0
+	assert.True(Do(0, func() bool {
+		calls++
+		return false
+	}))
+	assert.Equal(1, calls)
+}
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(time.Millisecond, backoff(0))
+	assert.Equal(8*time.Millisecond, backoff(3))
+	assert.Equal(maxBackoff, backoff(6))
+	assert.Equal(maxBackoff, backoff(100))
+}
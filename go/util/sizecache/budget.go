@@ -0,0 +1,117 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sizecache
+
+import "sync"
+
+// Budget limits the total memory used by several SizeCaches, and by other
+// buffers which account for their sizes with Reserve and Release, to Max
+// bytes. Each SizeCache still expires its own entries when adding one would
+// exceed the Budget, so a cache can't grow at the expense of the others'
+// entries, only into memory they don't use. A share of the Budget can be set
+// aside for one of them with SetShare.
+type Budget struct {
+	max    uint64
+	mu     sync.Mutex
+	used   uint64
+	byName map[string]uint64
+	shares map[string]uint64
+}
+
+// BudgetStats is the accounting of a Budget: its Max, the bytes Used of it,
+// and the bytes used by each of the caches and buffers sharing it, by name.
+type BudgetStats struct {
+	Max    uint64
+	Used   uint64
+	ByName map[string]uint64
+}
+
+// NewBudget returns a Budget of max bytes.
+func NewBudget(max uint64) *Budget {
+	return &Budget{max: max, byName: map[string]uint64{}, shares: map[string]uint64{}}
+}
+
+// SetShare sets aside size bytes of b for the cache or buffer called name:
+// the others can't reserve the part of it name doesn't use, so that e.g.
+// caches which fill b don't leave a buffer without room. A nil Budget has no
+// shares.
+func (b *Budget) SetShare(name string, size uint64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.shares[name] = size
+}
+
+// Reserve accounts for size more bytes used by the cache or buffer called
+// name, and returns true, unless that would exceed the Budget, in which case
+// nothing is reserved and it returns false. A nil Budget has no limit.
+func (b *Budget) Reserve(name string, size uint64) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	available := b.max - b.used
+	for other, share := range b.shares {
+		if used := b.byName[other]; other != name && used < share {
+			if share-used > available {
+				return false
+			}
+			available -= share - used
+		}
+	}
+	if size > available {
+		return false
+	}
+	b.used += size
+	b.byName[name] += size
+	return true
+}
+
+// Release accounts for size bytes reserved by name which are no longer used.
+func (b *Budget) Release(name string, size uint64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= size
+	b.byName[name] -= size
+}
+
+// Stats returns the current accounting of b.
+func (b *Budget) Stats() BudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	byName := make(map[string]uint64, len(b.byName))
+	for name, size := range b.byName {
+		byName[name] = size
+	}
+	return BudgetStats{b.max, b.used, byName}
+}
+
+var (
+	budgetMu     sync.Mutex
+	globalBudget *Budget
+)
+
+// SetBudget makes b the Budget shared by the value cache and pending writes of
+// ValueStores, and the table index caches of NBS stores, created after it's
+// called. A nil b, the default, leaves each of them limited only by its own
+// size.
+func SetBudget(b *Budget) {
+	budgetMu.Lock()
+	defer budgetMu.Unlock()
+	globalBudget = b
+}
+
+// GetBudget returns the Budget set by SetBudget.
+func GetBudget() *Budget {
+	budgetMu.Lock()
+	defer budgetMu.Unlock()
+	return globalBudget
+}
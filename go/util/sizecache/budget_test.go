@@ -0,0 +1,71 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package sizecache
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBudget(1000)
+	c1, c2 := NewWithBudget(800, b, "c1"), NewWithBudget(800, b, "c2")
+
+	for i := 0; i < 4; i++ {
+		c1.Add(i, 200, i)
+	}
+	assert.Equal(BudgetStats{1000, 800, map[string]uint64{"c1": 800}}, b.Stats())
+
+	// c2 can only use what c1 leaves, so it expires its own entries.
+	c2.Add("a", 200, "a")
+	c2.Add("b", 200, "b")
+	_, ok := c2.Get("a")
+	assert.False(ok)
+	assert.Equal(BudgetStats{1000, 1000, map[string]uint64{"c1": 800, "c2": 200}}, b.Stats())
+
+	// Entries which don't fit even once all of c2's are expired aren't added.
+	c2.Add("c", 300, "c")
+	_, ok = c2.Get("c")
+	assert.False(ok)
+	assert.Equal(uint64(800), b.Stats().Used)
+
+	c1.Drop(0)
+	assert.True(b.Reserve("buffer", 400))
+	assert.False(b.Reserve("buffer", 1))
+	b.Release("buffer", 400)
+	c1.Purge()
+	assert.Equal(BudgetStats{1000, 0, map[string]uint64{"c1": 0, "c2": 0, "buffer": 0}}, b.Stats())
+
+	var nilBudget *Budget
+	assert.True(nilBudget.Reserve("any", 1<<62))
+}
+
+func TestBudgetShare(t *testing.T) {
+	assert := assert.New(t)
+	b := NewBudget(1000)
+	b.SetShare("buffer", 400)
+	c := NewWithBudget(1000, b, "c")
+
+	for i := 0; i < 10; i++ {
+		c.Add(i, 100, i)
+	}
+	assert.Equal(uint64(600), b.Stats().ByName["c"])
+
+	assert.True(b.Reserve("buffer", 300))
+	assert.True(b.Reserve("buffer", 100))
+	assert.False(b.Reserve("buffer", 1))
+
+	// Beyond its share, the buffer competes for what's left like the others.
+	c.Drop(9)
+	assert.True(b.Reserve("buffer", 100))
+	b.Release("buffer", 400)
+	assert.False(b.Reserve("other", 101))
+	assert.True(b.Reserve("other", 100))
+
+	var nilBudget *Budget
+	nilBudget.SetShare("any", 1)
+}
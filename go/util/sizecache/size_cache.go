@@ -27,15 +27,80 @@ type SizeCache struct {
 	mu        sync.Mutex
 	lru       list.List
 	cache     map[interface{}]sizeCacheEntry
+	expireCb  func(key interface{})
+	budget    *Budget
+	name      string
+	policy    Policy
+	hits      uint64
+	misses    uint64
+}
+
+// Policy is the order in which a SizeCache expires its entries.
+type Policy int
+
+const (
+	// LRU expires the least recently added or gotten entries first.
+	LRU Policy = iota
+	// FIFO expires the least recently added entries first, so that entries
+	// which are gotten often don't stay in the cache longer, e.g. for scans.
+	FIFO
+)
+
+func (p Policy) String() string {
+	switch p {
+	case LRU:
+		return "lru"
+	case FIFO:
+		return "fifo"
+	}
+	return "unknown"
+}
+
+// Stats are the counts of a SizeCache's Gets which found their key, Hits, and
+// which didn't, Misses, and the number and total size of its entries.
+type Stats struct {
+	Hits, Misses uint64
+	Entries      int
+	Size         uint64
+	MaxSize      uint64
 }
 
 func New(maxSize uint64) *SizeCache {
-	return &SizeCache{maxSize: maxSize, cache: map[interface{}]sizeCacheEntry{}}
+	return NewWithExpireCallback(maxSize, nil)
+}
+
+// NewWithExpireCallback is like New, but calls expireCb, if it isn't nil, with
+// the key of each entry that's expired to keep the cache below maxSize.
+func NewWithExpireCallback(maxSize uint64, expireCb func(key interface{})) *SizeCache {
+	return &SizeCache{maxSize: maxSize, cache: map[interface{}]sizeCacheEntry{}, expireCb: expireCb}
+}
+
+// NewWithBudget is like New, but the entries of the cache are also accounted
+// to b as name, and entries are expired to keep within b as well as maxSize.
+// Entries which don't fit in b even after expiring all others aren't added.
+func NewWithBudget(maxSize uint64, b *Budget, name string) *SizeCache {
+	c := New(maxSize)
+	c.budget, c.name = b, name
+	return c
+}
+
+// SetPolicy sets the order in which c expires its entries, LRU unless it's
+// set. It must be called before c is used.
+func (c *SizeCache) SetPolicy(p Policy) {
+	c.policy = p
+}
+
+// Stats returns the Stats of c.
+func (c *SizeCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{c.hits, c.misses, len(c.cache), c.totalSize, c.maxSize}
 }
 
 // entry() checks if the value is in the cache. If not in the cache, it returns an
 // empty sizeCacheEntry and false. It it is in the cache, it moves it to
-// to the back of lru and returns the entry and true.
+// to the back of lru, unless the policy is FIFO, and returns the entry and
+// true.
 // Callers should have locked down the |c| with a call to c.mu.Lock() before
 // calling this entry().
 func (c *SizeCache) entry(key interface{}) (sizeCacheEntry, bool) {
@@ -43,7 +108,9 @@ func (c *SizeCache) entry(key interface{}) (sizeCacheEntry, bool) {
 	if !ok {
 		return sizeCacheEntry{}, false
 	}
-	c.lru.MoveToBack(entry.lruEntry)
+	if c.policy == LRU {
+		c.lru.MoveToBack(entry.lruEntry)
+	}
 	return entry, true
 }
 
@@ -55,8 +122,10 @@ func (c *SizeCache) Get(key interface{}) (interface{}, bool) {
 	defer c.mu.Unlock()
 
 	if entry, ok := c.entry(key); ok {
+		c.hits++
 		return entry.value, true
 	}
+	c.misses++
 	return nil, false
 }
 
@@ -74,25 +143,41 @@ func (c *SizeCache) Add(key interface{}, size uint64, value interface{}) {
 			return
 		}
 
+		for !c.budget.Reserve(c.name, size) {
+			if c.lru.Len() == 0 {
+				return
+			}
+			c.expire(c.lru.Front())
+		}
 		newEl := c.lru.PushBack(key)
 		ce := sizeCacheEntry{size: size, lruEntry: newEl, value: value}
 		c.cache[key] = ce
 		c.totalSize += ce.size
 		for el := c.lru.Front(); el != nil && c.totalSize > c.maxSize; {
-			key1 := el.Value
-			ce, ok := c.cache[key1]
-			if !ok {
-				d.Panic("SizeCache is missing expected value")
-			}
 			next := el.Next()
-			delete(c.cache, key1)
-			c.totalSize -= ce.size
-			c.lru.Remove(el)
+			c.expire(el)
 			el = next
 		}
 	}
 }
 
+// expire removes the entry of el from the cache. Callers should have locked
+// down |c|.
+func (c *SizeCache) expire(el *list.Element) {
+	key := el.Value
+	ce, ok := c.cache[key]
+	if !ok {
+		d.Panic("SizeCache is missing expected value")
+	}
+	delete(c.cache, key)
+	c.totalSize -= ce.size
+	c.budget.Release(c.name, ce.size)
+	c.lru.Remove(el)
+	if c.expireCb != nil {
+		c.expireCb(key)
+	}
+}
+
 // Drop will remove the element associated with the given key from the cache.
 func (c *SizeCache) Drop(key interface{}) {
 	c.mu.Lock()
@@ -100,7 +185,20 @@ func (c *SizeCache) Drop(key interface{}) {
 
 	if entry, ok := c.entry(key); ok {
 		c.totalSize -= entry.size
+		c.budget.Release(c.name, entry.size)
 		c.lru.Remove(entry.lruEntry)
 		delete(c.cache, key)
 	}
 }
+
+// Purge removes all the entries from the cache, returning the memory they
+// used to its Budget.
+func (c *SizeCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.budget.Release(c.name, c.totalSize)
+	c.totalSize = 0
+	c.lru.Init()
+	c.cache = map[interface{}]sizeCacheEntry{}
+}
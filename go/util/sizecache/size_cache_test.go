@@ -129,6 +129,24 @@ func TestTooLargeValue(t *testing.T) {
 	assert.False(ok)
 }
 
+func TestExpireCallback(t *testing.T) {
+	assert := assert.New(t)
+
+	expired := []string{}
+	c := NewWithExpireCallback(1024, func(key interface{}) {
+		expired = append(expired, key.(string))
+	})
+	c.Add("data1", 500, "data1")
+	c.Add("data2", 500, "data2")
+	c.Drop("data2")
+	c.Add("data3", 500, "data3")
+	assert.Empty(expired)
+
+	c.Add("data4", 500, "data4")
+	c.Add("data5", 1000, "data5")
+	assert.Equal([]string{"data1", "data3", "data4"}, expired)
+}
+
 func TestZeroSizeCache(t *testing.T) {
 	assert := assert.New(t)
 
@@ -137,3 +155,32 @@ func TestZeroSizeCache(t *testing.T) {
 	_, ok := c.Get(hashFromString("data1"))
 	assert.False(ok)
 }
+
+func TestFIFOPolicy(t *testing.T) {
+	assert := assert.New(t)
+	c := New(300)
+	c.SetPolicy(FIFO)
+	c.Add("a", 100, 1)
+	c.Add("b", 100, 2)
+	c.Add("c", 100, 3)
+	_, ok := c.Get("a")
+	assert.True(ok)
+
+	// Getting a didn't keep it from being expired first.
+	c.Add("d", 100, 4)
+	_, ok = c.Get("a")
+	assert.False(ok)
+	_, ok = c.Get("b")
+	assert.True(ok)
+}
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+	c := New(300)
+	c.Add("a", 100, 1)
+	c.Add("b", 50, 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("x")
+	assert.Equal(Stats{Hits: 2, Misses: 1, Entries: 2, Size: 150, MaxSize: 300}, c.Stats())
+}
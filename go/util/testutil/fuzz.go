@@ -0,0 +1,76 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package testutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// FuzzDecode is a go-fuzz (https://github.com/dvyukov/go-fuzz) entry point
+// for the codec. It decodes data as the chunk of a value, and panics if
+// encoding the value again doesn't give back data. It returns 1 if data is a
+// value, so that go-fuzz prefers it, and 0 if decoding failed with a
+// d.WrappedError. Other panics while decoding, e.g. reading past the end of
+// truncated data, are crashes for go-fuzz to report. Seed go-fuzz with a
+// corpus written by WriteCorpus:
+//
+//	func Fuzz(data []byte) int { return testutil.FuzzDecode(data) }
+func FuzzDecode(data []byte) int {
+	if len(data) == 0 {
+		return 0
+	}
+	vs := types.NewTestValueStore()
+	var v types.Value
+	if err := d.Try(func() { v = types.DecodeFromBytes(data, vs) }); err != nil {
+		return 0
+	}
+	if encoded := types.EncodeValue(v, nil).Data(); !bytes.Equal(data, encoded) {
+		d.Panic("%s was decoded from %x, but encodes to %x", types.EncodedValueMaxLines(v, 10), data, encoded)
+	}
+	return 1
+}
+
+// WriteCorpus writes the chunks of n values generated by NewGenerator(seed,
+// opts) to files in dir named by their hashes, as a corpus for FuzzDecode.
+// The chunks of values which are too big for one chunk, such as long lists,
+// are written too.
+func WriteCorpus(dir string, seed int64, n int, opts Options) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	cs := &corpusStore{chunks.NewMemoryStore(), dir, nil}
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	defer vs.Close()
+	for _, v := range NewGenerator(seed, opts).Values(n) {
+		vs.Flush(vs.WriteValue(v).TargetHash())
+	}
+	return cs.err
+}
+
+// corpusStore writes the chunks put in it to files in dir.
+type corpusStore struct {
+	*chunks.MemoryStore
+	dir string
+	err error
+}
+
+func (cs *corpusStore) Put(c chunks.Chunk) {
+	if err := ioutil.WriteFile(filepath.Join(cs.dir, c.Hash().String()), c.Data(), 0666); err != nil && cs.err == nil {
+		cs.err = err
+	}
+}
+
+func (cs *corpusStore) PutMany(chunks []chunks.Chunk) {
+	for _, c := range chunks {
+		cs.Put(c)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package testutil generates random Noms values for property tests of code
+// which uses Noms, and has entry points for fuzzing the codec with them.
+//
+// Values are generated by a Generator from a seed, so the same seed and
+// Options always generate the same values, and a failing test can be repeated
+// by logging its seed:
+//
+//	g := testutil.NewGenerator(seed, testutil.Options{MaxDepth: 3})
+//	for i := 0; i < 100; i++ {
+//	  v := g.Value()
+//	  ...
+//	}
+package testutil
+
+import (
+	"bytes"
+	"math/rand"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Options configure the shape and size of the values a Generator generates.
+// Zero fields are given the defaults below.
+type Options struct {
+	// Kinds are the kinds of values to generate, of BoolKind, NumberKind,
+	// StringKind, BlobKind, ListKind, MapKind, SetKind and StructKind. The
+	// default is all of them.
+	Kinds []types.NomsKind
+
+	// MaxDepth is how deeply lists, maps, sets and structs can be nested. At
+	// the deepest level only primitives and blobs are generated. The default
+	// is 2.
+	MaxDepth int
+
+	// MaxLen is the most elements of a list, map or set, or fields of a
+	// struct. The default is 10.
+	MaxLen int
+
+	// MaxBytes is the longest string or blob. The default is 32.
+	MaxBytes int
+}
+
+var allKinds = []types.NomsKind{
+	types.BoolKind, types.NumberKind, types.StringKind, types.BlobKind,
+	types.ListKind, types.MapKind, types.SetKind, types.StructKind,
+}
+
+// Generator generates random values. It isn't safe for concurrent use.
+type Generator struct {
+	r          *rand.Rand
+	opts       Options
+	primitives []types.NomsKind
+}
+
+// NewGenerator returns a Generator of values shaped by opts, which generates
+// the same values for the same seed.
+func NewGenerator(seed int64, opts Options) *Generator {
+	if len(opts.Kinds) == 0 {
+		opts.Kinds = allKinds
+	}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = 2
+	}
+	if opts.MaxLen == 0 {
+		opts.MaxLen = 10
+	}
+	if opts.MaxBytes == 0 {
+		opts.MaxBytes = 32
+	}
+	primitives := []types.NomsKind{}
+	for _, k := range opts.Kinds {
+		if types.IsPrimitiveKind(k) {
+			primitives = append(primitives, k)
+		}
+	}
+	if len(primitives) == 0 {
+		primitives = []types.NomsKind{types.NumberKind}
+	}
+	return &Generator{rand.New(rand.NewSource(seed)), opts, primitives}
+}
+
+// Value returns a new random value.
+func (g *Generator) Value() types.Value {
+	return g.value(g.opts.MaxDepth)
+}
+
+// Values returns n new random values.
+func (g *Generator) Values(n int) []types.Value {
+	values := make([]types.Value, n)
+	for i := range values {
+		values[i] = g.Value()
+	}
+	return values
+}
+
+func (g *Generator) value(depth int) types.Value {
+	kinds := g.opts.Kinds
+	if depth == 0 {
+		kinds = g.primitives
+	}
+	switch kinds[g.r.Intn(len(kinds))] {
+	case types.BoolKind:
+		return types.Bool(g.r.Intn(2) == 1)
+	case types.NumberKind:
+		if g.r.Intn(2) == 0 {
+			return types.Number(g.r.Int63n(1<<53) - 1<<52)
+		}
+		return types.Number(g.r.NormFloat64() * 1e6)
+	case types.StringKind:
+		return types.String(g.bytes())
+	case types.BlobKind:
+		b := make([]byte, g.r.Intn(g.opts.MaxBytes+1))
+		g.r.Read(b)
+		return types.NewBlob(bytes.NewReader(b))
+	case types.ListKind:
+		return types.NewList(g.elems(depth, false)...)
+	case types.MapKind:
+		return types.NewMap(g.elems(depth, true)...)
+	case types.SetKind:
+		return types.NewSet(g.elems(depth, false)...)
+	case types.StructKind:
+		data := types.StructData{}
+		for i, n := 0, g.r.Intn(g.opts.MaxLen+1); i < n; i++ {
+			data[g.name()] = g.value(depth - 1)
+		}
+		return types.NewStruct(g.name(), data)
+	}
+	panic("unreachable")
+}
+
+// elems returns up to MaxLen values a level deeper than depth, or as many
+// pairs of them if pairs is set.
+func (g *Generator) elems(depth int, pairs bool) []types.Value {
+	n := g.r.Intn(g.opts.MaxLen + 1)
+	if pairs {
+		n *= 2
+	}
+	values := make([]types.Value, n)
+	for i := range values {
+		values[i] = g.value(depth - 1)
+	}
+	return values
+}
+
+// bytes returns up to MaxBytes printable characters.
+func (g *Generator) bytes() []byte {
+	b := make([]byte, g.r.Intn(g.opts.MaxBytes+1))
+	for i := range b {
+		b[i] = byte(' ' + g.r.Intn('~'-' '+1))
+	}
+	return b
+}
+
+// name returns a random struct or field name.
+func (g *Generator) name() string {
+	const head = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	const tail = head + "0123456789_"
+	b := []byte{head[g.r.Intn(len(head))]}
+	for i, n := 0, g.r.Intn(8); i < n; i++ {
+		b = append(b, tail[g.r.Intn(len(tail))])
+	}
+	return string(b)
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	assert := assert.New(t)
+	opts := Options{MaxDepth: 3, MaxLen: 5}
+	a, b := NewGenerator(42, opts).Values(20), NewGenerator(42, opts).Values(20)
+	for i := range a {
+		assert.True(a[i].Equals(b[i]))
+	}
+	assert.False(types.NewList(a...).Equals(types.NewList(NewGenerator(43, opts).Values(20)...)))
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGenerator(1, Options{Kinds: []types.NomsKind{types.ListKind, types.StringKind}, MaxDepth: 2, MaxLen: 3, MaxBytes: 4})
+	var check func(v types.Value, depth int)
+	check = func(v types.Value, depth int) {
+		switch v := v.(type) {
+		case types.List:
+			assert.True(depth < 2)
+			assert.True(v.Len() <= 3)
+			v.IterAll(func(v types.Value, _ uint64) { check(v, depth+1) })
+		case types.String:
+			assert.True(len(v) <= 4)
+		default:
+			assert.Fail("unexpected kind", "%s", types.TypeOf(v).Describe())
+		}
+	}
+	for _, v := range g.Values(100) {
+		check(v, 0)
+	}
+}
+
+func TestFuzzDecodeCorpus(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "corpus")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(WriteCorpus(dir, 7, 50, Options{MaxLen: 200}))
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.NotEmpty(infos)
+	for _, info := range infos {
+		data, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		assert.NoError(err)
+		assert.Equal(1, FuzzDecode(data), info.Name())
+	}
+
+	assert.Equal(0, FuzzDecode(nil))
+}
@@ -0,0 +1,147 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package verbose
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Level is how important a log message is.
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Fields are the names and values of the details of a log message, e.g. the
+// address of the client whose request is being handled.
+type Fields map[string]interface{}
+
+// Logger is what the noms packages log messages with. Programs which use noms
+// can route its messages into their own logs with SetLogger.
+type Logger interface {
+	Log(level Level, msg string, fields Fields)
+}
+
+var (
+	loggerMu sync.RWMutex
+	logger   Logger = defaultLogger{}
+)
+
+// SetLogger sets the Logger that messages are logged with, or restores the
+// default one if l is nil. The default Logger writes debug messages to stdout
+// if Verbose(), info messages to stdout unless Quiet(), and warnings and
+// errors to stderr.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = defaultLogger{}
+	}
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	logger = l
+}
+
+// GetLogger returns the Logger that messages are logged with.
+func GetLogger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return logger
+}
+
+// WithFields returns a Logger which adds fields to the fields of the messages
+// logged with it, and logs them with l.
+func WithFields(l Logger, fields Fields) Logger {
+	return fieldsLogger{l, fields}
+}
+
+// Debug logs msg with fields at DebugLevel.
+func Debug(msg string, fields Fields) {
+	GetLogger().Log(DebugLevel, msg, fields)
+}
+
+// Info logs msg with fields at InfoLevel.
+func Info(msg string, fields Fields) {
+	GetLogger().Log(InfoLevel, msg, fields)
+}
+
+// Warn logs msg with fields at WarnLevel.
+func Warn(msg string, fields Fields) {
+	GetLogger().Log(WarnLevel, msg, fields)
+}
+
+// Error logs msg with fields at ErrorLevel.
+func Error(msg string, fields Fields) {
+	GetLogger().Log(ErrorLevel, msg, fields)
+}
+
+type fieldsLogger struct {
+	l      Logger
+	fields Fields
+}
+
+func (fl fieldsLogger) Log(level Level, msg string, fields Fields) {
+	all := make(Fields, len(fl.fields)+len(fields))
+	for k, v := range fl.fields {
+		all[k] = v
+	}
+	for k, v := range fields {
+		all[k] = v
+	}
+	fl.l.Log(level, msg, all)
+}
+
+type defaultLogger struct{}
+
+func (defaultLogger) Log(level Level, msg string, fields Fields) {
+	var w io.Writer = os.Stderr
+	switch level {
+	case DebugLevel:
+		if !Verbose() {
+			return
+		}
+		w = os.Stdout
+	case InfoLevel:
+		if Quiet() {
+			return
+		}
+		w = os.Stdout
+	}
+	fmt.Fprintln(w, formatMessage(msg, fields))
+}
+
+// formatMessage returns msg followed by fields as name=value, in order of
+// their names.
+func formatMessage(msg string, fields Fields) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg += fmt.Sprintf(" %s=%v", name, fields[name])
+	}
+	return msg
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package verbose
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+type message struct {
+	level  Level
+	msg    string
+	fields Fields
+}
+
+type testLogger struct {
+	messages []message
+}
+
+func (tl *testLogger) Log(level Level, msg string, fields Fields) {
+	tl.messages = append(tl.messages, message{level, msg, fields})
+}
+
+func TestLogger(t *testing.T) {
+	assert := assert.New(t)
+	tl := &testLogger{}
+	SetLogger(tl)
+	defer SetLogger(nil)
+	assert.Equal(tl, GetLogger())
+
+	Log("Sent %d chunks", 3)
+	Info("Listening", Fields{"port": 8000})
+	l := WithFields(GetLogger(), Fields{"remote": "1.2.3.4", "port": 1})
+	l.Log(WarnLevel, "Retrying", Fields{"port": 2})
+	Error("Failed", nil)
+
+	assert.Equal([]message{
+		{DebugLevel, "Sent 3 chunks", nil},
+		{InfoLevel, "Listening", Fields{"port": 8000}},
+		{WarnLevel, "Retrying", Fields{"remote": "1.2.3.4", "port": 2}},
+		{ErrorLevel, "Failed", nil},
+	}, tl.messages)
+
+	SetLogger(nil)
+	assert.Equal(defaultLogger{}, GetLogger())
+}
+
+func TestFormatMessage(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("Retrying", formatMessage("Retrying", nil))
+	assert.Equal("Retrying in=1s remote=1.2.3.4", formatMessage("Retrying", Fields{"remote": "1.2.3.4", "in": "1s"}))
+	assert.Equal("warn", WarnLevel.String())
+}
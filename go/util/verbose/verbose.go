@@ -41,13 +41,11 @@ func SetQuiet(q bool) {
 	quiet = q
 }
 
-// Log calls Printf(format, args...) iff Verbose() returns true.
+// Log logs Sprintf(format, args...) at DebugLevel, which the default Logger
+// prints iff Verbose() returns true.
 func Log(format string, args ...interface{}) {
-	if Verbose() {
-		if len(args) > 0 {
-			fmt.Printf(format+"\n", args...)
-		} else {
-			fmt.Println(format)
-		}
+	if len(args) > 0 {
+		format = fmt.Sprintf(format, args...)
 	}
+	Debug(format, nil)
 }
@@ -0,0 +1,247 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package workqueue implements a durable task queue in a dataset, with
+// at-least-once delivery: a task is leased to a worker for a time, and given
+// to another worker if it isn't acked before its lease expires.
+//
+// Each operation commits the new state of the queue to the dataset. Commits
+// are optimistic: if another process updated the queue concurrently, the
+// operation is retried on the new state. Each operation first rebases the
+// Database, so it sees tasks enqueued or acked by other processes. The head of the dataset is:
+//
+//	struct Queue {
+//	  next: Number,  // the ID of the next task enqueued
+//	  tasks: Map<Number, struct Task {
+//	    attempts: Number,
+//	    expires: Number,  // of the lease, in ms since the epoch; 0 if none
+//	    lease: String,
+//	    payload: Value,
+//	  }>,
+//	}
+//
+// Tasks are leased in the order they were enqueued. Every operation adds a
+// commit, so the queue suits tasks which take much longer than a commit, and
+// the history of the dataset grows with each one.
+package workqueue
+
+import (
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/retry"
+)
+
+// ErrLeaseLost is returned by Ack, Extend and Release if the task was acked
+// or leased again since it was leased.
+var ErrLeaseLost = errors.New("the task was acked or leased again")
+
+// ErrTooManyRetries is returned if an operation conflicted with concurrent
+// updates of the queue more than Queue.Retries times.
+var ErrTooManyRetries = errors.New("too many concurrent updates of the queue")
+
+// Task is a task leased from a Queue.
+type Task struct {
+	ID      uint64
+	Payload types.Value
+	// Attempts is the number of times the task was leased, including this
+	// one.
+	Attempts int
+	// Lease identifies the lease of the task, which Ack, Extend and Release
+	// check.
+	Lease   string
+	Expires time.Time
+}
+
+// Queue is a task queue kept in a dataset.
+type Queue struct {
+	db        datas.Database
+	datasetID string
+
+	// Now returns the time leases are checked against, time.Now by default.
+	Now func() time.Time
+	// Retries is how many times an operation is retried after conflicting
+	// with a concurrent update, 20 by default.
+	Retries int
+}
+
+// New returns the Queue in the dataset datasetID of db, which is created by
+// the first Enqueue.
+func New(db datas.Database, datasetID string) *Queue {
+	return &Queue{db: db, datasetID: datasetID, Now: time.Now, Retries: 20}
+}
+
+// Enqueue adds tasks of payloads to the queue, and returns their IDs.
+func (q *Queue) Enqueue(payloads ...types.Value) (ids []uint64, err error) {
+	err = q.update(func(st *state) error {
+		ids = make([]uint64, len(payloads))
+		for i, p := range payloads {
+			ids[i] = st.next
+			st.tasks = st.tasks.Set(types.Number(st.next), newTaskStruct(p, 0, "", 0))
+			st.next++
+		}
+		return nil
+	})
+	return
+}
+
+// Lease leases the first task whose lease has expired or which wasn't leased,
+// for d. It returns false if there's no such task.
+func (q *Queue) Lease(d time.Duration) (t Task, ok bool, err error) {
+	err = q.update(func(st *state) error {
+		now := toMillis(q.Now())
+		ok = false
+		st.tasks.Iter(func(k, v types.Value) bool {
+			ts := v.(types.Struct)
+			if float64(ts.Get("expires").(types.Number)) > now {
+				return false
+			}
+			t = Task{
+				ID:       uint64(k.(types.Number)),
+				Payload:  ts.Get("payload"),
+				Attempts: int(ts.Get("attempts").(types.Number)) + 1,
+				Lease:    newLease(),
+				Expires:  q.Now().Add(d),
+			}
+			ok = true
+			return true
+		})
+		if !ok {
+			return errUnchanged
+		}
+		st.tasks = st.tasks.Set(types.Number(t.ID), newTaskStruct(t.Payload, t.Attempts, t.Lease, toMillis(t.Expires)))
+		return nil
+	})
+	return
+}
+
+// Ack removes t, which is done, from the queue.
+func (q *Queue) Ack(t Task) error {
+	return q.update(func(st *state) error {
+		if _, err := st.leased(t); err != nil {
+			return err
+		}
+		st.tasks = st.tasks.Remove(types.Number(t.ID))
+		return nil
+	})
+}
+
+// Extend returns t leased until d from now.
+func (q *Queue) Extend(t Task, d time.Duration) (Task, error) {
+	err := q.update(func(st *state) error {
+		ts, err := st.leased(t)
+		if err != nil {
+			return err
+		}
+		t.Expires = q.Now().Add(d)
+		st.tasks = st.tasks.Set(types.Number(t.ID), ts.Set("expires", types.Number(toMillis(t.Expires))))
+		return nil
+	})
+	return t, err
+}
+
+// Release gives up the lease of t, so that it can be leased again at once.
+func (q *Queue) Release(t Task) error {
+	return q.update(func(st *state) error {
+		ts, err := st.leased(t)
+		if err != nil {
+			return err
+		}
+		st.tasks = st.tasks.Set(types.Number(t.ID), ts.Set("expires", types.Number(0)).Set("lease", types.String("")))
+		return nil
+	})
+}
+
+// Len returns the number of tasks in the queue, leased or not.
+func (q *Queue) Len() uint64 {
+	return q.load().tasks.Len()
+}
+
+// errUnchanged is returned by the function given to update if the state is
+// unchanged, so there's nothing to commit.
+var errUnchanged = errors.New("unchanged")
+
+// update commits the state of the queue as changed by f, retrying f on the
+// new state if the queue was updated concurrently.
+func (q *Queue) update(f func(st *state) error) (err error) {
+	ok := retry.Do(q.Retries, func() bool {
+		q.db.Rebase()
+		ds := q.db.GetDataset(q.datasetID)
+		st := stateOf(ds)
+		if err = f(&st); err == errUnchanged {
+			err = nil
+			return false
+		} else if err != nil {
+			return false
+		}
+		_, err = q.db.Commit(ds, st.value(), datas.CommitOptions{})
+		return err == datas.ErrMergeNeeded
+	})
+	if !ok {
+		return ErrTooManyRetries
+	}
+	return err
+}
+
+func (q *Queue) load() state {
+	q.db.Rebase()
+	return stateOf(q.db.GetDataset(q.datasetID))
+}
+
+type state struct {
+	next  uint64
+	tasks types.Map
+}
+
+func stateOf(ds datas.Dataset) state {
+	v, ok := ds.MaybeHeadValue()
+	if !ok {
+		return state{0, types.NewMap()}
+	}
+	s := v.(types.Struct)
+	return state{uint64(s.Get("next").(types.Number)), s.Get("tasks").(types.Map)}
+}
+
+func (st state) value() types.Value {
+	return types.NewStruct("Queue", types.StructData{
+		"next":  types.Number(st.next),
+		"tasks": st.tasks,
+	})
+}
+
+// leased returns the struct of t if t is still leased by its lease.
+func (st state) leased(t Task) (types.Struct, error) {
+	v, ok := st.tasks.MaybeGet(types.Number(t.ID))
+	if !ok {
+		return types.Struct{}, ErrLeaseLost
+	}
+	ts := v.(types.Struct)
+	if string(ts.Get("lease").(types.String)) != t.Lease {
+		return types.Struct{}, ErrLeaseLost
+	}
+	return ts, nil
+}
+
+func newTaskStruct(payload types.Value, attempts int, lease string, expires float64) types.Struct {
+	return types.NewStruct("Task", types.StructData{
+		"attempts": types.Number(attempts),
+		"expires":  types.Number(expires),
+		"lease":    types.String(lease),
+		"payload":  payload,
+	})
+}
+
+func newLease() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hash.Of(b).String()
+}
+
+func toMillis(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
@@ -0,0 +1,138 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package workqueue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestQueue(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	c := &clock{time.Unix(1000, 0)}
+	q := New(db, "queue")
+	q.Now = c.Now
+
+	_, ok, err := q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.False(ok)
+
+	ids, err := q.Enqueue(types.String("a"), types.String("b"))
+	assert.NoError(err)
+	assert.Equal([]uint64{0, 1}, ids)
+	assert.Equal(uint64(2), q.Len())
+
+	ta, ok, err := q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(uint64(0), ta.ID)
+	assert.Equal(types.String("a"), ta.Payload)
+	assert.Equal(1, ta.Attempts)
+
+	tb, ok, err := q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(types.String("b"), tb.Payload)
+
+	// Both are leased.
+	_, ok, err = q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.NoError(q.Ack(tb))
+	assert.Equal(ErrLeaseLost, q.Ack(tb))
+	assert.Equal(uint64(1), q.Len())
+
+	// a's lease expires, so it's leased again, and the old lease is lost.
+	c.now = c.now.Add(2 * time.Minute)
+	ta2, ok, err := q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(ta.ID, ta2.ID)
+	assert.Equal(2, ta2.Attempts)
+	assert.Equal(ErrLeaseLost, q.Ack(ta))
+
+	ta2, err = q.Extend(ta2, time.Hour)
+	assert.NoError(err)
+	c.now = c.now.Add(2 * time.Minute)
+	_, ok, err = q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.NoError(q.Release(ta2))
+	ta3, ok, err := q.Lease(time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(3, ta3.Attempts)
+	assert.NoError(q.Ack(ta3))
+	assert.Equal(uint64(0), q.Len())
+
+	// IDs aren't reused.
+	ids, err = q.Enqueue(types.String("c"))
+	assert.NoError(err)
+	assert.Equal([]uint64{2}, ids)
+}
+
+func TestQueueConcurrentWorkers(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := datas.NewDatabase(cs)
+	defer db.Close()
+
+	const n = 40
+	payloads := make([]types.Value, n)
+	for i := range payloads {
+		payloads[i] = types.Number(i)
+	}
+	_, err := New(db, "queue").Enqueue(payloads...)
+	assert.NoError(err)
+
+	// Each worker has its own database, as separate processes would.
+	mu := sync.Mutex{}
+	done := map[types.Value]int{}
+	wg := sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := New(datas.NewDatabase(cs), "queue")
+			q.Retries = 1000
+			for {
+				task, ok, err := q.Lease(time.Minute)
+				assert.NoError(err)
+				if !ok {
+					return
+				}
+				mu.Lock()
+				done[task.Payload]++
+				mu.Unlock()
+				assert.NoError(q.Ack(task))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(done, n)
+	for _, count := range done {
+		assert.Equal(1, count)
+	}
+	assert.Equal(uint64(0), New(db, "queue").Len())
+}
@@ -5,9 +5,11 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"time"
 
@@ -22,12 +24,29 @@ import (
 	flag "github.com/juju/gnuflag"
 )
 
+// stateInterval is how many bytes are written to the file between updates
+// of the state file.
+const stateInterval = 8 << 20
+
+// getState is the content of the state file of a download, which is resumed
+// if the blob, offset and length are the same.
+type getState struct {
+	Blob    string `json:"blob"`
+	Offset  uint64 `json:"offset"`
+	Length  uint64 `json:"length"`
+	Written uint64 `json:"written"`
+}
+
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s <dataset> [<file>]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [--offset <n>] [--length <n>] [--state <file>] <dataset> [<file>]\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
+	offsetArg := flag.Uint64("offset", 0, "byte offset to start reading the blob at")
+	lengthArg := flag.Uint64("length", 0, "number of bytes to read, or 0 to read to the end of the blob")
+	stateArg := flag.String("state", "", "file to record progress in, so that an interrupted download to <file> can be resumed")
+
 	verbose.RegisterVerboseFlags(flag.CommandLine)
 	profile.RegisterProfileFlags(flag.CommandLine)
 
@@ -51,36 +70,140 @@ func main() {
 		blob = b
 	}
 
+	offset, length := *offsetArg, *lengthArg
+	if offset > blob.Len() {
+		d.CheckErrorNoUsage(fmt.Errorf("Offset %d is past the end of the blob, which is %d bytes", offset, blob.Len()))
+	}
+	if length == 0 || length > blob.Len()-offset {
+		length = blob.Len() - offset
+	}
+	whole := offset == 0 && length == blob.Len()
+
 	defer profile.MaybeStartProfile().Stop()
 
 	filePath := flag.Arg(1)
 	if filePath == "" {
-		blob.Reader().Copy(os.Stdout)
+		if *stateArg != "" {
+			d.CheckErrorNoUsage(errors.New("--state requires a file to download to"))
+		}
+		if whole {
+			blob.Reader().Copy(os.Stdout)
+		} else {
+			copyRange(os.Stdout, blob, offset, length)
+		}
 		return
 	}
 
-	// Note: overwrites any existing file.
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0644)
+	state := getState{Blob: blob.Hash().String(), Offset: offset, Length: length}
+	fileFlags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if *stateArg != "" {
+		if prev, err := readGetState(*stateArg); err == nil && prev.Blob == state.Blob && prev.Offset == offset && prev.Length == length {
+			state.Written = prev.Written
+			fileFlags &^= os.O_TRUNC
+		} else if err != nil && !os.IsNotExist(err) {
+			d.CheckErrorNoUsage(err)
+		}
+	}
+
+	file, err := os.OpenFile(filePath, fileFlags, 0644)
 	d.CheckErrorNoUsage(err)
 	defer file.Close()
 
+	// Bytes past the last recorded state may not have been written fully.
+	if state.Written > 0 {
+		d.CheckErrorNoUsage(file.Truncate(int64(state.Written)))
+		_, err = file.Seek(int64(state.Written), 0)
+		d.CheckErrorNoUsage(err)
+		status.Printf("Resuming at %s of %s", humanize.Bytes(state.Written), humanize.Bytes(length))
+	}
+
 	start := time.Now()
-	expected := humanize.Bytes(blob.Len())
+	expected := humanize.Bytes(length)
+	resumed := state.Written
 
 	// Create a pipe so that we can connect a progress reader
 	preader, pwriter := io.Pipe()
 
 	go func() {
-		blob.Reader().Copy(pwriter)
+		if whole && resumed == 0 {
+			blob.Reader().Copy(pwriter)
+		} else {
+			copyRange(pwriter, blob, offset+resumed, length-resumed)
+		}
 		pwriter.Close()
 	}()
 
 	blobReader := progressreader.New(preader, func(seen uint64) {
 		elapsed := time.Since(start).Seconds()
 		rate := uint64(float64(seen) / elapsed)
-		status.Printf("%s of %s written in %ds (%s/s)...", humanize.Bytes(seen), expected, int(elapsed), humanize.Bytes(rate))
+		status.Printf("%s of %s written in %ds (%s/s)...", humanize.Bytes(resumed+seen), expected, int(elapsed), humanize.Bytes(rate))
 	})
 
-	io.Copy(file, blobReader)
+	if *stateArg == "" {
+		io.Copy(file, blobReader)
+		status.Done()
+		return
+	}
+
+	w := &stateWriter{file: file, path: *stateArg, state: state}
+	d.CheckErrorNoUsage(w.save())
+	_, err = io.Copy(w, blobReader)
+	d.CheckErrorNoUsage(err)
+	d.CheckErrorNoUsage(file.Sync())
 	status.Done()
+	d.CheckErrorNoUsage(os.Remove(*stateArg))
+}
+
+// copyRange copies length bytes of blob, from offset, to w.
+func copyRange(w io.Writer, blob types.Blob, offset, length uint64) {
+	r := blob.Reader()
+	_, err := r.Seek(int64(offset), 0)
+	d.Chk.NoError(err)
+	_, err = io.CopyN(w, r, int64(length))
+	d.Chk.NoError(err)
+}
+
+func readGetState(path string) (getState, error) {
+	var state getState
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	return state, err
+}
+
+// stateWriter writes to file, and records how much has been written in the
+// state file at path every stateInterval bytes, once they've been synced.
+type stateWriter struct {
+	file    *os.File
+	path    string
+	state   getState
+	unsaved uint64
+}
+
+func (w *stateWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.state.Written += uint64(n)
+	w.unsaved += uint64(n)
+	if err == nil && w.unsaved >= stateInterval {
+		if err = w.file.Sync(); err == nil {
+			err = w.save()
+		}
+	}
+	return n, err
+}
+
+// save writes the state file atomically, so that it's never left half
+// written.
+func (w *stateWriter) save() error {
+	data, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+	tmp := w.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	w.unsaved = 0
+	return os.Rename(tmp, w.path)
 }
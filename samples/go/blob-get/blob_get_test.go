@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -49,3 +50,63 @@ func (s *bgSuite) TestBlobGet() {
 	fmt.Println("stdout:", stdout)
 	s.Equal(blobBytes, []byte(stdout))
 }
+
+func (s *bgSuite) TestBlobGetRange() {
+	blob := types.NewBlob(bytes.NewBufferString("hello world"))
+
+	sp, err := spec.ForDatabase(s.TempDir)
+	s.NoError(err)
+	defer sp.Close()
+	db := sp.GetDatabase()
+	ref := db.WriteValue(blob)
+	_, err = db.CommitValue(db.GetDataset("rangeID"), ref)
+	s.NoError(err)
+
+	hashSpec := fmt.Sprintf("%s::#%s", s.TempDir, ref.TargetHash().String())
+	stdout, _ := s.MustRun(main, []string{"--offset", "6", "--length", "3", hashSpec})
+	s.Equal("wor", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"--offset", "6", hashSpec})
+	s.Equal("world", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"--offset", "6", "--length", "100", hashSpec})
+	s.Equal("world", stdout)
+
+	_, _, recovered := s.Run(main, []string{"--offset", "12", hashSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recovered)
+}
+
+func (s *bgSuite) TestBlobGetResume() {
+	blob := types.NewBlob(bytes.NewBufferString("hello world"))
+
+	sp, err := spec.ForDatabase(s.TempDir)
+	s.NoError(err)
+	defer sp.Close()
+	db := sp.GetDatabase()
+	ref := db.WriteValue(blob)
+	_, err = db.CommitValue(db.GetDataset("resumeID"), ref)
+	s.NoError(err)
+
+	// Bytes past the recorded state are written again.
+	filePath := filepath.Join(s.TempDir, "resumed")
+	statePath := filepath.Join(s.TempDir, "resumed.state")
+	s.NoError(ioutil.WriteFile(filePath, []byte("wor???"), 0644))
+	s.NoError(ioutil.WriteFile(statePath, []byte(fmt.Sprintf(`{"blob":"%s","offset":6,"length":5,"written":3}`, ref.TargetHash().String())), 0644))
+
+	hashSpec := fmt.Sprintf("%s::#%s", s.TempDir, ref.TargetHash().String())
+	s.MustRun(main, []string{"--offset", "6", "--state", statePath, hashSpec, filePath})
+
+	fileBytes, err := ioutil.ReadFile(filePath)
+	s.NoError(err)
+	s.Equal("world", string(fileBytes))
+	_, err = os.Stat(statePath)
+	s.True(os.IsNotExist(err))
+
+	// A state file for another range is ignored.
+	s.NoError(ioutil.WriteFile(statePath, []byte(fmt.Sprintf(`{"blob":"%s","offset":6,"length":5,"written":3}`, ref.TargetHash().String())), 0644))
+	s.MustRun(main, []string{"--state", statePath, hashSpec, filePath})
+
+	fileBytes, err = ioutil.ReadFile(filePath)
+	s.NoError(err)
+	s.Equal("hello world", string(fileBytes))
+}
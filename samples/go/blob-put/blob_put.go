@@ -5,27 +5,47 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"runtime"
 
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/profile"
+	"github.com/attic-labs/noms/go/util/status"
 	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
 	flag "github.com/juju/gnuflag"
 )
 
+// putState is the content of the state file of an upload. The part of the
+// file uploaded so far is committed to a staging dataset, so that it's
+// persisted, and the upload is resumed if the file hasn't changed since and
+// the staging dataset's head is still that part.
+type putState struct {
+	File    string `json:"file"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+	Staging string `json:"staging"`
+	Blob    string `json:"blob"`
+	Written int64  `json:"written"`
+}
+
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s <file> <dataset>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [--state <file>] <file> <dataset>\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
 	var concurrencyArg = flag.Int("concurrency", runtime.NumCPU(), "number of concurrent HTTP calls to retrieve remote resources")
+	var stateArg = flag.String("state", "", "file to record progress in, so that an interrupted upload can be resumed")
+	var partSizeArg = flag.Int64("part-size", 64<<20, "number of bytes to upload between updates of the state file")
 
 	verbose.RegisterVerboseFlags(flag.CommandLine)
 	profile.RegisterProfileFlags(flag.CommandLine)
@@ -48,39 +68,146 @@ func main() {
 
 	defer profile.MaybeStartProfile().Stop()
 
-	fileSize := info.Size()
-	chunkSize := fileSize / int64(*concurrencyArg)
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create dataset: %s\n", err)
+		return
+	}
+	defer db.Close()
+
+	var blob types.Blob
+	if *stateArg == "" {
+		blob = types.NewStreamingBlob(db, fileReaders(filePath, 0, info.Size(), *concurrencyArg)...)
+	} else {
+		if *partSizeArg <= 0 {
+			d.CheckErrorNoUsage(errors.New("--part-size must be positive"))
+		}
+		blob = putResumable(db, ds, filePath, info, *stateArg, *partSizeArg, *concurrencyArg)
+	}
+
+	_, err = db.CommitValue(ds, blob)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error committing: %s\n", err)
+		return
+	}
+
+	if *stateArg != "" {
+		_, err = db.Delete(db.GetDataset(stagingDatasetID(ds)))
+		d.CheckErrorNoUsage(err)
+		d.CheckErrorNoUsage(os.Remove(*stateArg))
+	}
+}
+
+// fileReaders returns readers of size bytes of the file at filePath, from
+// offset, in up to concurrency slices of at least 1MB.
+func fileReaders(filePath string, offset, size int64, concurrency int) []io.Reader {
+	chunkSize := size / int64(concurrency)
 	if chunkSize < (1 << 20) {
 		chunkSize = 1 << 20
 	}
 
-	readers := make([]io.Reader, fileSize/chunkSize)
+	n := size / chunkSize
+	if n == 0 {
+		n = 1
+	}
+	readers := make([]io.Reader, n)
 	for i := 0; i < len(readers); i++ {
 		r, err := os.Open(filePath)
 		d.CheckErrorNoUsage(err)
-		defer r.Close()
-		r.Seek(int64(i)*chunkSize, 0)
+		r.Seek(offset+int64(i)*chunkSize, 0)
 		limit := chunkSize
 		if i == len(readers)-1 {
-			limit += fileSize % chunkSize // adjust size of last slice to include the final bytes.
+			limit = size - int64(i)*chunkSize // adjust size of last slice to include the final bytes.
 		}
-		lr := io.LimitReader(r, limit)
-		readers[i] = lr
+		readers[i] = &closingReader{io.LimitReader(r, limit), r}
 	}
+	return readers
+}
 
-	cfg := config.NewResolver()
-	db, ds, err := cfg.GetDataset(flag.Arg(1))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not create dataset: %s\n", err)
-		return
+// closingReader closes the file it reads from once it's been read to the end.
+type closingReader struct {
+	io.Reader
+	f *os.File
+}
+
+func (r *closingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.f.Close()
 	}
-	defer db.Close()
+	return n, err
+}
 
-	blob := types.NewStreamingBlob(db, readers...)
+// stagingDatasetID returns the ID of the dataset which the part of the file
+// uploaded so far is committed to.
+func stagingDatasetID(ds datas.Dataset) string {
+	return ds.ID() + "/partial-upload"
+}
 
-	_, err = db.CommitValue(ds, blob)
+// putResumable uploads the file at filePath a part at a time, recording
+// progress in the state file at statePath, and resuming from it if it's an
+// upload of the same file.
+func putResumable(db datas.Database, ds datas.Dataset, filePath string, info os.FileInfo, statePath string, partSize int64, concurrency int) types.Blob {
+	state := putState{
+		File:    filePath,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Staging: stagingDatasetID(ds),
+	}
+	blob := types.NewEmptyBlob()
+	if prev, err := readPutState(statePath); err == nil && prev.File == state.File && prev.Size == state.Size && prev.ModTime == state.ModTime && prev.Staging == state.Staging {
+		head, _ := db.GetDataset(state.Staging).MaybeHeadValue()
+		b, ok := head.(types.Blob)
+		if !ok || b.Hash().String() != prev.Blob || int64(b.Len()) != prev.Written {
+			d.CheckErrorNoUsage(fmt.Errorf("The blob uploaded so far, #%s, isn't the head of %s, remove %s to start over", prev.Blob, state.Staging, statePath))
+		}
+		blob, state.Written = b, prev.Written
+		status.Printf("Resuming at %s of %s", humanize.Bytes(uint64(state.Written)), humanize.Bytes(uint64(state.Size)))
+	} else if err != nil && !os.IsNotExist(err) {
+		d.CheckErrorNoUsage(err)
+	}
+
+	staging := db.GetDataset(state.Staging)
+	for state.Written < state.Size {
+		size := partSize
+		if size > state.Size-state.Written {
+			size = state.Size - state.Written
+		}
+		part := types.NewStreamingBlob(db, fileReaders(filePath, state.Written, size, concurrency)...)
+		blob = blob.Concat(part)
+
+		var err error
+		staging, err = db.CommitValue(staging, blob)
+		d.CheckErrorNoUsage(err)
+		state.Written += size
+		state.Blob = blob.Hash().String()
+		d.CheckErrorNoUsage(writePutState(statePath, state))
+		status.Printf("%s of %s written...", humanize.Bytes(uint64(state.Written)), humanize.Bytes(uint64(state.Size)))
+	}
+	status.Done()
+	return blob
+}
+
+func readPutState(path string) (putState, error) {
+	var state putState
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	return state, err
+}
+
+// writePutState writes the state file atomically, so that it's never left
+// half written.
+func writePutState(path string, state putState) error {
+	data, err := json.Marshal(state)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error committing: %s\n", err)
-		return
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
 	}
+	return os.Rename(tmp, path)
 }
@@ -0,0 +1,78 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestBlobPut(t *testing.T) {
+	suite.Run(t, &bpSuite{})
+}
+
+type bpSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *bpSuite) readBlob(dsSpec string) string {
+	sp, err := spec.ForPath(dsSpec + ".value")
+	s.NoError(err)
+	defer sp.Close()
+	blob, ok := sp.GetValue().(types.Blob)
+	s.True(ok)
+	buf := &bytes.Buffer{}
+	blob.Reader().Copy(buf)
+	return buf.String()
+}
+
+func (s *bpSuite) TestBlobPut() {
+	filePath := filepath.Join(s.TempDir, "in")
+	s.NoError(ioutil.WriteFile(filePath, []byte("hello world"), 0644))
+
+	dsSpec := spec.CreateValueSpecString("nbs", s.DBDir, "put")
+	s.MustRun(main, []string{filePath, dsSpec})
+	s.Equal("hello world", s.readBlob(dsSpec))
+}
+
+func (s *bpSuite) TestBlobPutResume() {
+	filePath := filepath.Join(s.TempDir, "resumed")
+	statePath := filepath.Join(s.TempDir, "resumed.state")
+	s.NoError(ioutil.WriteFile(filePath, []byte("hello world"), 0644))
+	info, err := os.Stat(filePath)
+	s.NoError(err)
+
+	// Commit the first part of the file, as an interrupted upload would have.
+	sp, err := spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
+	s.NoError(err)
+	db := sp.GetDatabase()
+	partial := types.NewStreamingBlob(db, bytes.NewBufferString("hello"))
+	_, err = db.CommitValue(db.GetDataset("resumed/partial-upload"), partial)
+	s.NoError(err)
+	sp.Close()
+	s.NoError(ioutil.WriteFile(statePath, []byte(fmt.Sprintf(`{"file":"%s","size":11,"modTime":%d,"staging":"resumed/partial-upload","blob":"%s","written":5}`,
+		filePath, info.ModTime().UnixNano(), partial.Hash().String())), 0644))
+
+	dsSpec := spec.CreateValueSpecString("nbs", s.DBDir, "resumed")
+	s.MustRun(main, []string{"--state", statePath, "--part-size", "4", filePath, dsSpec})
+	s.Equal("hello world", s.readBlob(dsSpec))
+
+	_, err = os.Stat(statePath)
+	s.True(os.IsNotExist(err))
+	sp, err = spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
+	s.NoError(err)
+	defer sp.Close()
+	_, ok := sp.GetDatabase().GetDataset("resumed/partial-upload").MaybeHead()
+	s.False(ok)
+}
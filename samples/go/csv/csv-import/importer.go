@@ -5,10 +5,13 @@
 package main
 
 import (
+	"bufio"
+	gocsv "encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,10 +37,11 @@ const (
 func main() {
 	// Actually the delimiter uses runes, which can be multiple characters long.
 	// https://blog.golang.org/strings
-	delimiter := flag.String("delimiter", ",", "field delimiter for csv file, must be exactly one character long.")
+	delimiter := flag.String("delimiter", ",", "field delimiter for csv file, must be exactly one character long, or 'auto' to guess it and how fields are quoted from the first records.")
 	header := flag.String("header", "", "header row. If empty, we'll use the first row of the file")
 	name := flag.String("name", "Row", "struct name. The user-visible name to give to the struct type that will hold each row of data.")
-	columnTypes := flag.String("column-types", "", "a comma-separated list of types representing the desired type of each column. if absent all types default to be String")
+	columnTypes := flag.String("column-types", "", "a comma-separated list of types representing the desired type of each column: String, Number, Bool or DateTime. if absent all types default to be String")
+	dateFormat := flag.String("date-format", "", "the layout, as taken by Go's time.Parse, of the values of DateTime columns. if absent they can be RFC 3339 date times, or dates and times without a time zone like '2006-01-02 15:04:05', which are in UTC")
 	pathDescription := "noms path to blob to import"
 	path := flag.String("path", "", pathDescription)
 	flag.StringVar(path, "p", "", pathDescription)
@@ -45,6 +49,9 @@ func main() {
 	destType := flag.String("dest-type", "list", "the destination type to import to. can be 'list' or 'map:<pk>', where <pk> is the index position (0-based) of the column that is a the unique identifier for the column")
 	skipRecords := flag.Uint("skip-records", 0, "number of records to skip at beginning of file")
 	performCommit := flag.Bool("commit", true, "commit the data to head of the dataset (otherwise only write the data to the dataset)")
+	malformed := flag.String("malformed", "fail", "what to do with records which can't be read: 'fail', 'skip' or 'collect:<file>', which skips them and appends them to <file> as CSV, with their record number and error")
+	batchSize := flag.Int("batch", 0, "commit every <n> records, so that an interrupted import can be continued with -resume. if 0, commit once all of them are imported")
+	resume := flag.Bool("resume", false, "continue the import committed to the head of the dataset from the record after the last one it imported")
 	spec.RegisterCommitMetaFlags(flag.CommandLine)
 	verbose.RegisterVerboseFlags(flag.CommandLine)
 	profile.RegisterProfileFlags(flag.CommandLine)
@@ -112,8 +119,18 @@ func main() {
 		r = progressreader.New(r, getStatusPrinter(size))
 	}
 
-	delim, err := csv.StringToRune(*delimiter)
-	d.CheckErrorNoUsage(err)
+	br := bufio.NewReaderSize(r, sniffSize)
+	dialect := csv.Dialect{}
+	if *delimiter == "auto" {
+		dialect, err = csv.SniffDialect(br)
+		d.CheckErrorNoUsage(err)
+	} else {
+		dialect.Comma, err = csv.StringToRune(*delimiter)
+		d.CheckErrorNoUsage(err)
+	}
+	if *dateFormat != "" {
+		csv.DateTimeLayouts = []string{*dateFormat}
+	}
 
 	var dest int
 	var strPks []string
@@ -131,7 +148,38 @@ func main() {
 		return
 	}
 
-	cr := csv.NewCSVReader(r, delim)
+	var onMalformed func(csv.MalformedRow) error
+	switch {
+	case *malformed == "fail":
+	case *malformed == "skip":
+		onMalformed = func(row csv.MalformedRow) error {
+			verbose.Log("Skipped record %d: %s", row.Record+1, row.Err)
+			return nil
+		}
+	case strings.HasPrefix(*malformed, "collect:") && *malformed != "collect:":
+		f, err := os.OpenFile(strings.TrimPrefix(*malformed, "collect:"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		d.CheckErrorNoUsage(err)
+		defer f.Close()
+		w := gocsv.NewWriter(f)
+		w.Comma = dialect.Comma
+		onMalformed = func(row csv.MalformedRow) error {
+			w.Write(append(row.Fields, strconv.Itoa(row.Record+1), row.Err.Error()))
+			w.Flush()
+			return w.Error()
+		}
+	default:
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid malformed: %s, expected fail, skip or collect:<file>", *malformed))
+	}
+
+	if *batchSize < 0 {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid batch: %d", *batchSize))
+	}
+	if !*performCommit && (*batchSize > 0 || *resume) {
+		d.CheckErrorNoUsage(errors.New("batch and resume need the data to be committed"))
+	}
+
+	cr := csv.NewCSVReader(br, dialect.Comma)
+	cr.LazyQuotes = dialect.LazyQuotes
 	err = csv.SkipRecords(cr, *skipRecords)
 
 	if err == io.EOF {
@@ -167,30 +215,105 @@ func main() {
 	d.CheckError(err)
 	defer db.Close()
 
+	rr := csv.NewRowReader(cr, *name, headers, kinds)
+	rr.OnMalformed = onMalformed
+	metaInfo := additionalMetaInfo(filePath, *path)
+
 	var value types.Value
-	if dest == destList {
-		value, _ = csv.ReadToList(cr, *name, headers, kinds, db)
-	} else {
-		value = csv.ReadToMap(cr, *name, headers, strPks, kinds, db)
+	if *resume {
+		value, rr.Records, err = checkpoint(ds, metaInfo)
+		d.CheckErrorNoUsage(err)
+		err = csv.SkipRecords(cr, uint(rr.Records))
+		if err == io.EOF {
+			err = fmt.Errorf("resume skipped past EOF, the input changed since record %d was imported", rr.Records)
+		}
+		d.CheckErrorNoUsage(err)
 	}
 
-	if *performCommit {
-		meta, err := spec.CreateCommitMetaStruct(ds.Database(), "", "", additionalMetaInfo(filePath, *path), nil)
+	for commits := 0; ; commits++ {
+		var batch types.Value
+		var n int
+		if dest == destList {
+			batch, n, err = csv.ReadRowsToList(rr, *batchSize, db)
+			if err == nil {
+				// Lists can only be concatenated if they're read by the same
+				// ValueReader, and the head read by checkpoint is read by that
+				// of ds, so each batch is read back by it too.
+				vr := ds.Database()
+				batch = vr.ReadValue(vr.WriteValue(batch).TargetHash())
+			}
+			if err == nil && value != nil {
+				batch = value.(types.List).Concat(batch.(types.List))
+			}
+		} else {
+			batch, n, err = csv.ReadRowsToMap(rr, strPks, *batchSize, db)
+			if err == nil && value != nil {
+				batch = csv.MergeMaps(value.(types.Map), batch.(types.Map), len(strPks))
+			}
+		}
 		d.CheckErrorNoUsage(err)
-		_, err = db.Commit(ds, value, datas.CommitOptions{Meta: meta})
-		if !*noProgress {
-			status.Clear()
+		done := *batchSize == 0 || n < *batchSize
+		if n == 0 && commits > 0 {
+			break
 		}
-		d.PanicIfError(err)
-	} else {
-		ref := db.WriteValue(value)
-		if !*noProgress {
-			status.Clear()
+		value = batch
+
+		if *performCommit {
+			metaInfo[checkpointField] = strconv.Itoa(rr.Records)
+			meta, err := spec.CreateCommitMetaStruct(ds.Database(), "", "", metaInfo, nil)
+			d.CheckErrorNoUsage(err)
+			ds, err = db.Commit(ds, value, datas.CommitOptions{Meta: meta})
+			d.PanicIfError(err)
+			verbose.Log("Committed %d records as #%s", rr.Records, ds.HeadRef().TargetHash())
 		}
+		if done {
+			break
+		}
+	}
+
+	if !*noProgress {
+		status.Clear()
+	}
+	if !*performCommit {
+		ref := db.WriteValue(value)
 		fmt.Fprintf(os.Stdout, "#%s\n", ref.TargetHash().String())
 	}
 }
 
+// sniffSize is the size of the start of the input which -delimiter auto
+// guesses the delimiter from.
+const sniffSize = 1 << 16
+
+// checkpointField is the field of the meta of each commit which holds the
+// number of records imported so far, not counting any headers or skipped
+// ones.
+const checkpointField = "csvRecords"
+
+// checkpoint returns the value of the head of ds and the number of records
+// which were imported to it, or nil and 0 if ds has no head. It's an error if
+// the head wasn't imported from the input described by metaInfo.
+func checkpoint(ds datas.Dataset, metaInfo map[string]string) (types.Value, int, error) {
+	head, ok := ds.MaybeHead()
+	if !ok {
+		return nil, 0, nil
+	}
+	meta := head.Get(datas.MetaField).(types.Struct)
+	records, ok := meta.MaybeGet(checkpointField)
+	if !ok {
+		return nil, 0, fmt.Errorf("Can't resume: the head of %s has no %s in its meta", ds.ID(), checkpointField)
+	}
+	for k, v := range metaInfo {
+		if mv, ok := meta.MaybeGet(k); !ok || !mv.Equals(types.String(v)) {
+			return nil, 0, fmt.Errorf("Can't resume: the head of %s wasn't imported from %s", ds.ID(), v)
+		}
+	}
+	n, err := strconv.Atoi(string(records.(types.String)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("Can't resume: invalid %s in the meta of the head of %s: %s", checkpointField, ds.ID(), err)
+	}
+	return ds.HeadValue(), n, nil
+}
+
 func additionalMetaInfo(filePath, nomsPath string) map[string]string {
 	fileOrNomsPath := "inputPath"
 	path := nomsPath
@@ -10,7 +10,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
@@ -377,3 +380,156 @@ func (s *testSuite) TestCSVImportSkipRecordsCustomHeader() {
 	s.Equal(types.String("7"), st.Get("x"))
 	s.Equal(types.String("8"), st.Get("y"))
 }
+
+func (s *testSuite) TestCSVImportAutoDelimiterAndDateTimes() {
+	input, err := ioutil.TempFile(s.TempDir, "")
+	d.Chk.NoError(err)
+	defer input.Close()
+	defer os.Remove(input.Name())
+
+	_, err = input.WriteString("when;what\n2017-01-02;a \"b\"\n2017-01-03 04:05:06;c\n")
+	d.Chk.NoError(err)
+
+	setName := "csv"
+	dataspec := spec.CreateValueSpecString("nbs", s.DBDir, setName)
+	stdout, stderr := s.MustRun(main, []string{"--no-progress", "--delimiter", "auto", "--column-types", "DateTime,String", input.Name(), dataspec})
+	s.Equal("", stdout)
+	s.Equal("", stderr)
+
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer os.RemoveAll(s.DBDir)
+	defer db.Close()
+
+	l := db.GetDataset(setName).HeadValue().(types.List)
+	s.Equal(uint64(2), l.Len())
+	st := l.Get(0).(types.Struct)
+	s.Equal(types.String(`a "b"`), st.Get("what"))
+	s.True(st.Get("when").Equals(types.NewStruct("DateTime", types.StructData{
+		"secSinceEpoch": types.Number(time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC).Unix()),
+	})))
+	st = l.Get(1).(types.Struct)
+	s.True(st.Get("when").Equals(types.NewStruct("DateTime", types.StructData{
+		"secSinceEpoch": types.Number(time.Date(2017, 1, 3, 4, 5, 6, 0, time.UTC).Unix()),
+	})))
+}
+
+func (s *testSuite) TestCSVImportMalformed() {
+	input, err := ioutil.TempFile(s.TempDir, "")
+	d.Chk.NoError(err)
+	defer input.Close()
+	defer os.Remove(input.Name())
+
+	_, err = input.WriteString("a,b\n1,2\nx,3\n4\n5,6\n")
+	d.Chk.NoError(err)
+
+	setName := "csv"
+	dataspec := spec.CreateValueSpecString("nbs", s.DBDir, setName)
+	defer os.RemoveAll(s.DBDir)
+	args := []string{"--no-progress", "--column-types", "Number,Number", input.Name(), dataspec}
+
+	_, stderr, exitErr := s.Run(main, args)
+	s.Contains(stderr, "Error parsing value for column 'a' of record 2")
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+
+	_, stderr, exitErr = s.Run(main, append([]string{"--malformed", "ignore"}, args...))
+	s.Equal("error: Invalid malformed: ignore, expected fail, skip or collect:<file>\n", stderr)
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+
+	s.MustRun(main, append([]string{"--malformed", "skip"}, args...))
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	s.Equal(uint64(2), db.GetDataset(setName).HeadValue().(types.List).Len())
+	db.Close()
+
+	collected := path.Join(s.TempDir, "malformed.csv")
+	s.MustRun(main, append([]string{"--malformed", "collect:" + collected}, args...))
+	data, err := ioutil.ReadFile(collected)
+	s.NoError(err)
+	lines := strings.Split(string(data), "\n")
+	s.Len(lines, 3)
+	s.True(strings.HasPrefix(lines[0], "x,3,2,\"Error parsing value for column 'a' of record 2: "), lines[0])
+	s.Equal("4,3,\"Record 3 has 1 fields, expected 2\"", lines[1])
+}
+
+func (s *testSuite) TestCSVImportResume() {
+	input, err := ioutil.TempFile(s.TempDir, "")
+	d.Chk.NoError(err)
+	defer input.Close()
+	defer os.Remove(input.Name())
+
+	writeRows := func(from, to int) {
+		for i := from; i < to; i++ {
+			_, err := input.WriteString(fmt.Sprintf("%d,%d,a%d\n", i%2, i, i))
+			d.Chk.NoError(err)
+		}
+	}
+	_, err = input.WriteString("x,y,z\n")
+	d.Chk.NoError(err)
+	writeRows(0, 5)
+
+	test := func(destType string, firstLen uint64, validate func(v types.Value)) {
+		dataspec := spec.CreateValueSpecString("nbs", s.DBDir, "csv")
+		defer os.RemoveAll(s.DBDir)
+		args := []string{"--no-progress", "--column-types", "Number,Number,String", "--dest-type", destType, "--batch", "2", input.Name(), dataspec}
+		s.MustRun(main, args)
+
+		db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+		ds := db.GetDataset("csv")
+		s.Equal(firstLen, ds.HeadValue().(types.Collection).Len())
+		s.Equal(types.String("5"), ds.Head().Get(datas.MetaField).(types.Struct).Get("csvRecords"))
+		db.Close()
+
+		// The file grows after the import, which then continues with the
+		// records after those which were imported.
+		writeRows(5, 10)
+		defer input.Truncate(0)
+		s.MustRun(main, append([]string{"--resume"}, args...))
+
+		db = datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+		defer db.Close()
+		ds = db.GetDataset("csv")
+		s.Equal(types.String("10"), ds.Head().Get(datas.MetaField).(types.Struct).Get("csvRecords"))
+		validate(ds.HeadValue())
+	}
+
+	test("list", 5, func(v types.Value) {
+		l := v.(types.List)
+		s.Equal(uint64(10), l.Len())
+		for i := 0; i < 10; i++ {
+			s.Equal(types.Number(i), l.Get(uint64(i)).(types.Struct).Get("y"))
+		}
+	})
+
+	input.Seek(0, 0)
+	_, err = input.WriteString("x,y,z\n")
+	d.Chk.NoError(err)
+	writeRows(0, 5)
+	test("map:x,y", 2, func(v types.Value) {
+		m := v.(types.Map)
+		s.Equal(uint64(2), m.Len())
+		for i := 0; i < 10; i++ {
+			st := m.Get(types.Number(i % 2)).(types.Map).Get(types.Number(i)).(types.Struct)
+			s.Equal(types.String(fmt.Sprintf("a%d", i)), st.Get("z"))
+		}
+	})
+}
+
+func (s *testSuite) TestCSVImportResumeOtherInput() {
+	input, err := ioutil.TempFile(s.TempDir, "")
+	d.Chk.NoError(err)
+	defer input.Close()
+	defer os.Remove(input.Name())
+	_, err = input.WriteString("a,b\n1,2\n")
+	d.Chk.NoError(err)
+
+	dataspec := spec.CreateValueSpecString("nbs", s.DBDir, "csv")
+	defer os.RemoveAll(s.DBDir)
+	s.MustRun(main, []string{"--no-progress", "--resume", s.tmpFileName, dataspec})
+
+	_, stderr, exitErr := s.Run(main, []string{"--no-progress", "--resume", input.Name(), dataspec})
+	s.Equal(fmt.Sprintf("error: Can't resume: the head of csv wasn't imported from %s\n", input.Name()), stderr)
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+
+	_, stderr, exitErr = s.Run(main, []string{"--no-progress", "--commit=false", "--batch", "2", input.Name(), dataspec})
+	s.Equal("error: batch and resume need the data to be committed\n", stderr)
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+}
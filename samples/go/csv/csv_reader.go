@@ -6,6 +6,7 @@ package csv
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
 	"io"
 )
@@ -51,3 +52,67 @@ func NewCSVReader(res io.Reader, comma rune) *csv.Reader {
 	r.FieldsPerRecord = -1 // Don't enforce number of fields.
 	return r
 }
+
+// Dialect is how the fields of a CSV are delimited and quoted.
+type Dialect struct {
+	Comma rune
+	// LazyQuotes is true if quotes appear in unquoted fields, as in
+	// csv.Reader.
+	LazyQuotes bool
+}
+
+// sniffCommas are the delimiters SniffDialect chooses from, in order of
+// preference.
+var sniffCommas = []rune{',', '\t', ';', '|'}
+
+// SniffDialect guesses the Dialect of the CSV read by r from as many of its
+// first records as fit in the buffer of r, without consuming them. The
+// delimiter is the one which splits each of those records into the same number
+// of fields, the most if several do, and ',' if none does.
+func SniffDialect(r *bufio.Reader) (Dialect, error) {
+	sample, err := r.Peek(r.Size())
+	if err == nil {
+		// The buffer is full, so the last line is likely cut short.
+		if i := bytes.LastIndexByte(sample, nByte); i >= 0 {
+			sample = sample[:i+1]
+		}
+	} else if err != nil && err != io.EOF {
+		return Dialect{}, err
+	}
+
+	dialect := Dialect{Comma: ','}
+	best := 1
+	for _, comma := range sniffCommas {
+		cr := NewCSVReader(bytes.NewReader(sample), comma)
+		cr.LazyQuotes = true
+		fields := -1
+		for {
+			record, err := cr.Read()
+			if err != nil {
+				break
+			}
+			if fields == -1 {
+				fields = len(record)
+			} else if fields != len(record) {
+				fields = 0
+				break
+			}
+		}
+		if fields > best {
+			dialect.Comma, best = comma, fields
+		}
+	}
+
+	// Quotes are only needed lazily if there are quotes inside fields.
+	cr := NewCSVReader(bytes.NewReader(sample), dialect.Comma)
+	for {
+		_, err := cr.Read()
+		if perr, ok := err.(*csv.ParseError); ok && perr.Err == csv.ErrBareQuote {
+			dialect.LazyQuotes = true
+			break
+		} else if err == io.EOF {
+			break
+		}
+	}
+	return dialect, nil
+}
@@ -5,7 +5,9 @@
 package csv
 
 import (
+	"bufio"
 	"bytes"
+	"io/ioutil"
 	"strings"
 	"testing"
 
@@ -81,3 +83,37 @@ func TestCRLFEndOfBufferLength(t *testing.T) {
 		t.Errorf("Wrong number of lines. Expected 2, got %d", len(lines))
 	}
 }
+
+func TestSniffDialect(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(data string, expected Dialect) {
+		br := bufio.NewReader(strings.NewReader(data))
+		dialect, err := SniffDialect(br)
+		assert.NoError(err)
+		assert.Equal(expected, dialect, data)
+
+		// Nothing is consumed.
+		rest, err := ioutil.ReadAll(br)
+		assert.NoError(err)
+		assert.Equal(data, string(rest))
+	}
+
+	test("a,b,c\n1,2,3\n", Dialect{Comma: ','})
+	test("a\tb\tc\n1\t2\t3\n", Dialect{Comma: '\t'})
+	test("a;b\n\"1;5\";2\n", Dialect{Comma: ';'})
+	// The commas only split some records.
+	test("a|b|c\n1,5|2|3\n1|2,5|3\n", Dialect{Comma: '|'})
+	test("a,b\n1 \"inch\",2\n", Dialect{Comma: ',', LazyQuotes: true})
+	test("a\n1\n", Dialect{Comma: ','})
+	test("", Dialect{Comma: ','})
+}
+
+func TestSniffDialectPartialRecord(t *testing.T) {
+	assert := assert.New(t)
+	// The sample ends halfway through a record, which is ignored.
+	data := strings.Repeat("a;b;c\n", 100)
+	dialect, err := SniffDialect(bufio.NewReaderSize(strings.NewReader(data), len(data)-3))
+	assert.NoError(err)
+	assert.Equal(Dialect{Comma: ';'}, dialect)
+}
@@ -7,20 +7,26 @@ package csv
 import (
 	"encoding/csv"
 	"fmt"
-	"io"
 	"sort"
 	"strconv"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/datetime"
 )
 
+// DateTimeKind is the kind of columns which are parsed into datetime.DateTime
+// structs, the only kind of struct a column can hold. Its name is "DateTime".
+const DateTimeKind = types.StructKind
+
 // StringToKind maps names of valid NomsKinds (e.g. Bool, Number, etc) to their associated types.NomsKind
 var StringToKind = func(kindMap map[types.NomsKind]string) map[string]types.NomsKind {
 	m := map[string]types.NomsKind{}
 	for k, v := range kindMap {
 		m[v] = k
 	}
+	delete(m, DateTimeKind.String())
+	m["DateTime"] = DateTimeKind
 	return m
 }(types.KindToString)
 
@@ -41,7 +47,11 @@ func StringsToKinds(strs []string) KindSlice {
 func KindsToStrings(kinds KindSlice) []string {
 	strs := make([]string, len(kinds))
 	for i, k := range kinds {
-		strs[i] = k.String()
+		if k == DateTimeKind {
+			strs[i] = "DateTime"
+		} else {
+			strs[i] = k.String()
+		}
 	}
 	return strs
 }
@@ -74,7 +84,11 @@ func MakeStructTypeFromHeaders(headers []string, structName string, kinds KindSl
 		if ok {
 			d.Panic(`Duplicate field name "%s"`, key)
 		}
-		fieldMap[fn] = types.MakePrimitiveType(kind)
+		if kind == DateTimeKind {
+			fieldMap[fn] = datetime.DateTimeType
+		} else {
+			fieldMap[fn] = types.MakePrimitiveType(kind)
+		}
 		fieldNames[i] = fn
 	}
 
@@ -99,30 +113,12 @@ func MakeStructTypeFromHeaders(headers []string, structName string, kinds KindSl
 // If kinds is non-empty, it will be used to type the fields in the generated structs; otherwise, they will be left as string-fields.
 // In addition to the list, ReadToList returns the typeDef of the structs in the list.
 func ReadToList(r *csv.Reader, structName string, headers []string, kinds KindSlice, vrw types.ValueReadWriter) (l types.List, t *types.Type) {
-	t, fieldOrder, kindMap := MakeStructTypeFromHeaders(headers, structName, kinds)
-	valueChan := make(chan types.Value, 128) // TODO: Make this a function param?
-	listChan := types.NewStreamingList(vrw, valueChan)
-
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			close(valueChan)
-			break
-		} else if err != nil {
-			panic(err)
-		}
-
-		fields := readFieldsFromRow(row, headers, fieldOrder, kindMap)
-		data := make(types.StructData, len(fields))
-		i := 0
-		t.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
-			data[name] = fields[i]
-			i++
-		})
-		valueChan <- types.NewStruct(structName, data)
+	rr := NewRowReader(r, structName, headers, kinds)
+	l, _, err := ReadRowsToList(rr, 0, vrw)
+	if err != nil {
+		panic(err)
 	}
-
-	return <-listChan, t
+	return l, rr.Type()
 }
 
 // getFieldIndexByHeaderName takes the collection of headers and the name to search for and returns the index of name within the headers or -1 if not found
@@ -152,21 +148,6 @@ func getPkIndices(strPks []string, headers []string) []int {
 	return result
 }
 
-func readFieldsFromRow(row []string, headers []string, fieldOrder []int, kindMap []types.NomsKind) types.ValueSlice {
-	fields := make(types.ValueSlice, len(headers))
-	for i, v := range row {
-		if i < len(headers) {
-			fieldOrigIndex := fieldOrder[i]
-			val, err := StringToValue(v, kindMap[fieldOrigIndex])
-			if err != nil {
-				d.Chk.Fail(fmt.Sprintf("Error parsing value for column '%s': %s", headers[i], err))
-			}
-			fields[fieldOrigIndex] = val
-		}
-	}
-	return fields
-}
-
 // primaryKeyValuesFromFields extracts the values of the primaryKey fields into
 // array. The values are in the user-specified order. This function returns 2
 // objects:
@@ -196,28 +177,9 @@ func primaryKeyValuesFromFields(fields types.ValueSlice, fieldOrder, pkIndices [
 // ReadToMap takes a CSV reader and reads data into a typed Map of structs. Each row gets read into a struct named structName, described by headers. If the original data contained headers it is expected that the input reader has already read those and are pointing at the first data row.
 // If kinds is non-empty, it will be used to type the fields in the generated structs; otherwise, they will be left as string-fields.
 func ReadToMap(r *csv.Reader, structName string, headersRaw []string, primaryKeys []string, kinds KindSlice, vrw types.ValueReadWriter) types.Map {
-	t, fieldOrder, kindMap := MakeStructTypeFromHeaders(headersRaw, structName, kinds)
-	pkIndices := getPkIndices(primaryKeys, headersRaw)
-	d.Chk.True(len(pkIndices) >= 1, "No primary key defined when reading into map")
-	gb := types.NewGraphBuilder(vrw, types.MapKind, false)
-
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic(err)
-		}
-
-		fields := readFieldsFromRow(row, headersRaw, fieldOrder, kindMap)
-		graphKeys, mapKey := primaryKeyValuesFromFields(fields, fieldOrder, pkIndices)
-		data := make(types.StructData, len(fields))
-		i := 0
-		t.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
-			data[name] = fields[i]
-			i++
-		})
-		gb.MapSet(graphKeys, mapKey, types.NewStruct(structName, data))
+	m, _, err := ReadRowsToMap(NewRowReader(r, structName, headersRaw, kinds), primaryKeys, 0, vrw)
+	if err != nil {
+		panic(err)
 	}
-	return gb.Build().(types.Map)
+	return m
 }
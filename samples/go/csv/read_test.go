@@ -8,10 +8,13 @@ import (
 	"bytes"
 	"encoding/csv"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/marshal"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/datetime"
 	"github.com/attic-labs/testify/assert"
 )
 
@@ -250,3 +253,33 @@ func TestBooleanStrings(t *testing.T) {
 		assert.True(types.Bool(false).Equals(row.Get("F")))
 	}
 }
+
+func TestDateTimes(t *testing.T) {
+	assert := assert.New(t)
+	ds := datas.NewDatabase(chunks.NewMemoryStore())
+	dataString := "2017-03-04T05:06:07.5-01:00\n2017-03-04 05:06:07\n2017-03-04\n\"\"\n"
+	r := NewCSVReader(bytes.NewBufferString(dataString), ',')
+	headers := []string{"A"}
+	kinds := StringsToKinds([]string{"DateTime"})
+	assert.Equal([]string{"DateTime"}, KindsToStrings(kinds))
+
+	l, typ := ReadToList(r, "test", headers, kinds, ds)
+	fieldType, _ := typ.Desc.(types.StructDesc).Field("A")
+	assert.True(datetime.DateTimeType.Equals(fieldType))
+	assert.Equal(uint64(4), l.Len())
+
+	expected := []time.Time{
+		time.Date(2017, 3, 4, 6, 6, 7, 5e8, time.UTC),
+		time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC),
+		time.Date(2017, 3, 4, 0, 0, 0, 0, time.UTC),
+		time.Unix(0, 0),
+	}
+	for i, e := range expected {
+		var dt datetime.DateTime
+		assert.NoError(marshal.Unmarshal(l.Get(uint64(i)).(types.Struct).Get("A"), &dt))
+		assert.True(e.Equal(time.Time(dt)), "%s != %s", e, dt)
+	}
+
+	r = NewCSVReader(bytes.NewBufferString("yesterday\n"), ',')
+	assert.Panics(func() { ReadToList(r, "test", headers, kinds, ds) })
+}
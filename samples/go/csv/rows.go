@@ -0,0 +1,177 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// MalformedRow is a record of a CSV which couldn't be read into a row, because
+// it couldn't be parsed, it has fewer fields than there are headers or one of
+// its fields couldn't be converted to the kind of its column.
+type MalformedRow struct {
+	// Record is the number of records read before this one, not counting the
+	// headers.
+	Record int
+	Fields []string
+	Err    error
+}
+
+// RowReader reads the records of a CSV into structs named structName, with a
+// field for each of headers.
+type RowReader struct {
+	r          *csv.Reader
+	structName string
+	headers    []string
+	typ        *types.Type
+	fieldOrder []int
+	kindMap    []types.NomsKind
+
+	// Records is the number of records read so far, including malformed ones.
+	// It's set by callers which skipped records before the first row.
+	Records int
+	// OnMalformed is called with each malformed row, which is skipped unless
+	// it returns an error. If it's nil, a malformed row is an error.
+	OnMalformed func(MalformedRow) error
+}
+
+// NewRowReader returns a RowReader of the rows of r. If kinds is non-empty, it
+// types the fields of the structs; otherwise they're Strings. It's expected
+// that r has already read the headers, if the CSV has any.
+func NewRowReader(r *csv.Reader, structName string, headers []string, kinds KindSlice) *RowReader {
+	t, fieldOrder, kindMap := MakeStructTypeFromHeaders(headers, structName, kinds)
+	return &RowReader{r: r, structName: structName, headers: headers, typ: t, fieldOrder: fieldOrder, kindMap: kindMap}
+}
+
+// Type returns the type of the structs read by rr.
+func (rr *RowReader) Type() *types.Type {
+	return rr.typ
+}
+
+// Read returns the next row and its fields, ordered as those of the struct,
+// or io.EOF after the last one.
+func (rr *RowReader) Read() (types.Struct, types.ValueSlice, error) {
+	for {
+		record, err := rr.r.Read()
+		if err == io.EOF {
+			return types.Struct{}, nil, err
+		}
+		var fields types.ValueSlice
+		if err == nil {
+			fields, err = rr.readFields(record)
+		}
+		if err != nil {
+			if rr.OnMalformed == nil {
+				return types.Struct{}, nil, err
+			}
+			err = rr.OnMalformed(MalformedRow{rr.Records, record, err})
+			rr.Records++
+			if err != nil {
+				return types.Struct{}, nil, err
+			}
+			continue
+		}
+		rr.Records++
+
+		data := make(types.StructData, len(fields))
+		i := 0
+		rr.typ.Desc.(types.StructDesc).IterFields(func(name string, t *types.Type, optional bool) {
+			data[name] = fields[i]
+			i++
+		})
+		return types.NewStruct(rr.structName, data), fields, nil
+	}
+}
+
+// readFields converts the fields of record to the kinds of their columns.
+// Fields beyond the last header are ignored.
+func (rr *RowReader) readFields(record []string) (types.ValueSlice, error) {
+	if len(record) < len(rr.headers) {
+		return nil, fmt.Errorf("Record %d has %d fields, expected %d", rr.Records+1, len(record), len(rr.headers))
+	}
+	fields := make(types.ValueSlice, len(rr.headers))
+	for i, v := range record[:len(rr.headers)] {
+		fieldOrigIndex := rr.fieldOrder[i]
+		val, err := StringToValue(v, rr.kindMap[fieldOrigIndex])
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing value for column '%s' of record %d: %s", rr.headers[i], rr.Records+1, err)
+		}
+		fields[fieldOrigIndex] = val
+	}
+	return fields, nil
+}
+
+// ReadRowsToList reads the next n records of rr, all of them if n is 0, into
+// a List. It returns the List and the number of records read, malformed ones
+// included, which is less than n once there are none left.
+func ReadRowsToList(rr *RowReader, n int, vrw types.ValueReadWriter) (types.List, int, error) {
+	valueChan := make(chan types.Value, 128) // TODO: Make this a function param?
+	listChan := types.NewStreamingList(vrw, valueChan)
+
+	start := rr.Records
+	var err error
+	for n == 0 || rr.Records-start < n {
+		var st types.Struct
+		if st, _, err = rr.Read(); err != nil {
+			break
+		}
+		valueChan <- st
+	}
+	close(valueChan)
+	l := <-listChan
+	if err == io.EOF {
+		err = nil
+	}
+	return l, rr.Records - start, err
+}
+
+// ReadRowsToMap is like ReadRowsToList, but reads the rows into a Map keyed by
+// their primaryKeys, which are the names or indices of columns. With several
+// primary keys, the Map is nested, keyed by the first one at the top level.
+func ReadRowsToMap(rr *RowReader, primaryKeys []string, n int, vrw types.ValueReadWriter) (types.Map, int, error) {
+	pkIndices := getPkIndices(primaryKeys, rr.headers)
+	d.Chk.True(len(pkIndices) >= 1, "No primary key defined when reading into map")
+	gb := types.NewGraphBuilder(vrw, types.MapKind, false)
+
+	start := rr.Records
+	var err error
+	for n == 0 || rr.Records-start < n {
+		var st types.Struct
+		var fields types.ValueSlice
+		if st, fields, err = rr.Read(); err != nil {
+			break
+		}
+		graphKeys, mapKey := primaryKeyValuesFromFields(fields, rr.fieldOrder, pkIndices)
+		gb.MapSet(graphKeys, mapKey, st)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		return types.Map{}, rr.Records - start, err
+	}
+	return gb.Build().(types.Map), rr.Records - start, nil
+}
+
+// MergeMaps returns a with the entries of b set in it. The values of the top
+// depth-1 levels of both are Maps, as read by ReadRowsToMap with depth primary
+// keys, which are merged in turn.
+func MergeMaps(a, b types.Map, depth int) types.Map {
+	kvs := make([]types.Value, 0, 2*b.Len())
+	b.IterAll(func(k, v types.Value) {
+		if depth > 1 {
+			if av, ok := a.MaybeGet(k); ok {
+				v = MergeMaps(av.(types.Map), v.(types.Map), depth-1)
+			}
+		}
+		kvs = append(kvs, k, v)
+	})
+	return a.SetM(kvs...)
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+const malformedData = `a,1
+b,x
+c
+d,"4
+`
+
+func TestRowReaderMalformed(t *testing.T) {
+	assert := assert.New(t)
+	headers := []string{"A", "B"}
+	kinds := KindSlice{types.StringKind, types.NumberKind}
+
+	rr := NewRowReader(NewCSVReader(bytes.NewBufferString(malformedData), ','), "test", headers, kinds)
+	_, _, err := rr.Read()
+	assert.NoError(err)
+	_, _, err = rr.Read()
+	assert.Contains(err.Error(), "Error parsing value for column 'B' of record 2")
+
+	malformed := []MalformedRow{}
+	rr = NewRowReader(NewCSVReader(bytes.NewBufferString(malformedData), ','), "test", headers, kinds)
+	rr.OnMalformed = func(row MalformedRow) error {
+		malformed = append(malformed, row)
+		return nil
+	}
+	l, n, err := ReadRowsToList(rr, 0, datas.NewDatabase(chunks.NewMemoryStore()))
+	assert.NoError(err)
+	assert.Equal(4, n)
+	assert.Equal(uint64(1), l.Len())
+	assert.True(types.String("a").Equals(l.Get(0).(types.Struct).Get("A")))
+
+	assert.Len(malformed, 3)
+	assert.Equal(1, malformed[0].Record)
+	assert.Equal([]string{"b", "x"}, malformed[0].Fields)
+	assert.Equal(2, malformed[1].Record)
+	assert.Contains(malformed[1].Err.Error(), "Record 3 has 1 fields, expected 2")
+	assert.Equal(3, malformed[2].Record)
+
+	fail := errors.New("fail")
+	rr = NewRowReader(NewCSVReader(bytes.NewBufferString(malformedData), ','), "test", headers, kinds)
+	rr.OnMalformed = func(row MalformedRow) error {
+		return fail
+	}
+	_, n, err = ReadRowsToList(rr, 0, datas.NewDatabase(chunks.NewMemoryStore()))
+	assert.Equal(fail, err)
+	assert.Equal(2, n)
+}
+
+func TestReadRowsInBatches(t *testing.T) {
+	assert := assert.New(t)
+	ds := datas.NewDatabase(chunks.NewMemoryStore())
+	dataString := "1,a\n1,b\n2,a\n2,c\n3,a\n"
+	headers := []string{"A", "B"}
+	kinds := KindSlice{types.NumberKind, types.StringKind}
+
+	rr := NewRowReader(NewCSVReader(bytes.NewBufferString(dataString), ','), "test", headers, kinds)
+	l, n, err := ReadRowsToList(rr, 2, ds)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	for n == 2 {
+		var batch types.List
+		batch, n, err = ReadRowsToList(rr, 2, ds)
+		assert.NoError(err)
+		l = l.Concat(batch)
+	}
+	assert.Equal(1, n)
+	assert.Equal(5, rr.Records)
+	expected, _ := ReadToList(NewCSVReader(bytes.NewBufferString(dataString), ','), "test", headers, kinds, ds)
+	assert.True(expected.Equals(l))
+
+	rr = NewRowReader(NewCSVReader(bytes.NewBufferString(dataString), ','), "test", headers, kinds)
+	m, n, err := ReadRowsToMap(rr, []string{"A", "B"}, 3, ds)
+	assert.NoError(err)
+	assert.Equal(3, n)
+	batch, n, err := ReadRowsToMap(rr, []string{"A", "B"}, 3, ds)
+	assert.NoError(err)
+	assert.Equal(2, n)
+	m = MergeMaps(m, batch, 2)
+	expectedMap := ReadToMap(NewCSVReader(bytes.NewBufferString(dataString), ','), "test", headers, []string{"A", "B"}, kinds, ds)
+	assert.True(expectedMap.Equals(m))
+	assert.Equal(uint64(2), m.Get(types.Number(2)).(types.Map).Len())
+}
@@ -10,11 +10,23 @@ import (
 	"io"
 	"math"
 	"strconv"
+	"time"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/datetime"
 )
 
+// DateTimeLayouts are the layouts, as taken by time.Parse, which the values of
+// DateTime columns are parsed with, in order. Times without a time zone are
+// in UTC.
+var DateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
 type schemaOptions []*typeCanFit
 
 func newSchemaOptions(fieldCount int) schemaOptions {
@@ -237,6 +249,16 @@ func StringToValue(s string, k types.NomsKind) (types.Value, error) {
 		}
 	case types.StringKind:
 		return types.String(s), nil
+	case DateTimeKind:
+		if s == "" {
+			return datetime.DateTime(time.Unix(0, 0)).MarshalNoms()
+		}
+		for _, layout := range DateTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return datetime.DateTime(t).MarshalNoms()
+			}
+		}
+		return nil, fmt.Errorf("Could not parse '%s' into date time", s)
 	default:
 		d.Panic("Invalid column type kind:", k)
 	}
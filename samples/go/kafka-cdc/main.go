@@ -0,0 +1,74 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/attic-labs/noms/go/cdc"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/util/exit"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+func main() {
+	if !kafkaCDC() {
+		exit.Fail()
+	}
+}
+
+func kafkaCDC() bool {
+	restProxy := flag.String("rest-proxy", "http://localhost:8082", "URL of the Kafka REST Proxy")
+	topic := flag.String("topic", "", "Kafka topic to publish changes to")
+	checkpoint := flag.String("checkpoint", "", "file keeping the last published commit")
+	interval := flag.Duration("interval", 0, "how often to check the dataset for new commits, e.g. 10s; if 0, publish once and exit")
+	verbose.RegisterVerboseFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse(true)
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return false
+	}
+	if *topic == "" || *checkpoint == "" {
+		fmt.Fprintln(os.Stderr, "--topic and --checkpoint are required")
+		return false
+	}
+
+	pub := cdc.KafkaRESTPublisher{URL: *restProxy, Topic: *topic}
+	cp := cdc.FileCheckpoint(*checkpoint)
+	cfg := config.NewResolver()
+	for {
+		db, ds, err := cfg.GetDataset(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid dataset '%s': %s\n", flag.Arg(0), err)
+			return false
+		}
+		n, err := cdc.Publish(ds, pub, cp)
+		db.Close()
+		if n > 0 {
+			verbose.Log("Published %d commits of %s to %s", n, flag.Arg(0), *topic)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not publish changes: %s\n", err)
+			return false
+		}
+		if *interval == 0 {
+			return true
+		}
+		time.Sleep(*interval)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Kafka-cdc publishes the changes made by each commit of a dataset to a Kafka topic.\n\n")
+	fmt.Fprintf(os.Stderr, "Usage: %s --topic=<topic> --checkpoint=<file> [--rest-proxy=<url>] [--interval=<duration>] <ds>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  <ds> : Dataset to publish the changes of\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n\n")
+	flag.PrintDefaults()
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/p2p"
+	"github.com/attic-labs/noms/go/util/exit"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+func main() {
+	if !p2pSync() {
+		exit.Fail()
+	}
+}
+
+func p2pSync() bool {
+	port := flag.Int("port", 0, "port to serve the database to peers on; if 0, a free port is picked")
+	group := flag.String("group", p2p.DefaultGroup, "multicast address of the peers")
+	interval := flag.Duration("interval", 0, "time between announcements to peers (default 10s)")
+	secret := flag.String("secret", os.Getenv("P2P_SYNC_SECRET"), "secret shared by the peers, which announcements are signed with (default $P2P_SYNC_SECRET)")
+	verbose.RegisterVerboseFlags(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse(true)
+
+	if flag.NArg() < 2 || *secret == "" {
+		flag.Usage()
+		return false
+	}
+
+	cs, err := config.NewResolver().GetChunkStore(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid database '%s': %s\n", flag.Arg(0), err)
+		return false
+	}
+	node := p2p.NewNode(cs)
+	node.Datasets = flag.Args()[1:]
+	node.Port, node.Group, node.Interval, node.Secret = *port, *group, *interval, *secret
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		node.Stop()
+	}()
+
+	fmt.Printf("Replicating %s as %s\n", flag.Arg(0), node.ID)
+	if err := node.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not replicate: %s\n", err)
+		return false
+	}
+	return true
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "P2p-sync replicates datasets with peers on the same network.\n\n")
+	fmt.Fprintf(os.Stderr, "Usage: %s --secret=<secret> [--port=<port>] [--group=<addr>] <db> <dataset>...\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  <db>      : Database to replicate\n")
+	fmt.Fprintf(os.Stderr, "  <dataset> : Datasets to replicate; peers can't update any others\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n\n")
+	flag.PrintDefaults()
+}
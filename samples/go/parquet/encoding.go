@@ -0,0 +1,173 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/golang/snappy"
+)
+
+var errTruncatedPage = errors.New("truncated page")
+
+func decompress(codec int64, data []byte) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return data, nil
+	case codecSnappy:
+		return snappy.Decode(nil, data)
+	case codecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	}
+	return nil, fmt.Errorf("compression codec %d is not supported", codec)
+}
+
+// decodeLevels decodes n values of bitWidth bits encoded with the hybrid
+// RLE/bit-packing encoding used for definition levels and dictionary indices.
+func decodeLevels(b []byte, bitWidth uint, n int) ([]int, error) {
+	if bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width %d", bitWidth)
+	}
+	out := make([]int, 0, n)
+	byteWidth := int(bitWidth+7) / 8
+	pos := 0
+	for len(out) < n {
+		h, k := binary.Uvarint(b[pos:])
+		if k <= 0 || h>>1 == 0 {
+			return nil, errTruncatedPage
+		}
+		pos += k
+
+		if h&1 == 0 {
+			// A run of the same value.
+			if pos+byteWidth > len(b) {
+				return nil, errTruncatedPage
+			}
+			v := 0
+			for i := 0; i < byteWidth; i++ {
+				v |= int(b[pos+i]) << (8 * uint(i))
+			}
+			pos += byteWidth
+			for i := uint64(0); i < h>>1 && len(out) < n; i++ {
+				out = append(out, v)
+			}
+			continue
+		}
+
+		// Groups of 8 bit-packed values, least significant bit first.
+		count := int(h>>1) * 8
+		size := int(h>>1) * int(bitWidth)
+		if count < 0 || size < 0 {
+			return nil, errTruncatedPage
+		}
+		for i := 0; i < count && len(out) < n; i++ {
+			v := 0
+			for j := 0; j < int(bitWidth); j++ {
+				bit := i*int(bitWidth) + j
+				if pos+bit/8 >= len(b) {
+					return nil, errTruncatedPage
+				}
+				v |= int(b[pos+bit/8]>>uint(bit%8)&1) << uint(j)
+			}
+			out = append(out, v)
+		}
+		pos += size
+		if pos > len(b) {
+			pos = len(b)
+		}
+	}
+	return out, nil
+}
+
+// decodePlain decodes n values of column c from b, in the PLAIN encoding.
+func decodePlain(c Column, b []byte, n int) ([]interface{}, error) {
+	out := make([]interface{}, n)
+	pos := 0
+	next := func(size int) ([]byte, error) {
+		if size < 0 || pos+size > len(b) {
+			return nil, errTruncatedPage
+		}
+		v := b[pos : pos+size]
+		pos += size
+		return v, nil
+	}
+
+	for i := range out {
+		var v interface{}
+		switch c.physicalType {
+		case typeBoolean:
+			if i/8 >= len(b) {
+				return nil, errTruncatedPage
+			}
+			v = b[i/8]>>uint(i%8)&1 == 1
+		case typeInt32:
+			bs, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			v = int64(int32(binary.LittleEndian.Uint32(bs)))
+		case typeInt64:
+			bs, err := next(8)
+			if err != nil {
+				return nil, err
+			}
+			v = int64(binary.LittleEndian.Uint64(bs))
+		case typeInt96:
+			bs, err := next(12)
+			if err != nil {
+				return nil, err
+			}
+			v = int96ToUnixMillis(bs)
+		case typeFloat:
+			bs, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(bs)))
+		case typeDouble:
+			bs, err := next(8)
+			if err != nil {
+				return nil, err
+			}
+			v = math.Float64frombits(binary.LittleEndian.Uint64(bs))
+		case typeByteArray:
+			bs, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			if v, err = next(int(binary.LittleEndian.Uint32(bs))); err != nil {
+				return nil, err
+			}
+		case typeFixedLenByteArray:
+			var err error
+			if v, err = next(c.typeLength); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown physical type %d of column %s", c.physicalType, c.Name)
+		}
+		out[i] = c.convert(v)
+	}
+	return out, nil
+}
+
+// int96ToUnixMillis converts an INT96 timestamp, the nanoseconds within the
+// day followed by the Julian day, to milliseconds since the Unix epoch.
+func int96ToUnixMillis(b []byte) float64 {
+	const julianUnixEpoch = 2440588
+	nanos := binary.LittleEndian.Uint64(b[:8])
+	day := int64(binary.LittleEndian.Uint32(b[8:]))
+	return float64(day-julianUnixEpoch)*24*60*60*1000 + float64(nanos)/1e6
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+// Constants and thrift field ids from
+// https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift
+
+const magic = "PAR1"
+
+// Physical types.
+const (
+	typeBoolean = iota
+	typeInt32
+	typeInt64
+	typeInt96
+	typeFloat
+	typeDouble
+	typeByteArray
+	typeFixedLenByteArray
+)
+
+// Field repetition types.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// Converted types.
+const (
+	convertedUTF8    = 0
+	convertedEnum    = 4
+	convertedDecimal = 5
+	convertedJSON    = 19
+)
+
+// Logical types, which are the fields of the LogicalType union.
+const (
+	logicalString  = 1
+	logicalEnum    = 4
+	logicalDecimal = 5
+	logicalJSON    = 12
+)
+
+// Encodings.
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+	encodingRLEDictionary   = 8
+)
+
+// Compression codecs.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+)
+
+// Page types.
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// FileMetaData fields.
+const (
+	fileMetaDataVersion   = 1
+	fileMetaDataSchema    = 2
+	fileMetaDataNumRows   = 3
+	fileMetaDataRowGroups = 4
+	fileMetaDataCreatedBy = 6
+)
+
+// SchemaElement fields.
+const (
+	schemaElementType          = 1
+	schemaElementTypeLength    = 2
+	schemaElementRepetition    = 3
+	schemaElementName          = 4
+	schemaElementNumChildren   = 5
+	schemaElementConvertedType = 6
+	schemaElementScale         = 7
+	schemaElementPrecision     = 8
+	schemaElementLogicalType   = 10
+)
+
+// RowGroup fields.
+const (
+	rowGroupColumns       = 1
+	rowGroupTotalByteSize = 2
+	rowGroupNumRows       = 3
+)
+
+// ColumnChunk fields.
+const (
+	columnChunkFilePath   = 1
+	columnChunkFileOffset = 2
+	columnChunkMetaData   = 3
+)
+
+// ColumnMetaData fields.
+const (
+	columnMetaDataType                  = 1
+	columnMetaDataEncodings             = 2
+	columnMetaDataPathInSchema          = 3
+	columnMetaDataCodec                 = 4
+	columnMetaDataNumValues             = 5
+	columnMetaDataTotalUncompressedSize = 6
+	columnMetaDataTotalCompressedSize   = 7
+	columnMetaDataDataPageOffset        = 9
+	columnMetaDataDictionaryPageOffset  = 11
+)
+
+// PageHeader fields.
+const (
+	pageHeaderType                 = 1
+	pageHeaderUncompressedSize     = 2
+	pageHeaderCompressedSize       = 3
+	pageHeaderDataPageHeader       = 5
+	pageHeaderDictionaryPageHeader = 7
+	pageHeaderDataPageHeaderV2     = 8
+)
+
+// DataPageHeader fields.
+const (
+	dataPageHeaderNumValues               = 1
+	dataPageHeaderEncoding                = 2
+	dataPageHeaderDefinitionLevelEncoding = 3
+	dataPageHeaderRepetitionLevelEncoding = 4
+)
+
+// DictionaryPageHeader fields.
+const (
+	dictionaryPageHeaderNumValues = 1
+	dictionaryPageHeaderEncoding  = 2
+)
+
+// DataPageHeaderV2 fields.
+const (
+	dataPageHeaderV2NumValues                  = 1
+	dataPageHeaderV2NumNulls                   = 2
+	dataPageHeaderV2NumRows                    = 3
+	dataPageHeaderV2Encoding                   = 4
+	dataPageHeaderV2DefinitionLevelsByteLength = 5
+	dataPageHeaderV2RepetitionLevelsByteLength = 6
+	dataPageHeaderV2IsCompressed               = 7
+)
@@ -0,0 +1,72 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/profile"
+	"github.com/attic-labs/noms/go/util/verbose"
+	"github.com/attic-labs/noms/samples/go/parquet"
+	flag "github.com/juju/gnuflag"
+)
+
+func main() {
+	compression := flag.String("compression", "snappy", "codec to compress pages with: none, snappy or gzip")
+	rowGroupSize := flag.Int("row-group-size", parquet.DefaultRowGroupSize, "number of rows in each row group")
+	verbose.RegisterVerboseFlags(flag.CommandLine)
+	profile.RegisterProfileFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: parquet-export [options] <dataset> <parquetfile>")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse(true)
+
+	if flag.NArg() != 2 {
+		d.CheckError(errors.New("expected a dataset and a Parquet file"))
+	}
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(flag.Arg(0))
+	d.CheckError(err)
+	defer db.Close()
+
+	hv, ok := ds.MaybeHeadValue()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("dataset %s has no head", flag.Arg(0)))
+	}
+
+	out, err := os.Create(flag.Arg(1))
+	d.CheckErrorNoUsage(err)
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	defer profile.MaybeStartProfile().Stop()
+
+	opts := parquet.WriteOptions{Compression: *compression, RowGroupSize: *rowGroupSize}
+	switch hv := hv.(type) {
+	case types.List:
+		err = parquet.WriteList(hv, w, opts)
+	case types.Map:
+		err = parquet.WriteMap(hv, w, opts)
+	default:
+		err = fmt.Errorf("expected a List or Map of structs, found %s", types.TypeOf(hv).Describe())
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		os.Remove(flag.Arg(1))
+	}
+	d.CheckErrorNoUsage(err)
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/profile"
+	"github.com/attic-labs/noms/go/util/verbose"
+	"github.com/attic-labs/noms/samples/go/parquet"
+	flag "github.com/juju/gnuflag"
+)
+
+func main() {
+	name := flag.String("name", "Row", "struct name. The user-visible name to give to the struct type that will hold each row of data.")
+	columnNames := flag.String("columns", "", "a comma-separated list of the columns to import. If empty, all columns are imported")
+	destType := flag.String("dest-type", "list", "the destination type to import to. can be 'list' or 'map:<pk>', where <pk> is a comma-separated list of the names of the columns which uniquely identify a row")
+	parallelism := flag.Int("parallelism", 4, "number of row groups to read concurrently")
+	performCommit := flag.Bool("commit", true, "commit the data to head of the dataset (otherwise only write the data to the dataset)")
+	spec.RegisterCommitMetaFlags(flag.CommandLine)
+	verbose.RegisterVerboseFlags(flag.CommandLine)
+	profile.RegisterProfileFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: parquet-import [options] <parquetfile> <dataset>\n\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse(true)
+
+	if flag.NArg() != 2 {
+		d.CheckError(errors.New("expected a Parquet file and a dataset"))
+	}
+
+	var strPks []string
+	if *destType != "list" {
+		if !strings.HasPrefix(*destType, "map:") || *destType == "map:" {
+			d.CheckError(fmt.Errorf("Invalid dest-type: %s", *destType))
+		}
+		strPks = strings.Split(strings.TrimPrefix(*destType, "map:"), ",")
+	}
+
+	defer profile.MaybeStartProfile().Stop()
+
+	filePath := flag.Arg(0)
+	res, err := os.Open(filePath)
+	d.CheckError(err)
+	defer res.Close()
+	fi, err := res.Stat()
+	d.CheckError(err)
+
+	f, err := parquet.Open(res, fi.Size())
+	d.CheckErrorNoUsage(err)
+
+	columns := f.Columns
+	if *columnNames != "" {
+		columns, err = f.SelectColumns(strings.Split(*columnNames, ","))
+		d.CheckErrorNoUsage(err)
+	} else if len(f.Unsupported) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipping repeated or nested columns: %s\n", strings.Join(f.Unsupported, ", "))
+	}
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(flag.Arg(1))
+	d.CheckError(err)
+	defer db.Close()
+
+	var value types.Value
+	if strPks == nil {
+		value, err = parquet.ReadToList(f, *name, columns, *parallelism, db)
+	} else {
+		value, err = parquet.ReadToMap(f, *name, columns, strPks, *parallelism, db)
+	}
+	d.CheckErrorNoUsage(err)
+
+	if *performCommit {
+		meta, err := spec.CreateCommitMetaStruct(ds.Database(), "", "", map[string]string{"inputFile": filePath}, nil)
+		d.CheckErrorNoUsage(err)
+		_, err = db.Commit(ds, value, datas.CommitOptions{Meta: meta})
+		d.PanicIfError(err)
+	} else {
+		ref := db.WriteValue(value)
+		fmt.Fprintf(os.Stdout, "#%s\n", ref.TargetHash().String())
+	}
+}
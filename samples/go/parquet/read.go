@@ -0,0 +1,170 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// FieldName returns the name of the struct field a column is read into.
+func FieldName(column string) string {
+	if types.IsValidStructFieldName(column) {
+		return column
+	}
+	return types.CamelCaseFieldName(column)
+}
+
+type rowGroupResult struct {
+	values [][]interface{}
+	err    error
+}
+
+// iterRows calls cb with the values of columns in each row of f, in order.
+// Up to parallelism row groups are read concurrently.
+func iterRows(f *File, columns []Column, parallelism int, cb func(row []interface{}) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	done := make(chan struct{})
+	defer close(done)
+
+	// Use a channel of channels so that row groups can be read concurrently
+	// but their rows are still passed to cb in order.
+	results := make(chan chan rowGroupResult, parallelism-1)
+	go func() {
+		defer close(results)
+		for i := 0; i < f.NumRowGroups(); i++ {
+			ch := make(chan rowGroupResult, 1)
+			select {
+			case results <- ch:
+			case <-done:
+				return
+			}
+			go func(i int) {
+				values, err := f.ReadRowGroup(i, columns)
+				ch <- rowGroupResult{values, err}
+			}(i)
+		}
+	}()
+
+	row := make([]interface{}, len(columns))
+	i := 0
+	for ch := range results {
+		r := <-ch
+		if r.err != nil {
+			return r.err
+		}
+		for j := int64(0); j < f.rowGroups[i].numRows; j++ {
+			for c, values := range r.values {
+				row[c] = values[j]
+			}
+			if err := cb(row); err != nil {
+				return err
+			}
+		}
+		i++
+	}
+	return nil
+}
+
+func fieldNames(columns []Column) ([]string, error) {
+	names := make([]string, len(columns))
+	seen := map[string]bool{}
+	for i, c := range columns {
+		names[i] = FieldName(c.Name)
+		if seen[names[i]] {
+			return nil, fmt.Errorf(`Duplicate field name "%s"`, names[i])
+		}
+		seen[names[i]] = true
+	}
+	return names, nil
+}
+
+func rowToStruct(structName string, names []string, row []interface{}, vrw types.ValueReadWriter) types.Struct {
+	data := make(types.StructData, len(row))
+	for i, v := range row {
+		if v != nil {
+			data[names[i]] = toNomsValue(v, vrw)
+		}
+	}
+	return types.NewStruct(structName, data)
+}
+
+func toNomsValue(v interface{}, vrw types.ValueReadWriter) types.Value {
+	switch v := v.(type) {
+	case bool:
+		return types.Bool(v)
+	case float64:
+		return types.Number(v)
+	case string:
+		return types.String(v)
+	case []byte:
+		return types.NewStreamingBlob(vrw, bytes.NewReader(v))
+	}
+	panic("not reached")
+}
+
+// ReadToList reads the rows of f into a List of structs named structName,
+// with a field for each of columns, see FieldName. Null values are left out
+// of the structs. Up to parallelism row groups are read concurrently.
+func ReadToList(f *File, structName string, columns []Column, parallelism int, vrw types.ValueReadWriter) (types.List, error) {
+	names, err := fieldNames(columns)
+	if err != nil {
+		return types.List{}, err
+	}
+
+	valueChan := make(chan types.Value, 128)
+	listChan := types.NewStreamingList(vrw, valueChan)
+	err = iterRows(f, columns, parallelism, func(row []interface{}) error {
+		valueChan <- rowToStruct(structName, names, row, vrw)
+		return nil
+	})
+	close(valueChan)
+	l := <-listChan
+	return l, err
+}
+
+// ReadToMap is like ReadToList but reads the rows into a Map keyed by the
+// values of the primaryKeys columns. If there is more than one primary key,
+// the Map is nested, with a level per key.
+func ReadToMap(f *File, structName string, columns []Column, primaryKeys []string, parallelism int, vrw types.ValueReadWriter) (types.Map, error) {
+	d.PanicIfTrue(len(primaryKeys) == 0)
+	names, err := fieldNames(columns)
+	if err != nil {
+		return types.Map{}, err
+	}
+	pkIndices := make([]int, len(primaryKeys))
+outer:
+	for i, pk := range primaryKeys {
+		for j, c := range columns {
+			if c.Name == pk {
+				pkIndices[i] = j
+				continue outer
+			}
+		}
+		return types.Map{}, fmt.Errorf("Invalid pk: %s", pk)
+	}
+
+	gb := types.NewGraphBuilder(vrw, types.MapKind, false)
+	err = iterRows(f, columns, parallelism, func(row []interface{}) error {
+		keys := make(types.ValueSlice, len(pkIndices))
+		for i, idx := range pkIndices {
+			if row[idx] == nil {
+				return fmt.Errorf("null value in primary key column %s", columns[idx].Name)
+			}
+			keys[i] = toNomsValue(row[idx], vrw)
+		}
+		gb.MapSet(keys[:len(keys)-1], keys[len(keys)-1], rowToStruct(structName, names, row, vrw))
+		return nil
+	})
+	if err != nil {
+		return types.Map{}, err
+	}
+	return gb.Build().(types.Map), nil
+}
@@ -0,0 +1,432 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+var errNotParquet = errors.New("not a Parquet file")
+
+// Column is a column of a Parquet file which can be read, that is one with a
+// primitive type that's neither repeated nor nested in a group.
+type Column struct {
+	// Name is the name of the column in the file.
+	Name string
+	// Kind is the NomsKind its values are read as: Bool, Number, String or
+	// Blob. Integers, decimals and floating point numbers are read as Numbers,
+	// INT96 timestamps as Numbers of milliseconds since the Unix epoch, UTF8,
+	// ENUM and JSON byte arrays as Strings and other byte arrays as Blobs.
+	Kind types.NomsKind
+	// Optional columns can have null values.
+	Optional bool
+
+	chunk        int // index of the column in the column chunks of row groups
+	physicalType int64
+	typeLength   int
+	decimal      bool
+	scale        int64
+}
+
+func newColumn(name string, el thriftStruct, chunk int) (Column, error) {
+	c := Column{
+		Name:         name,
+		Optional:     el.int(schemaElementRepetition) == repetitionOptional,
+		chunk:        chunk,
+		physicalType: el.int(schemaElementType),
+		typeLength:   int(el.int(schemaElementTypeLength)),
+	}
+
+	converted := int64(-1)
+	if el.has(schemaElementConvertedType) {
+		converted = el.int(schemaElementConvertedType)
+	}
+	logical := el.strct(schemaElementLogicalType)
+	isString := converted == convertedUTF8 || converted == convertedEnum || converted == convertedJSON ||
+		logical.has(logicalString) || logical.has(logicalEnum) || logical.has(logicalJSON)
+	c.decimal = converted == convertedDecimal || logical.has(logicalDecimal)
+	if c.decimal {
+		c.scale = el.int(schemaElementScale)
+		if dt := logical.strct(logicalDecimal); dt.has(1) {
+			c.scale = dt.int(1)
+		}
+	}
+
+	switch c.physicalType {
+	case typeBoolean:
+		c.Kind = types.BoolKind
+	case typeInt32, typeInt64, typeInt96, typeFloat, typeDouble:
+		c.Kind = types.NumberKind
+	case typeByteArray, typeFixedLenByteArray:
+		switch {
+		case c.decimal:
+			c.Kind = types.NumberKind
+		case isString:
+			c.Kind = types.StringKind
+		default:
+			c.Kind = types.BlobKind
+		}
+	default:
+		return Column{}, fmt.Errorf("unknown physical type %d of column %s", c.physicalType, name)
+	}
+	return c, nil
+}
+
+// convert converts a value decoded from the physical type of c to the Go
+// representation of its Kind: bool, float64, string or []byte.
+func (c Column) convert(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		if c.decimal {
+			f, _ := new(big.Rat).Quo(big.NewRat(v, 1), pow10(c.scale)).Float64()
+			return f
+		}
+		return float64(v)
+	case []byte:
+		switch c.Kind {
+		case types.NumberKind:
+			i := new(big.Int).SetBytes(v)
+			if len(v) > 0 && v[0]&0x80 != 0 {
+				// Two's complement.
+				i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(8*len(v))))
+			}
+			f, _ := new(big.Rat).SetFrac(i, pow10(c.scale).Num()).Float64()
+			return f
+		case types.StringKind:
+			return string(v)
+		}
+		return append([]byte{}, v...)
+	}
+	return v
+}
+
+func pow10(n int64) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil))
+}
+
+// File is a Parquet file opened for reading.
+type File struct {
+	// Columns are the columns of the file which can be read.
+	Columns []Column
+	// Unsupported are the names of the columns which can't be read because
+	// they're repeated or nested in a group.
+	Unsupported []string
+	NumRows     int64
+
+	r         io.ReaderAt
+	rowGroups []rowGroup
+}
+
+type rowGroup struct {
+	numRows int64
+	chunks  []thriftStruct // ColumnMetaData
+}
+
+// Open reads the metadata of the Parquet file in r, which is size bytes long.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, errNotParquet
+	}
+	head := make([]byte, len(magic))
+	tail := make([]byte, 4+len(magic))
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if _, err := r.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return nil, err
+	}
+	if string(head) != magic || string(tail[4:]) != magic {
+		return nil, errNotParquet
+	}
+
+	footerSize := int64(binary.LittleEndian.Uint32(tail))
+	if footerSize > size-int64(len(head)+len(tail)) {
+		return nil, errNotParquet
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-int64(len(tail))-footerSize); err != nil {
+		return nil, err
+	}
+	md, _, err := decodeThriftStruct(footer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet metadata: %s", err)
+	}
+
+	f := &File{NumRows: md.int(fileMetaDataNumRows), r: r}
+	numChunks, err := f.parseSchema(md.list(fileMetaDataSchema))
+	if err != nil {
+		return nil, err
+	}
+	for _, rg := range md.list(fileMetaDataRowGroups) {
+		rg, _ := rg.(thriftStruct)
+		chunks := rg.list(rowGroupColumns)
+		if len(chunks) != numChunks {
+			return nil, fmt.Errorf("invalid Parquet metadata: expected %d column chunks, found %d", numChunks, len(chunks))
+		}
+		g := rowGroup{numRows: rg.int(rowGroupNumRows), chunks: make([]thriftStruct, len(chunks))}
+		for i, cc := range chunks {
+			cc, _ := cc.(thriftStruct)
+			if cc.has(columnChunkFilePath) {
+				return nil, errors.New("column chunks in other files are not supported")
+			}
+			g.chunks[i] = cc.strct(columnChunkMetaData)
+		}
+		f.rowGroups = append(f.rowGroups, g)
+	}
+	return f, nil
+}
+
+// parseSchema sets the Columns and Unsupported columns of f from the
+// flattened schema tree and returns the number of leaf columns.
+func (f *File) parseSchema(elems []interface{}) (int, error) {
+	if len(elems) == 0 {
+		return 0, errors.New("invalid Parquet metadata: empty schema")
+	}
+	root, _ := elems[0].(thriftStruct)
+	i, leaves := 1, 0
+
+	var walk func(prefix string, numChildren int, nested bool) error
+	walk = func(prefix string, numChildren int, nested bool) error {
+		for n := 0; n < numChildren; n++ {
+			if i >= len(elems) {
+				return errors.New("invalid Parquet metadata: truncated schema")
+			}
+			el, _ := elems[i].(thriftStruct)
+			i++
+			name := prefix + el.string(schemaElementName)
+
+			if !el.has(schemaElementType) {
+				// A group.
+				if !nested {
+					f.Unsupported = append(f.Unsupported, name)
+				}
+				if err := walk(name+".", int(el.int(schemaElementNumChildren)), true); err != nil {
+					return err
+				}
+				continue
+			}
+
+			leaves++
+			if nested {
+				continue
+			}
+			if el.int(schemaElementRepetition) == repetitionRepeated {
+				f.Unsupported = append(f.Unsupported, name)
+				continue
+			}
+			c, err := newColumn(name, el, leaves-1)
+			if err != nil {
+				return err
+			}
+			f.Columns = append(f.Columns, c)
+		}
+		return nil
+	}
+	if err := walk("", int(root.int(schemaElementNumChildren)), false); err != nil {
+		return 0, err
+	}
+	return leaves, nil
+}
+
+// SelectColumns returns the columns of f with the given names, in that order.
+func (f *File) SelectColumns(names []string) ([]Column, error) {
+	columns := make([]Column, len(names))
+outer:
+	for i, name := range names {
+		for _, c := range f.Columns {
+			if c.Name == name {
+				columns[i] = c
+				continue outer
+			}
+		}
+		for _, u := range f.Unsupported {
+			if u == name {
+				return nil, fmt.Errorf("column %s is repeated or nested, which is not supported", name)
+			}
+		}
+		return nil, fmt.Errorf("unknown column %s", name)
+	}
+	return columns, nil
+}
+
+// NumRowGroups returns the number of row groups in f.
+func (f *File) NumRowGroups() int {
+	return len(f.rowGroups)
+}
+
+// ReadRowGroup reads the values of columns in the i-th row group of f. For
+// each column it returns a value per row, nil for nulls, and bool, float64,
+// string or []byte otherwise, see Column.Kind.
+func (f *File) ReadRowGroup(i int, columns []Column) ([][]interface{}, error) {
+	rg := f.rowGroups[i]
+	values := make([][]interface{}, len(columns))
+	for j, c := range columns {
+		vs, err := f.readColumnChunk(c, rg.chunks[c.chunk])
+		if err != nil {
+			return nil, fmt.Errorf("reading column %s: %s", c.Name, err)
+		}
+		if int64(len(vs)) != rg.numRows {
+			return nil, fmt.Errorf("reading column %s: expected %d values, found %d", c.Name, rg.numRows, len(vs))
+		}
+		values[j] = vs
+	}
+	return values, nil
+}
+
+func (f *File) readColumnChunk(c Column, md thriftStruct) ([]interface{}, error) {
+	start := md.int(columnMetaDataDataPageOffset)
+	if off := md.int(columnMetaDataDictionaryPageOffset); off > 0 && off < start {
+		start = off
+	}
+	size := md.int(columnMetaDataTotalCompressedSize)
+	if start < 0 || size < 0 {
+		return nil, errTruncatedPage
+	}
+	buf := make([]byte, size)
+	if _, err := f.r.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+
+	codec := md.int(columnMetaDataCodec)
+	numValues := int(md.int(columnMetaDataNumValues))
+	values := make([]interface{}, 0, numValues)
+	var dict []interface{}
+	for pos := 0; len(values) < numValues; {
+		if pos >= len(buf) {
+			return nil, errTruncatedPage
+		}
+		h, n, err := decodeThriftStruct(buf[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		size := int(h.int(pageHeaderCompressedSize))
+		if size < 0 || pos+size > len(buf) {
+			return nil, errTruncatedPage
+		}
+		page := buf[pos : pos+size]
+		pos += size
+
+		switch h.int(pageHeaderType) {
+		case pageDictionary:
+			dh := h.strct(pageHeaderDictionaryPageHeader)
+			data, err := decompress(codec, page)
+			if err != nil {
+				return nil, err
+			}
+			if dict, err = decodePlain(c, data, int(dh.int(dictionaryPageHeaderNumValues))); err != nil {
+				return nil, err
+			}
+		case pageData:
+			dh := h.strct(pageHeaderDataPageHeader)
+			data, err := decompress(codec, page)
+			if err != nil {
+				return nil, err
+			}
+			n := int(dh.int(dataPageHeaderNumValues))
+			var levels []int
+			if c.Optional {
+				if len(data) < 4 {
+					return nil, errTruncatedPage
+				}
+				l := int(binary.LittleEndian.Uint32(data))
+				if l < 0 || 4+l > len(data) {
+					return nil, errTruncatedPage
+				}
+				if levels, err = decodeLevels(data[4:4+l], 1, n); err != nil {
+					return nil, err
+				}
+				data = data[4+l:]
+			}
+			if values, err = appendValues(values, c, dh.int(dataPageHeaderEncoding), data, n, levels, dict); err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			dh := h.strct(pageHeaderDataPageHeaderV2)
+			n := int(dh.int(dataPageHeaderV2NumValues))
+			repLen := int(dh.int(dataPageHeaderV2RepetitionLevelsByteLength))
+			defLen := int(dh.int(dataPageHeaderV2DefinitionLevelsByteLength))
+			if repLen < 0 || defLen < 0 || repLen+defLen > len(page) {
+				return nil, errTruncatedPage
+			}
+			var levels []int
+			if c.Optional {
+				if levels, err = decodeLevels(page[repLen:repLen+defLen], 1, n); err != nil {
+					return nil, err
+				}
+			}
+			data := page[repLen+defLen:]
+			if !dh.has(dataPageHeaderV2IsCompressed) || dh.bool(dataPageHeaderV2IsCompressed) {
+				if data, err = decompress(codec, data); err != nil {
+					return nil, err
+				}
+			}
+			if values, err = appendValues(values, c, dh.int(dataPageHeaderV2Encoding), data, n, levels, dict); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// appendValues decodes the n values of a data page, the non-null ones of
+// which are encoded in data, and appends them to values. levels are the
+// definition levels of the values, or nil if the column is required.
+func appendValues(values []interface{}, c Column, encoding int64, data []byte, n int, levels []int, dict []interface{}) ([]interface{}, error) {
+	nonNull := n
+	if levels != nil {
+		nonNull = 0
+		for _, l := range levels {
+			if l != 0 {
+				nonNull++
+			}
+		}
+	}
+
+	var decoded []interface{}
+	switch encoding {
+	case encodingPlain:
+		var err error
+		if decoded, err = decodePlain(c, data, nonNull); err != nil {
+			return nil, err
+		}
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if len(data) == 0 {
+			return nil, errTruncatedPage
+		}
+		indices, err := decodeLevels(data[1:], uint(data[0]), nonNull)
+		if err != nil {
+			return nil, err
+		}
+		decoded = make([]interface{}, nonNull)
+		for i, idx := range indices {
+			if idx >= len(dict) {
+				return nil, fmt.Errorf("invalid dictionary index %d", idx)
+			}
+			decoded[i] = dict[idx]
+		}
+	default:
+		return nil, fmt.Errorf("encoding %d is not supported", encoding)
+	}
+
+	if levels == nil {
+		return append(values, decoded...), nil
+	}
+	for _, l := range levels {
+		if l == 0 {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, decoded[0])
+		decoded = decoded[1:]
+	}
+	return values, nil
+}
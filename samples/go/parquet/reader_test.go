@@ -0,0 +1,397 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+	"github.com/golang/snappy"
+)
+
+type testPage struct {
+	header thriftStruct
+	data   []byte
+}
+
+type testChunk struct {
+	codec     int64
+	numValues int64
+	pages     []testPage
+}
+
+// buildTestFile lays out a Parquet file with the given schema and row groups
+// of column chunks, filling in sizes and offsets.
+func buildTestFile(schema []thriftStruct, numRows []int64, rowGroups [][]testChunk) []byte {
+	buf := bytes.NewBufferString(magic)
+	rgs := []thriftStruct{}
+	total := int64(0)
+	for i, chunks := range rowGroups {
+		ccs := []thriftStruct{}
+		for _, c := range chunks {
+			start := int64(buf.Len())
+			for _, p := range c.pages {
+				p.header[pageHeaderCompressedSize] = int32(len(p.data))
+				if !p.header.has(pageHeaderUncompressedSize) {
+					p.header[pageHeaderUncompressedSize] = int32(len(p.data))
+				}
+				buf.Write(encodeThriftStruct(p.header))
+				buf.Write(p.data)
+			}
+			ccs = append(ccs, thriftStruct{
+				columnChunkFileOffset: start,
+				columnChunkMetaData: thriftStruct{
+					columnMetaDataType:                  int32(0),
+					columnMetaDataEncodings:             []int32{encodingPlain},
+					columnMetaDataPathInSchema:          []string{"x"},
+					columnMetaDataCodec:                 int32(c.codec),
+					columnMetaDataNumValues:             c.numValues,
+					columnMetaDataTotalUncompressedSize: int64(buf.Len()) - start,
+					columnMetaDataTotalCompressedSize:   int64(buf.Len()) - start,
+					columnMetaDataDataPageOffset:        start,
+				},
+			})
+		}
+		rgs = append(rgs, thriftStruct{
+			rowGroupColumns:       ccs,
+			rowGroupTotalByteSize: int64(0),
+			rowGroupNumRows:       numRows[i],
+		})
+		total += numRows[i]
+	}
+
+	footer := encodeThriftStruct(thriftStruct{
+		fileMetaDataVersion:   int32(1),
+		fileMetaDataSchema:    schema,
+		fileMetaDataNumRows:   total,
+		fileMetaDataRowGroups: rgs,
+	})
+	buf.Write(footer)
+	binary.Write(buf, binary.LittleEndian, uint32(len(footer)))
+	buf.WriteString(magic)
+	return buf.Bytes()
+}
+
+func dataPage(n int, encoding int32, data []byte) testPage {
+	return testPage{
+		header: thriftStruct{
+			pageHeaderType: int32(pageData),
+			pageHeaderDataPageHeader: thriftStruct{
+				dataPageHeaderNumValues:               int32(n),
+				dataPageHeaderEncoding:                encoding,
+				dataPageHeaderDefinitionLevelEncoding: int32(encodingRLE),
+				dataPageHeaderRepetitionLevelEncoding: int32(encodingRLE),
+			},
+		},
+		data: data,
+	}
+}
+
+// rleRuns encodes levels as runs of a single value each, with bit width 1.
+func rleRuns(levels ...int) []byte {
+	b := []byte{}
+	for _, l := range levels {
+		b = append(b, 1<<1, byte(l))
+	}
+	return b
+}
+
+func withLevels(levels []byte, data []byte) []byte {
+	b := make([]byte, 4, 4+len(levels)+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(len(levels)))
+	return append(append(b, levels...), data...)
+}
+
+func plainInt32s(vs ...int32) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, vs)
+	return buf.Bytes()
+}
+
+func plainInt64s(vs ...int64) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, vs)
+	return buf.Bytes()
+}
+
+func plainDoubles(vs ...float64) []byte {
+	buf := &bytes.Buffer{}
+	for _, v := range vs {
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+	}
+	return buf.Bytes()
+}
+
+func plainByteArrays(vs ...string) []byte {
+	buf := &bytes.Buffer{}
+	for _, v := range vs {
+		binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+		buf.WriteString(v)
+	}
+	return buf.Bytes()
+}
+
+func schemaRoot(numChildren int) thriftStruct {
+	return thriftStruct{schemaElementName: "schema", schemaElementNumChildren: int32(numChildren)}
+}
+
+func schemaColumn(name string, typ, repetition int32) thriftStruct {
+	return thriftStruct{schemaElementName: name, schemaElementType: typ, schemaElementRepetition: repetition}
+}
+
+func openTestFile(t *testing.T, b []byte) *File {
+	f, err := Open(bytes.NewReader(b), int64(len(b)))
+	assert.NoError(t, err)
+	return f
+}
+
+func readAll(t *testing.T, f *File) types.List {
+	l, err := ReadToList(f, "Row", f.Columns, 2, types.NewTestValueStore())
+	assert.NoError(t, err)
+	return l
+}
+
+func TestReadPlain(t *testing.T) {
+	assert := assert.New(t)
+	name := schemaColumn("name", typeByteArray, repetitionOptional)
+	name[schemaElementConvertedType] = int32(convertedUTF8)
+	schema := []thriftStruct{
+		schemaRoot(4),
+		schemaColumn("id", typeInt32, repetitionRequired),
+		name,
+		schemaColumn("score", typeDouble, repetitionRequired),
+		schemaColumn("is active", typeBoolean, repetitionRequired),
+	}
+	b := buildTestFile(schema, []int64{2, 1}, [][]testChunk{
+		{
+			{numValues: 2, pages: []testPage{dataPage(2, encodingPlain, plainInt32s(1, 2))}},
+			{numValues: 2, pages: []testPage{dataPage(2, encodingPlain, withLevels(rleRuns(1, 0), plainByteArrays("a")))}},
+			{numValues: 2, pages: []testPage{dataPage(2, encodingPlain, plainDoubles(1.5, -2))}},
+			{numValues: 2, pages: []testPage{dataPage(2, encodingPlain, []byte{0x1})}},
+		},
+		{
+			{numValues: 1, pages: []testPage{dataPage(1, encodingPlain, plainInt32s(3))}},
+			{numValues: 1, pages: []testPage{dataPage(1, encodingPlain, withLevels(rleRuns(1), plainByteArrays("c")))}},
+			{numValues: 1, pages: []testPage{dataPage(1, encodingPlain, plainDoubles(0))}},
+			{numValues: 1, pages: []testPage{dataPage(1, encodingPlain, []byte{0x0})}},
+		},
+	})
+
+	f := openTestFile(t, b)
+	assert.Equal(int64(3), f.NumRows)
+	assert.Equal(2, f.NumRowGroups())
+	assert.Equal([]types.NomsKind{types.NumberKind, types.StringKind, types.NumberKind, types.BoolKind},
+		[]types.NomsKind{f.Columns[0].Kind, f.Columns[1].Kind, f.Columns[2].Kind, f.Columns[3].Kind})
+	assert.True(f.Columns[1].Optional)
+	assert.False(f.Columns[0].Optional)
+
+	expected := types.NewList(
+		types.NewStruct("Row", types.StructData{"id": types.Number(1), "name": types.String("a"), "score": types.Number(1.5), "isActive": types.Bool(true)}),
+		types.NewStruct("Row", types.StructData{"id": types.Number(2), "score": types.Number(-2), "isActive": types.Bool(false)}),
+		types.NewStruct("Row", types.StructData{"id": types.Number(3), "name": types.String("c"), "score": types.Number(0), "isActive": types.Bool(false)}),
+	)
+	assert.True(expected.Equals(readAll(t, f)))
+
+	columns, err := f.SelectColumns([]string{"name", "id"})
+	assert.NoError(err)
+	l, err := ReadToList(f, "Row", columns, 1, types.NewTestValueStore())
+	assert.NoError(err)
+	assert.Equal(uint64(3), l.Len())
+	assert.True(types.NewStruct("Row", types.StructData{"id": types.Number(2)}).Equals(l.Get(1)))
+
+	m, err := ReadToMap(f, "Row", f.Columns, []string{"id"}, 2, types.NewTestValueStore())
+	assert.NoError(err)
+	assert.Equal(uint64(3), m.Len())
+	assert.True(expected.Get(2).Equals(m.Get(types.Number(3))))
+
+	_, err = ReadToMap(f, "Row", f.Columns, []string{"name"}, 2, types.NewTestValueStore())
+	assert.EqualError(err, "null value in primary key column name")
+}
+
+func TestReadDictionarySnappy(t *testing.T) {
+	assert := assert.New(t)
+	name := schemaColumn("name", typeByteArray, repetitionOptional)
+	name[schemaElementLogicalType] = thriftStruct{logicalString: thriftStruct{}}
+	schema := []thriftStruct{schemaRoot(1), name}
+
+	dict := snappy.Encode(nil, plainByteArrays("x", "y", "z"))
+	// 5 values, the 4th is null. The indices 2, 0, 1, 2 are bit-packed with a
+	// bit width of 2: 0b10, 0b00, 0b01, 0b10.
+	indices := []byte{2, 1<<1 | 1, 0x92, 0x00}
+	data := snappy.Encode(nil, withLevels(rleRuns(1, 1, 1, 0, 1), indices))
+	b := buildTestFile(schema, []int64{5}, [][]testChunk{{{
+		codec:     codecSnappy,
+		numValues: 5,
+		pages: []testPage{
+			{
+				header: thriftStruct{
+					pageHeaderType: int32(pageDictionary),
+					pageHeaderDictionaryPageHeader: thriftStruct{
+						dictionaryPageHeaderNumValues: int32(3),
+						dictionaryPageHeaderEncoding:  int32(encodingPlain),
+					},
+				},
+				data: dict,
+			},
+			dataPage(5, encodingRLEDictionary, data),
+		},
+	}}})
+
+	f := openTestFile(t, b)
+	l := readAll(t, f)
+	names := []string{}
+	l.IterAll(func(v types.Value, _ uint64) {
+		if n, ok := v.(types.Struct).MaybeGet("name"); ok {
+			names = append(names, string(n.(types.String)))
+		} else {
+			names = append(names, "<null>")
+		}
+	})
+	assert.Equal([]string{"z", "x", "y", "<null>", "z"}, names)
+}
+
+func TestReadDataPageV2(t *testing.T) {
+	assert := assert.New(t)
+	price := schemaColumn("price", typeInt64, repetitionOptional)
+	price[schemaElementConvertedType] = int32(convertedDecimal)
+	price[schemaElementScale] = int32(2)
+	price[schemaElementPrecision] = int32(10)
+	schema := []thriftStruct{schemaRoot(2), price, schemaColumn("data", typeByteArray, repetitionRequired)}
+
+	gz := func(b []byte) []byte {
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	v2Page := func(n, nulls int, levels, data []byte) testPage {
+		return testPage{
+			header: thriftStruct{
+				pageHeaderType: int32(pageDataV2),
+				pageHeaderDataPageHeaderV2: thriftStruct{
+					dataPageHeaderV2NumValues:                  int32(n),
+					dataPageHeaderV2NumNulls:                   int32(nulls),
+					dataPageHeaderV2NumRows:                    int32(n),
+					dataPageHeaderV2Encoding:                   int32(encodingPlain),
+					dataPageHeaderV2DefinitionLevelsByteLength: int32(len(levels)),
+					dataPageHeaderV2RepetitionLevelsByteLength: int32(0),
+				},
+			},
+			data: append(append([]byte{}, levels...), gz(data)...),
+		}
+	}
+	b := buildTestFile(schema, []int64{3}, [][]testChunk{{
+		{codec: codecGzip, numValues: 3, pages: []testPage{v2Page(3, 1, rleRuns(1, 0, 1), plainInt64s(1234, -5))}},
+		{codec: codecGzip, numValues: 3, pages: []testPage{v2Page(3, 0, nil, plainByteArrays("\x00\x01", "", "\xff"))}},
+	}})
+
+	f := openTestFile(t, b)
+	assert.Equal(types.NumberKind, f.Columns[0].Kind)
+	assert.Equal(types.BlobKind, f.Columns[1].Kind)
+	vs := types.NewTestValueStore()
+	l, err := ReadToList(f, "Row", f.Columns, 1, vs)
+	assert.NoError(err)
+	assert.True(types.NewStruct("Row", types.StructData{
+		"price": types.Number(12.34),
+		"data":  types.NewBlob(bytes.NewReader([]byte{0, 1})),
+	}).Equals(l.Get(0)))
+	assert.True(types.NewStruct("Row", types.StructData{
+		"data": types.NewBlob(bytes.NewReader([]byte{})),
+	}).Equals(l.Get(1)))
+	assert.Equal(types.Number(-0.05), l.Get(2).(types.Struct).Get("price"))
+}
+
+func TestUnsupportedColumns(t *testing.T) {
+	assert := assert.New(t)
+	schema := []thriftStruct{
+		schemaRoot(3),
+		schemaColumn("id", typeInt32, repetitionRequired),
+		{schemaElementName: "address", schemaElementRepetition: int32(repetitionOptional), schemaElementNumChildren: int32(2)},
+		schemaColumn("street", typeByteArray, repetitionOptional),
+		schemaColumn("city", typeByteArray, repetitionOptional),
+		schemaColumn("tags", typeByteArray, repetitionRepeated),
+	}
+	empty := testChunk{pages: []testPage{}}
+	b := buildTestFile(schema, []int64{1}, [][]testChunk{{
+		{numValues: 1, pages: []testPage{dataPage(1, encodingPlain, plainInt32s(42))}},
+		empty, empty, empty,
+	}})
+
+	f := openTestFile(t, b)
+	assert.Len(f.Columns, 1)
+	assert.Equal([]string{"address", "tags"}, f.Unsupported)
+
+	_, err := f.SelectColumns([]string{"tags"})
+	assert.EqualError(err, "column tags is repeated or nested, which is not supported")
+	_, err = f.SelectColumns([]string{"nope"})
+	assert.EqualError(err, "unknown column nope")
+
+	l := readAll(t, f)
+	assert.True(types.NewList(types.NewStruct("Row", types.StructData{"id": types.Number(42)})).Equals(l))
+}
+
+func TestOpenInvalid(t *testing.T) {
+	assert := assert.New(t)
+	for _, b := range []string{"", "PAR1PAR1", "PAR1\xff\x00\x00\x00PAR1", "PAR1\x01\x02\x00\x00\x00PAR1"} {
+		_, err := Open(bytes.NewReader([]byte(b)), int64(len(b)))
+		assert.Error(err, "%q", b)
+	}
+
+	schema := []thriftStruct{schemaRoot(1), schemaColumn("id", typeInt32, repetitionRequired)}
+	b := buildTestFile(schema, []int64{2}, [][]testChunk{{
+		{numValues: 2, pages: []testPage{dataPage(2, encodingPlain, plainInt32s(1))}},
+	}})
+	f := openTestFile(t, b)
+	_, err := ReadToList(f, "Row", f.Columns, 1, types.NewTestValueStore())
+	assert.EqualError(err, "reading column id: truncated page")
+}
+
+func TestDecodeLevels(t *testing.T) {
+	assert := assert.New(t)
+	// A run of 3 fives with bit width 3, then a bit-packed group of 8 values.
+	b := []byte{3 << 1, 5, 1<<1 | 1, 0x88, 0xc6, 0xfa}
+	levels, err := decodeLevels(b, 3, 11)
+	assert.NoError(err)
+	assert.Equal([]int{5, 5, 5, 0, 1, 2, 3, 4, 5, 6, 7}, levels)
+
+	_, err = decodeLevels(b, 3, 12)
+	assert.Error(err)
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	s := thriftStruct{
+		1:   int32(-7),
+		2:   true,
+		3:   false,
+		4:   int64(1) << 40,
+		5:   "hello",
+		20:  thriftStruct{1: []int32{1, 2, 3}},
+		100: []string{"a", "b"},
+		101: 1.5,
+	}
+	b := encodeThriftStruct(s)
+	d, n, err := decodeThriftStruct(b)
+	assert.NoError(err)
+	assert.Equal(len(b), n)
+	assert.Equal(int64(-7), d.int(1))
+	assert.True(d.bool(2))
+	assert.True(d.has(3))
+	assert.False(d.bool(3))
+	assert.Equal(int64(1)<<40, d.int(4))
+	assert.Equal("hello", d.string(5))
+	assert.Equal([]interface{}{int64(1), int64(2), int64(3)}, d.strct(20).list(1))
+	assert.Equal([]interface{}{[]byte("a"), []byte("b")}, d.list(100))
+	assert.Equal(1.5, d[101])
+
+	_, _, err = decodeThriftStruct(b[:len(b)-1])
+	assert.Error(err)
+}
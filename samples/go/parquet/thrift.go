@@ -0,0 +1,334 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The metadata of Parquet files is encoded with the Thrift compact protocol:
+// https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+//
+// Structs are decoded into thriftStructs mapping field ids to values, which
+// are bool, int64 (for all integer types), float64, []byte, []interface{}
+// (for lists and sets) and thriftStruct. Maps are skipped. The same types,
+// plus int32, string and typed slices, are encoded.
+
+const (
+	ctStop      = 0
+	ctBoolTrue  = 1
+	ctBoolFalse = 2
+	ctByte      = 3
+	ctI16       = 4
+	ctI32       = 5
+	ctI64       = 6
+	ctDouble    = 7
+	ctBinary    = 8
+	ctList      = 9
+	ctSet       = 10
+	ctMap       = 11
+	ctStruct    = 12
+)
+
+const maxThriftNesting = 64
+
+var errThriftTruncated = errors.New("truncated thrift data")
+
+type thriftStruct map[int16]interface{}
+
+func (s thriftStruct) has(id int16) bool {
+	_, ok := s[id]
+	return ok
+}
+
+func (s thriftStruct) int(id int16) int64 {
+	i, _ := s[id].(int64)
+	return i
+}
+
+func (s thriftStruct) bool(id int16) bool {
+	b, _ := s[id].(bool)
+	return b
+}
+
+func (s thriftStruct) string(id int16) string {
+	b, _ := s[id].([]byte)
+	return string(b)
+}
+
+func (s thriftStruct) strct(id int16) thriftStruct {
+	st, _ := s[id].(thriftStruct)
+	return st
+}
+
+func (s thriftStruct) list(id int16) []interface{} {
+	l, _ := s[id].([]interface{})
+	return l
+}
+
+type thriftDecoder struct {
+	b     []byte
+	pos   int
+	depth int
+}
+
+// decodeThriftStruct decodes a struct from the start of b and returns it,
+// along with the number of bytes it took.
+func decodeThriftStruct(b []byte) (s thriftStruct, n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+	d := &thriftDecoder{b: b}
+	s = d.readStruct()
+	return s, d.pos, nil
+}
+
+func (d *thriftDecoder) readByte() byte {
+	if d.pos >= len(d.b) {
+		panic(errThriftTruncated)
+	}
+	b := d.b[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *thriftDecoder) readBytes(n int) []byte {
+	if n < 0 || d.pos+n > len(d.b) {
+		panic(errThriftTruncated)
+	}
+	b := d.b[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *thriftDecoder) readUvarint() uint64 {
+	v, n := binary.Uvarint(d.b[d.pos:])
+	if n <= 0 {
+		panic(errThriftTruncated)
+	}
+	d.pos += n
+	return v
+}
+
+func (d *thriftDecoder) readVarint() int64 {
+	u := d.readUvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *thriftDecoder) readStruct() thriftStruct {
+	d.depth++
+	if d.depth > maxThriftNesting {
+		panic(errors.New("thrift data is nested too deeply"))
+	}
+	defer func() { d.depth-- }()
+
+	s := thriftStruct{}
+	id := int16(0)
+	for {
+		h := d.readByte()
+		typ := h & 0x0f
+		if typ == ctStop {
+			return s
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(d.readVarint())
+		}
+		switch typ {
+		case ctBoolTrue:
+			s[id] = true
+		case ctBoolFalse:
+			s[id] = false
+		default:
+			s[id] = d.readValue(typ)
+		}
+	}
+}
+
+func (d *thriftDecoder) readValue(typ byte) interface{} {
+	switch typ {
+	case ctBoolTrue, ctBoolFalse:
+		// Only in lists and sets, as a byte.
+		return d.readByte() == ctBoolTrue
+	case ctByte:
+		return int64(int8(d.readByte()))
+	case ctI16, ctI32, ctI64:
+		return d.readVarint()
+	case ctDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(d.readBytes(8)))
+	case ctBinary:
+		return d.readBytes(int(d.readUvarint()))
+	case ctList, ctSet:
+		h := d.readByte()
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(d.readUvarint())
+		}
+		if size > len(d.b)-d.pos {
+			// Every element takes at least a byte.
+			panic(errThriftTruncated)
+		}
+		l := make([]interface{}, size)
+		for i := range l {
+			l[i] = d.readValue(h & 0x0f)
+		}
+		return l
+	case ctMap:
+		size := int(d.readUvarint())
+		if size > 0 {
+			kv := d.readByte()
+			for i := 0; i < size; i++ {
+				d.readValue(kv >> 4)
+				d.readValue(kv & 0x0f)
+			}
+		}
+		return nil
+	case ctStruct:
+		return d.readStruct()
+	}
+	panic(fmt.Errorf("unknown thrift type %d", typ))
+}
+
+type thriftEncoder struct {
+	b []byte
+}
+
+func encodeThriftStruct(s thriftStruct) []byte {
+	e := &thriftEncoder{}
+	e.writeStruct(s)
+	return e.b
+}
+
+func (e *thriftEncoder) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	e.b = append(e.b, buf[:n]...)
+}
+
+func (e *thriftEncoder) writeVarint(v int64) {
+	e.writeUvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (e *thriftEncoder) writeStruct(s thriftStruct) {
+	ids := make([]int, 0, len(s))
+	for id := range s {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	last := int16(0)
+	for _, i := range ids {
+		id := int16(i)
+		v := s[id]
+		typ := thriftType(v)
+		if b, ok := v.(bool); ok && !b {
+			typ = ctBoolFalse
+		}
+		if delta := id - last; delta > 0 && delta <= 15 {
+			e.b = append(e.b, byte(delta<<4)|typ)
+		} else {
+			e.b = append(e.b, typ)
+			e.writeVarint(int64(id))
+		}
+		last = id
+		if _, ok := v.(bool); !ok {
+			e.writeValue(v)
+		}
+	}
+	e.b = append(e.b, ctStop)
+}
+
+func thriftType(v interface{}) byte {
+	switch v.(type) {
+	case bool:
+		return ctBoolTrue
+	case int32:
+		return ctI32
+	case int64:
+		return ctI64
+	case float64:
+		return ctDouble
+	case []byte, string:
+		return ctBinary
+	case thriftStruct:
+		return ctStruct
+	case []interface{}, []int32, []string, []thriftStruct:
+		return ctList
+	}
+	panic(fmt.Errorf("can't encode %T with thrift", v))
+}
+
+func (e *thriftEncoder) writeValue(v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			e.b = append(e.b, ctBoolTrue)
+		} else {
+			e.b = append(e.b, ctBoolFalse)
+		}
+	case int32:
+		e.writeVarint(int64(v))
+	case int64:
+		e.writeVarint(v)
+	case float64:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		e.b = append(e.b, buf[:]...)
+	case []byte:
+		e.writeUvarint(uint64(len(v)))
+		e.b = append(e.b, v...)
+	case string:
+		e.writeUvarint(uint64(len(v)))
+		e.b = append(e.b, v...)
+	case thriftStruct:
+		e.writeStruct(v)
+	case []int32:
+		e.writeListHeader(ctI32, len(v))
+		for _, i := range v {
+			e.writeVarint(int64(i))
+		}
+	case []string:
+		e.writeListHeader(ctBinary, len(v))
+		for _, s := range v {
+			e.writeValue(s)
+		}
+	case []thriftStruct:
+		e.writeListHeader(ctStruct, len(v))
+		for _, s := range v {
+			e.writeStruct(s)
+		}
+	case []interface{}:
+		typ := byte(ctI32)
+		if len(v) > 0 {
+			typ = thriftType(v[0])
+		}
+		e.writeListHeader(typ, len(v))
+		for _, elem := range v {
+			e.writeValue(elem)
+		}
+	default:
+		panic(fmt.Errorf("can't encode %T with thrift", v))
+	}
+}
+
+func (e *thriftEncoder) writeListHeader(typ byte, size int) {
+	if size < 15 {
+		e.b = append(e.b, byte(size<<4)|typ)
+		return
+	}
+	e.b = append(e.b, 0xf0|typ)
+	e.writeUvarint(uint64(size))
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// StructDesc returns the description of the structs in v, which must be a
+// List of structs or a Map, possibly nested, whose values are structs.
+func StructDesc(v types.Value) (types.StructDesc, error) {
+	t := types.TypeOf(v)
+	for {
+		switch t.TargetKind() {
+		case types.ListKind:
+			t = t.Desc.(types.CompoundDesc).ElemTypes[0]
+		case types.MapKind:
+			t = t.Desc.(types.CompoundDesc).ElemTypes[1]
+		case types.StructKind:
+			return t.Desc.(types.StructDesc), nil
+		default:
+			return types.StructDesc{}, fmt.Errorf("expected a List or Map of structs, found %s", types.TypeOf(v).Describe())
+		}
+	}
+}
+
+// WriteList writes the structs in l to w as a Parquet file, with a column
+// per field, see SchemaFromStructDesc.
+func WriteList(l types.List, w io.Writer, opts WriteOptions) error {
+	sd, err := StructDesc(l)
+	if err != nil {
+		return err
+	}
+	pw, err := NewWriter(w, sd, opts)
+	if err != nil {
+		return err
+	}
+	l.IterAll(func(v types.Value, _ uint64) {
+		if err == nil {
+			err = pw.Write(v.(types.Struct))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// WriteMap is like WriteList but writes the struct values of m, in key order.
+// If m is a nested Map, the structs in the innermost Maps are written.
+func WriteMap(m types.Map, w io.Writer, opts WriteOptions) error {
+	sd, err := StructDesc(m)
+	if err != nil {
+		return err
+	}
+	pw, err := NewWriter(w, sd, opts)
+	if err != nil {
+		return err
+	}
+	if err := writeMapValues(m, pw); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+func writeMapValues(m types.Map, pw *Writer) (err error) {
+	m.Iter(func(k, v types.Value) bool {
+		if subMap, ok := v.(types.Map); ok {
+			err = writeMapValues(subMap, pw)
+		} else {
+			err = pw.Write(v.(types.Struct))
+		}
+		return err != nil
+	})
+	return
+}
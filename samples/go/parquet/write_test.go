@@ -0,0 +1,119 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func testRows(n int) []types.Value {
+	rows := make([]types.Value, n)
+	for i := range rows {
+		data := types.StructData{
+			"id":     types.Number(i),
+			"active": types.Bool(i%3 == 0),
+			"data":   types.NewBlob(bytes.NewReader([]byte{byte(i), 0xff})),
+		}
+		if i%2 == 0 {
+			data["name"] = types.String(fmt.Sprintf("row %d", i))
+		}
+		rows[i] = types.NewStruct("Row", data)
+	}
+	return rows
+}
+
+func TestWriteListRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	l := types.NewList(testRows(25)...)
+
+	for _, compression := range []string{"none", "snappy", "gzip"} {
+		buf := &bytes.Buffer{}
+		assert.NoError(WriteList(l, buf, WriteOptions{Compression: compression, RowGroupSize: 10}))
+
+		f, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(err)
+		assert.Equal(int64(25), f.NumRows)
+		assert.Equal(3, f.NumRowGroups())
+
+		kinds := map[string]types.NomsKind{}
+		for _, c := range f.Columns {
+			kinds[c.Name] = c.Kind
+			assert.Equal(c.Name == "name", c.Optional)
+		}
+		assert.Equal(map[string]types.NomsKind{
+			"active": types.BoolKind,
+			"data":   types.BlobKind,
+			"id":     types.NumberKind,
+			"name":   types.StringKind,
+		}, kinds)
+
+		actual, err := ReadToList(f, "Row", f.Columns, 2, types.NewTestValueStore())
+		assert.NoError(err)
+		assert.True(l.Equals(actual), compression)
+	}
+}
+
+func TestWriteMapRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	rows := testRows(10)
+	// A Map of Maps, keyed by whether id is odd and then by id.
+	gb := types.NewGraphBuilder(types.NewTestValueStore(), types.MapKind, false)
+	for _, r := range rows {
+		id := r.(types.Struct).Get("id").(types.Number)
+		gb.MapSet([]types.Value{types.Bool(int(id)%2 == 1)}, id, r)
+	}
+	m := gb.Build().(types.Map)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(WriteMap(m, buf, WriteOptions{}))
+	f, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(err)
+	assert.Equal(1, f.NumRowGroups())
+
+	actual, err := ReadToMap(f, "Row", f.Columns, []string{"id"}, 1, types.NewTestValueStore())
+	assert.NoError(err)
+	assert.Equal(uint64(10), actual.Len())
+	for _, r := range rows {
+		assert.True(r.Equals(actual.Get(r.(types.Struct).Get("id"))))
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	assert := assert.New(t)
+	buf := &bytes.Buffer{}
+
+	err := WriteList(types.NewList(types.Number(1)), buf, WriteOptions{})
+	assert.EqualError(err, "expected a List or Map of structs, found List<Number>")
+
+	nested := types.NewList(types.NewStruct("Row", types.StructData{"l": types.NewList()}))
+	err = WriteList(nested, buf, WriteOptions{})
+	assert.EqualError(err, "field l has type List<>, which can't be written to Parquet")
+
+	mixed := types.NewList(
+		types.NewStruct("Row", types.StructData{"x": types.Number(1)}),
+		types.NewStruct("Row", types.StructData{"x": types.String("one")}),
+	)
+	err = WriteList(mixed, buf, WriteOptions{})
+	assert.EqualError(err, "field x has type Number | String, which can't be written to Parquet")
+
+	l := types.NewList(types.NewStruct("Row", types.StructData{"x": types.Number(1)}))
+	err = WriteList(l, buf, WriteOptions{Compression: "lz4"})
+	assert.EqualError(err, "unknown compression lz4, expected none, snappy or gzip")
+}
+
+func TestEncodeLevels(t *testing.T) {
+	assert := assert.New(t)
+	levels := []int{1, 1, 1, 0, 1, 0, 0}
+	b := encodeLevels(levels)
+	assert.Equal([]byte{3 << 1, 1, 1 << 1, 0, 1 << 1, 1, 2 << 1, 0}, b)
+	decoded, err := decodeLevels(b, 1, len(levels))
+	assert.NoError(err)
+	assert.Equal(levels, decoded)
+}
@@ -0,0 +1,320 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/golang/snappy"
+)
+
+// DefaultRowGroupSize is the number of rows written to each row group if
+// WriteOptions.RowGroupSize isn't set.
+const DefaultRowGroupSize = 64 * 1024
+
+// WriteOptions configures how Parquet files are written.
+type WriteOptions struct {
+	// Compression is the codec pages are compressed with: "none", "snappy"
+	// or "gzip". Empty means "snappy".
+	Compression string
+	// RowGroupSize is the number of rows in each row group.
+	RowGroupSize int
+}
+
+func parseCompression(s string) (int64, error) {
+	switch s {
+	case "none":
+		return codecUncompressed, nil
+	case "", "snappy":
+		return codecSnappy, nil
+	case "gzip":
+		return codecGzip, nil
+	}
+	return 0, fmt.Errorf("unknown compression %s, expected none, snappy or gzip", s)
+}
+
+func compress(codec int64, data []byte) []byte {
+	switch codec {
+	case codecSnappy:
+		return snappy.Encode(nil, data)
+	case codecGzip:
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		_, err := w.Write(data)
+		d.PanicIfError(err)
+		d.PanicIfError(w.Close())
+		return buf.Bytes()
+	}
+	return data
+}
+
+// SchemaFromStructDesc returns the columns a Parquet file of structs
+// described by sd is written with, a column per field. Fields must be Bool,
+// Number, String or Blob, which are written as BOOLEAN, DOUBLE, UTF8
+// BYTE_ARRAY and BYTE_ARRAY columns. Optional fields are written as OPTIONAL
+// columns.
+func SchemaFromStructDesc(sd types.StructDesc) (columns []Column, err error) {
+	sd.IterFields(func(name string, t *types.Type, optional bool) {
+		if err != nil {
+			return
+		}
+		c := Column{Name: name, Kind: t.TargetKind(), Optional: optional, chunk: len(columns)}
+		switch c.Kind {
+		case types.BoolKind:
+			c.physicalType = typeBoolean
+		case types.NumberKind:
+			c.physicalType = typeDouble
+		case types.StringKind, types.BlobKind:
+			c.physicalType = typeByteArray
+		default:
+			err = fmt.Errorf("field %s has type %s, which can't be written to Parquet", name, t.Describe())
+			return
+		}
+		columns = append(columns, c)
+	})
+	return
+}
+
+func (c Column) schemaElement() thriftStruct {
+	el := thriftStruct{
+		schemaElementName:       c.Name,
+		schemaElementType:       int32(c.physicalType),
+		schemaElementRepetition: int32(repetitionRequired),
+	}
+	if c.Optional {
+		el[schemaElementRepetition] = int32(repetitionOptional)
+	}
+	if c.Kind == types.StringKind {
+		el[schemaElementConvertedType] = int32(convertedUTF8)
+		el[schemaElementLogicalType] = thriftStruct{logicalString: thriftStruct{}}
+	}
+	return el
+}
+
+// Writer writes structs to a Parquet file, buffering a row group at a time.
+type Writer struct {
+	w         io.Writer
+	pos       int64
+	name      string
+	columns   []Column
+	codec     int64
+	size      int
+	rows      []types.Struct
+	rowGroups []thriftStruct
+	numRows   int64
+}
+
+// NewWriter returns a Writer which writes structs described by sd to w, see
+// SchemaFromStructDesc.
+func NewWriter(w io.Writer, sd types.StructDesc, opts WriteOptions) (*Writer, error) {
+	columns, err := SchemaFromStructDesc(sd)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := parseCompression(opts.Compression)
+	if err != nil {
+		return nil, err
+	}
+	size := opts.RowGroupSize
+	if size <= 0 {
+		size = DefaultRowGroupSize
+	}
+	pw := &Writer{w: w, name: sd.Name, columns: columns, codec: codec, size: size}
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.pos += int64(n)
+	return err
+}
+
+// Write buffers s as the next row, writing a row group once enough rows are
+// buffered.
+func (w *Writer) Write(s types.Struct) error {
+	w.rows = append(w.rows, s)
+	if len(w.rows) >= w.size {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file metadata. It doesn't close the
+// underlying io.Writer.
+func (w *Writer) Close() error {
+	if len(w.rows) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	schema := make([]thriftStruct, len(w.columns)+1)
+	name := w.name
+	if name == "" {
+		name = "schema"
+	}
+	schema[0] = thriftStruct{schemaElementName: name, schemaElementNumChildren: int32(len(w.columns))}
+	for i, c := range w.columns {
+		schema[i+1] = c.schemaElement()
+	}
+	footer := encodeThriftStruct(thriftStruct{
+		fileMetaDataVersion:   int32(1),
+		fileMetaDataSchema:    schema,
+		fileMetaDataNumRows:   w.numRows,
+		fileMetaDataRowGroups: w.rowGroups,
+		fileMetaDataCreatedBy: "noms parquet-export",
+	})
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	b := make([]byte, 4, 4+len(magic))
+	binary.LittleEndian.PutUint32(b, uint32(len(footer)))
+	return w.write(append(b, magic...))
+}
+
+// flush writes the buffered rows as a row group, with a single data page per
+// column.
+func (w *Writer) flush() error {
+	chunks := make([]thriftStruct, len(w.columns))
+	total := int64(0)
+	for i, c := range w.columns {
+		data, err := w.encodePage(c)
+		if err != nil {
+			return err
+		}
+		compressed := compress(w.codec, data)
+		header := encodeThriftStruct(thriftStruct{
+			pageHeaderType:             int32(pageData),
+			pageHeaderUncompressedSize: int32(len(data)),
+			pageHeaderCompressedSize:   int32(len(compressed)),
+			pageHeaderDataPageHeader: thriftStruct{
+				dataPageHeaderNumValues:               int32(len(w.rows)),
+				dataPageHeaderEncoding:                int32(encodingPlain),
+				dataPageHeaderDefinitionLevelEncoding: int32(encodingRLE),
+				dataPageHeaderRepetitionLevelEncoding: int32(encodingRLE),
+			},
+		})
+
+		offset := w.pos
+		if err := w.write(header); err != nil {
+			return err
+		}
+		if err := w.write(compressed); err != nil {
+			return err
+		}
+		size := int64(len(header) + len(data))
+		total += size
+		chunks[i] = thriftStruct{
+			columnChunkFileOffset: offset,
+			columnChunkMetaData: thriftStruct{
+				columnMetaDataType:                  int32(c.physicalType),
+				columnMetaDataEncodings:             []int32{encodingPlain, encodingRLE},
+				columnMetaDataPathInSchema:          []string{c.Name},
+				columnMetaDataCodec:                 int32(w.codec),
+				columnMetaDataNumValues:             int64(len(w.rows)),
+				columnMetaDataTotalUncompressedSize: size,
+				columnMetaDataTotalCompressedSize:   w.pos - offset,
+				columnMetaDataDataPageOffset:        offset,
+			},
+		}
+	}
+
+	w.rowGroups = append(w.rowGroups, thriftStruct{
+		rowGroupColumns:       chunks,
+		rowGroupTotalByteSize: total,
+		rowGroupNumRows:       int64(len(w.rows)),
+	})
+	w.numRows += int64(len(w.rows))
+	w.rows = w.rows[:0]
+	return nil
+}
+
+// encodePage returns the uncompressed data page of column c of the buffered
+// rows: the definition levels, if c is optional, followed by the PLAIN
+// encoded non-null values.
+func (w *Writer) encodePage(c Column) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var levels []int
+	if c.Optional {
+		levels = make([]int, len(w.rows))
+	}
+	var bits []byte
+	n := 0
+	for i, s := range w.rows {
+		v, ok := s.MaybeGet(c.Name)
+		if !ok {
+			if !c.Optional {
+				return nil, fmt.Errorf("missing value of required column %s", c.Name)
+			}
+			continue
+		}
+		if v.Kind() != c.Kind {
+			return nil, fmt.Errorf("value of column %s has kind %s, expected %s", c.Name, v.Kind(), c.Kind)
+		}
+		if levels != nil {
+			levels[i] = 1
+		}
+
+		switch v := v.(type) {
+		case types.Bool:
+			if n%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v {
+				bits[n/8] |= 1 << uint(n%8)
+			}
+		case types.Number:
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(float64(v)))
+		case types.String:
+			binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(string(v))
+		case types.Blob:
+			b, err := ioutil.ReadAll(v.Reader())
+			if err != nil {
+				return nil, err
+			}
+			binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+			buf.Write(b)
+		}
+		n++
+	}
+	buf.Write(bits)
+
+	if levels == nil {
+		return buf.Bytes(), nil
+	}
+	encoded := encodeLevels(levels)
+	page := make([]byte, 4, 4+len(encoded)+buf.Len())
+	binary.LittleEndian.PutUint32(page, uint32(len(encoded)))
+	return append(append(page, encoded...), buf.Bytes()...), nil
+}
+
+// encodeLevels encodes definition levels of bit width 1 as runs of the same
+// value with the hybrid RLE/bit-packing encoding.
+func encodeLevels(levels []int) []byte {
+	b := []byte{}
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		var h [binary.MaxVarintLen64]byte
+		b = append(b, h[:binary.PutUvarint(h[:], uint64(j-i)<<1)]...)
+		b = append(b, byte(levels[i]))
+		i = j
+	}
+	return b
+}